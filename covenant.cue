@@ -27,10 +27,12 @@ package covenant
 
 // StdlibFunction defines a pure, total, terminating function available
 // for use in derived fact derivations. Functions must not perform I/O,
-// access ports, or reference external state.
+// access ports, or reference external state, except that convert reads
+// exchange rates, which it treats as reference data, from the rates port.
 #StdlibFunction: {
-	args:        1 | 2
-	returns:     "bool" | "number" | "string"
+	// "n" takes any number of arguments.
+	args:        1 | 2 | "n"
+	returns:     "bool" | "number" | "string" | "money"
 	description?: string
 }
 
@@ -77,6 +79,13 @@ package covenant
 		"and": #StdlibFunction & {args: 2, returns: "bool"}
 		"or":  #StdlibFunction & {args: 2, returns: "bool"}
 		"not": #StdlibFunction & {args: 1, returns: "bool"}
+
+		// Scoring
+		"weighted_sum": #StdlibFunction & {args: "n", returns: "number", description: "Sum of weighted terms"}
+		"score":        #StdlibFunction & {args: "n", returns: "string", description: "Label of the band a weighted sum falls in"}
+
+		// Money
+		"convert": #StdlibFunction & {args: 2, returns: "money", description: "Money fact converted into a currency at the rates port's rate"}
 	}
 }
 
@@ -106,24 +115,78 @@ package covenant
 //                    facts whose absence is a valid operational state.
 #OnMissing: "system_error" | "deny" | "skip"
 
+// OnStale governs executor behavior when a port fact's value is older than
+// its max_staleness. A port may report when its value was last true; if it
+// does not, the value is as old as the fetch. Defaults to "refresh".
+//
+//   "refresh" — fetch the fact again instead of using a cached value. If
+//               the port's own value is still too old, deny with FACT_STALE.
+//   "deny"    — produce a denied outcome with FACT_STALE.
+//   "flag"    — use the value and add a STALE_FACT flag verdict.
+#OnStale: "refresh" | "deny" | "flag"
+
 // FactDef declares a named, typed value the system knows about.
 // Facts are immutable for the duration of a single evaluation.
 // The fact set is closed — no facts can be added during evaluation.
 // Every base fact must declare its source.
 #FactDef: {
-	source:       #FactSource
-	required?:    bool | *true
-	on_missing?:  #OnMissing | *"system_error"
+	source:      #FactSource
+	required?:   bool | *true
+	on_missing?: #OnMissing | *"system_error"
+	// max_staleness is a Go duration, e.g. "15m". Port facts only.
+	max_staleness?: string
+	on_stale?:      #OnStale | *"refresh"
+	// The unit of a numeric fact's values. Comparisons convert the fact
+	// and thresholds with units, e.g. greater_than: "30d", to a common
+	// base; a threshold without a unit is in the fact's.
+	unit?: #Unit
+	// A timestamp fact holds an instant, sent as an RFC 3339 timestamp or
+	// a date; requests with an input timestamp that does not parse are
+	// refused with INVALID_TIMESTAMP.
+	type?:        "timestamp"
 	description?: string
 }
 
+// Unit is a unit of duration (ms, s, m, h, d, w), percentage (%, bp for
+// basis points) or size (decimal KB to TB, binary KiB to TiB). Durations
+// compare in seconds, percentages in percentage points and sizes in bytes;
+// quantities of different dimensions never compare.
+#Unit: "ms" | "s" | "m" | "h" | "d" | "w" | "%" | "bp" |
+	"B" | "KB" | "MB" | "GB" | "TB" | "KiB" | "MiB" | "GiB" | "TiB"
+
+// Quantity is a number followed by a unit, e.g. "30d", "99.5%" or "2GiB".
+// Fact values may be quantities too.
+#Quantity: =~"^-?[0-9.e+-]+ ?(ms|s|m|h|d|w|%|bp|B|KB|MB|GB|TB|KiB|MiB|GiB|TiB)$"
+
+// StringCompare modifies how equals and in compare two strings: ignoring
+// case, ignoring leading and trailing white space, and in Unicode
+// normalization form C, so "Café" matches "Cafe\u0301". A condition's set
+// fields override the contract's; strings otherwise compare exactly.
+#StringCompare: {
+	case_insensitive?: bool
+	trim?:             bool
+	nfc?:              bool
+}
+
+// Instant is a timestamp operand: an RFC 3339 timestamp, a date or a local
+// date and time in the contract's timezone, or "now" or "today" (its
+// midnight), optionally offset by a duration, e.g. "today-30d" or "now+1h".
+#Instant: string
+
 // DerivationArg is one argument to a derivation function.
 // Exactly one of fact or value must be set.
 //   fact  — reference to another fact in the fact set (base or derived)
 //   value — a literal value
+//
+// In weighted_sum and score, each argument is a term: a numeric fact, a
+// boolean fact counting 1 when true, or an inline condition {fact, op,
+// value} counting 1 when it holds, multiplied by its weight. Absent facts
+// count 0.
 #DerivationArg: {
-	fact?:  string
-	value?: _
+	fact?:   string
+	op?:     "equals" | "greater_than" | "less_than"
+	value?:  _
+	weight?: number | *1
 }
 
 // Derivation defines how a derived fact is computed.
@@ -131,9 +194,26 @@ package covenant
 // args are evaluated left to right; fact references are resolved
 // from the current fact set at evaluation time.
 // Derived facts must form a DAG — no cycles are permitted.
+//
+// A score derivation declares bands: the score is the label of the band
+// with the highest min its weighted sum reaches, or "none" below them all.
+//
+// A convert derivation converts a money fact, {value, currency}, into the
+// currency named by its second argument at the rate the "rates" port
+// quotes for the pair, e.g. "EUR/USD":
+//   {fn: "convert", args: [{fact: "payment.amount"}, {value: "USD"}]}
+// It is absent when the fact is not money; a missing rate makes it
+// unavailable (FACT_UNAVAILABLE).
 #Derivation: {
-	fn:   string
-	args: [...#DerivationArg]
+	fn:     string
+	args:   [...#DerivationArg]
+	bands?: [...#ScoreBand]
+}
+
+// ScoreBand labels the weighted sums from min up to the next band's min.
+#ScoreBand: {
+	label: string
+	min:   number
 }
 
 // DerivedFactDef declares a fact that is computed from other facts
@@ -165,6 +245,12 @@ package covenant
 // Tooling must verify that all states are reachable and that terminal
 // states are reachable from all non-terminal states.
 #EntityDef: {
+	// The input fact identifying an instance, e.g. "invoice.id". Required
+	// for rules and derived facts to read entity:<entity>.state, which the
+	// executor resolves through its state store; an instance the store does
+	// not track is in the initial state.
+	key?: string
+
 	// All valid states. Undeclared states are invalid.
 	states: [...string]
 
@@ -185,13 +271,39 @@ package covenant
 // No computed facts. No rule-to-rule dependencies. No mutation.
 // Evaluation order does not matter — rules are independent.
 #Condition: {
-	// Leaf condition: evaluate a single fact
+	// Leaf condition: evaluate a single fact. "entity:<entity>.state" reads
+	// the tracked state of the entity instance the request is about; see
+	// #EntityDef.key.
 	fact?:         string
 	equals?:       _
 	not_equals?:   _
-	greater_than?:  number
-	less_than?:    number
+	greater_than?: number | #Quantity
+	less_than?:    number | #Quantity
 	in?:           [..._]
+	contains?:     _ // list element, or substring of a string fact
+	// How equals and in compare strings, over the contract's
+	// string_compare.
+	compare?: #StringCompare
+
+	// Timestamp leaf conditions compare a fact of type "timestamp"; facts
+	// and operands that are not timestamps never compare.
+	before?:  #Instant
+	after?:   #Instant
+	between?: [#Instant, #Instant] // from inclusive, to exclusive
+
+	// Set leaf conditions compare a list fact, e.g. user.roles or
+	// invoice.tags, with a list of values; facts that are not lists never
+	// compare.
+	subset_of?:    [..._] // every element of the fact is listed
+	intersects?:   [..._] // some element of the fact is listed
+	contains_all?: [..._] // every listed value is an element of the fact
+
+	// Identity leaf conditions read the caller's identity ctx facts and
+	// take no fact; the contract must declare the fact they read.
+	has_role?:        _      // user.roles contains it
+	has_scope?:       _      // user.scopes contains it
+	subject_matches?: string // user.subject matches this glob pattern
+	tenant_equals?:   _      // user.tenant equals it
 
 	// Composite conditions
 	all?: [...#Condition]
@@ -235,11 +347,39 @@ package covenant
 }
 
 // EscalateVerdict queues the operation for human review. No side effects occur.
+// If the contract declares queues, queue must name one of them.
 #EscalateVerdict: {
 	queue:  string
 	reason: string
 }
 
+// QueueDef declares an escalation queue in the contract's queue catalog,
+// keyed by queue name. Discovery publishes the catalog so downstream routing
+// knows who owns each queue and how fast it must be worked.
+#QueueDef: {
+	sla:          string // Go duration, e.g. "4h"
+	owner:        string // accountable team
+	description?: string
+
+	// An escalation still pending hard_expiry (a Go duration no shorter
+	// than sla) after it was queued is resolved by the executor: denied,
+	// or approved and executed. Without it escalations wait indefinitely.
+	hard_expiry?: string
+	on_expiry?:   "deny" | "execute"
+}
+
+// ExperimentDef evaluates shares of traffic with other param values, e.g. a
+// limit of 10000 against 15000. Requests are assigned an arm by a hash of
+// the experiment name and the key input fact, in proportion to the arms'
+// weights; the arm is recorded in the audit record and decision events.
+#ExperimentDef: {
+	key: string
+	arms: {[arm=string]: {
+		weight: number & >0
+		params: {[param=string]: _}
+	}}
+}
+
 // RequireVerdict returns additional conditions to the agent.
 // The agent must satisfy these before the operation can proceed.
 // No side effects occur.
@@ -262,6 +402,11 @@ package covenant
 	escalate?: #EscalateVerdict
 	require?:  #RequireVerdict
 	flag?:     #FlagVerdict
+
+	// Structured data passed through unchanged to the verdict in
+	// responses, audit records and decision events, e.g. the owning team,
+	// a documentation URL or a risk tier.
+	metadata?: {...}
 }
 
 // RuleDef encodes a business policy. Rules are declarative, non-Turing
@@ -277,6 +422,36 @@ package covenant
 	when:        #Condition
 	verdict:     #VerdictDef
 	description?: string
+	// Applies the rule to a stable share of requests only, bucketed by the
+	// value of key, e.g. rollout: {percent: 10, key: "customer.id"}. For the
+	// rest a match is audited as a shadow verdict without effect.
+	rollout?: #RolloutDef
+	// Lint rule IDs not to report for this rule.
+	lint_ignore?: [...#LintRuleID]
+}
+
+#RolloutDef: {
+	percent: number & >=0 & <=100
+	key:     string
+}
+
+// LintRuleID names a lint rule of the validator.
+//   "deny-suggestion"           — a deny verdict's error must carry a
+//                                 suggestion (default: warning).
+//   "client-error-status"       — a deny error in a client category
+//                                 (validation, business_rule_violation,
+//                                 authorization) must have a 4xx http_status
+//                                 (default: error).
+//   "escalate-queue-registered" — an escalate verdict must name a queue in
+//                                 the queue catalog; loading enforces this
+//                                 once a catalog is declared (default:
+//                                 warning).
+#LintRuleID: "deny-suggestion" | "client-error-status" | "escalate-queue-registered"
+
+// LintConfig tunes the validator's lint rules. Imported contracts' settings
+// apply unless the importer sets its own severity for a rule.
+#LintConfig: {
+	severity?: {[#LintRuleID]: "error" | "warning" | "off"}
 }
 
 // ─── OPERATIONS ──────────────────────────────────────────────────────────────
@@ -331,6 +506,89 @@ package covenant
 	}
 
 	min_executor_version?: string
+
+	// Caller authorization, checked before fact gathering. requires may
+	// read only ctx facts and params, e.g.
+	//   authorize: requires: {fact: "user.roles", contains: "billing_admin"}
+	// A refused caller gets error, or a FORBIDDEN envelope with status 403.
+	authorize?: #AuthorizeDef
+
+	// How an execution is compensated, reported to the caller with each
+	// executed response. Operations that cannot be compensated declare
+	// irreversible: true.
+	undo?: #UndoDef
+
+	// Earlier executions the operation must follow, checked against the
+	// execution history before fact gathering, e.g.
+	//   requires: [{operation: "ProcessPayment", reference: "payment.invocation_id", entity: "invoice"}]
+	// A request without one is denied with error, or a
+	// PREREQUISITE_NOT_MET envelope with status 422.
+	requires?: [...#PrerequisiteDef]
+
+	// Likely resubmissions of a recent execution: the same values of the
+	// key input facts within window, e.g.
+	//   duplicates: {keys: ["invoice.id", "payment.amount"], window: "10m", action: "deny"}
+	// A match adds a POSSIBLE_DUPLICATE flag, or a denial with status 409.
+	duplicates?: #DuplicateDef
+
+	// Currencies the operation accepts for its money facts, e.g.
+	//   currencies: {accepted: ["USD", "EUR"], facts: ["payment.amount", "invoice.balance"], match: true}
+	// A money fact in another currency is denied with
+	// CURRENCY_NOT_ACCEPTED, and with match, facts in different currencies
+	// with CURRENCY_MISMATCH, both with status 422.
+	currencies?: #CurrencyDef
+}
+
+// PrerequisiteDef names an operation that must have been executed before
+// another. reference is the input fact carrying the invocation ID of that
+// execution; with entity, it must have been on the same instance, judged
+// by the entity's key.
+#PrerequisiteDef: {
+	operation: string
+	reference: string
+	entity?:   string
+	error?:    #ErrorEnvelope
+}
+
+// DuplicateDef is a heuristic for clients that retry without an
+// idempotency key; it is checked against the execution history.
+#DuplicateDef: {
+	keys:    [string, ...string]
+	window:  string
+	action?: "flag" | "deny" | *"flag"
+	error?:  #ErrorEnvelope
+}
+
+// CurrencyDef declares the ISO 4217 codes an operation accepts; empty
+// accepts any. facts are base money facts, checked before any derivation
+// converts them; absent ones are left to their on_missing policy.
+#CurrencyDef: {
+	accepted?: [...=~"^[A-Z]{3}$"]
+	facts:     [string, ...string]
+	match?:    bool | *false
+}
+
+// UndoDef names the operation that reverses another, e.g.
+//   undo: {operation: "RefundPayment", window: "720h",
+//          input: {"payment.id": "output.payment_id"}}
+#UndoDef: {
+	operation: string
+	// Go duration after execution within which it can be undone; absent
+	// means no limit.
+	window?: string
+	// Undo input facts, each taken from the executed operation's input
+	// ("input.<fact>") or output ("output.<field>").
+	input?: {[fact=string]: =~"^(input|output)\\."}
+} | {
+	irreversible: true
+}
+
+// AuthorizeDef gates an operation on its caller. Unlike personas, which
+// declare what an identity may invoke, it is enforced by the executor on
+// every invocation.
+#AuthorizeDef: {
+	requires: #Condition
+	error?:   #ErrorEnvelope
 }
 
 // ─── PERSONAS ────────────────────────────────────────────────────────────────
@@ -344,6 +602,10 @@ package covenant
 // Tooling must verify that every operation referenced in any flow is
 // present in the can list of the flow's persona. An operation that no
 // persona can invoke is dead code.
+//
+// Once personas are declared, every has_role condition must name one, and
+// an authorize block admitting a persona by has_role should be for an
+// operation in that persona's can list.
 #PersonaDef: {
 	description:   string
 	can:           [...string] // operation names
@@ -444,6 +706,23 @@ package covenant
 	operations:    {[name=string]: #OperationDef}
 	flows:         [...#FlowDef]
 	personas:      {[name=string]: #PersonaDef}
+	queues?:       {[name=string]: #QueueDef}
+	experiments?:  {[name=string]: #ExperimentDef}
+	lint?:         #LintConfig
+
+	// IANA timezone, e.g. "Europe/Paris", that dates, local times and
+	// "today" are read in. Defaults to UTC.
+	timezone?: string
+
+	// Default string comparison for equals and in conditions.
+	string_compare?: #StringCompare
+
+	// Purpose limitation: restricted facts mapped to the purposes they may
+	// be used for. A request whose purpose is not listed for a fact its
+	// operation needs, directly or through a derived fact, is refused with
+	// PURPOSE_NOT_PERMITTED before any fact is fetched. Unlisted facts may
+	// be used for any purpose. The request's purpose is recorded in audit.
+	data_use?: {[fact=string]: [...string]}
 }
 
 // ─── EVALUATION ALGORITHM ─────────────────────────────────────────────────────
@@ -455,6 +734,12 @@ package covenant
 // 1. GATHER base facts
 //    - input facts: validate against operation input schema
 //    - ctx facts: collect from execution context
+//    - authorize: if the operation declares an authorize block, evaluate
+//      it against the ctx facts and params; if it fails, return its error
+//      envelope (403 by default) before any port is consulted
+//    - purpose: if data_use restricts a fact the operation needs and the
+//      request's purpose is not among its purposes, return
+//      PURPOSE_NOT_PERMITTED before any port is consulted
 //    - port facts: fetch from named port adapters; apply on_missing policy on failure
 //
 // 2. DERIVE computed facts
@@ -527,6 +812,10 @@ package covenant
 		stdlib_version: #StdlibVersion
 	}
 
+	// Wire protocol versions the executor serves, oldest first.
+	// Absent means version 1 only.
+	protocol_versions?: [...int]
+
 	// Informational only. Do not use for policy decisions.
 	runtime?: {
 		active_flows?:   [...string]
@@ -557,6 +846,17 @@ package covenant
 	// Agents that cache contracts SHOULD include this field — it enables
 	// the executor to detect races between contract re-fetch and invocation.
 	contract_etag?: string
+
+	// Wire protocol version the client speaks. Absent means version 1.
+	// The executor answers in this version, or in its newest if older,
+	// and rejects versions it no longer serves with HTTP 400.
+	protocol_version?: int
+
+	// Session the request belongs to, as opened with POST /sessions.
+	// Port facts fetched earlier in the session may be reused, and the
+	// decision is added to the session's transcript. Executors MUST reject
+	// an unknown or expired session with HTTP 404.
+	session_id?: string
 }
 
 // ExecuteOutcome is the result of evaluation.
@@ -565,21 +865,40 @@ package covenant
 //   "escalated"      — an escalate verdict was produced, no side effects
 //   "required"       — a require verdict was produced, no side effects
 //   "system_error"   — fact unavailable or executor error, no side effects
+//   "state_conflict" — an entity instance the operation transitions moved
+//                      while the request was evaluated; no side effects,
+//                      retryable
+//   "throttled"      — the request's priority lane was full; no side
+//                      effects, retryable
 //   "dry_run"        — dry-run completed, no side effects
 //   "would_execute"  — dry-run: would have executed
+//   "would_execute_with_flags"
+//                    — dry-run: would have executed, with flag verdicts
 //   "would_deny"     — dry-run: would have been denied
 //   "would_escalate" — dry-run: would have been escalated
 //   "would_require"  — dry-run: would have required additional conditions
-#ExecuteOutcome: "executed" | "denied" | "escalated" | "required" | "system_error" | "dry_run" | "would_execute" | "would_deny" | "would_escalate" | "would_require"
+#ExecuteOutcome: "executed" | "denied" | "escalated" | "required" | "system_error" | "state_conflict" | "throttled" | "dry_run" | "would_execute" | "would_execute_with_flags" | "would_deny" | "would_escalate" | "would_require"
 
 // ExecuteResponse is the response from POST /execute.
 #ExecuteResponse: {
 	outcome:  #ExecuteOutcome
 	dry_run?: bool
 
+	// Wire protocol version of this response; absent for version 1.
+	// Version 1 responses report state_conflict as system_error.
+	protocol_version?: int
+
 	// Present on successful execution.
 	output?: {...}
 
+	// Present on successful execution of an operation that declares undo.
+	undo?: {
+		irreversible?: bool
+		operation?:    string
+		input?: {...}
+		deadline?: string // ISO 8601 UTC; absent means no limit
+	}
+
 	// Present on deny, escalate, require, or system_error.
 	error?: #ErrorEnvelope & {
 		// FACT_UNAVAILABLE indicates a port fact could not be gathered.
@@ -8,19 +8,45 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "record":
+			record(os.Args[2:])
+			return
+		case "replay":
+			replay(os.Args[2:])
+			return
+		}
+	}
+
 	op := flag.String("op", "", "Operation name (e.g. ProcessPayment, GetInvoice)")
 	customerID := flag.String("customer", "cust_123", "Customer ID")
 	invoiceID := flag.String("invoice", "inv_001", "Invoice ID")
 	amount := flag.Float64("amount", 100.0, "Payment amount (USD)")
 	dryRun := flag.Bool("dry-run", false, "Dry run — evaluate rules only, no side effects")
+	versionRange := flag.String("version", "", "Contract version range to negotiate (e.g. ^1.0); default is the active contract")
+	priority := flag.String("priority", "", "Evaluation priority: interactive (default) or batch")
+	purpose := flag.String("purpose", "", "Purpose of the request, checked against the contract's data_use policy (e.g. billing)")
 	executorURL := flag.String("executor", "http://localhost:26860", "Executor base URL")
 	contractURL := flag.String("contracts", "http://localhost:26861", "Contract server base URL")
+	exportFormat := flag.String("export", "", "Export decision history instead of executing: ndjson or parquet")
+	exportFrom := flag.String("from", "", "Export decisions at or after this RFC 3339 time")
+	exportTo := flag.String("to", "", "Export decisions before this RFC 3339 time (default now)")
+	exportOut := flag.String("out", "", "Write the export to this file (default stdout)")
 	flag.Parse()
 
+	if *exportFormat != "" {
+		if err := exportDecisions(*executorURL, *exportFormat, *exportFrom, *exportTo, *exportOut); err != nil {
+			log.Fatalf("Export: %v", err)
+		}
+		return
+	}
+
 	if *op == "" {
 		fmt.Fprintln(os.Stderr, "Error: --op is required")
 		fmt.Fprintln(os.Stderr, "\nOperations: ProcessPayment, GetInvoice")
@@ -55,6 +81,18 @@ func main() {
 		"dry_run":       *dryRun,
 		"contract_etag": disc.ContractETag,
 	}
+	if *versionRange != "" {
+		// The discovery ETag belongs to the active contract; a negotiated
+		// version may be an older one, so let the executor pick.
+		delete(req, "contract_etag")
+		req["version_range"] = *versionRange
+	}
+	if *priority != "" {
+		req["priority"] = *priority
+	}
+	if *purpose != "" {
+		req["purpose"] = *purpose
+	}
 
 	if *dryRun {
 		fmt.Printf("Dry run: %s\n", *op)
@@ -110,6 +148,45 @@ func execute(baseURL string, req map[string]any) (map[string]any, error) {
 	return result, nil
 }
 
+// exportDecisions streams the executor's decision history for a time range
+// to out, or stdout if out is empty.
+func exportDecisions(baseURL, format, from, to, out string) error {
+	q := url.Values{"format": {format}}
+	if from != "" {
+		q.Set("from", from)
+	}
+	if to != "" {
+		q.Set("to", to)
+	}
+	resp, err := http.Get(baseURL + "/decisions/export?" + q.Encode())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+
+	w := io.Writer(os.Stdout)
+	if out != "" {
+		f, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	n, err := io.Copy(w, resp.Body)
+	if err != nil {
+		return err
+	}
+	if out != "" {
+		fmt.Fprintf(os.Stderr, "Wrote %d bytes to %s\n", n, out)
+	}
+	return nil
+}
+
 func printResponse(resp map[string]any) {
 	outcome, _ := resp["outcome"].(string)
 
@@ -148,6 +225,9 @@ func printResponse(resp map[string]any) {
 		fmt.Printf("Outcome: %s\n", outcome)
 		if e, ok := resp["error"].(map[string]any); ok {
 			fmt.Printf("  Error: %v\n", e["message"])
+			if d, ok := e["details"].(map[string]any); ok && d["available"] != nil {
+				fmt.Printf("  Available versions: %v\n", d["available"])
+			}
 		}
 	}
 
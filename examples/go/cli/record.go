@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// capture is one recorded /execute exchange, a line of a capture file.
+type capture struct {
+	Time     time.Time       `json:"time"`
+	Request  json.RawMessage `json:"request"`
+	Status   int             `json:"status"`
+	Response json.RawMessage `json:"response"`
+}
+
+// maxCaptureBody bounds the request and response bodies recorded.
+const maxCaptureBody = 1 << 20
+
+// record runs `cli record`: a proxy in front of an executor that forwards
+// all traffic and appends each /execute exchange to a capture file.
+func record(args []string) {
+	fs := flag.NewFlagSet("record", flag.ExitOnError)
+	listen := fs.String("listen", ":26870", "Address to accept traffic on, in front of the executor")
+	executorURL := fs.String("executor", "http://localhost:26860", "Executor base URL to forward traffic to")
+	out := fs.String("out", "capture.jsonl", "Capture file to append /execute requests and responses to, one JSON object per line")
+	fs.Parse(args)
+
+	target, err := url.Parse(*executorURL)
+	if err != nil {
+		log.Fatalf("--executor: %v", err)
+	}
+	f, err := os.OpenFile(*out, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		log.Fatalf("Open capture file: %v", err)
+	}
+	defer f.Close()
+
+	rec := &recorder{proxy: httputil.NewSingleHostReverseProxy(target), enc: json.NewEncoder(f)}
+	log.Printf("Recording /execute traffic to %s: listening on %s, forwarding to %s", *out, *listen, *executorURL)
+	log.Fatal(http.ListenAndServe(*listen, rec))
+}
+
+// recorder forwards requests to the executor, capturing /execute ones.
+type recorder struct {
+	proxy *httputil.ReverseProxy
+
+	mu  sync.Mutex
+	enc *json.Encoder
+	n   int
+}
+
+func (rec *recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/execute" {
+		rec.proxy.ServeHTTP(w, r)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxCaptureBody+1))
+	if err != nil || len(body) > maxCaptureBody {
+		http.Error(w, "unreadable or oversized request", http.StatusBadRequest)
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	tee := &teeWriter{ResponseWriter: w}
+	rec.proxy.ServeHTTP(tee, r)
+
+	// Only exchanges the executor answered are worth replaying.
+	if !json.Valid(body) || !json.Valid(tee.body.Bytes()) {
+		return
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	err = rec.enc.Encode(capture{Time: time.Now().UTC(), Request: body, Status: tee.status, Response: tee.body.Bytes()})
+	if err != nil {
+		log.Printf("Capture: %v", err)
+		return
+	}
+	rec.n++
+	if rec.n%100 == 0 {
+		log.Printf("Captured %d requests", rec.n)
+	}
+}
+
+// teeWriter keeps a copy of the response it writes, up to maxCaptureBody.
+type teeWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *teeWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *teeWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.body.Len()+len(p) <= maxCaptureBody {
+		w.body.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *teeWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// readCaptures reads the captures in a file.
+func readCaptures(path string) ([]capture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var out []capture
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for dec.More() {
+		var c capture
+		if err := dec.Decode(&c); err != nil {
+			return nil, fmt.Errorf("%s: capture %d: %w", path, len(out)+1, err)
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+)
+
+// replay runs `cli replay`: it re-sends captured /execute requests to an
+// executor, or to another contract version, and reports those whose
+// outcome or verdicts differ from the recording. It exits 1 if any do.
+func replay(args []string) {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	executorURL := fs.String("executor", "http://localhost:26860", "Executor base URL to replay the captures against")
+	versionRange := fs.String("version", "", "Contract version range to replay against (e.g. ^2.0); default the executor's active contract")
+	live := fs.Bool("live", false, "Replay live requests live, executing them again; by default every request is replayed as a dry run, with no side effects")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: cli replay [flags] capture.jsonl...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	var replayed, changed, skipped int
+	for _, path := range fs.Args() {
+		captures, err := readCaptures(path)
+		if err != nil {
+			log.Fatalf("Read captures: %v", err)
+		}
+		for i, c := range captures {
+			var req, recorded map[string]any
+			if json.Unmarshal(c.Request, &req) != nil || json.Unmarshal(c.Response, &recorded) != nil || recorded["outcome"] == nil {
+				skipped++
+				continue
+			}
+			want := summarize(recorded)
+			// The recording was decided by another contract, and its
+			// idempotency key would return the recorded response.
+			delete(req, "contract_etag")
+			delete(req, "idempotency_key")
+			if *versionRange != "" {
+				req["version_range"] = *versionRange
+			}
+			if dryRun, _ := req["dry_run"].(bool); !dryRun && !*live {
+				req["dry_run"] = true
+				want = want.asDryRun()
+			}
+
+			resp, err := execute(*executorURL, req)
+			if err != nil {
+				log.Fatalf("%s: capture %d: %v", path, i+1, err)
+			}
+			replayed++
+			got := summarize(resp)
+			if got.equal(want) {
+				continue
+			}
+			changed++
+			fmt.Printf("%s:%d %v: %s → %s\n", path, i+1, req["operation"], want.outcome, got.outcome)
+			if !slices.Equal(want.verdicts, got.verdicts) {
+				fmt.Printf("    verdicts: [%s] → [%s]\n", strings.Join(want.verdicts, ", "), strings.Join(got.verdicts, ", "))
+			}
+			if want.errorCode != got.errorCode {
+				fmt.Printf("    error:    %q → %q\n", want.errorCode, got.errorCode)
+			}
+		}
+	}
+	fmt.Printf("Replayed %d requests against %s: %d changed", replayed, *executorURL, changed)
+	if skipped > 0 {
+		fmt.Printf(", %d unreadable captures skipped", skipped)
+	}
+	fmt.Println()
+	if changed > 0 {
+		os.Exit(1)
+	}
+}
+
+// decision is what replay compares of a response.
+type decision struct {
+	outcome   string
+	verdicts  []string // "type CODE", sorted
+	errorCode string
+}
+
+func summarize(resp map[string]any) decision {
+	d := decision{outcome: fmt.Sprint(resp["outcome"])}
+	if vs, ok := resp["verdicts"].([]any); ok {
+		for _, v := range vs {
+			vm, _ := v.(map[string]any)
+			if shadow, _ := vm["shadow"].(bool); shadow {
+				continue
+			}
+			d.verdicts = append(d.verdicts, fmt.Sprintf("%v %v", vm["type"], vm["code"]))
+		}
+		slices.Sort(d.verdicts)
+	}
+	if e, ok := resp["error"].(map[string]any); ok {
+		d.errorCode = fmt.Sprint(e["code"])
+	}
+	return d
+}
+
+// asDryRun is what a dry run of a recorded live request would have
+// answered: the same verdicts, an outcome saying what would happen, and no
+// error, which dry runs carry on their verdicts instead.
+func (d decision) asDryRun() decision {
+	switch d.outcome {
+	case "executed":
+		d.outcome = "would_execute"
+		if slices.ContainsFunc(d.verdicts, func(v string) bool { return strings.HasPrefix(v, "flag ") }) {
+			d.outcome = "would_execute_with_flags"
+		}
+	case "denied":
+		d.outcome = "would_deny"
+	case "escalated":
+		d.outcome = "would_escalate"
+	case "required":
+		d.outcome = "would_require"
+	default:
+		return d
+	}
+	d.errorCode = ""
+	return d
+}
+
+func (d decision) equal(o decision) bool {
+	return d.outcome == o.outcome && d.errorCode == o.errorCode && slices.Equal(d.verdicts, o.verdicts)
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"covenant-poc/executor/engine"
+	"covenant-poc/executor/rbac"
+)
+
+// draftDir holds the domain's draft channel: a full copy of the domain with
+// changes not yet promoted. With no draft directory, the draft channel is
+// the published contract. Base contracts are shared by both channels.
+func (s *contractServer) draftDir() string { return s.domain + ".draft" }
+
+// hasDraft reports whether the domain has unpromoted changes.
+func (s *contractServer) hasDraft() (bool, error) {
+	_, err := os.Stat(filepath.Join(s.dir, s.draftDir()))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// channels returns the "channels" object of a discovery document, given
+// the files of the published contract.
+func (s *contractServer) channels(published *contractFiles) (map[string]any, error) {
+	draft := published
+	ok, err := s.hasDraft()
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		if draft, err = s.listFiles(s.draftDir()); err != nil {
+			return nil, err
+		}
+	}
+	channel := func(cf *contractFiles) map[string]any {
+		return map[string]any{"contract_etag": cf.etag, "contracts": cf, "queues": s.queues(cf)}
+	}
+	return map[string]any{
+		engine.ChannelPublished: channel(published),
+		engine.ChannelDraft:     channel(draft),
+	}, nil
+}
+
+// seedDraft starts a draft as a copy of the published domain.
+func (s *contractServer) seedDraft() error {
+	defer s.changed()
+	src, err := filepath.EvalSymlinks(filepath.Join(s.dir, s.domain))
+	if err != nil {
+		return err
+	}
+	dst := filepath.Join(s.dir, s.draftDir())
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return os.MkdirAll(filepath.Join(dst, rel), 0o755)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dst, rel), data, 0o644)
+	})
+}
+
+// promoteResponse answers a promotion.
+type promoteResponse struct {
+	Domain   string        `json:"domain"`
+	ETag     string        `json:"contract_etag,omitempty"`
+	Proposal string        `json:"proposal,omitempty"`
+	Report   *domainReport `json:"report"`
+}
+
+// handlePromote replaces the published contract with the draft. The
+// If-Match must name the draft's contract ETag, so what is promoted is
+// what the caller validated and simulated, and the draft must pass the
+// same validation as --validate. When approvals are required, that draft
+// must also have an approved proposal.
+func (p *publisher) handlePromote(w http.ResponseWriter, r *http.Request) {
+	who, ok := p.authorize(w, r, rbac.Admin)
+	if !ok {
+		return
+	}
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		http.Error(w, "If-Match with the draft's contract ETag is required", http.StatusPreconditionRequired)
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	srv := p.srv
+	if ok, err := srv.hasDraft(); err != nil || !ok {
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		} else {
+			http.Error(w, "no draft to promote", http.StatusNotFound)
+		}
+		return
+	}
+	draft, err := srv.listFiles(srv.draftDir())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !etagMatches(ifMatch, draft.etag) {
+		w.Header().Set("ETag", `"`+draft.etag+`"`)
+		http.Error(w, "draft changed since "+ifMatch, http.StatusPreconditionFailed)
+		return
+	}
+
+	pr := p.proposals.approvedFor(draft.etag)
+	if pr == nil && p.proposals.required > 0 {
+		http.Error(w, fmt.Sprintf("draft %s needs an approved proposal (%d approvals) before it is promoted", draft.etag, p.proposals.required), http.StatusConflict)
+		return
+	}
+
+	report := srv.validateDomain(srv.draftDir(), time.Now())
+	resp := promoteResponse{Domain: srv.domain, Report: &report}
+	if !report.Valid {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	before, err := srv.listFiles(srv.domain)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := srv.swapInDraft(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	after, err := srv.listFiles(srv.domain)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp.ETag = after.etag
+
+	rec := publishRecord{
+		At:        time.Now().UTC(),
+		Publisher: who,
+		Method:    "PROMOTE",
+		Path:      srv.draftDir(),
+		Domain:    srv.domain,
+		PrevETag:  before.etag,
+		ETag:      after.etag,
+	}
+	if pr != nil {
+		resp.Proposal, rec.Proposal = pr.ID, pr.ID
+		p.proposals.transition(pr, proposalPromoted, rec.At)
+	}
+	p.draftChanged()
+	if err := p.record(rec); err != nil {
+		log.Printf("publish audit log: %v", err)
+	}
+	slog.Info("contract promoted", "draft", srv.draftDir(), "domain", srv.domain, "publisher", who, "proposal", rec.Proposal, "prev_etag", before.etag, "etag", after.etag)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", `"`+after.etag+`"`)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// While the write API is on, the published domain is a symbolic link to a
+// hidden version directory, .<domain>.v<n>, so that a promotion replaces it
+// with one rename and readers always find it.
+
+// versionDir returns a new name for a version directory of the domain.
+func (s *contractServer) versionDir() string {
+	return fmt.Sprintf(".%s.v%d", s.domain, time.Now().UnixNano())
+}
+
+// linkPublished moves a published domain kept as a plain directory into a
+// version directory behind a link. It runs at startup, before anything is
+// served.
+func (s *contractServer) linkPublished() error {
+	pub := filepath.Join(s.dir, s.domain)
+	fi, err := os.Lstat(pub)
+	if os.IsNotExist(err) || err == nil && fi.Mode()&fs.ModeSymlink != 0 {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	version := s.versionDir()
+	if err := os.Rename(pub, filepath.Join(s.dir, version)); err != nil {
+		return err
+	}
+	return os.Symlink(version, pub)
+}
+
+// swapInDraft makes the draft directory a version of the domain and points
+// the published link at it with a single rename, so a fetch sees either
+// the old contract or the new one. The version it replaces is kept until
+// the next promotion, for listings still reading it.
+func (s *contractServer) swapInDraft() error {
+	defer s.changed()
+	pub := filepath.Join(s.dir, s.domain)
+	prev, err := os.Readlink(pub)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("published domain %s is not a link: %w", s.domain, err)
+	}
+	version := s.versionDir()
+	draft := filepath.Join(s.dir, s.draftDir())
+	if err := os.Rename(draft, filepath.Join(s.dir, version)); err != nil {
+		return err
+	}
+	link := filepath.Join(s.dir, "."+s.domain+".link")
+	os.Remove(link) // left by a failed promotion
+	err = os.Symlink(version, link)
+	if err == nil {
+		err = os.Rename(link, pub)
+	}
+	if err != nil {
+		os.Remove(link)
+		if rerr := os.Rename(filepath.Join(s.dir, version), draft); rerr != nil {
+			log.Printf("restore %s: %v", draft, rerr)
+		}
+		return err
+	}
+	old, err := filepath.Glob(filepath.Join(s.dir, "."+s.domain+".v*"))
+	if err != nil {
+		return err
+	}
+	for _, dir := range old {
+		if name := filepath.Base(dir); name != version && name != filepath.Base(prev) {
+			if err := os.RemoveAll(dir); err != nil {
+				log.Printf("remove %s: %v", dir, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"covenant-poc/executor/engine"
+)
+
+// diagnosticsRequest carries contract files being edited, keyed by their
+// path under the contracts directory, such as "billing/rules.cue". They
+// replace the server's copies; other files of the domain and its imports
+// are read from the contracts directory.
+type diagnosticsRequest struct {
+	Files map[string]string `json:"files"`
+}
+
+type diagnosticsResponse struct {
+	Valid       bool                `json:"valid"`
+	Diagnostics []engine.Diagnostic `json:"diagnostics"`
+}
+
+func (s *contractServer) handleDiagnostics(w http.ResponseWriter, r *http.Request) {
+	var req diagnosticsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	overlay := map[string]map[string][]byte{} // by directory
+	for name, data := range req.Files {
+		dir, file, ok := strings.Cut(path.Clean(name), "/")
+		if !ok || !filepath.IsLocal(name) || strings.Contains(file, "/") || path.Ext(file) != ".cue" {
+			http.Error(w, fmt.Sprintf("file %q: expected <domain>/<file>.cue", name), http.StatusBadRequest)
+			return
+		}
+		if overlay[dir] == nil {
+			overlay[dir] = map[string][]byte{}
+		}
+		overlay[dir][dir+"/"+file] = []byte(data)
+	}
+	if len(overlay) != 1 {
+		http.Error(w, "files must all belong to one domain", http.StatusBadRequest)
+		return
+	}
+	var domain string
+	for domain = range overlay {
+	}
+
+	diags, _, err := s.diagnose(domain, overlay, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(diagnosticsResponse{Valid: !hasErrors(diags), Diagnostics: diags})
+}
+
+// diagnose compiles domain with overlay laid over the contracts directory
+// and returns its compile errors, or else the validator's findings located
+// where possible, along with the compiled contract. Overlay files are keyed
+// by directory and then <dir>/<file>; a nil file is treated as deleted.
+func (s *contractServer) diagnose(domain string, overlay map[string]map[string][]byte, now time.Time) ([]engine.Diagnostic, *engine.Contract, error) {
+	load := func(dir string) (map[string][]byte, error) {
+		files, err := s.readDomain(dir)
+		if err != nil {
+			return nil, err
+		}
+		for name, data := range overlay[dir] {
+			if data == nil {
+				delete(files, name)
+			} else {
+				files[name] = data
+			}
+		}
+		return files, nil
+	}
+	files, err := load(domain)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	diags := []engine.Diagnostic{}
+	c, err := engine.CompileSources(files, load)
+	if err != nil {
+		return append(diags, engine.ErrorDiagnostics(err)...), nil, nil
+	}
+	for _, d := range engine.Validate(c, now) {
+		if d.Rule != "" && d.File == "" {
+			d.File, d.Line, d.Column = locateRule(files, d.Rule)
+		}
+		diags = append(diags, d)
+	}
+	return diags, c, nil
+}
+
+func hasErrors(diags []engine.Diagnostic) bool {
+	for _, d := range diags {
+		if d.Severity == engine.SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// readDomain returns the .cue files directly in a domain directory, keyed
+// by <domain>/<file>. A directory that does not exist has no files.
+func (s *contractServer) readDomain(dir string) (map[string][]byte, error) {
+	paths, err := filepath.Glob(filepath.Join(s.dir, dir, "*.cue"))
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string][]byte, len(paths))
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		files[dir+"/"+filepath.Base(p)] = data
+	}
+	return files, nil
+}
+
+// locateRule finds where rule id is declared, by its id: "<id>" field.
+func locateRule(files map[string][]byte, id string) (string, int, int) {
+	re := regexp.MustCompile(`\bid:\s*"` + regexp.QuoteMeta(id) + `"`)
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for i, line := range strings.Split(string(files[name]), "\n") {
+			if loc := re.FindStringIndex(line); loc != nil {
+				return name, i + 1, loc[0] + 1
+			}
+		}
+	}
+	return "", 0, 0
+}
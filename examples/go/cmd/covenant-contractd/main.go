@@ -0,0 +1,419 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"covenant-poc/executor/config"
+	"covenant-poc/executor/engine"
+	"covenant-poc/executor/rbac"
+)
+
+// configSections are the sections of a --config file.
+var configSections = []string{"server", "contracts", "storage", "auth", "telemetry"}
+
+func main() {
+	configFile := flag.String("config", "", "JSON config file of settings by section (server, contracts, storage, auth, telemetry), named as the flags; COVENANT_<FLAG> environment variables override it and command-line flags override both")
+	validateConfig := flag.Bool("validate-config", false, "Check the settings and the files they name, then exit without listening")
+	contractsDir := flag.String("dir", "./contracts", "Directory of CUE contract files")
+	addr := flag.String("addr", ":26861", "Listen address")
+	service := flag.String("service", "billing", "Service name")
+	domain := flag.String("domain", "billing", "Domain subdirectory to serve")
+	bases := flag.String("bases", "common", "Comma-separated base contract subdirectories domains may import")
+	statusURL := flag.String("status-url", "", "Commit status endpoint to POST push validation results to; {sha} is replaced by the commit. Enables POST /webhooks/push")
+	repoPath := flag.String("repo-path", "contracts", "Path of --dir within the repository, for mapping pushed files to domains")
+	publishLog := flag.String("publish-log", "publishes.ndjson", "Audit log of contracts published over HTTP; the write API is enabled by COVENANT_PUBLISH_TOKENS or --rbac")
+	rbacFile := flag.String("rbac", "", "JSON file mapping bearer token claims to roles, for role-based access to the write API (tokens are verified with COVENANT_AUTH_SECRET)")
+	approvals := flag.Int("approvals", 0, "Approvals a proposal needs before the draft can be promoted (0: promotion needs no proposal)")
+	proposalsFile := flag.String("proposals", "proposals.json", "File recording promotion proposals and their approvals")
+	eventsURL := flag.String("events-url", "", "URL to POST a CloudEvent to for each proposal state change (optional)")
+	validate := flag.Bool("validate", false, "Validate every domain under --dir, print a JSON report and exit (status 1 if any is invalid)")
+	watch := flag.Bool("watch", true, "Watch --dir for changes, caching file listings and ETags until a file changes; off recomputes them on every request")
+	logFormat := flag.String("log-format", "text", "Log format: text or json")
+	flag.Parse()
+	if _, err := (config.File{Path: *configFile, Sections: configSections}).Apply(flag.CommandLine); err != nil {
+		log.Fatalf("Config: %v", err)
+	}
+	switch *logFormat {
+	case "text":
+		slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, nil)))
+	case "json":
+		slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, nil)))
+	default:
+		log.Fatalf("--log-format: %q is neither text nor json", *logFormat)
+	}
+
+	srv := &contractServer{
+		dir:     *contractsDir,
+		service: *service,
+		domain:  *domain,
+	}
+	for _, b := range strings.Split(*bases, ",") {
+		if b = strings.TrimSpace(b); b != "" {
+			srv.bases = append(srv.bases, b)
+		}
+	}
+
+	if *validate {
+		report, err := srv.validateAll(time.Now(), nil)
+		if err != nil {
+			log.Fatalf("Validate contracts: %v", err)
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+		if !report.Valid {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *validateConfig {
+		if _, err := os.Stat(*contractsDir); err != nil {
+			log.Fatalf("--dir: %v", err)
+		}
+		if _, err := loadProposals(*proposalsFile, *approvals, nil); err != nil {
+			log.Fatal(err)
+		}
+		if *rbacFile != "" {
+			if _, err := rbac.LoadFile(*rbacFile); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if _, err := newPublisher(srv, *publishLog, nil, nil); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Config is valid")
+		return
+	}
+	if *watch {
+		if err := srv.watch(); err != nil {
+			log.Fatalf("Watch %s: %v", *contractsDir, err)
+		}
+	}
+
+	http.HandleFunc("GET /.well-known/covenant", srv.handleDiscovery)
+	http.HandleFunc("GET /contracts/", srv.handleFile)
+	http.HandleFunc("POST /validate", srv.handleValidate)
+	http.HandleFunc("POST /diagnostics", srv.handleDiagnostics)
+	ps, err := loadProposals(*proposalsFile, *approvals, newProposalEvents(*eventsURL))
+	if err != nil {
+		log.Fatal(err)
+	}
+	var auth *rbac.Authorizer
+	if *rbacFile != "" {
+		if auth, err = rbac.LoadFile(*rbacFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+	pub, err := newPublisher(srv, *publishLog, ps, auth)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if pub != nil {
+		if err := srv.linkPublished(); err != nil {
+			log.Fatalf("Link published contract: %v", err)
+		}
+		http.HandleFunc("PUT /contracts/{path...}", pub.handlePut)
+		http.HandleFunc("DELETE /contracts/{path...}", pub.handleDelete)
+		http.HandleFunc("GET /publishes", pub.handleLog)
+		http.HandleFunc("POST /channels/draft/promote", pub.handlePromote)
+		http.HandleFunc("POST /proposals", pub.handlePropose)
+		http.HandleFunc("GET /proposals", pub.handleProposals)
+		http.HandleFunc("GET /proposals/{id}", pub.handleProposal)
+		http.HandleFunc("POST /proposals/{id}/approve", pub.handleApprove)
+		http.HandleFunc("POST /proposals/{id}/reject", pub.handleReject)
+	}
+	if *statusURL != "" {
+		http.HandleFunc("POST /webhooks/push", newStatusHook(srv, *statusURL, *repoPath).handle)
+	}
+
+	slog.Info("contract server listening", "addr", *addr, "dir", *contractsDir, "watch", *watch)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
+
+type contractServer struct {
+	dir     string
+	service string
+	domain  string
+	bases   []string
+
+	queueCache sync.Map  // contract ETag → its queue catalog
+	listings   *listings // file listings cached while --watch is on, or nil
+}
+
+func (s *contractServer) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	// A scheduled contract whose activation time has passed is served as the
+	// current one until an operator promotes <domain>.next over <domain>.
+	current := s.domain
+	sched, err := s.schedule()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if sched != nil && !time.Now().Before(sched.ActivateAt) {
+		current, sched = s.nextDir(), nil
+	}
+
+	cf, err := s.listFiles(current)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	disc := map[string]any{
+		"version":           "1.0",
+		"service":           s.service,
+		"description":       fmt.Sprintf("%s domain contracts", s.service),
+		"contract_etag":     cf.etag,
+		"persona":           "customer",
+		"contracts":         cf,
+		"queues":            s.queues(cf),
+		"protocol_versions": engine.ProtocolVersions(),
+	}
+	if disc["channels"], err = s.channels(cf); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if sched != nil {
+		next, err := s.listFiles(s.nextDir())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		disc["scheduled"] = map[string]any{
+			"contract_etag": next.etag,
+			"activate_at":   sched.ActivateAt,
+			"contracts":     next,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(disc)
+}
+
+// queues returns the queue catalog of the contract cf lists. Catalogs are
+// cached by contract ETag. A contract that does not compile has none; the
+// error is logged once, and executors report it when they load it.
+func (s *contractServer) queues(cf *contractFiles) map[string]engine.QueueDef {
+	if q, ok := s.queueCache.Load(cf.etag); ok {
+		return q.(map[string]engine.QueueDef)
+	}
+	var queues map[string]engine.QueueDef
+	files, err := s.readDomain(cf.dir)
+	if err == nil {
+		var c *engine.Contract
+		if c, err = engine.CompileSources(files, s.readDomain); err == nil {
+			queues = c.Queues
+		}
+	}
+	if err != nil {
+		log.Printf("Queue catalog of %s: %v", cf.dir, err)
+	}
+	s.queueCache.Store(cf.etag, queues)
+	return queues
+}
+
+// schedule is <domain>.next/schedule.json, which publishes the contract in
+// <domain>.next for activation at ActivateAt.
+type schedule struct {
+	ActivateAt time.Time `json:"activate_at"`
+}
+
+func (s *contractServer) nextDir() string { return s.domain + ".next" }
+
+// schedule returns the pending schedule, or nil if nothing is scheduled.
+func (s *contractServer) schedule() (*schedule, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, s.nextDir(), "schedule.json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var sc schedule
+	if err := json.Unmarshal(data, &sc); err != nil {
+		return nil, fmt.Errorf("%s/schedule.json: %w", s.nextDir(), err)
+	}
+	return &sc, nil
+}
+
+func (s *contractServer) handleFile(w http.ResponseWriter, r *http.Request) {
+	// Strip /contracts/ prefix and resolve to filesystem path.
+	rel := strings.TrimPrefix(r.URL.Path, "/contracts/")
+	abs := filepath.Join(s.dir, rel)
+
+	// Prevent path traversal.
+	if !strings.HasPrefix(abs, filepath.Clean(s.dir)) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		if os.IsNotExist(err) {
+			http.NotFound(w, r)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// A client fetching the files of the contract it discovered sends that
+	// contract's ETag. If the directory has changed since, the file may be
+	// from another version, so refuse it and let the client start over.
+	if match := r.Header.Get("If-Match"); match != "" {
+		ok, err := s.currentETag(match)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.Error(w, "contract changed since discovery", http.StatusPreconditionFailed)
+			return
+		}
+	}
+
+	if strings.HasSuffix(abs, ".json") {
+		w.Header().Set("Content-Type", "application/json")
+	} else {
+		w.Header().Set("Content-Type", "text/x-cue")
+	}
+	w.Header().Set("Cache-Control", "public, max-age=60")
+	w.Write(data)
+}
+
+// currentETag reports whether an If-Match header names the ETag of a
+// contract discovery currently offers: the domain's, the scheduled one's,
+// or the draft's.
+func (s *contractServer) currentETag(ifMatch string) (bool, error) {
+	dirs := []string{s.domain}
+	sched, err := s.schedule()
+	if err != nil {
+		return false, err
+	}
+	if sched != nil {
+		dirs = append(dirs, s.nextDir())
+	}
+	if ok, err := s.hasDraft(); err != nil {
+		return false, err
+	} else if ok {
+		dirs = append(dirs, s.draftDir())
+	}
+	for _, dir := range dirs {
+		cf, err := s.listFiles(dir)
+		if err != nil {
+			return false, err
+		}
+		if etagMatches(ifMatch, cf.etag) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// contractFiles is the "contracts" object of a discovery document.
+type contractFiles struct {
+	Files     []string            `json:"files"`
+	Bases     map[string][]string `json:"bases"`
+	Bindings  map[string]string   `json:"bindings"`
+	Checksums map[string]string   `json:"checksums"`
+	etag      string
+	dir       string // subdirectory of the domain's files
+}
+
+// listFiles returns the /contracts/... URLs for all .cue files in the domain
+// subdirectory and in each base subdirectory, the per-environment parameter
+// bindings (<domain>/bindings/<env>.json), the SHA-256 of each of them, and
+// an ETag over those checksums — a change to a base contract or a binding
+// changes the ETag. Executors verify fetched files against the checksums.
+// While --watch is on, listings are cached until a file changes.
+func (s *contractServer) listFiles(domain string) (*contractFiles, error) {
+	if s.listings == nil {
+		return s.readListing(domain)
+	}
+	return s.listings.get(domain, s.readListing)
+}
+
+// readListing lists the domain's files from disk.
+func (s *contractServer) readListing(domain string) (*contractFiles, error) {
+	cf := &contractFiles{Bases: map[string][]string{}, Bindings: map[string]string{}, Checksums: map[string]string{}, dir: domain}
+
+	files, err := s.walkDir(domain, cf.Checksums)
+	if err != nil {
+		return nil, err
+	}
+	cf.Files = files
+	for _, b := range s.bases {
+		bf, err := s.walkDir(b, cf.Checksums)
+		if err != nil {
+			return nil, err
+		}
+		cf.Bases[b] = bf
+	}
+
+	paths, err := filepath.Glob(filepath.Join(s.dir, domain, "bindings", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		env := strings.TrimSuffix(filepath.Base(path), ".json")
+		url := "/contracts/" + domain + "/bindings/" + filepath.Base(path)
+		cf.Bindings[env] = url
+		cf.Checksums[url] = fmt.Sprintf("%x", sha256.Sum256(data))
+	}
+
+	cf.etag = engine.ManifestETag(cf.Checksums)
+	return cf, nil
+}
+
+// walkDir lists the .cue files under one subdirectory, recording the SHA-256
+// of each in checksums.
+func (s *contractServer) walkDir(sub string, checksums map[string]string) ([]string, error) {
+	var files []string
+	// The published domain is a link; list the version it points to.
+	root, err := filepath.EvalSymlinks(filepath.Join(s.dir, sub))
+	if err != nil {
+		return nil, err
+	}
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".cue") {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		// Convert abs path to a /contracts/... URL.
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		url := "/contracts/" + filepath.ToSlash(filepath.Join(sub, rel))
+		files = append(files, url)
+		checksums[url] = fmt.Sprintf("%x", sha256.Sum256(data))
+		return nil
+	})
+	return files, err
+}
@@ -0,0 +1,429 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"time"
+
+	"covenant-poc/executor/rbac"
+)
+
+// Proposal states. A proposal is open while proposed or approved; any change
+// to the draft it proposed supersedes it.
+const (
+	proposalProposed   = "proposed"
+	proposalApproved   = "approved"
+	proposalRejected   = "rejected"
+	proposalSuperseded = "superseded"
+	proposalPromoted   = "promoted"
+)
+
+// proposal asks for one version of the draft, identified by its contract
+// ETag, to be promoted to the published channel.
+type proposal struct {
+	ID         string    `json:"id"`
+	Domain     string    `json:"domain"`
+	ETag       string    `json:"contract_etag"`
+	State      string    `json:"state"`
+	Required   int       `json:"required_approvals"`
+	ProposedBy string    `json:"proposed_by"`
+	Comment    string    `json:"comment,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	Approvals  []review  `json:"approvals"`
+	Rejection  *review   `json:"rejection,omitempty"`
+}
+
+// review is one approval or rejection.
+type review struct {
+	By      string    `json:"by"`
+	Comment string    `json:"comment,omitempty"`
+	At      time.Time `json:"at"`
+}
+
+func (pr *proposal) open() bool {
+	return pr.State == proposalProposed || pr.State == proposalApproved
+}
+
+// proposals tracks promotion proposals and their reviews, persisted as a
+// JSON array. With required above zero, the draft is promoted only under
+// an approved proposal for its current contract ETag. The publisher's
+// mutex guards it, since its state follows the draft's.
+type proposals struct {
+	path     string
+	required int
+	list     []*proposal
+	events   *proposalEvents
+}
+
+func loadProposals(path string, required int, events *proposalEvents) (*proposals, error) {
+	ps := &proposals{path: path, required: required, list: []*proposal{}, events: events}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return ps, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &ps.list); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return ps, nil
+}
+
+func (ps *proposals) get(id string) *proposal {
+	for _, pr := range ps.list {
+		if pr.ID == id {
+			return pr
+		}
+	}
+	return nil
+}
+
+// approvedFor returns the approved proposal for a draft contract ETag.
+func (ps *proposals) approvedFor(etag string) *proposal {
+	for _, pr := range ps.list {
+		if pr.State == proposalApproved && pr.ETag == etag {
+			return pr
+		}
+	}
+	return nil
+}
+
+// transition moves pr to state, saves, and emits the state change.
+func (ps *proposals) transition(pr *proposal, state string, now time.Time) {
+	pr.State, pr.UpdatedAt = state, now.UTC()
+	ps.changed(pr, state)
+}
+
+// changed saves the proposals and emits an event of the given kind for pr.
+func (ps *proposals) changed(pr *proposal, kind string) {
+	if err := ps.save(); err != nil {
+		log.Printf("proposals: %v", err)
+	}
+	ps.events.emit(kind, pr)
+}
+
+// supersede closes the open proposals for any draft other than etag; ""
+// closes them all.
+func (ps *proposals) supersede(etag string, now time.Time) {
+	for _, pr := range ps.list {
+		if pr.open() && pr.ETag != etag {
+			ps.transition(pr, proposalSuperseded, now)
+		}
+	}
+}
+
+func (ps *proposals) save() error {
+	data, err := json.MarshalIndent(ps.list, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(ps.path, data)
+}
+
+// reviewRequest is the body of a proposal, approval or rejection.
+type reviewRequest struct {
+	Comment string `json:"comment"`
+}
+
+// handlePropose proposes the draft named by If-Match for promotion.
+func (p *publisher) handlePropose(w http.ResponseWriter, r *http.Request) {
+	who, req, ok := p.reviewer(w, r, rbac.Operator)
+	if !ok {
+		return
+	}
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		http.Error(w, "If-Match with the draft's contract ETag is required", http.StatusPreconditionRequired)
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	etag, ok := p.draftETag(w)
+	if !ok {
+		return
+	}
+	if !etagMatches(ifMatch, etag) {
+		w.Header().Set("ETag", `"`+etag+`"`)
+		http.Error(w, "draft changed since "+ifMatch, http.StatusPreconditionFailed)
+		return
+	}
+	for _, pr := range p.proposals.list {
+		if pr.open() && pr.ETag == etag {
+			http.Error(w, fmt.Sprintf("draft %s is already proposed as %s", etag, pr.ID), http.StatusConflict)
+			return
+		}
+	}
+
+	now := time.Now().UTC()
+	pr := &proposal{
+		ID:         "prop_" + proposalID(),
+		Domain:     p.srv.domain,
+		ETag:       etag,
+		Required:   p.proposals.required,
+		ProposedBy: who,
+		Comment:    req.Comment,
+		CreatedAt:  now,
+		Approvals:  []review{},
+	}
+	p.proposals.list = append(p.proposals.list, pr)
+	p.proposals.transition(pr, proposalProposed, now)
+	if pr.Required == 0 {
+		p.proposals.transition(pr, proposalApproved, now)
+	}
+	log.Printf("PROPOSE %s by %s: draft %s", pr.ID, who, etag)
+	writeProposal(w, http.StatusCreated, pr)
+}
+
+// handleApprove records an approval. The proposer cannot approve their own
+// proposal, and each identity counts once.
+func (p *publisher) handleApprove(w http.ResponseWriter, r *http.Request) {
+	who, req, ok := p.reviewer(w, r, rbac.Approver)
+	if !ok {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pr, ok := p.openProposal(w, r)
+	if !ok {
+		return
+	}
+	if who == pr.ProposedBy {
+		http.Error(w, "a proposal cannot be approved by its proposer", http.StatusForbidden)
+		return
+	}
+	if slices.ContainsFunc(pr.Approvals, func(a review) bool { return a.By == who }) {
+		http.Error(w, who+" has already approved "+pr.ID, http.StatusConflict)
+		return
+	}
+
+	now := time.Now().UTC()
+	pr.Approvals = append(pr.Approvals, review{By: who, Comment: req.Comment, At: now})
+	pr.UpdatedAt = now
+	p.proposals.changed(pr, "approval_recorded")
+	if pr.State == proposalProposed && len(pr.Approvals) >= pr.Required {
+		p.proposals.transition(pr, proposalApproved, now)
+	}
+	log.Printf("APPROVE %s by %s: %d/%d", pr.ID, who, len(pr.Approvals), pr.Required)
+	writeProposal(w, http.StatusOK, pr)
+}
+
+// handleReject rejects a proposal, which takes a comment saying why. The
+// proposer may reject their own to withdraw it.
+func (p *publisher) handleReject(w http.ResponseWriter, r *http.Request) {
+	who, req, ok := p.reviewer(w, r, rbac.Approver)
+	if !ok {
+		return
+	}
+	if req.Comment == "" {
+		http.Error(w, "a rejection needs a comment", http.StatusBadRequest)
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pr, ok := p.openProposal(w, r)
+	if !ok {
+		return
+	}
+	now := time.Now().UTC()
+	pr.Rejection = &review{By: who, Comment: req.Comment, At: now}
+	p.proposals.transition(pr, proposalRejected, now)
+	log.Printf("REJECT %s by %s", pr.ID, who)
+	writeProposal(w, http.StatusOK, pr)
+}
+
+// handleProposals lists proposals, newest first, optionally in one state
+// (?state=proposed).
+func (p *publisher) handleProposals(w http.ResponseWriter, r *http.Request) {
+	if _, ok := p.authorize(w, r, rbac.Viewer); !ok {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	list := []*proposal{}
+	for _, pr := range slices.Backward(p.proposals.list) {
+		if s := r.URL.Query().Get("state"); s == "" || s == pr.State {
+			list = append(list, pr)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(list)
+}
+
+func (p *publisher) handleProposal(w http.ResponseWriter, r *http.Request) {
+	if _, ok := p.authorize(w, r, rbac.Viewer); !ok {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pr := p.proposals.get(r.PathValue("id"))
+	if pr == nil {
+		http.NotFound(w, r)
+		return
+	}
+	writeProposal(w, http.StatusOK, pr)
+}
+
+// reviewer authorizes a review request for need's role and decodes its
+// optional body.
+func (p *publisher) reviewer(w http.ResponseWriter, r *http.Request, need rbac.Role) (string, reviewRequest, bool) {
+	var req reviewRequest
+	who, ok := p.authorize(w, r, need)
+	if !ok {
+		return "", req, false
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+			return "", req, false
+		}
+	}
+	return who, req, true
+}
+
+// openProposal returns the request's proposal if it is still open and the
+// draft has not changed since it was proposed.
+func (p *publisher) openProposal(w http.ResponseWriter, r *http.Request) (*proposal, bool) {
+	pr := p.proposals.get(r.PathValue("id"))
+	if pr == nil {
+		http.NotFound(w, r)
+		return nil, false
+	}
+	if pr.open() {
+		etag, ok := p.draftETag(w)
+		if !ok {
+			return nil, false
+		}
+		p.proposals.supersede(etag, time.Now())
+	}
+	if !pr.open() {
+		http.Error(w, fmt.Sprintf("proposal %s is %s", pr.ID, pr.State), http.StatusConflict)
+		return nil, false
+	}
+	return pr, true
+}
+
+// draftETag returns the contract ETag of the draft. With no draft there is
+// nothing to propose, and it answers 404.
+func (p *publisher) draftETag(w http.ResponseWriter) (string, bool) {
+	ok, err := p.srv.hasDraft()
+	if err == nil && !ok {
+		http.Error(w, "no draft", http.StatusNotFound)
+		return "", false
+	}
+	var draft *contractFiles
+	if err == nil {
+		draft, err = p.srv.listFiles(p.srv.draftDir())
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return "", false
+	}
+	return draft.etag, true
+}
+
+// draftChanged supersedes proposals for drafts other than the current one,
+// after a write or a promotion. The publisher's mutex must be held.
+func (p *publisher) draftChanged() {
+	etag := ""
+	if ok, err := p.srv.hasDraft(); err == nil && ok {
+		if draft, err := p.srv.listFiles(p.srv.draftDir()); err == nil {
+			etag = draft.etag
+		}
+	}
+	p.proposals.supersede(etag, time.Now())
+}
+
+func writeProposal(w http.ResponseWriter, status int, pr *proposal) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(pr)
+}
+
+func proposalID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// proposalEventPrefix prefixes the type of each proposal event; the state
+// entered, or approval_recorded, completes it.
+const proposalEventPrefix = "dev.covenant.proposal."
+
+// proposalEvents POSTs a structured-mode CloudEvent for each change to a
+// proposal. A nil *proposalEvents drops them.
+type proposalEvents struct {
+	url    string
+	client *http.Client
+}
+
+func newProposalEvents(url string) *proposalEvents {
+	if url == "" {
+		return nil
+	}
+	return &proposalEvents{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// proposalEvent is a CloudEvents 1.0 event carrying a proposal.
+type proposalEvent struct {
+	SpecVersion     string    `json:"specversion"`
+	ID              string    `json:"id"`
+	Source          string    `json:"source"`
+	Type            string    `json:"type"`
+	Subject         string    `json:"subject"`
+	Time            time.Time `json:"time"`
+	DataContentType string    `json:"datacontenttype"`
+	Data            proposal  `json:"data"`
+}
+
+func (e *proposalEvents) emit(kind string, pr *proposal) {
+	if e == nil {
+		return
+	}
+	ev := proposalEvent{
+		SpecVersion:     "1.0",
+		ID:              proposalID(),
+		Source:          "/covenant/contract-server",
+		Type:            proposalEventPrefix + kind,
+		Subject:         pr.ID,
+		Time:            time.Now().UTC(),
+		DataContentType: "application/json",
+		Data:            *pr,
+	}
+	ev.Data.Approvals = slices.Clone(pr.Approvals)
+	go e.post(ev)
+}
+
+func (e *proposalEvents) post(ev proposalEvent) {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		log.Printf("proposal events: %v", err)
+		return
+	}
+	resp, err := e.client.Post(e.url, "application/cloudevents+json; charset=utf-8", bytes.NewReader(body))
+	if err != nil {
+		log.Printf("proposal events: %s: %v", ev.ID, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("proposal events: %s: HTTP %d", ev.ID, resp.StatusCode)
+	}
+}
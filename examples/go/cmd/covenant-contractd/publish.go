@@ -0,0 +1,364 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"covenant-poc/executor/engine"
+	"covenant-poc/executor/rbac"
+)
+
+// publisher accepts contract files over HTTP, so publishing pipelines need
+// no access to the contracts directory. Every write is authenticated,
+// conditional on the contract ETag the caller last saw, and validated
+// before it touches disk; accepted writes are appended to an audit log.
+type publisher struct {
+	srv       *contractServer
+	tokens    map[string]string // bearer token -> publisher identity
+	auth      *rbac.Authorizer  // role-based access for other bearer tokens, or nil
+	log       string            // audit log of publishes, one JSON record per line
+	proposals *proposals
+	mu        sync.Mutex // serializes writes, and the checks before them
+}
+
+// newPublisher reads publisher identities from COVENANT_PUBLISH_TOKENS, a
+// comma-separated list of name=token pairs, which act as admins. Other
+// callers need a role from auth. It returns nil if no tokens are set and
+// auth is nil, leaving the write API disabled.
+func newPublisher(srv *contractServer, auditLog string, ps *proposals, auth *rbac.Authorizer) (*publisher, error) {
+	tokens := map[string]string{}
+	for _, pair := range strings.Split(os.Getenv("COVENANT_PUBLISH_TOKENS"), ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		name, token, ok := strings.Cut(pair, "=")
+		if !ok || name == "" || token == "" {
+			return nil, fmt.Errorf("COVENANT_PUBLISH_TOKENS: expected name=token, got %q", pair)
+		}
+		tokens[token] = name
+	}
+	if len(tokens) == 0 && auth == nil {
+		return nil, nil
+	}
+	return &publisher{srv: srv, tokens: tokens, auth: auth, log: auditLog, proposals: ps}, nil
+}
+
+// publishRecord is one entry of the audit trail.
+type publishRecord struct {
+	At         time.Time `json:"at"`
+	Publisher  string    `json:"publisher"`
+	Method     string    `json:"method"` // PUT, DELETE or PROMOTE
+	Path       string    `json:"path"`
+	SHA256     string    `json:"sha256,omitempty"`          // of the new content
+	PrevSHA256 string    `json:"previous_sha256,omitempty"` // of the replaced content
+	Domain     string    `json:"domain"`
+	PrevETag   string    `json:"previous_contract_etag"`
+	ETag       string    `json:"contract_etag"`
+	Proposal   string    `json:"proposal,omitempty"` // approved proposal a promotion carried out
+}
+
+// publishResponse answers an accepted write, or one rejected by validation.
+type publishResponse struct {
+	Path        string              `json:"path"`
+	Domain      string              `json:"domain"`
+	ETag        string              `json:"contract_etag,omitempty"`
+	Diagnostics []engine.Diagnostic `json:"diagnostics"`
+}
+
+// identity returns the publisher named by the request's bearer token.
+func (p *publisher) identity(r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return "", false
+	}
+	for t, name := range p.tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// authorize returns the request's caller if it has need's role, or answers
+// 401 or 403 with an error envelope.
+func (p *publisher) authorize(w http.ResponseWriter, r *http.Request, need rbac.Role) (string, bool) {
+	if who, ok := p.identity(r); ok {
+		return who, true
+	}
+	if p.auth == nil {
+		rbac.WriteUnauthenticated(w, "unknown publish token")
+		return "", false
+	}
+	caller, ok := p.auth.Authorize(w, r, need)
+	return caller.Subject, ok
+}
+
+func (p *publisher) handlePut(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	p.publish(w, r, body)
+}
+
+func (p *publisher) handleDelete(w http.ResponseWriter, r *http.Request) {
+	p.publish(w, r, nil)
+}
+
+// publish writes data to the file the request names, or deletes the file if
+// data is nil.
+func (p *publisher) publish(w http.ResponseWriter, r *http.Request, data []byte) {
+	who, ok := p.authorize(w, r, rbac.Admin)
+	if !ok {
+		return
+	}
+	rel := r.PathValue("path")
+	dir, file, ok := strings.Cut(rel, "/")
+	if !ok || !filepath.IsLocal(rel) || path.Clean(rel) != rel || !publishable(file) {
+		http.Error(w, fmt.Sprintf("%q: expected <domain>/<file>.cue or <domain>/bindings/<env>.json", rel), http.StatusBadRequest)
+		return
+	}
+	// The first write to the draft channel starts the draft as a copy of
+	// the published contract, so it is checked against the published ETag.
+	_, err := os.Stat(filepath.Join(p.srv.dir, dir))
+	seed := os.IsNotExist(err) && dir == p.srv.draftDir()
+	if err != nil && !seed {
+		http.Error(w, fmt.Sprintf("unknown domain %q", dir), http.StatusNotFound)
+		return
+	}
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		http.Error(w, "If-Match with the contract ETag is required", http.StatusPreconditionRequired)
+		return
+	}
+
+	// A base contract is part of the served domain's contract, so a change
+	// to it is checked against, and validated as part of, that contract.
+	domain := dir
+	if slices.Contains(p.srv.bases, dir) {
+		domain = p.srv.domain
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	accepted := false
+	etagDir := domain
+	if seed {
+		etagDir = p.srv.domain
+	}
+	before, err := p.srv.listFiles(etagDir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !etagMatches(ifMatch, before.etag) {
+		w.Header().Set("ETag", `"`+before.etag+`"`)
+		http.Error(w, "contract changed since "+ifMatch, http.StatusPreconditionFailed)
+		return
+	}
+	if seed {
+		if err := p.srv.seedDraft(); err != nil {
+			os.RemoveAll(filepath.Join(p.srv.dir, dir))
+			p.srv.changed()
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// A rejected first write leaves no draft behind.
+		defer func() {
+			if !accepted {
+				os.RemoveAll(filepath.Join(p.srv.dir, dir))
+				p.srv.changed()
+			}
+		}()
+	}
+	abs := filepath.Join(p.srv.dir, filepath.FromSlash(rel))
+	prev, err := os.ReadFile(abs)
+	if err != nil && !os.IsNotExist(err) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if data == nil && prev == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	resp := publishResponse{Path: rel, Domain: domain}
+	resp.Diagnostics, err = p.check(domain, dir, rel, data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if hasErrors(resp.Diagnostics) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	if data == nil {
+		err = os.Remove(abs)
+	} else {
+		err = writeFileAtomic(abs, data)
+	}
+	p.srv.changed()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	after, err := p.srv.listFiles(domain)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	resp.ETag = after.etag
+	accepted = true
+	p.draftChanged()
+
+	rec := publishRecord{
+		At:        time.Now().UTC(),
+		Publisher: who,
+		Method:    r.Method,
+		Path:      rel,
+		Domain:    domain,
+		PrevETag:  before.etag,
+		ETag:      after.etag,
+	}
+	if data != nil {
+		rec.SHA256 = fmt.Sprintf("%x", sha256.Sum256(data))
+	}
+	if prev != nil {
+		rec.PrevSHA256 = fmt.Sprintf("%x", sha256.Sum256(prev))
+	}
+	if err := p.record(rec); err != nil {
+		log.Printf("publish audit log: %v", err)
+	}
+	slog.Info("contract published", "method", r.Method, "path", rel, "domain", domain, "publisher", who, "prev_etag", before.etag, "etag", after.etag)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", `"`+after.etag+`"`)
+	if prev == nil {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// publishable reports whether file, relative to its domain directory, is a
+// contract file or a parameter bindings file.
+func publishable(file string) bool {
+	if env, ok := strings.CutPrefix(file, "bindings/"); ok {
+		return path.Ext(env) == ".json" && !strings.Contains(env, "/")
+	}
+	return path.Ext(file) == ".cue" && !strings.Contains(file, "/")
+}
+
+// check validates domain as it would be with data written to rel in dir,
+// or rel deleted if data is nil. A bindings file must also bind cleanly to
+// the compiled contract.
+func (p *publisher) check(domain, dir, rel string, data []byte) ([]engine.Diagnostic, error) {
+	overlay := map[string]map[string][]byte{}
+	if path.Ext(rel) == ".cue" {
+		overlay[dir] = map[string][]byte{rel: data}
+	}
+	diags, c, err := p.srv.diagnose(domain, overlay, time.Now())
+	if err != nil || c == nil || data == nil || path.Ext(rel) != ".json" {
+		return diags, err
+	}
+	env := strings.TrimSuffix(path.Base(rel), ".json")
+	var values map[string]any
+	err = json.Unmarshal(data, &values)
+	if err == nil {
+		err = c.Bind(env, values)
+	}
+	if err != nil {
+		diags = append(diags, engine.Diagnostic{Severity: engine.SeverityError, Message: fmt.Sprintf("bindings %s: %v", env, err)})
+	}
+	return diags, nil
+}
+
+// etagMatches reports whether an If-Match header names etag, or is "*".
+func etagMatches(ifMatch, etag string) bool {
+	for _, tag := range strings.Split(ifMatch, ",") {
+		tag = strings.Trim(strings.TrimPrefix(strings.TrimSpace(tag), "W/"), `"`)
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeFileAtomic replaces name with data, so a concurrent reader sees the
+// old file or the new one, never a partial write.
+func writeFileAtomic(name string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".publish-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+func (p *publisher) record(rec publishRecord) error {
+	f, err := os.OpenFile(p.log, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewEncoder(f).Encode(rec)
+}
+
+// handleLog serves the audit trail, oldest first, optionally only the
+// publishes to one domain (?domain=billing).
+func (p *publisher) handleLog(w http.ResponseWriter, r *http.Request) {
+	if _, ok := p.authorize(w, r, rbac.Viewer); !ok {
+		return
+	}
+	records := []publishRecord{}
+	f, err := os.Open(p.log)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if f != nil {
+		defer f.Close()
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			var rec publishRecord
+			if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+				continue
+			}
+			if d := r.URL.Query().Get("domain"); d == "" || d == rec.Domain {
+				records = append(records, rec)
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(records)
+}
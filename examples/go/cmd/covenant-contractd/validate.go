@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"covenant-poc/covenant/binding"
+	"covenant-poc/executor/engine"
+)
+
+// validationReport is the result of validating every domain under the
+// contracts directory.
+type validationReport struct {
+	Valid     bool           `json:"valid"`
+	CheckedAt time.Time      `json:"checked_at"`
+	Domains   []domainReport `json:"domains"`
+}
+
+// domainReport covers one domain: its compiled contract, each of its
+// parameter bindings, and its transport binding spec if it has one.
+type domainReport struct {
+	Domain      string              `json:"domain"`
+	Valid       bool                `json:"valid"`
+	Version     string              `json:"version,omitempty"`
+	Errors      int                 `json:"errors"`
+	Warnings    int                 `json:"warnings"`
+	Diagnostics []engine.Diagnostic `json:"diagnostics"`
+}
+
+func (s *contractServer) handleValidate(w http.ResponseWriter, r *http.Request) {
+	report, err := s.validateAll(time.Now(), nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(report)
+}
+
+// validateAll validates each subdirectory of the contracts directory that
+// holds .cue files, including bases and scheduled <domain>.next contracts.
+// If only is non-nil, domains not in it are skipped.
+func (s *contractServer) validateAll(now time.Time, only map[string]bool) (*validationReport, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	report := &validationReport{Valid: true, CheckedAt: now.UTC(), Domains: []domainReport{}}
+	for _, ent := range entries {
+		// Version directories are reached through their domain's link.
+		if strings.HasPrefix(ent.Name(), ".") || (only != nil && !only[ent.Name()]) {
+			continue
+		}
+		if fi, err := os.Stat(filepath.Join(s.dir, ent.Name())); err != nil || !fi.IsDir() {
+			continue
+		}
+		files, err := filepath.Glob(filepath.Join(s.dir, ent.Name(), "*.cue"))
+		if err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			continue
+		}
+		d := s.validateDomain(ent.Name(), now)
+		report.Valid = report.Valid && d.Valid
+		report.Domains = append(report.Domains, d)
+	}
+	sort.Slice(report.Domains, func(i, j int) bool { return report.Domains[i].Domain < report.Domains[j].Domain })
+	return report, nil
+}
+
+func (s *contractServer) validateDomain(domain string, now time.Time) domainReport {
+	d := domainReport{Domain: domain, Diagnostics: []engine.Diagnostic{}}
+	add := func(diags ...engine.Diagnostic) {
+		for _, diag := range diags {
+			if diag.Severity == engine.SeverityError {
+				d.Errors++
+			} else {
+				d.Warnings++
+			}
+			d.Diagnostics = append(d.Diagnostics, diag)
+		}
+	}
+	errorf := func(format string, args ...any) {
+		add(engine.Diagnostic{Severity: engine.SeverityError, Message: fmt.Sprintf(format, args...)})
+	}
+
+	dir := filepath.Join(s.dir, domain)
+	c, err := engine.CompileDir(dir)
+	if err != nil {
+		add(engine.ErrorDiagnostics(err)...)
+		return d
+	}
+	d.Version = c.Version
+	add(engine.Validate(c, now)...)
+
+	envs, err := filepath.Glob(filepath.Join(dir, "bindings", "*.json"))
+	if err != nil {
+		errorf("%v", err)
+	}
+	for _, path := range envs {
+		env := strings.TrimSuffix(filepath.Base(path), ".json")
+		values, err := engine.LoadBindings(path)
+		if err == nil {
+			err = c.Bind(env, values)
+		}
+		if err != nil {
+			errorf("bindings %s: %v", env, err)
+		}
+	}
+
+	specPath := filepath.Join(dir, "transport", "bindings.cue")
+	if _, err := os.Stat(specPath); err == nil {
+		spec, err := binding.Load(specPath)
+		if err != nil {
+			errorf("transport: %v", err)
+		} else {
+			for _, diag := range spec.Validate(c) {
+				diag.Message = "transport: " + diag.Message
+				add(diag)
+			}
+		}
+	}
+
+	d.Valid = d.Errors == 0
+	return d
+}
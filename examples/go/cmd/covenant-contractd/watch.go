@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// rotationDelay is how long the watcher waits for a burst of changes, such
+// as a git checkout or a promotion's two renames, to settle before logging
+// the contracts it rotated in.
+const rotationDelay = 200 * time.Millisecond
+
+// listings caches file listings, and so ETags, by domain directory until
+// something under the contracts directory changes.
+type listings struct {
+	mu    sync.Mutex
+	gen   uint64 // bumped by each change
+	byDir map[string]*contractFiles
+}
+
+// get returns the cached listing of dir, reading it with read on a miss. A
+// listing read across a change is returned but not cached.
+func (l *listings) get(dir string, read func(string) (*contractFiles, error)) (*contractFiles, error) {
+	l.mu.Lock()
+	cf, ok := l.byDir[dir]
+	gen := l.gen
+	l.mu.Unlock()
+	if ok {
+		return cf, nil
+	}
+	cf, err := read(dir)
+	if err != nil {
+		return nil, err
+	}
+	l.mu.Lock()
+	if l.gen == gen {
+		l.byDir[dir] = cf
+	}
+	l.mu.Unlock()
+	return cf, nil
+}
+
+// invalidate drops every cached listing.
+func (l *listings) invalidate() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.gen++
+	l.byDir = map[string]*contractFiles{}
+}
+
+// changed records a write to the contracts directory by the server itself,
+// so the next listing sees it without waiting for the watcher's event.
+func (s *contractServer) changed() {
+	if s.listings != nil {
+		s.listings.invalidate()
+	}
+}
+
+// watch caches listings and drops them whenever anything under the
+// contracts directory changes, so discovery serves new ETags as soon as a
+// file is written, however it was written. When a change settles, the
+// contracts whose ETag it changed are logged as rotated.
+func (s *contractServer) watch() error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := addTree(w, s.dir); err != nil {
+		w.Close()
+		return err
+	}
+	s.listings = &listings{byDir: map[string]*contractFiles{}}
+	served := s.servedETags()
+	go func() {
+		settle := time.NewTimer(rotationDelay)
+		settle.Stop()
+		for {
+			select {
+			case ev, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				if ev.Has(fsnotify.Create) {
+					if fi, err := os.Stat(ev.Name); err == nil && fi.IsDir() {
+						if err := addTree(w, ev.Name); err != nil {
+							slog.Warn("watch contracts", "path", ev.Name, "error", err)
+						}
+					}
+				}
+				s.listings.invalidate()
+				settle.Reset(rotationDelay)
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				// Events may have been lost, so nothing cached can be trusted.
+				slog.Warn("watch contracts", "error", err)
+				s.listings.invalidate()
+			case <-settle.C:
+				now := s.servedETags()
+				for dir, etag := range now {
+					if prev := served[dir]; prev != etag {
+						slog.Info("contract rotated", "domain", dir, "prev_etag", prev, "etag", etag)
+					}
+				}
+				served = now
+			}
+		}
+	}()
+	return nil
+}
+
+// servedETags returns the ETag of each contract discovery offers: the
+// domain's, and the scheduled and draft ones if present.
+func (s *contractServer) servedETags() map[string]string {
+	etags := map[string]string{}
+	for _, dir := range []string{s.domain, s.nextDir(), s.draftDir()} {
+		if _, err := os.Stat(filepath.Join(s.dir, dir)); err != nil {
+			continue
+		}
+		if cf, err := s.listFiles(dir); err == nil {
+			etags[dir] = cf.etag
+		}
+	}
+	return etags
+}
+
+// addTree watches dir and every directory under it; fsnotify watches are
+// not recursive. Directories removed meanwhile, such as those a promotion
+// renames through, are skipped.
+func addTree(w *fsnotify.Watcher, dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if err := w.Add(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+		}
+		return nil
+	})
+}
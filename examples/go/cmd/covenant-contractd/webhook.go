@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// statusContext names the check on the commit.
+const statusContext = "covenant/contracts"
+
+// statusHook validates the contracts a push changed and reports the result
+// as a commit status. The contract server's directory must be a checkout
+// that is updated to the pushed commit before the push event arrives.
+type statusHook struct {
+	srv      *contractServer
+	url      string // status endpoint; "{sha}" is replaced by the commit
+	repoPath string // contracts directory within the repository
+	secret   string // verifies X-Hub-Signature-256 if set
+	token    string // sent as a bearer token if set
+	client   *http.Client
+}
+
+func newStatusHook(srv *contractServer, url, repoPath string) *statusHook {
+	return &statusHook{
+		srv:      srv,
+		url:      url,
+		repoPath: strings.Trim(repoPath, "/"),
+		secret:   os.Getenv("COVENANT_WEBHOOK_SECRET"),
+		token:    os.Getenv("COVENANT_STATUS_TOKEN"),
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// pushEvent is the part of a GitHub-style push payload the hook reads.
+type pushEvent struct {
+	After   string `json:"after"`
+	Commits []struct {
+		Added    []string `json:"added"`
+		Modified []string `json:"modified"`
+		Removed  []string `json:"removed"`
+	} `json:"commits"`
+}
+
+// commitStatus is posted to the status endpoint. State, Description and
+// Context follow GitHub's commit status API; Report carries the details.
+type commitStatus struct {
+	State       string            `json:"state"` // success, failure or error
+	Description string            `json:"description"`
+	Context     string            `json:"context"`
+	SHA         string            `json:"sha"`
+	Domains     []string          `json:"domains"`
+	Report      *validationReport `json:"report,omitempty"`
+}
+
+func (h *statusHook) handle(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 5<<20))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if h.secret != "" && !h.signed(body, r.Header.Get("X-Hub-Signature-256")) {
+		http.Error(w, "bad signature", http.StatusUnauthorized)
+		return
+	}
+	if r.Header.Get("X-GitHub-Event") != "push" {
+		w.WriteHeader(http.StatusNoContent) // ping and other events
+		return
+	}
+	var ev pushEvent
+	if err := json.Unmarshal(body, &ev); err != nil || ev.After == "" {
+		http.Error(w, "invalid push payload", http.StatusBadRequest)
+		return
+	}
+
+	status := h.check(ev, time.Now())
+	go h.post(status)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(status)
+}
+
+// signed reports whether sig is the HMAC-SHA256 of body under the secret.
+func (h *statusHook) signed(body []byte, sig string) bool {
+	want, err := hex.DecodeString(strings.TrimPrefix(sig, "sha256="))
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(h.secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), want)
+}
+
+// check validates the domains the push touched. A change to a base contract
+// revalidates every domain, since any of them may import it.
+func (h *statusHook) check(ev pushEvent, now time.Time) *commitStatus {
+	status := &commitStatus{Context: statusContext, SHA: ev.After, Domains: []string{}}
+	changed := map[string]bool{}
+	for _, c := range ev.Commits {
+		for _, files := range [][]string{c.Added, c.Modified, c.Removed} {
+			for _, f := range files {
+				if d := h.domainOf(f); d != "" {
+					changed[d] = true
+				}
+			}
+		}
+	}
+	if len(changed) == 0 {
+		status.State, status.Description = "success", "No contract changes"
+		return status
+	}
+	only := changed
+	for d := range changed {
+		if slices.Contains(h.srv.bases, d) {
+			only = nil
+		}
+	}
+
+	report, err := h.srv.validateAll(now, only)
+	if err != nil {
+		status.State, status.Description = "error", fmt.Sprintf("Validation failed to run: %v", err)
+		return status
+	}
+	status.Report = report
+	errors, invalid := 0, 0
+	for _, d := range report.Domains {
+		status.Domains = append(status.Domains, d.Domain)
+		errors += d.Errors
+		if !d.Valid {
+			invalid++
+		}
+	}
+	if report.Valid {
+		status.State = "success"
+		status.Description = fmt.Sprintf("%d contract domain(s) valid", len(report.Domains))
+	} else {
+		status.State = "failure"
+		status.Description = fmt.Sprintf("%d of %d contract domain(s) invalid, %d error(s)", invalid, len(report.Domains), errors)
+	}
+	return status
+}
+
+// domainOf returns the contract domain a repository path belongs to, or ""
+// if it is not a contract or bindings file.
+func (h *statusHook) domainOf(file string) string {
+	rel, ok := strings.CutPrefix(file, h.repoPath+"/")
+	if h.repoPath == "" {
+		rel, ok = file, true
+	}
+	if !ok || (path.Ext(rel) != ".cue" && path.Ext(rel) != ".json") {
+		return ""
+	}
+	domain, _, ok := strings.Cut(rel, "/")
+	if !ok {
+		return ""
+	}
+	return domain
+}
+
+// post delivers status to the status endpoint.
+func (h *statusHook) post(status *commitStatus) {
+	body, err := json.Marshal(status)
+	if err != nil {
+		log.Printf("status webhook: %v", err)
+		return
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.ReplaceAll(h.url, "{sha}", status.SHA), bytes.NewReader(body))
+	if err != nil {
+		log.Printf("status webhook: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if h.token != "" {
+		req.Header.Set("Authorization", "Bearer "+h.token)
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		log.Printf("status webhook: %v", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Printf("status webhook: HTTP %d", resp.StatusCode)
+	}
+}
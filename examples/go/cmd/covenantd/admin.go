@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"covenant-poc/executor/engine"
+	"covenant-poc/executor/mirror"
+	"covenant-poc/executor/rbac"
+	"covenant-poc/executor/store"
+)
+
+// loadFailure is a contract refresh that failed, for the admin API.
+type loadFailure struct {
+	At     time.Time           `json:"at"`
+	Error  string              `json:"error"`
+	Errors []engine.Diagnostic `json:"errors,omitempty"`
+}
+
+// lastLoadFailure is the most recent refresh failure, or nil once a refresh
+// succeeds.
+var lastLoadFailure atomic.Pointer[loadFailure]
+
+// recordLoadResult logs a failed refresh and keeps it for the admin API.
+func recordLoadResult(err error) {
+	if err == nil {
+		lastLoadFailure.Store(nil)
+		return
+	}
+	logLoadError("Contract refresh error", err)
+	f := &loadFailure{At: time.Now().UTC(), Error: err.Error()}
+	var ce *engine.ContractError
+	if errors.As(err, &ce) {
+		f.Errors = ce.Errors
+	}
+	lastLoadFailure.Store(f)
+}
+
+// logLoadError logs a failed contract load, listing every located problem
+// of a contract that did not compile.
+func logLoadError(msg string, err error) {
+	log.Printf("%s: %v", msg, err)
+	var ce *engine.ContractError
+	if errors.As(err, &ce) && len(ce.Errors) > 1 {
+		for _, d := range ce.Errors {
+			log.Printf("  %s", d)
+		}
+	}
+}
+
+// registerAdmin serves the operator API under /admin/ to admins.
+//
+//	GET /admin/contracts                  active, pending (scheduled) and retained contract
+//	                                      versions, and the last failed refresh if the latest
+//	                                      one failed
+//	GET /admin/kill-switches              the operations taken out of service
+//	PUT /admin/kill-switches/{operation}  switch an operation, or * for all of them, to
+//	                                      {"mode": "deny" | "dry_run", "reason": ...}
+//	DELETE /admin/kill-switches/{operation}  put it back in service
+func registerAdmin(mux *http.ServeMux, auth *rbac.Authorizer, eng *engine.Engine) {
+	mux.HandleFunc("GET /admin/contracts", auth.Require(rbac.Admin, func(w http.ResponseWriter, r *http.Request) {
+		active := map[string]any{"contract_etag": eng.ETag()}
+		if c := eng.Contract(); c != nil {
+			active["version"] = c.Version
+			active["environment"] = c.Environment
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(map[string]any{
+			"active":       active,
+			"pending":      eng.Pending(),
+			"versions":     eng.Versions(),
+			"load_failure": lastLoadFailure.Load(),
+		})
+	}))
+	mux.HandleFunc("GET /admin/kill-switches", auth.Require(rbac.Admin, func(w http.ResponseWriter, r *http.Request) {
+		writeKillSwitches(w, map[string]any{"kill_switches": eng.KillSwitches()})
+	}))
+	mux.HandleFunc("PUT /admin/kill-switches/{operation}", auth.Require(rbac.Admin, func(w http.ResponseWriter, r *http.Request) {
+		var s engine.KillSwitch
+		if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+			http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.Operation, s.SetAt = r.PathValue("operation"), time.Time{}
+		if c := eng.Contract(); s.Operation != engine.AllOperations && (c == nil || !hasOperation(c, s.Operation)) {
+			http.Error(w, "no such operation", http.StatusNotFound)
+			return
+		}
+		if p, ok := rbac.FromContext(r.Context()); ok {
+			s.SetBy = p.Subject
+		}
+		if err := eng.SetKillSwitch(s); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("kill switch: %s set to %s by %q: %s", s.Operation, s.Mode, s.SetBy, s.Reason)
+		writeKillSwitches(w, map[string]any{"kill_switches": eng.KillSwitches()})
+	}))
+	mux.HandleFunc("DELETE /admin/kill-switches/{operation}", auth.Require(rbac.Admin, func(w http.ResponseWriter, r *http.Request) {
+		operation := r.PathValue("operation")
+		if !eng.ClearKillSwitch(operation) {
+			http.Error(w, "no kill switch on "+operation, http.StatusNotFound)
+			return
+		}
+		log.Printf("kill switch: %s cleared", operation)
+		writeKillSwitches(w, map[string]any{"kill_switches": eng.KillSwitches()})
+	}))
+}
+
+func hasOperation(c *engine.Contract, operation string) bool {
+	_, ok := c.Operations[operation]
+	return ok
+}
+
+func writeKillSwitches(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(v)
+}
+
+// registerMirror serves what traffic mirroring found to admins.
+//
+//	GET /admin/mirror  the mirrored request counts and the most recent requests the
+//	                   secondary executor decided differently, newest first
+func registerMirror(mux *http.ServeMux, auth *rbac.Authorizer, m *mirror.Mirror) {
+	mux.HandleFunc("GET /admin/mirror", auth.Require(rbac.Admin, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(map[string]any{"stats": m.Stats(), "diffs": m.Diffs()})
+	}))
+}
+
+// defaultOutboxLimit is how many outbox entries or dead letters the admin
+// API lists unless ?limit= says otherwise.
+const defaultOutboxLimit = 100
+
+// registerOutbox serves the outbox and its dead letters under /admin/ to
+// admins.
+//
+//	GET /admin/outbox                    the count of undelivered side effects and dead
+//	                                     letters, and the oldest ?limit= undelivered
+//	                                     (default 100), with their attempts and last errors
+//	GET /admin/dead-letters              the count of dead letters and the oldest ?limit=
+//	POST /admin/dead-letters/{id}/retry  move a dead letter back to the outbox
+//	DELETE /admin/dead-letters/{id}      discard a dead letter
+func registerOutbox(mux *http.ServeMux, auth *rbac.Authorizer, outbox store.Outbox) {
+	mux.HandleFunc("GET /admin/outbox", auth.Require(rbac.Admin, func(w http.ResponseWriter, r *http.Request) {
+		limit, ok := outboxLimit(w, r)
+		if !ok {
+			return
+		}
+		entries, pending, err := outbox.PendingOutbox(r.Context(), limit)
+		var dead int64
+		if err == nil {
+			_, dead, err = outbox.DeadLetters(r.Context(), 0)
+		}
+		writeOutbox(w, map[string]any{"pending": pending, "dead_letters": dead, "entries": entries}, err)
+	}))
+	mux.HandleFunc("GET /admin/dead-letters", auth.Require(rbac.Admin, func(w http.ResponseWriter, r *http.Request) {
+		limit, ok := outboxLimit(w, r)
+		if !ok {
+			return
+		}
+		entries, total, err := outbox.DeadLetters(r.Context(), limit)
+		writeOutbox(w, map[string]any{"dead_letters": total, "entries": entries}, err)
+	}))
+	mux.HandleFunc("POST /admin/dead-letters/{id}/retry", auth.Require(rbac.Admin, func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err == nil {
+			err = outbox.RetryDeadLetter(r.Context(), id, time.Now())
+		}
+		if err == nil {
+			log.Printf("outbox: dead letter %d retried", id)
+		}
+		writeOutbox(w, map[string]any{"id": id, "retried": true}, err)
+	}))
+	mux.HandleFunc("DELETE /admin/dead-letters/{id}", auth.Require(rbac.Admin, func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err == nil {
+			err = outbox.DiscardDeadLetter(r.Context(), id)
+		}
+		if err == nil {
+			log.Printf("outbox: dead letter %d discarded", id)
+		}
+		writeOutbox(w, map[string]any{"id": id, "discarded": true}, err)
+	}))
+}
+
+// outboxLimit parses ?limit=, writing a 400 if it is malformed.
+func outboxLimit(w http.ResponseWriter, r *http.Request) (int, bool) {
+	s := r.URL.Query().Get("limit")
+	if s == "" {
+		return defaultOutboxLimit, true
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		http.Error(w, "limit must be a non-negative integer", http.StatusBadRequest)
+		return 0, false
+	}
+	return n, true
+}
+
+func writeOutbox(w http.ResponseWriter, v any, err error) {
+	var numErr *strconv.NumError
+	switch {
+	case errors.As(err, &numErr):
+		http.Error(w, "id must be an integer", http.StatusBadRequest)
+		return
+	case errors.Is(err, engine.ErrNotFound):
+		http.Error(w, "no such dead letter", http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"strings"
+
+	"covenant-poc/executor/config"
+	"covenant-poc/executor/ports/flags"
+)
+
+// configSections are the sections of a --config file.
+var configSections = []string{"server", "contracts", "ports", "storage", "auth", "telemetry"}
+
+// reload handles SIGHUP: it reloads the feature flags file, if any, and
+// logs the settings whose values differ from those applied at startup,
+// which need a restart to take effect. The caller refreshes the contracts.
+func reload(cfg config.File, applied map[string]string, flagsFile string, provider *flags.Static) {
+	log.Printf("SIGHUP: reloading")
+	if flagsFile != "" {
+		if err := provider.ReloadFile(flagsFile); err != nil {
+			log.Printf("Reload feature flags: %v", err)
+		}
+	}
+	changed, err := cfg.Changed(flag.CommandLine, applied)
+	if err != nil {
+		log.Printf("Reload config: %v", err)
+		return
+	}
+	if len(changed) > 0 {
+		log.Printf("Reload config: restart to apply %s", strings.Join(changed, ", "))
+	}
+}
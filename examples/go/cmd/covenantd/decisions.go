@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"covenant-poc/executor/engine"
+	"covenant-poc/executor/rbac"
+	"covenant-poc/executor/store"
+)
+
+// registerDecisions serves lookups against the persistent store to viewers
+// and escalation resolution to approvers.
+//
+//	GET /decisions/{id}     audit record for an invocation ID
+//	GET /decisions/export   decisions in [?from, ?to) as ?format=ndjson|parquet
+//	GET /escalations        queued escalations, filtered by ?queue= and ?status=
+//	GET /escalations/{id}   one escalation
+//	POST /escalations/{id}/resolve
+//	                        resolve a pending escalation with
+//	                        {"decision": "execute"|"deny", "resolved_by": ...,
+//	                        "justification": ...}; with access control the
+//	                        caller is the resolver. With --workflows the
+//	                        decision is handed to the escalation's workflow
+//	                        and the response is 202 with it still pending
+func registerDecisions(mux *http.ServeMux, auth *rbac.Authorizer, decisions engine.DecisionStore, history store.Log, escalations engine.EscalationStore, resolve resolveFunc) {
+	mux.HandleFunc("GET /decisions/export", auth.Require(rbac.Viewer, func(w http.ResponseWriter, r *http.Request) {
+		exportDecisions(w, r, history)
+	}))
+	mux.HandleFunc("GET /decisions/{id}", auth.Require(rbac.Viewer, func(w http.ResponseWriter, r *http.Request) {
+		rec, err := decisions.Decision(r.Context(), r.PathValue("id"))
+		writeLookup(w, rec, err)
+	}))
+	mux.HandleFunc("GET /escalations", auth.Require(rbac.Viewer, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		list, err := escalations.Escalations(r.Context(), q.Get("queue"), q.Get("status"))
+		if list == nil {
+			list = []*engine.Escalation{}
+		}
+		writeLookup(w, map[string]any{"escalations": list}, err)
+	}))
+	mux.HandleFunc("GET /escalations/{id}", auth.Require(rbac.Viewer, func(w http.ResponseWriter, r *http.Request) {
+		esc, err := escalations.Escalation(r.Context(), r.PathValue("id"))
+		writeLookup(w, esc, err)
+	}))
+	mux.HandleFunc("POST /escalations/{id}/resolve", auth.Require(rbac.Approver, func(w http.ResponseWriter, r *http.Request) {
+		resolveEscalation(w, r, resolve)
+	}))
+}
+
+// registerHistory serves the execution history to viewers.
+//
+//	GET /history?entity=invoice:inv_001
+//	                        executions on an entity instance, newest first,
+//	                        filtered by ?operation= and ?since= (RFC 3339)
+//	                        and at most ?limit= (default 100); without
+//	                        entity, all executions
+func registerHistory(mux *http.ServeMux, auth *rbac.Authorizer, executions engine.ExecutionStore) {
+	mux.HandleFunc("GET /history", auth.Require(rbac.Viewer, func(w http.ResponseWriter, r *http.Request) {
+		q, err := historyQuery(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		list, err := executions.Executions(r.Context(), q)
+		if list == nil {
+			list = []*engine.Execution{}
+		}
+		writeLookup(w, map[string]any{"executions": list}, err)
+	}))
+}
+
+func historyQuery(v url.Values) (engine.ExecutionQuery, error) {
+	q := engine.ExecutionQuery{Operation: v.Get("operation")}
+	if entity := v.Get("entity"); entity != "" {
+		var ok bool
+		if q.Entity, q.ID, ok = strings.Cut(entity, ":"); !ok || q.Entity == "" || q.ID == "" {
+			return q, fmt.Errorf("entity must be <entity>:<id>, e.g. invoice:inv_001")
+		}
+	}
+	if since := v.Get("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return q, fmt.Errorf("since must be an RFC 3339 time")
+		}
+		q.Since = t
+	}
+	if limit := v.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return q, fmt.Errorf("limit must be a positive integer")
+		}
+		q.Limit = n
+	}
+	return q, nil
+}
+
+// resolveFunc is Engine.ResolveEscalation, or workflow.Escalations.Resolve.
+type resolveFunc func(ctx context.Context, id, decision, by, justification string) (*engine.Escalation, error)
+
+func resolveEscalation(w http.ResponseWriter, r *http.Request, resolve resolveFunc) {
+	var body struct {
+		Decision      string `json:"decision"`
+		ResolvedBy    string `json:"resolved_by"`
+		Justification string `json:"justification"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if p, ok := rbac.FromContext(r.Context()); ok {
+		body.ResolvedBy = p.Subject
+	}
+	switch {
+	case body.Decision != engine.DecisionExecute && body.Decision != engine.DecisionDeny:
+		http.Error(w, "decision must be execute or deny", http.StatusBadRequest)
+		return
+	case body.ResolvedBy == "":
+		http.Error(w, "resolved_by is required", http.StatusBadRequest)
+		return
+	case strings.TrimSpace(body.Justification) == "":
+		http.Error(w, "justification is required", http.StatusBadRequest)
+		return
+	}
+	esc, err := resolve(r.Context(), r.PathValue("id"), body.Decision, body.ResolvedBy, body.Justification)
+	switch {
+	case errors.Is(err, engine.ErrStatusChanged):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err == nil && esc.Status == engine.EscalationPending:
+		// Handed to its workflow, which resolves it shortly.
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(esc)
+		return
+	}
+	writeLookup(w, esc, err)
+}
+
+// expiredEscalations counts escalations resolved at their queue's hard
+// expiry since the executor started.
+var expiredEscalations = expvar.NewInt("covenant_escalations_expired")
+
+// sweepEscalations checks pending escalations against their queue SLAs
+// immediately and then every interval until ctx is done, publishing each
+// backlog to backlog. Failures are logged and retried on the next tick.
+func sweepEscalations(ctx context.Context, eng *engine.Engine, interval time.Duration, backlog *atomic.Pointer[engine.EscalationBacklog]) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		b, err := eng.SweepEscalations(ctx)
+		if err != nil {
+			log.Printf("Escalation SLA sweep: %v", err)
+		}
+		if b != nil {
+			backlog.Store(b)
+			expiredEscalations.Add(int64(b.Expired))
+			if b.Expired > 0 {
+				log.Printf("Escalation SLA sweep: resolved %d expired escalations", b.Expired)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func writeLookup(w http.ResponseWriter, v any, err error) {
+	switch {
+	case errors.Is(err, engine.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// exportDecisions streams a time range of decisions. from and to are RFC 3339
+// timestamps defaulting to the epoch and now; format defaults to ndjson.
+func exportDecisions(w http.ResponseWriter, r *http.Request, history store.Log) {
+	q := r.URL.Query()
+	format := q.Get("format")
+	if format == "" {
+		format = store.FormatNDJSON
+	}
+	contentType, ok := store.ContentType(format)
+	if !ok {
+		http.Error(w, "format must be ndjson or parquet", http.StatusBadRequest)
+		return
+	}
+	from, to := time.Unix(0, 0), time.Now()
+	for name, t := range map[string]*time.Time{"from": &from, "to": &to} {
+		if v := q.Get(name); v != "" {
+			parsed, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				http.Error(w, name+": "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			*t = parsed
+		}
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="decisions.`+format+`"`)
+	// The status is already sent once streaming starts, so a failure can only
+	// truncate the body; the log is where it shows up.
+	if err := store.Export(r.Context(), w, history, from, to, format); err != nil {
+		log.Printf("Export decisions: %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"covenant-poc/executor/engine"
+	"covenant-poc/executor/kube"
+)
+
+// kubeSyncTimeout bounds the wait for the first list of contract objects.
+const kubeSyncTimeout = 30 * time.Second
+
+// openKubeSource starts watching the cluster for the contract a kube://
+// --contracts URL names, through the API server at apiURL or, if empty,
+// that of the cluster the executor runs in.
+func openKubeSource(rawURL, apiURL string) (*kube.Source, error) {
+	cfg, err := kube.ParseSourceURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	var client *kube.Client
+	if apiURL != "" {
+		client = kube.NewClient(apiURL, os.Getenv("COVENANT_KUBE_TOKEN"))
+	} else if client, err = kube.InCluster(); err != nil {
+		return nil, err
+	}
+	src := kube.NewSource(client, cfg)
+	go src.Run(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), kubeSyncTimeout)
+	defer cancel()
+	if err := src.WaitSynced(ctx); err != nil {
+		return nil, err
+	}
+	return src, nil
+}
+
+// refreshKube loads the contract from the cluster if its ETag has changed,
+// binding it with the --bindings file or, failing that, the bindings for
+// --env held with its files.
+func refreshKube(eng *engine.Engine, src *kube.Source, binder paramBinder) error {
+	etag, err := src.ETag()
+	if err != nil {
+		return err
+	}
+	if etag == eng.ETag() {
+		return nil
+	}
+	contract, values, etag, err := src.LoadWithBindings(binder.env)
+	if err != nil {
+		return err
+	}
+	if binder.file != "" {
+		if values, err = engine.LoadBindings(binder.file); err != nil {
+			return err
+		}
+	}
+	if err := contract.Bind(binder.env, values); err != nil {
+		return err
+	}
+	if err := checkContract(eng, contract, time.Now()); err != nil {
+		return err
+	}
+	eng.LoadContract(contract, etag)
+	log.Printf("Contracts loaded: etag=%s version=%s env=%s source=kube", etag, contract.Version, binder.env)
+	return nil
+}
@@ -0,0 +1,931 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"covenant-poc/covenant/binding"
+	"covenant-poc/covenant/covenantadmission"
+	"covenant-poc/executor/config"
+	"covenant-poc/executor/engine"
+	"covenant-poc/executor/events"
+	"covenant-poc/executor/graphql"
+	"covenant-poc/executor/identity"
+	"covenant-poc/executor/jsonrpc"
+	"covenant-poc/executor/lanes"
+	"covenant-poc/executor/mirror"
+	"covenant-poc/executor/monitor"
+	"covenant-poc/executor/notify"
+	"covenant-poc/executor/ports"
+	"covenant-poc/executor/ports/flags"
+	"covenant-poc/executor/ports/geoip"
+	"covenant-poc/executor/ports/httpport"
+	"covenant-poc/executor/ports/inmem"
+	"covenant-poc/executor/ports/normalize"
+	"covenant-poc/executor/ports/outliers"
+	"covenant-poc/executor/ports/rates"
+	"covenant-poc/executor/ports/schemas"
+	"covenant-poc/executor/ports/velocity"
+	"covenant-poc/executor/rbac"
+	"covenant-poc/executor/stats"
+	"covenant-poc/executor/store"
+	"covenant-poc/executor/store/postgres"
+	"covenant-poc/executor/store/redis"
+	"covenant-poc/executor/store/sqlite"
+	"covenant-poc/executor/stream"
+	"covenant-poc/executor/workflow"
+)
+
+func main() {
+	configFile := flag.String("config", "", "JSON config file of settings by section (server, contracts, ports, storage, auth, telemetry), named as the flags; COVENANT_<FLAG> environment variables override it and command-line flags override both")
+	validateConfig := flag.Bool("validate-config", false, "Check the settings and the files they name, then exit without opening stores or listening")
+	contractServer := flag.String("contracts", "http://localhost:26861", "Contract server base URL, or a Kubernetes source: kube://configmaps/<namespace>?domain=<domain> or kube://contracts/<namespace>?domain=<domain>")
+	kubeAPI := flag.String("kube-api", "", "Kubernetes API server URL for kube:// contracts, e.g. a kubectl proxy, with COVENANT_KUBE_TOKEN as bearer token if set (default: the cluster the executor runs in)")
+	channel := flag.String("channel", engine.ChannelPublished, "Contract channel to follow: published, or draft to try out changes before they are promoted")
+	addr := flag.String("addr", ":26860", "Listen address")
+	statsWindow := flag.Duration("stats-window", 5*time.Minute, "Sliding window for GET /stats")
+	alertWindow := flag.Duration("alert-window", 5*time.Minute, "Window for rule hit-rate anomaly detection")
+	alertWebhook := flag.String("alert-webhook", "", "URL to POST rule hit-rate alerts to (optional)")
+	env := flag.String("env", "", "Environment whose param bindings to apply (e.g. dev, prod); empty uses contract defaults")
+	flagsFile := flag.String("flags", "", "JSON feature flag file for the flags port (default: all flags off)")
+	geoipFile := flag.String("geoip", "", "CSV file of networks with their country and datacenter or tor tags, resolving request.ip into geo.country, geo.is_datacenter and geo.is_tor facts for the geo port (default: every address unknown)")
+	ratesFile := flag.String("rates", "", "JSON file of exchange rates against a base currency, {\"base\": \"USD\", \"rates\": {\"EUR\": 0.91}}, quoted to convert derivations by the rates port (default: only same-currency conversions)")
+	outlierSpec := flag.String("outliers", "", "Numeric input facts to learn distributions of from executed decisions, as fact=group_by (e.g. payment.amount.value=customer.id), served as <fact>.zscore by the outliers port")
+	velocityFile := flag.String("velocity", "", "JSON file of counters of executions per key fact over sliding windows, served as <counter>.count_<window> and <counter>.sum_<window> by the velocity port; counted in the --db or --postgres store, else in memory")
+	httpPortsFile := flag.String("http-ports", "", "JSON file of ports served by upstream HTTP APIs, templating a request for each fact and operation; replaces the built-in ports of the same name")
+	schemasFile := flag.String("schemas", "", "JSON file naming a schema registry and the subjects of port facts and operation outputs; payloads that violate their JSON Schema fail with port_contract_violation")
+	normalizeFile := flag.String("normalize", "", "JSON file of rules mapping port facts and operation outputs onto the shapes contracts expect: picking and renaming fields, scaling units, converting currencies and parsing timestamps")
+	bindingsFile := flag.String("bindings", "", "Local JSON param bindings file; overrides the contract server's bindings for --env")
+	enableGraphQL := flag.Bool("graphql", false, "Serve contract operations as GraphQL mutations at POST /graphql")
+	enableJSONRPC := flag.Bool("jsonrpc", false, "Serve contract operations as JSON-RPC 2.0 methods at POST /rpc")
+	admissionFile := flag.String("admission", "", "Binding spec whose admission section maps Kubernetes operations on resources to contract operations; serves them as a validating admission webhook at POST /admission, behind a proxy terminating HTTPS")
+	mirrorURL := flag.String("mirror", "", "Secondary executor, running a new engine or contract build, to send a sample of /execute requests to as dry runs, recording where it decides differently (see GET /admin/mirror)")
+	mirrorSample := flag.Float64("mirror-sample", 0.01, "Fraction of /execute requests sent to the --mirror executor")
+	eventsURL := flag.String("events-url", "", "URL to POST decision CloudEvents to (optional)")
+	eventsMode := flag.String("events-mode", events.ModeBinary, "CloudEvents content mode: binary or structured")
+	eventsSource := flag.String("events-source", "/covenant/executor", "CloudEvents source attribute for decision events")
+	dbPath := flag.String("db", "", "SQLite database for decision history, idempotency keys and escalations (optional)")
+	postgresURL := flag.String("postgres", "", "Postgres URL for decision history, idempotency keys and escalations shared across replicas; overrides --db")
+	stateSpec := flag.String("state", "", "Store for entity state read by entity:<entity>.state: memory, or a redis:// URL; default the --db or --postgres store, else memory")
+	rbacFile := flag.String("rbac", "", "JSON file mapping bearer token claims to roles; enables access control on the admin, decision and escalation APIs (tokens are verified with COVENANT_AUTH_SECRET)")
+	identitySpec := flag.String("identity", "", "Comma-separated providers of callers' ctx facts, tried in order: oidc (bearer tokens verified per --rbac) or spiffe (the SPIFFE ID in X-Forwarded-Client-Cert, from an mTLS-terminating proxy); replaces any context in request bodies")
+	notifyFile := flag.String("notify", "", "JSON file of the Slack, email and PagerDuty notifiers each escalation queue notifies (needs --db or --postgres)")
+	publicURL := flag.String("public-url", "http://localhost:26860", "Base URL of this executor for the links in escalation notifications")
+	workflowJournal := flag.String("workflows", "", "JSON Lines journal of durable escalation workflows; when set, each escalation waits for its decision or hard expiry in a workflow that survives restarts (needs --db or --postgres)")
+	useOutbox := flag.Bool("outbox", false, "Deliver decision events and escalation notifications through an outbox written with each decision in the store, so they are delivered even if the executor stops first (needs --db or --postgres)")
+	outboxMaxAttempts := flag.Int("outbox-max-attempts", 10, "Deliveries of an outbox entry to attempt before moving it to the dead letters (0 retries until delivered)")
+	slaInterval := flag.Duration("sla-interval", time.Minute, "How often to check pending escalations against their queue SLAs and resolve those past their hard expiry")
+	retention := flag.Duration("retention", 30*24*time.Hour, "Prune decision history older than this from the store (0 keeps everything)")
+	retentionMax := flag.Int64("retention-max-records", 0, "Keep at most this many decisions in the store, pruning the oldest (0 for no limit)")
+	auditSample := flag.String("audit-sample", "", "Per-outcome fraction of decisions sent to the store and events, e.g. executed=0.01,would_execute=0.1 (unlisted outcomes: all)")
+	auditSuppress := flag.String("audit-suppress", "", "Comma-separated input fields and facts removed from decisions sent to the store and events")
+	concurrency := flag.String("concurrency", "", "Per-operation execution limits as operation=max:queue, e.g. ProcessPayment=5:20; overrides the contract")
+	streamHeartbeat := flag.Duration("stream-heartbeat", 15*time.Second, "Idle interval after which streamed simulation results send a heartbeat (0 for none)")
+	laneSpec := flag.String("lanes", "interactive=32:1000,batch=4:10000", "Worker pools per priority lane as lane=workers:queue")
+	cacheSize := flag.Int("response-cache", 10000, "Responses of cacheable operations to keep in memory (0 disables caching)")
+	decisionCacheSize := flag.Int("decision-cache", 0, "Rule evaluation results to keep in memory, reused by requests with identical facts (0 disables)")
+	flag.StringVar(&snapshotPath, "snapshot", "", "Compiled contract file shared by the executors on a host, e.g. /dev/shm/covenant-billing.snap; the first to load a contract writes it and the rest read it instead of compiling (all must share --env and --bindings)")
+	flag.Parse()
+	cfg := config.File{Path: *configFile, Sections: configSections}
+	applied, err := cfg.Apply(flag.CommandLine)
+	if err != nil {
+		log.Fatalf("Config: %v", err)
+	}
+
+	binder := paramBinder{env: *env, file: *bindingsFile}
+
+	var auth *rbac.Authorizer
+	if *rbacFile != "" {
+		var err error
+		if auth, err = rbac.LoadFile(*rbacFile); err != nil {
+			log.Fatalf("Load access control: %v", err)
+		}
+	}
+	var admission *binding.Spec
+	if *admissionFile != "" {
+		var err error
+		if admission, err = binding.Load(*admissionFile); err != nil {
+			log.Fatalf("Load admission bindings: %v", err)
+		}
+	}
+	identities, err := identityProvider(*identitySpec, auth)
+	if err != nil {
+		log.Fatalf("Identity providers: %v", err)
+	}
+
+	// Build port registry.
+	registry := ports.NewRegistry()
+	registry.Register("customerRepo", inmem.NewCustomerRepo())
+	registry.Register("paymentProcessor", inmem.NewPaymentProcessor())
+	invoiceRepo := inmem.NewInvoiceRepo()
+	registry.Register("invoiceRepo", invoiceRepo)
+
+	flagProvider := flags.NewStatic(nil)
+	if *flagsFile != "" {
+		var err error
+		if flagProvider, err = flags.LoadFile(*flagsFile); err != nil {
+			log.Fatalf("Load feature flags: %v", err)
+		}
+	}
+	registry.Register("flags", flags.NewPort(flagProvider, "customer.id"))
+	geoRanges := geoip.NewRanges(nil)
+	if *geoipFile != "" {
+		var err error
+		if geoRanges, err = geoip.LoadFile(*geoipFile); err != nil {
+			log.Fatalf("Load GeoIP ranges: %v", err)
+		}
+	}
+	registry.Register("geo", geoip.NewPort(geoRanges, geoip.DefaultIPFact))
+	rateTable := &rates.Table{Base: "USD"}
+	if *ratesFile != "" {
+		var err error
+		if rateTable, err = rates.LoadFile(*ratesFile); err != nil {
+			log.Fatalf("Load exchange rates: %v", err)
+		}
+	}
+	registry.Register(engine.RatesPort, rates.NewPort(rateTable))
+	tracked, err := outliers.Parse(*outlierSpec)
+	if err != nil {
+		log.Fatalf("--outliers: %v", err)
+	}
+	outlierDetector := outliers.NewDetector(tracked)
+	registry.Register("outliers", outlierDetector)
+	if *httpPortsFile != "" {
+		httpPorts, err := httpport.LoadFile(*httpPortsFile)
+		if err != nil {
+			log.Fatalf("Load HTTP ports: %v", err)
+		}
+		for name, p := range httpPorts {
+			registry.Register(name, p)
+		}
+	}
+	// Schemas describe what upstreams return, so they are checked before
+	// normalization.
+	if *schemasFile != "" {
+		schemaRegistry, subjects, err := schemas.LoadFile(*schemasFile)
+		if err != nil {
+			log.Fatalf("Load schemas: %v", err)
+		}
+		for name, s := range subjects {
+			c, ok := registry.Client(name)
+			if !ok {
+				log.Fatalf("Load schemas: no port %q", name)
+			}
+			registry.Register(name, schemas.Wrap(c, name, s, schemaRegistry))
+		}
+	}
+	if *normalizeFile != "" {
+		rules, err := normalize.LoadFile(*normalizeFile)
+		if err != nil {
+			log.Fatalf("Load normalization rules: %v", err)
+		}
+		for name, cfg := range rules {
+			c, ok := registry.Client(name)
+			if !ok {
+				log.Fatalf("Load normalization rules: no port %q", name)
+			}
+			registry.Register(name, normalize.Wrap(c, cfg))
+		}
+	}
+
+	aggregator := stats.NewAggregator(*statsWindow)
+
+	alerters := []monitor.Alerter{monitor.LogAlerter{}, monitor.MetricAlerter{}}
+	if *alertWebhook != "" {
+		alerters = append(alerters, monitor.WebhookAlerter{URL: *alertWebhook})
+	}
+	ruleMonitor := monitor.New(monitor.Config{Window: *alertWindow}, alerters...)
+
+	// Stats, the rule monitor and the outlier detector need every decision
+	// to compute rates and distributions; the store and event stream get the
+	// sampled telemetry.
+	opts := []engine.Option{
+		engine.WithAuditSink(aggregator),
+		engine.WithAuditSink(ruleMonitor),
+		engine.WithAuditSink(outlierDetector),
+		engine.WithResponseCache(*cacheSize),
+		engine.WithDecisionCache(*decisionCacheSize),
+	}
+	laneConfig, err := lanes.Parse(*laneSpec)
+	if err != nil {
+		log.Fatalf("--lanes: %v", err)
+	}
+	for _, p := range []string{engine.PriorityInteractive, engine.PriorityBatch} {
+		if _, ok := laneConfig[p]; !ok {
+			log.Fatalf("--lanes: no %s lane", p)
+		}
+	}
+	if *mirrorSample < 0 || *mirrorSample > 1 {
+		log.Fatalf("--mirror-sample: %v is not between 0 and 1", *mirrorSample)
+	}
+	scheduler := lanes.New(laneConfig)
+	expvar.Publish("covenant_lanes", expvar.Func(func() any { return scheduler.Stats() }))
+	opts = append(opts, engine.WithScheduler(scheduler))
+
+	limits, err := engine.ParseConcurrencyLimits(*concurrency)
+	if err != nil {
+		log.Fatalf("--concurrency: %v", err)
+	}
+	if len(limits) > 0 {
+		opts = append(opts, engine.WithConcurrencyLimits(limits))
+	}
+	rates, err := engine.ParseSampleRates(*auditSample)
+	if err != nil {
+		log.Fatalf("--audit-sample: %v", err)
+	}
+	sampling := engine.Sampling{Rates: rates}
+	if *auditSuppress != "" {
+		sampling.Suppress = strings.Split(*auditSuppress, ",")
+	}
+	// With --outbox, decision events and notifications are delivered from
+	// the store rather than as audit sinks.
+	var outboxSinks []store.OutboxSink
+	if *eventsURL != "" {
+		emitter, err := events.NewEmitter(events.Config{URL: *eventsURL, Mode: *eventsMode, Source: *eventsSource})
+		if err != nil {
+			log.Fatalf("Decision events: %v", err)
+		}
+		if *useOutbox {
+			outboxSinks = append(outboxSinks, store.OutboxSink{Name: "events", Deliver: emitter.Deliver})
+		} else {
+			opts = append(opts, engine.WithAuditSink(engine.SampledSink(emitter, sampling)))
+		}
+	}
+
+	// Nothing so far has opened a store or a connection; the files read
+	// after it are checked here.
+	if *validateConfig {
+		if *notifyFile != "" {
+			if _, err := notify.LoadFile(*notifyFile); err != nil {
+				log.Fatalf("Load notifiers: %v", err)
+			}
+		}
+		if *velocityFile != "" {
+			if _, err := velocity.LoadFile(*velocityFile); err != nil {
+				log.Fatalf("Load velocity counters: %v", err)
+			}
+		}
+		log.Printf("Config is valid")
+		return
+	}
+
+	var db historyStore
+	switch {
+	case *postgresURL != "":
+		pg, err := postgres.Open(context.Background(), *postgresURL)
+		if err != nil {
+			log.Fatalf("Open store: %v", err)
+		}
+		db = pg
+	case *dbPath != "":
+		lite, err := sqlite.Open(*dbPath)
+		if err != nil {
+			log.Fatalf("Open store: %v", err)
+		}
+		db = lite
+	case *notifyFile != "":
+		log.Fatalf("--notify needs --db or --postgres to track escalations")
+	case *workflowJournal != "":
+		log.Fatalf("--workflows needs --db or --postgres to track escalations")
+	case *useOutbox:
+		log.Fatalf("--outbox needs --db or --postgres")
+	}
+	var workflows *workflow.Escalations
+	if db != nil {
+		var escalations engine.EscalationStore = db
+		if *workflowJournal != "" {
+			journal, err := workflow.OpenFileJournal(*workflowJournal)
+			if err != nil {
+				log.Fatalf("Open workflow journal: %v", err)
+			}
+			workflows = workflow.NewEscalations(db, workflow.NewRuntime(journal))
+			escalations = workflows
+		}
+		opts = append(opts,
+			engine.WithAuditSink(engine.SampledSink(db, sampling)),
+			engine.WithIdempotencyStore(db),
+			engine.WithEscalationStore(escalations),
+		)
+		if *notifyFile != "" {
+			queues, err := notify.LoadFile(*notifyFile)
+			if err != nil {
+				log.Fatalf("Load notifiers: %v", err)
+			}
+			dispatcher := notify.NewDispatcher(notify.Config{Queues: queues, BaseURL: *publicURL}, db)
+			if *useOutbox {
+				outboxSinks = append(outboxSinks, store.OutboxSink{Name: "notify", Accept: notify.Notifies, Deliver: dispatcher.Deliver})
+			} else {
+				opts = append(opts, engine.WithAuditSink(dispatcher))
+			}
+		}
+		if *useOutbox {
+			db.EnableOutbox(outboxSinks...)
+			go store.NewOutboxDispatcher(db, *outboxMaxAttempts, outboxSinks...).Run(context.Background(), time.Second)
+			expvar.Publish("covenant_dead_letters", expvar.Func(func() any {
+				_, n, err := db.DeadLetters(context.Background(), 0)
+				if err != nil {
+					return nil
+				}
+				return n
+			}))
+		}
+		policy := store.Retention{MaxAge: *retention, MaxRecords: *retentionMax}
+		go policy.Enforce(context.Background(), db, time.Hour)
+	}
+	states, err := openStateStore(*stateSpec, db)
+	if err != nil {
+		log.Fatalf("Open state store: %v", err)
+	}
+	opts = append(opts, engine.WithStateStore(states))
+	// Executions are kept unsampled, for prerequisites and GET /history.
+	var executions engine.ExecutionStore = engine.NewMemoryExecutionStore(memoryExecutions)
+	if db != nil {
+		executions = db
+	}
+	opts = append(opts, engine.WithHistory(executions))
+	if *velocityFile != "" {
+		counters, err := velocity.LoadFile(*velocityFile)
+		if err != nil {
+			log.Fatalf("Load velocity counters: %v", err)
+		}
+		if db != nil && *retention > 0 && velocity.Keep(counters) > *retention {
+			log.Fatalf("--velocity: windows longer than --retention %v would lose counts to pruning", *retention)
+		}
+		var counts engine.CounterStore = db
+		if db == nil {
+			counts = engine.NewMemoryCounterStore(velocity.Keep(counters))
+		}
+		tracker, err := velocity.NewTracker(counts, counters)
+		if err != nil {
+			log.Fatalf("Load velocity counters: %v", err)
+		}
+		registry.Register("velocity", tracker)
+		// Like the stats, velocity counts every execution.
+		opts = append(opts, engine.WithAuditSink(tracker))
+	}
+
+	eng := engine.NewEngine(registry, opts...)
+	expvar.Publish("covenant_cache", expvar.Func(func() any { return eng.CacheStats() }))
+	var mirrored *mirror.Mirror
+	if *mirrorURL != "" {
+		mirrored = mirror.New(mirror.Config{URL: *mirrorURL, Sample: *mirrorSample})
+		expvar.Publish("covenant_mirror", expvar.Func(func() any { return mirrored.Stats() }))
+	}
+	expvar.Publish("covenant_decision_cache", expvar.Func(func() any { return eng.DecisionCacheStats() }))
+	expvar.Publish("covenant_contract_load", expvar.Func(func() any { return contractLoader.LastLoad() }))
+
+	// Load contracts from the contract server, or from the cluster, which
+	// also reloads them as soon as they change.
+	refresh := func() error { return refreshContracts(eng, *contractServer, *channel, binder) }
+	var changes <-chan struct{}
+	if strings.HasPrefix(*contractServer, "kube://") {
+		if *channel != engine.ChannelPublished {
+			log.Fatalf("--channel: kube:// contracts have no %s channel", *channel)
+		}
+		src, err := openKubeSource(*contractServer, *kubeAPI)
+		if err != nil {
+			log.Fatalf("--contracts: %v", err)
+		}
+		refresh = func() error { return refreshKube(eng, src, binder) }
+		changes = src.Changes()
+	}
+	if err := refresh(); err != nil {
+		logLoadError("Initial contract load failed", err)
+		os.Exit(1)
+	}
+	if admission != nil {
+		checkAdmission(admission, eng.Contract())
+	}
+
+	// Escalation workflows interrupted by the last shutdown carry on.
+	if workflows != nil {
+		workflows.Register(eng)
+		if err := workflows.Resume(context.Background()); err != nil {
+			log.Printf("Resume escalation workflows: %v", err)
+		}
+	}
+
+	// Escalation SLAs are tracked in the store.
+	var backlog atomic.Pointer[engine.EscalationBacklog]
+	expvar.Publish("covenant_escalations", expvar.Func(func() any { return backlog.Load() }))
+	if db != nil {
+		go sweepEscalations(context.Background(), eng, *slaInterval, &backlog)
+	}
+
+	// Poll for contract updates every 30 seconds, on SIGHUP, which also
+	// reloads the feature flags, and on changes in the cluster.
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		ticker := time.NewTicker(30 * time.Second)
+		for {
+			select {
+			case <-ticker.C:
+			case <-hup:
+				reload(cfg, applied, *flagsFile, flagProvider)
+			case <-changes:
+			}
+			recordLoadResult(refresh())
+		}
+	}()
+
+	http.HandleFunc("POST /execute", func(w http.ResponseWriter, r *http.Request) {
+		var req engine.Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		if key := r.Header.Get("Idempotency-Key"); key != "" && req.IdempotencyKey == "" {
+			req.IdempotencyKey = key
+		}
+		protocol, err := engine.NegotiateProtocol(req.ProtocolVersion)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx := context.Background()
+		if facts, ok := engine.CallerFacts(r.Context()); ok {
+			ctx = engine.WithCallerFacts(ctx, facts)
+		}
+		resp, err := eng.Evaluate(ctx, &req)
+		if errors.Is(err, engine.ErrUnknownSession) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("eval error: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if resp.Cache != nil {
+			w.Header().Set("ETag", resp.Cache.ETag)
+			w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(time.Until(resp.Cache.Expires).Seconds())))
+			// The operation is a read, so a matching validator means the
+			// client's copy of the output is current.
+			if engine.ETagMatch(r.Header.Get("If-None-Match"), resp.Cache.ETag) {
+				w.WriteHeader(http.StatusNotModified)
+				log.Printf("op=%s outcome=%s not_modified=true", req.Operation, resp.Outcome)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp.ForProtocol(protocol)); err != nil {
+			log.Printf("encode error: %v", err)
+		}
+
+		log.Printf("op=%s outcome=%s dry_run=%v", req.Operation, resp.Outcome, req.DryRun)
+		if mirrored != nil {
+			mirrored.Offer(ctx, &req, resp)
+		}
+	})
+
+	http.HandleFunc("POST /simulate", func(w http.ResponseWriter, r *http.Request) {
+		var req engine.SimulateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		protocol, err := engine.NegotiateProtocol(req.ProtocolVersion)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		resp, err := eng.Simulate(&req)
+		if err != nil {
+			log.Printf("simulate error: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp.ForProtocol(protocol)); err != nil {
+			log.Printf("encode error: %v", err)
+		}
+
+		log.Printf("op=%s outcome=%s simulated=true", req.Operation, resp.Outcome)
+	})
+
+	http.HandleFunc("POST /simulate/batch", func(w http.ResponseWriter, r *http.Request) {
+		var req engine.BatchSimulateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		if format, ok := stream.Negotiate(r); ok {
+			streamBatch(w, r, eng, &req, format, *streamHeartbeat)
+			return
+		}
+
+		resp, err := eng.SimulateBatch(&req)
+		if err != nil {
+			log.Printf("simulate batch error: %v", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Printf("encode error: %v", err)
+		}
+
+		log.Printf("op=%s rows=%d outcomes=%v simulated=true", req.Operation, len(req.Rows), resp.Outcomes)
+	})
+
+	http.HandleFunc("POST /intents", func(w http.ResponseWriter, r *http.Request) {
+		var intent engine.Intent
+		if err := json.NewDecoder(r.Body).Decode(&intent); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		receipt, err := eng.Prefetch(r.Context(), &intent)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(receipt)
+		log.Printf("intent=%s op=%s prefetched=%d", receipt.IntentID, intent.Operation, len(receipt.Facts))
+	})
+
+	http.HandleFunc("POST /plan/validate", func(w http.ResponseWriter, r *http.Request) {
+		var req engine.PlanRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		ctx := context.Background()
+		if facts, ok := engine.CallerFacts(r.Context()); ok {
+			ctx = engine.WithCallerFacts(ctx, facts)
+		}
+		result, err := eng.ValidatePlan(ctx, &req)
+		switch {
+		case errors.Is(err, engine.ErrUnknownSession):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			log.Printf("encode error: %v", err)
+		}
+		log.Printf("plan steps=%d valid=%v", len(req.Steps), result.Valid)
+	})
+
+	http.HandleFunc("POST /sessions", func(w http.ResponseWriter, r *http.Request) {
+		var opts engine.SessionOptions
+		if err := json.NewDecoder(r.Body).Decode(&opts); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		sess, err := eng.OpenSession(opts)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(sess)
+		log.Printf("session=%s opened", sess.SessionID)
+	})
+
+	http.HandleFunc("GET /sessions/{id}", func(w http.ResponseWriter, r *http.Request) {
+		transcript, err := eng.Transcript(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(transcript)
+	})
+
+	http.HandleFunc("DELETE /sessions/{id}", func(w http.ResponseWriter, r *http.Request) {
+		transcript, err := eng.CloseSession(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(transcript)
+		log.Printf("session=%s closed decisions=%d facts_reused=%d", transcript.SessionID, len(transcript.Decisions), transcript.FactsReused)
+	})
+
+	http.HandleFunc("GET /versions", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"versions": eng.Versions()})
+	})
+
+	http.Handle("GET /stats", stats.Handler(stats.WithEscalationBacklog(aggregator, backlog.Load)))
+	http.Handle("GET /stats/experiments", stats.ExperimentsHandler(aggregator))
+
+	if *enableGraphQL {
+		graphql.NewHandler(eng).Register(http.DefaultServeMux)
+	}
+	if *enableJSONRPC {
+		http.Handle("POST /rpc", jsonrpc.NewHandler(eng))
+	}
+	if admission != nil {
+		http.Handle("POST /admission", covenantadmission.Handler(eng, covenantadmission.FromSpec(admission)))
+	}
+
+	if db != nil {
+		resolve := eng.ResolveEscalation
+		if workflows != nil {
+			resolve = workflows.Resolve
+		}
+		registerDecisions(http.DefaultServeMux, auth, db, db, db, resolve)
+		if *useOutbox {
+			registerOutbox(http.DefaultServeMux, auth, db)
+		}
+	}
+
+	registerHistory(http.DefaultServeMux, auth, executions)
+	registerUI(http.DefaultServeMux, eng)
+	registerAdmin(http.DefaultServeMux, auth, eng)
+	if mirrored != nil {
+		registerMirror(http.DefaultServeMux, auth, mirrored)
+	}
+
+	log.Printf("Executor listening on %s (contracts: %s)", *addr, *contractServer)
+	var handler http.Handler = http.DefaultServeMux
+	if identities != nil {
+		handler = identity.Middleware(identities, handler)
+	}
+	log.Fatal(http.ListenAndServe(*addr, handler))
+}
+
+// identityProvider builds the --identity providers. OIDC tokens are verified
+// by the --rbac access control.
+// streamBatch answers POST /simulate/batch as a stream: a row event per row
+// as it is simulated, then a trailer event with the totals. A failure
+// before anything is sent is an ordinary 400; after that, an error event
+// ends the stream.
+func streamBatch(w http.ResponseWriter, r *http.Request, eng *engine.Engine, req *engine.BatchSimulateRequest, format string, heartbeat time.Duration) {
+	sw := stream.NewWriter(w, format, heartbeat)
+	defer sw.Close()
+
+	trailer, err := eng.StreamBatch(r.Context(), req, func(index int, row engine.BatchRow) error {
+		return sw.Send("row", struct {
+			Index int `json:"index"`
+			engine.BatchRow
+		}{index, row})
+	})
+	if err != nil {
+		log.Printf("simulate batch error: %v", err)
+		if !sw.Error(err.Error(), http.StatusBadRequest) {
+			sw.Send("error", map[string]string{"message": err.Error()})
+		}
+		return
+	}
+	if err := sw.Send("trailer", trailer); err != nil {
+		log.Printf("simulate batch stream error: %v", err)
+	}
+	log.Printf("op=%s rows=%d outcomes=%v simulated=true stream=%s", req.Operation, trailer.Rows, trailer.Outcomes, format)
+}
+
+func identityProvider(spec string, auth *rbac.Authorizer) (identity.Provider, error) {
+	var providers []identity.Provider
+	for _, name := range strings.Split(spec, ",") {
+		switch strings.TrimSpace(name) {
+		case "":
+		case "oidc":
+			if auth == nil {
+				return nil, fmt.Errorf("oidc needs --rbac to verify tokens")
+			}
+			providers = append(providers, identity.OIDC{Claims: auth.Claims})
+		case "spiffe":
+			// The executor serves plain HTTP, so the ID comes from the proxy.
+			providers = append(providers, identity.SPIFFE{Forwarded: true})
+		default:
+			return nil, fmt.Errorf("unknown provider %q (want oidc or spiffe)", name)
+		}
+	}
+	if len(providers) == 0 {
+		return nil, nil
+	}
+	return identity.First(providers...), nil
+}
+
+// openStateStore opens the --state store. Without one, entity state lives
+// in the history store if there is one.
+func openStateStore(spec string, db historyStore) (engine.StateStore, error) {
+	switch {
+	case strings.HasPrefix(spec, "redis://"):
+		return redis.Open(context.Background(), spec)
+	case spec == "memory", spec == "" && db == nil:
+		return engine.NewMemoryStateStore(), nil
+	case spec == "":
+		return db, nil
+	}
+	return nil, fmt.Errorf("unknown store %q (want memory or a redis:// URL)", spec)
+}
+
+// memoryExecutions is how many executions are kept without --db or
+// --postgres.
+const memoryExecutions = 100_000
+
+// historyStore is the persistent store behind --db or --postgres.
+type historyStore interface {
+	engine.DecisionStore
+	engine.IdempotencyStore
+	engine.EscalationStore
+	engine.StateStore
+	engine.CounterStore
+	engine.ExecutionStore
+	store.Log
+	store.Pruner
+	store.Outbox
+}
+
+// tornReadRetries is how many times a refresh starts over when the contract
+// changes on the server while its files are being fetched.
+const tornReadRetries = 3
+
+func refreshContracts(eng *engine.Engine, serverURL, channel string, binder paramBinder) error {
+	for attempt := 1; ; attempt++ {
+		err := refreshOnce(eng, serverURL, channel, binder)
+		if !errors.Is(err, engine.ErrTornRead) || attempt > tornReadRetries {
+			return err
+		}
+		log.Printf("Contract changed during fetch; retrying (%d/%d)", attempt, tornReadRetries)
+		time.Sleep(time.Duration(attempt) * 100 * time.Millisecond)
+	}
+}
+
+func refreshOnce(eng *engine.Engine, serverURL, channel string, binder paramBinder) error {
+	disc, err := engine.FetchDiscovery(serverURL)
+	if err != nil {
+		return err
+	}
+	if err := disc.UseChannel(channel); err != nil {
+		return err
+	}
+
+	pending := eng.Pending()
+	switch {
+	case disc.ContractETag != "" && disc.ContractETag == eng.ETag():
+		// Unchanged.
+	case pending != nil && disc.ContractETag == pending.ETag:
+		// The server has passed the activation time of the contract we have
+		// staged; it activates on our own clock.
+	default:
+		contract, err := activeContract(eng, serverURL, disc, binder)
+		if err != nil {
+			return err
+		}
+		eng.LoadContract(contract, disc.ContractETag)
+		log.Printf("Contracts loaded: etag=%s version=%s env=%s service=%s channel=%s", disc.ContractETag, contract.Version, binder.env, disc.Service, channel)
+	}
+
+	return refreshSchedule(eng, serverURL, disc, binder)
+}
+
+// refreshSchedule stages the contract the server has scheduled, or cancels
+// ours if the server withdrew it.
+func refreshSchedule(eng *engine.Engine, serverURL string, disc *engine.Discovery, binder paramBinder) error {
+	pending := eng.Pending()
+	sched := disc.Scheduled
+	if sched == nil {
+		if pending != nil && pending.ETag != disc.ContractETag {
+			eng.CancelPending()
+			log.Printf("Scheduled contract %s withdrawn", pending.ETag)
+		}
+		return nil
+	}
+	if pending != nil && pending.ETag == sched.ContractETag && pending.ActivateAt.Equal(sched.ActivateAt) {
+		return nil
+	}
+
+	// Validate as of the activation time, so rules that will have expired
+	// by then are reported now.
+	contract, err := prepareContract(eng, serverURL, sched.Contracts, binder, sched.ActivateAt)
+	if err != nil {
+		return fmt.Errorf("scheduled contract %s: %w", sched.ContractETag, err)
+	}
+	eng.ScheduleContract(contract, sched.ContractETag, sched.ActivateAt)
+	log.Printf("Contract scheduled: etag=%s version=%s activate_at=%s", sched.ContractETag, contract.Version, sched.ActivateAt.Format(time.RFC3339))
+	return nil
+}
+
+// contractLoader loads contracts incrementally, so a refresh after one file
+// changed refetches and recompiles only that file.
+var contractLoader = engine.NewLoader()
+
+// snapshotPath is the --snapshot file through which the executors on a host
+// share the active contract.
+var snapshotPath string
+
+// activeContract prepares the contract discovery lists, or reads it from
+// the snapshot file if another executor has already prepared it. A contract
+// this executor prepares is written to the snapshot file for the others.
+func activeContract(eng *engine.Engine, serverURL string, disc *engine.Discovery, binder paramBinder) (*engine.Contract, error) {
+	if snapshotPath == "" || disc.ContractETag == "" {
+		return prepareContract(eng, serverURL, disc.Contracts, binder, time.Now())
+	}
+	contract, err := engine.ReadSnapshot(snapshotPath, disc.ContractETag, binder.env)
+	if err == nil {
+		log.Printf("Contract read from snapshot %s", snapshotPath)
+		return contract, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) && !errors.Is(err, engine.ErrSnapshotMismatch) {
+		log.Printf("Contract snapshot unreadable, compiling instead: %v", err)
+	}
+	contract, err = prepareContract(eng, serverURL, disc.Contracts, binder, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if err := engine.WriteSnapshot(snapshotPath, disc.ContractETag, contract); err != nil {
+		log.Printf("Write contract snapshot: %v", err)
+	}
+	return contract, nil
+}
+
+// prepareContract loads, binds, validates and warms up a contract.
+func prepareContract(eng *engine.Engine, serverURL string, cf engine.ContractFiles, binder paramBinder, at time.Time) (*engine.Contract, error) {
+	contract, err := contractLoader.LoadContractFiles(serverURL, cf)
+	if err != nil {
+		return nil, err
+	}
+	if err := binder.bind(contract, serverURL, cf); err != nil {
+		return nil, err
+	}
+	if err := checkContract(eng, contract, at); err != nil {
+		return nil, err
+	}
+	return contract, nil
+}
+
+// checkAdmission checks the admission bindings against the contract first
+// loaded, exiting on errors.
+func checkAdmission(spec *binding.Spec, contract *engine.Contract) {
+	failed := false
+	for _, d := range spec.Validate(contract) {
+		log.Printf("--admission: %s", d)
+		failed = failed || d.Severity == engine.SeverityError
+	}
+	if failed {
+		log.Fatalf("--admission: the bindings do not match the contract")
+	}
+}
+
+// checkContract validates and warms up a bound contract. Validation and
+// warm-up errors reject it; warnings are logged.
+func checkContract(eng *engine.Engine, contract *engine.Contract, at time.Time) error {
+	for _, d := range engine.Validate(contract, at) {
+		if d.Severity == engine.SeverityError {
+			return fmt.Errorf("contract invalid: %s", d)
+		}
+		log.Printf("Contract %s", d)
+	}
+	if diags := eng.Warmup(contract, at); len(diags) > 0 {
+		return fmt.Errorf("contract warm-up failed: %s", diags[0])
+	}
+	return nil
+}
+
+// paramBinder resolves contract params for the executor's environment from a
+// local bindings file or, failing that, the contract server.
+type paramBinder struct {
+	env  string
+	file string
+}
+
+func (b paramBinder) bind(c *engine.Contract, serverURL string, cf engine.ContractFiles) error {
+	var values map[string]any
+	var err error
+	switch {
+	case b.file != "":
+		values, err = engine.LoadBindings(b.file)
+	case b.env != "":
+		values, err = engine.FetchBindings(serverURL, cf, b.env)
+	}
+	if err != nil {
+		return err
+	}
+	return c.Bind(b.env, values)
+}
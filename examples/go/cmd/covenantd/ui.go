@@ -0,0 +1,40 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"io/fs"
+	"log"
+	"net/http"
+
+	"covenant-poc/executor/engine"
+)
+
+//go:embed ui
+var uiFiles embed.FS
+
+// registerUI serves the embedded contract browser and escalation review at
+// /ui and the loaded contract the browser reads at GET /contract. The review
+// page reads and resolves escalations through the store's endpoints.
+func registerUI(mux *http.ServeMux, eng *engine.Engine) {
+	static, err := fs.Sub(uiFiles, "ui")
+	if err != nil {
+		log.Fatalf("embedded ui: %v", err)
+	}
+	mux.Handle("GET /ui/", http.StripPrefix("/ui/", http.FileServerFS(static)))
+	mux.Handle("GET /ui", http.RedirectHandler("/ui/", http.StatusMovedPermanently))
+
+	mux.HandleFunc("GET /contract", func(w http.ResponseWriter, r *http.Request) {
+		c := eng.Contract()
+		if c == nil {
+			http.Error(w, "no contract loaded", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"`+eng.ETag()+`"`)
+		json.NewEncoder(w).Encode(map[string]any{
+			"contract_etag": eng.ETag(),
+			"contract":      c,
+		})
+	})
+}
@@ -0,0 +1,51 @@
+// Command codegen generates a client SDK from a contract directory:
+//
+//	go run ./codegen --dir ./contracts/billing --lang typescript --out billing.ts
+//	go run ./codegen --dir ./contracts/billing --lang python --out billing.py
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"covenant-poc/covenant/codegen"
+	"covenant-poc/executor/engine"
+)
+
+// generators are the client languages, by --lang.
+var generators = map[string]func(io.Writer, *codegen.API) error{
+	"typescript": codegen.TypeScript,
+	"python":     codegen.Python,
+}
+
+func main() {
+	dir := flag.String("dir", "./contracts/billing", "Contract domain directory to generate a client for")
+	lang := flag.String("lang", "typescript", "Client language: typescript or python")
+	out := flag.String("out", "", "Write the client to this file (default stdout)")
+	flag.Parse()
+
+	generate, ok := generators[*lang]
+	if !ok {
+		log.Fatalf("Unknown --lang %q", *lang)
+	}
+	c, err := engine.CompileDir(*dir)
+	if err != nil {
+		log.Fatalf("Compile %s: %v", *dir, err)
+	}
+	var buf bytes.Buffer
+	if err := generate(&buf, codegen.Describe(c)); err != nil {
+		log.Fatalf("Generate: %v", err)
+	}
+	if *out == "" {
+		os.Stdout.Write(buf.Bytes())
+		return
+	}
+	if err := os.WriteFile(*out, buf.Bytes(), 0o644); err != nil {
+		log.Fatalf("Write %s: %v", *out, err)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %s client for %s to %s\n", *lang, *dir, *out)
+}
@@ -0,0 +1,21 @@
+// Billing contract metadata.
+
+// Semantic version. Bump MAJOR when a change can turn a previously allowed
+// invocation into a denial or remove an operation, MINOR for additive
+// changes, PATCH otherwise. Clients may pin a range (e.g. "^1.0") with
+// version_range on /execute.
+version: "1.0.0"
+
+// Base contracts composed under this domain (contracts/<name>).
+imports: ["common"]
+
+// Escalation queues. Escalate verdicts must name one of these.
+queues: {
+	"payment-review": {
+		sla:         "4h"
+		owner:       "payments-ops"
+		description: "Manual review of large payments"
+		hard_expiry: "24h"
+		on_expiry:   "deny"
+	}
+}
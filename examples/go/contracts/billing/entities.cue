@@ -2,6 +2,7 @@
 
 entities: {
 	"invoice": {
+		key:      "invoice.id"
 		states:   ["draft", "submitted", "approved", "paid", "cancelled"]
 		initial:  "draft"
 		terminal: ["paid", "cancelled"]
@@ -1,11 +1,9 @@
 // Billing domain facts.
 // No package declaration — compiled and unified by the executor.
 
+// customer.id and customer.status are inherited from common.
+
 facts: {
-	"customer.id": {
-		source:   "input"
-		required: true
-	}
 	"invoice.id": {
 		source:   "input"
 		required: true
@@ -14,15 +12,12 @@ facts: {
 		source:   "input"
 		required: true
 	}
-	"customer.status": {
-		source:     "port:customerRepo"
-		required:   true
-		on_missing: "deny"
-	}
+	// Never decide on a balance more than 15 minutes old.
 	"invoice.balance": {
-		source:     "port:invoiceRepo"
-		required:   true
-		on_missing: "system_error"
+		source:        "port:invoiceRepo"
+		required:      true
+		on_missing:    "system_error"
+		max_staleness: "15m"
 	}
 	"invoice.status": {
 		source:     "port:invoiceRepo"
@@ -34,9 +29,26 @@ facts: {
 		required:   true
 		on_missing: "deny"
 	}
+	// Feature flag: route large payments to manual review. An unknown or
+	// unreachable flag counts as off.
+	"flags.payment_review_v2": {
+		source:     "port:flags"
+		required:   false
+		on_missing: "skip"
+	}
 }
 
 derived_facts: {
+	// The large-payment threshold is in US dollars.
+	"payment.amount_usd": {
+		derivation: {
+			fn: "convert"
+			args: [
+				{fact: "payment.amount"},
+				{value: "USD"},
+			]
+		}
+	}
 	"payment.exceeds_balance": {
 		derivation: {
 			fn: "greater_than"
@@ -19,3 +19,10 @@ flows: [
 		]
 	},
 ]
+
+personas: {
+	"customer": {
+		description: "Pays their own invoices"
+		can:         ["GetInvoice", "ProcessPayment"]
+	}
+}
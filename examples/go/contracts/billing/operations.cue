@@ -7,14 +7,34 @@ operations: {
 			"insufficient-funds",
 			"processor-down",
 			"large-payment-flag",
+			"large-payment-review",
 		]
 		transitions: [
 			{entity: "invoice", from: "approved", to: "paid"},
 		]
+		// The payment processor takes a handful of charges at a time; more
+		// wait their turn, and beyond the queue callers are told to retry.
+		concurrency: {
+			max:   5
+			queue: 20
+		}
+		// The payment must be in the invoice's currency, so it can be
+		// compared with the balance.
+		currencies: {
+			accepted: ["USD", "EUR", "GBP"]
+			facts: ["payment.amount", "invoice.balance"]
+			match: true
+		}
 	}
 
 	"GetInvoice": {
 		constrained_by: []
 		transitions:    []
+		// A pure read: repeat lookups within the TTL are served from the
+		// executor's cache without re-evaluating rules.
+		cache: {
+			ttl: "30s"
+			key: ["invoice.id", "customer.id"]
+		}
 	}
 }
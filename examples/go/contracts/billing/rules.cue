@@ -1,6 +1,14 @@
 // Billing domain business rules.
 // Rules produce verdicts; absence of a deny/escalate/require is permission.
 
+// Tunable values, bound per environment from bindings/<env>.json.
+params: {
+	large_payment_threshold: {
+		default:     10000
+		description: "Payments above this amount (in invoice currency) are flagged for review"
+	}
+}
+
 rules: [
 	{
 		id:         "no-payments-closed-accounts"
@@ -24,6 +32,11 @@ rules: [
 				suggestion:  "Contact support to reactivate account"
 			}
 		}
+		verdict: metadata: {
+			owner:     "billing-platform"
+			doc_url:   "https://docs.example.com/billing/account-closed"
+			risk_tier: "low"
+		}
 	},
 
 	{
@@ -80,13 +93,30 @@ rules: [
 
 		when: {
 			all: [
-				{fact: "payment.amount.value", greater_than: 10000},
+				{fact: "payment.amount_usd.value", greater_than: {param: "large_payment_threshold"}},
 			]
 		}
 
 		verdict: flag: {
 			code:   "LARGE_PAYMENT"
-			reason: "Payment amount over 10,000 — flagged for review"
+			reason: "Payment amount over the large-payment threshold — flagged for review"
+		}
+	},
+
+	{
+		id:         "large-payment-review"
+		applies_to: ["ProcessPayment"]
+
+		when: {
+			all: [
+				{fact: "flags.payment_review_v2", equals: true},
+				{fact: "payment.amount_usd.value", greater_than: {param: "large_payment_threshold"}},
+			]
+		}
+
+		verdict: escalate: {
+			queue:  "payment-review"
+			reason: "Large payments require manual review while payment_review_v2 is rolled out"
 		}
 	},
 ]
@@ -0,0 +1,39 @@
+// Transport bindings for the billing contract: how the covenanthttp
+// middleware and covenantgrpc interceptors find each operation's input facts.
+// Kept out of the domain directory, which is compiled as the contract itself.
+
+http: {
+	"POST /invoices/{id}/pay": {
+		operation: "ProcessPayment"
+		input: {
+			"invoice.id":     {path: "id"}
+			"customer.id":    {header: "X-Customer-ID"}
+			"payment.amount": {body: "/amount"}
+		}
+	}
+	"GET /invoices/{id}": {
+		operation: "GetInvoice"
+		input: {
+			"invoice.id":  {path: "id"}
+			"customer.id": {header: "X-Customer-ID"}
+		}
+	}
+}
+
+grpc: {
+	"/billing.v1.Billing/ProcessPayment": {
+		operation: "ProcessPayment"
+		input: {
+			"invoice.id":     {field: "invoice_id"}
+			"customer.id":    {field: "customer_id"}
+			"payment.amount": {field: "amount"}
+		}
+	}
+	"/billing.v1.Billing/GetInvoice": {
+		operation: "GetInvoice"
+		input: {
+			"invoice.id":  {field: "invoice_id"}
+			"customer.id": {field: "customer_id"}
+		}
+	}
+}
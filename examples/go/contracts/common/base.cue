@@ -0,0 +1,42 @@
+// Shared base contract — facts and global rules every domain inherits.
+// Domains opt in with `imports: ["common"]`; see composeContract in the
+// executor engine for override/merge semantics.
+
+facts: {
+	"customer.id": {
+		source:   "input"
+		required: true
+	}
+	"customer.status": {
+		source:     "port:customerRepo"
+		required:   true
+		on_missing: "deny"
+	}
+}
+
+rules: [
+	{
+		id:         "no-suspended-accounts"
+		applies_to: ["*"]
+		final:      true
+
+		when: {
+			all: [
+				{fact: "customer.status", equals: "suspended"},
+			]
+		}
+
+		verdict: deny: {
+			code:   "ACCOUNT_SUSPENDED"
+			reason: "Suspended accounts cannot perform any operation"
+			error: {
+				code:        "ACCOUNT_SUSPENDED"
+				message:     "Account is suspended"
+				http_status: 403
+				category:    "business_rule_violation"
+				retryable:   false
+				suggestion:  "Contact support to lift the suspension"
+			}
+		}
+	},
+]
@@ -0,0 +1,170 @@
+// Package binding reads transport binding specs: CUE files that declare how
+// the covenanthttp middleware, covenantgrpc interceptors and
+// covenantadmission webhook map routes, methods and Kubernetes resources to
+// contract operations and extract their input facts.
+//
+//	http: "POST /invoices/{id}/pay": {
+//		operation: "ProcessPayment"
+//		input: {
+//			"invoice.id":     {path: "id"}
+//			"customer.id":    {header: "X-Customer-ID"}
+//			"payment.amount": {body: "/amount"}
+//		}
+//	}
+//	grpc: "/billing.v1.Billing/ProcessPayment": {
+//		operation: "ProcessPayment"
+//		input: {
+//			"invoice.id":     {field: "invoice_id"}
+//			"payment.amount": {field: "amount"}
+//		}
+//	}
+//	admission: "CREATE apps/deployments": {
+//		operation: "CreateDeployment"
+//		input: {
+//			"deployment.replicas": {object: "/spec/replicas"}
+//			"user.name":           {request: "/userInfo/username"}
+//		}
+//	}
+//
+// An HTTP source is exactly one of path (a pattern wildcard), query, header
+// or body (an RFC 6901 JSON pointer, "" for the whole body); path, query and
+// header take an optional type of "string", "number" or "bool". A gRPC source
+// is a field path into the request message. An admission rule is keyed by
+// a Kubernetes operation (CREATE, UPDATE, DELETE or CONNECT) and a
+// <group>/<resource>[/<subresource>], with "core" for the core group; its
+// sources are JSON pointers into the object, the old object, or the
+// AdmissionRequest itself. Validate checks a spec against the contract it
+// will enforce.
+package binding
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+)
+
+// schema constrains a spec; each source matches exactly one disjunct.
+const schema = `
+#Type: "string" | "number" | "bool"
+
+#HTTPSource: {path: string, type?: #Type} | {query: string, type?: #Type} | {header: string, type?: #Type} | {body: string}
+#GRPCSource: {field: string}
+#AdmissionSource: {object: string} | {old_object: string} | {request: string}
+
+#Spec: {
+	http?: [string]: {
+		operation: string
+		input: [string]: #HTTPSource
+	}
+	grpc?: [string]: {
+		operation: string
+		input: [string]: #GRPCSource
+	}
+	admission?: [string]: {
+		operation: string
+		input: [string]: #AdmissionSource
+	}
+}
+`
+
+// Spec is a parsed binding spec.
+type Spec struct {
+	// HTTP maps net/http ServeMux patterns to routes.
+	HTTP map[string]Route `json:"http,omitempty"`
+	// GRPC maps full gRPC method names to routes.
+	GRPC map[string]Route `json:"grpc,omitempty"`
+	// Admission maps Kubernetes operations on resources, such as
+	// "CREATE apps/deployments", to routes.
+	Admission map[string]Route `json:"admission,omitempty"`
+}
+
+// Route maps a route or method to a contract operation.
+type Route struct {
+	Operation string            `json:"operation"`
+	Input     map[string]Source `json:"input"`
+}
+
+// Source locates one input fact. Exactly one of the location fields is set.
+type Source struct {
+	Path   string  `json:"path,omitempty"`
+	Query  string  `json:"query,omitempty"`
+	Header string  `json:"header,omitempty"`
+	Body   *string `json:"body,omitempty"`
+	Field  string  `json:"field,omitempty"`
+	Type   string  `json:"type,omitempty"`
+
+	Object    *string `json:"object,omitempty"`
+	OldObject *string `json:"old_object,omitempty"`
+	Request   *string `json:"request,omitempty"`
+}
+
+// Load reads and parses the spec file at path.
+func Load(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	spec, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return spec, nil
+}
+
+// Parse compiles a CUE spec and checks it against the schema.
+func Parse(data []byte) (*Spec, error) {
+	ctx := cuecontext.New()
+	def := ctx.CompileString(schema).LookupPath(cue.ParsePath("#Spec"))
+	v := ctx.CompileBytes(data)
+	if v.Err() != nil {
+		return nil, fmt.Errorf("compile: %w", v.Err())
+	}
+	v = def.Unify(v)
+	if err := v.Validate(cue.Concrete(true)); err != nil {
+		return nil, fmt.Errorf("invalid binding spec: %w", err)
+	}
+	var spec Spec
+	if err := v.Decode(&spec); err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+	return &spec, nil
+}
+
+// AdmissionTarget is the Kubernetes operation on a resource that an
+// admission rule applies to.
+type AdmissionTarget struct {
+	Operation   string // CREATE, UPDATE, DELETE or CONNECT
+	Group       string // "" for the core group
+	Resource    string // plural, e.g. deployments
+	Subresource string // e.g. scale or exec; empty for the resource itself
+}
+
+// ParseAdmissionKey parses an admission rule key such as
+// "CREATE apps/deployments" or "CONNECT core/pods/exec".
+func ParseAdmissionKey(key string) (AdmissionTarget, error) {
+	op, path, ok := strings.Cut(key, " ")
+	if !ok {
+		return AdmissionTarget{}, fmt.Errorf("%q: expected <operation> <group>/<resource>", key)
+	}
+	switch op {
+	case "CREATE", "UPDATE", "DELETE", "CONNECT":
+	default:
+		return AdmissionTarget{}, fmt.Errorf("%q: operation %q is not CREATE, UPDATE, DELETE or CONNECT", key, op)
+	}
+	parts := strings.Split(path, "/")
+	if len(parts) < 2 || len(parts) > 3 || slices.Contains(parts, "") {
+		return AdmissionTarget{}, fmt.Errorf("%q: expected <group>/<resource>[/<subresource>], with core for the core group", key)
+	}
+	t := AdmissionTarget{Operation: op, Group: parts[0], Resource: parts[1]}
+	if t.Group == "core" {
+		t.Group = ""
+	}
+	if len(parts) == 3 {
+		t.Subresource = parts[2]
+	}
+	return t, nil
+}
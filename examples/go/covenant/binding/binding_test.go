@@ -0,0 +1,136 @@
+package binding
+
+import (
+	"strings"
+	"testing"
+
+	"covenant-poc/executor/engine"
+)
+
+func billing(t *testing.T) *engine.Contract {
+	t.Helper()
+	c, err := engine.CompileDir("../../contracts/billing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestLoad_billingSpecIsValid(t *testing.T) {
+	spec, err := Load("../../contracts/billing/transport/bindings.cue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pay := spec.HTTP["POST /invoices/{id}/pay"]
+	if pay.Operation != "ProcessPayment" || pay.Input["invoice.id"].Path != "id" || *pay.Input["payment.amount"].Body != "/amount" {
+		t.Errorf("unexpected route %+v", pay)
+	}
+	if spec.GRPC["/billing.v1.Billing/GetInvoice"].Input["invoice.id"].Field != "invoice_id" {
+		t.Errorf("unexpected grpc methods %+v", spec.GRPC)
+	}
+	if diags := spec.Validate(billing(t)); len(diags) != 0 {
+		t.Errorf("expected no diagnostics, got %v", diags)
+	}
+}
+
+func TestParse_rejectsMalformedSources(t *testing.T) {
+	for name, src := range map[string]string{
+		"two locations":   `http: "GET /x": {operation: "Op", input: f: {path: "a", query: "b"}}`,
+		"unknown type":    `http: "GET /x": {operation: "Op", input: f: {query: "a", type: "date"}}`,
+		"typed body":      `http: "GET /x": {operation: "Op", input: f: {body: "/a", type: "number"}}`,
+		"http field":      `http: "GET /x": {operation: "Op", input: f: {field: "a"}}`,
+		"grpc header":     `grpc: "/s/M": {operation: "Op", input: f: {header: "a"}}`,
+		"admission query": `admission: "CREATE apps/deployments": {operation: "Op", input: f: {query: "a"}}`,
+		"no operation":    `http: "GET /x": {input: f: {path: "a"}}`,
+		"unknown section": `kafka: {}`,
+	} {
+		if _, err := Parse([]byte(src)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestValidate_checksAgainstContract(t *testing.T) {
+	spec, err := Parse([]byte(`
+http: {
+	"POST /pay": {
+		operation: "ProcessPayment"
+		input: {
+			"invoice.id":      {path: "id"}
+			"invoice.balance": {query: "balance", type: "number"}
+			"made.up":         {header: "X-Made-Up"}
+		}
+	}
+}
+grpc: "/billing.v1.Billing/Refund": {operation: "Refund", input: {}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	diags := spec.Validate(billing(t))
+
+	want := []string{
+		`error: http POST /pay: fact "invoice.balance" is sourced from port:invoiceRepo, not input`,
+		`error: http POST /pay: fact "made.up" is not declared by the contract`,
+		`warning: http POST /pay: required input fact "payment.amount" of ProcessPayment is not bound`,
+		`error: grpc /billing.v1.Billing/Refund: unknown operation "Refund"`,
+	}
+	var got []string
+	for _, d := range diags {
+		got = append(got, d.String())
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected diagnostics:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestParseAdmissionKey(t *testing.T) {
+	for key, want := range map[string]AdmissionTarget{
+		"CREATE apps/deployments": {Operation: "CREATE", Group: "apps", Resource: "deployments"},
+		"CONNECT core/pods/exec":  {Operation: "CONNECT", Resource: "pods", Subresource: "exec"},
+	} {
+		if got, err := ParseAdmissionKey(key); err != nil || got != want {
+			t.Errorf("%s: got %+v, %v", key, got, err)
+		}
+	}
+	for _, bad := range []string{"PATCH apps/deployments", "CREATE deployments", "CREATE apps/deployments/scale/x", "CREATE /pods", "CREATE"} {
+		if _, err := ParseAdmissionKey(bad); err == nil {
+			t.Errorf("%s: expected an error", bad)
+		}
+	}
+}
+
+func TestValidate_checksAdmissionKeys(t *testing.T) {
+	spec, err := Parse([]byte(`
+admission: {
+	"UPDATE core/invoices": {operation: "ProcessPayment", input: {"invoice.id": {object: "/metadata/name"}, "payment.amount": {object: "/spec/amount"}}}
+	"PATCH core/invoices": {operation: "ProcessPayment", input: {"invoice.id": {old_object: "/metadata/name"}, "payment.amount": {request: "/object/spec/amount"}}}
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, d := range spec.Validate(billing(t)) {
+		got = append(got, d.String())
+	}
+	want := `error: admission "PATCH core/invoices": operation "PATCH" is not CREATE, UPDATE, DELETE or CONNECT`
+	if strings.Join(got, "\n") != want {
+		t.Errorf("unexpected diagnostics:\n%s\nwant:\n%s", strings.Join(got, "\n"), want)
+	}
+}
+
+func TestPointer(t *testing.T) {
+	doc := map[string]any{"a/b": map[string]any{"c": []any{1.0, 2.0}}, "m~n": "x"}
+	cases := map[string]any{"/a~1b/c/1": 2.0, "/m~0n": "x"}
+	for ptr, want := range cases {
+		if got, ok := Pointer(doc, ptr); !ok || got != want {
+			t.Errorf("%s: expected %v, got %v, %v", ptr, want, got, ok)
+		}
+	}
+	for _, ptr := range []string{"/missing", "/a~1b/c/5", "/m~0n/deeper"} {
+		if _, ok := Pointer(doc, ptr); ok {
+			t.Errorf("%s: expected no value", ptr)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package binding
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Pointer resolves an RFC 6901 JSON pointer against a decoded document.
+func Pointer(doc any, ptr string) (any, bool) {
+	if ptr == "" {
+		return doc, doc != nil
+	}
+	for _, tok := range strings.Split(ptr[1:], "/") {
+		tok = strings.ReplaceAll(strings.ReplaceAll(tok, "~1", "/"), "~0", "~")
+		switch v := doc.(type) {
+		case map[string]any:
+			next, ok := v[tok]
+			if !ok {
+				return nil, false
+			}
+			doc = next
+		case []any:
+			i, err := strconv.Atoi(tok)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			doc = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return doc, true
+}
@@ -0,0 +1,67 @@
+package binding
+
+import (
+	"fmt"
+	"sort"
+
+	"covenant-poc/executor/engine"
+)
+
+// Validate checks the spec against the contract it will enforce. It is an
+// error for a route to name an unknown operation or to bind a fact the
+// contract does not declare as an input fact; a required input fact that
+// the operation's rules need but the route leaves unbound is a warning, as
+// the request will fail evaluation unless something else supplies it.
+func (s *Spec) Validate(c *engine.Contract) []engine.Diagnostic {
+	var diags []engine.Diagnostic
+	check := func(transport string, routes map[string]Route) {
+		for _, key := range sortedKeys(routes) {
+			route := routes[key]
+			where := fmt.Sprintf("%s %s", transport, key)
+			if _, ok := c.Operations[route.Operation]; !ok {
+				diags = append(diags, errorf("%s: unknown operation %q", where, route.Operation))
+				continue
+			}
+			for _, fact := range sortedKeys(route.Input) {
+				def, ok := c.Facts[fact]
+				switch {
+				case !ok:
+					diags = append(diags, errorf("%s: fact %q is not declared by the contract", where, fact))
+				case def.Source != "input":
+					diags = append(diags, errorf("%s: fact %q is sourced from %s, not input", where, fact, def.Source))
+				}
+			}
+			for _, fact := range engine.NeededFacts(c, route.Operation) {
+				def := c.Facts[fact]
+				if _, bound := route.Input[fact]; def.Source == "input" && def.Required && !bound {
+					diags = append(diags, engine.Diagnostic{
+						Severity: engine.SeverityWarning,
+						Message:  fmt.Sprintf("%s: required input fact %q of %s is not bound", where, fact, route.Operation),
+					})
+				}
+			}
+		}
+	}
+	check("http", s.HTTP)
+	check("grpc", s.GRPC)
+	check("admission", s.Admission)
+	for _, key := range sortedKeys(s.Admission) {
+		if _, err := ParseAdmissionKey(key); err != nil {
+			diags = append(diags, errorf("admission %v", err))
+		}
+	}
+	return diags
+}
+
+func errorf(format string, args ...any) engine.Diagnostic {
+	return engine.Diagnostic{Severity: engine.SeverityError, Message: fmt.Sprintf(format, args...)}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,81 @@
+package covenant
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"covenant-poc/executor/engine"
+)
+
+// Client evaluates requests against a contract. *Covenant does so
+// in-process and *Remote on an executor; services that take a Client can
+// be unit-tested with covenanttest.FakeExecutor.
+type Client interface {
+	Evaluate(ctx context.Context, req *Request) (*Response, error)
+}
+
+var (
+	_ Client = (*Covenant)(nil)
+	_ Client = (*Remote)(nil)
+)
+
+// Remote evaluates requests on an executor's POST /execute.
+type Remote struct {
+	URL    string       // executor base URL, such as http://localhost:26860
+	Client *http.Client // nil uses http.DefaultClient
+}
+
+// Evaluate implements Client. Denials and escalations are responses, as
+// they are in-process; an error means the request was not evaluated, or
+// the executor answered with an outcome this package does not know.
+//
+// Requests name the newest wire protocol version this package speaks,
+// unless req names another, and executors answer in the newest version
+// both sides speak.
+func (r *Remote) Evaluate(ctx context.Context, req *Request) (*Response, error) {
+	if req.ProtocolVersion == 0 {
+		cp := *req
+		cp.ProtocolVersion = engine.ProtocolVersion
+		req = &cp
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	hreq, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(r.URL, "/")+"/execute", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	hreq.Header.Set("Content-Type", "application/json")
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	hresp, err := client.Do(hreq)
+	if err != nil {
+		return nil, err
+	}
+	defer hresp.Body.Close()
+	if hresp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(hresp.Body, 4<<10))
+		return nil, fmt.Errorf("executor: HTTP %d: %s", hresp.StatusCode, bytes.TrimSpace(msg))
+	}
+	var resp Response
+	if err := json.NewDecoder(hresp.Body).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("executor: decode response: %w", err)
+	}
+	// An executor older than protocol version 2 answers in version 1, whose
+	// responses are version 2 responses without protocol_version.
+	if resp.ProtocolVersion > req.ProtocolVersion {
+		return nil, fmt.Errorf("executor: answered in protocol version %d, newer than %d", resp.ProtocolVersion, req.ProtocolVersion)
+	}
+	if !resp.Outcome.Valid() {
+		return nil, fmt.Errorf("executor: unknown outcome %q", resp.Outcome)
+	}
+	return &resp, nil
+}
@@ -0,0 +1,333 @@
+// Package codegen generates client SDKs from a contract, so that frontends,
+// agents and scripts call its operations with typed inputs and handle its
+// denials by code without hand-writing either.
+//
+// Describe reduces a contract to what a client sees of it: each
+// operation's input facts, typed as the contract's conditions use them,
+// and the verdicts its rules can return. The generators render that
+// description in a client language.
+package codegen
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"unicode"
+
+	"covenant-poc/executor/engine"
+)
+
+// Type is the JSON type of an input fact or field.
+type Type string
+
+// Types. TypeUnknown is used when the contract never compares a fact with
+// anything that implies a type, or implies conflicting ones.
+const (
+	TypeUnknown Type = ""
+	TypeString  Type = "string"
+	TypeNumber  Type = "number"
+	TypeBool    Type = "boolean"
+	TypeObject  Type = "object"
+	TypeArray   Type = "array"
+)
+
+// API is a contract as its clients see it.
+type API struct {
+	Version    string      // contract semver, if it has one
+	Operations []Operation // sorted by name
+}
+
+// Operation is one operation's input and the verdicts its rules can
+// return.
+type Operation struct {
+	Name string
+	// Input lists every input fact of the contract, sorted. A required
+	// fact is required here only if the operation's rules, the entities it
+	// transitions or its cache key read it; the rest are optional.
+	Input []Field
+	// Verdicts are those of the rules constraining the operation, in
+	// contract order.
+	Verdicts []Verdict
+}
+
+// Field is an input fact, or a field of an object fact.
+type Field struct {
+	Name     string // the fact, or the field's key within its object
+	Type     Type
+	Required bool
+	Fields   []Field // of an object, sorted by name
+}
+
+// Verdict is the verdict one rule returns.
+type Verdict struct {
+	Rule string
+	Type string // deny, escalate, require or flag
+	// Code is a deny or flag verdict's code.
+	Code   string
+	Reason string
+	Error  *engine.ErrorEnvelope // of a deny verdict
+	Queue  string                // of an escalate verdict, unless a param picks it
+	// Metadata is the verdict's declared metadata.
+	Metadata map[string]any
+}
+
+// Denials returns the errors op's deny verdicts answer with, one per code,
+// in contract order.
+func (op *Operation) Denials() []engine.ErrorEnvelope {
+	var out []engine.ErrorEnvelope
+	seen := map[string]bool{}
+	for _, v := range op.Verdicts {
+		if v.Error != nil && !seen[v.Error.Code] {
+			seen[v.Error.Code] = true
+			out = append(out, *v.Error)
+		}
+	}
+	return out
+}
+
+// ExecutorDenials are the error codes the executor itself denies requests
+// with, whatever the contract: failed authorization, facts that are
+// missing or stale with on_missing or on_stale "deny", a purpose the
+// contract's data_use does not permit, and a rejected escalation.
+var ExecutorDenials = []string{"FORBIDDEN", "FACT_UNAVAILABLE", "FACT_STALE", "PURPOSE_NOT_PERMITTED", "ESCALATION_DENIED"}
+
+// Describe returns the API of c.
+func Describe(c *engine.Contract) *API {
+	types := inferTypes(c)
+	api := &API{Version: c.Version}
+	for _, name := range sortedKeys(c.Operations) {
+		api.Operations = append(api.Operations, describeOperation(c, name, types))
+	}
+	return api
+}
+
+func describeOperation(c *engine.Contract, name string, types map[string]Type) Operation {
+	def := c.Operations[name]
+	reads := map[string]bool{}
+	for _, f := range engine.NeededFacts(c, name) {
+		reads[f] = true
+	}
+	for _, t := range def.Transitions {
+		if key := c.Entities[t.Entity].Key; key != "" {
+			reads[key] = true
+		}
+	}
+	if def.Cache != nil {
+		for _, f := range def.Cache.Key {
+			reads[f] = true
+		}
+	}
+
+	op := Operation{Name: name}
+	for _, fact := range sortedKeys(c.Facts) {
+		if fd := c.Facts[fact]; fd.Source == "input" {
+			field := buildField(c, fact, fact, types)
+			field.Required = fd.Required && reads[fact]
+			op.Input = append(op.Input, field)
+		}
+	}
+
+	constrained := map[string]bool{}
+	for _, id := range def.ConstrainedBy {
+		constrained[id] = true
+	}
+	for _, r := range c.Rules {
+		if !constrained[r.ID] {
+			continue
+		}
+		v := Verdict{Rule: r.ID, Metadata: r.Verdict.Metadata}
+		switch d := r.Verdict; {
+		case d.Deny != nil:
+			e := d.Deny.Error
+			v.Type, v.Code, v.Reason, v.Error = "deny", d.Deny.Code, d.Deny.Reason, &e
+		case d.Escalate != nil:
+			v.Type, v.Reason = "escalate", d.Escalate.Reason
+			if d.Escalate.QueueParam == "" {
+				v.Queue = d.Escalate.Queue
+			}
+		case d.Require != nil:
+			v.Type, v.Reason = "require", d.Require.Reason
+		case d.Flag != nil:
+			v.Type, v.Code, v.Reason = "flag", d.Flag.Code, d.Flag.Reason
+		default:
+			continue
+		}
+		op.Verdicts = append(op.Verdicts, v)
+	}
+	return op
+}
+
+// buildField returns the field for path: an object of the paths below it
+// the contract reads, if there are any, or a value of its inferred type.
+func buildField(c *engine.Contract, name, path string, types map[string]Type) Field {
+	f := Field{Name: name, Type: types[path]}
+	children := map[string]bool{}
+	for p := range types {
+		if rest, ok := strings.CutPrefix(p, path+"."); ok && !declaredBelow(c, path, p) {
+			child, _, _ := strings.Cut(rest, ".")
+			children[child] = true
+		}
+	}
+	if len(children) == 0 {
+		return f
+	}
+	f.Type = TypeObject
+	for _, child := range sortedKeys(children) {
+		sub := buildField(c, child, path+"."+child, types)
+		// A field the contract compares with something is one it expects.
+		sub.Required = sub.Type != TypeUnknown
+		f.Fields = append(f.Fields, sub)
+	}
+	return f
+}
+
+// declaredBelow reports whether p is, or is below, a fact declared below
+// path, such as customer.status below a customer fact.
+func declaredBelow(c *engine.Contract, path, p string) bool {
+	for ; len(p) > len(path); p = p[:strings.LastIndex(p, ".")] {
+		if _, ok := c.Facts[p]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// inferTypes returns the type each fact path the contract reads is
+// compared as, in rules, authorize blocks and derivations.
+func inferTypes(c *engine.Contract) map[string]Type {
+	types := map[string]Type{}
+	conflicted := map[string]bool{}
+	observe := func(path string, t Type) {
+		if path == "" || conflicted[path] {
+			return
+		}
+		switch prev, ok := types[path]; {
+		case !ok || prev == TypeUnknown:
+			types[path] = t
+		case t != TypeUnknown && t != prev:
+			types[path], conflicted[path] = TypeUnknown, true
+		}
+	}
+	var visit func(engine.Condition)
+	visit = func(cond engine.Condition) {
+		if cond.Fact != "" {
+			switch {
+			case cond.GreaterThan != nil || cond.LessThan != nil:
+				observe(cond.Fact, TypeNumber)
+			case cond.Equals != nil:
+				observe(cond.Fact, typeOf(c.ResolveOperand(cond.Equals)))
+			case len(cond.In) > 0:
+				for _, v := range cond.In {
+					observe(cond.Fact, typeOf(c.ResolveOperand(v)))
+				}
+			case cond.Contains != nil || len(cond.SubsetOf) > 0 || len(cond.Intersects) > 0 || len(cond.ContainsAll) > 0:
+				observe(cond.Fact, TypeArray)
+			case cond.Before != nil || cond.After != nil || len(cond.Between) > 0:
+				observe(cond.Fact, TypeString)
+			default:
+				observe(cond.Fact, TypeUnknown)
+			}
+		}
+		for _, sub := range cond.All {
+			visit(sub)
+		}
+		for _, sub := range cond.Any {
+			visit(sub)
+		}
+		if cond.Not != nil {
+			visit(*cond.Not)
+		}
+	}
+	for _, r := range c.Rules {
+		visit(r.When)
+	}
+	for _, name := range sortedKeys(c.Operations) {
+		if auth := c.Operations[name].Authorize; auth != nil {
+			visit(auth.Requires)
+		}
+	}
+	for _, name := range sortedKeys(c.DerivedFacts) {
+		d := c.DerivedFacts[name].Derivation
+		for i, arg := range d.Args {
+			if arg.Fact == "" {
+				continue
+			}
+			switch {
+			case arg.Op == "greater_than" || arg.Op == "less_than":
+				observe(arg.Fact, TypeNumber)
+			case arg.Op == "equals":
+				observe(arg.Fact, typeOf(arg.Value))
+			case d.Fn == "greater_than" || d.Fn == "greater_or_equal" || d.Fn == "less_than":
+				observe(arg.Fact, TypeNumber)
+			case d.Fn == "equals" && len(d.Args) == 2:
+				observe(arg.Fact, typeOf(d.Args[1-i].Value))
+			case d.Fn == "and" || d.Fn == "or" || d.Fn == "not":
+				observe(arg.Fact, TypeBool)
+			default:
+				observe(arg.Fact, TypeUnknown)
+			}
+		}
+	}
+	// Derived facts are computed, not sent.
+	for name := range c.DerivedFacts {
+		delete(types, name)
+	}
+	return types
+}
+
+func typeOf(v any) Type {
+	switch v.(type) {
+	case string:
+		return TypeString
+	case float64, float32, int, int64, int32:
+		return TypeNumber
+	case bool:
+		return TypeBool
+	case map[string]any:
+		return TypeObject
+	case []any:
+		return TypeArray
+	}
+	return TypeUnknown
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ident returns name as an identifier in the generated code: letters,
+// digits and underscores, starting with an upper-case letter.
+func ident(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	id := b.String()
+	if id == "" || !unicode.IsLetter(rune(id[0])) {
+		id = "Op" + id
+	}
+	return strings.ToUpper(id[:1]) + id[1:]
+}
+
+// quote returns s as a string literal, which JSON's are in both
+// TypeScript and Python.
+func quote(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func versionSuffix(api *API) string {
+	if api.Version == "" {
+		return ""
+	}
+	return " version " + api.Version
+}
@@ -0,0 +1,171 @@
+package codegen
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"covenant-poc/executor/engine"
+)
+
+func billing(t *testing.T) *API {
+	t.Helper()
+	c, err := engine.CompileDir("../../contracts/billing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Describe(c)
+}
+
+func operation(t *testing.T, api *API, name string) Operation {
+	t.Helper()
+	for _, op := range api.Operations {
+		if op.Name == name {
+			return op
+		}
+	}
+	t.Fatalf("no operation %s in %+v", name, api.Operations)
+	return Operation{}
+}
+
+func TestDescribe_billing(t *testing.T) {
+	api := billing(t)
+	pay := operation(t, api, "ProcessPayment")
+
+	want := []Field{
+		{Name: "customer.id"},
+		{Name: "invoice.id", Required: true},
+		{Name: "payment.amount", Type: TypeObject, Required: true, Fields: []Field{{Name: "value", Type: TypeNumber, Required: true}}},
+	}
+	if !reflect.DeepEqual(pay.Input, want) {
+		t.Errorf("got input %+v", pay.Input)
+	}
+	var codes []string
+	for _, e := range pay.Denials() {
+		codes = append(codes, e.Code)
+	}
+	if !reflect.DeepEqual(codes, []string{"ACCOUNT_SUSPENDED", "ACCOUNT_CLOSED", "INSUFFICIENT_FUNDS", "PROCESSOR_UNAVAILABLE"}) {
+		t.Errorf("got denials %v", codes)
+	}
+	if v := pay.Verdicts[len(pay.Verdicts)-1]; v.Rule != "large-payment-review" || v.Type != "escalate" || v.Queue != "payment-review" {
+		t.Errorf("got verdict %+v", v)
+	}
+
+	// GetInvoice's cache key reads customer.id; it never reads an amount.
+	get := operation(t, api, "GetInvoice")
+	if !get.Input[0].Required || get.Input[2].Required {
+		t.Errorf("got input %+v", get.Input)
+	}
+}
+
+func TestDescribe_infersTypesFromComparisons(t *testing.T) {
+	c := &engine.Contract{
+		Params: map[string]engine.ParamDef{"tier": {Default: "gold"}},
+		Facts: map[string]engine.FactDef{
+			"order":        {Source: "input", Required: true},
+			"order.status": {Source: "port:orders"},
+			"coupon":       {Source: "input"},
+			"tags":         {Source: "input"},
+			"vip":          {Source: "input"},
+			"mixed":        {Source: "input"},
+		},
+		DerivedFacts: map[string]engine.DerivedFactDef{
+			"big": {Derivation: engine.Derivation{Fn: "greater_than", Args: []engine.DerivationArg{{Fact: "order.total"}, {Value: 100.0}}}},
+			"ok":  {Derivation: engine.Derivation{Fn: "and", Args: []engine.DerivationArg{{Fact: "vip"}}}},
+		},
+		Rules: []engine.RuleDef{{
+			ID: "r",
+			When: engine.Condition{All: []engine.Condition{
+				{Fact: "coupon", Equals: map[string]any{"param": "tier"}},
+				{Fact: "tags", Contains: "x"},
+				{Fact: "order.status", Equals: "open"},
+				{Fact: "mixed", In: []any{"a", 1.0}},
+				{Fact: "big", Equals: true},
+			}},
+			Verdict: engine.VerdictDef{Flag: &engine.FlagVerdict{Code: "F"}},
+		}},
+		Operations: map[string]engine.OperationDef{"Buy": {ConstrainedBy: []string{"r"}}},
+	}
+	op := Describe(c).Operations[0]
+	got := map[string]Field{}
+	for _, f := range op.Input {
+		got[f.Name] = f
+	}
+	if got["coupon"].Type != TypeString || got["tags"].Type != TypeArray || got["vip"].Type != TypeBool || got["mixed"].Type != TypeUnknown {
+		t.Errorf("got input %+v", op.Input)
+	}
+	// order.status is a port fact of its own, not a field of the order.
+	if want := []Field{{Name: "total", Type: TypeNumber, Required: true}}; !reflect.DeepEqual(got["order"].Fields, want) || !got["order"].Required {
+		t.Errorf("got order %+v", got["order"])
+	}
+	if _, ok := got["big"]; ok {
+		t.Error("expected derived facts left out of the input")
+	}
+}
+
+func TestTypeScript_billing(t *testing.T) {
+	var b bytes.Buffer
+	if err := TypeScript(&b, billing(t)); err != nil {
+		t.Fatal(err)
+	}
+	ts := b.String()
+	for _, want := range []string{
+		"// Code generated by covenant codegen from contract version 1.0.0. DO NOT EDIT.",
+		"export const PROTOCOL_VERSION = 2;",
+		"export interface ProcessPaymentInput {\n  \"customer.id\"?: unknown;\n  \"invoice.id\": unknown;\n  \"payment.amount\": {\n    \"value\": number;\n    [field: string]: unknown;\n  };\n}",
+		`| (ErrorEnvelope & { code: "INSUFFICIENT_FUNDS"; http_status: 402; retryable: true })`,
+		`| { rule: "large-payment-flag"; type: "flag"; code: "LARGE_PAYMENT"; reason?: string }`,
+		`| { rule: "no-payments-closed-accounts"; type: "deny"; code: "ACCOUNT_CLOSED"; error: ErrorEnvelope & { code: "ACCOUNT_CLOSED" }; metadata: Record<string, unknown>; reason?: string }`,
+		"export type ProcessPaymentResponse = CovenantResponse<ProcessPaymentVerdict, ProcessPaymentDenial>;",
+		"  processPayment(input: ProcessPaymentInput, opts?: RequestOptions): Promise<ProcessPaymentResponse> {",
+		"  dryRunGetInvoice(input: GetInvoiceInput, opts?: RequestOptions): Promise<GetInvoiceResponse> {",
+		`    return this.evaluate<GetInvoiceResponse>("GetInvoice", input, true, opts);`,
+	} {
+		if !strings.Contains(ts, want) {
+			t.Errorf("expected the client to contain\n%s", want)
+		}
+	}
+}
+
+func TestIdent(t *testing.T) {
+	for in, want := range map[string]string{"ProcessPayment": "ProcessPayment", "refund-order": "Refund_order", "2fa.Verify": "Op2fa_Verify"} {
+		if got := ident(in); got != want {
+			t.Errorf("ident(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestPython_billing(t *testing.T) {
+	var b bytes.Buffer
+	if err := Python(&b, billing(t)); err != nil {
+		t.Fatal(err)
+	}
+	py := b.String()
+	for _, want := range []string{
+		"# Code generated by covenant codegen from contract version 1.0.0. DO NOT EDIT.",
+		"PROTOCOL_VERSION = 2",
+		"class InsufficientFundsError(Denied):\n    \"\"\"INSUFFICIENT_FUNDS: Payment amount exceeds invoice balance\"\"\"\n\n    code = \"INSUFFICIENT_FUNDS\"\n    http_status = 402\n    retryable = True\n",
+		"class FactUnavailableError(Denied):",
+		`    "ACCOUNT_CLOSED": AccountClosedError,`,
+		"class ProcessPaymentPaymentAmount(BaseModel):",
+		"    customer_id: Any = Field(default=None, alias=\"customer.id\")\n    invoice_id: Any = Field(alias=\"invoice.id\")\n    payment_amount: ProcessPaymentPaymentAmount = Field(alias=\"payment.amount\")\n",
+		"    def process_payment(self, input: ProcessPaymentInput, *, raise_on_deny: bool = True, **opts: Any) -> Response:",
+		"    def dry_run_get_invoice(self, input: GetInvoiceInput, **opts: Any) -> Response:",
+	} {
+		if !strings.Contains(py, want) {
+			t.Errorf("expected the client to contain\n%s", want)
+		}
+	}
+}
+
+func TestPythonNames(t *testing.T) {
+	for in, want := range map[string]string{"ProcessPayment": "process_payment", "customer.id": "customer_id", "HTTPCheck": "http_check", "class": "class_", "2fa": "f_2fa"} {
+		if got := snake(in); got != want {
+			t.Errorf("snake(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if got := pyException("PURPOSE_NOT_PERMITTED"); got != "PurposeNotPermittedError" {
+		t.Errorf("got %s", got)
+	}
+}
@@ -0,0 +1,336 @@
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"covenant-poc/executor/engine"
+)
+
+// Python writes a Python client for api to w. Each operation gets a
+// pydantic model of its input, and each denial code an exception class
+// derived from Denied. CovenantClient has a method and a dry-run method per
+// operation that evaluate it on an executor's POST /execute with urllib,
+// raising the exception for a denial's code unless asked not to. Its only
+// dependency is pydantic 2.
+func Python(w io.Writer, api *API) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# Code generated by covenant codegen from contract%s. DO NOT EDIT.\n", versionSuffix(api))
+	b.WriteString(pyPreamble)
+	fmt.Fprintf(&b, "\nPROTOCOL_VERSION = %d\n\nOutcome = Literal[", engine.ProtocolVersion)
+	for i, o := range engine.Outcomes {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, "\n    %s", quote(string(o)))
+	}
+	b.WriteString(",\n]\n")
+	b.WriteString(pyModels)
+
+	// One exception per denial code, the executor's own first.
+	codes := []string{}
+	denials := map[string]*engine.ErrorEnvelope{}
+	for _, code := range ExecutorDenials {
+		codes = append(codes, code)
+		denials[code] = nil
+	}
+	for _, op := range api.Operations {
+		for _, e := range op.Denials() {
+			if _, ok := denials[e.Code]; !ok {
+				codes = append(codes, e.Code)
+				denials[e.Code] = &e
+			}
+		}
+	}
+	for _, code := range codes {
+		fmt.Fprintf(&b, "\n\nclass %s(Denied):\n", pyException(code))
+		if e := denials[code]; e != nil {
+			doc := code
+			if e.Message != "" {
+				doc += ": " + e.Message
+			}
+			fmt.Fprintf(&b, "    %s\n\n    code = %s\n    http_status = %d\n    retryable = %s\n", pyDoc(doc), quote(code), e.HttpStatus, pyBool(e.Retryable))
+			continue
+		}
+		fmt.Fprintf(&b, "    %s\n\n    code = %s\n", pyDoc(code+", from the executor itself."), quote(code))
+	}
+	b.WriteString("\n\nDENIALS: dict[str, type[Denied]] = {\n")
+	for _, code := range codes {
+		fmt.Fprintf(&b, "    %s: %s,\n", quote(code), pyException(code))
+	}
+	b.WriteString("}\n")
+
+	for _, op := range api.Operations {
+		pyModel(&b, ident(op.Name), "Input", "Input facts of "+op.Name+".", op.Input)
+	}
+
+	b.WriteString(pyClientHead)
+	for _, op := range api.Operations {
+		method := snake(op.Name)
+		fmt.Fprintf(&b, `
+    def %[1]s(self, input: %[2]sInput, *, raise_on_deny: bool = True, **opts: Any) -> Response:
+        """Evaluates %[3]s, raising the Denied subclass for its code if denied."""
+        return self._evaluate(%[4]s, input, False, raise_on_deny, opts)
+
+    def dry_run_%[1]s(self, input: %[2]sInput, **opts: Any) -> Response:
+        """Evaluates %[3]s's rules as a dry-run, without side effects."""
+        return self._evaluate(%[4]s, input, True, False, opts)
+`, method, ident(op.Name), op.Name, quote(op.Name))
+	}
+	b.WriteString(pyClientTail)
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// pyModel writes a pydantic model of fields named prefix+name, preceded by
+// the models of its object fields, named prefix plus the field.
+func pyModel(b *bytes.Buffer, prefix, name, doc string, fields []Field) {
+	for _, f := range fields {
+		if f.Type == TypeObject && len(f.Fields) > 0 {
+			pyModel(b, prefix, pascal(f.Name), "The "+f.Name+" field of "+prefix+name+".", f.Fields)
+		}
+	}
+	fmt.Fprintf(b, "\n\nclass %s(BaseModel):\n    %s\n\n", prefix+name, pyDoc(doc))
+	b.WriteString("    model_config = ConfigDict(extra=\"allow\", populate_by_name=True)\n")
+	if len(fields) > 0 {
+		b.WriteString("\n")
+	}
+	for _, f := range fields {
+		typ := pyType(f.Type)
+		if f.Type == TypeObject && len(f.Fields) > 0 {
+			typ = prefix + pascal(f.Name)
+		}
+		attr := snake(f.Name)
+		var args []string
+		if !f.Required {
+			if typ != "Any" {
+				typ = "Optional[" + typ + "]"
+			}
+			args = append(args, "default=None")
+		}
+		if attr != f.Name {
+			args = append(args, "alias="+quote(f.Name))
+		}
+		if len(args) == 0 {
+			fmt.Fprintf(b, "    %s: %s\n", attr, typ)
+			continue
+		}
+		fmt.Fprintf(b, "    %s: %s = Field(%s)\n", attr, typ, strings.Join(args, ", "))
+	}
+}
+
+func pyType(t Type) string {
+	switch t {
+	case TypeString:
+		return "str"
+	case TypeNumber:
+		return "float"
+	case TypeBool:
+		return "bool"
+	case TypeObject:
+		return "dict[str, Any]"
+	case TypeArray:
+		return "list[Any]"
+	}
+	return "Any"
+}
+
+// pyException returns the exception class for a denial code, such as
+// InsufficientFundsError for INSUFFICIENT_FUNDS.
+func pyException(code string) string {
+	return pascal(strings.ToLower(code)) + "Error"
+}
+
+// pascal returns name as a class name: payment.amount as PaymentAmount.
+func pascal(name string) string {
+	var b strings.Builder
+	for _, word := range strings.FieldsFunc(name, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	return ident(b.String())
+}
+
+// snake returns name as a Python attribute or method name: ProcessPayment
+// as process_payment, customer.id as customer_id.
+func snake(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		switch {
+		case unicode.IsUpper(r):
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) ||
+				unicode.IsUpper(runes[i-1]) && i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	s := strings.Trim(b.String(), "_")
+	if s == "" || unicode.IsDigit(rune(s[0])) {
+		s = "f_" + s
+	}
+	if pyKeywords[s] {
+		s += "_"
+	}
+	return s
+}
+
+var pyKeywords = map[string]bool{
+	"and": true, "as": true, "assert": true, "async": true, "await": true, "break": true, "class": true,
+	"continue": true, "def": true, "del": true, "elif": true, "else": true, "except": true, "finally": true,
+	"for": true, "from": true, "global": true, "if": true, "import": true, "in": true, "is": true,
+	"lambda": true, "nonlocal": true, "not": true, "or": true, "pass": true, "raise": true, "return": true,
+	"try": true, "while": true, "with": true, "yield": true, "None": true, "True": true, "False": true,
+}
+
+func pyDoc(s string) string {
+	return `"""` + strings.ReplaceAll(s, `"""`, `\"\"\"`) + `"""`
+}
+
+func pyBool(v bool) string {
+	if v {
+		return "True"
+	}
+	return "False"
+}
+
+const pyPreamble = `
+from __future__ import annotations
+
+import json
+import urllib.error
+import urllib.request
+from typing import Any, Literal, Optional
+
+from pydantic import BaseModel, ConfigDict, Field
+`
+
+const pyModels = `
+
+class ErrorEnvelope(BaseModel):
+    model_config = ConfigDict(extra="allow")
+
+    code: str
+    message: str = ""
+    http_status: int = 0
+    category: str = ""
+    retryable: bool = False
+    suggestion: Optional[str] = None
+    details: Optional[dict[str, Any]] = None
+
+
+class Verdict(BaseModel):
+    model_config = ConfigDict(extra="allow")
+
+    rule: Optional[str] = None
+    type: str
+    code: Optional[str] = None
+    reason: Optional[str] = None
+    error: Optional[ErrorEnvelope] = None
+    queue: Optional[str] = None
+    metadata: Optional[dict[str, Any]] = None
+
+
+class Response(BaseModel):
+    """The response to an operation. Denials and escalations are responses."""
+
+    model_config = ConfigDict(extra="allow")
+
+    outcome: Outcome
+    invocation_id: Optional[str] = None
+    output: Optional[dict[str, Any]] = None
+    error: Optional[ErrorEnvelope] = None
+    verdicts: list[Verdict] = []
+    fact_snapshot: Optional[dict[str, Any]] = None
+    dry_run: bool = False
+    contract_semver: Optional[str] = None
+    escalation_id: Optional[str] = None
+    protocol_version: Optional[int] = None
+
+    def would_proceed(self) -> bool:
+        """Reports whether the operation ran, or for a dry-run would have run."""
+        return self.outcome in ("executed", "would_execute", "would_execute_with_flags")
+
+    def is_dry_run(self) -> bool:
+        """Reports whether this answers a dry-run."""
+        return self.outcome.startswith("would_")
+
+
+class CovenantError(Exception):
+    """The executor answered with an HTTP error instead of a response."""
+
+    def __init__(self, status: int, message: str) -> None:
+        super().__init__(f"executor: HTTP {status}: {message}")
+        self.status = status
+
+
+class Denied(Exception):
+    """An operation was denied. Each denial code has a subclass."""
+
+    code = ""
+    http_status = 0
+    retryable = False
+
+    def __init__(self, response: Response) -> None:
+        error = response.error or ErrorEnvelope(code=self.code)
+        super().__init__(f"{error.code}: {error.message}")
+        self.response = response
+        self.error = error
+`
+
+const pyClientHead = `
+
+class CovenantClient:
+    """Evaluates operations on an executor.
+
+    Options accepted by each operation method: idempotency_key, purpose,
+    priority ("interactive" or "batch") and explain.
+    """
+
+    def __init__(
+        self,
+        base_url: str,
+        *,
+        headers: Optional[dict[str, str]] = None,
+        contract_etag: Optional[str] = None,
+        timeout: float = 30.0,
+    ) -> None:
+        self.base_url = base_url.rstrip("/")
+        self.headers = headers or {}
+        self.contract_etag = contract_etag
+        self.timeout = timeout
+`
+
+const pyClientTail = `
+    def _evaluate(self, operation: str, input: BaseModel, dry_run: bool, raise_on_deny: bool, opts: dict[str, Any]) -> Response:
+        body = {
+            "operation": operation,
+            "input": input.model_dump(by_alias=True, exclude_none=True),
+            "dry_run": dry_run,
+            "contract_etag": self.contract_etag,
+            "protocol_version": PROTOCOL_VERSION,
+            **opts,
+        }
+        req = urllib.request.Request(
+            self.base_url + "/execute",
+            data=json.dumps({k: v for k, v in body.items() if v is not None}).encode(),
+            headers={"Content-Type": "application/json", **self.headers},
+            method="POST",
+        )
+        try:
+            with urllib.request.urlopen(req, timeout=self.timeout) as res:
+                resp = Response.model_validate_json(res.read())
+        except urllib.error.HTTPError as e:
+            raise CovenantError(e.code, e.read().decode(errors="replace").strip()) from None
+        if raise_on_deny and resp.outcome == "denied":
+            code = resp.error.code if resp.error else ""
+            raise DENIALS.get(code, Denied)(resp)
+        return resp
+`
@@ -0,0 +1,228 @@
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"covenant-poc/executor/engine"
+)
+
+// TypeScript writes a TypeScript client for api to w. For each operation it
+// declares an input interface, the operation's denials as a union
+// discriminated by error code, its verdicts as one discriminated by rule,
+// and a method and a dry-run method on CovenantClient, which evaluates them
+// on an executor's POST /execute with fetch.
+func TypeScript(w io.Writer, api *API) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by covenant codegen from contract%s. DO NOT EDIT.\n\n", versionSuffix(api))
+	fmt.Fprintf(&b, "/** Wire protocol version this client speaks. */\nexport const PROTOCOL_VERSION = %d;\n\n", engine.ProtocolVersion)
+	b.WriteString("export type Outcome =")
+	for _, o := range engine.Outcomes {
+		fmt.Fprintf(&b, "\n  | %s", quote(string(o)))
+	}
+	b.WriteString(";\n\n")
+	b.WriteString(tsPreamble)
+	fmt.Fprintf(&b, "\n/** Errors the executor itself may deny any operation with. */\nexport type ExecutorDenial = ErrorEnvelope & { code: %s };\n", tsUnion(ExecutorDenials))
+
+	for _, op := range api.Operations {
+		name := ident(op.Name)
+		fmt.Fprintf(&b, "\n// %s\n\n", op.Name)
+
+		fmt.Fprintf(&b, "/** Input facts of %s. */\nexport interface %sInput {\n", op.Name, name)
+		tsFields(&b, op.Input, "  ")
+		b.WriteString("}\n\n")
+
+		fmt.Fprintf(&b, "/** Errors %s is denied with, by code. */\nexport type %sDenial =", op.Name, name)
+		for _, e := range op.Denials() {
+			fmt.Fprintf(&b, "\n  | (ErrorEnvelope & { code: %s; http_status: %d; retryable: %t })", quote(e.Code), e.HttpStatus, e.Retryable)
+		}
+		b.WriteString("\n  | ExecutorDenial;\n\n")
+
+		fmt.Fprintf(&b, "/** Verdicts of the rules constraining %s, by rule. */\nexport type %sVerdict =", op.Name, name)
+		for _, v := range op.Verdicts {
+			fmt.Fprintf(&b, "\n  | { rule: %s; type: %s", quote(v.Rule), quote(v.Type))
+			switch v.Type {
+			case "deny":
+				fmt.Fprintf(&b, "; code: %s; error: ErrorEnvelope & { code: %s }", quote(v.Code), quote(v.Error.Code))
+			case "flag":
+				fmt.Fprintf(&b, "; code: %s", quote(v.Code))
+			case "escalate":
+				if v.Queue != "" {
+					fmt.Fprintf(&b, "; queue: %s", quote(v.Queue))
+				} else {
+					b.WriteString("; queue: string")
+				}
+			}
+			if len(v.Metadata) > 0 {
+				b.WriteString("; metadata: Record<string, unknown>")
+			}
+			b.WriteString("; reason?: string }")
+		}
+		if len(op.Verdicts) == 0 {
+			b.WriteString(" never")
+		}
+		b.WriteString(";\n\n")
+
+		fmt.Fprintf(&b, "export type %[1]sResponse = CovenantResponse<%[1]sVerdict, %[1]sDenial>;\n", name)
+	}
+
+	b.WriteString(tsClientHead)
+	for _, op := range api.Operations {
+		name := ident(op.Name)
+		method := strings.ToLower(name[:1]) + name[1:]
+		fmt.Fprintf(&b, `
+  /** Evaluates %[1]s. */
+  %[3]s(input: %[2]sInput, opts?: RequestOptions): Promise<%[2]sResponse> {
+    return this.evaluate<%[2]sResponse>(%[4]s, input, false, opts);
+  }
+
+  /** Evaluates %[1]s's rules as a dry-run, without side effects. */
+  dryRun%[2]s(input: %[2]sInput, opts?: RequestOptions): Promise<%[2]sResponse> {
+    return this.evaluate<%[2]sResponse>(%[4]s, input, true, opts);
+  }
+`, op.Name, name, method, quote(op.Name))
+	}
+	b.WriteString(tsClientTail)
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+func tsFields(b *bytes.Buffer, fields []Field, indent string) {
+	for _, f := range fields {
+		opt := "?"
+		if f.Required {
+			opt = ""
+		}
+		fmt.Fprintf(b, "%s%s%s: ", indent, quote(f.Name), opt)
+		if f.Type == TypeObject && len(f.Fields) > 0 {
+			b.WriteString("{\n")
+			tsFields(b, f.Fields, indent+"  ")
+			fmt.Fprintf(b, "%s  [field: string]: unknown;\n%s};\n", indent, indent)
+			continue
+		}
+		fmt.Fprintf(b, "%s;\n", tsType(f.Type))
+	}
+}
+
+func tsType(t Type) string {
+	switch t {
+	case TypeString, TypeNumber, TypeBool:
+		return string(t)
+	case TypeObject:
+		return "Record<string, unknown>"
+	case TypeArray:
+		return "unknown[]"
+	}
+	return "unknown"
+}
+
+func tsUnion(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = quote(v)
+	}
+	return strings.Join(quoted, " | ")
+}
+
+const tsPreamble = `export interface ErrorEnvelope {
+  code: string;
+  message: string;
+  http_status: number;
+  category: string;
+  retryable: boolean;
+  suggestion?: string;
+  details?: Record<string, unknown>;
+}
+
+export interface ResponseBase<V> {
+  invocation_id?: string;
+  output?: Record<string, unknown>;
+  verdicts?: V[];
+  fact_snapshot?: Record<string, unknown>;
+  dry_run?: boolean;
+  contract_semver?: string;
+  escalation_id?: string;
+  protocol_version?: number;
+}
+
+/**
+ * The response to an operation. A denial's error is one of the operation's
+ * denials, discriminated by its code.
+ */
+export type CovenantResponse<V, D extends ErrorEnvelope> =
+  | (ResponseBase<V> & { outcome: "denied"; error: D })
+  | (ResponseBase<V> & { outcome: Exclude<Outcome, "denied">; error?: ErrorEnvelope });
+
+/** Reports whether the operation ran, or for a dry-run would have run. */
+export function wouldProceed(resp: { outcome: Outcome }): boolean {
+  return resp.outcome === "executed" || resp.outcome === "would_execute" || resp.outcome === "would_execute_with_flags";
+}
+
+/** Reports whether resp answers a dry-run. */
+export function isDryRun(resp: { outcome: Outcome }): boolean {
+  return resp.outcome.startsWith("would_");
+}
+
+/** The executor answered with an HTTP error instead of a response. */
+export class CovenantError extends Error {
+  constructor(readonly status: number, message: string) {
+    super("executor: HTTP " + status + ": " + message);
+    this.name = "CovenantError";
+  }
+}
+`
+
+const tsClientHead = `
+export interface ClientOptions {
+  /** The fetch to use; the global one by default. */
+  fetch?: typeof fetch;
+  /** Headers sent with every request, such as Authorization. */
+  headers?: Record<string, string>;
+  /** Contract ETag to pin requests to; a stale one is denied with CONTRACT_VERSION_MISMATCH. */
+  contractETag?: string;
+}
+
+export interface RequestOptions {
+  idempotencyKey?: string;
+  purpose?: string;
+  priority?: "interactive" | "batch";
+  explain?: boolean;
+  signal?: AbortSignal;
+}
+
+/**
+ * Evaluates operations on an executor. Denials and escalations are
+ * responses; a CovenantError means the request was not evaluated.
+ */
+export class CovenantClient {
+  constructor(readonly baseURL: string, private readonly options: ClientOptions = {}) {}
+`
+
+const tsClientTail = `
+  private async evaluate<R>(operation: string, input: object, dryRun: boolean, opts: RequestOptions = {}): Promise<R> {
+    const doFetch = this.options.fetch ?? fetch;
+    const res = await doFetch(this.baseURL.replace(/\/$/, "") + "/execute", {
+      method: "POST",
+      headers: { "Content-Type": "application/json", ...this.options.headers },
+      body: JSON.stringify({
+        operation,
+        input,
+        dry_run: dryRun,
+        contract_etag: this.options.contractETag,
+        idempotency_key: opts.idempotencyKey,
+        purpose: opts.purpose,
+        priority: opts.priority,
+        explain: opts.explain,
+        protocol_version: PROTOCOL_VERSION,
+      }),
+      signal: opts.signal,
+    });
+    if (!res.ok) {
+      throw new CovenantError(res.status, (await res.text()).trim());
+    }
+    return (await res.json()) as R;
+  }
+}
+`
@@ -0,0 +1,157 @@
+// Package covenant embeds the contract engine in a Go service.
+//
+// The executor runs the engine behind HTTP; a Go service can instead load a
+// contract in-process and call Evaluate directly, skipping the network hop:
+//
+//	cov, err := covenant.New(covenant.Dir("contracts/billing"), registry)
+//	...
+//	resp, err := cov.Evaluate(ctx, &covenant.Request{Operation: "ProcessPayment", Input: input})
+//
+// To guard an existing handler, evaluate with the handler as the operation's
+// action (see WithAction): the engine gathers facts and applies the rules,
+// and runs the handler only if the contract allows the request — exactly
+// where the executor would call the operation's port. Denials and
+// escalations come back as a Response with the contract's error envelope and
+// the handler never runs.
+package covenant
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"covenant-poc/executor/engine"
+)
+
+// Types shared with the engine.
+type (
+	Request       = engine.Request
+	Response      = engine.Response
+	Outcome       = engine.Outcome
+	ErrorEnvelope = engine.ErrorEnvelope
+	UndoInfo      = engine.UndoInfo
+	Contract      = engine.Contract
+	PortRegistry  = engine.PortRegistry
+	Option        = engine.Option
+)
+
+// Outcomes, as in Response.Outcome; see engine.Outcome.
+const (
+	OutcomeExecuted              = engine.OutcomeExecuted
+	OutcomeDenied                = engine.OutcomeDenied
+	OutcomeEscalated             = engine.OutcomeEscalated
+	OutcomeRequired              = engine.OutcomeRequired
+	OutcomeSystemError           = engine.OutcomeSystemError
+	OutcomeStateConflict         = engine.OutcomeStateConflict
+	OutcomeThrottled             = engine.OutcomeThrottled
+	OutcomePortContractViolation = engine.OutcomePortContractViolation
+	OutcomeWouldExecute          = engine.OutcomeWouldExecute
+	OutcomeWouldExecuteWithFlags = engine.OutcomeWouldExecuteWithFlags
+	OutcomeWouldDeny             = engine.OutcomeWouldDeny
+	OutcomeWouldEscalate         = engine.OutcomeWouldEscalate
+	OutcomeWouldRequire          = engine.OutcomeWouldRequire
+)
+
+// Covenant is an in-process contract engine. It is safe for concurrent use.
+type Covenant struct {
+	eng    *engine.Engine
+	source Source
+
+	mu sync.Mutex // serialises Reload
+}
+
+// New loads the contract from source and returns an engine that reads facts
+// from and executes operations on ports. ports may be nil if every fact the
+// contract needs comes from request input and every operation runs as an
+// action. opts are the engine's options, such as engine.WithAuditSink.
+func New(source Source, ports PortRegistry, opts ...Option) (*Covenant, error) {
+	if ports == nil {
+		ports = noPorts{}
+	}
+	c := &Covenant{
+		eng:    engine.NewEngine(actionPorts{ports}, opts...),
+		source: source,
+	}
+	if err := c.Reload(context.Background()); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Evaluate runs req against the loaded contract. See engine.Engine.Evaluate.
+func (c *Covenant) Evaluate(ctx context.Context, req *Request) (*Response, error) {
+	return c.eng.Evaluate(ctx, req)
+}
+
+// Reload fetches the contract from the source again and, if its ETag
+// changed, swaps it in. Evaluations in flight finish on the old contract.
+func (c *Covenant) Reload(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	contract, etag, err := c.source.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("load contract: %w", err)
+	}
+	if etag == c.eng.ETag() {
+		return nil
+	}
+	for _, d := range engine.Validate(contract, time.Now()) {
+		if d.Severity == engine.SeverityError {
+			return fmt.Errorf("contract invalid: %s", d)
+		}
+	}
+	if diags := c.eng.Warmup(contract, time.Now()); len(diags) > 0 {
+		return fmt.Errorf("contract warm-up failed: %s", diags[0])
+	}
+	c.eng.LoadContract(contract, etag)
+	return nil
+}
+
+// ETag returns the ETag of the active contract.
+func (c *Covenant) ETag() string {
+	return c.eng.ETag()
+}
+
+// Engine returns the underlying engine, for the executor's transport packages
+// (graphql, jsonrpc) and anything else that takes one.
+func (c *Covenant) Engine() *engine.Engine {
+	return c.eng
+}
+
+// Action performs an allowed operation in place of its port.
+type Action func(ctx context.Context) (map[string]any, error)
+
+type actionKey struct{}
+
+// WithAction returns a context under which a live evaluation that the
+// contract allows runs fn instead of executing the operation on its port.
+// fn's output becomes the response's output; an error from fn becomes an
+// EXECUTION_FAILED system error. Dry-runs never run fn.
+func WithAction(ctx context.Context, fn Action) context.Context {
+	return context.WithValue(ctx, actionKey{}, fn)
+}
+
+// actionPorts runs the context's action, if any, in place of Execute.
+type actionPorts struct {
+	PortRegistry
+}
+
+func (p actionPorts) Execute(ctx context.Context, port, operation string, input map[string]any) (map[string]any, error) {
+	if fn, ok := ctx.Value(actionKey{}).(Action); ok {
+		return fn(ctx)
+	}
+	return p.PortRegistry.Execute(ctx, port, operation, input)
+}
+
+// noPorts is the registry for a Covenant without ports.
+type noPorts struct{}
+
+func (noPorts) Get(_ context.Context, port, fact string, _ map[string]any) (any, error) {
+	return nil, fmt.Errorf("fact %q: no port %q registered", fact, port)
+}
+
+func (noPorts) Execute(_ context.Context, port, operation string, _ map[string]any) (map[string]any, error) {
+	return nil, fmt.Errorf("%s: no port %q registered and no action given", operation, port)
+}
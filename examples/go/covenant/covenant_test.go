@@ -0,0 +1,203 @@
+package covenant
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"covenant-poc/executor/engine"
+)
+
+func denyOver100() *Contract {
+	return &Contract{
+		Facts: map[string]engine.FactDef{"amount": {Source: "input"}},
+		Rules: []engine.RuleDef{{
+			ID:        "limit",
+			AppliesTo: []string{"Pay"},
+			When:      engine.Condition{Fact: "amount", GreaterThan: 100.0},
+			Verdict: engine.VerdictDef{Deny: &engine.DenyVerdict{
+				Code:  "LIMIT",
+				Error: engine.ErrorEnvelope{Code: "LIMIT", HttpStatus: 403},
+			}},
+		}},
+		Operations: map[string]engine.OperationDef{"Pay": {ConstrainedBy: []string{"limit"}}},
+	}
+}
+
+func TestCovenant_actionRunsOnlyWhenAllowed(t *testing.T) {
+	cov, err := New(Static(denyOver100()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ran int
+	ctx := WithAction(context.Background(), func(context.Context) (map[string]any, error) {
+		ran++
+		return map[string]any{"ok": true}, nil
+	})
+
+	resp, err := cov.Evaluate(ctx, &Request{Operation: "Pay", Input: map[string]any{"amount": 50.0}})
+	if err != nil || resp.Outcome != "executed" || resp.Output["ok"] != true {
+		t.Fatalf("expected executed with action output, got %+v, %v", resp, err)
+	}
+	resp, _ = cov.Evaluate(ctx, &Request{Operation: "Pay", Input: map[string]any{"amount": 500.0}})
+	if resp.Outcome != "denied" || resp.Error.Code != "LIMIT" {
+		t.Fatalf("expected LIMIT denial, got %+v", resp)
+	}
+	resp, _ = cov.Evaluate(ctx, &Request{Operation: "Pay", Input: map[string]any{"amount": 50.0}, DryRun: true})
+	if resp.Outcome != "would_execute" {
+		t.Fatalf("expected would_execute, got %+v", resp)
+	}
+	if ran != 1 {
+		t.Errorf("expected the action to run once, ran %d times", ran)
+	}
+}
+
+func TestCovenant_actionErrorIsExecutionFailure(t *testing.T) {
+	cov, _ := New(Static(denyOver100()), nil)
+	ctx := WithAction(context.Background(), func(context.Context) (map[string]any, error) {
+		return nil, errors.New("db down")
+	})
+	resp, _ := cov.Evaluate(ctx, &Request{Operation: "Pay", Input: map[string]any{"amount": 1.0}})
+	if resp.Outcome != "system_error" || resp.Error.Code != "EXECUTION_FAILED" {
+		t.Errorf("expected EXECUTION_FAILED, got %+v", resp)
+	}
+}
+
+func TestCovenant_reloadSwapsOnNewETag(t *testing.T) {
+	contract := denyOver100()
+	loads := 0
+	src := SourceFunc(func(context.Context) (*Contract, string, error) {
+		loads++
+		if loads > 1 {
+			contract = &Contract{Operations: map[string]engine.OperationDef{"Refund": {}}}
+			return contract, "v2", nil
+		}
+		return contract, "v1", nil
+	})
+	cov, err := New(src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cov.Reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if cov.ETag() != "v2" {
+		t.Fatalf("expected v2 after reload, got %s", cov.ETag())
+	}
+	if _, err := cov.Evaluate(context.Background(), &Request{Operation: "Pay"}); !errors.Is(err, engine.ErrUnknownOperation) {
+		t.Errorf("expected Pay to be gone after reload, got %v", err)
+	}
+}
+
+func TestCovenant_sourceErrorFailsNew(t *testing.T) {
+	src := SourceFunc(func(context.Context) (*Contract, string, error) {
+		return nil, "", errors.New("unreachable")
+	})
+	if _, err := New(src, nil); err == nil {
+		t.Error("expected New to fail")
+	}
+}
+
+func TestStatic_etagIsContentHash(t *testing.T) {
+	_, a, _ := Static(denyOver100()).Load(context.Background())
+	_, b, _ := Static(denyOver100()).Load(context.Background())
+	_, c, _ := Static(&Contract{}).Load(context.Background())
+	if a != b || a == c || len(a) != 12 {
+		t.Errorf("expected stable 12-digit content ETags, got %s, %s, %s", a, b, c)
+	}
+}
+
+func TestServer_startsOverOnTornRead(t *testing.T) {
+	var discoveries atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The contract changes to v2 right after the first discovery.
+		current := "v2"
+		if r.URL.Path == "/.well-known/covenant" {
+			if discoveries.Add(1) == 1 {
+				current = "v1"
+			}
+			fmt.Fprintf(w, `{"contract_etag": %q, "contracts": {"files": ["/contracts/shop/c.cue"]}}`, current)
+			return
+		}
+		if r.Header.Get("If-Match") != `"`+current+`"` {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		fmt.Fprint(w, `operations: Pay: {constrained_by: [], transitions: []}`)
+	}))
+	defer srv.Close()
+
+	c, etag, err := Server(srv.URL).Load(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Operations["Pay"]; !ok || etag != "v2" || discoveries.Load() != 2 {
+		t.Errorf("expected v2 after one retry, got %s after %d discoveries", etag, discoveries.Load())
+	}
+}
+
+func TestRemote_evaluatesOnExecutor(t *testing.T) {
+	cov, err := New(Static(denyOver100()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		json.NewDecoder(r.Body).Decode(&req)
+		resp, err := cov.Evaluate(r.Context(), &req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer srv.Close()
+
+	var c Client = &Remote{URL: srv.URL}
+	resp, err := c.Evaluate(context.Background(), &Request{Operation: "Pay", Input: map[string]any{"amount": 500.0}, DryRun: true})
+	if err != nil || resp.Outcome != "would_deny" || resp.Verdicts[0].Code != "LIMIT" {
+		t.Fatalf("expected a LIMIT would_deny, got %+v, %v", resp, err)
+	}
+	if _, err := c.Evaluate(context.Background(), &Request{Operation: "Missing"}); err == nil {
+		t.Error("expected an executor error to be returned")
+	}
+}
+
+func TestRemote_rejectsUnknownOutcomes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"outcome": "would_maybe"}`))
+	}))
+	defer srv.Close()
+
+	_, err := (&Remote{URL: srv.URL}).Evaluate(context.Background(), &Request{Operation: "Pay"})
+	if err == nil || !strings.Contains(err.Error(), `unknown outcome "would_maybe"`) {
+		t.Errorf("expected the outcome rejected, got %v", err)
+	}
+}
+
+func TestRemote_negotiatesProtocol(t *testing.T) {
+	var requested int
+	version := engine.ProtocolV1
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		json.NewDecoder(r.Body).Decode(&req)
+		requested = req.ProtocolVersion
+		fmt.Fprintf(w, `{"outcome": "executed", "protocol_version": %d}`, version)
+	}))
+	defer srv.Close()
+
+	c := &Remote{URL: srv.URL}
+	if _, err := c.Evaluate(context.Background(), &Request{Operation: "Pay"}); err != nil || requested != engine.ProtocolVersion {
+		t.Fatalf("expected version %d requested and an older answer accepted, got %d, %v", engine.ProtocolVersion, requested, err)
+	}
+	version = engine.ProtocolVersion + 1
+	if _, err := c.Evaluate(context.Background(), &Request{Operation: "Pay"}); err == nil {
+		t.Error("expected an answer newer than requested rejected")
+	}
+}
@@ -0,0 +1,249 @@
+// Package covenantadmission serves a contract as a Kubernetes validating
+// admission webhook, so covenant rules govern changes to the cluster.
+//
+// Handler maps Kubernetes operations on resources to contract operations.
+// For a matching AdmissionReview it extracts the operation's input facts
+// from the object, the old object and the request as the rule's bindings
+// declare, evaluates the operation, and answers with the verdict: allowed
+// if the contract would execute it, with its flags as warnings, and refused
+// with the contract's error envelope otherwise. Reviews are evaluated as
+// dry runs, since the API server rather than a port makes the change, so
+// they are decided and audited but nothing executes. Reviews of unmapped
+// resources are allowed.
+//
+//	http.Handle("POST /admission", covenantadmission.Handler(cov, covenantadmission.Rules{
+//		"CREATE apps/deployments": {
+//			Operation: "CreateDeployment",
+//			Input: map[string]covenantadmission.Binding{
+//				"deployment.replicas": covenantadmission.Object("/spec/replicas"),
+//				"user.name":           covenantadmission.Request("/userInfo/username"),
+//			},
+//		},
+//	}))
+//
+// The API server only calls webhooks over HTTPS.
+package covenantadmission
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"covenant-poc/covenant/binding"
+	"covenant-poc/executor/engine"
+)
+
+// Evaluator decides requests; both *covenant.Covenant and *engine.Engine
+// are Evaluators.
+type Evaluator interface {
+	Evaluate(ctx context.Context, req *engine.Request) (*engine.Response, error)
+}
+
+// Binding sources.
+const (
+	FromObject    = "object"     // the object being admitted
+	FromOldObject = "old_object" // the object before an UPDATE or DELETE
+	FromRequest   = "request"    // the AdmissionRequest, e.g. /userInfo/username
+)
+
+// Binding locates one input fact in an AdmissionReview, as an RFC 6901
+// JSON pointer ("" for the whole document) into one of its sources.
+type Binding struct {
+	From    string `json:"from"`
+	Pointer string `json:"pointer"`
+}
+
+// Object binds the value at a JSON pointer in the object.
+func Object(pointer string) Binding { return Binding{From: FromObject, Pointer: pointer} }
+
+// OldObject binds the value at a JSON pointer in the old object.
+func OldObject(pointer string) Binding { return Binding{From: FromOldObject, Pointer: pointer} }
+
+// Request binds the value at a JSON pointer in the AdmissionRequest.
+func Request(pointer string) Binding { return Binding{From: FromRequest, Pointer: pointer} }
+
+func (b Binding) validate() error {
+	switch b.From {
+	case FromObject, FromOldObject, FromRequest:
+	default:
+		return fmt.Errorf("unknown binding source %q", b.From)
+	}
+	if b.Pointer != "" && !strings.HasPrefix(b.Pointer, "/") {
+		return fmt.Errorf("pointer %q must be empty or start with /", b.Pointer)
+	}
+	return nil
+}
+
+// Rule maps one operation on a resource to a contract operation.
+type Rule struct {
+	Operation string `json:"operation"`
+	// Input maps each input fact to where it is found in the review.
+	Input map[string]Binding `json:"input"`
+}
+
+// Rules maps Kubernetes operations on resources, keyed as in binding
+// specs (e.g. "CREATE apps/deployments", "CONNECT core/pods/exec"), to the
+// contract operations they perform.
+type Rules map[string]Rule
+
+// FromSpec returns the admission rules of a binding spec.
+func FromSpec(spec *binding.Spec) Rules {
+	rules := make(Rules, len(spec.Admission))
+	for key, r := range spec.Admission {
+		rule := Rule{Operation: r.Operation, Input: make(map[string]Binding, len(r.Input))}
+		for fact, src := range r.Input {
+			switch {
+			case src.Object != nil:
+				rule.Input[fact] = Object(*src.Object)
+			case src.OldObject != nil:
+				rule.Input[fact] = OldObject(*src.OldObject)
+			case src.Request != nil:
+				rule.Input[fact] = Request(*src.Request)
+			}
+		}
+		rules[key] = rule
+	}
+	return rules
+}
+
+// maxReview bounds the AdmissionReview read; objects are at most about
+// 1.5MB in etcd.
+const maxReview = 4 << 20
+
+// Handler returns a webhook answering AdmissionReviews by ev's contract. It
+// panics if a rule key or binding is malformed, as http.ServeMux.Handle
+// does.
+func Handler(ev Evaluator, rules Rules) http.Handler {
+	byTarget := make(map[binding.AdmissionTarget]Rule, len(rules))
+	for key, rule := range rules {
+		t, err := binding.ParseAdmissionKey(key)
+		if err != nil {
+			panic(fmt.Sprintf("covenantadmission: %v", err))
+		}
+		if rule.Operation == "" {
+			panic(fmt.Sprintf("covenantadmission: %s: no operation", key))
+		}
+		for fact, b := range rule.Input {
+			if err := b.validate(); err != nil {
+				panic(fmt.Sprintf("covenantadmission: %s: %s: %v", key, fact, err))
+			}
+		}
+		byTarget[t] = rule
+	}
+	return &webhook{ev: ev, rules: byTarget}
+}
+
+type webhook struct {
+	ev    Evaluator
+	rules map[binding.AdmissionTarget]Rule
+}
+
+func (h *webhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxReview+1))
+	if err != nil || len(data) > maxReview {
+		http.Error(w, "unreadable or oversized AdmissionReview", http.StatusBadRequest)
+		return
+	}
+	var review Review
+	if err := json.Unmarshal(data, &review); err != nil || review.Request == nil {
+		http.Error(w, "expected an AdmissionReview with a request", http.StatusBadRequest)
+		return
+	}
+	review.Response = h.review(r.Context(), review.Request)
+	review.Request = nil
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(review)
+}
+
+// review decides one AdmissionRequest.
+func (h *webhook) review(ctx context.Context, raw json.RawMessage) *AdmissionResponse {
+	var req AdmissionRequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return &AdmissionResponse{Result: &Status{Code: http.StatusBadRequest, Message: err.Error()}}
+	}
+	allowed := &AdmissionResponse{UID: req.UID, Allowed: true}
+	rule, ok := h.rules[binding.AdmissionTarget{
+		Operation:   req.Operation,
+		Group:       req.Resource.Group,
+		Resource:    req.Resource.Resource,
+		Subresource: req.SubResource,
+	}]
+	if !ok {
+		return allowed
+	}
+
+	docs := map[string]any{}
+	for from, doc := range map[string]json.RawMessage{FromObject: req.Object, FromOldObject: req.OldObject, FromRequest: raw} {
+		if len(doc) == 0 || string(doc) == "null" {
+			continue
+		}
+		var v any
+		if err := json.Unmarshal(doc, &v); err != nil {
+			return refused(req.UID, http.StatusBadRequest, fmt.Sprintf("%s is not JSON: %v", from, err))
+		}
+		docs[from] = v
+	}
+	input := make(map[string]any, len(rule.Input))
+	for fact, b := range rule.Input {
+		if v, ok := binding.Pointer(docs[b.From], b.Pointer); ok {
+			input[fact] = v
+		}
+	}
+
+	resp, err := h.ev.Evaluate(ctx, &engine.Request{Operation: rule.Operation, Input: input, DryRun: true})
+	if err != nil {
+		log.Printf("covenantadmission: %s: %v", rule.Operation, err)
+		return refused(req.UID, http.StatusInternalServerError, "contract evaluation failed")
+	}
+	var warnings []string
+	for _, v := range resp.Verdicts {
+		if v.Type == "flag" {
+			warnings = append(warnings, verdictMessage(v))
+		}
+	}
+	switch resp.Outcome {
+	case engine.OutcomeWouldExecute, engine.OutcomeWouldExecuteWithFlags:
+		allowed.Warnings = warnings
+		return allowed
+	}
+	// A dry run carries the deciding verdict's envelope on the verdict.
+	out := refused(req.UID, http.StatusForbidden, fmt.Sprintf("%s: %s", rule.Operation, resp.Outcome))
+	out.Warnings = warnings
+	env := resp.Error
+	for _, v := range resp.Verdicts {
+		if v.Type != "flag" {
+			out.Result.Message = verdictMessage(v)
+			if env == nil {
+				env = v.Error
+			}
+			break
+		}
+	}
+	if env != nil {
+		if env.HttpStatus != 0 {
+			out.Result.Code = env.HttpStatus
+		}
+		out.Result.Message = env.Code + ": " + env.Message
+	}
+	return out
+}
+
+func refused(uid string, code int, message string) *AdmissionResponse {
+	return &AdmissionResponse{UID: uid, Result: &Status{Code: code, Message: message}}
+}
+
+// verdictMessage describes a verdict for kubectl users.
+func verdictMessage(v engine.Verdict) string {
+	msg := v.Code
+	if v.Reason != "" {
+		msg += ": " + v.Reason
+	}
+	if v.Type == "escalate" && v.Queue != "" {
+		msg += " (needs review by " + v.Queue + ")"
+	}
+	return msg
+}
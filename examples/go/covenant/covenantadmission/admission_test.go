@@ -0,0 +1,156 @@
+package covenantadmission
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"covenant-poc/covenant"
+	"covenant-poc/covenant/binding"
+	"covenant-poc/executor/engine"
+)
+
+// newCovenant denies deployments of more than 10 replicas and flags those
+// made by anyone outside the platform team.
+func newCovenant(t *testing.T) *covenant.Covenant {
+	t.Helper()
+	cov, err := covenant.New(covenant.Static(&engine.Contract{
+		Facts: map[string]engine.FactDef{
+			"deployment.replicas": {Source: "input", Required: true},
+			"user.name":           {Source: "input"},
+		},
+		Rules: []engine.RuleDef{
+			{
+				ID:        "replicas",
+				AppliesTo: []string{"CreateDeployment"},
+				When:      engine.Condition{Fact: "deployment.replicas", GreaterThan: 10.0},
+				Verdict: engine.VerdictDef{Deny: &engine.DenyVerdict{
+					Code:  "TOO_MANY_REPLICAS",
+					Error: engine.ErrorEnvelope{Code: "TOO_MANY_REPLICAS", Message: "at most 10 replicas", HttpStatus: 422},
+				}},
+			},
+			{
+				ID:        "outsider",
+				AppliesTo: []string{"CreateDeployment"},
+				When:      engine.Condition{Not: &engine.Condition{Fact: "user.name", Equals: "platform"}},
+				Verdict:   engine.VerdictDef{Flag: &engine.FlagVerdict{Code: "OUTSIDER", Reason: "not deployed by the platform team"}},
+			},
+		},
+		Operations: map[string]engine.OperationDef{"CreateDeployment": {ConstrainedBy: []string{"replicas", "outsider"}}},
+	}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cov
+}
+
+var deploymentRules = Rules{
+	"CREATE apps/deployments": {
+		Operation: "CreateDeployment",
+		Input: map[string]Binding{
+			"deployment.replicas": Object("/spec/replicas"),
+			"user.name":           Request("/userInfo/username"),
+		},
+	},
+}
+
+func review(t *testing.T, h http.Handler, req string) *AdmissionResponse {
+	t.Helper()
+	body := `{"apiVersion":"admission.k8s.io/v1","kind":"AdmissionReview","request":` + req + `}`
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/admission", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var out Review
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Kind != "AdmissionReview" || out.Request != nil || out.Response == nil {
+		t.Fatalf("unexpected review %s", rec.Body)
+	}
+	return out.Response
+}
+
+func createDeployment(replicas int, user string) string {
+	return fmt.Sprintf(`{"uid":"u1","operation":"CREATE","resource":{"group":"apps","version":"v1","resource":"deployments"},`+
+		`"userInfo":{"username":%q},"object":{"spec":{"replicas":%d}}}`, user, replicas)
+}
+
+func TestHandler_allowsWithFlagsAsWarnings(t *testing.T) {
+	h := Handler(newCovenant(t), deploymentRules)
+
+	resp := review(t, h, createDeployment(3, "platform"))
+	if !resp.Allowed || resp.UID != "u1" || len(resp.Warnings) != 0 {
+		t.Errorf("expected an allowed review without warnings, got %+v", resp)
+	}
+	resp = review(t, h, createDeployment(3, "intern"))
+	if !resp.Allowed || len(resp.Warnings) != 1 || resp.Warnings[0] != "OUTSIDER: not deployed by the platform team" {
+		t.Errorf("expected the flag as a warning, got %+v", resp)
+	}
+}
+
+func TestHandler_refusesWithErrorEnvelope(t *testing.T) {
+	resp := review(t, Handler(newCovenant(t), deploymentRules), createDeployment(50, "platform"))
+	if resp.Allowed || resp.Result == nil || resp.Result.Code != 422 || resp.Result.Message != "TOO_MANY_REPLICAS: at most 10 replicas" {
+		t.Errorf("expected the deny envelope, got %+v %+v", resp, resp.Result)
+	}
+}
+
+func TestHandler_allowsUnmappedResources(t *testing.T) {
+	resp := review(t, Handler(newCovenant(t), deploymentRules),
+		`{"uid":"u2","operation":"DELETE","resource":{"group":"apps","version":"v1","resource":"deployments"}}`)
+	if !resp.Allowed || resp.UID != "u2" {
+		t.Errorf("expected an unmapped review allowed, got %+v", resp)
+	}
+}
+
+func TestHandler_rejectsMalformedReviews(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Handler(newCovenant(t), deploymentRules).ServeHTTP(rec, httptest.NewRequest("POST", "/admission", strings.NewReader(`{"kind":"AdmissionReview"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a review without a request, got %d", rec.Code)
+	}
+}
+
+func TestHandler_panicsOnMalformedRules(t *testing.T) {
+	for name, rules := range map[string]Rules{
+		"bad key":     {"PATCH apps/deployments": {Operation: "CreateDeployment"}},
+		"no op":       {"CREATE apps/deployments": {}},
+		"bad source":  {"CREATE apps/deployments": {Operation: "Op", Input: map[string]Binding{"f": {From: "query"}}}},
+		"bad pointer": {"CREATE apps/deployments": {Operation: "Op", Input: map[string]Binding{"f": Object("spec")}}},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected a panic", name)
+				}
+			}()
+			Handler(newCovenant(t), rules)
+		}()
+	}
+}
+
+func TestFromSpec(t *testing.T) {
+	spec, err := binding.Parse([]byte(`
+admission: "CREATE apps/deployments": {
+	operation: "CreateDeployment"
+	input: {
+		"deployment.replicas": {object: "/spec/replicas"}
+		"user.name":           {request: "/userInfo/username"}
+	}
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := FromSpec(spec)
+	got := rules["CREATE apps/deployments"]
+	want := deploymentRules["CREATE apps/deployments"]
+	if got.Operation != want.Operation || len(got.Input) != 2 || got.Input["deployment.replicas"] != want.Input["deployment.replicas"] || got.Input["user.name"] != want.Input["user.name"] {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
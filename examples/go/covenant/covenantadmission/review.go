@@ -0,0 +1,45 @@
+package covenantadmission
+
+import "encoding/json"
+
+// Review is an admission.k8s.io/v1 AdmissionReview. The webhook answers
+// with the review it was sent, its request replaced by a response.
+type Review struct {
+	APIVersion string             `json:"apiVersion"`
+	Kind       string             `json:"kind"`
+	Request    json.RawMessage    `json:"request,omitempty"`
+	Response   *AdmissionResponse `json:"response,omitempty"`
+}
+
+// AdmissionRequest is the part of an AdmissionRequest the webhook reads.
+type AdmissionRequest struct {
+	UID         string          `json:"uid"`
+	Resource    Resource        `json:"resource"`
+	SubResource string          `json:"subResource,omitempty"`
+	Operation   string          `json:"operation"`
+	Name        string          `json:"name,omitempty"`
+	Namespace   string          `json:"namespace,omitempty"`
+	Object      json.RawMessage `json:"object,omitempty"`
+	OldObject   json.RawMessage `json:"oldObject,omitempty"`
+}
+
+// Resource is a group, version and resource.
+type Resource struct {
+	Group    string `json:"group"`
+	Version  string `json:"version"`
+	Resource string `json:"resource"`
+}
+
+// AdmissionResponse answers an AdmissionRequest.
+type AdmissionResponse struct {
+	UID      string   `json:"uid"`
+	Allowed  bool     `json:"allowed"`
+	Result   *Status  `json:"status,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// Status explains a refusal to the client; kubectl prints the message.
+type Status struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
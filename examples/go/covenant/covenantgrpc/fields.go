@@ -0,0 +1,110 @@
+package covenantgrpc
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// input extracts the bound facts from msg. A field path names fields by
+// their proto or JSON name, separated by dots ("payment.amount").
+func input(msg any, bindings map[string]string) (map[string]any, error) {
+	pm, ok := msg.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("request %T is not a proto message", msg)
+	}
+	m := pm.ProtoReflect()
+	out := make(map[string]any, len(bindings))
+	for fact, path := range bindings {
+		v, ok, err := field(m, path)
+		if err != nil {
+			return nil, fmt.Errorf("fact %q: %w", fact, err)
+		}
+		if ok {
+			out[fact] = v
+		}
+	}
+	return out, nil
+}
+
+// field resolves a field path in m. It reports false if a field with
+// presence along the path is unset.
+func field(m protoreflect.Message, path string) (any, bool, error) {
+	parts := strings.Split(path, ".")
+	for i, name := range parts {
+		fields := m.Descriptor().Fields()
+		fd := fields.ByName(protoreflect.Name(name))
+		if fd == nil {
+			fd = fields.ByJSONName(name)
+		}
+		if fd == nil {
+			return nil, false, fmt.Errorf("%s has no field %q", m.Descriptor().FullName(), name)
+		}
+		if fd.HasPresence() && !m.Has(fd) {
+			return nil, false, nil
+		}
+		v := m.Get(fd)
+		if i == len(parts)-1 {
+			return value(fd, v), true, nil
+		}
+		if fd.Kind() != protoreflect.MessageKind || fd.IsList() || fd.IsMap() {
+			return nil, false, fmt.Errorf("field %q of %s is not a message", name, m.Descriptor().FullName())
+		}
+		m = v.Message()
+	}
+	return nil, false, nil
+}
+
+// value converts a field value to the engine's fact representation, the
+// same as decoded JSON: numbers are float64, enums their names, messages
+// and maps map[string]any, lists []any.
+func value(fd protoreflect.FieldDescriptor, v protoreflect.Value) any {
+	switch {
+	case fd.IsList():
+		l := v.List()
+		out := make([]any, l.Len())
+		for i := range out {
+			out[i] = scalar(fd, l.Get(i))
+		}
+		return out
+	case fd.IsMap():
+		out := map[string]any{}
+		v.Map().Range(func(k protoreflect.MapKey, mv protoreflect.Value) bool {
+			out[k.String()] = scalar(fd.MapValue(), mv)
+			return true
+		})
+		return out
+	}
+	return scalar(fd, v)
+}
+
+func scalar(fd protoreflect.FieldDescriptor, v protoreflect.Value) any {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		out := map[string]any{}
+		v.Message().Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+			out[string(fd.Name())] = value(fd, v)
+			return true
+		})
+		return out
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return string(ev.Name())
+		}
+		return float64(v.Enum())
+	case protoreflect.BoolKind:
+		return v.Bool()
+	case protoreflect.StringKind:
+		return v.String()
+	case protoreflect.BytesKind:
+		return v.Bytes()
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return v.Float()
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return float64(v.Uint())
+	default:
+		return float64(v.Int())
+	}
+}
@@ -0,0 +1,270 @@
+// Package covenantgrpc enforces contracts on an existing gRPC service.
+//
+// The interceptors map full method names to contract operations, read each
+// operation's input facts from the request message by field path, and
+// evaluate it before the method runs. A denied, escalated or failed
+// evaluation fails the call with a gRPC status converted from the contract's
+// error envelope; an allowed one runs the method unchanged.
+//
+//	methods := covenantgrpc.Methods{
+//		"/billing.v1.Billing/ProcessPayment": {
+//			Operation: "ProcessPayment",
+//			Input: map[string]string{
+//				"invoice.id":     "invoice_id",
+//				"payment.amount": "amount", // a message becomes a map of its fields
+//			},
+//		},
+//	}
+//	srv := grpc.NewServer(
+//		grpc.UnaryInterceptor(covenantgrpc.UnaryServerInterceptor(cov, methods)),
+//		grpc.StreamInterceptor(covenantgrpc.StreamServerInterceptor(cov, methods)),
+//	)
+package covenantgrpc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"covenant-poc/covenant"
+	"covenant-poc/covenant/binding"
+	"covenant-poc/executor/engine"
+)
+
+// Method maps one gRPC method to a contract operation.
+type Method struct {
+	Operation string `json:"operation"`
+	// Input maps each input fact to a field path in the request message.
+	Input map[string]string `json:"input"`
+}
+
+// Methods maps full method names, such as "/billing.v1.Billing/Pay", to the
+// operations they perform.
+type Methods map[string]Method
+
+// FromSpec returns the gRPC methods of a binding spec.
+func FromSpec(spec *binding.Spec) Methods {
+	methods := make(Methods, len(spec.GRPC))
+	for name, r := range spec.GRPC {
+		m := Method{Operation: r.Operation, Input: make(map[string]string, len(r.Input))}
+		for fact, src := range r.Input {
+			m.Input[fact] = src.Field
+		}
+		methods[name] = m
+	}
+	return methods
+}
+
+// ErrorDomain is the ErrorInfo domain of statuses built from contract
+// error envelopes.
+const ErrorDomain = "covenant"
+
+// UnaryServerInterceptor enforces cov's contract on the unary methods in
+// methods. The handler runs as the operation's action, so it runs only if
+// the contract allows the call, and its result and error are returned as is.
+func UnaryServerInterceptor(cov *covenant.Covenant, methods Methods) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		m, ok := methods[info.FullMethod]
+		if !ok {
+			return handler(ctx, req)
+		}
+		in, err := input(req, m.Input)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "covenant: %v", err)
+		}
+
+		var (
+			ran        bool
+			out        any
+			handlerErr error
+		)
+		actx := covenant.WithAction(ctx, func(ctx context.Context) (map[string]any, error) {
+			ran = true
+			out, handlerErr = handler(ctx, req)
+			return nil, handlerErr
+		})
+		resp, err := cov.Evaluate(actx, &engine.Request{
+			Operation:      m.Operation,
+			Input:          in,
+			IdempotencyKey: idempotencyKey(ctx),
+		})
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "covenant: %v", err)
+		}
+		if ran {
+			return out, handlerErr
+		}
+		return nil, Status(m.Operation, resp).Err()
+	}
+}
+
+// StreamServerInterceptor enforces cov's contract on the streaming methods in
+// methods. A method without input bindings is evaluated before the handler
+// runs, with the handler as the operation's action. One with bindings takes
+// its facts from the first request message: the handler's first RecvMsg
+// evaluates the operation and fails with the contract's status if it is not
+// allowed, so the handler must not send before receiving. The decision is
+// audited when it is made, not when the stream ends.
+func StreamServerInterceptor(cov *covenant.Covenant, methods Methods) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		m, ok := methods[info.FullMethod]
+		if !ok {
+			return handler(srv, ss)
+		}
+		if len(m.Input) == 0 {
+			var (
+				ran        bool
+				handlerErr error
+			)
+			ctx := covenant.WithAction(ss.Context(), func(context.Context) (map[string]any, error) {
+				ran = true
+				handlerErr = handler(srv, ss)
+				return nil, handlerErr
+			})
+			resp, err := cov.Evaluate(ctx, &engine.Request{Operation: m.Operation, Input: map[string]any{}})
+			if err != nil {
+				return status.Errorf(codes.Internal, "covenant: %v", err)
+			}
+			if ran {
+				return handlerErr
+			}
+			return Status(m.Operation, resp).Err()
+		}
+		return handler(srv, &guardedStream{ServerStream: ss, cov: cov, method: m})
+	}
+}
+
+// guardedStream evaluates the operation on the first received message.
+type guardedStream struct {
+	grpc.ServerStream
+	cov     *covenant.Covenant
+	method  Method
+	checked bool
+}
+
+func (s *guardedStream) RecvMsg(msg any) error {
+	if err := s.ServerStream.RecvMsg(msg); err != nil || s.checked {
+		return err
+	}
+	s.checked = true
+
+	in, err := input(msg, s.method.Input)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "covenant: %v", err)
+	}
+	allowed := false
+	ctx := covenant.WithAction(s.Context(), func(context.Context) (map[string]any, error) {
+		allowed = true
+		return nil, nil
+	})
+	resp, err := s.cov.Evaluate(ctx, &engine.Request{Operation: s.method.Operation, Input: in})
+	if err != nil {
+		return status.Errorf(codes.Internal, "covenant: %v", err)
+	}
+	if !allowed {
+		return Status(s.method.Operation, resp).Err()
+	}
+	return nil
+}
+
+// idempotencyKey reads the idempotency-key request metadata.
+func idempotencyKey(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get("idempotency-key"); len(v) > 0 {
+			return v[0]
+		}
+	}
+	return ""
+}
+
+// Status converts a response that did not execute into a gRPC status. The
+// code follows the envelope's HTTP status; an ErrorInfo detail carries the
+// envelope's code as its reason, with the category, retryability,
+// suggestion, invocation ID and any escalation ID as metadata. Escalations
+// are FailedPrecondition with reason ESCALATED.
+func Status(operation string, resp *engine.Response) *status.Status {
+	env := resp.Error
+	switch {
+	case env != nil:
+	case resp.Outcome == engine.OutcomeEscalated:
+		env = &engine.ErrorEnvelope{
+			Code:       "ESCALATED",
+			Message:    operation + " is held for review",
+			HttpStatus: http.StatusAccepted,
+			Category:   "escalation",
+		}
+	default:
+		env = &engine.ErrorEnvelope{
+			Code:       "NOT_EXECUTED",
+			Message:    fmt.Sprintf("%s: outcome %s", operation, resp.Outcome),
+			HttpStatus: http.StatusInternalServerError,
+			Category:   "system",
+		}
+	}
+
+	info := &errdetails.ErrorInfo{
+		Reason: env.Code,
+		Domain: ErrorDomain,
+		Metadata: map[string]string{
+			"operation": operation,
+			"outcome":   string(resp.Outcome),
+			"retryable": strconv.FormatBool(env.Retryable),
+		},
+	}
+	for k, v := range map[string]string{
+		"category":      env.Category,
+		"suggestion":    env.Suggestion,
+		"invocation_id": resp.InvocationID,
+		"escalation_id": resp.EscalationID,
+	} {
+		if v != "" {
+			info.Metadata[k] = v
+		}
+	}
+
+	st := status.New(grpcCode(env), env.Message)
+	if withDetails, err := st.WithDetails(info); err == nil {
+		st = withDetails
+	}
+	return st
+}
+
+// grpcCode maps an envelope's HTTP status to a gRPC code, following the
+// google.rpc.Code mapping.
+func grpcCode(env *engine.ErrorEnvelope) codes.Code {
+	switch env.HttpStatus {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.Aborted
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+	switch {
+	case env.HttpStatus >= 500:
+		return codes.Internal
+	default:
+		// 402 and other business-rule refusals, and escalations: the call is
+		// valid but the system's state does not allow it.
+		return codes.FailedPrecondition
+	}
+}
@@ -0,0 +1,243 @@
+package covenantgrpc
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"covenant-poc/covenant"
+	"covenant-poc/executor/engine"
+)
+
+// payRequest is the descriptor of
+//
+//	message Money { double value = 1; string currency = 2; }
+//	message PayRequest { string invoice_id = 1; Money amount = 2; repeated string tags = 3; }
+var payRequest = sync.OnceValue(func() protoreflect.MessageDescriptor {
+	field := func(name string, num int32, typ descriptorpb.FieldDescriptorProto_Type, label descriptorpb.FieldDescriptorProto_Label, typeName string) *descriptorpb.FieldDescriptorProto {
+		f := &descriptorpb.FieldDescriptorProto{
+			Name: proto.String(name), Number: proto.Int32(num), Type: typ.Enum(), Label: label.Enum(),
+			JsonName: proto.String(name),
+		}
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		}
+		return f
+	}
+	opt := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:    proto.String("pay.proto"),
+		Package: proto.String("test"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("Money"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("value", 1, descriptorpb.FieldDescriptorProto_TYPE_DOUBLE, opt, ""),
+				field("currency", 2, descriptorpb.FieldDescriptorProto_TYPE_STRING, opt, ""),
+			}},
+			{Name: proto.String("PayRequest"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("invoice_id", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, opt, ""),
+				field("amount", 2, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, opt, ".test.Money"),
+				field("tags", 3, descriptorpb.FieldDescriptorProto_TYPE_STRING, descriptorpb.FieldDescriptorProto_LABEL_REPEATED, ""),
+			}},
+		},
+	}, nil)
+	if err != nil {
+		panic(err)
+	}
+	return fd.Messages().ByName("PayRequest")
+})
+
+func newPay(invoice string, amount float64) proto.Message {
+	md := payRequest()
+	msg := dynamicpb.NewMessage(md)
+	msg.Set(md.Fields().ByName("invoice_id"), protoreflect.ValueOfString(invoice))
+	money := dynamicpb.NewMessage(md.Fields().ByName("amount").Message())
+	money.Set(money.Descriptor().Fields().ByName("value"), protoreflect.ValueOfFloat64(amount))
+	money.Set(money.Descriptor().Fields().ByName("currency"), protoreflect.ValueOfString("USD"))
+	msg.Set(md.Fields().ByName("amount"), protoreflect.ValueOfMessage(money))
+	tags := msg.Mutable(md.Fields().ByName("tags")).List()
+	tags.Append(protoreflect.ValueOfString("web"))
+	return msg
+}
+
+// newCovenant denies Pay above 100 USD.
+func newCovenant(t *testing.T) *covenant.Covenant {
+	t.Helper()
+	cov, err := covenant.New(covenant.Static(&engine.Contract{
+		Facts: map[string]engine.FactDef{
+			"invoice.id":     {Source: "input", Required: true},
+			"payment.amount": {Source: "input"},
+		},
+		Rules: []engine.RuleDef{{
+			ID:        "limit",
+			AppliesTo: []string{"Pay"},
+			When:      engine.Condition{Fact: "payment.amount.value", GreaterThan: 100.0},
+			Verdict: engine.VerdictDef{Deny: &engine.DenyVerdict{
+				Code: "LIMIT",
+				Error: engine.ErrorEnvelope{
+					Code: "LIMIT", Message: "over limit", HttpStatus: 402,
+					Category: "business_rule_violation", Suggestion: "Pay less",
+				},
+			}},
+		}},
+		Operations: map[string]engine.OperationDef{"Pay": {ConstrainedBy: []string{"limit"}}},
+	}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cov
+}
+
+var payMethods = Methods{
+	"/test.Billing/Pay": {
+		Operation: "Pay",
+		Input:     map[string]string{"invoice.id": "invoice_id", "payment.amount": "amount"},
+	},
+}
+
+func TestUnaryServerInterceptor_allowedCallRunsHandler(t *testing.T) {
+	intercept := UnaryServerInterceptor(newCovenant(t), payMethods)
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Billing/Pay"}
+
+	out, err := intercept(context.Background(), newPay("inv_1", 50), info, func(context.Context, any) (any, error) {
+		return "paid", nil
+	})
+	if err != nil || out != "paid" {
+		t.Errorf("expected handler result, got %v, %v", out, err)
+	}
+}
+
+func TestUnaryServerInterceptor_denyBecomesStatusWithDetails(t *testing.T) {
+	intercept := UnaryServerInterceptor(newCovenant(t), payMethods)
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Billing/Pay"}
+
+	called := false
+	_, err := intercept(context.Background(), newPay("inv_1", 500), info, func(context.Context, any) (any, error) {
+		called = true
+		return nil, nil
+	})
+	if called {
+		t.Fatal("handler ran for a denied call")
+	}
+	st := status.Convert(err)
+	if st.Code() != codes.FailedPrecondition || st.Message() != "over limit" {
+		t.Fatalf("unexpected status %v", st)
+	}
+	if len(st.Details()) != 1 {
+		t.Fatalf("expected one detail, got %v", st.Details())
+	}
+	info2, ok := st.Details()[0].(*errdetails.ErrorInfo)
+	if !ok || info2.Reason != "LIMIT" || info2.Domain != ErrorDomain || info2.Metadata["suggestion"] != "Pay less" || info2.Metadata["invocation_id"] == "" {
+		t.Errorf("unexpected detail %v", st.Details()[0])
+	}
+}
+
+func TestUnaryServerInterceptor_handlerErrorPassesThrough(t *testing.T) {
+	intercept := UnaryServerInterceptor(newCovenant(t), payMethods)
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Billing/Pay"}
+	want := status.Error(codes.NotFound, "no such invoice")
+
+	_, err := intercept(context.Background(), newPay("inv_1", 5), info, func(context.Context, any) (any, error) {
+		return nil, want
+	})
+	if !errors.Is(err, want) {
+		t.Errorf("expected the handler's error, got %v", err)
+	}
+}
+
+func TestUnaryServerInterceptor_unmappedMethodPassesThrough(t *testing.T) {
+	intercept := UnaryServerInterceptor(newCovenant(t), payMethods)
+	out, err := intercept(context.Background(), "anything", &grpc.UnaryServerInfo{FullMethod: "/test.Billing/Health"},
+		func(context.Context, any) (any, error) { return "ok", nil })
+	if err != nil || out != "ok" {
+		t.Errorf("expected pass-through, got %v, %v", out, err)
+	}
+}
+
+// fakeStream delivers msgs to RecvMsg in order.
+type fakeStream struct {
+	grpc.ServerStream
+	msgs []proto.Message
+}
+
+func (s *fakeStream) Context() context.Context { return context.Background() }
+
+func (s *fakeStream) RecvMsg(m any) error {
+	if len(s.msgs) == 0 {
+		return errors.New("EOF")
+	}
+	proto.Merge(m.(proto.Message), s.msgs[0])
+	s.msgs = s.msgs[1:]
+	return nil
+}
+
+func TestStreamServerInterceptor_checksFirstMessage(t *testing.T) {
+	intercept := StreamServerInterceptor(newCovenant(t), payMethods)
+	info := &grpc.StreamServerInfo{FullMethod: "/test.Billing/Pay", IsClientStream: true}
+	md := payRequest()
+
+	for _, tc := range []struct {
+		amount float64
+		code   codes.Code
+	}{{50, codes.OK}, {500, codes.FailedPrecondition}} {
+		ss := &fakeStream{msgs: []proto.Message{newPay("inv_1", tc.amount), newPay("inv_2", 1000)}}
+		received := 0
+		err := intercept(nil, ss, info, func(_ any, stream grpc.ServerStream) error {
+			for {
+				if err := stream.RecvMsg(dynamicpb.NewMessage(md)); err != nil {
+					if status.Code(err) != codes.Unknown {
+						return err
+					}
+					return nil // EOF
+				}
+				received++
+			}
+		})
+		if status.Code(err) != tc.code {
+			t.Errorf("amount %v: expected %v, got %v", tc.amount, tc.code, err)
+		}
+		if tc.code == codes.OK && received != 2 {
+			t.Errorf("amount %v: expected both messages, got %d", tc.amount, received)
+		}
+	}
+}
+
+func TestInput_fieldPaths(t *testing.T) {
+	msg := newPay("inv_1", 42)
+	in, err := input(msg, map[string]string{
+		"invoice.id":     "invoice_id",
+		"amount.value":   "amount.value",
+		"payment.amount": "amount",
+		"tags":           "tags",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	money, _ := in["payment.amount"].(map[string]any)
+	tags, _ := in["tags"].([]any)
+	if in["invoice.id"] != "inv_1" || in["amount.value"] != 42.0 || money["currency"] != "USD" || len(tags) != 1 {
+		t.Errorf("unexpected input %v", in)
+	}
+
+	if _, err := input(msg, map[string]string{"x": "invoice_id.nested"}); err == nil {
+		t.Error("expected error for a path through a scalar")
+	}
+	if _, err := input(msg, map[string]string{"x": "missing"}); err == nil {
+		t.Error("expected error for an unknown field")
+	}
+	empty := dynamicpb.NewMessage(payRequest())
+	if in, _ := input(empty, map[string]string{"payment.amount": "amount"}); len(in) != 0 {
+		t.Errorf("unset message field must be absent, got %v", in)
+	}
+}
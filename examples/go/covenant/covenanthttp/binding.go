@@ -0,0 +1,161 @@
+package covenanthttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"covenant-poc/covenant/binding"
+)
+
+// Binding sources.
+const (
+	FromPath   = "path"   // a wildcard of the route pattern
+	FromQuery  = "query"  // a URL query parameter
+	FromHeader = "header" // a request header
+	FromBody   = "body"   // a JSON pointer into a JSON request body
+)
+
+// Binding types for values read from the path, query or headers, which are
+// strings on the wire. Body values keep their JSON type.
+const (
+	TypeString = "string"
+	TypeNumber = "number"
+	TypeBool   = "bool"
+)
+
+// Binding locates one input fact in an HTTP request.
+type Binding struct {
+	From string `json:"from"`
+	// Name is the wildcard, parameter or header name, or for the body an
+	// RFC 6901 JSON pointer ("" for the whole body).
+	Name string `json:"name"`
+	// Type converts a path, query or header value; empty means string.
+	Type string `json:"type,omitempty"`
+}
+
+// Path binds a path wildcard.
+func Path(name string) Binding { return Binding{From: FromPath, Name: name} }
+
+// Query binds a query parameter.
+func Query(name string) Binding { return Binding{From: FromQuery, Name: name} }
+
+// Header binds a request header.
+func Header(name string) Binding { return Binding{From: FromHeader, Name: name} }
+
+// Body binds the value at a JSON pointer in the request body.
+func Body(pointer string) Binding { return Binding{From: FromBody, Name: pointer} }
+
+// Number converts the bound string to a number.
+func (b Binding) Number() Binding { b.Type = TypeNumber; return b }
+
+// Bool converts the bound string to a boolean.
+func (b Binding) Bool() Binding { b.Type = TypeBool; return b }
+
+// validate reports a malformed binding.
+func (b Binding) validate() error {
+	switch b.From {
+	case FromPath, FromQuery, FromHeader:
+		if b.Name == "" {
+			return fmt.Errorf("%s binding needs a name", b.From)
+		}
+		switch b.Type {
+		case "", TypeString, TypeNumber, TypeBool:
+		default:
+			return fmt.Errorf("unknown binding type %q", b.Type)
+		}
+	case FromBody:
+		if b.Name != "" && !strings.HasPrefix(b.Name, "/") {
+			return fmt.Errorf("body pointer %q must be empty or start with /", b.Name)
+		}
+		if b.Type != "" {
+			return fmt.Errorf("body bindings keep their JSON type")
+		}
+	default:
+		return fmt.Errorf("unknown binding source %q", b.From)
+	}
+	return nil
+}
+
+// extract returns the bound value, and false if the request does not carry
+// it. body is the decoded request body, or nil.
+func (b Binding) extract(r *http.Request, body any) (any, bool, error) {
+	var raw string
+	switch b.From {
+	case FromPath:
+		raw = r.PathValue(b.Name)
+		if raw == "" {
+			return nil, false, nil
+		}
+	case FromQuery:
+		q := r.URL.Query()
+		if !q.Has(b.Name) {
+			return nil, false, nil
+		}
+		raw = q.Get(b.Name)
+	case FromHeader:
+		if _, ok := r.Header[http.CanonicalHeaderKey(b.Name)]; !ok {
+			return nil, false, nil
+		}
+		raw = r.Header.Get(b.Name)
+	case FromBody:
+		v, ok := binding.Pointer(body, b.Name)
+		return v, ok, nil
+	}
+
+	switch b.Type {
+	case TypeNumber:
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, false, fmt.Errorf("%s %q: %q is not a number", b.From, b.Name, raw)
+		}
+		return n, true, nil
+	case TypeBool:
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, false, fmt.Errorf("%s %q: %q is not a boolean", b.From, b.Name, raw)
+		}
+		return v, true, nil
+	}
+	return raw, true, nil
+}
+
+// decodeBody decodes a JSON body, keeping numbers as float64 as the engine
+// expects.
+func decodeBody(data []byte) (any, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("request body is not JSON: %w", err)
+	}
+	return v, nil
+}
+
+// FromSpec returns the HTTP routes of a binding spec.
+func FromSpec(spec *binding.Spec) Routes {
+	routes := make(Routes, len(spec.HTTP))
+	for pattern, r := range spec.HTTP {
+		route := Route{Operation: r.Operation, Input: make(map[string]Binding, len(r.Input))}
+		for fact, src := range r.Input {
+			var b Binding
+			switch {
+			case src.Path != "":
+				b = Path(src.Path)
+			case src.Query != "":
+				b = Query(src.Query)
+			case src.Header != "":
+				b = Header(src.Header)
+			case src.Body != nil:
+				b = Body(*src.Body)
+			}
+			b.Type = src.Type
+			route.Input[fact] = b
+		}
+		routes[pattern] = route
+	}
+	return routes
+}
@@ -0,0 +1,183 @@
+// Package covenanthttp enforces contracts on an existing net/http service.
+//
+// Middleware maps routes to contract operations. For a matching request it
+// extracts the operation's input facts from the path, query, headers and JSON
+// body as the route's bindings declare, and evaluates the operation with the
+// wrapped handler as its action: the handler runs only if the contract
+// allows the request. Otherwise the middleware answers itself with the
+// engine's response, whose error envelope carries the contract's code,
+// message and HTTP status. Requests on unmapped routes pass straight through.
+//
+//	mw := covenanthttp.Middleware(cov, covenanthttp.Routes{
+//		"POST /invoices/{id}/pay": {
+//			Operation: "ProcessPayment",
+//			Input: map[string]covenanthttp.Binding{
+//				"invoice.id":     covenanthttp.Path("id"),
+//				"customer.id":    covenanthttp.Header("X-Customer-ID"),
+//				"payment.amount": covenanthttp.Body("/amount"),
+//			},
+//		},
+//	})
+//	http.ListenAndServe(":8080", mw(existingMux))
+package covenanthttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"covenant-poc/covenant"
+	"covenant-poc/executor/engine"
+)
+
+// Route maps one route to a contract operation.
+type Route struct {
+	Operation string `json:"operation"`
+	// Input maps each input fact to where it is found in the request.
+	Input map[string]Binding `json:"input"`
+}
+
+// Routes maps net/http ServeMux patterns, such as "POST /invoices/{id}/pay",
+// to the operations they perform.
+type Routes map[string]Route
+
+// maxBody bounds the request body read for body bindings.
+const maxBody = 1 << 20
+
+// Middleware returns middleware enforcing cov's contract on routes. It panics
+// if a pattern or binding is malformed, as http.ServeMux.Handle does.
+func Middleware(cov *covenant.Covenant, routes Routes) func(http.Handler) http.Handler {
+	for pattern, route := range routes {
+		if route.Operation == "" {
+			panic(fmt.Sprintf("covenanthttp: %s: no operation", pattern))
+		}
+		for fact, b := range route.Input {
+			if err := b.validate(); err != nil {
+				panic(fmt.Sprintf("covenanthttp: %s: %s: %v", pattern, fact, err))
+			}
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		mux := http.NewServeMux()
+		for pattern, route := range routes {
+			mux.Handle(pattern, &guard{cov: cov, route: route, next: next})
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, pattern := mux.Handler(r); pattern == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			mux.ServeHTTP(w, r)
+		})
+	}
+}
+
+// guard enforces one route.
+type guard struct {
+	cov   *covenant.Covenant
+	route Route
+	next  http.Handler
+}
+
+func (g *guard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	input, env := g.input(r)
+	if env != nil {
+		writeResponse(w, &engine.Response{Outcome: engine.OutcomeSystemError, Error: env})
+		return
+	}
+
+	ran := false
+	ctx := covenant.WithAction(r.Context(), func(ctx context.Context) (map[string]any, error) {
+		ran = true
+		g.next.ServeHTTP(w, r.WithContext(ctx))
+		return nil, nil
+	})
+	resp, err := g.cov.Evaluate(ctx, &engine.Request{
+		Operation:      g.route.Operation,
+		Input:          input,
+		IdempotencyKey: r.Header.Get("Idempotency-Key"),
+	})
+	if err != nil {
+		log.Printf("covenanthttp: %s: %v", g.route.Operation, err)
+		http.Error(w, "contract evaluation failed", http.StatusInternalServerError)
+		return
+	}
+	if !ran {
+		// Denied, escalated or failed — or an idempotent replay of an
+		// executed request, which the handler must not serve twice.
+		writeResponse(w, resp)
+	}
+}
+
+// input extracts the route's input facts, or returns the envelope to reject
+// the request with.
+func (g *guard) input(r *http.Request) (map[string]any, *engine.ErrorEnvelope) {
+	var body any
+	if g.needsBody() && r.Body != nil {
+		data, err := io.ReadAll(io.LimitReader(r.Body, maxBody+1))
+		if err != nil {
+			return nil, invalidInput(fmt.Sprintf("read request body: %v", err))
+		}
+		if len(data) > maxBody {
+			return nil, invalidInput("request body too large for contract bindings")
+		}
+		// The handler reads the body again if the request is allowed.
+		r.Body = io.NopCloser(bytes.NewReader(data))
+		if body, err = decodeBody(data); err != nil {
+			return nil, invalidInput(err.Error())
+		}
+	}
+
+	input := make(map[string]any, len(g.route.Input))
+	for fact, b := range g.route.Input {
+		v, ok, err := b.extract(r, body)
+		if err != nil {
+			return nil, invalidInput(err.Error())
+		}
+		if ok {
+			input[fact] = v
+		}
+	}
+	return input, nil
+}
+
+func (g *guard) needsBody() bool {
+	for _, b := range g.route.Input {
+		if b.From == FromBody {
+			return true
+		}
+	}
+	return false
+}
+
+func invalidInput(msg string) *engine.ErrorEnvelope {
+	return &engine.ErrorEnvelope{
+		Code:       "INVALID_INPUT",
+		Message:    msg,
+		HttpStatus: http.StatusBadRequest,
+		Category:   "validation",
+		Retryable:  false,
+	}
+}
+
+// writeResponse answers with the engine's response, using the status of its
+// error envelope. Escalations are 202 Accepted.
+func writeResponse(w http.ResponseWriter, resp *engine.Response) {
+	status := http.StatusOK
+	switch {
+	case resp.Error != nil && resp.Error.HttpStatus != 0:
+		status = resp.Error.HttpStatus
+	case resp.Error != nil:
+		status = http.StatusInternalServerError
+	case resp.Outcome == engine.OutcomeEscalated:
+		status = http.StatusAccepted
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
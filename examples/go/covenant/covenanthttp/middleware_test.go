@@ -0,0 +1,185 @@
+package covenanthttp
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"covenant-poc/covenant"
+	"covenant-poc/covenant/binding"
+	"covenant-poc/executor/engine"
+)
+
+// newCovenant denies Pay above 100 and escalates Pay for flagged customers.
+func newCovenant(t *testing.T) *covenant.Covenant {
+	t.Helper()
+	cov, err := covenant.New(covenant.Static(&engine.Contract{
+		Facts: map[string]engine.FactDef{
+			"invoice.id":  {Source: "input", Required: true},
+			"amount":      {Source: "input"},
+			"customer.id": {Source: "input"},
+		},
+		Rules: []engine.RuleDef{
+			{
+				ID:        "limit",
+				AppliesTo: []string{"Pay"},
+				When:      engine.Condition{Fact: "amount", GreaterThan: 100.0},
+				Verdict: engine.VerdictDef{Deny: &engine.DenyVerdict{
+					Code:  "LIMIT",
+					Error: engine.ErrorEnvelope{Code: "LIMIT", Message: "over limit", HttpStatus: 402},
+				}},
+			},
+			{
+				ID:        "review",
+				AppliesTo: []string{"Pay"},
+				When:      engine.Condition{Fact: "customer.id", Equals: "cust_flagged"},
+				Verdict:   engine.VerdictDef{Escalate: &engine.EscalateVerdict{Queue: "review"}},
+			},
+		},
+		Operations: map[string]engine.OperationDef{"Pay": {ConstrainedBy: []string{"limit", "review"}}},
+	}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cov
+}
+
+var payRoutes = Routes{
+	"POST /invoices/{id}/pay": {
+		Operation: "Pay",
+		Input: map[string]Binding{
+			"invoice.id":  Path("id"),
+			"amount":      Body("/amount"),
+			"customer.id": Header("X-Customer-ID"),
+		},
+	},
+}
+
+// payHandler is the existing handler; it echoes the body it reads.
+func payHandler(calls *int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	})
+}
+
+func serve(h http.Handler, method, target, body string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMiddleware_allowedRequestReachesHandler(t *testing.T) {
+	var calls int
+	h := Middleware(newCovenant(t), payRoutes)(payHandler(&calls))
+
+	rec := serve(h, "POST", "/invoices/inv_1/pay", `{"amount": 50}`, nil)
+	if rec.Code != 200 || calls != 1 {
+		t.Fatalf("expected handler to run, got %d after %d calls", rec.Code, calls)
+	}
+	if rec.Body.String() != `{"amount": 50}` {
+		t.Errorf("handler did not see the original body, got %q", rec.Body.String())
+	}
+}
+
+func TestMiddleware_denyShortCircuitsWithEnvelope(t *testing.T) {
+	var calls int
+	h := Middleware(newCovenant(t), payRoutes)(payHandler(&calls))
+
+	rec := serve(h, "POST", "/invoices/inv_1/pay", `{"amount": 500}`, nil)
+	if rec.Code != 402 || calls != 0 {
+		t.Fatalf("expected 402 without calling the handler, got %d after %d calls", rec.Code, calls)
+	}
+	var resp engine.Response
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if resp.Outcome != "denied" || resp.Error.Code != "LIMIT" || resp.InvocationID == "" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+}
+
+func TestMiddleware_escalationIsAccepted(t *testing.T) {
+	var calls int
+	h := Middleware(newCovenant(t), payRoutes)(payHandler(&calls))
+
+	rec := serve(h, "POST", "/invoices/inv_1/pay", `{"amount": 5}`, map[string]string{"X-Customer-ID": "cust_flagged"})
+	if rec.Code != http.StatusAccepted || calls != 0 {
+		t.Errorf("expected 202 without calling the handler, got %d after %d calls", rec.Code, calls)
+	}
+}
+
+func TestMiddleware_unmappedRoutesPassThrough(t *testing.T) {
+	var calls int
+	h := Middleware(newCovenant(t), payRoutes)(payHandler(&calls))
+
+	serve(h, "GET", "/invoices/inv_1/pay", "", nil)
+	serve(h, "GET", "/healthz", "", nil)
+	if calls != 2 {
+		t.Errorf("expected both requests to pass through, got %d calls", calls)
+	}
+}
+
+func TestMiddleware_badInputIsRejected(t *testing.T) {
+	var calls int
+	routes := Routes{"GET /pay": {Operation: "Pay", Input: map[string]Binding{
+		"invoice.id": Query("invoice"),
+		"amount":     Query("amount").Number(),
+	}}}
+	h := Middleware(newCovenant(t), routes)(payHandler(&calls))
+
+	rec := serve(h, "GET", "/pay?invoice=inv_1&amount=lots", "", nil)
+	if rec.Code != 400 || calls != 0 || !strings.Contains(rec.Body.String(), "INVALID_INPUT") {
+		t.Errorf("expected INVALID_INPUT, got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := serve(h, "GET", "/pay?invoice=inv_1&amount=20", "", nil); rec.Code != 200 || calls != 1 {
+		t.Errorf("expected numeric query binding to pass, got %d", rec.Code)
+	}
+}
+
+func TestMiddleware_malformedBindingPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic")
+		}
+	}()
+	Middleware(newCovenant(t), Routes{"GET /x": {Operation: "Pay", Input: map[string]Binding{
+		"amount": {From: "cookie", Name: "amount"},
+	}}})
+}
+
+func TestFromSpec(t *testing.T) {
+	spec, err := binding.Parse([]byte(`http: "POST /pay/{id}": {
+	operation: "Pay"
+	input: {
+		"invoice.id":     {path: "id"}
+		"payment.amount": {query: "amount", type: "number"}
+		"customer.id":    {header: "X-Customer-ID"}
+		"note":           {body: ""}
+	}
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := FromSpec(spec)["POST /pay/{id}"]
+	want := map[string]Binding{
+		"invoice.id":     Path("id"),
+		"payment.amount": Query("amount").Number(),
+		"customer.id":    Header("X-Customer-ID"),
+		"note":           Body(""),
+	}
+	if got.Operation != "Pay" || len(got.Input) != len(want) {
+		t.Fatalf("unexpected route %+v", got)
+	}
+	for fact, b := range want {
+		if got.Input[fact] != b {
+			t.Errorf("%s: expected %+v, got %+v", fact, b, got.Input[fact])
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package covenantlambda
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// proxyEvent holds the fields of the API Gateway REST (1.0) and HTTP API
+// (2.0) payload formats and of Application Load Balancer events that HTTP
+// reads.
+type proxyEvent struct {
+	Version string `json:"version"`
+
+	// 1.0 and ALB
+	HTTPMethod                      string              `json:"httpMethod"`
+	Path                            string              `json:"path"`
+	QueryStringParameters           map[string]string   `json:"queryStringParameters"`
+	MultiValueQueryStringParameters map[string][]string `json:"multiValueQueryStringParameters"`
+	MultiValueHeaders               map[string][]string `json:"multiValueHeaders"`
+
+	// 2.0
+	RawPath        string   `json:"rawPath"`
+	RawQueryString string   `json:"rawQueryString"`
+	Cookies        []string `json:"cookies"`
+
+	Headers         map[string]string `json:"headers"`
+	Body            string            `json:"body"`
+	IsBase64Encoded bool              `json:"isBase64Encoded"`
+	RequestContext  struct {
+		ELB      *json.RawMessage `json:"elb"`
+		Identity struct {
+			SourceIP string `json:"sourceIp"`
+		} `json:"identity"`
+		HTTP struct {
+			Method   string `json:"method"`
+			SourceIP string `json:"sourceIp"`
+		} `json:"http"`
+	} `json:"requestContext"`
+}
+
+// proxyResponse answers a proxyEvent in the same format.
+type proxyResponse struct {
+	StatusCode        int                 `json:"statusCode"`
+	StatusDescription string              `json:"statusDescription,omitempty"`
+	Headers           map[string]string   `json:"headers,omitempty"`
+	MultiValueHeaders map[string][]string `json:"multiValueHeaders,omitempty"`
+	Cookies           []string            `json:"cookies,omitempty"`
+	Body              string              `json:"body"`
+	IsBase64Encoded   bool                `json:"isBase64Encoded"`
+}
+
+// HTTP answers API Gateway proxy events, of REST or HTTP APIs, and
+// Application Load Balancer events by serving them with h, for instance a
+// mux guarded by the covenanthttp middleware. Binary bodies are passed
+// base64-encoded both ways.
+func HTTP(h http.Handler) Handler {
+	return func(ctx context.Context, event json.RawMessage) (any, error) {
+		var ev proxyEvent
+		if err := json.Unmarshal(event, &ev); err != nil {
+			return nil, fmt.Errorf("decode proxy event: %w", err)
+		}
+		req, err := ev.request(ctx)
+		if err != nil {
+			return nil, err
+		}
+		w := &responseWriter{header: http.Header{}}
+		h.ServeHTTP(w, req)
+		return ev.response(w), nil
+	}
+}
+
+// request builds the http.Request the event describes.
+func (ev *proxyEvent) request(ctx context.Context) (*http.Request, error) {
+	body := []byte(ev.Body)
+	if ev.IsBase64Encoded {
+		var err error
+		if body, err = base64.StdEncoding.DecodeString(ev.Body); err != nil {
+			return nil, fmt.Errorf("decode proxy event body: %w", err)
+		}
+	}
+	u := &url.URL{}
+	method, remote := ev.HTTPMethod, ev.RequestContext.Identity.SourceIP
+	header := http.Header{}
+	if ev.Version == "2.0" {
+		method, remote = ev.RequestContext.HTTP.Method, ev.RequestContext.HTTP.SourceIP
+		var err error
+		if u, err = url.Parse(ev.RawPath); err != nil {
+			return nil, fmt.Errorf("proxy event: %w", err)
+		}
+		u.RawQuery = ev.RawQueryString
+		for k, v := range ev.Headers {
+			header.Set(k, v)
+		}
+		if len(ev.Cookies) > 0 {
+			header.Set("Cookie", strings.Join(ev.Cookies, "; "))
+		}
+	} else {
+		u.Path = ev.Path
+		query := ev.MultiValueQueryStringParameters
+		if query == nil {
+			query = map[string][]string{}
+			for k, v := range ev.QueryStringParameters {
+				query[k] = []string{v}
+			}
+		}
+		if ev.RequestContext.ELB != nil {
+			// Load balancers pass the query as received, still escaped.
+			var params []string
+			for _, k := range slices.Sorted(maps.Keys(query)) {
+				for _, v := range query[k] {
+					params = append(params, k+"="+v)
+				}
+			}
+			u.RawQuery = strings.Join(params, "&")
+		} else {
+			u.RawQuery = url.Values(query).Encode()
+		}
+		for k, v := range ev.Headers {
+			header.Set(k, v)
+		}
+		for k, vs := range ev.MultiValueHeaders {
+			header[http.CanonicalHeaderKey(k)] = vs
+		}
+	}
+	u.Host = header.Get("Host")
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("proxy event: %w", err)
+	}
+	req.Header = header
+	req.RemoteAddr = remote
+	req.RequestURI = u.RequestURI()
+	return req, nil
+}
+
+// response answers the event with what the handler wrote.
+func (ev *proxyEvent) response(w *responseWriter) *proxyResponse {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	out := &proxyResponse{StatusCode: w.status}
+	if utf8.Valid(w.body.Bytes()) {
+		out.Body = w.body.String()
+	} else {
+		out.Body, out.IsBase64Encoded = base64.StdEncoding.EncodeToString(w.body.Bytes()), true
+	}
+	switch {
+	case ev.Version == "2.0":
+		out.Cookies = w.header.Values("Set-Cookie")
+		w.header.Del("Set-Cookie")
+		out.Headers = map[string]string{}
+		for k, vs := range w.header {
+			out.Headers[k] = strings.Join(vs, ",")
+		}
+	case ev.MultiValueHeaders != nil:
+		out.MultiValueHeaders = w.header
+	default:
+		out.Headers = map[string]string{}
+		for k, vs := range w.header {
+			out.Headers[k] = vs[len(vs)-1]
+		}
+	}
+	if ev.RequestContext.ELB != nil {
+		out.StatusDescription = fmt.Sprintf("%d %s", w.status, http.StatusText(w.status))
+	}
+	return out
+}
+
+// responseWriter buffers a response for the event's answer.
+type responseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *responseWriter) Header() http.Header { return w.header }
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(p)
+}
@@ -0,0 +1,173 @@
+// Package covenantlambda runs contracts in AWS Lambda and other
+// function-as-a-service platforms, for teams that do not run long-lived
+// executors.
+//
+// A function creates its Covenant once, while the execution environment
+// initializes, and then serves invocations with Start:
+//
+//	func main() {
+//		cfg, err := covenantlambda.ParseS3URL(os.Getenv("COVENANT_CONTRACTS"))
+//		...
+//		cov, err := covenant.New(covenantlambda.S3(cfg), registry)
+//		if err != nil {
+//			log.Fatal(err)
+//		}
+//		covenantlambda.Start(covenantlambda.Reloading(cov, time.Minute,
+//			covenantlambda.HTTP(covenanthttp.Middleware(cov, routes)(mux))))
+//	}
+//
+// HTTP serves API Gateway proxy events (REST and HTTP APIs) and Application
+// Load Balancer events with an http.Handler, such as one guarded by the
+// covenanthttp middleware; Evaluate answers direct invocations, from the
+// SDK, Step Functions or another function, carrying a covenant.Request.
+//
+// S3 loads the contract from a bucket and keeps what it compiles as an
+// engine snapshot, so a warm start never compiles twice and, with
+// ShareSnapshots, a cold start reads the snapshot instead of compiling.
+// Lambda freezes an environment between invocations, so Reloading checks for
+// contract changes as invocations arrive rather than on a timer.
+package covenantlambda
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"covenant-poc/covenant"
+	"covenant-poc/executor/engine"
+)
+
+// Handler answers one invocation's event with a value to encode as JSON.
+type Handler func(ctx context.Context, event json.RawMessage) (any, error)
+
+// Evaluator decides requests; both *covenant.Covenant and *engine.Engine
+// are Evaluators.
+type Evaluator interface {
+	Evaluate(ctx context.Context, req *engine.Request) (*engine.Response, error)
+}
+
+// Evaluate answers invocations whose event is a covenant.Request with the
+// covenant.Response.
+func Evaluate(ev Evaluator) Handler {
+	return func(ctx context.Context, event json.RawMessage) (any, error) {
+		var req engine.Request
+		if err := json.Unmarshal(event, &req); err != nil {
+			return nil, fmt.Errorf("decode request: %w", err)
+		}
+		return ev.Evaluate(ctx, &req)
+	}
+}
+
+// Reloading reloads cov's contract before an invocation if it was last
+// loaded more than every ago. A failed reload is logged and the invocation
+// is served by the contract already loaded.
+func Reloading(cov *covenant.Covenant, every time.Duration, h Handler) Handler {
+	var mu sync.Mutex
+	last := time.Now()
+	return func(ctx context.Context, event json.RawMessage) (any, error) {
+		mu.Lock()
+		if time.Since(last) >= every {
+			last = time.Now()
+			if err := cov.Reload(ctx); err != nil {
+				log.Printf("covenantlambda: %v", err)
+			}
+		}
+		mu.Unlock()
+		return h(ctx, event)
+	}
+}
+
+type requestIDKey struct{}
+
+// RequestID returns the AWS request ID of the invocation ctx belongs to.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Start serves invocations with h through the Lambda runtime API, for a
+// function built on a provided.* (OS-only) runtime. It does not return.
+func Start(h Handler) {
+	log.Fatal(Serve(context.Background(), os.Getenv("AWS_LAMBDA_RUNTIME_API"), h))
+}
+
+// runtimeError is the body of an invocation error.
+type runtimeError struct {
+	Message string `json:"errorMessage"`
+	Type    string `json:"errorType"`
+}
+
+// Serve serves invocations from the runtime API at api, a host:port, until
+// ctx is done or the runtime API fails.
+func Serve(ctx context.Context, api string, h Handler) error {
+	if api == "" {
+		return fmt.Errorf("covenantlambda: AWS_LAMBDA_RUNTIME_API is not set; not running in Lambda")
+	}
+	base := "http://" + api + "/2018-06-01/runtime/invocation/"
+	// The next invocation is long-polled, so requests have no timeout.
+	client := &http.Client{}
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"next", nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("covenantlambda: next invocation: %w", err)
+		}
+		event, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil || resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("covenantlambda: next invocation: %s %v", resp.Status, err)
+		}
+		id := resp.Header.Get("Lambda-Runtime-Aws-Request-Id")
+		os.Setenv("_X_AMZN_TRACE_ID", resp.Header.Get("Lambda-Runtime-Trace-Id"))
+
+		invocation := context.WithValue(ctx, requestIDKey{}, id)
+		cancel := func() {}
+		if ms, err := strconv.ParseInt(resp.Header.Get("Lambda-Runtime-Deadline-Ms"), 10, 64); err == nil {
+			invocation, cancel = context.WithDeadline(invocation, time.UnixMilli(ms))
+		}
+		out, err := invoke(invocation, h, event)
+		cancel()
+
+		path, body := base+id+"/response", out
+		if err != nil {
+			typ := strings.TrimPrefix(fmt.Sprintf("%T", err), "*")
+			body, _ = json.Marshal(runtimeError{Message: err.Error(), Type: typ})
+			path = base + id + "/error"
+		}
+		post, err := client.Post(path, "application/json", bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("covenantlambda: answer invocation %s: %w", id, err)
+		}
+		io.Copy(io.Discard, post.Body)
+		post.Body.Close()
+		if post.StatusCode != http.StatusAccepted {
+			log.Printf("covenantlambda: answer invocation %s: %s", id, post.Status)
+		}
+	}
+}
+
+// invoke runs h, reporting a panic as the invocation's error.
+func invoke(ctx context.Context, h Handler, event []byte) (out []byte, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	v, err := h(ctx, event)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
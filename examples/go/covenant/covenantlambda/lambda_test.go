@@ -0,0 +1,173 @@
+package covenantlambda
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"covenant-poc/covenant"
+	"covenant-poc/executor/engine"
+)
+
+// fakeRuntime is the Lambda runtime API, handing out events in order and
+// collecting the answers.
+type fakeRuntime struct {
+	events  chan string
+	answers chan answer
+}
+
+type answer struct {
+	id, kind, body string
+}
+
+func (rt *fakeRuntime) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/2018-06-01/runtime/invocation/")
+	if rest == "next" {
+		select {
+		case ev := <-rt.events:
+			id, body, _ := strings.Cut(ev, " ")
+			w.Header().Set("Lambda-Runtime-Aws-Request-Id", id)
+			w.Header().Set("Lambda-Runtime-Deadline-Ms", "4102444800000")
+			io.WriteString(w, body)
+		case <-r.Context().Done():
+		}
+		return
+	}
+	id, kind, _ := strings.Cut(rest, "/")
+	body, _ := io.ReadAll(r.Body)
+	rt.answers <- answer{id, kind, string(body)}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func serveRuntime(t *testing.T, h Handler) *fakeRuntime {
+	t.Helper()
+	rt := &fakeRuntime{events: make(chan string, 4), answers: make(chan answer, 4)}
+	srv := httptest.NewServer(rt)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	t.Cleanup(func() { cancel(); <-done; srv.Close() })
+	go func() {
+		defer close(done)
+		Serve(ctx, strings.TrimPrefix(srv.URL, "http://"), h)
+	}()
+	return rt
+}
+
+func (rt *fakeRuntime) invoke(t *testing.T, id, event string) answer {
+	t.Helper()
+	rt.events <- id + " " + event
+	select {
+	case a := <-rt.answers:
+		return a
+	case <-time.After(5 * time.Second):
+		t.Fatal("no answer to the invocation")
+		return answer{}
+	}
+}
+
+func newCovenant(t *testing.T) *covenant.Covenant {
+	t.Helper()
+	cov, err := covenant.New(covenant.Static(&engine.Contract{
+		Facts: map[string]engine.FactDef{"amount": {Source: "input", Required: true}},
+		Rules: []engine.RuleDef{{
+			ID:        "limit",
+			AppliesTo: []string{"Pay"},
+			When:      engine.Condition{Fact: "amount", GreaterThan: 100.0},
+			Verdict: engine.VerdictDef{Deny: &engine.DenyVerdict{
+				Code:  "LIMIT",
+				Error: engine.ErrorEnvelope{Code: "LIMIT", Message: "over limit", HttpStatus: 402},
+			}},
+		}},
+		Operations: map[string]engine.OperationDef{"Pay": {ConstrainedBy: []string{"limit"}}},
+	}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cov
+}
+
+func TestServe_answersInvocations(t *testing.T) {
+	rt := serveRuntime(t, func(ctx context.Context, event json.RawMessage) (any, error) {
+		if _, ok := ctx.Deadline(); !ok || RequestID(ctx) == "" {
+			t.Error("expected the invocation's deadline and request ID")
+		}
+		switch string(event) {
+		case `"fail"`:
+			return nil, errors.New("failed")
+		case `"panic"`:
+			panic("boom")
+		}
+		return map[string]string{"echo": string(event)}, nil
+	})
+
+	if a := rt.invoke(t, "r1", `"hi"`); a.id != "r1" || a.kind != "response" || a.body != `{"echo":"\"hi\""}` {
+		t.Errorf("unexpected answer %+v", a)
+	}
+	if a := rt.invoke(t, "r2", `"fail"`); a.kind != "error" || !strings.Contains(a.body, `"errorMessage":"failed"`) {
+		t.Errorf("expected an error answer, got %+v", a)
+	}
+	if a := rt.invoke(t, "r3", `"panic"`); a.kind != "error" || !strings.Contains(a.body, "panic: boom") {
+		t.Errorf("expected the panic reported, got %+v", a)
+	}
+	if a := rt.invoke(t, "r4", `"again"`); a.kind != "response" {
+		t.Errorf("expected invocations served after a panic, got %+v", a)
+	}
+}
+
+func TestEvaluate_decidesRequestEvents(t *testing.T) {
+	rt := serveRuntime(t, Evaluate(newCovenant(t)))
+	a := rt.invoke(t, "r1", `{"operation":"Pay","input":{"amount":500},"dry_run":true}`)
+	var resp engine.Response
+	if err := json.Unmarshal([]byte(a.body), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Outcome != engine.OutcomeWouldDeny {
+		t.Errorf("expected would_deny, got %+v", resp)
+	}
+}
+
+func TestHTTP_servesProxyEvents(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /invoices/{id}/pay", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Add("Set-Cookie", "a=1")
+		w.Header().Set("X-Echo", r.PathValue("id")+" "+r.URL.Query().Get("q")+" "+r.Header.Get("X-Customer")+" "+string(body))
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte{0xff})
+	})
+	h := HTTP(mux)
+
+	for name, event := range map[string]string{
+		"rest": `{"httpMethod":"POST","path":"/invoices/inv 1/pay","multiValueQueryStringParameters":{"q":["a b"]},
+			"headers":{"x-customer":"c1"},"multiValueHeaders":{"x-customer":["c1"]},"body":"e30=","isBase64Encoded":true,
+			"requestContext":{"identity":{"sourceIp":"10.0.0.1"}}}`,
+		"http api": `{"version":"2.0","rawPath":"/invoices/inv%201/pay","rawQueryString":"q=a+b","headers":{"x-customer":"c1"},
+			"body":"{}","requestContext":{"http":{"method":"POST","sourceIp":"10.0.0.1"}}}`,
+		"alb": `{"httpMethod":"POST","path":"/invoices/inv 1/pay","queryStringParameters":{"q":"a%20b"},
+			"headers":{"x-customer":"c1"},"body":"{}","requestContext":{"elb":{"targetGroupArn":"arn"}}}`,
+	} {
+		out, err := h(context.Background(), json.RawMessage(event))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		resp := out.(*proxyResponse)
+		echo := resp.Headers["X-Echo"]
+		if resp.MultiValueHeaders != nil {
+			echo = resp.MultiValueHeaders["X-Echo"][0]
+		}
+		if resp.StatusCode != http.StatusCreated || echo != "inv 1 a b c1 {}" || !resp.IsBase64Encoded || resp.Body != "/w==" {
+			t.Errorf("%s: unexpected response %+v", name, resp)
+		}
+	}
+
+	out, _ := h(context.Background(), json.RawMessage(`{"version":"2.0","rawPath":"/invoices/1/pay","requestContext":{"http":{"method":"POST"}}}`))
+	if resp := out.(*proxyResponse); len(resp.Cookies) != 1 || resp.Headers["Set-Cookie"] != "" {
+		t.Errorf("expected HTTP API cookies apart from the headers, got %+v", resp)
+	}
+}
@@ -0,0 +1,252 @@
+package covenantlambda
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Credentials sign S3 requests.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string // set for temporary credentials, as Lambda's are
+}
+
+// EnvCredentials reads the credentials Lambda gives a function's execution
+// role from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN.
+func EnvCredentials() Credentials {
+	return Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+}
+
+// s3Client makes the few S3 calls a source needs, signed with Signature
+// Version 4.
+type s3Client struct {
+	bucket   string
+	region   string
+	endpoint string // path-style endpoint; empty for virtual-hosted AWS URLs
+	creds    Credentials
+	http     *http.Client
+	now      func() time.Time
+}
+
+// errPreconditionFailed is a GET whose If-Match no longer matches: the
+// object changed since it was listed.
+var errPreconditionFailed = errors.New("object changed since it was listed")
+
+// errNotFound is a GET of a missing object.
+var errNotFound = errors.New("no such key")
+
+// objectURL returns the URL of key, or of the bucket if key is empty. The
+// path is escaped as it is signed.
+func (c *s3Client) objectURL(key string) *url.URL {
+	u := &url.URL{Scheme: "https", Host: c.bucket + ".s3." + c.region + ".amazonaws.com"}
+	if c.endpoint != "" {
+		u, _ = url.Parse(strings.TrimSuffix(c.endpoint, "/"))
+		key = c.bucket + "/" + key
+	}
+	u.Path += "/" + key
+	u.RawPath += "/" + uriEncode(key, false)
+	return u
+}
+
+func (c *s3Client) do(ctx context.Context, method string, u *url.URL, header http.Header, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	sign(req, body, c.creds, c.region, c.now())
+	return c.http.Do(req)
+}
+
+// object is one listed object.
+type object struct {
+	Key  string `xml:"Key"`
+	ETag string `xml:"ETag"`
+}
+
+// list returns the objects under prefix, following continuation tokens.
+func (c *s3Client) list(ctx context.Context, prefix string) ([]object, error) {
+	var out []object
+	token := ""
+	for {
+		u := c.objectURL("")
+		q := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		u.RawQuery = q.Encode()
+		resp, err := c.do(ctx, http.MethodGet, u, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Contents              []object `xml:"Contents"`
+			IsTruncated           bool     `xml:"IsTruncated"`
+			NextContinuationToken string   `xml:"NextContinuationToken"`
+		}
+		err = decodeResponse(resp, func(r io.Reader) error { return xml.NewDecoder(r).Decode(&page) })
+		if err != nil {
+			return nil, fmt.Errorf("list s3://%s/%s: %w", c.bucket, prefix, err)
+		}
+		out = append(out, page.Contents...)
+		if !page.IsTruncated {
+			return out, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+// get reads key. With an etag, it fails with errPreconditionFailed if the
+// object is no longer that version.
+func (c *s3Client) get(ctx context.Context, key, etag string) ([]byte, error) {
+	header := http.Header{}
+	if etag != "" {
+		header.Set("If-Match", etag)
+	}
+	resp, err := c.do(ctx, http.MethodGet, c.objectURL(key), header, nil)
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	err = decodeResponse(resp, func(r io.Reader) error {
+		data, err = io.ReadAll(r)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get s3://%s/%s: %w", c.bucket, key, err)
+	}
+	return data, nil
+}
+
+// put writes key.
+func (c *s3Client) put(ctx context.Context, key string, data []byte) error {
+	resp, err := c.do(ctx, http.MethodPut, c.objectURL(key), nil, data)
+	if err != nil {
+		return err
+	}
+	if err := decodeResponse(resp, func(io.Reader) error { return nil }); err != nil {
+		return fmt.Errorf("put s3://%s/%s: %w", c.bucket, key, err)
+	}
+	return nil
+}
+
+// decodeResponse decodes a successful response's body with fn and turns
+// any other into an error carrying S3's error code.
+func decodeResponse(resp *http.Response, fn func(io.Reader) error) error {
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return fn(resp.Body)
+	case http.StatusPreconditionFailed:
+		return errPreconditionFailed
+	case http.StatusNotFound:
+		return errNotFound
+	}
+	var e struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&e)
+	if e.Code == "" {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return fmt.Errorf("%s: %s: %s", resp.Status, e.Code, e.Message)
+}
+
+// sign adds a Signature Version 4 Authorization header for S3 to req,
+// signing its host, the x-amz-* headers it adds and any headers already set.
+func sign(req *http.Request, body []byte, creds Credentials, region string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	payload := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payload[:]))
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.Join(v, ",")
+	}
+	names := slices.Sorted(maps.Keys(headers))
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + strings.TrimSpace(headers[k]) + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	query := req.URL.Query()
+	var params []string
+	for _, k := range slices.Sorted(maps.Keys(query)) {
+		for _, v := range query[k] {
+			params = append(params, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonical := strings.Join([]string{
+		req.Method,
+		path,
+		strings.Join(params, "&"),
+		canonicalHeaders.String(),
+		signed,
+		hex.EncodeToString(payload[:]),
+	}, "\n")
+
+	scope := now.Format("20060102") + "/" + region + "/s3/aws4_request"
+	hashed := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+	key := []byte("AWS4" + creds.SecretAccessKey)
+	for _, part := range []string{now.Format("20060102"), region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s,SignedHeaders=%s,Signature=%x",
+		creds.AccessKeyID, scope, signed, hmacSHA256(key, toSign)))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// uriEncode percent-encodes s as Signature Version 4 requires: everything
+// but unreserved characters, and '/' too if slash is set.
+func uriEncode(s string, slash bool) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !slash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,226 @@
+package covenantlambda
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"covenant-poc/covenant"
+	"covenant-poc/executor/engine"
+)
+
+// S3Config says where an S3 source finds its contract.
+type S3Config struct {
+	Bucket string
+	// Prefix holds one directory per domain, as the contracts directory
+	// does, e.g. contracts/ with contracts/billing/payments.cue.
+	Prefix string
+	Domain string // the domain to serve; others are bases it may import
+	// Region defaults to AWS_REGION, which Lambda sets.
+	Region string
+	// Endpoint is an S3-compatible endpoint addressed path-style, such as
+	// a MinIO server; empty for AWS.
+	Endpoint string
+	// Credentials default to EnvCredentials.
+	Credentials *Credentials
+	// CacheDir keeps compiled contracts as engine snapshots; it defaults
+	// to the system temporary directory, which Lambda keeps for the life
+	// of an execution environment.
+	CacheDir string
+	// ShareSnapshots also stores compiled contracts in the bucket, under
+	// <prefix>.snapshots/, so a cold start reads the snapshot another
+	// environment wrote instead of fetching and compiling the files. The
+	// function's role needs s3:PutObject on that prefix.
+	ShareSnapshots bool
+}
+
+// ParseS3URL parses a contract source URL,
+// s3://<bucket>/<prefix>?domain=<domain>, e.g.
+// s3://acme-contracts/contracts?domain=billing.
+func ParseS3URL(raw string) (S3Config, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return S3Config{}, err
+	}
+	cfg := S3Config{Bucket: u.Host, Prefix: strings.Trim(u.Path, "/"), Domain: u.Query().Get("domain")}
+	switch {
+	case u.Scheme != "s3":
+		return S3Config{}, fmt.Errorf("%s: not an s3:// URL", raw)
+	case cfg.Bucket == "":
+		return S3Config{}, fmt.Errorf("%s: no bucket", raw)
+	case cfg.Domain == "":
+		return S3Config{}, fmt.Errorf("%s: no ?domain=", raw)
+	}
+	if cfg.Prefix != "" {
+		cfg.Prefix += "/"
+	}
+	return cfg, nil
+}
+
+// S3Source compiles a domain's contract from the files under an S3 prefix.
+// Its ETag is over the ETags S3 lists for the files, so checking for a
+// change costs one list request and no downloads. Files are read with
+// If-Match, and a load that sees a file change midway starts over, a few
+// times.
+type S3Source struct {
+	cfg    S3Config
+	client *s3Client
+
+	mu       sync.Mutex
+	etag     string
+	contract *engine.Contract
+}
+
+// S3 returns a source reading the contract cfg locates.
+func S3(cfg S3Config) *S3Source {
+	if cfg.Region == "" {
+		cfg.Region = os.Getenv("AWS_REGION")
+	}
+	creds := EnvCredentials()
+	if cfg.Credentials != nil {
+		creds = *cfg.Credentials
+	}
+	if cfg.CacheDir == "" {
+		cfg.CacheDir = os.TempDir()
+	}
+	return &S3Source{cfg: cfg, client: &s3Client{
+		bucket:   cfg.Bucket,
+		region:   cfg.Region,
+		endpoint: cfg.Endpoint,
+		creds:    creds,
+		http:     &http.Client{Timeout: 30 * time.Second},
+		now:      time.Now,
+	}}
+}
+
+var _ covenant.Source = (*S3Source)(nil)
+
+// Load returns the contract, compiling it only if its files changed since
+// the last load and no snapshot of it is cached.
+func (s *S3Source) Load(ctx context.Context) (*engine.Contract, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for attempt := 0; ; attempt++ {
+		c, etag, err := s.load(ctx)
+		if errors.Is(err, errPreconditionFailed) && attempt < 3 {
+			continue
+		}
+		return c, etag, err
+	}
+}
+
+func (s *S3Source) load(ctx context.Context) (*engine.Contract, string, error) {
+	objects, err := s.client.list(ctx, s.cfg.Prefix)
+	if err != nil {
+		return nil, "", err
+	}
+	// domain → file → S3 ETag
+	files := map[string]map[string]string{}
+	checksums := map[string]string{}
+	for _, o := range objects {
+		domain, name, ok := strings.Cut(strings.TrimPrefix(o.Key, s.cfg.Prefix), "/")
+		if !ok || strings.HasPrefix(domain, ".") || strings.Contains(name, "/") || !strings.HasSuffix(name, ".cue") {
+			continue
+		}
+		if files[domain] == nil {
+			files[domain] = map[string]string{}
+		}
+		files[domain][name] = o.ETag
+		checksums[domain+"/"+name] = o.ETag
+	}
+	if len(files[s.cfg.Domain]) == 0 {
+		return nil, "", fmt.Errorf("no contract files of domain %s under s3://%s/%s", s.cfg.Domain, s.cfg.Bucket, s.cfg.Prefix)
+	}
+	etag := engine.ManifestETag(checksums)
+	if etag == s.etag {
+		return s.contract, etag, nil
+	}
+
+	snap := filepath.Join(s.cfg.CacheDir, "covenant-"+s.cfg.Domain+"-"+etag+".snap")
+	c, err := s.readSnapshot(ctx, snap, etag)
+	if err != nil {
+		c, err = s.compile(ctx, files)
+		if err != nil {
+			return nil, "", err
+		}
+		s.writeSnapshot(ctx, snap, etag, c)
+	}
+	s.etag, s.contract = etag, c
+	return c, etag, nil
+}
+
+// sharedKey is where ShareSnapshots keeps the snapshot of a contract.
+func (s *S3Source) sharedKey(etag string) string {
+	return s.cfg.Prefix + ".snapshots/" + s.cfg.Domain + "-" + etag + ".snap"
+}
+
+// readSnapshot reads the contract with etag from the cache directory or,
+// with ShareSnapshots, from the bucket.
+func (s *S3Source) readSnapshot(ctx context.Context, snap, etag string) (*engine.Contract, error) {
+	c, err := engine.ReadSnapshot(snap, etag, "")
+	if err == nil || !errors.Is(err, fs.ErrNotExist) || !s.cfg.ShareSnapshots {
+		return c, err
+	}
+	data, err := s.client.get(ctx, s.sharedKey(etag), "")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(snap, data, 0o444); err != nil {
+		return nil, err
+	}
+	return engine.ReadSnapshot(snap, etag, "")
+}
+
+// writeSnapshot caches a compiled contract, logging rather than failing
+// the load if it cannot.
+func (s *S3Source) writeSnapshot(ctx context.Context, snap, etag string, c *engine.Contract) {
+	if err := engine.WriteSnapshot(snap, etag, c); err != nil {
+		log.Printf("covenantlambda: cache contract snapshot: %v", err)
+		return
+	}
+	if !s.cfg.ShareSnapshots {
+		return
+	}
+	data, err := os.ReadFile(snap)
+	if err == nil {
+		err = s.client.put(ctx, s.sharedKey(etag), data)
+	}
+	if err != nil {
+		log.Printf("covenantlambda: share contract snapshot: %v", err)
+	}
+}
+
+// compile fetches the domain's files and those of the domains it imports,
+// as listed, and compiles them.
+func (s *S3Source) compile(ctx context.Context, files map[string]map[string]string) (*engine.Contract, error) {
+	fetch := func(domain string) (map[string][]byte, error) {
+		listed, ok := files[domain]
+		if !ok {
+			return nil, fmt.Errorf("no contract files under s3://%s/%s%s/", s.cfg.Bucket, s.cfg.Prefix, domain)
+		}
+		out := make(map[string][]byte, len(listed))
+		for name, etag := range listed {
+			data, err := s.client.get(ctx, s.cfg.Prefix+domain+"/"+name, etag)
+			if err != nil {
+				return nil, err
+			}
+			out[path.Join(domain, name)] = data
+		}
+		return out, nil
+	}
+	own, err := fetch(s.cfg.Domain)
+	if err != nil {
+		return nil, err
+	}
+	return engine.CompileSources(own, fetch)
+}
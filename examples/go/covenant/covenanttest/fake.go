@@ -0,0 +1,142 @@
+// Package covenanttest helps unit-test services that call the contract
+// engine through a covenant.Client.
+//
+// FakeExecutor answers each operation with an outcome the test programs,
+// without a network or a contract, and records every request it gets:
+//
+//	fake := covenanttest.NewFakeExecutor()
+//	fake.Deny("ProcessPayment", "INSUFFICIENT_FUNDS", "balance too low")
+//	svc := billing.NewService(fake)
+//	...
+//	if got := fake.Calls("ProcessPayment"); len(got) != 1 { ... }
+package covenanttest
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"sync"
+
+	"covenant-poc/covenant"
+	"covenant-poc/executor/engine"
+)
+
+// Handler computes the response to one request.
+type Handler func(ctx context.Context, req *covenant.Request) (*covenant.Response, error)
+
+// FakeExecutor is a covenant.Client with programmed outcomes. It is safe
+// for concurrent use.
+type FakeExecutor struct {
+	mu       sync.Mutex
+	handlers map[string]Handler
+	requests []covenant.Request
+}
+
+var _ covenant.Client = (*FakeExecutor)(nil)
+
+// NewFakeExecutor returns a FakeExecutor with no outcomes programmed.
+// Requests for an operation with no outcome fail with an error.
+func NewFakeExecutor() *FakeExecutor {
+	return &FakeExecutor{handlers: map[string]Handler{}}
+}
+
+// Evaluate records req and answers it with the operation's programmed
+// outcome.
+func (f *FakeExecutor) Evaluate(ctx context.Context, req *covenant.Request) (*covenant.Response, error) {
+	f.mu.Lock()
+	rec := *req
+	rec.Input = maps.Clone(req.Input)
+	f.requests = append(f.requests, rec)
+	h := f.handlers[req.Operation]
+	f.mu.Unlock()
+
+	if h == nil {
+		return nil, fmt.Errorf("covenanttest: no outcome programmed for operation %q", req.Operation)
+	}
+	return h(ctx, req)
+}
+
+// Handle answers operation with h, replacing any outcome programmed for it.
+func (f *FakeExecutor) Handle(operation string, h Handler) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.handlers[operation] = h
+}
+
+// Respond answers operation with a copy of resp.
+func (f *FakeExecutor) Respond(operation string, resp *covenant.Response) {
+	f.Handle(operation, func(context.Context, *covenant.Request) (*covenant.Response, error) {
+		r := *resp
+		return &r, nil
+	})
+}
+
+// Allow lets operation through: live requests are executed with output,
+// and dry-runs would execute.
+func (f *FakeExecutor) Allow(operation string, output map[string]any) {
+	f.Handle(operation, func(_ context.Context, req *covenant.Request) (*covenant.Response, error) {
+		if req.DryRun {
+			return &covenant.Response{Outcome: covenant.OutcomeWouldExecute, DryRun: true, SideEffectsIsolated: true}, nil
+		}
+		return &covenant.Response{Outcome: covenant.OutcomeExecuted, Output: maps.Clone(output)}, nil
+	})
+}
+
+// Deny denies operation with a client error envelope carrying code and
+// message, as a deny rule with that code would.
+func (f *FakeExecutor) Deny(operation, code, message string) {
+	env := covenant.ErrorEnvelope{Code: code, Message: message, HttpStatus: http.StatusForbidden, Category: "client"}
+	f.verdict(operation, engine.Verdict{Type: "deny", Code: code, Reason: message, Error: &env}, covenant.OutcomeDenied, covenant.OutcomeWouldDeny)
+}
+
+// Escalate escalates operation to queue.
+func (f *FakeExecutor) Escalate(operation, queue string) {
+	f.verdict(operation, engine.Verdict{Type: "escalate", Code: "ESCALATED", Queue: queue}, covenant.OutcomeEscalated, covenant.OutcomeWouldEscalate)
+}
+
+func (f *FakeExecutor) verdict(operation string, v engine.Verdict, outcome, dryRunOutcome covenant.Outcome) {
+	f.Handle(operation, func(_ context.Context, req *covenant.Request) (*covenant.Response, error) {
+		// As from the engine, only a live denial carries the envelope.
+		if req.DryRun {
+			return &covenant.Response{Outcome: dryRunOutcome, DryRun: true, SideEffectsIsolated: true, Verdicts: []engine.Verdict{v}}, nil
+		}
+		return &covenant.Response{Outcome: outcome, Error: v.Error, Verdicts: []engine.Verdict{v}}, nil
+	})
+}
+
+// Fail makes requests for operation fail with err, as an unreachable
+// executor would.
+func (f *FakeExecutor) Fail(operation string, err error) {
+	f.Handle(operation, func(context.Context, *covenant.Request) (*covenant.Response, error) {
+		return nil, err
+	})
+}
+
+// Requests returns every request received, in order.
+func (f *FakeExecutor) Requests() []covenant.Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]covenant.Request(nil), f.requests...)
+}
+
+// Calls returns the requests received for operation, in order.
+func (f *FakeExecutor) Calls(operation string) []covenant.Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var calls []covenant.Request
+	for _, r := range f.requests {
+		if r.Operation == operation {
+			calls = append(calls, r)
+		}
+	}
+	return calls
+}
+
+// Reset forgets recorded requests and programmed outcomes.
+func (f *FakeExecutor) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.handlers = map[string]Handler{}
+	f.requests = nil
+}
@@ -0,0 +1,80 @@
+package covenanttest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"covenant-poc/covenant"
+)
+
+// chargeInvoice stands in for a service under test that calls the engine.
+func chargeInvoice(ctx context.Context, c covenant.Client, invoice string) (string, error) {
+	resp, err := c.Evaluate(ctx, &covenant.Request{Operation: "ProcessPayment", Input: map[string]any{"invoice.id": invoice}})
+	if err != nil {
+		return "", err
+	}
+	if resp.Error != nil {
+		return resp.Error.Code, nil
+	}
+	return string(resp.Outcome), nil
+}
+
+func TestFakeExecutor_programmedOutcomes(t *testing.T) {
+	fake := NewFakeExecutor()
+	ctx := context.Background()
+
+	fake.Allow("ProcessPayment", map[string]any{"payment_id": "pay_1"})
+	if got, err := chargeInvoice(ctx, fake, "inv_1"); err != nil || got != "executed" {
+		t.Fatalf("expected executed, got %q, %v", got, err)
+	}
+	resp, _ := fake.Evaluate(ctx, &covenant.Request{Operation: "ProcessPayment", DryRun: true})
+	if resp.Outcome != "would_execute" || resp.Output != nil {
+		t.Errorf("expected a dry-run to would_execute without output, got %+v", resp)
+	}
+
+	fake.Deny("ProcessPayment", "INSUFFICIENT_FUNDS", "balance too low")
+	if got, _ := chargeInvoice(ctx, fake, "inv_2"); got != "INSUFFICIENT_FUNDS" {
+		t.Errorf("expected the denial's code, got %q", got)
+	}
+
+	fake.Escalate("ProcessPayment", "payment-review")
+	resp, _ = fake.Evaluate(ctx, &covenant.Request{Operation: "ProcessPayment", DryRun: true})
+	if resp.Outcome != "would_escalate" || resp.Verdicts[0].Queue != "payment-review" {
+		t.Errorf("expected would_escalate to payment-review, got %+v", resp)
+	}
+
+	down := errors.New("connection refused")
+	fake.Fail("ProcessPayment", down)
+	if _, err := chargeInvoice(ctx, fake, "inv_3"); !errors.Is(err, down) {
+		t.Errorf("expected the programmed error, got %v", err)
+	}
+
+	if _, err := fake.Evaluate(ctx, &covenant.Request{Operation: "RefundPayment"}); err == nil {
+		t.Error("expected an unprogrammed operation to fail")
+	}
+}
+
+func TestFakeExecutor_recordsRequests(t *testing.T) {
+	fake := NewFakeExecutor()
+	fake.Allow("ProcessPayment", nil)
+	ctx := context.Background()
+
+	input := map[string]any{"invoice.id": "inv_1"}
+	fake.Evaluate(ctx, &covenant.Request{Operation: "ProcessPayment", Input: input})
+	fake.Evaluate(ctx, &covenant.Request{Operation: "GetInvoice"})
+	input["invoice.id"] = "changed"
+
+	calls := fake.Calls("ProcessPayment")
+	if len(calls) != 1 || calls[0].Input["invoice.id"] != "inv_1" {
+		t.Errorf("expected one recorded call with its input as sent, got %+v", calls)
+	}
+	if n := len(fake.Requests()); n != 2 {
+		t.Errorf("expected 2 recorded requests, got %d", n)
+	}
+
+	fake.Reset()
+	if len(fake.Requests()) != 0 {
+		t.Error("expected Reset to forget requests")
+	}
+}
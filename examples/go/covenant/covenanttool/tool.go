@@ -0,0 +1,276 @@
+// Package covenanttool exposes contract operations as tools for LLM agents,
+// making the contract the guardrail on the actions an agent takes.
+//
+// Each Tool has what function-calling models and agent frameworks such as
+// LangChain expect of a tool — a name, a description and JSON Schema
+// parameters — built from the contract. Call takes the model's JSON
+// arguments, dry-runs the operation, and executes it only if the dry-run
+// would proceed. Either way it returns an observation, plain text for the
+// model to read, naming the outcome and the reasons of the verdicts behind
+// it, so that the agent can change course rather than fail:
+//
+//	for _, t := range covenanttool.Tools(contract, client) {
+//		agent.AddTool(t.Name(), t.Description(), t.Parameters(), t.Call)
+//	}
+//
+// Tool's Name, Description and Call methods satisfy langchaingo's
+// tools.Tool interface as they are.
+package covenanttool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"covenant-poc/covenant"
+	"covenant-poc/covenant/codegen"
+)
+
+// Tool is one contract operation as an agent tool.
+type Tool struct {
+	op         codegen.Operation
+	client     covenant.Client
+	dryRunOnly bool
+	desc       string
+	params     map[string]any
+}
+
+// Option configures the tools Tools returns.
+type Option func(*Tool)
+
+// DryRunOnly makes the tools only ever dry-run their operations, for agents
+// that propose plans for a person to approve.
+func DryRunOnly() Option {
+	return func(t *Tool) { t.dryRunOnly = true }
+}
+
+// Tools returns a tool for each operation of c, sorted by name, evaluating
+// it with client.
+func Tools(c *covenant.Contract, client covenant.Client, opts ...Option) []*Tool {
+	var tools []*Tool
+	for _, op := range codegen.Describe(c).Operations {
+		t := &Tool{op: op, client: client}
+		for _, opt := range opts {
+			opt(t)
+		}
+		t.desc = describe(c, op)
+		t.params = schema(op.Input)
+		tools = append(tools, t)
+	}
+	return tools
+}
+
+var invalidName = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// Name returns the operation's name, with any characters tool names may
+// not contain replaced by underscores.
+func (t *Tool) Name() string {
+	return invalidName.ReplaceAllString(t.op.Name, "_")
+}
+
+// Operation returns the name of the operation the tool evaluates.
+func (t *Tool) Operation() string { return t.op.Name }
+
+// Description tells the model what the operation does to entities and
+// which rules can stop it, with their reasons.
+func (t *Tool) Description() string { return t.desc }
+
+// Parameters returns the JSON Schema of the tool's arguments: an object of
+// the operation's input facts.
+func (t *Tool) Parameters() map[string]any { return t.params }
+
+// Definition returns the tool as a function definition in the format of
+// the OpenAI and compatible chat completion APIs.
+func (t *Tool) Definition() map[string]any {
+	return map[string]any{
+		"type": "function",
+		"function": map[string]any{
+			"name":        t.Name(),
+			"description": t.desc,
+			"parameters":  t.params,
+		},
+	}
+}
+
+// Call evaluates the operation with input, the model's JSON arguments, and
+// returns the observation. It dry-runs the operation first and executes it
+// only if the dry-run would proceed, and never with DryRunOnly. Denials,
+// escalations and malformed arguments are observations; an error means the
+// operation could not be evaluated at all.
+func (t *Tool) Call(ctx context.Context, input string) (string, error) {
+	args := map[string]any{}
+	if strings.TrimSpace(input) != "" {
+		if err := json.Unmarshal([]byte(input), &args); err != nil {
+			return fmt.Sprintf("%s was not run: its arguments must be a JSON object of the input facts (%v).", t.op.Name, err), nil
+		}
+	}
+
+	resp, err := t.client.Evaluate(ctx, &covenant.Request{Operation: t.op.Name, Input: args, DryRun: true})
+	if err != nil {
+		return "", err
+	}
+	if !resp.WouldProceed() || t.dryRunOnly {
+		return observe(t.op.Name, resp), nil
+	}
+	resp, err = t.client.Evaluate(ctx, &covenant.Request{Operation: t.op.Name, Input: args})
+	if err != nil {
+		return "", err
+	}
+	return observe(t.op.Name, resp), nil
+}
+
+// observe describes resp to the model.
+func observe(op string, resp *covenant.Response) string {
+	var b strings.Builder
+	switch resp.Outcome {
+	case covenant.OutcomeExecuted:
+		fmt.Fprintf(&b, "%s was executed.", op)
+		if len(resp.Output) > 0 {
+			out, _ := json.Marshal(resp.Output)
+			fmt.Fprintf(&b, " Output: %s", out)
+		}
+		writeUndo(&b, resp.Undo)
+	case covenant.OutcomeWouldExecute, covenant.OutcomeWouldExecuteWithFlags:
+		fmt.Fprintf(&b, "%s would be allowed; it was checked but not run.", op)
+	case covenant.OutcomeDenied, covenant.OutcomeWouldDeny:
+		fmt.Fprintf(&b, "%s was denied and not run.", op)
+	case covenant.OutcomeEscalated:
+		fmt.Fprintf(&b, "%s was escalated for review by a person and has not run.", op)
+		if resp.EscalationID != "" {
+			fmt.Fprintf(&b, " Escalation ID: %s.", resp.EscalationID)
+		}
+	case covenant.OutcomeWouldEscalate:
+		fmt.Fprintf(&b, "%s needs review by a person and was not run.", op)
+	case covenant.OutcomeRequired, covenant.OutcomeWouldRequire:
+		fmt.Fprintf(&b, "%s needs more before it can run and was not run.", op)
+	default:
+		fmt.Fprintf(&b, "%s could not be evaluated (%s) and was not run.", op, resp.Outcome)
+	}
+
+	if e := resp.Error; e != nil && !hasVerdictError(resp, e.Code) {
+		writeError(&b, e)
+	}
+	for _, v := range resp.Verdicts {
+		b.WriteString("\n- ")
+		switch v.Type {
+		case "deny":
+			fmt.Fprintf(&b, "Denied (%s)", v.Code)
+		case "escalate":
+			b.WriteString("Needs review")
+			if v.Queue != "" {
+				fmt.Fprintf(&b, " in the %s queue", v.Queue)
+			}
+		case "require":
+			b.WriteString("Requires more")
+		case "flag":
+			fmt.Fprintf(&b, "Flagged (%s)", v.Code)
+		}
+		if v.Reason != "" {
+			fmt.Fprintf(&b, ": %s", v.Reason)
+		}
+		if v.Error != nil && v.Error.Suggestion != "" {
+			fmt.Fprintf(&b, ". Suggestion: %s", v.Error.Suggestion)
+		}
+	}
+	if e := resp.Error; e != nil && e.Retryable {
+		b.WriteString("\nRetrying later may succeed.")
+	}
+	return b.String()
+}
+
+func hasVerdictError(resp *covenant.Response, code string) bool {
+	for _, v := range resp.Verdicts {
+		if v.Error != nil && v.Error.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+func writeError(b *strings.Builder, e *covenant.ErrorEnvelope) {
+	fmt.Fprintf(b, "\n- Error %s", e.Code)
+	if e.Message != "" {
+		fmt.Fprintf(b, ": %s", e.Message)
+	}
+	if e.Suggestion != "" {
+		fmt.Fprintf(b, ". Suggestion: %s", e.Suggestion)
+	}
+}
+
+func writeUndo(b *strings.Builder, u *covenant.UndoInfo) {
+	switch {
+	case u == nil:
+	case u.Irreversible:
+		b.WriteString(" It cannot be undone.")
+	default:
+		fmt.Fprintf(b, "\nIt can be undone with %s", u.Operation)
+		if len(u.Input) > 0 {
+			in, _ := json.Marshal(u.Input)
+			fmt.Fprintf(b, " and input %s", in)
+		}
+		if u.Deadline != nil {
+			fmt.Fprintf(b, " until %s", u.Deadline.Format(time.RFC3339))
+		}
+		b.WriteString(".")
+	}
+}
+
+// describe returns the tool description of op.
+func describe(c *covenant.Contract, op codegen.Operation) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Performs the %s operation, guarded by a contract: it is checked first and runs only if no rule stops it.", op.Name)
+	for _, t := range c.Operations[op.Name].Transitions {
+		if t.From != "" {
+			fmt.Fprintf(&b, " Moves the %s from %s to %s.", t.Entity, t.From, t.To)
+		} else {
+			fmt.Fprintf(&b, " Moves the %s to %s.", t.Entity, t.To)
+		}
+	}
+	if len(op.Verdicts) > 0 {
+		b.WriteString(" Rules:")
+	}
+	for _, v := range op.Verdicts {
+		switch v.Type {
+		case "deny":
+			fmt.Fprintf(&b, "\n- Denied with %s", v.Code)
+		case "escalate":
+			b.WriteString("\n- Sent for review by a person")
+			if v.Queue != "" {
+				fmt.Fprintf(&b, " in the %s queue", v.Queue)
+			}
+		case "require":
+			b.WriteString("\n- Requires more")
+		case "flag":
+			fmt.Fprintf(&b, "\n- Flagged %s", v.Code)
+		}
+		if v.Reason != "" {
+			fmt.Fprintf(&b, ": %s", v.Reason)
+		}
+	}
+	return b.String()
+}
+
+// schema returns the JSON Schema of an object with fields.
+func schema(fields []codegen.Field) map[string]any {
+	props := map[string]any{}
+	required := []string{}
+	for _, f := range fields {
+		var prop map[string]any
+		switch {
+		case f.Type == codegen.TypeObject && len(f.Fields) > 0:
+			prop = schema(f.Fields)
+		case f.Type == codegen.TypeUnknown:
+			prop = map[string]any{}
+		default:
+			prop = map[string]any{"type": string(f.Type)}
+		}
+		props[f.Name] = prop
+		if f.Required {
+			required = append(required, f.Name)
+		}
+	}
+	return map[string]any{"type": "object", "properties": props, "required": required}
+}
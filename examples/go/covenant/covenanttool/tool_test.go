@@ -0,0 +1,116 @@
+package covenanttool
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"covenant-poc/covenant"
+	"covenant-poc/covenant/covenanttest"
+	"covenant-poc/executor/engine"
+)
+
+func billingTool(t *testing.T, client covenant.Client, name string, opts ...Option) *Tool {
+	t.Helper()
+	c, err := engine.CompileDir("../../contracts/billing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tool := range Tools(c, client, opts...) {
+		if tool.Name() == name {
+			return tool
+		}
+	}
+	t.Fatalf("no tool %s", name)
+	return nil
+}
+
+func TestTools_describeTheContract(t *testing.T) {
+	pay := billingTool(t, nil, "ProcessPayment")
+	for _, want := range []string{
+		"Moves the invoice from approved to paid.",
+		"- Denied with INSUFFICIENT_FUNDS: Payment amount exceeds invoice balance",
+		"- Sent for review by a person in the payment-review queue",
+	} {
+		if !strings.Contains(pay.Description(), want) {
+			t.Errorf("expected the description to contain %q, got\n%s", want, pay.Description())
+		}
+	}
+
+	params := pay.Parameters()
+	if !reflect.DeepEqual(params["required"], []string{"invoice.id", "payment.amount"}) {
+		t.Errorf("got required %v", params["required"])
+	}
+	amount := params["properties"].(map[string]any)["payment.amount"].(map[string]any)
+	if value := amount["properties"].(map[string]any)["value"]; !reflect.DeepEqual(value, map[string]any{"type": "number"}) {
+		t.Errorf("got payment.amount %v", amount)
+	}
+	if fn := pay.Definition()["function"].(map[string]any); fn["name"] != "ProcessPayment" {
+		t.Errorf("got definition %v", fn)
+	}
+}
+
+func TestTool_Call(t *testing.T) {
+	ctx := context.Background()
+	fake := covenanttest.NewFakeExecutor()
+	pay := billingTool(t, fake, "ProcessPayment")
+	args := `{"invoice.id": "inv_1", "payment.amount": {"value": 20}}`
+
+	fake.Allow("ProcessPayment", map[string]any{"payment_id": "pay_1"})
+	obs, err := pay.Call(ctx, args)
+	if err != nil || obs != `ProcessPayment was executed. Output: {"payment_id":"pay_1"}` {
+		t.Errorf("got %q, %v", obs, err)
+	}
+	calls := fake.Calls("ProcessPayment")
+	if len(calls) != 2 || !calls[0].DryRun || calls[1].DryRun || calls[1].Input["invoice.id"] != "inv_1" {
+		t.Errorf("expected a dry-run then an execution, got %+v", calls)
+	}
+
+	deadline := time.Date(2025, 1, 31, 12, 0, 0, 0, time.UTC)
+	fake.Respond("ProcessPayment", &covenant.Response{Outcome: covenant.OutcomeExecuted, Undo: &covenant.UndoInfo{Operation: "RefundPayment", Input: map[string]any{"payment.id": "pay_1"}, Deadline: &deadline}})
+	if obs, _ := pay.Call(ctx, args); !strings.HasSuffix(obs, "\nIt can be undone with RefundPayment and input {\"payment.id\":\"pay_1\"} until 2025-01-31T12:00:00Z.") {
+		t.Errorf("got %q", obs)
+	}
+
+	fake.Reset()
+	fake.Deny("ProcessPayment", "INSUFFICIENT_FUNDS", "Payment amount exceeds invoice balance")
+	obs, err = pay.Call(ctx, args)
+	if err != nil || obs != "ProcessPayment was denied and not run.\n- Denied (INSUFFICIENT_FUNDS): Payment amount exceeds invoice balance" {
+		t.Errorf("got %q, %v", obs, err)
+	}
+	if calls := fake.Calls("ProcessPayment"); len(calls) != 1 {
+		t.Errorf("expected only the dry-run, got %+v", calls)
+	}
+
+	fake.Escalate("ProcessPayment", "payment-review")
+	if obs, _ := pay.Call(ctx, args); !strings.Contains(obs, "needs review by a person") || !strings.Contains(obs, "payment-review queue") {
+		t.Errorf("got %q", obs)
+	}
+
+	if obs, err := pay.Call(ctx, "{not json"); err != nil || !strings.Contains(obs, "was not run: its arguments must be a JSON object") {
+		t.Errorf("expected malformed arguments as an observation, got %q, %v", obs, err)
+	}
+
+	down := errors.New("connection refused")
+	fake.Fail("ProcessPayment", down)
+	if _, err := pay.Call(ctx, args); !errors.Is(err, down) {
+		t.Errorf("expected the client's error, got %v", err)
+	}
+}
+
+func TestTool_Call_dryRunOnly(t *testing.T) {
+	fake := covenanttest.NewFakeExecutor()
+	fake.Allow("ProcessPayment", nil)
+	pay := billingTool(t, fake, "ProcessPayment", DryRunOnly())
+
+	obs, err := pay.Call(context.Background(), `{"invoice.id": "inv_1"}`)
+	if err != nil || obs != "ProcessPayment would be allowed; it was checked but not run." {
+		t.Errorf("got %q, %v", obs, err)
+	}
+	if calls := fake.Calls("ProcessPayment"); len(calls) != 1 || !calls[0].DryRun {
+		t.Errorf("expected only a dry-run, got %+v", calls)
+	}
+}
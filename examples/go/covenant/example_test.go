@@ -0,0 +1,78 @@
+package covenant_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+
+	"covenant-poc/covenant"
+	"covenant-poc/executor/ports"
+	"covenant-poc/executor/ports/flags"
+	"covenant-poc/executor/ports/inmem"
+)
+
+// An existing payment handler is guarded by the billing contract: it runs
+// only when the contract allows the payment, and a denial answers with the
+// contract's error envelope instead.
+func Example_wrapHandler() {
+	registry := ports.NewRegistry()
+	registry.Register("customerRepo", inmem.NewCustomerRepo())
+	registry.Register("invoiceRepo", inmem.NewInvoiceRepo())
+	registry.Register("paymentProcessor", inmem.NewPaymentProcessor())
+	registry.Register("flags", flags.NewPort(flags.NewStatic(nil), "customer.id"))
+
+	cov, err := covenant.New(covenant.Dir("../contracts/billing"), registry)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	pay := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "payment accepted")
+	})
+	handler := guard(cov, "ProcessPayment", pay)
+
+	for _, amount := range []string{"100", "5000"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("POST", "/invoices/inv_001/pay?amount="+amount, nil))
+		fmt.Print(rec.Code, " ", rec.Body.String())
+	}
+	// Output:
+	// 200 payment accepted
+	// 402 {"code":"INSUFFICIENT_FUNDS","message":"Payment amount exceeds invoice balance"}
+}
+
+// guard runs next as op's action, so it executes only if cov allows the
+// request.
+func guard(cov *covenant.Covenant, op string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		amount, _ := strconv.ParseFloat(r.URL.Query().Get("amount"), 64)
+		input := map[string]any{
+			"customer.id":    "cust_123",
+			"invoice.id":     "inv_001",
+			"payment.amount": map[string]any{"value": amount, "currency": "USD"},
+		}
+		ctx := covenant.WithAction(r.Context(), func(ctx context.Context) (map[string]any, error) {
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return nil, nil
+		})
+
+		resp, err := cov.Evaluate(ctx, &covenant.Request{Operation: op, Input: input})
+		switch {
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		case resp.Outcome == "executed":
+			// next has written the response.
+		case resp.Error != nil:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(resp.Error.HttpStatus)
+			json.NewEncoder(w).Encode(map[string]string{"code": resp.Error.Code, "message": resp.Error.Message})
+		default:
+			// Escalated: held for review.
+			w.WriteHeader(http.StatusAccepted)
+		}
+	})
+}
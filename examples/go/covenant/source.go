@@ -0,0 +1,85 @@
+package covenant
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"covenant-poc/executor/engine"
+)
+
+// Source supplies the contract a Covenant enforces.
+type Source interface {
+	// Load returns the current contract and its ETag. An unchanged contract
+	// must keep its ETag.
+	Load(ctx context.Context) (*Contract, string, error)
+}
+
+// SourceFunc adapts a function to Source.
+type SourceFunc func(ctx context.Context) (*Contract, string, error)
+
+func (f SourceFunc) Load(ctx context.Context) (*Contract, string, error) { return f(ctx) }
+
+// Dir compiles the contract in a local domain directory, resolving imports
+// from sibling directories as engine.CompileDir does. The ETag is a hash of
+// the compiled contract.
+func Dir(path string) Source {
+	return SourceFunc(func(context.Context) (*Contract, string, error) {
+		c, err := engine.CompileDir(path)
+		if err != nil {
+			return nil, "", err
+		}
+		etag, err := contentETag(c)
+		return c, etag, err
+	})
+}
+
+// Server fetches the active contract from a contract server, with its
+// declared param defaults. The ETag is the server's. If the contract
+// changes while it is being fetched, Server starts over, a few times.
+func Server(url string) Source {
+	return ServerChannel(url, engine.ChannelPublished)
+}
+
+// ServerChannel is Server following the contract on a channel, such as
+// engine.ChannelDraft for trying out changes before they are promoted.
+// Reloads fetch and recompile only the files that changed.
+func ServerChannel(url, channel string) Source {
+	loader := engine.NewLoader()
+	return SourceFunc(func(context.Context) (*Contract, string, error) {
+		for attempt := 0; ; attempt++ {
+			disc, err := engine.FetchDiscovery(url)
+			if err != nil {
+				return nil, "", err
+			}
+			if err := disc.UseChannel(channel); err != nil {
+				return nil, "", err
+			}
+			c, err := loader.LoadContract(url, disc)
+			if errors.Is(err, engine.ErrTornRead) && attempt < 3 {
+				continue
+			}
+			return c, disc.ContractETag, err
+		}
+	})
+}
+
+// Static serves a contract built in code. The ETag is a hash of c.
+func Static(c *Contract) Source {
+	return SourceFunc(func(context.Context) (*Contract, string, error) {
+		etag, err := contentETag(c)
+		return c, etag, err
+	})
+}
+
+// contentETag matches the contract server's ETag format: the first 12 hex
+// digits of a SHA-256 over the contract.
+func contentETag(c *Contract) (string, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data))[:12], nil
+}
@@ -0,0 +1,149 @@
+// Package config reads the settings of the covenant daemons from a JSON
+// config file and the environment. Settings are named as the daemon's
+// flags and grouped into sections, e.g.
+//
+//	{"server":  {"addr": ":26860", "lanes": "interactive=64:2000,batch=8:10000"},
+//	 "storage": {"postgres": "postgres://covenant@db/covenant", "retention": "2160h"},
+//	 "auth":    {"rbac": "/etc/covenant/rbac.json"}}
+//
+// Sections only group settings; a setting may appear in any one of them.
+// A COVENANT_<FLAG> environment variable overrides the file, and a flag
+// given on the command line overrides both.
+package config
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+// EnvPrefix starts the environment variable overriding each setting, e.g.
+// COVENANT_EVENTS_URL for events-url.
+const EnvPrefix = "COVENANT_"
+
+// reserved are the flags naming and checking the config file, which it
+// cannot set itself.
+var reserved = map[string]bool{"config": true, "validate-config": true}
+
+// File is a daemon's config file.
+type File struct {
+	Path     string   // empty for settings from the environment only
+	Sections []string // the sections the file may have
+	// Getenv looks up environment variables; nil uses os.Getenv.
+	Getenv func(string) string
+}
+
+// Load reads the file into settings by flag name. Values may be strings,
+// numbers or booleans; durations are strings such as "720h".
+func (f File) Load(fs *flag.FlagSet) (map[string]string, error) {
+	settings := map[string]string{}
+	if f.Path == "" {
+		return settings, nil
+	}
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		return nil, err
+	}
+	var sections map[string]map[string]any
+	if err := json.Unmarshal(data, &sections); err != nil {
+		return nil, fmt.Errorf("%s: %w", f.Path, err)
+	}
+	for _, section := range slices.Sorted(maps.Keys(sections)) {
+		if !slices.Contains(f.Sections, section) {
+			return nil, fmt.Errorf("%s: unknown section %q (want %s)", f.Path, section, strings.Join(f.Sections, ", "))
+		}
+		for _, name := range slices.Sorted(maps.Keys(sections[section])) {
+			if fs.Lookup(name) == nil || reserved[name] {
+				return nil, fmt.Errorf("%s: %s: unknown setting %q", f.Path, section, name)
+			}
+			if _, ok := settings[name]; ok {
+				return nil, fmt.Errorf("%s: %s: setting %q appears in more than one section", f.Path, section, name)
+			}
+			switch v := sections[section][name].(type) {
+			case string, float64, bool:
+				settings[name] = fmt.Sprint(v)
+			default:
+				return nil, fmt.Errorf("%s: %s: setting %q is not a string, number or boolean", f.Path, section, name)
+			}
+		}
+	}
+	return settings, nil
+}
+
+// Settings returns the value the environment or, failing that, the file
+// gives each flag not set on the command line. Call it after fs.Parse.
+func (f File) Settings(fs *flag.FlagSet) (map[string]string, error) {
+	return f.settings(fs, nil)
+}
+
+// settings is Settings once the flags in applied have been set from the
+// config rather than the command line.
+func (f File) settings(fs *flag.FlagSet, applied map[string]string) (map[string]string, error) {
+	settings, err := f.Load(fs)
+	if err != nil {
+		return nil, err
+	}
+	getenv := f.Getenv
+	if getenv == nil {
+		getenv = os.Getenv
+	}
+	fs.VisitAll(func(fl *flag.Flag) {
+		if v := getenv(EnvName(fl.Name)); v != "" && !reserved[fl.Name] {
+			settings[fl.Name] = v
+		}
+	})
+	fs.Visit(func(fl *flag.Flag) {
+		if _, ok := applied[fl.Name]; !ok {
+			delete(settings, fl.Name)
+		}
+	})
+	return settings, nil
+}
+
+// Apply sets the flags from Settings, returning the settings it applied.
+func (f File) Apply(fs *flag.FlagSet) (map[string]string, error) {
+	settings, err := f.Settings(fs)
+	if err != nil {
+		return nil, err
+	}
+	var errs []string
+	for _, name := range slices.Sorted(maps.Keys(settings)) {
+		if err := fs.Set(name, settings[name]); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return settings, nil
+}
+
+// Changed lists the settings the file and the environment now give a
+// different value, or none, than they did when applied.
+func (f File) Changed(fs *flag.FlagSet, applied map[string]string) ([]string, error) {
+	settings, err := f.settings(fs, applied)
+	if err != nil {
+		return nil, err
+	}
+	var changed []string
+	for _, name := range slices.Sorted(maps.Keys(settings)) {
+		if v, ok := applied[name]; !ok || v != settings[name] {
+			changed = append(changed, name)
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(applied)) {
+		if _, ok := settings[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	return changed, nil
+}
+
+// EnvName is the environment variable overriding the setting name.
+func EnvName(name string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newFlags() (*flag.FlagSet, *string, *time.Duration, *bool) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	addr := fs.String("addr", ":26860", "")
+	retention := fs.Duration("retention", time.Hour, "")
+	outbox := fs.Bool("outbox", false, "")
+	fs.String("config", "", "")
+	return fs, addr, retention, outbox
+}
+
+func writeConfig(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func env(vars map[string]string) func(string) string {
+	return func(k string) string { return vars[k] }
+}
+
+func TestFile_Apply_precedence(t *testing.T) {
+	fs, addr, retention, outbox := newFlags()
+	if err := fs.Parse([]string{"--addr", ":1"}); err != nil {
+		t.Fatal(err)
+	}
+	f := File{
+		Path:     writeConfig(t, `{"server": {"addr": ":2", "outbox": true}, "storage": {"retention": "720h"}}`),
+		Sections: []string{"server", "storage"},
+		Getenv:   env(map[string]string{"COVENANT_RETENTION": "48h"}),
+	}
+	applied, err := f.Apply(fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *addr != ":1" || *retention != 48*time.Hour || !*outbox {
+		t.Errorf("expected the command line, then the environment, then the file, got addr=%s retention=%v outbox=%v", *addr, *retention, *outbox)
+	}
+	if want := map[string]string{"outbox": "true", "retention": "48h"}; !reflect.DeepEqual(applied, want) {
+		t.Errorf("expected applied %v, got %v", want, applied)
+	}
+}
+
+func TestFile_Load_rejectsMalformedFiles(t *testing.T) {
+	for body, want := range map[string]string{
+		`{"srv": {}}`:                                        `unknown section "srv"`,
+		`{"server": {"port": 1}}`:                            `unknown setting "port"`,
+		`{"server": {"config": "other.json"}}`:               `unknown setting "config"`,
+		`{"server": {"addr": ":1"}, "auth": {"addr": ":2"}}`: `more than one section`,
+		`{"server": {"addr": [":1"]}}`:                       `not a string, number or boolean`,
+	} {
+		fs, _, _, _ := newFlags()
+		_, err := File{Path: writeConfig(t, body), Sections: []string{"server", "auth"}}.Load(fs)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected an error containing %q, got %v", body, want, err)
+		}
+	}
+}
+
+func TestFile_Apply_reportsInvalidValues(t *testing.T) {
+	fs, _, _, _ := newFlags()
+	f := File{Sections: []string{"storage"}, Getenv: env(map[string]string{"COVENANT_RETENTION": "a while"})}
+	if _, err := f.Apply(fs); err == nil || !strings.Contains(err.Error(), "retention") {
+		t.Errorf("expected a retention error, got %v", err)
+	}
+}
+
+func TestFile_Changed(t *testing.T) {
+	fs, _, _, _ := newFlags()
+	path := writeConfig(t, `{"server": {"addr": ":2", "outbox": true}}`)
+	f := File{Path: path, Sections: []string{"server", "storage"}, Getenv: env(nil)}
+	applied, err := f.Apply(fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := f.Changed(fs, applied); err != nil || len(changed) != 0 {
+		t.Fatalf("expected nothing changed, got %v %v", changed, err)
+	}
+	if err := os.WriteFile(path, []byte(`{"server": {"addr": ":3"}, "storage": {"retention": "24h"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	changed, err := f.Changed(fs, applied)
+	if want := []string{"addr", "retention", "outbox"}; err != nil || !reflect.DeepEqual(changed, want) {
+		t.Errorf("expected %v changed, got %v %v", want, changed, err)
+	}
+}
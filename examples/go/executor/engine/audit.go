@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"context"
+	"time"
+)
+
+// AuditRecord is produced for every invocation, live or dry-run (Section 14.6).
+type AuditRecord struct {
+	InvocationID    string         `json:"invocation_id"`
+	Timestamp       time.Time      `json:"timestamp"`
+	Operation       string         `json:"operation"`
+	Input           map[string]any `json:"input_payload"`
+	ContractVersion string         `json:"contract_version"`
+	ContractSemver  string         `json:"contract_semver,omitempty"`
+	FactSnapshot    map[string]any `json:"fact_snapshot,omitempty"`
+	Verdicts        []Verdict      `json:"verdicts,omitempty"`
+	RulesMatched    []string       `json:"rules_matched,omitempty"`
+	// Experiments maps each experiment the request was in to its arm.
+	Experiments map[string]string `json:"experiments,omitempty"`
+	// ShadowVerdicts are those of matching rules the request was outside
+	// the rollout of; see RolloutDef.
+	ShadowVerdicts []Verdict `json:"shadow_verdicts,omitempty"`
+	Outcome        string    `json:"outcome"`
+	ErrorCode      string    `json:"error_code,omitempty"`
+	EscalationID   string    `json:"escalation_id,omitempty"`
+	DryRun         bool      `json:"dry_run"`
+	Purpose        string    `json:"purpose,omitempty"`
+	DurationMS     float64   `json:"duration_ms"`
+	// SampleRate is set when a SampledSink kept this record at a rate
+	// below 1.
+	SampleRate float64 `json:"sample_rate,omitempty"`
+	// Resolution is set on the record of an escalation's resolution, whose
+	// outcome is denied, or executed or system_error for an approval.
+	Resolution *EscalationResolution `json:"resolution,omitempty"`
+	// Labels are attached by hooks; see HookCall.Label.
+	Labels map[string]string `json:"labels,omitempty"`
+	// SessionID is the session the request was made in, and SessionFacts
+	// the facts it took from the session rather than fetched.
+	SessionID    string   `json:"session_id,omitempty"`
+	SessionFacts []string `json:"session_facts,omitempty"`
+	// PortContractViolation is set when a port's payload did not match its
+	// schema.
+	PortContractViolation *PortContractViolation `json:"port_contract_violation,omitempty"`
+}
+
+// AuditSink receives audit records. Record is called synchronously at the end
+// of every evaluation, so implementations must be safe for concurrent use and
+// should hand off slow work rather than block.
+type AuditSink interface {
+	Record(ctx context.Context, rec *AuditRecord)
+}
+
+// audit completes rec from the response and delivers it to every sink.
+func (e *Engine) audit(ctx context.Context, rec *AuditRecord, resp *Response, elapsed time.Duration) {
+	if len(e.auditSinks) == 0 {
+		return
+	}
+	rec.Outcome = string(resp.Outcome)
+	rec.Verdicts = resp.Verdicts
+	for _, v := range resp.Verdicts {
+		if v.Rule != "" {
+			rec.RulesMatched = append(rec.RulesMatched, v.Rule)
+		}
+	}
+	if resp.Error != nil {
+		rec.ErrorCode = resp.Error.Code
+	}
+	rec.EscalationID = resp.EscalationID
+	rec.DurationMS = float64(elapsed.Microseconds()) / 1000
+	for _, s := range e.auditSinks {
+		s.Record(ctx, rec)
+	}
+}
@@ -0,0 +1,143 @@
+package engine
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingSink struct {
+	mu   sync.Mutex
+	recs []*AuditRecord
+}
+
+func (s *recordingSink) Record(_ context.Context, rec *AuditRecord) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recs = append(s.recs, rec)
+}
+
+func TestEngine_Evaluate_auditsDenyWithMatchedRules(t *testing.T) {
+	sink := &recordingSink{}
+	eng := NewEngine(&mockPorts{}, WithAuditSink(sink))
+	contract := makeSimpleContract("block-rule",
+		VerdictDef{Deny: &DenyVerdict{
+			Code:  "BLOCKED",
+			Error: ErrorEnvelope{Code: "BLOCKED", HttpStatus: 403},
+		}},
+		Condition{Fact: "customer.status", Equals: "blocked"},
+	)
+	eng.LoadContract(contract, "etag-1")
+
+	resp, err := eng.Evaluate(context.Background(), &Request{
+		Operation: "testOp",
+		Input:     map[string]any{"customer.status": "blocked"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sink.recs) != 1 {
+		t.Fatalf("expected 1 audit record, got %d", len(sink.recs))
+	}
+	rec := sink.recs[0]
+	if rec.InvocationID == "" || rec.InvocationID != resp.InvocationID {
+		t.Fatalf("audit invocation ID %q does not match response %q", rec.InvocationID, resp.InvocationID)
+	}
+	if !strings.HasPrefix(rec.InvocationID, "inv_") {
+		t.Fatalf("unexpected invocation ID format %q", rec.InvocationID)
+	}
+	if rec.Outcome != "denied" || rec.ErrorCode != "BLOCKED" || rec.ContractVersion != "etag-1" {
+		t.Fatalf("unexpected audit record: %+v", rec)
+	}
+	if len(rec.RulesMatched) != 1 || rec.RulesMatched[0] != "block-rule" {
+		t.Fatalf("expected rules_matched [block-rule], got %v", rec.RulesMatched)
+	}
+	if rec.FactSnapshot["customer.status"] != "blocked" {
+		t.Fatalf("expected fact snapshot in audit record, got %v", rec.FactSnapshot)
+	}
+}
+
+func TestEngine_Evaluate_auditsDryRun(t *testing.T) {
+	sink := &recordingSink{}
+	eng := NewEngine(&mockPorts{}, WithAuditSink(sink))
+	eng.LoadContract(makeMinimalContract(), "etag-1")
+
+	if _, err := eng.Evaluate(context.Background(), &Request{Operation: "testOp", DryRun: true}); err != nil {
+		t.Fatal(err)
+	}
+	if len(sink.recs) != 1 || !sink.recs[0].DryRun || sink.recs[0].Outcome != "would_execute" {
+		t.Fatalf("expected one dry-run audit record, got %+v", sink.recs)
+	}
+}
+
+func TestEngine_Evaluate_noAuditOnEvaluationError(t *testing.T) {
+	sink := &recordingSink{}
+	eng := NewEngine(&mockPorts{}, WithAuditSink(sink))
+	eng.LoadContract(makeMinimalContract(), "etag-1")
+
+	if _, err := eng.Evaluate(context.Background(), &Request{Operation: "unknownOp"}); err == nil {
+		t.Fatal("expected error")
+	}
+	if len(sink.recs) != 0 {
+		t.Fatalf("expected no audit record for a failed evaluation, got %d", len(sink.recs))
+	}
+}
+
+func TestEngine_Evaluate_passesVerdictMetadataThrough(t *testing.T) {
+	sink := &recordingSink{}
+	eng := NewEngine(&mockPorts{}, WithAuditSink(sink), WithDecisionCache(100))
+	meta := map[string]any{"owner": "billing-platform", "risk_tier": "high"}
+	eng.LoadContract(makeSimpleContract("block-rule",
+		VerdictDef{
+			Deny:     &DenyVerdict{Code: "BLOCKED", Error: ErrorEnvelope{Code: "BLOCKED", HttpStatus: 403}},
+			Metadata: meta,
+		},
+		Condition{Fact: "customer.status", Equals: "blocked"},
+	), "etag-1")
+
+	// The second request's verdicts come from the decision cache.
+	for i := 0; i < 2; i++ {
+		resp, err := eng.Evaluate(context.Background(), &Request{
+			Operation: "testOp",
+			Input:     map[string]any{"customer.status": "blocked"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(resp.Verdicts) != 1 || resp.Verdicts[0].Metadata["owner"] != "billing-platform" || resp.Verdicts[0].Metadata["risk_tier"] != "high" {
+			t.Errorf("request %d: expected the verdict metadata in the response, got %+v", i, resp.Verdicts)
+		}
+		if rec := sink.recs[i]; len(rec.Verdicts) != 1 || rec.Verdicts[0].Metadata["owner"] != "billing-platform" {
+			t.Errorf("request %d: expected the verdict metadata in the audit record, got %+v", i, rec.Verdicts)
+		}
+	}
+}
+
+func TestEngine_Evaluate_ruleWithoutVerdictKeepsOtherMetadata(t *testing.T) {
+	c := makeSimpleContract("block-rule",
+		VerdictDef{
+			Flag:     &FlagVerdict{Code: "BLOCKED", Reason: "blocked"},
+			Metadata: map[string]any{"owner": "billing-platform"},
+		},
+		Condition{Fact: "customer.status", Equals: "blocked"},
+	)
+	empty := RuleDef{ID: "empty", AppliesTo: []string{"testOp"}, When: c.Rules[0].When, Verdict: VerdictDef{Metadata: map[string]any{"owner": "nobody"}}}
+	c.Rules = append([]RuleDef{empty}, c.Rules...)
+	c.Operations["testOp"] = OperationDef{ConstrainedBy: []string{"empty", "block-rule"}}
+	if diags := Validate(c, time.Now()); len(diags) != 1 || diags[0].Rule != "empty" || diags[0].Severity != SeverityError {
+		t.Errorf("expected the verdictless rule reported, got %v", diags)
+	}
+
+	eng := NewEngine(&mockPorts{})
+	eng.LoadContract(c, "etag-1")
+	resp, err := eng.Evaluate(context.Background(), &Request{Operation: "testOp", Input: map[string]any{"customer.status": "blocked"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Verdicts) != 1 || resp.Verdicts[0].Rule != "block-rule" || resp.Verdicts[0].Metadata["owner"] != "billing-platform" {
+		t.Errorf("expected only block-rule's verdict and metadata, got %+v", resp.Verdicts)
+	}
+}
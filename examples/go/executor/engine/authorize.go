@@ -0,0 +1,94 @@
+package engine
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// AuthorizeDef gates an operation on who is calling it, e.g.
+//
+//	authorize: requires: {fact: "user.roles", contains: "billing_admin"}
+//
+// Requires may read only ctx facts and params, so it is checked before any
+// port is consulted: refusing a caller costs no fact gathering. A refused
+// caller is denied with Error, or a FORBIDDEN envelope if none is declared.
+type AuthorizeDef struct {
+	Requires Condition      `json:"requires"`
+	Error    *ErrorEnvelope `json:"error,omitempty"`
+}
+
+// authorize checks the request's ctx facts against the operation's authorize
+// block. It returns the condition's trace, nil if the operation declares no
+// block, and the response refusing the caller, nil if it may proceed. A
+// refusal's audit record keeps the facts it was decided on.
+func authorize(c *Contract, operation string, op OperationDef, req *Request, rec *AuditRecord) (*ConditionTrace, *Response) {
+	if op.Authorize == nil {
+		return nil, nil
+	}
+	facts := NewFactSet()
+	facts.evaluateAs(c, rec.Timestamp)
+	for name, val := range req.Context {
+		if def, ok := c.Facts[name]; ok && def.Source == "ctx" {
+			facts.Set(name, val)
+		}
+	}
+	collectParamRefs(op.Authorize.Requires, func(name string) {
+		if val, ok := c.ParamValue(name); ok {
+			facts.Set(paramFactPrefix+name, val)
+		}
+	})
+
+	trace := traceCondition(op.Authorize.Requires, facts)
+	if trace.Passed {
+		return &trace, nil
+	}
+	rec.FactSnapshot = facts.Snapshot()
+	env := op.Authorize.Error
+	if env == nil {
+		env = &ErrorEnvelope{
+			Code:       "FORBIDDEN",
+			Message:    fmt.Sprintf("Caller is not authorized to invoke %s", operation),
+			HttpStatus: http.StatusForbidden,
+			Category:   "authorization",
+		}
+	}
+	resp := &Response{DryRun: req.DryRun, Outcome: OutcomeDenied, Error: env}
+	if req.Explain {
+		resp.Explain = &Explanation{Authorize: &trace}
+	}
+	return &trace, resp
+}
+
+// validateAuthorize checks that an operation's authorize condition reads
+// only facts available before fact gathering.
+func validateAuthorize(c *Contract, name string, op OperationDef) []Diagnostic {
+	if op.Authorize == nil {
+		return nil
+	}
+	var diags []Diagnostic
+	var paths []string
+	collectFromCondition(op.Authorize.Requires, func(path string) { paths = append(paths, path) })
+	if len(paths) == 0 {
+		diags = append(diags, Diagnostic{
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("operation %s: authorize requires no fact and admits every caller", name),
+		})
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if def, ok := c.Facts[baseFactOf(c, path)]; !ok || (def.Source != "ctx" && def.Source != "param") {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("operation %s: authorize reads %q, which is not a ctx fact", name, path),
+			})
+		}
+	}
+	if env := op.Authorize.Error; env != nil && (env.HttpStatus < 400 || env.HttpStatus >= 500) {
+		diags = append(diags, Diagnostic{
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("operation %s: authorize error has http_status %d; a refused caller needs a 4xx status", name, env.HttpStatus),
+		})
+	}
+	return diags
+}
@@ -0,0 +1,175 @@
+package engine
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEvalCondition_containsMatchesListElementOrSubstring(t *testing.T) {
+	fs := NewFactSet()
+	fs.Set("user.roles", []any{"support", "billing_admin"})
+	fs.Set("user.email", "ops@example.com")
+	for _, tc := range []struct {
+		cond Condition
+		want bool
+	}{
+		{Condition{Fact: "user.roles", Contains: "billing_admin"}, true},
+		{Condition{Fact: "user.roles", Contains: "billing"}, false},
+		{Condition{Fact: "user.email", Contains: "@example.com"}, true},
+		{Condition{Fact: "user.email", Contains: "@evil.com"}, false},
+		{Condition{Fact: "user.missing", Contains: "x"}, false},
+	} {
+		if got := evalCondition(tc.cond, fs); got != tc.want {
+			t.Errorf("%s contains %v: got %v, want %v", tc.cond.Fact, tc.cond.Contains, got, tc.want)
+		}
+	}
+}
+
+// authorizedContract gates testOp on the billing_admin role and has one rule
+// reading a port fact.
+func authorizedContract() *Contract {
+	c := makeSimpleContract("r1",
+		VerdictDef{Flag: &FlagVerdict{Code: "F", Reason: "flagged"}},
+		Condition{Fact: "customer.status", Equals: "active"})
+	c.Facts["customer.status"] = FactDef{Source: "port:customerRepo", Required: true, OnMissing: "system_error"}
+	c.Facts["user.roles"] = FactDef{Source: "ctx"}
+	op := c.Operations["testOp"]
+	op.Authorize = &AuthorizeDef{Requires: Condition{Fact: "user.roles", Contains: "billing_admin"}}
+	c.Operations["testOp"] = op
+	return c
+}
+
+func TestEngine_Evaluate_authorizeRefusesBeforeGatheringFacts(t *testing.T) {
+	gets := 0
+	ports := &mockPorts{getFunc: func(context.Context, string, string, map[string]any) (any, error) {
+		gets++
+		return "active", nil
+	}}
+	sink := &recordingSink{}
+	e := NewEngine(ports, WithAuditSink(sink))
+	e.LoadContract(authorizedContract(), "v1")
+
+	resp, err := e.Evaluate(context.Background(), &Request{
+		Operation: "testOp",
+		Context:   map[string]any{"user.roles": []any{"support"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Outcome != "denied" || resp.Error == nil || resp.Error.Code != "FORBIDDEN" || resp.Error.HttpStatus != 403 || resp.Error.Category != "authorization" {
+		t.Fatalf("expected a 403 FORBIDDEN envelope, got %+v", resp)
+	}
+	if gets != 0 {
+		t.Errorf("expected no port reads for a refused caller, got %d", gets)
+	}
+	recs := sink.recs
+	if len(recs) != 1 || recs[0].Outcome != "denied" || recs[0].FactSnapshot["user.roles"] == nil {
+		t.Errorf("expected the refusal audited with the caller's ctx facts, got %+v", recs)
+	}
+
+	resp, err = e.Evaluate(context.Background(), &Request{
+		Operation: "testOp",
+		Context:   map[string]any{"user.roles": []any{"support", "billing_admin"}},
+		Explain:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Outcome != "executed" || gets != 1 {
+		t.Fatalf("expected an authorized caller to proceed, got %s after %d port reads", resp.Outcome, gets)
+	}
+	if resp.Explain == nil || resp.Explain.Authorize == nil || !resp.Explain.Authorize.Passed {
+		t.Errorf("expected the authorize trace in the explanation, got %+v", resp.Explain)
+	}
+}
+
+func TestEngine_Evaluate_authorizeUsesDeclaredErrorAndChecksDryRuns(t *testing.T) {
+	c := authorizedContract()
+	c.Operations["testOp"].Authorize.Error = &ErrorEnvelope{
+		Code:       "BILLING_ADMIN_ONLY",
+		Message:    "Only billing admins may run this",
+		HttpStatus: 403,
+		Category:   "authorization",
+	}
+	e := NewEngine(&mockPorts{})
+	e.LoadContract(c, "v1")
+
+	resp, err := e.Evaluate(context.Background(), &Request{Operation: "testOp", DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Outcome != "denied" || !resp.DryRun || resp.Error.Code != "BILLING_ADMIN_ONLY" {
+		t.Errorf("expected the declared envelope for a dry-run without ctx facts, got %+v", resp)
+	}
+}
+
+func TestEngine_Evaluate_authorizeGuardsCachedResponses(t *testing.T) {
+	c := authorizedContract()
+	op := c.Operations["testOp"]
+	op.ConstrainedBy = nil
+	op.Cache = &CacheDef{TTL: "1m"}
+	c.Operations["testOp"] = op
+	e := NewEngine(&mockPorts{}, WithResponseCache(10))
+	e.LoadContract(c, "v1")
+
+	admin := &Request{Operation: "testOp", Context: map[string]any{"user.roles": []any{"billing_admin"}}}
+	if resp, _ := e.Evaluate(context.Background(), admin); resp.Outcome != "executed" {
+		t.Fatalf("got %s", resp.Outcome)
+	}
+	if resp, _ := e.Evaluate(context.Background(), &Request{Operation: "testOp"}); resp.Outcome != "denied" {
+		t.Errorf("expected a cached response withheld from an unauthorized caller, got %s", resp.Outcome)
+	}
+}
+
+func TestEngine_Evaluate_requiredCtxFactFromRequestContext(t *testing.T) {
+	c := makeSimpleContract("r1",
+		VerdictDef{Deny: &DenyVerdict{Code: "TENANT", Reason: "wrong tenant"}},
+		Condition{Fact: "user.tenant", Equals: "acme"})
+	c.Facts["user.tenant"] = FactDef{Source: "ctx", Required: true}
+	e := NewEngine(&mockPorts{})
+	e.LoadContract(c, "v1")
+
+	resp, err := e.Evaluate(context.Background(), &Request{Operation: "testOp", Context: map[string]any{"user.tenant": "acme"}})
+	if err != nil || resp.Outcome != "denied" {
+		t.Errorf("expected the ctx fact read from the request context, got %+v, %v", resp, err)
+	}
+	if _, err := e.Evaluate(context.Background(), &Request{Operation: "testOp"}); err == nil || !strings.Contains(err.Error(), `required ctx fact "user.tenant"`) {
+		t.Errorf("expected a missing required ctx fact to fail, got %v", err)
+	}
+}
+
+func TestValidate_authorizeReadsOnlyCtxFactsAndParams(t *testing.T) {
+	c := authorizedContract()
+	c.Params = map[string]ParamDef{"admin_role": {Default: "billing_admin"}}
+	c.Facts[paramFactPrefix+"admin_role"] = FactDef{Source: "param"}
+	op := c.Operations["testOp"]
+	op.Authorize = &AuthorizeDef{
+		Requires: Condition{All: []Condition{
+			{Fact: "user.roles", Contains: map[string]any{"param": "admin_role"}},
+			{Fact: "customer.status", Equals: "active"},
+		}},
+		Error: &ErrorEnvelope{Code: "NOPE", HttpStatus: 200},
+	}
+	c.Operations["testOp"] = op
+
+	var msgs []string
+	for _, d := range Validate(c, time.Now()) {
+		if d.Severity == SeverityError {
+			msgs = append(msgs, d.Message)
+		}
+	}
+	want := []string{
+		`operation testOp: authorize reads "customer.status", which is not a ctx fact`,
+		"operation testOp: authorize error has http_status 200; a refused caller needs a 4xx status",
+	}
+	if strings.Join(msgs, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got %q", msgs)
+	}
+
+	op.Authorize.Requires.All[0].Contains = map[string]any{"param": "missing"}
+	if err := c.Bind("dev", nil); err == nil || !strings.Contains(err.Error(), `undeclared param "missing"`) {
+		t.Errorf("expected Bind to reject an undeclared param in authorize, got %v", err)
+	}
+}
@@ -0,0 +1,465 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// BatchSimulateRequest is the payload sent to POST /simulate/batch: one
+// operation simulated over many fact sets, each like SimulateRequest.Facts,
+// for impact analysis.
+type BatchSimulateRequest struct {
+	Operation    string           `json:"operation"`
+	Rows         []map[string]any `json:"rows"`
+	ContractETag string           `json:"contract_etag,omitempty"`
+	VersionRange string           `json:"version_range,omitempty"`
+	At           *time.Time       `json:"at,omitempty"`       // evaluate as of this time (default now)
+	Priority     string           `json:"priority,omitempty"` // default "batch"
+}
+
+// BatchSimulateResponse reports the outcome of each row and the rules that
+// matched it, with totals.
+type BatchSimulateResponse struct {
+	ContractSemver string     `json:"contract_semver,omitempty"`
+	Rows           []BatchRow `json:"rows"`
+	// Outcomes and RuleMatches count rows per outcome and per matching
+	// rule.
+	Outcomes    map[Outcome]int `json:"outcomes"`
+	RuleMatches map[string]int  `json:"rule_matches"`
+	// Vectorized lists the rules evaluated column by column; the rest were
+	// evaluated row by row.
+	Vectorized []string `json:"vectorized"`
+	// Error is set instead when the batch was refused, as when its lane is
+	// full or its contract version cannot be served.
+	Error *ErrorEnvelope `json:"error,omitempty"`
+}
+
+// BatchRow is one row's simulated outcome, as Simulate would report it, and
+// the IDs of the rules that matched it in contract order.
+type BatchRow struct {
+	Outcome Outcome  `json:"outcome"`
+	Rules   []string `json:"rules,omitempty"`
+}
+
+// SimulateBatch simulates an operation over every row of req. It agrees
+// with calling Simulate on each row, but evaluates rules a column at a
+// time: each fact a rule compares is laid out as a column of values, and
+// the rule's condition is applied to the whole column in one pass. Rules
+// whose conditions read derived facts, identity facts or per-row param
+// overrides, use contains or set operators, or compare quantities with
+// units or timestamps, are evaluated row by row, as are rules in rollout;
+// like Simulate, a batch leaves out the shadow verdicts of rows outside a
+// rule's rollout.
+func (e *Engine) SimulateBatch(req *BatchSimulateRequest) (*BatchSimulateResponse, error) {
+	var out *BatchSimulateResponse
+	refused, err := e.schedule(context.Background(), req.Priority, PriorityBatch, func() (*Response, error) {
+		var err error
+		out, err = e.simulateBatch(req)
+		return nil, err
+	})
+	if refused != nil {
+		return &BatchSimulateResponse{Error: refused.Error}, nil
+	}
+	return out, err
+}
+
+// BatchTrailer totals a streamed batch simulation, as the fields of
+// BatchSimulateResponse do for a buffered one.
+type BatchTrailer struct {
+	ContractSemver string          `json:"contract_semver,omitempty"`
+	Rows           int             `json:"rows"`
+	Outcomes       map[Outcome]int `json:"outcomes"`
+	RuleMatches    map[string]int  `json:"rule_matches"`
+	// Error is set when the batch was refused before any row was
+	// simulated.
+	Error *ErrorEnvelope `json:"error,omitempty"`
+}
+
+// batchChunkRows is how many rows StreamBatch simulates at a time: enough
+// for the columnar evaluation to pay off, few enough that the first
+// results arrive promptly.
+const batchChunkRows = 1024
+
+// StreamBatch simulates like SimulateBatch, but instead of returning the
+// rows it calls emit with each one, in order, as soon as its chunk of rows
+// has been simulated, and returns only the totals. It stops at the first
+// error emit returns, or when ctx is done, and returns that error.
+func (e *Engine) StreamBatch(ctx context.Context, req *BatchSimulateRequest, emit func(index int, row BatchRow) error) (*BatchTrailer, error) {
+	var trailer *BatchTrailer
+	refused, err := e.schedule(ctx, req.Priority, PriorityBatch, func() (*Response, error) {
+		var err error
+		trailer, err = e.streamBatch(ctx, req, emit)
+		return nil, err
+	})
+	if refused != nil {
+		return &BatchTrailer{Error: refused.Error}, nil
+	}
+	return trailer, err
+}
+
+func (e *Engine) streamBatch(ctx context.Context, req *BatchSimulateRequest, emit func(int, BatchRow) error) (*BatchTrailer, error) {
+	plan, refused, err := e.planBatch(req)
+	if err != nil {
+		return nil, err
+	}
+	if refused != nil {
+		return &BatchTrailer{Error: refused}, nil
+	}
+	trailer := &BatchTrailer{
+		ContractSemver: plan.contract.Version,
+		Outcomes:       map[Outcome]int{},
+		RuleMatches:    map[string]int{},
+	}
+	for first := 0; first < len(req.Rows); first += batchChunkRows {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		chunk := req.Rows[first:min(first+batchChunkRows, len(req.Rows))]
+		rows, _, err := e.simulateRows(plan, chunk, first, trailer.Outcomes, trailer.RuleMatches)
+		if err != nil {
+			return nil, err
+		}
+		for i, row := range rows {
+			if err := emit(first+i, row); err != nil {
+				return nil, err
+			}
+			trailer.Rows++
+		}
+	}
+	return trailer, nil
+}
+
+func (e *Engine) simulateBatch(req *BatchSimulateRequest) (*BatchSimulateResponse, error) {
+	plan, refused, err := e.planBatch(req)
+	if err != nil {
+		return nil, err
+	}
+	if refused != nil {
+		return &BatchSimulateResponse{Error: refused}, nil
+	}
+	resp := &BatchSimulateResponse{
+		ContractSemver: plan.contract.Version,
+		Outcomes:       map[Outcome]int{},
+		RuleMatches:    map[string]int{},
+	}
+	resp.Rows, resp.Vectorized, err = e.simulateRows(plan, req.Rows, 0, resp.Outcomes, resp.RuleMatches)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// batchPlan is what every row of a batch simulation is evaluated against:
+// the contract, the operation's rules active at the batch's time, and the
+// bound params rows fall back to.
+type batchPlan struct {
+	contract *Contract
+	rules    []*RuleDef
+	params   map[string]any
+	at       time.Time
+}
+
+// planBatch resolves the contract and rules for req. A refusal the caller
+// should report, such as a stale ETag, is returned as an error envelope.
+func (e *Engine) planBatch(req *BatchSimulateRequest) (*batchPlan, *ErrorEnvelope, error) {
+	e.activateDue()
+	contract, etag, negErr := e.selectContract(req.VersionRange)
+	if negErr != nil {
+		return nil, negErr.Error, nil
+	}
+	if contract == nil {
+		return nil, nil, fmt.Errorf("no contract loaded")
+	}
+	if req.ContractETag != "" && req.ContractETag != etag {
+		return nil, contractVersionMismatch().Error, nil
+	}
+	op, ok := contract.Operations[req.Operation]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %s", ErrUnknownOperation, req.Operation)
+	}
+	at := e.now()
+	if req.At != nil {
+		at = *req.At
+	}
+
+	plan := &batchPlan{contract: contract, params: map[string]any{}, at: at}
+	for name := range contract.Params {
+		if val, ok := contract.ParamValue(name); ok {
+			plan.params[paramFactPrefix+name] = val
+		}
+	}
+	ruleSet := map[string]bool{}
+	for _, id := range op.ConstrainedBy {
+		ruleSet[id] = true
+	}
+	for i := range contract.Rules {
+		if ruleSet[contract.Rules[i].ID] && contract.Rules[i].ActiveAt(at) {
+			plan.rules = append(plan.rules, &contract.Rules[i])
+		}
+	}
+	return plan, nil, nil
+}
+
+// simulateRows evaluates rows, the batch's rows from index first on,
+// against plan, adding their outcomes and rule matches to the given totals,
+// and returns each row's result and the IDs of the rules evaluated column
+// by column.
+func (e *Engine) simulateRows(plan *batchPlan, rows []map[string]any, first int, outcomes map[Outcome]int, ruleMatches map[string]int) ([]BatchRow, []string, error) {
+	rules := plan.rules
+	b := &columnBatch{c: plan.contract, rows: rows, params: plan.params, columns: map[string]*column{}}
+	vectorized := []string{}
+	matched := make([][]bool, len(rules))
+	var rowWise []int
+	for i, rule := range rules {
+		// A rule in rollout matches only the rows its rollout admits.
+		if rule.Rollout == nil {
+			if m, ok := b.eval(rule.When); ok {
+				matched[i] = m
+				vectorized = append(vectorized, rule.ID)
+				continue
+			}
+		}
+		matched[i] = make([]bool, len(rows))
+		rowWise = append(rowWise, i)
+	}
+	if len(rowWise) > 0 {
+		for r, row := range rows {
+			facts := NewFactSet()
+			facts.evaluateAs(plan.contract, plan.at)
+			for name, val := range plan.params {
+				facts.Set(name, val)
+			}
+			for name, val := range row {
+				facts.Set(name, val)
+			}
+			if err := e.deriveFacts(context.Background(), ratesOnlyPorts{readOnlyPorts{e.ports}}, plan.contract, facts); err != nil {
+				return nil, nil, fmt.Errorf("row %d: derive facts: %w", first+r, err)
+			}
+			for _, i := range rowWise {
+				matched[i][r] = evalCondition(rules[i].When, facts) && inRollout(rules[i], facts)
+			}
+		}
+	}
+
+	out := make([]BatchRow, len(rows))
+	for r := range rows {
+		row := &out[r]
+		var verdicts []Verdict
+		for i, rule := range rules {
+			typ := verdictType(rule.Verdict)
+			if !matched[i][r] || typ == "" {
+				continue
+			}
+			row.Rules = append(row.Rules, rule.ID)
+			ruleMatches[rule.ID]++
+			verdicts = append(verdicts, Verdict{Type: typ})
+		}
+		row.Outcome = dryRunOutcome(resolveVerdicts(verdicts))
+		outcomes[row.Outcome]++
+	}
+	return out, vectorized, nil
+}
+
+// columnBatch holds the fact rows of a batch simulation, and the columns
+// extracted from them so far.
+type columnBatch struct {
+	c       *Contract
+	rows    []map[string]any
+	params  map[string]any
+	columns map[string]*column
+}
+
+// column is one fact across every row, with the views conditions compare
+// it through built on first use: as numbers, as conditions convert them,
+// and as the strings equals compares.
+type column struct {
+	vals  []any
+	nums  []float64
+	isNum []bool
+	strs  []string
+}
+
+func (b *columnBatch) column(fact string) *column {
+	if col, ok := b.columns[fact]; ok {
+		return col
+	}
+	col := &column{vals: make([]any, len(b.rows))}
+	for r, row := range b.rows {
+		v, ok := getPath(row, fact)
+		if !ok {
+			v, _ = getPath(b.params, fact)
+		}
+		col.vals[r] = v
+	}
+	b.columns[fact] = col
+	return col
+}
+
+func (col *column) numbers() ([]float64, []bool) {
+	if col.nums == nil {
+		col.nums, col.isNum = make([]float64, len(col.vals)), make([]bool, len(col.vals))
+		for r, v := range col.vals {
+			col.nums[r], col.isNum[r] = toFloat(v)
+		}
+	}
+	return col.nums, col.isNum
+}
+
+func (col *column) strings() []string {
+	if col.strs == nil {
+		col.strs = make([]string, len(col.vals))
+		for r, v := range col.vals {
+			col.strs[r] = fmt.Sprintf("%v", v)
+		}
+	}
+	return col.strs
+}
+
+// eval applies cond to every row as evalCondition would, and reports false
+// if cond must be evaluated row by row instead.
+func (b *columnBatch) eval(cond Condition) ([]bool, bool) {
+	if !b.columnar(cond) {
+		return nil, false
+	}
+	return b.apply(cond), true
+}
+
+// columnar reports whether cond only compares base facts with operands
+// that are the same for every row.
+func (b *columnBatch) columnar(cond Condition) bool {
+	switch {
+	case len(cond.All) > 0:
+		return b.allColumnar(cond.All)
+	case len(cond.Any) > 0:
+		return b.allColumnar(cond.Any)
+	case cond.Not != nil:
+		return b.columnar(*cond.Not)
+	case cond.isIdentity():
+		return false
+	case cond.Fact != "":
+		for name := range b.c.DerivedFacts {
+			if cond.Fact == name || strings.HasPrefix(cond.Fact, name+".") {
+				return false
+			}
+		}
+		if cond.Contains != nil || cond.isTime() || cond.isSet() {
+			return false
+		}
+		// Strings compared other than exactly are compared row by row.
+		if (cond.Equals != nil || len(cond.In) > 0) && !stringModeFor(b.c.StringCompare, cond.Compare).exact() {
+			return false
+		}
+		// Quantities with units are compared row by row.
+		if b.c.Facts[cond.Fact].Unit != "" {
+			return false
+		}
+		for _, v := range []any{cond.GreaterThan, cond.LessThan} {
+			if _, ok := b.operand(v).(string); ok {
+				return false
+			}
+		}
+		for _, v := range append([]any{cond.Equals, cond.GreaterThan, cond.LessThan}, cond.In...) {
+			if !b.constant(v) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (b *columnBatch) allColumnar(conds []Condition) bool {
+	for _, sub := range conds {
+		if !b.columnar(sub) {
+			return false
+		}
+	}
+	return true
+}
+
+// constant reports whether an operand is the same for every row: a
+// literal, or a param no row overrides.
+func (b *columnBatch) constant(operand any) bool {
+	name, ok := paramRef(operand)
+	if !ok {
+		return true
+	}
+	for _, row := range b.rows {
+		if _, ok := row[paramFactPrefix+name]; ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *columnBatch) operand(v any) any {
+	if name, ok := paramRef(v); ok {
+		return b.params[paramFactPrefix+name]
+	}
+	return v
+}
+
+// apply evaluates a columnar condition over every row.
+func (b *columnBatch) apply(cond Condition) []bool {
+	n := len(b.rows)
+	out := make([]bool, n)
+	switch {
+	case len(cond.All) > 0:
+		for r := range out {
+			out[r] = true
+		}
+		for _, sub := range cond.All {
+			m := b.apply(sub)
+			for r := range out {
+				out[r] = out[r] && m[r]
+			}
+		}
+	case len(cond.Any) > 0:
+		for _, sub := range cond.Any {
+			m := b.apply(sub)
+			for r := range out {
+				out[r] = out[r] || m[r]
+			}
+		}
+	case cond.Not != nil:
+		m := b.apply(*cond.Not)
+		for r := range out {
+			out[r] = !m[r]
+		}
+	case cond.Fact != "" && cond.Equals != nil:
+		b.equals(cond.Fact, cond.Equals, out)
+	case cond.Fact != "" && (cond.GreaterThan != nil || cond.LessThan != nil):
+		greater := cond.GreaterThan != nil
+		bound, ok := toFloat(b.operand(cond.GreaterThan))
+		if !greater {
+			bound, ok = toFloat(b.operand(cond.LessThan))
+		}
+		if !ok {
+			break
+		}
+		nums, isNum := b.column(cond.Fact).numbers()
+		for r := range out {
+			out[r] = isNum[r] && (greater && nums[r] > bound || !greater && nums[r] < bound)
+		}
+	case cond.Fact != "" && len(cond.In) > 0:
+		for _, v := range cond.In {
+			b.equals(cond.Fact, v, out)
+		}
+	default:
+		for r := range out {
+			out[r] = true
+		}
+	}
+	return out
+}
+
+// equals sets out for the rows whose fact equals operand.
+func (b *columnBatch) equals(fact string, operand any, out []bool) {
+	want := fmt.Sprintf("%v", b.operand(operand))
+	for r, s := range b.column(fact).strings() {
+		if s == want {
+			out[r] = true
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// batchContract guards testOp with numeric thresholds, a param, list
+// membership and a derived fact, so a batch mixes columnar and row-wise
+// rules.
+func batchContract() *Contract {
+	c := makeParamContract()
+	c.Facts["customer.status"] = FactDef{Source: "input"}
+	c.Facts["score"] = FactDef{Source: "input"}
+	c.DerivedFacts["risky"] = DerivedFactDef{
+		Derivation: Derivation{Fn: "greater_than", Args: []DerivationArg{{Fact: "score"}, {Value: 80.0}}},
+	}
+	c.Rules = append(c.Rules,
+		RuleDef{
+			ID:      "blocked",
+			When:    Condition{Fact: "customer.status", In: []any{"blocked", "closed"}},
+			Verdict: VerdictDef{Deny: &DenyVerdict{Code: "BLOCKED", Error: ErrorEnvelope{Code: "BLOCKED", HttpStatus: 403}}},
+		},
+		RuleDef{
+			ID: "large-trial",
+			When: Condition{All: []Condition{
+				{Fact: "amount", GreaterThan: 500.0},
+				{Not: &Condition{Fact: "customer.status", Equals: "active"}},
+			}},
+			Verdict: VerdictDef{Escalate: &EscalateVerdict{Queue: "review"}},
+		},
+		RuleDef{
+			ID:      "tiny",
+			When:    Condition{Any: []Condition{{Fact: "amount", LessThan: 1}, {Fact: "score", LessThan: 0}}},
+			Verdict: VerdictDef{Require: &RequireVerdict{Reason: "confirm"}},
+		},
+		RuleDef{
+			ID:      "risky",
+			When:    Condition{Fact: "risky", Equals: true},
+			Verdict: VerdictDef{Flag: &FlagVerdict{Code: "RISKY"}},
+		},
+	)
+	c.Operations["testOp"] = OperationDef{ConstrainedBy: []string{"over-limit", "blocked", "large-trial", "tiny", "risky"}}
+	return c
+}
+
+// batchRows returns n rows spread over the rules' thresholds, some with
+// facts missing or of the wrong type.
+func batchRows(n int) []map[string]any {
+	statuses := []any{"active", "trial", "blocked", "closed", 7, nil}
+	rows := make([]map[string]any, n)
+	for i := range rows {
+		row := map[string]any{
+			"amount":          float64(i % 900),
+			"customer.status": statuses[i%len(statuses)],
+			"score":           i % 97,
+		}
+		switch i % 11 {
+		case 0:
+			delete(row, "amount")
+		case 1:
+			row["amount"] = "600"
+		}
+		rows[i] = row
+	}
+	return rows
+}
+
+func TestEngine_SimulateBatch_agreesWithSimulate(t *testing.T) {
+	e := NewEngine(&mockPorts{})
+	e.LoadContract(batchContract(), "v1")
+	rows := batchRows(500)
+
+	resp, err := e.SimulateBatch(&BatchSimulateRequest{Operation: "testOp", Rows: rows})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"over-limit", "blocked", "large-trial", "tiny"}; !reflect.DeepEqual(resp.Vectorized, want) {
+		t.Errorf("expected the derived fact's rule evaluated row by row, got vectorized %v", resp.Vectorized)
+	}
+	outcomes := map[Outcome]int{}
+	for i, row := range rows {
+		want, err := e.Simulate(&SimulateRequest{Operation: "testOp", Facts: row})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rules []string
+		for _, v := range want.Verdicts {
+			rules = append(rules, v.Rule)
+		}
+		got := resp.Rows[i]
+		if got.Outcome != want.Outcome || !reflect.DeepEqual(got.Rules, rules) {
+			t.Fatalf("row %d %v: got %s %v, want %s %v", i, row, got.Outcome, got.Rules, want.Outcome, rules)
+		}
+		outcomes[want.Outcome]++
+	}
+	if !reflect.DeepEqual(resp.Outcomes, outcomes) {
+		t.Errorf("got outcome totals %v, want %v", resp.Outcomes, outcomes)
+	}
+}
+
+func TestEngine_SimulateBatch_paramOverridesAreEvaluatedPerRow(t *testing.T) {
+	e := NewEngine(&mockPorts{})
+	e.LoadContract(makeParamContract(), "v1")
+
+	resp, err := e.SimulateBatch(&BatchSimulateRequest{Operation: "testOp", Rows: []map[string]any{
+		{"amount": 150.0},
+		{"amount": 150.0, "params.limit": 200.0},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Vectorized) != 0 {
+		t.Errorf("expected a rule with an overridden param evaluated row by row, got %v", resp.Vectorized)
+	}
+	if resp.Rows[0].Outcome != "would_execute_with_flags" || resp.Rows[1].Outcome != "would_execute" {
+		t.Errorf("expected each row's own limit applied, got %+v", resp.Rows)
+	}
+	if resp.RuleMatches["over-limit"] != 1 {
+		t.Errorf("got rule matches %v", resp.RuleMatches)
+	}
+}
+
+func TestEngine_SimulateBatch_refusesAStaleETag(t *testing.T) {
+	e := NewEngine(&mockPorts{})
+	e.LoadContract(batchContract(), "v1")
+
+	resp, err := e.SimulateBatch(&BatchSimulateRequest{Operation: "testOp", ContractETag: "stale"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error == nil || resp.Rows != nil {
+		t.Errorf("expected the batch refused, got %+v", resp)
+	}
+}
+
+func TestEngine_StreamBatch_agreesWithSimulateBatch(t *testing.T) {
+	e := NewEngine(&mockPorts{})
+	e.LoadContract(batchContract(), "v1")
+	req := &BatchSimulateRequest{Operation: "testOp", Rows: batchRows(2*batchChunkRows + 10)}
+
+	want, err := e.SimulateBatch(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []BatchRow
+	trailer, err := e.StreamBatch(context.Background(), req, func(i int, row BatchRow) error {
+		if i != len(got) {
+			t.Fatalf("got row %d after %d rows", i, len(got))
+		}
+		got = append(got, row)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want.Rows) {
+		t.Error("expected the streamed rows to match the buffered ones")
+	}
+	if trailer.Rows != len(req.Rows) || !reflect.DeepEqual(trailer.Outcomes, want.Outcomes) || !reflect.DeepEqual(trailer.RuleMatches, want.RuleMatches) {
+		t.Errorf("got trailer %+v, want totals %v %v", trailer, want.Outcomes, want.RuleMatches)
+	}
+}
+
+func TestEngine_StreamBatch_stopsAtEmitError(t *testing.T) {
+	e := NewEngine(&mockPorts{})
+	e.LoadContract(batchContract(), "v1")
+	gone := errors.New("client gone")
+
+	var emitted int
+	_, err := e.StreamBatch(context.Background(), &BatchSimulateRequest{Operation: "testOp", Rows: batchRows(3 * batchChunkRows)}, func(int, BatchRow) error {
+		emitted++
+		return gone
+	})
+	if !errors.Is(err, gone) || emitted != 1 {
+		t.Errorf("expected the stream stopped after one row, got %v after %d", err, emitted)
+	}
+
+	trailer, err := e.StreamBatch(context.Background(), &BatchSimulateRequest{Operation: "testOp", ContractETag: "stale"}, nil)
+	if err != nil || trailer.Error == nil {
+		t.Errorf("expected a refusal in the trailer, got %+v, %v", trailer, err)
+	}
+}
+
+// The benchmarks simulate 10,000 rows in one batch and one row at a time.
+
+func BenchmarkSimulateBatch_10kRows(b *testing.B) {
+	e := NewEngine(&mockPorts{})
+	e.LoadContract(batchContract(), "v1")
+	req := &BatchSimulateRequest{Operation: "testOp", Rows: batchRows(10_000)}
+	for b.Loop() {
+		if _, err := e.SimulateBatch(req); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSimulate_10kRows(b *testing.B) {
+	e := NewEngine(&mockPorts{})
+	e.LoadContract(batchContract(), "v1")
+	rows := batchRows(10_000)
+	for b.Loop() {
+		for _, row := range rows {
+			if _, err := e.Simulate(&SimulateRequest{Operation: "testOp", Facts: row}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultCacheCapacity is how many responses an Engine caches unless
+// WithResponseCache says otherwise.
+const defaultCacheCapacity = 10_000
+
+// CacheDef marks an operation as a cacheable read, or a derived fact as
+// cacheable. Executed responses and computed values are reused for TTL
+// across requests that agree on the Key input facts, and dropped when the
+// contract changes.
+type CacheDef struct {
+	TTL string   `json:"ttl"` // Go duration, e.g. "30s"
+	Key []string `json:"key"` // input facts identifying the response
+}
+
+// Duration returns the parsed TTL, or 0 if it is missing or malformed.
+func (d CacheDef) Duration() time.Duration {
+	ttl, err := time.ParseDuration(d.TTL)
+	if err != nil || ttl < 0 {
+		return 0
+	}
+	return ttl
+}
+
+// CacheInfo describes how a response relates to the response cache.
+type CacheInfo struct {
+	// Hit reports that the response was served from the cache without
+	// evaluating rules or executing the operation.
+	Hit bool `json:"hit"`
+	// ETag is a weak entity tag for the output: equal outputs share it, so
+	// clients can revalidate with If-None-Match.
+	ETag    string    `json:"etag"`
+	Expires time.Time `json:"expires"`
+}
+
+// CacheStats counts response cache lookups for one operation.
+type CacheStats struct {
+	Hits    int64   `json:"hits"`
+	Misses  int64   `json:"misses"`
+	HitRate float64 `json:"hit_rate"`
+}
+
+// WithResponseCache bounds the response cache to capacity entries; 0
+// disables caching regardless of what the contract declares.
+func WithResponseCache(capacity int) Option {
+	return func(e *Engine) {
+		if capacity <= 0 {
+			e.cache = nil
+			return
+		}
+		e.cache = newResponseCache(capacity)
+	}
+}
+
+// CacheStats returns response cache lookups by operation since startup.
+func (e *Engine) CacheStats() map[string]CacheStats {
+	if e.cache == nil {
+		return map[string]CacheStats{}
+	}
+	return e.cache.stats()
+}
+
+// responseCache holds executed responses of cacheable operations.
+type responseCache struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]cacheEntry
+	counts   map[string]*CacheStats
+}
+
+type cacheEntry struct {
+	output  map[string]any
+	etag    string
+	expires time.Time
+}
+
+func newResponseCache(capacity int) *responseCache {
+	return &responseCache{
+		capacity: capacity,
+		entries:  map[string]cacheEntry{},
+		counts:   map[string]*CacheStats{},
+	}
+}
+
+// cacheKey returns the cache key for req against the contract with the
+// given ETag, and false if req may not be served from the cache: the
+// operation isn't cacheable, the request is a dry-run or asks for an
+// explanation, or it lacks one of the key facts.
+func cacheKey(op OperationDef, etag string, req *Request) (string, bool) {
+	if op.Cache == nil || op.Cache.Duration() == 0 || req.DryRun || req.Explain {
+		return "", false
+	}
+	parts := make([]any, len(op.Cache.Key))
+	for i, fact := range op.Cache.Key {
+		v, ok := req.Input[fact]
+		if !ok {
+			return "", false
+		}
+		parts[i] = v
+	}
+	data, err := json.Marshal(parts)
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.Sum256([]byte(etag + "\x00" + req.Operation + "\x00" + string(data)))
+	return hex.EncodeToString(sum[:]), true
+}
+
+// get returns the cached response for key, counting the lookup against
+// operation.
+func (c *responseCache) get(key, operation string, now time.Time) *Response {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.counts[operation]
+	if s == nil {
+		s = &CacheStats{}
+		c.counts[operation] = s
+	}
+	ent, ok := c.entries[key]
+	if ok && !now.Before(ent.expires) {
+		delete(c.entries, key)
+		ok = false
+	}
+	if !ok {
+		s.Misses++
+		return nil
+	}
+	s.Hits++
+	return &Response{
+		Outcome: OutcomeExecuted,
+		Output:  ent.output,
+		Cache:   &CacheInfo{Hit: true, ETag: ent.etag, Expires: ent.expires},
+	}
+}
+
+// put caches an executed response and stamps it with its cache info. When
+// the cache is full, expired entries are dropped first, then the entry
+// closest to expiry.
+func (c *responseCache) put(key string, resp *Response, ttl time.Duration, now time.Time) {
+	data, err := json.Marshal(resp.Output)
+	if err != nil {
+		return
+	}
+	sum := sha256.Sum256(data)
+	ent := cacheEntry{
+		output:  resp.Output,
+		etag:    fmt.Sprintf(`W/"%s"`, hex.EncodeToString(sum[:8])),
+		expires: now.Add(ttl),
+	}
+	resp.Cache = &CacheInfo{ETag: ent.etag, Expires: ent.expires}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.capacity {
+		c.evict(now)
+	}
+	c.entries[key] = ent
+}
+
+// evict makes room for one entry. Callers hold c.mu.
+func (c *responseCache) evict(now time.Time) {
+	var oldest string
+	for k, ent := range c.entries {
+		if !now.Before(ent.expires) {
+			delete(c.entries, k)
+			continue
+		}
+		if oldest == "" || ent.expires.Before(c.entries[oldest].expires) {
+			oldest = k
+		}
+	}
+	if len(c.entries) >= c.capacity {
+		delete(c.entries, oldest)
+	}
+}
+
+func (c *responseCache) stats() map[string]CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make(map[string]CacheStats, len(c.counts))
+	for op, s := range c.counts {
+		st := *s
+		if total := st.Hits + st.Misses; total > 0 {
+			st.HitRate = float64(st.Hits) / float64(total)
+		}
+		out[op] = st
+	}
+	return out
+}
+
+// ETagMatch reports whether an If-None-Match header value matches etag,
+// using the weak comparison RFC 9110 prescribes for If-None-Match.
+func ETagMatch(ifNoneMatch, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// validateCache checks an operation's cache declaration.
+func validateCache(c *Contract, name string, op OperationDef) []Diagnostic {
+	if op.Cache == nil {
+		return nil
+	}
+	var diags []Diagnostic
+	if ttl, err := time.ParseDuration(op.Cache.TTL); err != nil || ttl <= 0 {
+		diags = append(diags, Diagnostic{
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("operation %s: cache ttl %q is not a positive duration", name, op.Cache.TTL),
+		})
+	}
+	for _, fact := range op.Cache.Key {
+		if def, ok := c.Facts[fact]; !ok || def.Source != "input" {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("operation %s: cache key %q is not an input fact", name, fact),
+			})
+		}
+	}
+	if len(op.Transitions) > 0 {
+		diags = append(diags, Diagnostic{
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("operation %s is cacheable but declares state transitions; cached responses skip them", name),
+		})
+	}
+	return diags
+}
+
+// sortedOperations returns the contract's operation names in order.
+func sortedOperations(c *Contract) []string {
+	names := make([]string, 0, len(c.Operations))
+	for name := range c.Operations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,167 @@
+package engine
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// cachedContract declares testOp as cacheable by id for a minute.
+func cachedContract() *Contract {
+	c := makeMinimalContract()
+	c.Facts["id"] = FactDef{Source: "input"}
+	c.Operations["testOp"] = OperationDef{Cache: &CacheDef{TTL: "1m", Key: []string{"id"}}}
+	return c
+}
+
+func TestEngine_Evaluate_cacheServesRepeatReads(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	calls := 0
+	ports := &mockPorts{executeFunc: func(_ context.Context, _, _ string, input map[string]any) (map[string]any, error) {
+		calls++
+		return map[string]any{"id": input["id"], "n": float64(calls)}, nil
+	}}
+	e := NewEngine(ports, WithClock(func() time.Time { return now }))
+	e.LoadContract(cachedContract(), "v1")
+
+	eval := func(id string) *Response {
+		t.Helper()
+		resp, err := e.Evaluate(context.Background(), &Request{Operation: "testOp", Input: map[string]any{"id": id}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	first := eval("a")
+	second := eval("a")
+	if calls != 1 || first.Cache == nil || first.Cache.Hit || second.Cache == nil || !second.Cache.Hit {
+		t.Fatalf("expected one execution then a hit, got %d calls, %+v, %+v", calls, first.Cache, second.Cache)
+	}
+	if second.Output["n"] != 1.0 || second.Cache.ETag != first.Cache.ETag || !strings.HasPrefix(first.Cache.ETag, `W/"`) {
+		t.Errorf("hit must replay the output and ETag, got %v %q vs %q", second.Output, second.Cache.ETag, first.Cache.ETag)
+	}
+	if second.InvocationID == "" || second.InvocationID == first.InvocationID {
+		t.Errorf("hits get their own invocation ID, got %q", second.InvocationID)
+	}
+
+	eval("b")
+	if calls != 2 {
+		t.Errorf("a different key must miss, got %d calls", calls)
+	}
+
+	now = now.Add(time.Minute)
+	if resp := eval("a"); resp.Cache.Hit || calls != 3 {
+		t.Errorf("expired entry must miss, got %+v after %d calls", resp.Cache, calls)
+	}
+
+	e.LoadContract(cachedContract(), "v2")
+	if resp := eval("a"); resp.Cache.Hit {
+		t.Error("a new contract must not serve the old contract's entries")
+	}
+
+	stats := e.CacheStats()["testOp"]
+	if stats.Hits != 1 || stats.Misses != 4 || stats.HitRate != 0.2 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestEngine_Evaluate_cacheBypassed(t *testing.T) {
+	calls := 0
+	ports := &mockPorts{executeFunc: func(context.Context, string, string, map[string]any) (map[string]any, error) {
+		calls++
+		return map[string]any{}, nil
+	}}
+
+	cases := map[string]struct {
+		opts []Option
+		req  Request
+	}{
+		"dry run":     {req: Request{Operation: "testOp", Input: map[string]any{"id": "a"}, DryRun: true}},
+		"explain":     {req: Request{Operation: "testOp", Input: map[string]any{"id": "a"}, Explain: true}},
+		"missing key": {req: Request{Operation: "testOp", Input: map[string]any{}}},
+		"disabled":    {opts: []Option{WithResponseCache(0)}, req: Request{Operation: "testOp", Input: map[string]any{"id": "a"}}},
+	}
+	for name, tc := range cases {
+		e := NewEngine(ports, tc.opts...)
+		e.LoadContract(cachedContract(), "v1")
+		for i := 0; i < 2; i++ {
+			req := tc.req
+			resp, err := e.Evaluate(context.Background(), &req)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			if resp.Cache != nil && resp.Cache.Hit {
+				t.Errorf("%s: unexpected cache hit", name)
+			}
+		}
+	}
+	if calls != 6 { // dry runs don't execute
+		t.Errorf("expected every live request to execute, got %d", calls)
+	}
+}
+
+func TestEngine_Evaluate_deniesAreNotCached(t *testing.T) {
+	c := cachedContract()
+	c.Facts["blocked"] = FactDef{Source: "input"}
+	c.Rules = []RuleDef{{ID: "block", When: Condition{Fact: "blocked", Equals: true}, Verdict: VerdictDef{Deny: &DenyVerdict{Code: "BLOCKED"}}}}
+	c.Operations["testOp"] = OperationDef{ConstrainedBy: []string{"block"}, Cache: c.Operations["testOp"].Cache}
+	e := NewEngine(&mockPorts{})
+	e.LoadContract(c, "v1")
+
+	denied, _ := e.Evaluate(context.Background(), &Request{Operation: "testOp", Input: map[string]any{"id": "a", "blocked": true}})
+	allowed, _ := e.Evaluate(context.Background(), &Request{Operation: "testOp", Input: map[string]any{"id": "a"}})
+	if denied.Outcome != "denied" || denied.Cache != nil || allowed.Outcome != "executed" || allowed.Cache.Hit {
+		t.Errorf("expected an uncached deny then a fresh execution, got %+v, %+v", denied, allowed)
+	}
+}
+
+func TestResponseCache_evictsClosestToExpiry(t *testing.T) {
+	now := time.Now()
+	c := newResponseCache(2)
+	c.put("short", &Response{}, time.Second, now)
+	c.put("long", &Response{}, time.Hour, now)
+	c.put("new", &Response{}, time.Minute, now)
+	if _, ok := c.entries["short"]; ok || len(c.entries) != 2 {
+		t.Errorf("expected the soonest-expiring entry evicted, got %v", c.entries)
+	}
+}
+
+func TestETagMatch(t *testing.T) {
+	etag := `W/"abc"`
+	for header, want := range map[string]bool{
+		`W/"abc"`:      true,
+		`"abc"`:        true,
+		`"x", W/"abc"`: true,
+		`*`:            true,
+		`"abd"`:        false,
+		``:             false,
+	} {
+		if got := ETagMatch(header, etag); got != want {
+			t.Errorf("%q: expected %v", header, want)
+		}
+	}
+}
+
+func TestValidate_cacheDeclarations(t *testing.T) {
+	c := cachedContract()
+	c.Facts["balance"] = FactDef{Source: "port:repo"}
+	c.Operations["bad"] = OperationDef{
+		Cache:       &CacheDef{TTL: "soon", Key: []string{"balance", "nope"}},
+		Transitions: []EntityTransitionRef{{Entity: "invoice", To: "paid"}},
+	}
+	var got []string
+	for _, d := range Validate(c, time.Now()) {
+		got = append(got, d.String())
+	}
+	want := []string{
+		`error: operation bad: cache ttl "soon" is not a positive duration`,
+		`error: operation bad: cache key "balance" is not an input fact`,
+		`error: operation bad: cache key "nope" is not an input fact`,
+		`warning: operation bad is cacheable but declares state transitions; cached responses skip them`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected diagnostics:\n%s", strings.Join(got, "\n"))
+	}
+}
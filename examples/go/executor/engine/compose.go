@@ -0,0 +1,232 @@
+package engine
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+)
+
+// baseContract is a compiled base layer named by the domain's imports.
+type baseContract struct {
+	name     string
+	contract *Contract
+}
+
+// resolveImports compiles the base contracts a domain imports, using load to
+// obtain each base's sources, and composes them under the domain. Domains
+// without imports are returned unchanged. Either way the result's queue
+// catalog is checked; see checkQueues.
+func resolveImports(cc *compileCache, domain *Contract, load func(name string) ([]sourceFile, error)) (*Contract, error) {
+	if len(domain.Imports) == 0 {
+		if err := checkQueues(domain); err != nil {
+			return nil, err
+		}
+		return domain, nil
+	}
+	bases := make([]baseContract, 0, len(domain.Imports))
+	for _, name := range domain.Imports {
+		files, err := load(name)
+		if err != nil {
+			return nil, fmt.Errorf("import %q: %w", name, err)
+		}
+		c, err := cc.compile("import "+name, files)
+		if err != nil {
+			return nil, fmt.Errorf("import %q: %w", name, err)
+		}
+		if len(c.Imports) > 0 {
+			return nil, fmt.Errorf("import %q: base contracts cannot import others (imports %v)", name, c.Imports)
+		}
+		bases = append(bases, baseContract{name: name, contract: c})
+	}
+	c, err := composeContract(domain, bases)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkQueues(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// composeContract layers a domain contract over its base contracts. Bases are
+// applied in import order and the domain last; each layer may extend the
+// result of the layers before it, under these rules:
+//
+//   - Params, facts, derived facts and rules are inherited. Redeclaring an
+//     inherited name with an identical definition is a no-op; changing it
+//     requires override: true on the new definition, and override: true on
+//     a name that isn't inherited is an error.
+//   - A rule marked final cannot be overridden.
+//   - Entities, operations, queues, experiments, personas and data-use
+//     restrictions cannot be overridden; a differing redeclaration is an
+//     error.
+//   - Lint settings accumulate: a layer's lint severities replace those
+//     of the layers before it.
+//   - A layer's timezone and default string comparison replace those of
+//     the layers before it.
+//   - Inherited rules constrain the operations named in their applies_to,
+//     or every operation if applies_to contains "*". Domain rules are
+//     attached only through constrained_by, as in an uncomposed contract.
+//
+// The composed contract takes its version and imports from the domain.
+func composeContract(domain *Contract, bases []baseContract) (*Contract, error) {
+	out := &Contract{
+		Version:      domain.Version,
+		Imports:      domain.Imports,
+		Params:       map[string]ParamDef{},
+		Facts:        map[string]FactDef{},
+		DerivedFacts: map[string]DerivedFactDef{},
+		Operations:   map[string]OperationDef{},
+		Entities:     map[string]EntityDef{},
+		Queues:       map[string]QueueDef{},
+		Experiments:  map[string]ExperimentDef{},
+		Personas:     map[string]PersonaDef{},
+		DataUse:      map[string][]string{},
+	}
+	inherited := map[string]bool{} // rule IDs first declared by a base
+	origin := map[string]string{}  // "kind:name" → layer that last defined it
+
+	layer := func(name string, c *Contract) error {
+		for param, def := range c.Params {
+			prev, ok := out.Params[param]
+			same := reflect.DeepEqual(prev.Default, def.Default) && prev.Description == def.Description
+			if err := checkOverride("param", param, name, origin, ok, def.Override, same); err != nil {
+				return err
+			}
+			out.Params[param] = def
+		}
+		for fact, def := range c.Facts {
+			prev, ok := out.Facts[fact]
+			if err := checkOverride("fact", fact, name, origin, ok, def.Override, sameFact(prev, def)); err != nil {
+				return err
+			}
+			out.Facts[fact] = def
+		}
+		for fact, def := range c.DerivedFacts {
+			prev, ok := out.DerivedFacts[fact]
+			same := reflect.DeepEqual(prev.Derivation, def.Derivation)
+			if err := checkOverride("derived fact", fact, name, origin, ok, def.Override, same); err != nil {
+				return err
+			}
+			out.DerivedFacts[fact] = def
+		}
+		for _, rule := range c.Rules {
+			i := slices.IndexFunc(out.Rules, func(r RuleDef) bool { return r.ID == rule.ID })
+			if i >= 0 && out.Rules[i].Final && !sameRule(out.Rules[i], rule) {
+				return fmt.Errorf("%s: rule %q is final in %s and cannot be overridden", name, rule.ID, origin["rule:"+rule.ID])
+			}
+			same := i >= 0 && sameRule(out.Rules[i], rule)
+			if err := checkOverride("rule", rule.ID, name, origin, i >= 0, rule.Override, same); err != nil {
+				return err
+			}
+			if i >= 0 {
+				out.Rules[i] = rule
+			} else {
+				out.Rules = append(out.Rules, rule)
+			}
+		}
+		for ent, def := range c.Entities {
+			if prev, ok := out.Entities[ent]; ok && !reflect.DeepEqual(prev, def) {
+				return fmt.Errorf("%s: entity %q conflicts with its definition in %s", name, ent, origin["entity:"+ent])
+			}
+			out.Entities[ent] = def
+			origin["entity:"+ent] = name
+		}
+		for op, def := range c.Operations {
+			if prev, ok := out.Operations[op]; ok && !reflect.DeepEqual(prev, def) {
+				return fmt.Errorf("%s: operation %q conflicts with its definition in %s", name, op, origin["operation:"+op])
+			}
+			out.Operations[op] = def
+			origin["operation:"+op] = name
+		}
+		for q, def := range c.Queues {
+			if prev, ok := out.Queues[q]; ok && prev != def {
+				return fmt.Errorf("%s: queue %q conflicts with its definition in %s", name, q, origin["queue:"+q])
+			}
+			out.Queues[q] = def
+			origin["queue:"+q] = name
+		}
+		for x, def := range c.Experiments {
+			if prev, ok := out.Experiments[x]; ok && !reflect.DeepEqual(prev, def) {
+				return fmt.Errorf("%s: experiment %q conflicts with its definition in %s", name, x, origin["experiment:"+x])
+			}
+			out.Experiments[x] = def
+			origin["experiment:"+x] = name
+		}
+		for p, def := range c.Personas {
+			if prev, ok := out.Personas[p]; ok && !reflect.DeepEqual(prev, def) {
+				return fmt.Errorf("%s: persona %q conflicts with its definition in %s", name, p, origin["persona:"+p])
+			}
+			out.Personas[p] = def
+			origin["persona:"+p] = name
+		}
+		for fact, purposes := range c.DataUse {
+			if prev, ok := out.DataUse[fact]; ok && !sameDataUse(prev, purposes) {
+				return fmt.Errorf("%s: data_use for %q conflicts with its definition in %s", name, fact, origin["data_use:"+fact])
+			}
+			out.DataUse[fact] = purposes
+			origin["data_use:"+fact] = name
+		}
+		out.Lint = mergeLint(out.Lint, c.Lint)
+		if c.Timezone != "" {
+			out.Timezone = c.Timezone
+		}
+		if c.StringCompare != nil {
+			out.StringCompare = c.StringCompare
+		}
+		return nil
+	}
+
+	for _, b := range bases {
+		if err := layer(b.name, b.contract); err != nil {
+			return nil, err
+		}
+		for _, r := range b.contract.Rules {
+			inherited[r.ID] = true
+		}
+	}
+	if err := layer("domain", domain); err != nil {
+		return nil, err
+	}
+
+	// Attach inherited rules to the operations they apply to. Operations are
+	// copied so the domain's ConstrainedBy slices aren't aliased.
+	for name, op := range out.Operations {
+		cb := slices.Clone(op.ConstrainedBy)
+		for _, rule := range out.Rules {
+			if !inherited[rule.ID] || slices.Contains(cb, rule.ID) {
+				continue
+			}
+			if slices.Contains(rule.AppliesTo, "*") || slices.Contains(rule.AppliesTo, name) {
+				cb = append(cb, rule.ID)
+			}
+		}
+		op.ConstrainedBy = cb
+		out.Operations[name] = op
+	}
+	return out, nil
+}
+
+// checkOverride enforces the override rules for one named definition in
+// layer and records the layer as its origin.
+func checkOverride(kind, name, layer string, origin map[string]string, exists, override, same bool) error {
+	key := kind + ":" + name
+	switch {
+	case !exists && override:
+		return fmt.Errorf("%s: %s %q sets override but no imported contract defines it", layer, kind, name)
+	case exists && !same && !override:
+		return fmt.Errorf("%s: %s %q redefines the definition in %s; set override: true to replace it", layer, kind, name, origin[key])
+	}
+	origin[key] = layer
+	return nil
+}
+
+func sameFact(a, b FactDef) bool {
+	a.Override, b.Override = false, false
+	return a == b
+}
+
+func sameRule(a, b RuleDef) bool {
+	a.Override, b.Override = false, false
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,200 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func baseWithGlobalDeny() *Contract {
+	return &Contract{
+		Facts: map[string]FactDef{
+			"customer.status": {Source: "input", OnMissing: "skip"},
+		},
+		Rules: []RuleDef{{
+			ID:        "global-deny",
+			AppliesTo: []string{"*"},
+			When:      Condition{Fact: "customer.status", Equals: "suspended"},
+			Verdict:   VerdictDef{Deny: &DenyVerdict{Code: "SUSPENDED"}},
+			Final:     true,
+		}},
+	}
+}
+
+func TestComposeContract_globalRuleConstrainsEveryOperation(t *testing.T) {
+	domain := makeMinimalContract()
+	domain.Operations["otherOp"] = OperationDef{}
+
+	c, err := composeContract(domain, []baseContract{{"common", baseWithGlobalDeny()}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for name, op := range c.Operations {
+		if !slices.Contains(op.ConstrainedBy, "global-deny") {
+			t.Errorf("operation %s not constrained by global-deny: %v", name, op.ConstrainedBy)
+		}
+	}
+	if len(domain.Operations["testOp"].ConstrainedBy) != 0 {
+		t.Error("expected domain operations not to be modified")
+	}
+
+	e := NewEngine(&mockPorts{})
+	e.LoadContract(c, "v1")
+	resp, _ := e.Evaluate(context.Background(), &Request{
+		Operation: "otherOp",
+		Input:     map[string]any{"customer.status": "suspended"},
+	})
+	if resp.Outcome != "denied" {
+		t.Errorf("expected denied, got %s", resp.Outcome)
+	}
+}
+
+func TestComposeContract_scopedRuleOnlyConstrainsNamedOperations(t *testing.T) {
+	base := baseWithGlobalDeny()
+	base.Rules[0].AppliesTo = []string{"testOp"}
+	domain := makeMinimalContract()
+	domain.Operations["otherOp"] = OperationDef{}
+
+	c, err := composeContract(domain, []baseContract{{"common", base}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Contains(c.Operations["testOp"].ConstrainedBy, "global-deny") {
+		t.Error("expected testOp to be constrained")
+	}
+	if slices.Contains(c.Operations["otherOp"].ConstrainedBy, "global-deny") {
+		t.Error("expected otherOp not to be constrained")
+	}
+}
+
+func TestComposeContract_identicalRedeclarationIsAllowed(t *testing.T) {
+	domain := makeMinimalContract()
+	domain.Facts["customer.status"] = FactDef{Source: "input", OnMissing: "skip"}
+
+	if _, err := composeContract(domain, []baseContract{{"common", baseWithGlobalDeny()}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestComposeContract_changedFactRequiresOverride(t *testing.T) {
+	domain := makeMinimalContract()
+	domain.Facts["customer.status"] = FactDef{Source: "port:crm", OnMissing: "deny"}
+
+	_, err := composeContract(domain, []baseContract{{"common", baseWithGlobalDeny()}})
+	if err == nil || !strings.Contains(err.Error(), "set override: true") {
+		t.Fatalf("expected override error, got %v", err)
+	}
+
+	domain.Facts["customer.status"] = FactDef{Source: "port:crm", OnMissing: "deny", Override: true}
+	c, err := composeContract(domain, []baseContract{{"common", baseWithGlobalDeny()}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.Facts["customer.status"].Source != "port:crm" {
+		t.Errorf("expected domain definition to win, got %+v", c.Facts["customer.status"])
+	}
+}
+
+func TestComposeContract_overrideWithoutInheritedDefinitionFails(t *testing.T) {
+	domain := makeMinimalContract()
+	domain.Facts["invoice.total"] = FactDef{Source: "input", Override: true}
+
+	_, err := composeContract(domain, []baseContract{{"common", baseWithGlobalDeny()}})
+	if err == nil || !strings.Contains(err.Error(), "no imported contract defines it") {
+		t.Fatalf("expected error, got %v", err)
+	}
+}
+
+func TestComposeContract_finalRuleCannotBeOverridden(t *testing.T) {
+	domain := makeMinimalContract()
+	domain.Rules = []RuleDef{{
+		ID:       "global-deny",
+		When:     Condition{Fact: "customer.status", Equals: "never"},
+		Verdict:  VerdictDef{Deny: &DenyVerdict{Code: "SUSPENDED"}},
+		Override: true,
+	}}
+
+	_, err := composeContract(domain, []baseContract{{"common", baseWithGlobalDeny()}})
+	if err == nil || !strings.Contains(err.Error(), "is final") {
+		t.Fatalf("expected final error, got %v", err)
+	}
+}
+
+func TestComposeContract_overriddenRuleKeepsInheritedAttachment(t *testing.T) {
+	base := baseWithGlobalDeny()
+	base.Rules[0].Final = false
+	domain := makeMinimalContract()
+	domain.Rules = []RuleDef{{
+		ID:        "global-deny",
+		AppliesTo: []string{"*"},
+		When:      Condition{Fact: "customer.status", Equals: "banned"},
+		Verdict:   VerdictDef{Deny: &DenyVerdict{Code: "BANNED"}},
+		Override:  true,
+	}}
+
+	c, err := composeContract(domain, []baseContract{{"common", base}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(c.Rules) != 1 || c.Rules[0].Verdict.Deny.Code != "BANNED" {
+		t.Errorf("expected domain rule to replace base rule, got %+v", c.Rules)
+	}
+	if !slices.Contains(c.Operations["testOp"].ConstrainedBy, "global-deny") {
+		t.Error("expected overridden rule to stay attached")
+	}
+}
+
+func TestComposeContract_conflictingOperationFails(t *testing.T) {
+	base := makeMinimalContract()
+	domain := makeMinimalContract()
+	domain.Operations["testOp"] = OperationDef{ConstrainedBy: []string{"x"}}
+
+	if _, err := composeContract(domain, []baseContract{{"common", base}}); err == nil {
+		t.Fatal("expected operation conflict error")
+	}
+}
+
+func TestCompileDir_resolvesImportsFromSiblingDirectory(t *testing.T) {
+	root := t.TempDir()
+	write := func(rel, src string) {
+		p := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("common/base.cue", `facts: "customer.status": {source: "input", required: false}`)
+	write("shop/shop.cue", `
+imports: ["common"]
+operations: Checkout: {constrained_by: [], transitions: []}
+`)
+
+	c, err := CompileDir(filepath.Join(root, "shop"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := c.Facts["customer.status"]; !ok {
+		t.Error("expected inherited fact customer.status")
+	}
+	if _, ok := c.Operations["Checkout"]; !ok {
+		t.Error("expected domain operation Checkout")
+	}
+}
+
+func TestCompileDir_missingImportFails(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "shop"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "shop", "shop.cue"), []byte(`imports: ["nope"]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CompileDir(filepath.Join(root, "shop")); err == nil {
+		t.Fatal("expected error for missing import")
+	}
+}
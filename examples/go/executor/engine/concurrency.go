@@ -0,0 +1,165 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ConcurrencyDef limits how many executions of an operation run at once.
+// Requests beyond Max wait for a slot; once Queue requests are waiting,
+// further ones are refused with the throttled outcome.
+type ConcurrencyDef struct {
+	Max   int `json:"max"`
+	Queue int `json:"queue"`
+}
+
+// WithConcurrencyLimits sets per-operation concurrency limits that take
+// precedence over those declared in the contract.
+func WithConcurrencyLimits(limits map[string]ConcurrencyDef) Option {
+	return func(e *Engine) { e.concurrency = limits }
+}
+
+// limiter bounds the executions of one operation.
+type limiter struct {
+	def     ConcurrencyDef
+	slots   chan struct{}
+	mu      sync.Mutex
+	waiting int
+}
+
+// acquire takes an execution slot, waiting while fewer than def.Queue other
+// requests are. It reports false if the queue is full or ctx ends first.
+func (l *limiter) acquire(ctx context.Context) bool {
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+	}
+	l.mu.Lock()
+	if l.waiting >= l.def.Queue {
+		l.mu.Unlock()
+		return false
+	}
+	l.waiting++
+	l.mu.Unlock()
+	defer func() {
+		l.mu.Lock()
+		l.waiting--
+		l.mu.Unlock()
+	}()
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (l *limiter) release() { <-l.slots }
+
+// concurrencyLimit returns the limit in force for an operation: the
+// engine's configured limit, else the contract's.
+func (e *Engine) concurrencyLimit(operation string, op OperationDef) (ConcurrencyDef, bool) {
+	if def, ok := e.concurrency[operation]; ok {
+		return def, def.Max > 0
+	}
+	if op.Concurrency != nil {
+		return *op.Concurrency, op.Concurrency.Max > 0
+	}
+	return ConcurrencyDef{}, false
+}
+
+// limiterFor returns the operation's limiter, replacing it if the limit has
+// changed since it was made. Executions holding a slot on a replaced limiter
+// release it there.
+func (e *Engine) limiterFor(operation string, def ConcurrencyDef) *limiter {
+	e.limitersMu.Lock()
+	defer e.limitersMu.Unlock()
+	if l, ok := e.limiters[operation]; ok && l.def == def {
+		return l
+	}
+	if e.limiters == nil {
+		e.limiters = map[string]*limiter{}
+	}
+	l := &limiter{def: def, slots: make(chan struct{}, def.Max)}
+	e.limiters[operation] = l
+	return l
+}
+
+// acquireExecution takes an execution slot for a live request. It returns
+// the function that gives the slot back, or the throttled response to send
+// instead of executing.
+func (e *Engine) acquireExecution(ctx context.Context, operation string, op OperationDef) (func(), *Response) {
+	def, limited := e.concurrencyLimit(operation, op)
+	if !limited {
+		return func() {}, nil
+	}
+	l := e.limiterFor(operation, def)
+	if !l.acquire(ctx) {
+		return nil, throttled(fmt.Sprintf("Too many concurrent %s executions", operation),
+			map[string]any{"max_concurrent": def.Max, "queue": def.Queue})
+	}
+	return l.release, nil
+}
+
+// throttled is the response to a request refused for lack of capacity.
+func throttled(message string, details map[string]any) *Response {
+	return &Response{
+		Outcome: OutcomeThrottled,
+		Error: &ErrorEnvelope{
+			Code:       "THROTTLED",
+			Message:    message,
+			HttpStatus: 429,
+			Category:   "system",
+			Retryable:  true,
+			Suggestion: "Retry after a short delay",
+			Details:    details,
+		},
+	}
+}
+
+// ParseConcurrencyLimits parses a comma-separated list of operation=max:queue
+// pairs, such as "ProcessPayment=5:20". The queue defaults to 0.
+func ParseConcurrencyLimits(spec string) (map[string]ConcurrencyDef, error) {
+	limits := map[string]ConcurrencyDef{}
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		operation, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("concurrency limit %q: expected operation=max:queue", pair)
+		}
+		maxStr, queueStr, _ := strings.Cut(value, ":")
+		max, err := strconv.Atoi(maxStr)
+		if err != nil || max < 1 {
+			return nil, fmt.Errorf("concurrency limit %q: max must be a positive integer", pair)
+		}
+		queue := 0
+		if queueStr != "" {
+			if queue, err = strconv.Atoi(queueStr); err != nil || queue < 0 {
+				return nil, fmt.Errorf("concurrency limit %q: queue must be a non-negative integer", pair)
+			}
+		}
+		limits[strings.TrimSpace(operation)] = ConcurrencyDef{Max: max, Queue: queue}
+	}
+	return limits, nil
+}
+
+// validateConcurrency checks an operation's concurrency declaration.
+func validateConcurrency(name string, op OperationDef) []Diagnostic {
+	if op.Concurrency == nil {
+		return nil
+	}
+	if op.Concurrency.Max < 1 || op.Concurrency.Queue < 0 {
+		return []Diagnostic{{
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("operation %s: concurrency needs max >= 1 and queue >= 0", name),
+		}}
+	}
+	return nil
+}
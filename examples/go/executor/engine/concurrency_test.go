@@ -0,0 +1,126 @@
+package engine
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEngine_Evaluate_concurrencyLimitQueuesThenThrottles(t *testing.T) {
+	started := make(chan struct{}, 10)
+	unblock := make(chan struct{})
+	ports := &mockPorts{executeFunc: func(context.Context, string, string, map[string]any) (map[string]any, error) {
+		started <- struct{}{}
+		<-unblock
+		return map[string]any{}, nil
+	}}
+	c := makeMinimalContract()
+	c.Operations["testOp"] = OperationDef{Concurrency: &ConcurrencyDef{Max: 1, Queue: 1}}
+	e := NewEngine(ports)
+	e.LoadContract(c, "v1")
+
+	results := make(chan *Response, 2)
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, _ := e.Evaluate(context.Background(), &Request{Operation: "testOp"})
+			results <- resp
+		}()
+	}
+	<-started // one executing
+	waitFor(t, func() bool {
+		l := e.limiterFor("testOp", ConcurrencyDef{Max: 1, Queue: 1})
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		return l.waiting == 1
+	})
+
+	resp, err := e.Evaluate(context.Background(), &Request{Operation: "testOp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Outcome != "throttled" || resp.Error == nil || resp.Error.Code != "THROTTLED" || resp.Error.HttpStatus != 429 || !resp.Error.Retryable {
+		t.Fatalf("expected throttled envelope with the queue full, got %+v", resp)
+	}
+
+	close(unblock)
+	wg.Wait()
+	close(results)
+	for r := range results {
+		if r.Outcome != "executed" {
+			t.Errorf("queued request must execute once a slot frees, got %s", r.Outcome)
+		}
+	}
+}
+
+func TestEngine_Evaluate_configuredLimitOverridesContract(t *testing.T) {
+	unblock := make(chan struct{})
+	ports := &mockPorts{executeFunc: func(context.Context, string, string, map[string]any) (map[string]any, error) {
+		<-unblock
+		return map[string]any{}, nil
+	}}
+	c := makeMinimalContract()
+	c.Operations["testOp"] = OperationDef{Concurrency: &ConcurrencyDef{Max: 100, Queue: 100}}
+	e := NewEngine(ports, WithConcurrencyLimits(map[string]ConcurrencyDef{"testOp": {Max: 1}}))
+	e.LoadContract(c, "v1")
+
+	done := make(chan struct{})
+	go func() {
+		e.Evaluate(context.Background(), &Request{Operation: "testOp"})
+		close(done)
+	}()
+	waitFor(t, func() bool { return len(e.limiterFor("testOp", ConcurrencyDef{Max: 1}).slots) == 1 })
+
+	resp, _ := e.Evaluate(context.Background(), &Request{Operation: "testOp"})
+	if resp.Outcome != "throttled" {
+		t.Errorf("expected the configured limit to apply, got %s", resp.Outcome)
+	}
+	if resp, _ := e.Evaluate(context.Background(), &Request{Operation: "testOp", DryRun: true}); resp.Outcome != "would_execute" {
+		t.Errorf("dry-runs don't take a slot, got %s", resp.Outcome)
+	}
+	close(unblock)
+	<-done
+}
+
+func TestLimiter_cancelledWhileQueuedGivesUp(t *testing.T) {
+	l := &limiter{def: ConcurrencyDef{Max: 1, Queue: 5}, slots: make(chan struct{}, 1)}
+	l.slots <- struct{}{}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if l.acquire(ctx) {
+		t.Error("expected acquire to give up when the context ends")
+	}
+	if l.waiting != 0 {
+		t.Errorf("waiter must leave the queue, got %d", l.waiting)
+	}
+}
+
+func TestParseConcurrencyLimits(t *testing.T) {
+	got, err := ParseConcurrencyLimits("ProcessPayment=5:20, GetInvoice=50")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["ProcessPayment"] != (ConcurrencyDef{Max: 5, Queue: 20}) || got["GetInvoice"] != (ConcurrencyDef{Max: 50}) {
+		t.Errorf("unexpected limits %v", got)
+	}
+	for _, bad := range []string{"ProcessPayment", "ProcessPayment=0", "ProcessPayment=5:-1", "ProcessPayment=x:1"} {
+		if _, err := ParseConcurrencyLimits(bad); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
+
+// waitFor polls cond until it holds or a second passes.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not reached")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
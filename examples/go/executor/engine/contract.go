@@ -1,26 +1,173 @@
 package engine
 
 import (
+	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"cuelang.org/go/cue"
-	"cuelang.org/go/cue/cuecontext"
 )
 
 // Discovery is the response from /.well-known/covenant.
 type Discovery struct {
-	Version      string `json:"version"`
-	Service      string `json:"service"`
-	Description  string `json:"description"`
-	ContractETag string `json:"contract_etag"`
-	Persona      string `json:"persona"`
-	Contracts    struct {
-		Files []string `json:"files"`
-	} `json:"contracts"`
+	Version      string        `json:"version"`
+	Service      string        `json:"service"`
+	Description  string        `json:"description"`
+	ContractETag string        `json:"contract_etag"`
+	Persona      string        `json:"persona"`
+	Contracts    ContractFiles `json:"contracts"`
+	// Queues is the contract's escalation queue catalog, so services that
+	// route escalations know each queue's SLA and owner.
+	Queues map[string]QueueDef `json:"queues,omitempty"`
+
+	// Scheduled is a contract published ahead of its activation time.
+	Scheduled *ScheduledContract `json:"scheduled,omitempty"`
+
+	// Channels offers the contract on each channel, keyed by name. The
+	// published channel is the contract above.
+	Channels map[string]*ChannelContract `json:"channels,omitempty"`
+
+	// ProtocolVersions lists the wire protocol versions the deployment's
+	// executors serve; see ProtocolVersion. Absent means version 1 only.
+	ProtocolVersions []int `json:"protocol_versions,omitempty"`
+}
+
+// Contract channels. Authors push changes to the draft channel, validate and
+// simulate them there, then promote the draft to published. Executors follow
+// the published channel unless configured otherwise.
+const (
+	ChannelPublished = "published"
+	ChannelDraft     = "draft"
+)
+
+// ChannelContract is the contract on one channel.
+type ChannelContract struct {
+	ContractETag string              `json:"contract_etag"`
+	Contracts    ContractFiles       `json:"contracts"`
+	Queues       map[string]QueueDef `json:"queues,omitempty"`
+}
+
+// UseChannel points the discovery document at the contract on the named
+// channel. The published channel, or "", leaves it as it is; any other
+// channel replaces the contract and drops the schedule, which applies only
+// to the published channel.
+func (d *Discovery) UseChannel(name string) error {
+	if name == "" || name == ChannelPublished {
+		return nil
+	}
+	ch, ok := d.Channels[name]
+	if !ok {
+		return fmt.Errorf("contract server offers no %q channel", name)
+	}
+	d.ContractETag, d.Contracts, d.Queues, d.Scheduled = ch.ContractETag, ch.Contracts, ch.Queues, nil
+	return nil
+}
+
+// ContractFiles locates the sources of one contract on the contract server.
+type ContractFiles struct {
+	Files []string `json:"files"`
+	// Bases lists the files of each base contract the server offers
+	// for import, keyed by name.
+	Bases map[string][]string `json:"bases,omitempty"`
+	// Bindings maps environment name to the URL of its param bindings.
+	Bindings map[string]string `json:"bindings,omitempty"`
+	// Checksums maps the URL of every file above to the hex SHA-256 of its
+	// contents. The contract ETag is ManifestETag(Checksums).
+	Checksums map[string]string `json:"checksums,omitempty"`
+
+	// ETag is the contract ETag the discovery document gave these files.
+	// Each fetch sends it as If-Match, so the server refuses to serve
+	// files from a contract that has changed since; see ErrTornRead.
+	ETag string `json:"-"`
+}
+
+// ErrTornRead reports that the contract changed on the server while its
+// files were being fetched. Fetch the discovery document again and retry.
+var ErrTornRead = errors.New("contract changed while its files were being fetched")
+
+// ErrChecksum reports a contract file, or the checksum manifest itself,
+// that does not match what discovery published.
+var ErrChecksum = errors.New("contract checksum mismatch")
+
+// ManifestETag is the contract ETag of a checksum manifest: the first 12
+// hex digits of a SHA-256 over its "<url> <checksum>" lines in URL order.
+func ManifestETag(checksums map[string]string) string {
+	urls := make([]string, 0, len(checksums))
+	for u := range checksums {
+		urls = append(urls, u)
+	}
+	sort.Strings(urls)
+	h := sha256.New()
+	for _, u := range urls {
+		fmt.Fprintf(h, "%s %s\n", u, checksums[u])
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))[:12]
+}
+
+// verifyManifest checks that the manifest covers every listed file and
+// hashes to the contract ETag. Servers that publish no manifest are
+// trusted as before.
+func (cf ContractFiles) verifyManifest() error {
+	if cf.Checksums == nil {
+		return nil
+	}
+	urls := slices.Clone(cf.Files)
+	for _, files := range cf.Bases {
+		urls = append(urls, files...)
+	}
+	for _, u := range cf.Bindings {
+		urls = append(urls, u)
+	}
+	for _, u := range urls {
+		if _, ok := cf.Checksums[u]; !ok {
+			return fmt.Errorf("%w: no checksum for %s", ErrChecksum, u)
+		}
+	}
+	if cf.ETag != "" && ManifestETag(cf.Checksums) != cf.ETag {
+		return fmt.Errorf("%w: manifest does not hash to contract ETag %s", ErrChecksum, cf.ETag)
+	}
+	return nil
+}
+
+// fetch fetches one of the contract's files and checks it against the
+// manifest. Transient failures are retried.
+func (cf ContractFiles) fetch(serverURL, path string) ([]byte, error) {
+	var data []byte
+	var err error
+	for attempt := 1; ; attempt++ {
+		data, err = fetchFile(serverURL+path, cf.ETag)
+		if err == nil || !transient(err) || attempt == fetchAttempts {
+			break
+		}
+		time.Sleep(time.Duration(attempt) * fetchRetryDelay)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("fetch %s: %w", path, err)
+	}
+	if want, ok := cf.Checksums[path]; ok {
+		if got := fmt.Sprintf("%x", sha256.Sum256(data)); got != want {
+			return nil, fmt.Errorf("%w: %s has SHA-256 %s, manifest says %s", ErrChecksum, path, got, want)
+		}
+	}
+	return data, nil
+}
+
+// ScheduledContract is a pending contract and the moment it takes effect.
+type ScheduledContract struct {
+	ContractETag string        `json:"contract_etag"`
+	ActivateAt   time.Time     `json:"activate_at"`
+	Contracts    ContractFiles `json:"contracts"`
 }
 
 // FetchDiscovery fetches and parses the discovery document.
@@ -35,55 +182,284 @@ func FetchDiscovery(serverURL string) (*Discovery, error) {
 	if err := json.NewDecoder(resp.Body).Decode(&disc); err != nil {
 		return nil, fmt.Errorf("decode discovery: %w", err)
 	}
+	disc.Contracts.ETag = disc.ContractETag
+	if disc.Scheduled != nil {
+		disc.Scheduled.Contracts.ETag = disc.Scheduled.ContractETag
+	}
+	for _, ch := range disc.Channels {
+		ch.Contracts.ETag = ch.ContractETag
+	}
 	return &disc, nil
 }
 
 // LoadContract fetches CUE files listed in the discovery doc, compiles them
-// with the CUE Go SDK, and extracts a Contract struct.
+// with the CUE Go SDK, and extracts a Contract struct. Base contracts the
+// domain imports are fetched from the discovery doc's bases and composed in.
+// A contract that does not compile fails with a *ContractError locating
+// each problem.
 func LoadContract(serverURL string, disc *Discovery) (*Contract, error) {
-	ctx := cuecontext.New()
+	return LoadContractFiles(serverURL, disc.Contracts)
+}
+
+// LoadContractFiles is LoadContract for an explicit file set, such as a
+// scheduled contract's.
+func LoadContractFiles(serverURL string, cf ContractFiles) (*Contract, error) {
+	return loadContractFiles(newCompileCache(), serverURL, cf)
+}
 
-	var unified cue.Value
-	for _, filePath := range disc.Contracts.Files {
-		data, err := fetchFile(serverURL + filePath)
+func loadContractFiles(cc *compileCache, serverURL string, cf ContractFiles) (*Contract, error) {
+	if err := cf.verifyManifest(); err != nil {
+		return nil, err
+	}
+	files, err := cf.fetchFiles(cc, serverURL, cf.Files)
+	if err != nil {
+		return nil, err
+	}
+	domain, err := cc.compile("", files)
+	if err != nil {
+		return nil, err
+	}
+	return resolveImports(cc, domain, func(name string) ([]sourceFile, error) {
+		paths, ok := cf.Bases[name]
+		if !ok {
+			return nil, fmt.Errorf("not served by the contract server")
+		}
+		return cf.fetchFiles(cc, serverURL, paths)
+	})
+}
+
+// FetchBindings fetches the param bindings cf lists for env.
+func FetchBindings(serverURL string, cf ContractFiles, env string) (map[string]any, error) {
+	path, ok := cf.Bindings[env]
+	if !ok {
+		return nil, fmt.Errorf("no bindings for environment %q", env)
+	}
+	if err := cf.verifyManifest(); err != nil {
+		return nil, err
+	}
+	data, err := cf.fetch(serverURL, path)
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]any
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// Contract files are fetched fetchConcurrency at a time, each up to
+// fetchAttempts times.
+const (
+	fetchConcurrency = 8
+	fetchAttempts    = 3
+	fetchRetryDelay  = 100 * time.Millisecond
+)
+
+// fetchFiles fetches the files at paths, in parallel, except those whose
+// manifest checksum matches a file cc already holds. Files are returned in
+// the order of paths, whatever order they arrive in, and if several fail
+// the error is the first one's in that order. Once one fails, files not yet
+// requested are not.
+func (cf ContractFiles) fetchFiles(cc *compileCache, serverURL string, paths []string) ([]sourceFile, error) {
+	files := make([]sourceFile, len(paths))
+	var missing []int
+	for i, filePath := range paths {
+		files[i].path = filePath
+		sum, listed := cf.Checksums[filePath]
+		if data, ok := cc.fetched(sum); listed && ok {
+			files[i].data = data
+			continue
+		}
+		missing = append(missing, i)
+	}
+
+	errs := make([]error, len(paths))
+	sem := make(chan struct{}, fetchConcurrency)
+	var failed atomic.Bool
+	var wg sync.WaitGroup
+	for _, i := range missing {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if failed.Load() {
+				return
+			}
+			if files[i].data, errs[i] = cf.fetch(serverURL, paths[i]); errs[i] != nil {
+				failed.Store(true)
+			}
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, i := range missing {
+		cc.store(checksum(files[i].data), files[i].data)
+	}
+	return files, nil
+}
+
+// CompileFiles reads CUE contract files from the local filesystem and compiles
+// them in the given order. Used by tests and tooling that work on a contracts
+// checkout rather than a running contract server. Imports are not resolved;
+// use CompileDir for composed contracts.
+func CompileFiles(paths ...string) (*Contract, error) {
+	files, err := readFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+	return compileContract(files)
+}
+
+// CompileDir compiles the .cue files in a domain directory, resolving each
+// import from the sibling directory of that name (contracts/billing imports
+// "common" from contracts/common), as the contract server lays them out.
+func CompileDir(dir string) (*Contract, error) {
+	load := func(d string) ([]sourceFile, error) {
+		paths, err := filepath.Glob(filepath.Join(d, "*.cue"))
 		if err != nil {
-			return nil, fmt.Errorf("fetch %s: %w", filePath, err)
+			return nil, err
 		}
+		sort.Strings(paths)
+		return readFiles(paths)
+	}
+	files, err := load(dir)
+	if err != nil {
+		return nil, err
+	}
+	cc := newCompileCache()
+	domain, err := cc.compile("", files)
+	if err != nil {
+		return nil, err
+	}
+	return resolveImports(cc, domain, func(name string) ([]sourceFile, error) {
+		return load(filepath.Join(filepath.Dir(filepath.Clean(dir)), name))
+	})
+}
+
+// CompileSources compiles a domain contract from in-memory files, such as an
+// editor's unsaved buffers, keyed by the name errors are reported under.
+// Files compile in name order, and each import is resolved with load.
+func CompileSources(files map[string][]byte, load func(name string) (map[string][]byte, error)) (*Contract, error) {
+	return compileSources(newCompileCache(), files, load)
+}
 
-		v := ctx.CompileBytes(data)
-		if v.Err() != nil {
-			return nil, fmt.Errorf("compile %s: %w", filePath, v.Err())
+func compileSources(cc *compileCache, files map[string][]byte, load func(name string) (map[string][]byte, error)) (*Contract, error) {
+	domain, err := cc.compile("", sortedSources(files))
+	if err != nil {
+		return nil, err
+	}
+	return resolveImports(cc, domain, func(name string) ([]sourceFile, error) {
+		files, err := load(name)
+		if err != nil {
+			return nil, err
 		}
+		return sortedSources(files), nil
+	})
+}
 
-		if !unified.Exists() {
-			unified = v
-		} else {
-			unified = unified.Unify(v)
+func sortedSources(files map[string][]byte) []sourceFile {
+	out := make([]sourceFile, 0, len(files))
+	for path, data := range files {
+		out = append(out, sourceFile{path: path, data: data})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].path < out[j].path })
+	return out
+}
+
+func readFiles(paths []string) ([]sourceFile, error) {
+	files := make([]sourceFile, 0, len(paths))
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", p, err)
 		}
+		files = append(files, sourceFile{path: p, data: data})
 	}
+	return files, nil
+}
+
+// sourceFile is one fetched CUE contract file.
+type sourceFile struct {
+	path string
+	data []byte
+}
 
-	if !unified.Exists() {
-		return nil, fmt.Errorf("no contract files loaded")
+// ContractError is a contract that failed to compile. Errors lists every
+// problem CUE reported, each located in the contract sources when CUE gives
+// a position, so authors can see exactly where to look. Compile functions
+// and LoadContract return one, possibly wrapped; use errors.As.
+type ContractError struct {
+	Errors []Diagnostic
+	err    error
+}
+
+// Error describes the first problem and how many more there are.
+func (e *ContractError) Error() string {
+	first := e.Errors[0]
+	msg := first.Message
+	if first.File != "" {
+		msg = fmt.Sprintf("%s:%d:%d: %s", first.File, first.Line, first.Column, msg)
 	}
-	if unified.Err() != nil {
-		return nil, fmt.Errorf("unified contract error: %w", unified.Err())
+	if n := len(e.Errors) - 1; n > 0 {
+		msg += fmt.Sprintf(" (and %d more)", n)
 	}
+	return msg
+}
 
-	return extractContract(unified)
+func (e *ContractError) Unwrap() error { return e.err }
+
+// compileContract compiles and unifies CUE sources in order, then extracts
+// a Contract from the unified value. It fails with a *ContractError.
+func compileContract(files []sourceFile) (*Contract, error) {
+	return newCompileCache().compile("", files)
 }
 
-func fetchFile(url string) ([]byte, error) {
-	resp, err := http.Get(url)
+// fetchFile fetches a contract file, requiring it to belong to the contract
+// with the given ETag if one is set.
+func fetchFile(url, etag string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if etag != "" {
+		req.Header.Set("If-Match", `"`+etag+`"`)
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		return nil, ErrTornRead
+	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+		return nil, statusError(resp.StatusCode)
 	}
 	return io.ReadAll(resp.Body)
 }
 
+// statusError is a contract server response other than 200 OK.
+type statusError int
+
+func (e statusError) Error() string { return fmt.Sprintf("HTTP %d", int(e)) }
+
+// transient reports whether a failed fetch may succeed if retried: the
+// connection failed, or the server is overloaded or erring. A torn read is
+// not retried here; the whole load starts over.
+func transient(err error) bool {
+	var status statusError
+	if errors.As(err, &status) {
+		return status >= 500 || status == http.StatusTooManyRequests
+	}
+	return !errors.Is(err, ErrTornRead)
+}
+
 // extractContract walks the unified CUE value tree and populates a Contract.
 func extractContract(v cue.Value) (*Contract, error) {
 	c := &Contract{
@@ -93,6 +469,15 @@ func extractContract(v cue.Value) (*Contract, error) {
 		Entities:     make(map[string]EntityDef),
 	}
 
+	if err := extractVersion(v, c); err != nil {
+		return nil, err
+	}
+	if err := extractImports(v, c); err != nil {
+		return nil, err
+	}
+	if err := extractParams(v, c); err != nil {
+		return nil, err
+	}
 	if err := extractFacts(v, c); err != nil {
 		return nil, err
 	}
@@ -108,10 +493,80 @@ func extractContract(v cue.Value) (*Contract, error) {
 	if err := extractEntities(v, c); err != nil {
 		return nil, err
 	}
+	if err := extractQueues(v, c); err != nil {
+		return nil, err
+	}
+	if err := extractExperiments(v, c); err != nil {
+		return nil, err
+	}
+	if err := extractLint(v, c); err != nil {
+		return nil, err
+	}
+	if err := extractPersonas(v, c); err != nil {
+		return nil, err
+	}
+	if err := extractDataUse(v, c); err != nil {
+		return nil, err
+	}
+	if err := extractTimezone(v, c); err != nil {
+		return nil, err
+	}
+	if err := extractStringCompare(v, c); err != nil {
+		return nil, err
+	}
 
 	return c, nil
 }
 
+// extractVersion reads the optional top-level semantic version.
+func extractVersion(v cue.Value, c *Contract) error {
+	verVal := v.LookupPath(cue.ParsePath("version"))
+	if !verVal.Exists() {
+		return nil
+	}
+	ver, err := verVal.String()
+	if err != nil {
+		return fmt.Errorf("version: %w", err)
+	}
+	if _, err := parseSemver(ver); err != nil {
+		return fmt.Errorf("version: %w", err)
+	}
+	c.Version = ver
+	return nil
+}
+
+// extractImports reads the optional list of base contracts to compose over.
+func extractImports(v cue.Value, c *Contract) error {
+	impVal := v.LookupPath(cue.ParsePath("imports"))
+	if !impVal.Exists() {
+		return nil
+	}
+	if err := impVal.Decode(&c.Imports); err != nil {
+		return fmt.Errorf("imports: %w", err)
+	}
+	return nil
+}
+
+// extractParams reads declared params and exposes each as a params.<name>
+// fact, so conditions can test switches directly.
+func extractParams(v cue.Value, c *Contract) error {
+	pVal := v.LookupPath(cue.ParsePath("params"))
+	if !pVal.Exists() {
+		return nil
+	}
+	jsonBytes, err := pVal.MarshalJSON()
+	if err != nil {
+		return fmt.Errorf("marshal params: %w", err)
+	}
+	if err := json.Unmarshal(jsonBytes, &c.Params); err != nil {
+		return fmt.Errorf("unmarshal params: %w", err)
+	}
+	for name := range c.Params {
+		c.Facts[paramFactPrefix+name] = FactDef{Source: "param", OnMissing: "skip"}
+	}
+	return nil
+}
+
 func extractFacts(v cue.Value, c *Contract) error {
 	factsVal := v.LookupPath(cue.ParsePath("facts"))
 	if !factsVal.Exists() {
@@ -128,7 +583,7 @@ func extractFacts(v cue.Value, c *Contract) error {
 		fv := iter.Value()
 
 		def := FactDef{
-			Required:  true,  // default
+			Required:  true,           // default
 			OnMissing: "system_error", // default
 		}
 
@@ -141,6 +596,21 @@ func extractFacts(v cue.Value, c *Contract) error {
 		if om, err := fv.LookupPath(cue.ParsePath("on_missing")).String(); err == nil {
 			def.OnMissing = om
 		}
+		if ms, err := fv.LookupPath(cue.ParsePath("max_staleness")).String(); err == nil {
+			def.MaxStaleness = ms
+		}
+		if st, err := fv.LookupPath(cue.ParsePath("on_stale")).String(); err == nil {
+			def.OnStale = st
+		}
+		if u, err := fv.LookupPath(cue.ParsePath("unit")).String(); err == nil {
+			def.Unit = u
+		}
+		if ty, err := fv.LookupPath(cue.ParsePath("type")).String(); err == nil {
+			def.Type = ty
+		}
+		if ov, err := fv.LookupPath(cue.ParsePath("override")).Bool(); err == nil {
+			def.Override = ov
+		}
 
 		c.Facts[name] = def
 	}
@@ -173,7 +643,18 @@ func extractDerivedFacts(v cue.Value, c *Contract) error {
 			return fmt.Errorf("unmarshal derivation for %s: %w", name, err)
 		}
 
-		c.DerivedFacts[name] = DerivedFactDef{Derivation: d}
+		def := DerivedFactDef{Derivation: d}
+		if ov, err := fv.LookupPath(cue.ParsePath("override")).Bool(); err == nil {
+			def.Override = ov
+		}
+		if cv := fv.LookupPath(cue.ParsePath("cache")); cv.Exists() {
+			var cd CacheDef
+			if err := cv.Decode(&cd); err != nil {
+				return fmt.Errorf("decode cache for %s: %w", name, err)
+			}
+			def.Cache = &cd
+		}
+		c.DerivedFacts[name] = def
 	}
 	return nil
 }
@@ -192,6 +673,94 @@ func extractRules(v cue.Value, c *Contract) error {
 	return json.Unmarshal(jsonBytes, &c.Rules)
 }
 
+// extractQueues reads the optional escalation queue catalog.
+func extractQueues(v cue.Value, c *Contract) error {
+	qVal := v.LookupPath(cue.ParsePath("queues"))
+	if !qVal.Exists() {
+		return nil
+	}
+	if err := qVal.Decode(&c.Queues); err != nil {
+		return fmt.Errorf("queues: %w", err)
+	}
+	return nil
+}
+
+// extractExperiments reads the optional experiments.
+func extractExperiments(v cue.Value, c *Contract) error {
+	eVal := v.LookupPath(cue.ParsePath("experiments"))
+	if !eVal.Exists() {
+		return nil
+	}
+	if err := eVal.Decode(&c.Experiments); err != nil {
+		return fmt.Errorf("experiments: %w", err)
+	}
+	return nil
+}
+
+// extractPersonas reads the optional persona declarations.
+func extractPersonas(v cue.Value, c *Contract) error {
+	pVal := v.LookupPath(cue.ParsePath("personas"))
+	if !pVal.Exists() {
+		return nil
+	}
+	if err := pVal.Decode(&c.Personas); err != nil {
+		return fmt.Errorf("personas: %w", err)
+	}
+	return nil
+}
+
+// extractDataUse reads the optional data-use policy.
+func extractDataUse(v cue.Value, c *Contract) error {
+	dVal := v.LookupPath(cue.ParsePath("data_use"))
+	if !dVal.Exists() {
+		return nil
+	}
+	if err := dVal.Decode(&c.DataUse); err != nil {
+		return fmt.Errorf("data_use: %w", err)
+	}
+	return nil
+}
+
+// extractTimezone reads the optional timezone.
+func extractTimezone(v cue.Value, c *Contract) error {
+	tzVal := v.LookupPath(cue.ParsePath("timezone"))
+	if !tzVal.Exists() {
+		return nil
+	}
+	tz, err := tzVal.String()
+	if err != nil {
+		return fmt.Errorf("timezone: %w", err)
+	}
+	c.Timezone = tz
+	return nil
+}
+
+// extractStringCompare reads the optional default string comparison.
+func extractStringCompare(v cue.Value, c *Contract) error {
+	scVal := v.LookupPath(cue.ParsePath("string_compare"))
+	if !scVal.Exists() {
+		return nil
+	}
+	if err := scVal.Decode(&c.StringCompare); err != nil {
+		return fmt.Errorf("string_compare: %w", err)
+	}
+	return nil
+}
+
+// extractLint reads the optional lint block.
+func extractLint(v cue.Value, c *Contract) error {
+	lintVal := v.LookupPath(cue.ParsePath("lint"))
+	if !lintVal.Exists() {
+		return nil
+	}
+	var lc LintConfig
+	if err := lintVal.Decode(&lc); err != nil {
+		return fmt.Errorf("lint: %w", err)
+	}
+	c.Lint = &lc
+	return nil
+}
+
 func extractOperations(v cue.Value, c *Contract) error {
 	opsVal := v.LookupPath(cue.ParsePath("operations"))
 	if !opsVal.Exists() {
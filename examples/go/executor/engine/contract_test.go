@@ -0,0 +1,145 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoadContractFiles_verifiesChecksums(t *testing.T) {
+	const src = `operations: Pay: {constrained_by: [], transitions: []}`
+	served := src
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, served)
+	}))
+	defer srv.Close()
+
+	manifest := func() ContractFiles {
+		sums := map[string]string{"/contracts/shop/c.cue": fmt.Sprintf("%x", sha256.Sum256([]byte(src)))}
+		return ContractFiles{Files: []string{"/contracts/shop/c.cue"}, Checksums: sums, ETag: ManifestETag(sums)}
+	}
+
+	if _, err := LoadContractFiles(srv.URL, manifest()); err != nil {
+		t.Fatalf("expected matching files to load, got %v", err)
+	}
+
+	cf := manifest()
+	cf.ETag = "0123456789ab"
+	if _, err := LoadContractFiles(srv.URL, cf); !errors.Is(err, ErrChecksum) {
+		t.Errorf("expected a manifest that does not match the ETag rejected, got %v", err)
+	}
+
+	cf = manifest()
+	cf.Files = append(cf.Files, "/contracts/shop/extra.cue")
+	if _, err := LoadContractFiles(srv.URL, cf); !errors.Is(err, ErrChecksum) {
+		t.Errorf("expected a file missing from the manifest rejected, got %v", err)
+	}
+
+	served = src + "\n// tampered"
+	if _, err := LoadContractFiles(srv.URL, manifest()); !errors.Is(err, ErrChecksum) {
+		t.Errorf("expected a modified file rejected, got %v", err)
+	}
+}
+
+func TestDiscovery_UseChannel(t *testing.T) {
+	const doc = `{"contract_etag": "pub", "contracts": {"files": ["/contracts/shop/c.cue"]},
+		"scheduled": {"contract_etag": "next", "activate_at": "2030-01-01T00:00:00Z", "contracts": {"files": []}},
+		"queues": {"review": {"sla": "4h", "owner": "ops"}},
+		"channels": {"draft": {"contract_etag": "dft", "contracts": {"files": ["/contracts/shop.draft/c.cue"]},
+			"queues": {"review": {"sla": "1h", "owner": "ops"}}}}}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, doc)
+	}))
+	defer srv.Close()
+
+	disc, err := FetchDiscovery(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := disc.UseChannel(ChannelPublished); err != nil || disc.ContractETag != "pub" || disc.Scheduled == nil {
+		t.Fatalf("expected the published channel to leave the document as is, got %v", err)
+	}
+	if err := disc.UseChannel(ChannelDraft); err != nil {
+		t.Fatal(err)
+	}
+	if disc.ContractETag != "dft" || disc.Contracts.ETag != "dft" || disc.Contracts.Files[0] != "/contracts/shop.draft/c.cue" {
+		t.Errorf("expected the draft contract, got %s %v", disc.ContractETag, disc.Contracts.Files)
+	}
+	if disc.Queues["review"].SLA != "1h" {
+		t.Errorf("expected the draft queue catalog, got %v", disc.Queues)
+	}
+	if disc.Scheduled != nil {
+		t.Error("expected the schedule dropped off the published channel")
+	}
+	if err := disc.UseChannel("canary"); err == nil {
+		t.Error("expected an unknown channel to be an error")
+	}
+}
+
+func TestLoadContractFiles_fetchesInParallelInOrder(t *testing.T) {
+	files := contractTree(30)
+	var inFlight, maxInFlight atomic.Int32
+	var mu sync.Mutex
+	attempts := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		attempts[r.URL.Path]++
+		first := attempts[r.URL.Path] == 1
+		mu.Unlock()
+		if first && strings.HasSuffix(r.URL.Path, "7.cue") {
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(files[strings.TrimPrefix(r.URL.Path, "/contracts/shop/")])
+	}))
+	defer srv.Close()
+
+	cf := treeFiles(files)
+	got, err := cf.fetchFiles(newCompileCache(), srv.URL, cf.Files)
+	if err != nil {
+		t.Fatalf("expected transient failures retried, got %v", err)
+	}
+	for i, f := range got {
+		if f.path != cf.Files[i] || checksum(f.data) != cf.Checksums[f.path] {
+			t.Fatalf("file %d: got %s, want %s", i, f.path, cf.Files[i])
+		}
+	}
+	if m := maxInFlight.Load(); m < 2 || m > fetchConcurrency {
+		t.Errorf("expected between 2 and %d fetches in flight, got %d", fetchConcurrency, m)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if attempts["/contracts/shop/f017.cue"] != 2 || attempts["/contracts/shop/f016.cue"] != 1 {
+		t.Errorf("expected only failed fetches retried, got %v", attempts)
+	}
+}
+
+func TestLoadContractFiles_doesNotRetryClientErrors(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	_, err := LoadContractFiles(srv.URL, ContractFiles{Files: []string{"/contracts/shop/c.cue"}})
+	if err == nil || !strings.Contains(err.Error(), "HTTP 404") || requests.Load() != 1 {
+		t.Errorf("expected one attempt failing with HTTP 404, got %v after %d requests", err, requests.Load())
+	}
+}
@@ -0,0 +1,66 @@
+package engine
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryCounterStore is a CounterStore in process memory, for a single
+// executor and for tests. It is safe for concurrent use.
+type MemoryCounterStore struct {
+	keep time.Duration
+
+	mu       sync.Mutex
+	counters map[memoryCounter]int64
+	latest   time.Time // latest time added at
+	swept    time.Time // when windows were last forgotten
+}
+
+type memoryCounter struct {
+	key   string
+	start time.Time
+	end   time.Time
+}
+
+// NewMemoryCounterStore returns a MemoryCounterStore that forgets windows
+// once they ended more than keep before the latest time counted at.
+func NewMemoryCounterStore(keep time.Duration) *MemoryCounterStore {
+	return &MemoryCounterStore{keep: keep, counters: map[memoryCounter]int64{}}
+}
+
+// Add implements CounterStore.
+func (s *MemoryCounterStore) Add(_ context.Context, key string, window time.Duration, at time.Time, delta int64) (int64, error) {
+	start := at.Truncate(window)
+	c := memoryCounter{key: key, start: start, end: start.Add(window)}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if at.After(s.latest) {
+		s.latest = at
+	}
+	// Sweep at most once per keep, so each Add stays cheap.
+	if s.latest.Sub(s.swept) > s.keep {
+		for k := range s.counters {
+			if s.latest.Sub(k.end) > s.keep {
+				delete(s.counters, k)
+			}
+		}
+		s.swept = s.latest
+	}
+	if delta == 0 {
+		return s.counters[c], nil
+	}
+	s.counters[c] += delta
+	return s.counters[c], nil
+}
+
+// Sum implements CounterStore.
+func (s *MemoryCounterStore) Sum(_ context.Context, key string, window time.Duration, from, to time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var total int64
+	for start := from.Truncate(window); !start.After(to); start = start.Add(window) {
+		total += s.counters[memoryCounter{key: key, start: start, end: start.Add(window)}]
+	}
+	return total, nil
+}
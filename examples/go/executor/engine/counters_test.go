@@ -0,0 +1,52 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryCounterStore_Add(t *testing.T) {
+	s := NewMemoryCounterStore(2 * time.Hour)
+	ctx := context.Background()
+	t0 := *mustTime(t, "2026-03-01T10:00:00Z")
+
+	s.Add(ctx, "cust_1", time.Hour, t0, 1)
+	if n, _ := s.Add(ctx, "cust_1", time.Hour, t0.Add(30*time.Minute), 2); n != 3 {
+		t.Errorf("expected 3 in the first window, got %d", n)
+	}
+	if n, _ := s.Add(ctx, "cust_1", time.Hour, t0.Add(time.Hour), 1); n != 1 {
+		t.Errorf("expected a fresh count in the next window, got %d", n)
+	}
+	if n, _ := s.Add(ctx, "cust_2", time.Hour, t0, 0); n != 0 {
+		t.Errorf("expected other keys uncounted, got %d", n)
+	}
+
+	// Windows that ended more than keep ago are forgotten.
+	s.Add(ctx, "cust_3", time.Hour, t0.Add(4*time.Hour), 1)
+	if n, _ := s.Add(ctx, "cust_1", time.Hour, t0, 0); n != 0 {
+		t.Errorf("expected the first window forgotten, got %d", n)
+	}
+	if len(s.counters) != 2 {
+		t.Errorf("expected 2 windows left, got %d", len(s.counters))
+	}
+}
+
+func TestMemoryCounterStore_Sum(t *testing.T) {
+	s := NewMemoryCounterStore(24 * time.Hour)
+	ctx := context.Background()
+	t0 := *mustTime(t, "2026-03-01T10:00:00Z")
+
+	s.Add(ctx, "cust_1", time.Hour, t0, 1)
+	s.Add(ctx, "cust_1", time.Hour, t0.Add(90*time.Minute), 2)
+	s.Add(ctx, "cust_1", time.Hour, t0.Add(3*time.Hour), 4)
+	if n, _ := s.Sum(ctx, "cust_1", time.Hour, t0.Add(30*time.Minute), t0.Add(2*time.Hour)); n != 3 {
+		t.Errorf("expected the two windows overlapping the range summed, got %d", n)
+	}
+	if n, _ := s.Sum(ctx, "cust_2", time.Hour, t0, t0.Add(3*time.Hour)); n != 0 {
+		t.Errorf("expected other keys uncounted, got %d", n)
+	}
+	if len(s.counters) != 3 {
+		t.Errorf("expected Sum to create no counters, got %d", len(s.counters))
+	}
+}
@@ -0,0 +1,195 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// RatesPort is the port the convert derivation reads exchange rates from.
+// Its facts are currency pairs: Get(ctx, "rates", "EUR/USD", input) answers
+// how many US dollars one euro buys, as a number.
+const RatesPort = "rates"
+
+// ratesOnlyPorts wraps a PortRegistry for simulation, whose only port reads
+// are the rates its convert derivations quote; every other read, and
+// Execute, is refused.
+type ratesOnlyPorts struct {
+	readOnlyPorts
+}
+
+func (p ratesOnlyPorts) Get(ctx context.Context, port, fact string, input map[string]any) (any, error) {
+	if port != RatesPort {
+		return nil, fmt.Errorf("get %s from port %s: simulation reads only the %s port", fact, port, RatesPort)
+	}
+	return p.PortRegistry.Get(ctx, port, fact, input)
+}
+
+// A money fact is an object of a value and an ISO 4217 currency code, e.g.
+// {"value": 120.5, "currency": "EUR"}.
+func money(v any) (value float64, currency string, ok bool) {
+	m, _ := v.(map[string]any)
+	currency, _ = m["currency"].(string)
+	value, ok = toFloat(m["value"])
+	return value, currency, ok && currency != ""
+}
+
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// convert evaluates a convert derivation, e.g.
+//
+//	derivation: {fn: "convert", args: [{fact: "payment.amount"}, {value: "USD"}]}
+//
+// It converts the money fact in its first argument into the currency of its
+// second with the rate the rates port quotes, and is absent if the fact is
+// not money. A rate that cannot be read makes the derived fact unavailable.
+func convert(ctx context.Context, ports PortRegistry, name string, d Derivation, facts *FactSet) (any, error) {
+	if len(d.Args) != 2 {
+		return nil, fmt.Errorf("convert takes an amount and a currency, got %d arguments", len(d.Args))
+	}
+	amount, _ := facts.GetPath(d.Args[0].Fact)
+	value, from, ok := money(amount)
+	to, _ := d.Args[1].Value.(string)
+	if !ok || to == "" {
+		return nil, nil
+	}
+	if from == to {
+		return map[string]any{"value": value, "currency": to}, nil
+	}
+	if ports == nil {
+		return nil, nil // warm-up, which has no ports to read rates from
+	}
+	quote, err := ports.Get(ctx, RatesPort, from+"/"+to, nil)
+	if err != nil {
+		return nil, &factError{fact: name, reason: fmt.Sprintf("no %s/%s rate: %v", from, to, err), outcome: OutcomeSystemError}
+	}
+	rate, ok := toFloat(quote)
+	if !ok || rate <= 0 {
+		return nil, &factError{fact: name, reason: fmt.Sprintf("%s/%s rate %v is not a positive number", from, to, quote), outcome: OutcomeSystemError}
+	}
+	return map[string]any{"value": value * rate, "currency": to}, nil
+}
+
+// CurrencyDef declares the currencies an operation accepts, e.g.
+//
+//	currencies: {accepted: ["USD", "EUR"], facts: ["payment.amount", "invoice.balance"], match: true}
+//
+// Its facts are base money facts, checked once they are gathered and before
+// any is converted. A request with one in a currency not accepted is denied
+// with CURRENCY_NOT_ACCEPTED, and, with match, one whose facts are in
+// different currencies with CURRENCY_MISMATCH. Absent facts are left to
+// their on_missing policy.
+type CurrencyDef struct {
+	// Accepted lists ISO 4217 codes; empty accepts any currency.
+	Accepted []string `json:"accepted,omitempty"`
+	// Facts are the money facts checked.
+	Facts []string `json:"facts"`
+	// Match requires the facts to share one currency.
+	Match bool `json:"match,omitempty"`
+}
+
+// currencyRefused returns the denial of a request whose money facts the
+// operation does not accept, or nil.
+func currencyRefused(c *Contract, operation string, req *Request, facts *FactSet) *Response {
+	d := c.Operations[operation].Currencies
+	if d == nil {
+		return nil
+	}
+	var first, firstCurrency string
+	for _, name := range d.Facts {
+		v, ok := facts.GetPath(name)
+		if !ok || v == nil {
+			continue
+		}
+		_, currency, ok := money(v)
+		if !ok {
+			return currencyDenied(req, &ErrorEnvelope{
+				Code:       "CURRENCY_NOT_ACCEPTED",
+				Message:    fmt.Sprintf("%s has no currency", name),
+				Suggestion: "Send the amount as {value, currency}",
+				Details:    map[string]any{"fact": name, "accepted": d.Accepted},
+			})
+		}
+		if len(d.Accepted) > 0 && !slices.Contains(d.Accepted, currency) {
+			return currencyDenied(req, &ErrorEnvelope{
+				Code:       "CURRENCY_NOT_ACCEPTED",
+				Message:    fmt.Sprintf("%s is in %s; %s accepts %s", name, currency, operation, strings.Join(d.Accepted, ", ")),
+				Suggestion: "Retry in one of the accepted currencies",
+				Details:    map[string]any{"fact": name, "currency": currency, "accepted": d.Accepted},
+			})
+		}
+		if first == "" {
+			first, firstCurrency = name, currency
+		} else if d.Match && currency != firstCurrency {
+			return currencyDenied(req, &ErrorEnvelope{
+				Code:       "CURRENCY_MISMATCH",
+				Message:    fmt.Sprintf("%s is in %s but %s is in %s", name, currency, first, firstCurrency),
+				Suggestion: fmt.Sprintf("Retry in %s", firstCurrency),
+				Details:    map[string]any{"currencies": map[string]string{first: firstCurrency, name: currency}},
+			})
+		}
+	}
+	return nil
+}
+
+func currencyDenied(req *Request, env *ErrorEnvelope) *Response {
+	env.HttpStatus = http.StatusUnprocessableEntity
+	env.Category = "validation"
+	return &Response{DryRun: req.DryRun, Outcome: OutcomeDenied, Error: env}
+}
+
+// validateCurrencies checks an operation's accepted currencies.
+func validateCurrencies(c *Contract, name string, op OperationDef) []Diagnostic {
+	d := op.Currencies
+	if d == nil {
+		return nil
+	}
+	var diags []Diagnostic
+	report := func(format string, args ...any) {
+		diags = append(diags, Diagnostic{
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("operation %s: currencies ", name) + fmt.Sprintf(format, args...),
+		})
+	}
+	if len(d.Facts) == 0 {
+		report("declares no facts")
+	}
+	for _, f := range d.Facts {
+		if _, ok := c.Facts[f]; !ok {
+			report("fact %q is not a declared base fact", f)
+		}
+	}
+	for _, code := range d.Accepted {
+		if !currencyCode.MatchString(code) {
+			report("%q is not an ISO 4217 currency code", code)
+		}
+	}
+	if d.Match && len(d.Facts) < 2 {
+		report("match needs at least two facts")
+	}
+	return diags
+}
+
+// validateConvert checks a convert derivation.
+func validateConvert(name string, df DerivedFactDef) []Diagnostic {
+	d := df.Derivation
+	if d.Fn != "convert" {
+		return nil
+	}
+	report := func(format string, args ...any) []Diagnostic {
+		return []Diagnostic{{
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("derived fact %s: convert ", name) + fmt.Sprintf(format, args...),
+		}}
+	}
+	if len(d.Args) != 2 || d.Args[0].Fact == "" {
+		return report("takes an amount fact and a currency")
+	}
+	if to, _ := d.Args[1].Value.(string); !currencyCode.MatchString(to) {
+		return report("currency %v is not an ISO 4217 currency code", d.Args[1].Value)
+	}
+	return nil
+}
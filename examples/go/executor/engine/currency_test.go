@@ -0,0 +1,171 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func currencyContract() *Contract {
+	c := makeMinimalContract()
+	c.Facts = map[string]FactDef{
+		"payment.amount":  {Source: "input"},
+		"invoice.balance": {Source: "input"},
+	}
+	c.DerivedFacts = map[string]DerivedFactDef{
+		"payment.usd": {Derivation: Derivation{Fn: "convert", Args: []DerivationArg{{Fact: "payment.amount"}, {Value: "USD"}}}},
+	}
+	c.Rules = []RuleDef{{
+		ID:        "large-payment",
+		AppliesTo: []string{"ProcessPayment"},
+		When:      Condition{Fact: "payment.usd.value", GreaterThan: 1000.0},
+		Verdict:   VerdictDef{Escalate: &EscalateVerdict{Queue: "review", Reason: "large payment"}},
+	}}
+	c.Operations = map[string]OperationDef{
+		"ProcessPayment": {
+			ConstrainedBy: []string{"large-payment"},
+			Currencies:    &CurrencyDef{Accepted: []string{"USD", "EUR", "GBP"}, Facts: []string{"payment.amount", "invoice.balance"}, Match: true},
+		},
+	}
+	return c
+}
+
+// rates quotes EUR/USD at 1.1 and GBP/USD at 1.25.
+func rates() *mockPorts {
+	return &mockPorts{getFunc: func(_ context.Context, port, fact string, _ map[string]any) (any, error) {
+		if port != RatesPort {
+			return nil, errors.New("unexpected port " + port)
+		}
+		switch fact {
+		case "EUR/USD":
+			return 1.1, nil
+		case "GBP/USD":
+			return 1.25, nil
+		}
+		return nil, errors.New("no rate")
+	}}
+}
+
+func amountIn(value float64, currency string) map[string]any {
+	return map[string]any{"value": value, "currency": currency}
+}
+
+func TestEngine_currencies(t *testing.T) {
+	e := NewEngine(rates())
+	e.LoadContract(currencyContract(), "v1")
+	pay := func(amount, balance map[string]any) *Response {
+		t.Helper()
+		resp, err := e.Evaluate(context.Background(), &Request{Operation: "ProcessPayment", Input: map[string]any{"payment.amount": amount, "invoice.balance": balance}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	for _, tc := range []struct {
+		name            string
+		amount, balance map[string]any
+		outcome         Outcome
+		code            string
+	}{
+		{"small EUR", amountIn(900, "EUR"), amountIn(900, "EUR"), OutcomeExecuted, ""},
+		{"converted over the limit", amountIn(950, "EUR"), amountIn(950, "EUR"), OutcomeEscalated, ""},
+		{"USD needs no rate", amountIn(1000, "USD"), amountIn(1000, "USD"), OutcomeExecuted, ""},
+		{"not accepted", amountIn(100, "JPY"), amountIn(100, "JPY"), OutcomeDenied, "CURRENCY_NOT_ACCEPTED"},
+		{"no currency", map[string]any{"value": 100.0}, amountIn(100, "USD"), OutcomeDenied, "CURRENCY_NOT_ACCEPTED"},
+		{"mismatch", amountIn(100, "GBP"), amountIn(100, "EUR"), OutcomeDenied, "CURRENCY_MISMATCH"},
+	} {
+		resp := pay(tc.amount, tc.balance)
+		if resp.Outcome != tc.outcome {
+			t.Errorf("%s: expected %s, got %s %+v", tc.name, tc.outcome, resp.Outcome, resp.Error)
+			continue
+		}
+		if tc.code != "" && (resp.Error == nil || resp.Error.Code != tc.code || resp.Error.HttpStatus != 422) {
+			t.Errorf("%s: expected a %s envelope, got %+v", tc.name, tc.code, resp.Error)
+		}
+	}
+}
+
+func TestEngine_convert_missingRateIsUnavailable(t *testing.T) {
+	c := currencyContract()
+	c.Operations["ProcessPayment"] = OperationDef{ConstrainedBy: []string{"large-payment"}}
+	e := NewEngine(rates())
+	e.LoadContract(c, "v1")
+	resp, err := e.Evaluate(context.Background(), &Request{Operation: "ProcessPayment", Input: map[string]any{"payment.amount": amountIn(100, "CHF")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Outcome != OutcomeSystemError || resp.Error == nil || resp.Error.Code != "FACT_UNAVAILABLE" || !strings.Contains(resp.Error.Message, "CHF/USD") {
+		t.Errorf("expected FACT_UNAVAILABLE for the missing rate, got %s %+v", resp.Outcome, resp.Error)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	d := Derivation{Fn: "convert", Args: []DerivationArg{{Fact: "amount"}, {Value: "USD"}}}
+	for _, tc := range []struct {
+		amount any
+		want   any
+	}{
+		{amountIn(10, "GBP"), amountIn(12.5, "USD")},
+		{amountIn(10, "USD"), amountIn(10, "USD")},
+		{10.0, nil},
+		{nil, nil},
+	} {
+		fs := NewFactSet()
+		if tc.amount != nil {
+			fs.Set("amount", tc.amount)
+		}
+		got, err := convert(context.Background(), rates(), "converted", d, fs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gm, ok := got.(map[string]any); tc.want == nil && got != nil || tc.want != nil && (!ok || gm["value"] != tc.want.(map[string]any)["value"] || gm["currency"] != "USD") {
+			t.Errorf("convert(%v): expected %v, got %v", tc.amount, tc.want, got)
+		}
+	}
+}
+
+func TestRatesOnlyPorts(t *testing.T) {
+	var calls []string
+	ports := ratesOnlyPorts{readOnlyPorts{&mockPorts{
+		getFunc: func(_ context.Context, port, fact string, _ map[string]any) (any, error) {
+			calls = append(calls, port+":"+fact)
+			return 1.1, nil
+		},
+		executeFunc: func(_ context.Context, port, _ string, _ map[string]any) (map[string]any, error) {
+			calls = append(calls, port+":execute")
+			return nil, nil
+		},
+	}}}
+	ctx := context.Background()
+	if rate, err := ports.Get(ctx, RatesPort, "EUR/USD", nil); err != nil || rate != 1.1 {
+		t.Errorf("expected the rate read through, got %v %v", rate, err)
+	}
+	if _, err := ports.Get(ctx, "invoiceRepo", "invoice.status", nil); err == nil {
+		t.Error("expected a read from another port refused")
+	}
+	if _, err := ports.Execute(ctx, RatesPort, "refresh", nil); !errors.Is(err, ErrDryRunSideEffect) {
+		t.Errorf("expected Execute refused, got %v", err)
+	}
+	if len(calls) != 1 || calls[0] != "rates:EUR/USD" {
+		t.Errorf("expected only the rate read to reach the ports, got %v", calls)
+	}
+}
+
+func TestValidate_reportsMalformedCurrencies(t *testing.T) {
+	c := currencyContract()
+	c.Operations["ProcessPayment"].Currencies.Accepted = []string{"usd"}
+	c.Operations["ProcessPayment"].Currencies.Facts = []string{"invoice.total"}
+	c.DerivedFacts["payment.usd"] = DerivedFactDef{Derivation: Derivation{Fn: "convert", Args: []DerivationArg{{Fact: "payment.amount"}, {Value: "dollars"}}}}
+	var got []string
+	for _, d := range Validate(c, time.Now()) {
+		if strings.Contains(d.Message, "currenc") {
+			got = append(got, d.Message)
+		}
+	}
+	if len(got) != 4 {
+		t.Errorf("expected 4 diagnostics, got %q", got)
+	}
+}
@@ -0,0 +1,197 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// WithDecisionCache keeps the verdicts of up to capacity evaluations, so a
+// request whose facts match an earlier one's skips rule evaluation. It
+// suits what-if workloads such as agents dry-running the same request
+// repeatedly. Facts are still gathered, since they are what the cache is
+// keyed by. 0, the default, disables the cache.
+func WithDecisionCache(capacity int) Option {
+	return func(e *Engine) {
+		if capacity <= 0 {
+			e.decisions = nil
+			return
+		}
+		e.decisions = newDecisionCache(capacity)
+	}
+}
+
+// DecisionCacheStats returns decision cache lookups by operation since
+// startup.
+func (e *Engine) DecisionCacheStats() map[string]CacheStats {
+	if e.decisions == nil {
+		return map[string]CacheStats{}
+	}
+	return e.decisions.stats()
+}
+
+// decisionCache holds the verdicts rules produced, keyed by operation,
+// contract ETag, the rules active at the time and a hash of the facts.
+// Verdicts depend on nothing else, so entries never expire; they are
+// dropped when their contract is no longer loaded, or to make room.
+type decisionCache struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]decisionEntry
+	counts   map[string]*CacheStats
+}
+
+type decisionEntry struct {
+	etag     string
+	verdicts []Verdict
+}
+
+func newDecisionCache(capacity int) *decisionCache {
+	return &decisionCache{
+		capacity: capacity,
+		entries:  map[string]decisionEntry{},
+		counts:   map[string]*CacheStats{},
+	}
+}
+
+// decide evaluates the operation's rules, or returns the verdicts cached
+// for the same facts. Partial fact sets are neither looked up nor cached.
+// The shadow verdicts of rules outside their rollout are returned apart.
+func (e *Engine) decide(c *Contract, etag, operation string, facts *FactSet, at time.Time, partial bool) (verdicts, shadow []Verdict) {
+	if e.decisions == nil || partial {
+		return splitShadow(e.evaluateRules(c, operation, facts, at))
+	}
+	key, ok := decisionKey(c, etag, operation, facts, at)
+	if !ok {
+		return splitShadow(e.evaluateRules(c, operation, facts, at))
+	}
+	if verdicts, ok := e.decisions.get(key, operation); ok {
+		return splitShadow(verdicts)
+	}
+	verdicts = e.evaluateRules(c, operation, facts, at)
+	e.decisions.put(key, etag, verdicts)
+	return splitShadow(verdicts)
+}
+
+// decisionKey hashes what an operation's verdicts depend on, and reports
+// false if the facts cannot be hashed or a rule compares them with the
+// time of evaluation.
+func decisionKey(c *Contract, etag, operation string, facts *FactSet, at time.Time) (string, bool) {
+	ruleSet := map[string]bool{}
+	for _, id := range c.Operations[operation].ConstrainedBy {
+		ruleSet[id] = true
+	}
+	var active []string
+	for _, rule := range c.Rules {
+		if ruleSet[rule.ID] && rule.ActiveAt(at) {
+			if readsClock(rule.When) {
+				return "", false
+			}
+			active = append(active, rule.ID)
+		}
+	}
+	data, err := json.Marshal(struct {
+		Rules []string       `json:"rules"`
+		Facts map[string]any `json:"facts"`
+	}{active, facts.Snapshot()})
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.Sum256([]byte(etag + "\x00" + operation + "\x00" + string(data)))
+	return hex.EncodeToString(sum[:]), true
+}
+
+// get returns a copy of the verdicts cached under key, counting the lookup
+// against operation.
+func (c *decisionCache) get(key, operation string) ([]Verdict, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.counts[operation]
+	if s == nil {
+		s = &CacheStats{}
+		c.counts[operation] = s
+	}
+	ent, ok := c.entries[key]
+	if !ok {
+		s.Misses++
+		return nil, false
+	}
+	s.Hits++
+	return copyVerdicts(ent.verdicts), true
+}
+
+// put caches verdicts. When the cache is full an arbitrary entry makes
+// room.
+func (c *decisionCache) put(key, etag string, verdicts []Verdict) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.capacity {
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	c.entries[key] = decisionEntry{etag: etag, verdicts: copyVerdicts(verdicts)}
+}
+
+// retain drops the entries of contracts not in etags.
+func (c *decisionCache) retain(etags map[string]bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, ent := range c.entries {
+		if !etags[ent.etag] {
+			delete(c.entries, k)
+		}
+	}
+}
+
+func (c *decisionCache) stats() map[string]CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make(map[string]CacheStats, len(c.counts))
+	for op, s := range c.counts {
+		st := *s
+		if total := st.Hits + st.Misses; total > 0 {
+			st.HitRate = float64(st.Hits) / float64(total)
+		}
+		out[op] = st
+	}
+	return out
+}
+
+// copyVerdicts deep-copies verdicts, with their error envelopes and
+// metadata, which responses and hooks hand to callers.
+func copyVerdicts(verdicts []Verdict) []Verdict {
+	if verdicts == nil {
+		return nil
+	}
+	out := append([]Verdict(nil), verdicts...)
+	for i := range out {
+		if out[i].Error != nil {
+			env := *out[i].Error
+			if env.Details != nil {
+				env.Details = cloneValue(env.Details).(map[string]any)
+			}
+			out[i].Error = &env
+		}
+		if out[i].Metadata != nil {
+			out[i].Metadata = cloneValue(out[i].Metadata).(map[string]any)
+		}
+	}
+	return out
+}
+
+// invalidateDecisions drops cached verdicts of contracts no longer loaded.
+// Callers hold e.mu.
+func (e *Engine) invalidateDecisions() {
+	if e.decisions == nil {
+		return
+	}
+	etags := map[string]bool{e.contractETag: true}
+	for _, lc := range e.versions {
+		etags[lc.etag] = true
+	}
+	e.decisions.retain(etags)
+}
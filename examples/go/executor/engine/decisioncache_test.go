@@ -0,0 +1,95 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// blockedContract denies blocked customers.
+func blockedContract() *Contract {
+	return makeSimpleContract("r1",
+		VerdictDef{Deny: &DenyVerdict{Code: "BLOCKED", Error: ErrorEnvelope{Code: "BLOCKED", HttpStatus: 403}}},
+		Condition{Fact: "customer.status", Equals: "blocked"},
+	)
+}
+
+func TestEngine_Evaluate_reusesDecisionsForTheSameFacts(t *testing.T) {
+	e := NewEngine(&mockPorts{}, WithDecisionCache(100))
+	e.LoadContract(blockedContract(), "v1")
+	dryRun := func(status string) *Response {
+		t.Helper()
+		resp, err := e.Evaluate(context.Background(), &Request{Operation: "testOp", Input: map[string]any{"customer.status": status}, DryRun: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	for range 3 {
+		if resp := dryRun("blocked"); resp.Outcome != "would_deny" || resp.Verdicts[0].Code != "BLOCKED" {
+			t.Fatalf("got %+v", resp)
+		}
+	}
+	if resp := dryRun("active"); resp.Outcome != "would_execute" {
+		t.Fatalf("expected other facts evaluated afresh, got %s", resp.Outcome)
+	}
+	if got, want := e.DecisionCacheStats()["testOp"], (CacheStats{Hits: 2, Misses: 2, HitRate: 0.5}); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	resp := dryRun("blocked")
+	resp.Verdicts[0].Error.Code = "CHANGED"
+	if resp := dryRun("blocked"); resp.Verdicts[0].Error.Code != "BLOCKED" {
+		t.Error("expected cached verdicts unaffected by changes to a response")
+	}
+}
+
+func TestEngine_Evaluate_decisionCacheFollowsRuleWindows(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	e := NewEngine(&mockPorts{}, WithDecisionCache(100), WithClock(func() time.Time { return now }))
+	c := blockedContract()
+	from := now.Add(time.Hour)
+	c.Rules[0].EffectiveFrom = &from
+	e.LoadContract(c, "v1")
+	req := &Request{Operation: "testOp", Input: map[string]any{"customer.status": "blocked"}, DryRun: true}
+
+	if resp, _ := e.Evaluate(context.Background(), req); resp.Outcome != "would_execute" {
+		t.Fatalf("expected the rule inactive, got %s", resp.Outcome)
+	}
+	now = now.Add(2 * time.Hour)
+	if resp, _ := e.Evaluate(context.Background(), req); resp.Outcome != "would_deny" {
+		t.Errorf("expected the decision before the rule's window not reused, got %s", resp.Outcome)
+	}
+}
+
+func TestEngine_LoadContract_invalidatesDecisions(t *testing.T) {
+	e := NewEngine(&mockPorts{}, WithDecisionCache(100))
+	e.LoadContract(blockedContract(), "v1")
+	req := &Request{Operation: "testOp", Input: map[string]any{"customer.status": "blocked"}, DryRun: true}
+	e.Evaluate(context.Background(), req)
+
+	relaxed := blockedContract()
+	relaxed.Rules[0].When = Condition{Fact: "customer.status", Equals: "closed"}
+	e.LoadContract(relaxed, "v2")
+	if n := len(e.decisions.entries); n != 0 {
+		t.Errorf("expected the old contract's decisions dropped, got %d", n)
+	}
+	if resp, _ := e.Evaluate(context.Background(), req); resp.Outcome != "would_execute" {
+		t.Errorf("expected the new contract's rules applied, got %s", resp.Outcome)
+	}
+}
+
+func TestEngine_Simulate_reusesDecisions(t *testing.T) {
+	e := NewEngine(&mockPorts{}, WithDecisionCache(100))
+	e.LoadContract(blockedContract(), "v1")
+	for range 2 {
+		resp, err := e.Simulate(&SimulateRequest{Operation: "testOp", Facts: map[string]any{"customer.status": "blocked"}})
+		if err != nil || resp.Outcome != "would_deny" {
+			t.Fatalf("got %+v, %v", resp, err)
+		}
+	}
+	if got := e.DecisionCacheStats()["testOp"]; got.Hits != 1 {
+		t.Errorf("expected the second simulation served from the cache, got %+v", got)
+	}
+}
@@ -0,0 +1,180 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// factOriginDerived marks a fact cache entry stored by a derivation.
+const factOriginDerived = "derived"
+
+// cachedDerivedFacts returns the cacheable derived facts the operation's
+// rules reach that the fact cache holds for input under the contract etag.
+func (e *Engine) cachedDerivedFacts(c *Contract, etag, operation string, input map[string]any, now time.Time) map[string]*cachedFact {
+	var hits map[string]*cachedFact
+	_, derived := neededFacts(c, operation, nil)
+	for name := range derived {
+		if c.DerivedFacts[name].Cache == nil {
+			continue
+		}
+		if ent, ok := e.factCache.get(name, input, now); ok && ent.etag == etag {
+			if hits == nil {
+				hits = map[string]*cachedFact{}
+			}
+			hits[name] = ent
+		}
+	}
+	return hits
+}
+
+// cacheDerivedFacts stores the cacheable derived facts just computed for a
+// request, scoped to the request's values of the fact's key. An entry
+// expires after the fact's TTL, or sooner when a cached fact it was derived
+// from expires or a fact it was derived from exceeds its max_staleness, and
+// is not used once another contract version is loaded.
+func (e *Engine) cacheDerivedFacts(c *Contract, etag, operation string, facts *FactSet, input map[string]any, now time.Time) {
+	traces := facts.Traces()
+	_, derived := neededFacts(c, operation, nil)
+	for name := range derived {
+		def := c.DerivedFacts[name]
+		if def.Cache == nil || def.Cache.Duration() == 0 || traces[name].Source == factSourceCache {
+			continue
+		}
+		val, ok := facts.Get(name)
+		if !ok {
+			continue
+		}
+		scope := make(map[string]any, len(def.Cache.Key))
+		for _, k := range def.Cache.Key {
+			v, ok := input[k]
+			if !ok {
+				scope = nil
+				break
+			}
+			scope[k] = v
+		}
+		if scope == nil {
+			continue
+		}
+
+		expires := now.Add(def.Cache.Duration()).UTC()
+		for up := range upstreamFacts(c, name) {
+			t, ok := traces[up]
+			if !ok {
+				continue
+			}
+			if t.ExpiresAt != nil && t.ExpiresAt.Before(expires) {
+				expires = *t.ExpiresAt
+			}
+			if max := c.Facts[up].maxStaleness(); max > 0 && t.asOf().Add(max).Before(expires) {
+				expires = t.asOf().Add(max)
+			}
+		}
+		e.factCache.put(name, &cachedFact{
+			scope: scope,
+			value: val,
+			etag:  etag,
+			trace: FactTrace{
+				Source:    factSourceCache,
+				FetchedAt: now.UTC(),
+				ExpiresAt: &expires,
+				Origin:    factOriginDerived,
+			},
+		}, now)
+	}
+}
+
+// upstreamFacts returns every fact, base or derived, that the derived fact
+// name is computed from, directly or transitively.
+func upstreamFacts(c *Contract, name string) map[string]bool {
+	seen := map[string]bool{}
+	var visit func(string)
+	visit = func(n string) {
+		df, ok := c.DerivedFacts[n]
+		if !ok {
+			return
+		}
+		for _, arg := range df.Derivation.Args {
+			if arg.Fact == "" {
+				continue
+			}
+			f := baseFactOf(c, arg.Fact)
+			if !seen[f] {
+				seen[f] = true
+				visit(f)
+			}
+		}
+	}
+	visit(name)
+	return seen
+}
+
+// baseFactOf resolves a dotted path to the declared fact or derived fact it
+// reads, e.g. "payment.amount.value" to "payment.amount".
+func baseFactOf(c *Contract, path string) string {
+	for p := path; ; {
+		if _, ok := c.Facts[p]; ok {
+			return p
+		}
+		if _, ok := c.DerivedFacts[p]; ok {
+			return p
+		}
+		i := strings.LastIndex(p, ".")
+		if i < 0 {
+			return path
+		}
+		p = p[:i]
+	}
+}
+
+// validateDerivedCache checks a derived fact's cache declaration. Every
+// input fact the derivation reads must be part of the key, or one cached
+// value would be reused for different inputs.
+func validateDerivedCache(c *Contract, name string, df DerivedFactDef) []Diagnostic {
+	if df.Cache == nil {
+		return nil
+	}
+	var diags []Diagnostic
+	if ttl, err := time.ParseDuration(df.Cache.TTL); err != nil || ttl <= 0 {
+		diags = append(diags, Diagnostic{
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("derived fact %s: cache ttl %q is not a positive duration", name, df.Cache.TTL),
+		})
+	}
+	key := map[string]bool{}
+	for _, fact := range df.Cache.Key {
+		key[fact] = true
+		if def, ok := c.Facts[fact]; !ok || def.Source != "input" {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("derived fact %s: cache key %q is not an input fact", name, fact),
+			})
+		}
+	}
+	var inputs []string
+	for up := range upstreamFacts(c, name) {
+		if def, ok := c.Facts[up]; ok && def.Source == "input" && !key[up] {
+			inputs = append(inputs, up)
+		}
+	}
+	sort.Strings(inputs)
+	for _, up := range inputs {
+		diags = append(diags, Diagnostic{
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("derived fact %s: derived from input fact %q, which is not in its cache key", name, up),
+		})
+	}
+	return diags
+}
+
+// sortedDerivedFacts returns the contract's derived fact names in order.
+func sortedDerivedFacts(c *Contract) []string {
+	names := make([]string, 0, len(c.DerivedFacts))
+	for name := range c.DerivedFacts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
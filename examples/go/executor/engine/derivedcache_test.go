@@ -0,0 +1,129 @@
+package engine
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// derivedCacheContract flags testOp when a customer's risk score, derived
+// from a slow port fact, is high. The derived fact is cached per customer.
+func derivedCacheContract() *Contract {
+	c := makeMinimalContract()
+	c.Facts["customer.id"] = FactDef{Source: "input", Required: true}
+	c.Facts["score"] = FactDef{Source: "port:risk"}
+	c.DerivedFacts["risky"] = DerivedFactDef{
+		Derivation: Derivation{Fn: "greater_than", Args: []DerivationArg{{Fact: "score"}, {Value: 50.0}}},
+		Cache:      &CacheDef{TTL: "1m", Key: []string{"customer.id"}},
+	}
+	c.Rules = []RuleDef{{ID: "risky", When: Condition{Fact: "risky", Equals: true}, Verdict: VerdictDef{Flag: &FlagVerdict{Code: "RISKY"}}}}
+	c.Operations["testOp"] = OperationDef{ConstrainedBy: []string{"risky"}}
+	return c
+}
+
+func TestEngine_cachedDerivedFactSkipsItsPorts(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	var gets atomic.Int32
+	ports := &mockPorts{getFunc: func(context.Context, string, string, map[string]any) (any, error) {
+		gets.Add(1)
+		return 80.0, nil
+	}}
+	e := NewEngine(ports, WithClock(func() time.Time { return now }))
+	e.LoadContract(derivedCacheContract(), "v1")
+	eval := func(customer string) *Response {
+		t.Helper()
+		resp, err := e.Evaluate(context.Background(), &Request{Operation: "testOp", Input: map[string]any{"customer.id": customer}, Explain: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	eval("cust_1")
+	resp := eval("cust_1")
+	if gets.Load() != 1 || len(resp.Verdicts) != 1 {
+		t.Fatalf("expected the cached derived fact to be used, got %d gets, %+v", gets.Load(), resp)
+	}
+	if trace := resp.Explain.Facts["risky"]; trace.Source != "cache" || trace.Origin != "derived" {
+		t.Errorf("expected cache attribution in explain, got %+v", trace)
+	}
+
+	// Another customer, the same customer after expiry, and the same
+	// customer under a new contract version all compute it afresh.
+	eval("cust_2")
+	now = now.Add(time.Minute)
+	eval("cust_1")
+	e.LoadContract(derivedCacheContract(), "v2")
+	eval("cust_1")
+	if gets.Load() != 4 {
+		t.Errorf("expected 4 port fetches, got %d", gets.Load())
+	}
+}
+
+func TestEngine_cachedDerivedFactExpiresWithItsInputs(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	var gets atomic.Int32
+	ports := &mockPorts{getFunc: func(context.Context, string, string, map[string]any) (any, error) {
+		gets.Add(1)
+		return 80.0, nil
+	}}
+	e := NewEngine(ports, WithClock(func() time.Time { return now }))
+	e.LoadContract(derivedCacheContract(), "v1")
+	input := map[string]any{"customer.id": "cust_1"}
+
+	if _, err := e.Prefetch(context.Background(), &Intent{Operation: "testOp", Input: input, TTLSeconds: 10}); err != nil {
+		t.Fatal(err)
+	}
+	e.Evaluate(context.Background(), &Request{Operation: "testOp", Input: input})
+	ent, ok := e.factCache.get("risky", input, now)
+	if !ok || !ent.trace.ExpiresAt.Equal(now.Add(10*time.Second)) {
+		t.Fatalf("expected the derived entry to expire with the prefetched score, got %+v", ent)
+	}
+
+	now = now.Add(10 * time.Second)
+	e.Evaluate(context.Background(), &Request{Operation: "testOp", Input: input})
+	if gets.Load() != 2 {
+		t.Errorf("expected the score refetched once its entry expired, got %d gets", gets.Load())
+	}
+}
+
+func TestValidate_derivedCacheDeclarations(t *testing.T) {
+	c := derivedCacheContract()
+	c.Facts["invoice.id"] = FactDef{Source: "input"}
+	c.DerivedFacts["bad"] = DerivedFactDef{
+		Derivation: Derivation{Fn: "equals", Args: []DerivationArg{{Fact: "invoice.id"}, {Value: "inv_1"}}},
+		Cache:      &CacheDef{TTL: "soon", Key: []string{"score"}},
+	}
+	var got []string
+	for _, d := range Validate(c, time.Now()) {
+		got = append(got, d.String())
+	}
+	want := []string{
+		`error: derived fact bad: cache ttl "soon" is not a positive duration`,
+		`error: derived fact bad: cache key "score" is not an input fact`,
+		`error: derived fact bad: derived from input fact "invoice.id", which is not in its cache key`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected diagnostics:\n%s", strings.Join(got, "\n"))
+	}
+}
+
+func TestCompileFiles_derivedFactCache(t *testing.T) {
+	c, err := compileContract([]sourceFile{{path: "d.cue", data: []byte(`
+facts: "customer.id": {source: "input"}
+derived_facts: known: {
+	derivation: {fn: "equals", args: [{fact: "customer.id"}, {value: "cust_1"}]}
+	cache: {ttl: "5m", key: ["customer.id"]}
+}
+operations: op: {constrained_by: [], transitions: []}
+`)}})
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	got := c.DerivedFacts["known"].Cache
+	if got == nil || got.TTL != "5m" || len(got.Key) != 1 || got.Key[0] != "customer.id" {
+		t.Errorf("expected the cache declaration loaded, got %+v", got)
+	}
+}
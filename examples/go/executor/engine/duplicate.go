@@ -0,0 +1,121 @@
+package engine
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DuplicateDef flags, or denies, a request that looks like a resubmission
+// of a recent execution: the same operation with the same values of the
+// key input facts within the window, e.g.
+//
+//	duplicates: {keys: ["invoice.id", "payment.amount"], window: "10m", action: "deny"}
+//
+// It catches retries by clients that send no idempotency key, so it is a
+// heuristic: it needs the execution history, and a request whose history
+// cannot be read is let through.
+type DuplicateDef struct {
+	Keys   []string `json:"keys"`
+	Window string   `json:"window"`
+	// Action is "flag" (the default) or "deny".
+	Action string `json:"action,omitempty"`
+	// Error replaces the POSSIBLE_DUPLICATE envelope of a denial.
+	Error *ErrorEnvelope `json:"error,omitempty"`
+}
+
+// duplicateFingerprint identifies the values of the operation's duplicate
+// keys in input, or is empty if the operation declares none or input lacks
+// one of them.
+func duplicateFingerprint(c *Contract, operation string, input map[string]any) string {
+	d := c.Operations[operation].Duplicates
+	if d == nil {
+		return ""
+	}
+	values := make([]any, len(d.Keys))
+	for i, key := range d.Keys {
+		v, ok := input[key]
+		if !ok || v == nil {
+			return ""
+		}
+		values[i] = v
+	}
+	data, _ := json.Marshal(values) // map keys marshal sorted
+	sum := sha256.Sum256(append([]byte(operation+"\x00"), data...))
+	return hex.EncodeToString(sum[:])
+}
+
+// duplicateVerdicts returns the verdict on a request repeating an
+// execution within its operation's duplicate window, if there is one.
+func (e *Engine) duplicateVerdicts(ctx context.Context, c *Contract, operation string, input map[string]any, now time.Time) []Verdict {
+	d := c.Operations[operation].Duplicates
+	fingerprint := duplicateFingerprint(c, operation, input)
+	if e.history == nil || fingerprint == "" {
+		return nil
+	}
+	window, err := time.ParseDuration(d.Window)
+	if err != nil {
+		return nil
+	}
+	prior, err := e.history.Executions(ctx, ExecutionQuery{Operation: operation, Fingerprint: fingerprint, Since: now.Add(-window), Limit: 1})
+	if err != nil || len(prior) == 0 {
+		return nil
+	}
+	reason := fmt.Sprintf("possible duplicate of %s %s, executed %s ago with the same %s",
+		operation, prior[0].DecisionID, now.Sub(prior[0].ExecutedAt).Round(time.Second), strings.Join(d.Keys, ", "))
+	if d.Action != "deny" {
+		return []Verdict{{Type: "flag", Code: "POSSIBLE_DUPLICATE", Reason: reason}}
+	}
+	env := &ErrorEnvelope{
+		Code:       "POSSIBLE_DUPLICATE",
+		Message:    reason,
+		HttpStatus: http.StatusConflict,
+		Category:   "business_rule_violation",
+		Suggestion: "Retry with the idempotency key of the original request, or wait out the duplicate window",
+	}
+	if d.Error != nil {
+		declared := *d.Error
+		env = &declared
+	}
+	env.Details = map[string]any{
+		"duplicate_of": prior[0].DecisionID,
+		"keys":         d.Keys,
+		"window":       d.Window,
+	}
+	return []Verdict{{Type: "deny", Code: env.Code, Reason: reason, Error: env}}
+}
+
+// validateDuplicates checks an operation's duplicate heuristic.
+func validateDuplicates(c *Contract, name string, op OperationDef) []Diagnostic {
+	d := op.Duplicates
+	if d == nil {
+		return nil
+	}
+	var diags []Diagnostic
+	report := func(format string, args ...any) {
+		diags = append(diags, Diagnostic{
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("operation %s: duplicates ", name) + fmt.Sprintf(format, args...),
+		})
+	}
+	if len(d.Keys) == 0 {
+		report("declares no keys")
+	}
+	for _, key := range d.Keys {
+		if def, ok := c.Facts[key]; !ok || def.Source != "input" {
+			report("key %q is not an input fact", key)
+		}
+	}
+	if w, err := time.ParseDuration(d.Window); err != nil || w <= 0 {
+		report("window %q is not a positive duration", d.Window)
+	}
+	if d.Action != "" && d.Action != "flag" && d.Action != "deny" {
+		report("action %q is neither flag nor deny", d.Action)
+	}
+	return diags
+}
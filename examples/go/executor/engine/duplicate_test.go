@@ -0,0 +1,76 @@
+package engine
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func duplicatesContract(action string) *Contract {
+	c := makeMinimalContract()
+	c.Facts = map[string]FactDef{
+		"invoice.id":     {Source: "input"},
+		"payment.amount": {Source: "input"},
+	}
+	c.Operations = map[string]OperationDef{
+		"ProcessPayment": {Duplicates: &DuplicateDef{Keys: []string{"invoice.id", "payment.amount"}, Window: "10m", Action: action}},
+	}
+	return c
+}
+
+func TestEngine_duplicates(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	e := NewEngine(&mockPorts{}, WithHistory(NewMemoryExecutionStore(0)), WithClock(func() time.Time { return now }))
+	e.LoadContract(duplicatesContract("deny"), "v1")
+	ctx := context.Background()
+	pay := func(invoice string, amount float64, dryRun bool) *Response {
+		t.Helper()
+		resp, err := e.Evaluate(ctx, &Request{Operation: "ProcessPayment", DryRun: dryRun, Input: map[string]any{"invoice.id": invoice, "payment.amount": amount}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	first := pay("inv_1", 100, false)
+	if first.Outcome != OutcomeExecuted {
+		t.Fatalf("expected the first payment executed, got %s", first.Outcome)
+	}
+	now = now.Add(5 * time.Minute)
+	resp := pay("inv_1", 100, false)
+	if resp.Outcome != OutcomeDenied || resp.Error.Code != "POSSIBLE_DUPLICATE" || resp.Error.Details["duplicate_of"] != first.InvocationID {
+		t.Errorf("expected a POSSIBLE_DUPLICATE denial, got %s %+v", resp.Outcome, resp.Error)
+	}
+	if resp := pay("inv_1", 100, true); resp.Outcome != OutcomeWouldDeny {
+		t.Errorf("expected a dry run to see the duplicate, got %s", resp.Outcome)
+	}
+	if resp := pay("inv_1", 90, false); resp.Outcome != OutcomeExecuted {
+		t.Errorf("expected a different amount executed, got %s", resp.Outcome)
+	}
+	now = now.Add(6 * time.Minute)
+	if resp := pay("inv_1", 100, false); resp.Outcome != OutcomeExecuted {
+		t.Errorf("expected a payment after the window executed, got %s %+v", resp.Outcome, resp.Error)
+	}
+
+	e.LoadContract(duplicatesContract(""), "v2")
+	resp = pay("inv_1", 100, false)
+	if resp.Outcome != OutcomeExecuted || len(resp.Verdicts) != 1 || resp.Verdicts[0].Code != "POSSIBLE_DUPLICATE" {
+		t.Errorf("expected executed with a POSSIBLE_DUPLICATE flag, got %s %+v", resp.Outcome, resp.Verdicts)
+	}
+}
+
+func TestValidate_reportsMalformedDuplicates(t *testing.T) {
+	c := duplicatesContract("block")
+	c.Operations["ProcessPayment"].Duplicates.Keys = []string{"customer.id"}
+	c.Operations["ProcessPayment"].Duplicates.Window = "soon"
+	var got []string
+	for _, d := range Validate(c, time.Now()) {
+		if strings.Contains(d.Message, "duplicates") {
+			got = append(got, d.Message)
+		}
+	}
+	if len(got) != 3 {
+		t.Errorf("expected 3 diagnostics, got %q", got)
+	}
+}
@@ -2,10 +2,13 @@ package engine
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/rand/v2"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 )
 
 // Engine interprets a loaded Contract and evaluates operations against it.
@@ -14,144 +17,497 @@ type Engine struct {
 	contract     *Contract
 	contractETag string
 	ports        PortRegistry
+	auditSinks   []AuditSink
+
+	// versions are the semantically versioned contracts available for
+	// negotiation, in load order; at most retain of them are kept.
+	versions []loadedContract
+	retain   int
+
+	// now is the clock used for rule validity windows, audit timestamps and
+	// scheduled activation.
+	now func() time.Time
+
+	pending *pendingContract
+
+	idempotency IdempotencyStore
+	escalations EscalationStore
+	states      StateStore
+	history     ExecutionStore
+
+	cache     *responseCache
+	factCache *factCache
+	decisions *decisionCache
+
+	// concurrency holds configured per-operation limits; limiters enforce
+	// them, or the contract's.
+	concurrency map[string]ConcurrencyDef
+	limitersMu  sync.Mutex
+	limiters    map[string]*limiter
+
+	scheduler Scheduler
+	hooks     []Hook
+
+	sessionsMu sync.Mutex
+	sessions   map[string]*session
+
+	switchesMu sync.RWMutex
+	switches   map[string]KillSwitch
 }
 
+// ErrUnknownOperation is returned (wrapped) when a request names an operation
+// the selected contract does not declare.
+var ErrUnknownOperation = errors.New("unknown operation")
+
 // PortRegistry provides access to port adapters by name.
 type PortRegistry interface {
 	Get(ctx context.Context, port, fact string, input map[string]any) (any, error)
 	Execute(ctx context.Context, port, operation string, input map[string]any) (map[string]any, error)
 }
 
-func NewEngine(ports PortRegistry) *Engine {
-	return &Engine{ports: ports}
+// Option configures an Engine at construction.
+type Option func(*Engine)
+
+// WithAuditSink adds a sink that receives an AuditRecord for every evaluation.
+func WithAuditSink(s AuditSink) Option {
+	return func(e *Engine) { e.auditSinks = append(e.auditSinks, s) }
+}
+
+// WithClock sets the clock the engine evaluates rule validity windows
+// (effective_from / expires_at) and stamps audit records with. Tests and
+// replays use it to evaluate "as of" a fixed time.
+func WithClock(now func() time.Time) Option {
+	return func(e *Engine) { e.now = now }
 }
 
+func NewEngine(ports PortRegistry, opts ...Option) *Engine {
+	e := &Engine{
+		ports:  ports,
+		retain: defaultRetainedVersions,
+		now:    time.Now,
+		cache:  newResponseCache(defaultCacheCapacity),
+
+		factCache: newFactCache(),
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// LoadContract makes c the active contract. If c declares a semantic version
+// it is also retained for clients that negotiate by version range.
 func (e *Engine) LoadContract(c *Contract, etag string) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 	e.contract = c
 	e.contractETag = etag
+	e.retainVersion(c, etag)
+	e.invalidateDecisions()
 }
 
 func (e *Engine) ETag() string {
+	e.activateDue()
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 	return e.contractETag
 }
 
+// Contract returns the currently loaded contract, or nil if none is loaded.
+// The returned value must not be modified.
+func (e *Engine) Contract() *Contract {
+	e.activateDue()
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.contract
+}
+
 // Evaluate runs the Section 11 evaluation algorithm for the given request.
+// Every evaluation that produces a response — live or dry-run — is assigned an
+// invocation ID and reported to the configured audit sinks.
+//
+// With an IdempotencyStore, a live request carrying an idempotency key that
+// was already used replays the stored response (marked idempotent_replay)
+// without evaluating or auditing again. If storing the response fails the key
+// stays claimed, so retries are refused rather than executed twice.
+//
+// Live requests for a cacheable operation (see CacheDef) are answered from
+// the response cache while a fresh entry exists; such hits are still
+// audited, with no fact snapshot.
+//
+// A request naming a session with SessionID shares the session's port
+// facts and is recorded in its transcript; one naming a session that is not
+// open fails with ErrUnknownSession.
+//
+// A request for an operation under a kill switch (see KillSwitch) is
+// refused, or evaluated as a dry run, before anything else.
+//
+// With a Scheduler, the request runs in the lane for its priority
+// (interactive by default); if that lane is full it is refused as throttled
+// before evaluation, and not audited.
 func (e *Engine) Evaluate(ctx context.Context, req *Request) (*Response, error) {
-	e.mu.RLock()
-	contract := e.contract
-	etag := e.contractETag
-	e.mu.RUnlock()
+	return e.schedule(ctx, req.Priority, PriorityInteractive, func() (*Response, error) {
+		return e.evaluateRequest(ctx, req)
+	})
+}
+
+func (e *Engine) evaluateRequest(ctx context.Context, req *Request) (*Response, error) {
+	var sess *session
+	if req.SessionID != "" {
+		var err error
+		if sess, err = e.session(req.SessionID, true); err != nil {
+			return nil, err
+		}
+	}
+	// A kill switch in dry_run mode turns live requests into dry runs; one
+	// in deny mode refuses them without claiming their idempotency keys,
+	// so they can be retried once the operation is back.
+	sw, switched := e.killSwitch(req.Operation)
+	forcedDryRun := switched && sw.Mode == KillSwitchDryRun && !req.DryRun
+	if forcedDryRun {
+		r := *req
+		r.DryRun = true
+		req = &r
+	}
+	idempotent := e.idempotency != nil && req.IdempotencyKey != "" && !req.DryRun && !switched
+	if idempotent {
+		resp, err := e.claimIdempotencyKey(ctx, req)
+		if err != nil || resp != nil {
+			return resp, err
+		}
+	}
+
+	start := time.Now()
+	rec := &AuditRecord{
+		InvocationID: "inv_" + randID(16),
+		Timestamp:    e.now().UTC(),
+		Operation:    req.Operation,
+		Input:        req.Input,
+		DryRun:       req.DryRun,
+		Purpose:      req.Purpose,
+		SessionID:    req.SessionID,
+	}
+
+	var resp *Response
+	var err error
+	if switched && sw.Mode == KillSwitchDeny {
+		resp = switchedOff(sw, req)
+	} else {
+		resp, err = e.evaluate(ctx, req, rec, sess)
+	}
+	if err != nil {
+		if idempotent {
+			e.idempotency.Release(ctx, req.IdempotencyKey)
+		}
+		return nil, err
+	}
+	resp.InvocationID = rec.InvocationID
+	resp.ContractSemver = rec.ContractSemver
+	resp.ForcedDryRun = forcedDryRun
+	if idempotent {
+		e.settleIdempotencyKey(ctx, req, resp)
+	}
+	if sess != nil {
+		sess.record(rec, resp)
+	}
+	e.audit(ctx, rec, resp, time.Since(start))
+	return resp, nil
+}
 
+func (e *Engine) evaluate(ctx context.Context, req *Request, rec *AuditRecord, sess *session) (*Response, error) {
+	e.activateDue()
+	contract, etag, negErr := e.selectContract(req.VersionRange)
+	if negErr != nil {
+		return negErr, nil
+	}
 	if contract == nil {
 		return nil, fmt.Errorf("no contract loaded")
 	}
+	rec.ContractVersion = etag
+	rec.ContractSemver = contract.Version
 
 	// Validate contract ETag if supplied.
 	if req.ContractETag != "" && req.ContractETag != etag {
-		return &Response{
-			Outcome: "system_error",
-			Error: &ErrorEnvelope{
-				Code:       "CONTRACT_VERSION_MISMATCH",
-				Message:    "Client contract version is stale — re-fetch contracts and retry",
-				HttpStatus: 409,
-				Category:   "system",
-				Retryable:  true,
-			},
-		}, nil
+		return contractVersionMismatch(), nil
 	}
 
 	op, ok := contract.Operations[req.Operation]
 	if !ok {
-		return nil, fmt.Errorf("unknown operation: %s", req.Operation)
+		return nil, fmt.Errorf("%w: %s", ErrUnknownOperation, req.Operation)
+	}
+
+	// Ctx facts an identity provider established replace any the request
+	// claims for itself.
+	if facts, ok := CallerFacts(ctx); ok {
+		r := *req
+		r.Context = facts
+		req = &r
+	}
+
+	// Refuse unauthorized callers on their ctx facts alone, before any port
+	// is consulted or a cached response served.
+	authz, denied := authorize(contract, req.Operation, op, req, rec)
+	if denied != nil {
+		return denied, nil
+	}
+	// Facts are fetched only for purposes the data-use policy allows.
+	if denied := purposeDenied(contract, req.Operation, req); denied != nil {
+		return denied, nil
+	}
+
+	hooks := e.newHookCall(req, rec)
+	if refused := e.runHooks(ctx, hooks, HookBeforeEvaluate); refused != nil {
+		return refused, nil
+	}
+	if hooks != nil && hooks.changed {
+		r := *req
+		r.Input = hooks.input
+		req = &r
+		rec.Input = r.Input
+	}
+
+	// Refuse requests that do not follow the executions their operation
+	// requires.
+	if refused := e.checkPrerequisites(ctx, contract, req.Operation, op, req); refused != nil {
+		return refused, nil
 	}
 
-	// Step 1: Gather base facts.
-	facts, err := e.gatherFacts(ctx, contract, req.Operation, req.Input)
+	// Cacheable reads are answered from the cache while the entry is fresh.
+	key, cacheable := cacheKey(op, etag, req)
+	cacheable = cacheable && e.cache != nil
+	if cacheable {
+		if resp := e.cache.get(key, req.Operation, rec.Timestamp); resp != nil {
+			return resp, nil
+		}
+	}
+
+	// Dry-runs see a read-only view of the ports, so a write can't leak even
+	// if a later step mistakenly reaches Execute.
+	ports := e.ports
+	if req.DryRun {
+		ports = readOnlyPorts{ports}
+	}
+
+	// Step 1: Gather base facts. A partial dry-run stops waiting for port
+	// facts in time to answer before its deadline.
+	ctx, cancel := withRequestTimeout(ctx, req)
+	defer cancel()
+	stop, stopTimer := partialStop(ctx, req, time.Now())
+	defer stopTimer()
+	// Requests in an experiment are evaluated with their arms' params, so
+	// their derived facts are neither read from nor written to the cache.
+	arms, armParams := experimentArms(contract, req.Input, neededBaseFacts(contract, req.Operation))
+	rec.Experiments = arms
+	var cachedDerived map[string]*cachedFact
+	if arms == nil {
+		cachedDerived = e.cachedDerivedFacts(contract, etag, req.Operation, req.Input, rec.Timestamp)
+	}
+	facts, skipped, err := e.gatherFacts(ctx, ports, contract, req.Operation, req.Input, req.Context, cachedDerived, sess, stop)
 	if err != nil {
+		var violation *PortContractViolation
+		if errors.As(err, &violation) {
+			rec.PortContractViolation = violation
+			return portContractViolation(violation), nil
+		}
 		if fe, ok := err.(*factError); ok {
-			return &Response{
-				Outcome: fe.outcome,
-				Error: &ErrorEnvelope{
-					Code:       "FACT_UNAVAILABLE",
-					Message:    fmt.Sprintf("fact %q unavailable: %s", fe.fact, fe.reason),
-					HttpStatus: 503,
-					Category:   "system",
-					Retryable:  true,
-				},
-			}, nil
+			return factUnavailable(fe), nil
 		}
 		return nil, err
 	}
 
-	// Step 2: Derive computed facts.
-	if err := e.deriveFacts(contract, facts); err != nil {
+	for param, val := range armParams {
+		facts.Set(paramFactPrefix+param, val)
+	}
+
+	// Refuse money in currencies the operation does not accept before
+	// converting it.
+	if refused := currencyRefused(contract, req.Operation, req, facts); refused != nil {
+		return refused, nil
+	}
+	if refused := timestampRefused(contract, req, facts); refused != nil {
+		return refused, nil
+	}
+
+	// Step 2: Derive computed facts, and cache those the contract allows.
+	if err := e.deriveFacts(ctx, ports, contract, facts); err != nil {
+		if fe, ok := err.(*factError); ok {
+			return factUnavailable(fe), nil
+		}
 		return nil, fmt.Errorf("derive facts: %w", err)
 	}
+	if skipped == nil && arms == nil {
+		e.cacheDerivedFacts(contract, etag, req.Operation, facts, req.Input, rec.Timestamp)
+	}
+	rec.FactSnapshot = facts.Snapshot()
+	if sess != nil {
+		rec.SessionFacts = sessionFacts(facts.Traces())
+	}
 
-	// Step 3: Validate entity state (simplified — transitions declared on operation).
-	// For this POC we skip state machine validation since we don't track live state.
+	// Step 3: Read the version of each entity instance the operation
+	// transitions, so the transition can be claimed from it.
+	var transitions []entityTransition
+	if !req.DryRun {
+		var refused *Response
+		if transitions, refused = e.readTransitions(ctx, contract, op, req.Input, facts); refused != nil {
+			return refused, nil
+		}
+	}
+
+	// Step 4: Evaluate rules, or reuse the verdicts of an earlier request
+	// with the same facts.
+	verdicts, shadow := e.decide(contract, etag, req.Operation, facts, rec.Timestamp, skipped != nil)
+	rec.ShadowVerdicts = shadow
+	verdicts = append(verdicts, staleFlags(contract, facts, e.now())...)
+	verdicts = append(verdicts, e.duplicateVerdicts(ctx, contract, req.Operation, req.Input, rec.Timestamp)...)
+
+	var ex *Explanation
+	if req.Explain {
+		ex = explain(contract, req.Operation, facts, rec.Timestamp)
+		ex.Authorize = authz
+	}
 
-	// Step 4: Evaluate rules.
-	verdicts := e.evaluateRules(contract, req.Operation, facts)
+	if hooks != nil {
+		hooks.facts, hooks.verdicts = facts, verdicts
+		if refused := e.runHooks(ctx, hooks, HookAfterVerdict); refused != nil {
+			refused.Explain = ex
+			return refused, nil
+		}
+	}
 
 	// Step 5: Apply verdict.
 	final := resolveVerdicts(verdicts)
 
 	if req.DryRun {
 		return &Response{
-			DryRun:       true,
-			Outcome:      dryRunOutcome(final),
-			Verdicts:     verdicts,
-			FactSnapshot: facts.Snapshot(),
+			DryRun:              true,
+			Outcome:             dryRunOutcome(final),
+			Verdicts:            verdicts,
+			FactSnapshot:        facts.Snapshot(),
+			SideEffectsIsolated: true,
+			Explain:             ex,
+			Partial:             skipped != nil,
+			SkippedFacts:        skipped,
 		}, nil
 	}
 
 	if final != nil && final.Type == "deny" {
 		return &Response{
-			Outcome:  "denied",
+			Outcome:  OutcomeDenied,
 			Error:    final.Error,
 			Verdicts: verdicts,
+			Explain:  ex,
 		}, nil
 	}
 
 	if final != nil && final.Type == "escalate" {
-		return &Response{
-			Outcome:  "escalated",
+		resp := &Response{
+			Outcome:  OutcomeEscalated,
 			Verdicts: verdicts,
-		}, nil
+			Explain:  ex,
+		}
+		if e.escalations != nil {
+			id, err := e.enqueueEscalation(ctx, contract, req, rec, final)
+			if err != nil {
+				return &Response{
+					Outcome: OutcomeSystemError,
+					Error: &ErrorEnvelope{
+						Code:       "ESCALATION_FAILED",
+						Message:    fmt.Sprintf("escalation could not be queued: %v", err),
+						HttpStatus: 503,
+						Category:   "system",
+						Retryable:  true,
+					},
+					Verdicts: verdicts,
+					Explain:  ex,
+				}, nil
+			}
+			resp.EscalationID = id
+		}
+		return resp, nil
 	}
 
-	// Step 6: Execute — side effects happen here only.
-	result, err := e.ports.Execute(ctx, operationPort(op), req.Operation, req.Input)
+	// Step 6: Transition entity state and execute — side effects happen
+	// here only. Transitions are claimed first, so that of two requests
+	// racing on an instance only one executes; a failed execution undoes
+	// its claims.
+	if refused := e.runHooks(ctx, hooks, HookBeforeExecute); refused != nil {
+		refused.Explain = ex
+		return refused, nil
+	}
+	release, refused := e.acquireExecution(ctx, req.Operation, op)
+	if refused != nil {
+		refused.Verdicts = verdicts
+		refused.Explain = ex
+		return refused, nil
+	}
+	if refused := e.claimTransitions(ctx, transitions); refused != nil {
+		release()
+		refused.Verdicts = verdicts
+		refused.Explain = ex
+		return refused, nil
+	}
+	result, err := ports.Execute(ctx, operationPort(op), req.Operation, req.Input)
+	release()
+	var violation *PortContractViolation
+	if errors.As(err, &violation) {
+		rec.PortContractViolation = violation
+		resp := portContractViolation(violation)
+		resp.Explain = ex
+		e.afterExecute(ctx, hooks, resp)
+		return resp, nil
+	}
 	if err != nil {
-		return &Response{
-			Outcome: "system_error",
-			Error: &ErrorEnvelope{
-				Code:       "EXECUTION_FAILED",
-				Message:    err.Error(),
-				HttpStatus: 500,
-				Category:   "system",
-				Retryable:  true,
-			},
-		}, nil
+		e.undoTransitions(ctx, transitions)
+		resp := executionFailed(err)
+		resp.Explain = ex
+		e.afterExecute(ctx, hooks, resp)
+		return resp, nil
 	}
 
-	// Step 7: Transition entity state (recorded in port adapter for this POC).
-
+	e.recordExecution(ctx, contract, rec, req.Input, result)
 	resp := &Response{
-		Outcome: "executed",
+		Outcome: OutcomeExecuted,
 		Output:  result,
+		Explain: ex,
+		Undo:    undoInfo(op, req.Input, result, rec.Timestamp),
 	}
 	if len(verdicts) > 0 {
 		resp.Verdicts = verdicts // include any flags
 	}
+	if cacheable {
+		e.cache.put(key, resp, op.Cache.Duration(), rec.Timestamp)
+	}
+	e.afterExecute(ctx, hooks, resp)
 	return resp, nil
 }
 
+func contractVersionMismatch() *Response {
+	return &Response{
+		Outcome: OutcomeSystemError,
+		Error: &ErrorEnvelope{
+			Code:       "CONTRACT_VERSION_MISMATCH",
+			Message:    "Client contract version is stale — re-fetch contracts and retry",
+			HttpStatus: 409,
+			Category:   "system",
+			Retryable:  true,
+		},
+	}
+}
+
+// ErrDryRunSideEffect is returned when a dry-run evaluation attempts to
+// execute an operation through its ports.
+var ErrDryRunSideEffect = errors.New("side effect attempted during dry-run")
+
+// readOnlyPorts wraps a PortRegistry for dry-run evaluation: fact reads pass
+// through, Execute is refused.
+type readOnlyPorts struct {
+	PortRegistry
+}
+
+func (p readOnlyPorts) Execute(_ context.Context, port, operation string, _ map[string]any) (map[string]any, error) {
+	return nil, fmt.Errorf("execute %s on port %s: %w", operation, port, ErrDryRunSideEffect)
+}
+
 // operationPort returns the primary port for executing an operation.
 // In this POC, ProcessPayment is handled by invoiceRepo; GetInvoice also by invoiceRepo.
 func operationPort(_ OperationDef) string {
@@ -160,23 +516,49 @@ func operationPort(_ OperationDef) string {
 
 // gatherFacts collects the base facts needed by the operation's rules.
 // Only facts relevant to the operation are validated as required.
-// Port facts are fetched in parallel.
-func (e *Engine) gatherFacts(ctx context.Context, c *Contract, operation string, input map[string]any) (*FactSet, error) {
+// Port facts are fetched in parallel. If stop fires before every port fact
+// has arrived, gathering ends there and the outstanding facts are returned,
+// sorted, as skipped; a nil stop waits for all of them.
+//
+// Derived facts in cached are taken from the fact cache, and base facts
+// needed only to derive them are not gathered. With a session, port facts
+// are looked up in it before the fact cache, and those fetched are kept in
+// it.
+func (e *Engine) gatherFacts(ctx context.Context, ports PortRegistry, c *Contract, operation string, input, callerCtx map[string]any, cached map[string]*cachedFact, sess *session, stop <-chan time.Time) (*FactSet, []string, error) {
 	facts := NewFactSet()
 
-	needed := neededBaseFacts(c, operation)
+	resolved := make(map[string]bool, len(cached))
+	for name, ent := range cached {
+		facts.SetTraced(name, ent.value, ent.trace)
+		resolved[name] = true
+	}
+	needed, _ := neededFacts(c, operation, resolved)
 
 	type portResult struct {
-		name string
-		val  any
-		err  error
-		def  FactDef
+		name  string
+		val   any
+		err   error
+		def   FactDef
+		trace FactTrace
 	}
 
 	ch := make(chan portResult, len(needed))
-	var wg sync.WaitGroup
+	pending := map[string]bool{}
 
 	for name := range needed {
+		if entity, ok := entityStateRef(name); ok {
+			id, err := entityKey(c, entity, input)
+			if err != nil {
+				return nil, nil, err
+			}
+			pending[name] = true
+			go func(n string) {
+				trace := FactTrace{Source: factSourceState, FetchedAt: e.now().UTC()}
+				val, err := e.entityState(ctx, c, entity, id)
+				ch <- portResult{name: n, val: val, err: err, trace: trace}
+			}(name)
+			continue
+		}
 		def, ok := c.Facts[name]
 		if !ok {
 			continue
@@ -186,48 +568,113 @@ func (e *Engine) gatherFacts(ctx context.Context, c *Contract, operation string,
 			if val, ok := input[name]; ok {
 				facts.Set(name, val)
 			} else if def.Required {
-				return nil, fmt.Errorf("required input fact %q missing from request", name)
+				return nil, nil, fmt.Errorf("required input fact %q missing from request", name)
+			}
+		case def.Source == "param":
+			if val, ok := c.ParamValue(strings.TrimPrefix(name, paramFactPrefix)); ok {
+				facts.Set(name, val)
 			}
 		case def.Source == "ctx":
-			if name == "user.roles" {
-				facts.Set(name, []string{"customer"})
+			if val, ok := callerCtx[name]; ok {
+				facts.Set(name, val)
+			} else if def.Required {
+				return nil, nil, fmt.Errorf("required ctx fact %q missing from request context", name)
 			}
 		case strings.HasPrefix(def.Source, "port:"):
-			wg.Add(1)
+			hit, ok := (*cachedFact)(nil), false
+			if sess != nil {
+				hit, ok = sess.factCache().get(name, input, e.now())
+			}
+			if !ok {
+				hit, ok = e.factCache.get(name, input, e.now())
+			}
+			if ok {
+				if !stale(def, hit.trace, e.now()) || def.OnStale == onStaleFlag {
+					facts.SetTraced(name, hit.value, hit.trace)
+					continue
+				}
+				if def.OnStale == onStaleDeny {
+					return nil, nil, staleError(name, def, hit.trace, e.now())
+				}
+				// Refresh: fetch the fact again below.
+			}
+			pending[name] = true
 			go func(n string, d FactDef) {
-				defer wg.Done()
-				val, err := e.ports.Get(ctx, portName(d.Source), n, input)
-				ch <- portResult{name: n, val: val, err: err, def: d}
+				trace := FactTrace{Source: factSourcePort, Port: portName(d.Source), FetchedAt: e.now().UTC()}
+				val, err := ports.Get(ctx, portName(d.Source), n, input)
+				val = unwrapTimestamped(val, &trace)
+				ch <- portResult{name: n, val: val, err: err, def: d, trace: trace}
 			}(name, def)
 		}
 	}
 
-	go func() { wg.Wait(); close(ch) }()
-
-	for r := range ch {
+	for len(pending) > 0 {
+		var r portResult
+		select {
+		case r = <-ch:
+		case <-stop:
+			skipped := make([]string, 0, len(pending))
+			for name := range pending {
+				skipped = append(skipped, name)
+			}
+			sort.Strings(skipped)
+			return facts, skipped, nil
+		}
+		delete(pending, r.name)
+		var violation *PortContractViolation
+		if errors.As(r.err, &violation) {
+			return nil, nil, violation
+		}
 		if r.err != nil {
 			switch r.def.OnMissing {
 			case "deny":
-				return nil, &factError{fact: r.name, reason: r.err.Error(), outcome: "denied"}
+				return nil, nil, &factError{fact: r.name, reason: r.err.Error(), outcome: OutcomeDenied}
 			case "skip":
 				// Fact absent — conditions referencing it evaluate to false.
 			default: // "system_error"
-				return nil, &factError{fact: r.name, reason: r.err.Error(), outcome: "system_error"}
+				return nil, nil, &factError{fact: r.name, reason: r.err.Error(), outcome: OutcomeSystemError}
 			}
 			continue
 		}
-		facts.Set(r.name, r.val)
+		// A port's own data may be too old; refreshing cannot help.
+		if stale(r.def, r.trace, e.now()) && r.def.OnStale != onStaleFlag {
+			return nil, nil, staleError(r.name, r.def, r.trace, e.now())
+		}
+		facts.SetTraced(r.name, r.val, r.trace)
+		if sess != nil && r.trace.Source == factSourcePort {
+			sess.cache(r.name, r.val, r.trace, input, e.now())
+		}
 	}
 
-	return facts, nil
+	return facts, nil, nil
+}
+
+// NeededFacts returns the base facts, sorted, that the rules constraining
+// operation depend on, directly or through derived facts.
+func NeededFacts(c *Contract, operation string) []string {
+	needed := neededBaseFacts(c, operation)
+	out := make([]string, 0, len(needed))
+	for f := range needed {
+		out = append(out, f)
+	}
+	sort.Strings(out)
+	return out
 }
 
 // neededBaseFacts returns the set of base fact names (all sources) required by
 // the rules that constrain the given operation.
 // Dotted paths like "payment.amount.value" are resolved to their base fact "payment.amount".
 func neededBaseFacts(c *Contract, operation string) map[string]bool {
-	needed := map[string]bool{}
-	derivedVisited := map[string]bool{}
+	needed, _ := neededFacts(c, operation, nil)
+	return needed
+}
+
+// neededFacts returns the base and derived facts the rules constraining
+// operation, and its currency checks, depend on. The dependencies of derived
+// facts in resolved, whose values are already known, are not followed.
+func neededFacts(c *Contract, operation string, resolved map[string]bool) (needed, derivedVisited map[string]bool) {
+	needed = map[string]bool{}
+	derivedVisited = map[string]bool{}
 
 	var addPath func(path string)
 	addPath = func(path string) {
@@ -236,12 +683,23 @@ func neededBaseFacts(c *Contract, operation string) map[string]bool {
 			needed[path] = true
 			return
 		}
+		// Entity state, resolved by the instance's key.
+		if entity, ok := entityStateRef(path); ok {
+			needed[path] = true
+			if def, ok := c.Entities[entity]; ok && def.Key != "" {
+				addPath(def.Key)
+			}
+			return
+		}
 		// Derived fact — recurse into its arg dependencies.
 		if df, ok := c.DerivedFacts[path]; ok {
 			if derivedVisited[path] {
 				return
 			}
 			derivedVisited[path] = true
+			if resolved[path] {
+				return
+			}
 			for _, arg := range df.Derivation.Args {
 				if arg.Fact != "" {
 					addPath(arg.Fact)
@@ -266,22 +724,36 @@ func neededBaseFacts(c *Contract, operation string) map[string]bool {
 
 	op, ok := c.Operations[operation]
 	if !ok {
-		return needed
+		return needed, derivedVisited
 	}
 	for _, ruleID := range op.ConstrainedBy {
 		for i := range c.Rules {
 			if c.Rules[i].ID == ruleID {
 				collectFromCondition(c.Rules[i].When, addPath)
+				for _, name := range ruleParamRefs(c.Rules[i]) {
+					addPath(paramFactPrefix + name)
+				}
+				if r := c.Rules[i].Rollout; r != nil {
+					addPath(r.Key)
+				}
 			}
 		}
 	}
-	return needed
+	if op.Currencies != nil {
+		for _, name := range op.Currencies.Facts {
+			addPath(name)
+		}
+	}
+	return needed, derivedVisited
 }
 
 func collectFromCondition(cond Condition, collect func(string)) {
 	if cond.Fact != "" {
 		collect(cond.Fact)
 	}
+	if _, fact, _ := cond.identityOp(); fact != "" {
+		collect(fact)
+	}
 	for _, sub := range cond.All {
 		collectFromCondition(sub, collect)
 	}
@@ -293,12 +765,29 @@ func collectFromCondition(cond Condition, collect func(string)) {
 	}
 }
 
-// deriveFacts evaluates derived facts in topological order.
-func (e *Engine) deriveFacts(c *Contract, facts *FactSet) error {
+// deriveFacts evaluates derived facts in topological order, keeping those
+// already served from the fact cache.
+func (e *Engine) deriveFacts(ctx context.Context, ports PortRegistry, c *Contract, facts *FactSet) error {
+	// Derivations and rules compare quantities in their declared units.
+	facts.setUnits(c)
 	order := topoSort(c.DerivedFacts)
+	traces := facts.Traces()
 	for _, name := range order {
+		if traces[name].Source == factSourceCache {
+			continue // served from the fact cache
+		}
 		df := c.DerivedFacts[name]
-		val, err := evalDerivation(df.Derivation, facts)
+		var val any
+		var err error
+		if df.Derivation.Fn == "convert" {
+			val, err = convert(ctx, ports, name, df.Derivation, facts)
+		} else {
+			val, err = evalDerivation(df.Derivation, facts)
+		}
+		var fe *factError
+		if errors.As(err, &fe) {
+			return err
+		}
 		if err != nil {
 			return fmt.Errorf("derive %q: %w", name, err)
 		}
@@ -336,6 +825,15 @@ func topoSort(dfs map[string]DerivedFactDef) []string {
 	return order
 }
 
+// argUnit is the unit of a comparison's second argument: its fact's, or for
+// a literal, that of the fact it is compared with.
+func argUnit(arg, other DerivationArg, facts *FactSet) string {
+	if arg.Fact != "" {
+		return facts.unit(arg.Fact)
+	}
+	return facts.unit(other.Fact)
+}
+
 // evalDerivation evaluates a single derivation against the fact set.
 func evalDerivation(d Derivation, facts *FactSet) (any, error) {
 	getArg := func(arg DerivationArg) (any, bool) {
@@ -352,12 +850,7 @@ func evalDerivation(d Derivation, facts *FactSet) (any, error) {
 		}
 		a, _ := getArg(d.Args[0])
 		b, _ := getArg(d.Args[1])
-		fa, oka := toFloat(a)
-		fb, okb := toFloat(b)
-		if oka && okb {
-			return fa > fb, nil
-		}
-		return false, nil
+		return compareQuantities("greater_than", a, facts.unit(d.Args[0].Fact), b, argUnit(d.Args[1], d.Args[0], facts)), nil
 
 	case "greater_or_equal":
 		if len(d.Args) < 2 {
@@ -365,12 +858,7 @@ func evalDerivation(d Derivation, facts *FactSet) (any, error) {
 		}
 		a, _ := getArg(d.Args[0])
 		b, _ := getArg(d.Args[1])
-		fa, oka := toFloat(a)
-		fb, okb := toFloat(b)
-		if oka && okb {
-			return fa >= fb, nil
-		}
-		return false, nil
+		return compareQuantities("greater_or_equal", a, facts.unit(d.Args[0].Fact), b, argUnit(d.Args[1], d.Args[0], facts)), nil
 
 	case "less_than":
 		if len(d.Args) < 2 {
@@ -378,12 +866,7 @@ func evalDerivation(d Derivation, facts *FactSet) (any, error) {
 		}
 		a, _ := getArg(d.Args[0])
 		b, _ := getArg(d.Args[1])
-		fa, oka := toFloat(a)
-		fb, okb := toFloat(b)
-		if oka && okb {
-			return fa < fb, nil
-		}
-		return false, nil
+		return compareQuantities("less_than", a, facts.unit(d.Args[0].Fact), b, argUnit(d.Args[1], d.Args[0], facts)), nil
 
 	case "equals":
 		if len(d.Args) < 2 {
@@ -430,13 +913,22 @@ func evalDerivation(d Derivation, facts *FactSet) (any, error) {
 		}
 		return false, nil
 
+	case "weighted_sum":
+		return weightedSum(d.Args, facts), nil
+
+	case "score":
+		return scoreBand(d.Bands, weightedSum(d.Args, facts)), nil
+
 	default:
 		return nil, fmt.Errorf("unknown derivation function: %s", d.Fn)
 	}
 }
 
-// evaluateRules returns all matching verdicts for the given operation.
-func (e *Engine) evaluateRules(c *Contract, operation string, facts *FactSet) []Verdict {
+// evaluateRules returns all matching verdicts for the given operation: it
+// evaluates the rules constraining operation that are in effect at the given
+// instant, in contract order. Wide rule sets have their conditions
+// evaluated in parallel (see matchRules).
+func (e *Engine) evaluateRules(c *Contract, operation string, facts *FactSet, at time.Time) []Verdict {
 	var verdicts []Verdict
 
 	op := c.Operations[operation]
@@ -444,42 +936,64 @@ func (e *Engine) evaluateRules(c *Contract, operation string, facts *FactSet) []
 	for _, id := range op.ConstrainedBy {
 		ruleSet[id] = true
 	}
-
-	for _, rule := range c.Rules {
-		if !ruleSet[rule.ID] {
-			continue
+	var rules []*RuleDef
+	for i := range c.Rules {
+		if ruleSet[c.Rules[i].ID] && c.Rules[i].ActiveAt(at) {
+			rules = append(rules, &c.Rules[i])
 		}
-		if !evalCondition(rule.When, facts) {
+	}
+
+	facts.evaluateAs(c, at)
+	matched := matchRules(rules, facts, ruleWorkers(len(rules)))
+	for i, rule := range rules {
+		if !matched[i] {
 			continue
 		}
 		v := rule.Verdict
+		if verdictType(v) == "" {
+			continue // Validate reports rules without a verdict
+		}
 		switch {
 		case v.Deny != nil:
 			e := v.Deny.Error
 			verdicts = append(verdicts, Verdict{
+				Rule:   rule.ID,
 				Type:   "deny",
 				Code:   v.Deny.Code,
 				Reason: v.Deny.Reason,
 				Error:  &e,
 			})
 		case v.Escalate != nil:
+			queue := v.Escalate.Queue
+			if v.Escalate.QueueParam != "" {
+				if q, ok := facts.Get(paramFactPrefix + v.Escalate.QueueParam); ok {
+					queue = fmt.Sprint(q)
+				}
+			}
 			verdicts = append(verdicts, Verdict{
+				Rule:   rule.ID,
 				Type:   "escalate",
 				Reason: v.Escalate.Reason,
-				Queue:  v.Escalate.Queue,
+				Queue:  queue,
 			})
 		case v.Require != nil:
 			verdicts = append(verdicts, Verdict{
+				Rule:   rule.ID,
 				Type:   "require",
 				Reason: v.Require.Reason,
 			})
 		case v.Flag != nil:
 			verdicts = append(verdicts, Verdict{
+				Rule:   rule.ID,
 				Type:   "flag",
 				Code:   v.Flag.Code,
 				Reason: v.Flag.Reason,
 			})
 		}
+		verdicts[len(verdicts)-1].Metadata = v.Metadata
+		if !inRollout(rule, facts) {
+			verdicts[len(verdicts)-1].Shadow = true
+		}
 	}
 
 	return verdicts
@@ -507,27 +1021,64 @@ func evalCondition(cond Condition, facts *FactSet) bool {
 	case cond.Not != nil:
 		return !evalCondition(*cond.Not, facts)
 
+	case cond.isIdentity():
+		op, fact, operand := cond.identityOp()
+		val, _ := facts.Get(fact)
+		return evalIdentity(op, val, resolveOperand(operand, facts))
+
 	case cond.Fact != "":
 		val, _ := facts.GetPath(cond.Fact)
+		// A threshold without a unit is in the fact's.
+		unit := facts.unit(cond.Fact)
 		switch {
 		case cond.Equals != nil:
-			return applyOp("equals", val, cond.Equals)
+			return facts.stringMode(cond).equal(val, resolveOperand(cond.Equals, facts))
 		case cond.GreaterThan != nil:
-			return applyOp("greater_than", val, cond.GreaterThan)
+			return compareQuantities("greater_than", val, unit, resolveOperand(cond.GreaterThan, facts), unit)
 		case cond.LessThan != nil:
-			return applyOp("less_than", val, cond.LessThan)
+			return compareQuantities("less_than", val, unit, resolveOperand(cond.LessThan, facts), unit)
 		case len(cond.In) > 0:
+			mode := facts.stringMode(cond)
 			for _, v := range cond.In {
-				if applyOp("equals", val, v) {
+				if mode.equal(val, resolveOperand(v, facts)) {
 					return true
 				}
 			}
 			return false
+		case cond.Contains != nil:
+			return contains(val, resolveOperand(cond.Contains, facts))
+		case cond.isTime():
+			return evalTime(cond, val, facts)
+		case cond.isSet():
+			return evalSet(cond, val, facts)
 		}
 	}
 	return true
 }
 
+// contains reports whether a list has an element equal to want, or a string
+// has want as a substring.
+func contains(val, want any) bool {
+	switch v := val.(type) {
+	case string:
+		s, ok := want.(string)
+		return ok && strings.Contains(v, s)
+	case []any:
+		for _, el := range v {
+			if applyOp("equals", el, want) {
+				return true
+			}
+		}
+	case []string:
+		for _, el := range v {
+			if applyOp("equals", el, want) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func applyOp(op string, left, right any) bool {
 	switch op {
 	case "equals":
@@ -573,19 +1124,19 @@ func resolveVerdicts(verdicts []Verdict) *Verdict {
 	return best
 }
 
-func dryRunOutcome(v *Verdict) string {
+func dryRunOutcome(v *Verdict) Outcome {
 	if v == nil {
-		return "would_execute"
+		return OutcomeWouldExecute
 	}
 	switch v.Type {
 	case "deny":
-		return "would_deny"
+		return OutcomeWouldDeny
 	case "escalate":
-		return "would_escalate"
+		return OutcomeWouldEscalate
 	case "require":
-		return "would_require"
+		return OutcomeWouldRequire
 	default:
-		return "would_execute_with_flags"
+		return OutcomeWouldExecuteWithFlags
 	}
 }
 
@@ -602,7 +1153,27 @@ func randID(n int) string {
 type factError struct {
 	fact    string
 	reason  string
-	outcome string
+	outcome Outcome
+	stale   bool // the fact is older than its max_staleness
+}
+
+// factUnavailable is the response to a request whose fact could not be
+// gathered or derived.
+func factUnavailable(fe *factError) *Response {
+	code, message := "FACT_UNAVAILABLE", fmt.Sprintf("fact %q unavailable: %s", fe.fact, fe.reason)
+	if fe.stale {
+		code, message = "FACT_STALE", fmt.Sprintf("fact %q is stale: %s", fe.fact, fe.reason)
+	}
+	return &Response{
+		Outcome: fe.outcome,
+		Error: &ErrorEnvelope{
+			Code:       code,
+			Message:    message,
+			HttpStatus: 503,
+			Category:   "system",
+			Retryable:  true,
+		},
+	}
 }
 
 func (e *factError) Error() string {
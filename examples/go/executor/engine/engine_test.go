@@ -2,8 +2,10 @@ package engine
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"testing"
+	"time"
 )
 
 // mockPorts implements PortRegistry for tests.
@@ -195,7 +197,7 @@ func TestEvaluateRules_denyVerdictWhenConditionMatches(t *testing.T) {
 	fs := NewFactSet()
 	fs.Set("customer.status", "blocked")
 
-	verdicts := e.evaluateRules(contract, "testOp", fs)
+	verdicts := e.evaluateRules(contract, "testOp", fs, time.Now())
 
 	if len(verdicts) != 1 {
 		t.Fatalf("expected 1 verdict, got %d", len(verdicts))
@@ -217,7 +219,7 @@ func TestEvaluateRules_flagVerdictWhenConditionMatches(t *testing.T) {
 	fs := NewFactSet()
 	fs.Set("amount", 2000.0)
 
-	verdicts := e.evaluateRules(contract, "testOp", fs)
+	verdicts := e.evaluateRules(contract, "testOp", fs, time.Now())
 
 	if len(verdicts) != 1 || verdicts[0].Type != "flag" {
 		t.Fatalf("expected flag verdict, got %+v", verdicts)
@@ -236,7 +238,7 @@ func TestEvaluateRules_escalateVerdictWhenConditionMatches(t *testing.T) {
 	fs := NewFactSet()
 	fs.Set("risk.score", 95.0)
 
-	verdicts := e.evaluateRules(contract, "testOp", fs)
+	verdicts := e.evaluateRules(contract, "testOp", fs, time.Now())
 
 	if len(verdicts) != 1 || verdicts[0].Type != "escalate" {
 		t.Fatalf("expected escalate verdict, got %+v", verdicts)
@@ -255,7 +257,7 @@ func TestEvaluateRules_noVerdictWhenConditionDoesNotMatch(t *testing.T) {
 	fs := NewFactSet()
 	fs.Set("customer.status", "active")
 
-	verdicts := e.evaluateRules(contract, "testOp", fs)
+	verdicts := e.evaluateRules(contract, "testOp", fs, time.Now())
 
 	if len(verdicts) != 0 {
 		t.Fatalf("expected no verdicts, got %+v", verdicts)
@@ -281,7 +283,7 @@ func TestEvaluateRules_ruleNotInOperationConstraintsIsSkipped(t *testing.T) {
 	fs := NewFactSet()
 	fs.Set("x", "y")
 
-	verdicts := e.evaluateRules(contract, "testOp", fs)
+	verdicts := e.evaluateRules(contract, "testOp", fs, time.Now())
 
 	if len(verdicts) != 0 {
 		t.Fatalf("expected rule not in ConstrainedBy to be skipped, got %+v", verdicts)
@@ -513,7 +515,7 @@ func TestDeriveFacts_evaluatesChainInTopologicalOrder(t *testing.T) {
 	fs := NewFactSet()
 	fs.Set("amount", 1000.0)
 
-	if err := e.deriveFacts(contract, fs); err != nil {
+	if err := e.deriveFacts(context.Background(), nil, contract, fs); err != nil {
 		t.Fatal(err)
 	}
 
@@ -792,3 +794,54 @@ func TestEngine_Evaluate_portFactFetchedAndUsedInCondition(t *testing.T) {
 		t.Fatalf("expected executed, got %s (error: %+v)", resp.Outcome, resp.Error)
 	}
 }
+
+func TestEngine_Evaluate_dryRunMakesNoExecuteCalls(t *testing.T) {
+	calls := 0
+	ports := &mockPorts{
+		getFunc: func(_ context.Context, _, _ string, _ map[string]any) (any, error) {
+			return "active", nil
+		},
+		executeFunc: func(_ context.Context, _, _ string, _ map[string]any) (map[string]any, error) {
+			calls++
+			return map[string]any{}, nil
+		},
+	}
+	eng := NewEngine(ports)
+	contract := makeSimpleContract("r1",
+		VerdictDef{Flag: &FlagVerdict{Code: "F"}},
+		Condition{Fact: "customer.status", Equals: "active"},
+	)
+	contract.Facts["customer.status"] = FactDef{Source: "port:customerRepo", Required: true}
+	eng.LoadContract(contract, "etag-1")
+
+	resp, err := eng.Evaluate(context.Background(), &Request{Operation: "testOp", DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 0 {
+		t.Fatalf("expected no Execute calls during dry-run, got %d", calls)
+	}
+	if !resp.SideEffectsIsolated {
+		t.Fatal("expected dry-run response to report side-effect isolation")
+	}
+	if resp.FactSnapshot["customer.status"] != "active" {
+		t.Fatalf("expected port facts to be readable during dry-run, got %v", resp.FactSnapshot)
+	}
+}
+
+func TestReadOnlyPorts_ExecuteRefused(t *testing.T) {
+	calls := 0
+	inner := &mockPorts{
+		executeFunc: func(_ context.Context, _, _ string, _ map[string]any) (map[string]any, error) {
+			calls++
+			return nil, nil
+		},
+	}
+	_, err := readOnlyPorts{inner}.Execute(context.Background(), "invoiceRepo", "ProcessPayment", nil)
+	if !errors.Is(err, ErrDryRunSideEffect) {
+		t.Fatalf("expected ErrDryRunSideEffect, got %v", err)
+	}
+	if calls != 0 {
+		t.Fatal("read-only view must not forward Execute")
+	}
+}
@@ -0,0 +1,281 @@
+// Package enginetest provides property-based checks of rule semantics.
+//
+// Contract repositories can run the built-in properties against their own
+// contracts to catch evaluation regressions without writing per-rule tests:
+//
+//	func TestContractProperties(t *testing.T) {
+//		c := enginetest.LoadDir(t, "./billing")
+//		enginetest.Check(t, c, enginetest.Properties()...)
+//	}
+//
+// Cases are generated deterministically from the literals that appear in the
+// contract's conditions, so rules actually fire across the generated inputs.
+//
+// Golden pins whole responses to golden files, so any change to what a
+// request returns shows up as a diff:
+//
+//	resp, _ := eng.Evaluate(ctx, req)
+//	enginetest.Golden(t, "testdata/pay_over_balance.golden.json", resp)
+package enginetest
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"covenant-poc/executor/engine"
+)
+
+// Config controls case generation for Check.
+type Config struct {
+	Cases int    // cases generated per operation (default 200)
+	Seed  uint64 // generator seed (default 1)
+}
+
+// Case is one generated invocation: the request input plus the values the
+// fake ports return for port-sourced facts. Port facts absent from PortFacts
+// fail to resolve, exercising on_missing handling.
+type Case struct {
+	Operation string
+	Input     map[string]any
+	PortFacts map[string]any
+}
+
+func (c Case) String() string {
+	return fmt.Sprintf("%s input=%v ports=%v", c.Operation, c.Input, c.PortFacts)
+}
+
+// Property is a named invariant checked against every generated case.
+// Check returns a non-nil error describing the violation.
+type Property struct {
+	Name  string
+	Check func(c *engine.Contract, cs Case) error
+}
+
+// Properties returns all built-in properties.
+func Properties() []Property {
+	return []Property{DenyWinsOverFlag, FlagNeverBlocksExecution, DryRunNeverExecutes}
+}
+
+// LoadDir compiles every .cue file in dir (sorted by name), composing any
+// imported base contracts from sibling directories, and fails the test on
+// error.
+func LoadDir(tb testing.TB, dir string) *engine.Contract {
+	tb.Helper()
+	c, err := engine.CompileDir(dir)
+	if err != nil {
+		tb.Fatalf("compile %s: %v", dir, err)
+	}
+	return c
+}
+
+// Check runs each property as a subtest over generated cases using the
+// default Config.
+func Check(t *testing.T, c *engine.Contract, props ...Property) {
+	t.Helper()
+	CheckWith(t, c, Config{}, props...)
+}
+
+// CheckWith is Check with explicit generation settings.
+func CheckWith(t *testing.T, c *engine.Contract, cfg Config, props ...Property) {
+	t.Helper()
+	cases := Generate(c, cfg)
+	for _, p := range props {
+		t.Run(p.Name, func(t *testing.T) {
+			for _, cs := range cases {
+				if err := p.Check(c, cs); err != nil {
+					t.Fatalf("%s: %v\n  case: %s", p.Name, err, cs)
+				}
+			}
+		})
+	}
+}
+
+// Generate produces cases for every operation in the contract.
+func Generate(c *engine.Contract, cfg Config) []Case {
+	if cfg.Cases <= 0 {
+		cfg.Cases = 200
+	}
+	if cfg.Seed == 0 {
+		cfg.Seed = 1
+	}
+	rng := rand.New(rand.NewPCG(cfg.Seed, cfg.Seed))
+	pools := literalPools(c)
+
+	ops := make([]string, 0, len(c.Operations))
+	for name := range c.Operations {
+		ops = append(ops, name)
+	}
+	sort.Strings(ops)
+
+	var cases []Case
+	for _, op := range ops {
+		paths := operationPaths(c, op)
+		for range cfg.Cases {
+			cs := Case{Operation: op, Input: map[string]any{}, PortFacts: map[string]any{}}
+			for _, path := range paths {
+				base, rest := baseFact(c, path)
+				if base == "" {
+					continue
+				}
+				def := c.Facts[base]
+				target := cs.PortFacts
+				if def.Source == "input" {
+					target = cs.Input
+				} else if !strings.HasPrefix(def.Source, "port:") {
+					continue
+				}
+				// Occasionally leave port facts unresolved.
+				if def.Source != "input" && rng.IntN(10) == 0 {
+					continue
+				}
+				setPath(target, base, rest, pick(rng, pools[path]))
+			}
+			setCurrencies(c, op, cs)
+			cases = append(cases, cs)
+		}
+	}
+	return cases
+}
+
+// --- built-in properties ---
+
+// DenyWinsOverFlag asserts that whenever a deny verdict is produced, the
+// resolved outcome is a denial regardless of other verdicts.
+var DenyWinsOverFlag = Property{
+	Name: "deny_wins_over_flag",
+	Check: func(c *engine.Contract, cs Case) error {
+		dry := evaluate(c, cs, true)
+		if !hasVerdict(dry.resp, "deny") {
+			return nil
+		}
+		if dry.resp.Outcome != engine.OutcomeWouldDeny {
+			return fmt.Errorf("dry-run produced deny verdict but outcome %q", dry.resp.Outcome)
+		}
+		live := evaluate(c, cs, false)
+		if live.resp.Outcome != engine.OutcomeDenied {
+			return fmt.Errorf("deny verdict produced but live outcome %q", live.resp.Outcome)
+		}
+		return nil
+	},
+}
+
+// FlagNeverBlocksExecution asserts that adding an always-matching flag rule
+// to an operation never turns an executed outcome into anything else.
+var FlagNeverBlocksExecution = Property{
+	Name: "flag_never_blocks_execution",
+	Check: func(c *engine.Contract, cs Case) error {
+		before := evaluate(c, cs, false)
+		if before.resp.Outcome != engine.OutcomeExecuted {
+			return nil
+		}
+		after := evaluate(withAlwaysFlag(c, cs.Operation), cs, false)
+		if after.resp.Outcome != engine.OutcomeExecuted {
+			return fmt.Errorf("adding a flag rule changed outcome executed → %q", after.resp.Outcome)
+		}
+		return nil
+	},
+}
+
+// DryRunNeverExecutes asserts that dry-run evaluation makes no port Execute calls.
+var DryRunNeverExecutes = Property{
+	Name: "dry_run_never_executes",
+	Check: func(c *engine.Contract, cs Case) error {
+		r := evaluate(c, cs, true)
+		if n := r.ports.ExecuteCalls(); n != 0 {
+			return fmt.Errorf("dry-run made %d Execute call(s)", n)
+		}
+		return nil
+	},
+}
+
+// --- harness ---
+
+// Ports is a fake engine.PortRegistry serving fixed fact values and counting
+// Execute calls.
+type Ports struct {
+	mu           sync.Mutex
+	facts        map[string]any
+	executeCalls atomic.Int64
+}
+
+// NewPorts returns fake ports that serve the given fact values.
+func NewPorts(facts map[string]any) *Ports {
+	return &Ports{facts: facts}
+}
+
+func (p *Ports) Get(_ context.Context, port, fact string, _ map[string]any) (any, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	v, ok := p.facts[fact]
+	if !ok {
+		return nil, fmt.Errorf("%s: fact %q unavailable", port, fact)
+	}
+	return v, nil
+}
+
+func (p *Ports) Execute(_ context.Context, _, _ string, _ map[string]any) (map[string]any, error) {
+	p.executeCalls.Add(1)
+	return map[string]any{}, nil
+}
+
+// ExecuteCalls returns the number of Execute calls observed.
+func (p *Ports) ExecuteCalls() int64 {
+	return p.executeCalls.Load()
+}
+
+type result struct {
+	resp  *engine.Response
+	ports *Ports
+}
+
+func evaluate(c *engine.Contract, cs Case, dryRun bool) result {
+	ports := NewPorts(cs.PortFacts)
+	eng := engine.NewEngine(ports)
+	eng.LoadContract(c, "enginetest")
+	resp, err := eng.Evaluate(context.Background(), &engine.Request{
+		Operation: cs.Operation,
+		Input:     cs.Input,
+		DryRun:    dryRun,
+	})
+	if err != nil {
+		// Evaluation errors (e.g. missing required input) are outside the
+		// properties' scope; treat them as vacuously satisfied.
+		return result{resp: &engine.Response{}, ports: ports}
+	}
+	return result{resp: resp, ports: ports}
+}
+
+func hasVerdict(resp *engine.Response, typ string) bool {
+	for _, v := range resp.Verdicts {
+		if v.Type == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// withAlwaysFlag returns a copy of c where op is additionally constrained by a
+// flag rule whose condition always matches.
+func withAlwaysFlag(c *engine.Contract, op string) *engine.Contract {
+	const id = "enginetest.always-flag"
+	cp := *c
+	cp.Rules = append(append([]engine.RuleDef(nil), c.Rules...), engine.RuleDef{
+		ID:        id,
+		AppliesTo: []string{op},
+		Verdict:   engine.VerdictDef{Flag: &engine.FlagVerdict{Code: "ENGINETEST", Reason: "always"}},
+	})
+	cp.Operations = make(map[string]engine.OperationDef, len(c.Operations))
+	for name, def := range c.Operations {
+		if name == op {
+			def.ConstrainedBy = append(append([]string(nil), def.ConstrainedBy...), id)
+		}
+		cp.Operations[name] = def
+	}
+	return &cp
+}
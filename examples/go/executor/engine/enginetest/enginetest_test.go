@@ -0,0 +1,51 @@
+package enginetest
+
+import (
+	"testing"
+
+	"covenant-poc/executor/engine"
+)
+
+func TestProperties_billingContract(t *testing.T) {
+	c := LoadDir(t, "../../../contracts/billing")
+	Check(t, c, Properties()...)
+}
+
+func TestGenerate_exercisesDenyAndExecute(t *testing.T) {
+	c := LoadDir(t, "../../../contracts/billing")
+	outcomes := map[engine.Outcome]int{}
+	for _, cs := range Generate(c, Config{Cases: 100}) {
+		if cs.Operation != "ProcessPayment" {
+			continue
+		}
+		outcomes[evaluate(c, cs, true).resp.Outcome]++
+	}
+	if outcomes["would_deny"] == 0 || outcomes["would_execute"]+outcomes["would_execute_with_flags"] == 0 {
+		t.Fatalf("generated cases do not cover both deny and execute: %v", outcomes)
+	}
+}
+
+func TestGenerate_isDeterministic(t *testing.T) {
+	c := LoadDir(t, "../../../contracts/billing")
+	a := Generate(c, Config{Cases: 20, Seed: 7})
+	b := Generate(c, Config{Cases: 20, Seed: 7})
+	if len(a) != len(b) {
+		t.Fatalf("case counts differ: %d vs %d", len(a), len(b))
+	}
+	for i := range a {
+		if a[i].String() != b[i].String() {
+			t.Fatalf("case %d differs:\n  %s\n  %s", i, a[i], b[i])
+		}
+	}
+}
+
+func TestWithAlwaysFlag_doesNotMutateOriginal(t *testing.T) {
+	c := &engine.Contract{
+		Operations: map[string]engine.OperationDef{"op": {ConstrainedBy: []string{"r1"}}},
+		Rules:      []engine.RuleDef{{ID: "r1"}},
+	}
+	withAlwaysFlag(c, "op")
+	if len(c.Rules) != 1 || len(c.Operations["op"].ConstrainedBy) != 1 {
+		t.Fatalf("original contract mutated: %+v", c)
+	}
+}
@@ -0,0 +1,270 @@
+package enginetest
+
+import (
+	"math/rand/v2"
+	"sort"
+	"strings"
+
+	"covenant-poc/executor/engine"
+)
+
+// defaultPool is used for fact paths that no condition compares to a literal,
+// e.g. two facts compared with each other inside a derivation.
+var defaultPool = []any{0.0, 1.0, 99.0, 100.0, 1000.0, 10000.0, 100000.0, "active", "closed", true, false}
+
+func pick(rng *rand.Rand, pool []any) any {
+	if len(pool) == 0 {
+		pool = defaultPool
+	}
+	return pool[rng.IntN(len(pool))]
+}
+
+// literalPools maps each fact path to the literals it is compared against,
+// plus neighbouring values so thresholds are exercised on both sides.
+func literalPools(c *engine.Contract) map[string][]any {
+	pools := map[string][]any{}
+	add := func(path string, vals ...any) {
+		pools[path] = append(pools[path], vals...)
+	}
+	numeric := func(path string, v any) {
+		if f, ok := v.(float64); ok {
+			add(path, f-1, f, f+1)
+		} else if i, ok := v.(int); ok {
+			add(path, float64(i-1), float64(i), float64(i+1))
+		}
+	}
+	// Timestamp facts take the instant an operand names, and instants long
+	// before and after any operand, including "now" and "today".
+	timestamp := func(path string, v any) {
+		s, ok := v.(string)
+		if !ok {
+			return
+		}
+		if !strings.HasPrefix(s, "now") && !strings.HasPrefix(s, "today") {
+			add(path, s)
+		}
+		add(path, "1970-01-01T00:00:00Z", "2999-01-01T00:00:00Z")
+	}
+
+	// List facts take a set operand's values, its first value alone, and
+	// both with a value outside it.
+	list := func(path string, set []any) {
+		vals := make([]any, len(set))
+		for i, v := range set {
+			vals[i] = c.ResolveOperand(v)
+		}
+		add(path, vals, []any{vals[0]}, append([]any{"enginetest-other"}, vals...), []any{"enginetest-other"})
+	}
+
+	var walk func(cond engine.Condition)
+	walk = func(cond engine.Condition) {
+		if cond.Fact != "" {
+			cond.Fact = unconverted(c, cond.Fact)
+			if cond.Equals != nil {
+				add(cond.Fact, c.ResolveOperand(cond.Equals), "enginetest-other")
+			}
+			numeric(cond.Fact, c.ResolveOperand(cond.GreaterThan))
+			numeric(cond.Fact, c.ResolveOperand(cond.LessThan))
+			for _, v := range cond.In {
+				add(cond.Fact, c.ResolveOperand(v))
+			}
+			if cond.Contains != nil {
+				add(cond.Fact, []any{c.ResolveOperand(cond.Contains)}, []any{"enginetest-other"})
+			}
+			for _, v := range append([]any{cond.Before, cond.After}, cond.Between...) {
+				timestamp(cond.Fact, c.ResolveOperand(v))
+			}
+			for _, set := range [][]any{cond.SubsetOf, cond.Intersects, cond.ContainsAll} {
+				if len(set) > 0 {
+					list(cond.Fact, set)
+				}
+			}
+		}
+		if cond.HasRole != nil {
+			add(engine.FactRoles, []any{c.ResolveOperand(cond.HasRole)}, []any{"enginetest-other"})
+		}
+		if cond.HasScope != nil {
+			add(engine.FactScopes, []any{c.ResolveOperand(cond.HasScope)}, []any{"enginetest-other"})
+		}
+		if cond.SubjectMatches != nil {
+			add(engine.FactSubject, c.ResolveOperand(cond.SubjectMatches), "enginetest-other")
+		}
+		if cond.TenantEquals != nil {
+			add(engine.FactTenant, c.ResolveOperand(cond.TenantEquals), "enginetest-other")
+		}
+		for _, sub := range cond.All {
+			walk(sub)
+		}
+		for _, sub := range cond.Any {
+			walk(sub)
+		}
+		if cond.Not != nil {
+			walk(*cond.Not)
+		}
+	}
+	for _, r := range c.Rules {
+		walk(r.When)
+	}
+
+	for _, df := range c.DerivedFacts {
+		if df.Derivation.Fn == "convert" {
+			continue
+		}
+		var factArg string
+		for _, arg := range df.Derivation.Args {
+			switch {
+			case arg.Fact != "" && arg.Op != "":
+				add(arg.Fact, arg.Value, "enginetest-other")
+			case arg.Fact != "":
+				factArg = arg.Fact
+			case arg.Value != nil && factArg != "":
+				add(factArg, arg.Value)
+				numeric(factArg, arg.Value)
+			}
+		}
+	}
+	return pools
+}
+
+// operationPaths returns the sorted base-fact paths read by the rules that
+// constrain op, expanding derived facts into their arguments.
+func operationPaths(c *engine.Contract, op string) []string {
+	seen := map[string]bool{}
+	var add func(path string)
+	add = func(path string) {
+		if seen[path] {
+			return
+		}
+		seen[path] = true
+		if df, ok := c.DerivedFacts[path]; ok {
+			for _, arg := range df.Derivation.Args {
+				if arg.Fact != "" {
+					add(arg.Fact)
+				}
+			}
+		}
+	}
+	var walk func(cond engine.Condition)
+	walk = func(cond engine.Condition) {
+		if cond.Fact != "" {
+			add(unconverted(c, cond.Fact))
+		}
+		for fact, operand := range map[string]any{
+			engine.FactRoles:   cond.HasRole,
+			engine.FactScopes:  cond.HasScope,
+			engine.FactSubject: cond.SubjectMatches,
+			engine.FactTenant:  cond.TenantEquals,
+		} {
+			if operand != nil {
+				add(fact)
+			}
+		}
+		for _, sub := range cond.All {
+			walk(sub)
+		}
+		for _, sub := range cond.Any {
+			walk(sub)
+		}
+		if cond.Not != nil {
+			walk(*cond.Not)
+		}
+	}
+
+	constrained := map[string]bool{}
+	for _, id := range c.Operations[op].ConstrainedBy {
+		constrained[id] = true
+	}
+	for _, r := range c.Rules {
+		if constrained[r.ID] {
+			walk(r.When)
+		}
+	}
+	// Required input facts must always be present or evaluation errors out.
+	for name, def := range c.Facts {
+		if def.Source == "input" && def.Required {
+			seen[name] = true
+		}
+	}
+
+	var paths []string
+	for p := range seen {
+		if _, derived := c.DerivedFacts[p]; !derived {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// unconverted maps a path into a converted money fact onto the amount it
+// was converted from, e.g. "payment.amount_usd.value" →
+// "payment.amount.value", so cases vary the amount itself.
+func unconverted(c *engine.Contract, path string) string {
+	name, ok := strings.CutSuffix(path, ".value")
+	if df, isDerived := c.DerivedFacts[name]; ok && isDerived && df.Derivation.Fn == "convert" && len(df.Derivation.Args) > 0 {
+		return df.Derivation.Args[0].Fact + ".value"
+	}
+	return path
+}
+
+// setCurrencies gives the money facts an operation checks the first
+// currency it accepts, so that cases are not all refused for their
+// currency.
+func setCurrencies(c *engine.Contract, op string, cs Case) {
+	d := c.Operations[op].Currencies
+	if d == nil {
+		return
+	}
+	currency := "USD"
+	if len(d.Accepted) > 0 {
+		currency = d.Accepted[0]
+	}
+	for _, name := range d.Facts {
+		for _, target := range []map[string]any{cs.Input, cs.PortFacts} {
+			if m, ok := target[name].(map[string]any); ok {
+				m["currency"] = currency
+			}
+		}
+	}
+}
+
+// baseFact splits a dotted path into its declared base fact and the
+// remaining segments to navigate, e.g. "payment.amount.value" →
+// ("payment.amount", ["value"]). It returns "" if no base fact matches.
+func baseFact(c *engine.Contract, path string) (string, []string) {
+	if _, ok := c.Facts[path]; ok {
+		return path, nil
+	}
+	parts := strings.Split(path, ".")
+	for i := len(parts) - 1; i > 0; i-- {
+		prefix := strings.Join(parts[:i], ".")
+		if _, ok := c.Facts[prefix]; ok {
+			return prefix, parts[i:]
+		}
+	}
+	return "", nil
+}
+
+// setPath stores val under base, creating nested maps for rest.
+func setPath(target map[string]any, base string, rest []string, val any) {
+	if len(rest) == 0 {
+		if _, isMap := target[base].(map[string]any); !isMap {
+			target[base] = val
+		}
+		return
+	}
+	m, ok := target[base].(map[string]any)
+	if !ok {
+		m = map[string]any{}
+		target[base] = m
+	}
+	for _, part := range rest[:len(rest)-1] {
+		next, ok := m[part].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			m[part] = next
+		}
+		m = next
+	}
+	m[rest[len(rest)-1]] = val
+}
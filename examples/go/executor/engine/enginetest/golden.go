@@ -0,0 +1,136 @@
+package enginetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"covenant-poc/executor/engine"
+)
+
+// UpdateGoldenEnv names the environment variable that, set to 1, makes
+// Golden write golden files instead of comparing against them:
+//
+//	COVENANT_UPDATE_GOLDEN=1 go test ./...
+const UpdateGoldenEnv = "COVENANT_UPDATE_GOLDEN"
+
+// volatileIDs are response fields that differ on every evaluation. Normalize
+// replaces their values with a placeholder naming the field.
+var volatileIDs = map[string]bool{
+	"invocation_id": true,
+	"escalation_id": true,
+	"intent_id":     true,
+}
+
+// Golden compares resp with the golden file at path, such as
+// "testdata/pay_denied.golden.json", so a contract or engine change that
+// alters a response shows up as a test failure and a diff in review. Both
+// sides are normalized first; see Normalize. A missing golden file fails
+// the test. Set COVENANT_UPDATE_GOLDEN=1 to write the files instead, then
+// review and commit them.
+func Golden(tb testing.TB, path string, resp *engine.Response) {
+	tb.Helper()
+	got, err := Normalize(resp)
+	if err != nil {
+		tb.Fatalf("golden %s: %v", path, err)
+	}
+	if os.Getenv(UpdateGoldenEnv) == "1" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			tb.Fatalf("golden %s: %v", path, err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			tb.Fatalf("golden %s: %v", path, err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		tb.Fatalf("golden %s: %v (run with %s=1 to create it)", path, err, UpdateGoldenEnv)
+	}
+	if diff := lineDiff(want, got); diff != "" {
+		tb.Errorf("response differs from %s (run with %s=1 to update it):\n%s", path, UpdateGoldenEnv, diff)
+	}
+}
+
+// Normalize serializes resp deterministically, for golden files: indented
+// JSON with object keys sorted, invocation, escalation and intent IDs
+// replaced by "<invocation_id>" and so on, and every timestamp replaced by
+// "<time>". Anything else that changes is a change in behavior.
+func Normalize(resp *engine.Response) ([]byte, error) {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(normalize(v, "")); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func normalize(v any, key string) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, val := range v {
+			v[k] = normalize(val, k)
+		}
+		return v
+	case []any:
+		for i, val := range v {
+			v[i] = normalize(val, "")
+		}
+		return v
+	case string:
+		if v == "" {
+			return v
+		}
+		if volatileIDs[key] {
+			return "<" + key + ">"
+		}
+		if _, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return "<time>"
+		}
+	}
+	return v
+}
+
+// lineDiff lists the lines that differ between want and got, or returns ""
+// if they are equal.
+func lineDiff(want, got []byte) string {
+	if bytes.Equal(want, got) {
+		return ""
+	}
+	w := strings.Split(string(want), "\n")
+	g := strings.Split(string(got), "\n")
+	var b strings.Builder
+	shown := 0
+	for i := 0; i < max(len(w), len(g)) && shown < 20; i++ {
+		var wl, gl string
+		if i < len(w) {
+			wl = w[i]
+		}
+		if i < len(g) {
+			gl = g[i]
+		}
+		if wl == gl {
+			continue
+		}
+		fmt.Fprintf(&b, "line %d:\n  - %s\n  + %s\n", i+1, wl, gl)
+		shown++
+	}
+	return b.String()
+}
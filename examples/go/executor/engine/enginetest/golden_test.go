@@ -0,0 +1,70 @@
+package enginetest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"covenant-poc/executor/engine"
+)
+
+// recordingTB captures failures instead of failing the test.
+type recordingTB struct {
+	testing.TB
+	failed []string
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Errorf(format string, args ...any) {
+	r.failed = append(r.failed, fmt.Sprintf(format, args...))
+}
+
+func TestGolden_normalizesVolatileFields(t *testing.T) {
+	c := LoadDir(t, "../../../contracts/billing")
+	eval := func(balance float64) *engine.Response {
+		eng := engine.NewEngine(NewPorts(map[string]any{
+			"customer.status":          "active",
+			"invoice.balance":          map[string]any{"value": balance, "currency": "USD"},
+			"invoice.status":           "open",
+			"payment.processor.status": "up",
+		}))
+		eng.LoadContract(c, "golden")
+		resp, err := eng.Evaluate(context.Background(), &engine.Request{
+			Operation: "ProcessPayment",
+			Input: map[string]any{
+				"customer.id":    "cust_1",
+				"invoice.id":     "inv_1",
+				"payment.amount": map[string]any{"value": 50.0, "currency": "USD"},
+			},
+			Explain: true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	path := filepath.Join(t.TempDir(), "testdata", "pay.golden.json")
+	t.Setenv(UpdateGoldenEnv, "1")
+	Golden(t, path, eval(100))
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"invocation_id": "<invocation_id>"`) || !strings.Contains(string(data), `"fetched_at": "<time>"`) {
+		t.Errorf("expected IDs and timestamps normalized, got:\n%s", data)
+	}
+
+	t.Setenv(UpdateGoldenEnv, "")
+	Golden(t, path, eval(100)) // a new invocation ID and fetch times
+
+	rec := &recordingTB{TB: t}
+	Golden(rec, path, eval(10)) // now exceeds the balance
+	if len(rec.failed) != 1 || !strings.Contains(rec.failed[0], "line ") {
+		t.Errorf("expected a changed response reported with a diff, got %q", rec.failed)
+	}
+}
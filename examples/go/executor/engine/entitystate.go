@@ -0,0 +1,134 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Conditions and derived facts read an entity's tracked state as
+//
+//	{fact: "entity:invoice.state", equals: "approved"}
+//
+// The instance is the one whose ID the entity's key input fact carries, and
+// its state comes from the engine's StateStore. An instance the store does
+// not track is in the entity's initial state.
+const entityRefPrefix = "entity:"
+
+// entityStateRef returns the entity whose state path refers to.
+func entityStateRef(path string) (entity string, ok bool) {
+	ref, ok := strings.CutPrefix(path, entityRefPrefix)
+	if !ok {
+		return "", false
+	}
+	return strings.CutSuffix(ref, ".state")
+}
+
+// entityKey returns the ID of the entity instance a request is about.
+func entityKey(c *Contract, entity string, input map[string]any) (string, error) {
+	def, ok := c.Entities[entity]
+	if !ok || def.Key == "" {
+		return "", fmt.Errorf("entity %q has no key to look up its state by", entity)
+	}
+	id, ok := input[def.Key]
+	if !ok {
+		return "", fmt.Errorf("input fact %q, the key of entity %q, missing from request", def.Key, entity)
+	}
+	return fmt.Sprint(id), nil
+}
+
+// entityState returns the current state of an entity instance, or the
+// state an earlier step of the plan being validated would leave it in.
+func (e *Engine) entityState(ctx context.Context, c *Contract, entity, id string) (string, error) {
+	if planned, ok := ctx.Value(plannedStatesKey{}).(plannedStates); ok {
+		if state, ok := planned[entityInstance{entity, id}]; ok {
+			return state, nil
+		}
+	}
+	if e.states == nil {
+		return "", errors.New("no state store configured")
+	}
+	state, _, err := e.states.GetState(ctx, entity, id)
+	if errors.Is(err, ErrNotFound) {
+		return c.Entities[entity].Initial, nil
+	}
+	return state, err
+}
+
+// validateEntityRefs checks that every entity state a rule or derived fact
+// reads can be looked up: the entity is declared and keyed by an input
+// fact.
+func validateEntityRefs(c *Contract) []Diagnostic {
+	var diags []Diagnostic
+	check := func(rule, where, path string) {
+		report := func(format string, args ...any) {
+			diags = append(diags, Diagnostic{Severity: SeverityError, Rule: rule, Message: where + fmt.Sprintf(format, args...)})
+		}
+		entity, ok := entityStateRef(path)
+		if !ok {
+			if strings.HasPrefix(path, entityRefPrefix) {
+				report("%s is not an entity state reference (entity:<entity>.state)", path)
+			}
+			return
+		}
+		def, declared := c.Entities[entity]
+		switch {
+		case !declared:
+			report("%s reads undeclared entity %s", path, entity)
+		case def.Key == "":
+			report("%s reads entity %s, which declares no key", path, entity)
+		case c.Facts[def.Key].Source != "input":
+			report("entity %s is keyed by %s, which is not an input fact", entity, def.Key)
+		}
+	}
+	for _, r := range c.Rules {
+		collectFromCondition(r.When, func(path string) { check(r.ID, "", path) })
+	}
+	for _, name := range sortedDerivedFacts(c) {
+		for _, arg := range c.DerivedFacts[name].Derivation.Args {
+			check("", "derived fact "+name+": ", arg.Fact)
+		}
+	}
+	return diags
+}
+
+// MemoryStateStore is a StateStore in process memory, for a single executor
+// and for tests. It is safe for concurrent use.
+type MemoryStateStore struct {
+	mu        sync.Mutex
+	instances map[[2]string]memoryState // by entity and ID
+}
+
+type memoryState struct {
+	state   string
+	version int64
+}
+
+func NewMemoryStateStore() *MemoryStateStore {
+	return &MemoryStateStore{instances: map[[2]string]memoryState{}}
+}
+
+// GetState implements StateStore.
+func (s *MemoryStateStore) GetState(_ context.Context, entity, id string) (string, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.instances[[2]string{entity, id}]
+	if !ok {
+		return "", 0, ErrNotFound
+	}
+	return st.state, st.version, nil
+}
+
+// TransitionCAS implements StateStore.
+func (s *MemoryStateStore) TransitionCAS(_ context.Context, entity, id string, version int64, to string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := [2]string{entity, id}
+	if s.instances[key].version != version {
+		return 0, ErrStateConflict
+	}
+	s.instances[key] = memoryState{state: to, version: version + 1}
+	return version + 1, nil
+}
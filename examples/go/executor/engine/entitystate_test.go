@@ -0,0 +1,150 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// brokenStates is a StateStore that cannot be reached.
+type brokenStates struct{}
+
+func (brokenStates) GetState(context.Context, string, string) (string, int64, error) {
+	return "", 0, errors.New("connection refused")
+}
+
+func (brokenStates) TransitionCAS(context.Context, string, string, int64, string) (int64, error) {
+	return 0, errors.New("connection refused")
+}
+
+// entityStateContract denies testOp unless the invoice is approved, read
+// directly and through a derived fact.
+func entityStateContract() *Contract {
+	c := makeMinimalContract()
+	c.Facts["invoice.id"] = FactDef{Source: "input", Required: true}
+	c.Entities["invoice"] = EntityDef{Key: "invoice.id", States: []string{"draft", "approved"}, Initial: "draft"}
+	c.DerivedFacts["invoice.payable"] = DerivedFactDef{Derivation: Derivation{Fn: "equals", Args: []DerivationArg{{Fact: "entity:invoice.state"}, {Value: "approved"}}}}
+	c.Rules = []RuleDef{
+		{ID: "approved", When: Condition{Not: &Condition{Fact: "entity:invoice.state", Equals: "approved"}}, Verdict: VerdictDef{Deny: &DenyVerdict{Code: "NOT_APPROVED", Error: ErrorEnvelope{Code: "NOT_APPROVED", HttpStatus: 409, Suggestion: "Approve the invoice first"}}}},
+		{ID: "payable", When: Condition{Fact: "invoice.payable", Equals: true}, Verdict: VerdictDef{Flag: &FlagVerdict{Code: "PAYABLE"}}},
+	}
+	c.Operations["testOp"] = OperationDef{ConstrainedBy: []string{"approved", "payable"}}
+	return c
+}
+
+func TestEngine_Evaluate_readsEntityStateFromStateStore(t *testing.T) {
+	states := NewMemoryStateStore()
+	states.TransitionCAS(context.Background(), "invoice", "inv_1", 0, "approved")
+	states.TransitionCAS(context.Background(), "invoice", "inv_2", 0, "submitted")
+	e := NewEngine(&mockPorts{}, WithStateStore(states))
+	e.LoadContract(entityStateContract(), "v1")
+
+	for _, tc := range []struct {
+		id      string
+		outcome Outcome
+	}{
+		{"inv_1", "executed"},
+		{"inv_2", "denied"},
+		{"inv_new", "denied"}, // untracked: draft
+	} {
+		resp, err := e.Evaluate(context.Background(), &Request{Operation: "testOp", Input: map[string]any{"invoice.id": tc.id}, Explain: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Outcome != tc.outcome {
+			t.Errorf("%s: got %s, want %s", tc.id, resp.Outcome, tc.outcome)
+		}
+		if tc.outcome == "executed" && (len(resp.Verdicts) != 1 || resp.Verdicts[0].Code != "PAYABLE") {
+			t.Errorf("%s: expected the derived fact to read the state, got %+v", tc.id, resp.Verdicts)
+		}
+		if src := resp.Explain.Facts["entity:invoice.state"].Source; src != "state" {
+			t.Errorf("%s: expected the state store attributed in explain, got %q", tc.id, src)
+		}
+	}
+
+	e = NewEngine(&mockPorts{}, WithStateStore(brokenStates{}))
+	e.LoadContract(entityStateContract(), "v1")
+	resp, _ := e.Evaluate(context.Background(), &Request{Operation: "testOp", Input: map[string]any{"invoice.id": "inv_1"}})
+	if resp.Outcome != "system_error" || resp.Error.Code != "FACT_UNAVAILABLE" {
+		t.Errorf("expected a failing store to be a system error, got %+v", resp)
+	}
+}
+
+func TestEngine_Evaluate_entityStateNeedsAStore(t *testing.T) {
+	e := NewEngine(&mockPorts{})
+	e.LoadContract(entityStateContract(), "v1")
+	resp, err := e.Evaluate(context.Background(), &Request{Operation: "testOp", Input: map[string]any{"invoice.id": "inv_1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Outcome != "system_error" || !strings.Contains(resp.Error.Message, "no state store") {
+		t.Errorf("expected a system error without a state store, got %+v", resp)
+	}
+}
+
+func TestNeededFacts_includesEntityStateAndItsKey(t *testing.T) {
+	got := strings.Join(NeededFacts(entityStateContract(), "testOp"), ",")
+	if got != "entity:invoice.state,invoice.id" {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestValidate_entityStateReferences(t *testing.T) {
+	c := entityStateContract()
+	c.Rules = append(c.Rules,
+		RuleDef{ID: "order", When: Condition{Fact: "entity:order.state", Equals: "open"}, Verdict: VerdictDef{Flag: &FlagVerdict{Code: "F"}}},
+		RuleDef{ID: "typo", When: Condition{Fact: "entity:invoice.status", Equals: "open"}, Verdict: VerdictDef{Flag: &FlagVerdict{Code: "F"}}},
+	)
+	c.Entities["refund"] = EntityDef{Key: "refund.id", States: []string{"open"}, Initial: "open"}
+	c.DerivedFacts["refund.open"] = DerivedFactDef{Derivation: Derivation{Fn: "equals", Args: []DerivationArg{{Fact: "entity:refund.state"}, {Value: "open"}}}}
+
+	var got []string
+	for _, d := range Validate(c, time.Now()) {
+		got = append(got, d.String())
+	}
+	want := []string{
+		"error: rule order: entity:order.state reads undeclared entity order",
+		"error: rule typo: entity:invoice.status is not an entity state reference (entity:<entity>.state)",
+		"error: derived fact refund.open: entity refund is keyed by refund.id, which is not an input fact",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s", strings.Join(got, "\n"))
+	}
+}
+
+func TestMemoryStateStore_TransitionCAS(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStateStore()
+	if _, _, err := s.GetState(ctx, "invoice", "inv_1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected an untracked instance not found, got %v", err)
+	}
+	if v, err := s.TransitionCAS(ctx, "invoice", "inv_1", 0, "submitted"); err != nil || v != 1 {
+		t.Fatalf("got %d, %v", v, err)
+	}
+	if _, err := s.TransitionCAS(ctx, "invoice", "inv_1", 0, "cancelled"); !errors.Is(err, ErrStateConflict) {
+		t.Errorf("expected a stale version refused, got %v", err)
+	}
+	if state, v, err := s.GetState(ctx, "invoice", "inv_1"); state != "submitted" || v != 1 || err != nil {
+		t.Errorf("got %s@%d, %v", state, v, err)
+	}
+
+	var wg sync.WaitGroup
+	var won atomic.Int32
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.TransitionCAS(ctx, "invoice", "inv_1", 1, "approved"); err == nil {
+				won.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if won.Load() != 1 {
+		t.Errorf("expected exactly one of the racing transitions to succeed, got %d", won.Load())
+	}
+}
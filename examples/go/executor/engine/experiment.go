@@ -0,0 +1,122 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// ExperimentDef compares parameterizations of the contract's rules on live
+// traffic, e.g.
+//
+//	experiments: "payment-limit": {key: "customer.id", arms: {
+//		control:   {weight: 50, params: {large_payment_threshold: 10000}},
+//		treatment: {weight: 50, params: {large_payment_threshold: 15000}}}}
+//
+// Each request is assigned an arm by a hash of the experiment name and the
+// value of the key input fact, so a customer stays in one arm, and is
+// evaluated with that arm's param values in place of the contract's. The
+// audit record's Experiments names the arm of each experiment whose params
+// the operation reads. Requests without the key fact take the contract's
+// values and are not in the experiment.
+type ExperimentDef struct {
+	Key  string            `json:"key"`
+	Arms map[string]ArmDef `json:"arms"`
+}
+
+// ArmDef is one parameterization of an experiment. Arms are assigned in
+// proportion to their weights.
+type ArmDef struct {
+	Weight float64        `json:"weight"`
+	Params map[string]any `json:"params"`
+}
+
+// experimentArms returns the arm of each experiment the request is in,
+// among those setting a param in needed, and the param values the arms
+// set.
+func experimentArms(c *Contract, input map[string]any, needed map[string]bool) (arms map[string]string, params map[string]any) {
+	for _, name := range sortedExperiments(c) {
+		exp := c.Experiments[name]
+		reads := false
+		for _, arm := range exp.Arms {
+			for param := range arm.Params {
+				reads = reads || needed[paramFactPrefix+param]
+			}
+		}
+		v, ok := input[exp.Key]
+		if !reads || !ok || v == nil {
+			continue
+		}
+		arm := assignArm(name, exp, v)
+		if arms == nil {
+			arms, params = map[string]string{}, map[string]any{}
+		}
+		arms[name] = arm
+		for param, val := range exp.Arms[arm].Params {
+			params[param] = val
+		}
+	}
+	return arms, params
+}
+
+// assignArm picks the arm of exp for the key value v.
+func assignArm(name string, exp ExperimentDef, v any) string {
+	names := slices.Sorted(maps.Keys(exp.Arms))
+	var total float64
+	for _, def := range exp.Arms {
+		total += def.Weight
+	}
+	sum := sha256.Sum256([]byte(name + "\x00" + fmt.Sprint(v)))
+	point := float64(binary.BigEndian.Uint64(sum[:8])%10000) / 10000 * total
+	for _, arm := range names {
+		if point < exp.Arms[arm].Weight {
+			return arm
+		}
+		point -= exp.Arms[arm].Weight
+	}
+	return names[len(names)-1]
+}
+
+func sortedExperiments(c *Contract) []string {
+	return slices.Sorted(maps.Keys(c.Experiments))
+}
+
+// validateExperiments checks that each experiment is keyed by an input
+// fact and has at least two weighted arms setting declared params, and that
+// no param is set by two experiments.
+func validateExperiments(c *Contract) []Diagnostic {
+	var diags []Diagnostic
+	setBy := map[string]string{}
+	for _, name := range sortedExperiments(c) {
+		exp := c.Experiments[name]
+		report := func(format string, args ...any) {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("experiment %s: ", name) + fmt.Sprintf(format, args...),
+			})
+		}
+		if def, ok := c.Facts[exp.Key]; !ok || def.Source != "input" {
+			report("key %q is not an input fact", exp.Key)
+		}
+		if len(exp.Arms) < 2 {
+			report("has %d arms, want at least 2", len(exp.Arms))
+		}
+		for _, arm := range slices.Sorted(maps.Keys(exp.Arms)) {
+			def := exp.Arms[arm]
+			if def.Weight <= 0 {
+				report("arm %s: weight %v is not positive", arm, def.Weight)
+			}
+			for _, param := range slices.Sorted(maps.Keys(def.Params)) {
+				if _, ok := c.Params[param]; !ok {
+					report("arm %s: param %q is not declared", arm, param)
+				} else if other, ok := setBy[param]; ok && other != name {
+					report("arm %s: param %q is also set by experiment %s", arm, param, other)
+				}
+				setBy[param] = name
+			}
+		}
+	}
+	return diags
+}
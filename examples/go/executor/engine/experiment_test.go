@@ -0,0 +1,73 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func experimentContract() *Contract {
+	c := makeParamContract()
+	c.Facts["customer.id"] = FactDef{Source: "input"}
+	c.Experiments = map[string]ExperimentDef{
+		"limit-test": {Key: "customer.id", Arms: map[string]ArmDef{
+			"control":   {Weight: 1, Params: map[string]any{"limit": 100.0}},
+			"treatment": {Weight: 1, Params: map[string]any{"limit": 200.0}},
+		}},
+	}
+	return c
+}
+
+func TestEngine_experimentsEvaluateArmsWithTheirParams(t *testing.T) {
+	sink := &recordingSink{}
+	e := NewEngine(&mockPorts{}, WithAuditSink(sink), WithDecisionCache(100))
+	e.LoadContract(experimentContract(), "v1")
+	ctx := context.Background()
+
+	arms := map[string]int{}
+	for i := range 200 {
+		customer := fmt.Sprintf("cust_%d", i)
+		resp, err := e.Evaluate(ctx, &Request{Operation: "testOp", DryRun: true, Input: map[string]any{"customer.id": customer, "amount": 150.0}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		arm := sink.recs[len(sink.recs)-1].Experiments["limit-test"]
+		arms[arm]++
+		flagged := resp.Outcome == OutcomeWouldExecuteWithFlags
+		if flagged != (arm == "control") {
+			t.Fatalf("%s in arm %q: flagged %v", customer, arm, flagged)
+		}
+		// A customer stays in their arm.
+		e.Evaluate(ctx, &Request{Operation: "testOp", DryRun: true, Input: map[string]any{"customer.id": customer, "amount": 150.0}})
+		if again := sink.recs[len(sink.recs)-1].Experiments["limit-test"]; again != arm {
+			t.Fatalf("%s moved from %s to %s", customer, arm, again)
+		}
+	}
+	if arms["control"] < 70 || arms["treatment"] < 70 {
+		t.Errorf("expected the arms evenly weighted, got %v", arms)
+	}
+
+	// A request without the key is not in the experiment.
+	e.Evaluate(ctx, &Request{Operation: "testOp", DryRun: true, Input: map[string]any{"amount": 150.0}})
+	if rec := sink.recs[len(sink.recs)-1]; rec.Experiments != nil {
+		t.Errorf("expected no experiment arms, got %v", rec.Experiments)
+	}
+}
+
+func TestValidate_reportsMalformedExperiments(t *testing.T) {
+	c := experimentContract()
+	c.Experiments["limit-test"] = ExperimentDef{Key: "account.id", Arms: map[string]ArmDef{
+		"only": {Weight: 0, Params: map[string]any{"ceiling": 1.0}},
+	}}
+	var got []string
+	for _, d := range Validate(c, time.Now()) {
+		if strings.Contains(d.Message, "experiment") {
+			got = append(got, d.Message)
+		}
+	}
+	if len(got) != 4 {
+		t.Errorf("expected 4 diagnostics, got %q", got)
+	}
+}
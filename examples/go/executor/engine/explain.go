@@ -0,0 +1,213 @@
+package engine
+
+import (
+	"strings"
+	"time"
+)
+
+// Explanation describes how each rule constraining an operation was evaluated.
+// It is returned when a request sets explain.
+type Explanation struct {
+	Rules []RuleTrace `json:"rules"`
+
+	// Environment and Params echo the parameter values the rules were
+	// evaluated with, for the params those rules reference.
+	Environment string                `json:"environment,omitempty"`
+	Params      map[string]ParamTrace `json:"params,omitempty"`
+
+	// Facts records where each port fact came from, its port or the fact
+	// cache and what warmed it, and which derived facts were served from
+	// the cache.
+	Facts map[string]FactTrace `json:"facts,omitempty"`
+
+	// Authorize traces the operation's authorize condition.
+	Authorize *ConditionTrace `json:"authorize,omitempty"`
+}
+
+// ParamTrace is the resolved value of one param and where it came from:
+// "binding", "default", or "supplied" for simulations.
+type ParamTrace struct {
+	Value  any    `json:"value"`
+	Source string `json:"source"`
+}
+
+// RuleTrace is the evaluation record of a single rule.
+type RuleTrace struct {
+	ID      string         `json:"id"`
+	Matched bool           `json:"matched"`
+	Verdict string         `json:"verdict"` // deny, escalate, require, flag
+	When    ConditionTrace `json:"when"`
+
+	// Inactive is set when the rule was outside its effective_from /
+	// expires_at window; its condition is still traced but cannot match.
+	Inactive bool `json:"inactive,omitempty"`
+	// Shadow is set when the rule's condition held but the request was
+	// outside its rollout, so its verdict was recorded as a shadow verdict
+	// rather than applied; such a rule is not Matched.
+	Shadow bool `json:"shadow,omitempty"`
+}
+
+// ConditionTrace mirrors a Condition tree with the result of each node.
+// Leaf nodes carry the operator, the expected operand and the actual fact value.
+type ConditionTrace struct {
+	Kind     string           `json:"kind"` // all, any, not, fact, empty
+	Passed   bool             `json:"passed"`
+	Fact     string           `json:"fact,omitempty"`
+	Op       string           `json:"op,omitempty"`
+	Expected any              `json:"expected,omitempty"`
+	Param    string           `json:"param,omitempty"` // param Expected was resolved from
+	Actual   any              `json:"actual,omitempty"`
+	Present  bool             `json:"present,omitempty"`
+	Children []ConditionTrace `json:"children,omitempty"`
+}
+
+// explain traces every rule constraining operation against the fact set as
+// of the given instant.
+func explain(c *Contract, operation string, facts *FactSet, at time.Time) *Explanation {
+	op := c.Operations[operation]
+	ruleSet := map[string]bool{}
+	for _, id := range op.ConstrainedBy {
+		ruleSet[id] = true
+	}
+
+	facts.evaluateAs(c, at)
+	needed := neededBaseFacts(c, operation)
+	ex := &Explanation{Rules: []RuleTrace{}}
+	for _, rule := range c.Rules {
+		if !ruleSet[rule.ID] {
+			continue
+		}
+		when := traceCondition(rule.When, facts)
+		active := rule.ActiveAt(at)
+		live := inRollout(&rule, facts)
+		ex.Rules = append(ex.Rules, RuleTrace{
+			ID:       rule.ID,
+			Matched:  active && when.Passed && live,
+			Verdict:  verdictType(rule.Verdict),
+			When:     when,
+			Inactive: !active,
+			Shadow:   active && when.Passed && !live,
+		})
+	}
+
+	for name, def := range c.Facts {
+		if def.Source != "param" || !needed[name] {
+			continue
+		}
+		param := strings.TrimPrefix(name, paramFactPrefix)
+		val, _ := facts.Get(name)
+		source := "default"
+		if _, ok := c.Bindings[param]; ok {
+			source = "binding"
+		}
+		if ex.Params == nil {
+			ex.Params = map[string]ParamTrace{}
+		}
+		ex.Params[param] = ParamTrace{Value: val, Source: source}
+	}
+	if ex.Params != nil {
+		ex.Environment = c.Environment
+	}
+	ex.Facts = facts.Traces()
+	return ex
+}
+
+// traceCondition evaluates cond exactly as evalCondition does, recording the
+// outcome of every node.
+func traceCondition(cond Condition, facts *FactSet) ConditionTrace {
+	switch {
+	case len(cond.All) > 0:
+		t := ConditionTrace{Kind: "all", Passed: true}
+		for _, sub := range cond.All {
+			st := traceCondition(sub, facts)
+			t.Passed = t.Passed && st.Passed
+			t.Children = append(t.Children, st)
+		}
+		return t
+
+	case len(cond.Any) > 0:
+		t := ConditionTrace{Kind: "any"}
+		for _, sub := range cond.Any {
+			st := traceCondition(sub, facts)
+			t.Passed = t.Passed || st.Passed
+			t.Children = append(t.Children, st)
+		}
+		return t
+
+	case cond.Not != nil:
+		st := traceCondition(*cond.Not, facts)
+		return ConditionTrace{Kind: "not", Passed: !st.Passed, Children: []ConditionTrace{st}}
+
+	case cond.isIdentity():
+		op, fact, operand := cond.identityOp()
+		val, present := facts.Get(fact)
+		t := ConditionTrace{Kind: "fact", Fact: fact, Op: op, Expected: operand, Actual: val, Present: present}
+		if name, ok := paramRef(operand); ok {
+			t.Param, t.Expected = name, resolveOperand(operand, facts)
+		}
+		t.Passed = evalCondition(cond, facts)
+		return t
+
+	case cond.Fact != "":
+		val, present := facts.GetPath(cond.Fact)
+		t := ConditionTrace{Kind: "fact", Fact: cond.Fact, Actual: val, Present: present}
+		switch {
+		case cond.Equals != nil:
+			t.Op, t.Expected = "equals", cond.Equals
+		case cond.GreaterThan != nil:
+			t.Op, t.Expected = "greater_than", cond.GreaterThan
+		case cond.LessThan != nil:
+			t.Op, t.Expected = "less_than", cond.LessThan
+		case len(cond.In) > 0:
+			in := make([]any, len(cond.In))
+			for i, v := range cond.In {
+				in[i] = resolveOperand(v, facts)
+			}
+			t.Op, t.Expected = "in", in
+		case cond.Contains != nil:
+			t.Op, t.Expected = "contains", cond.Contains
+		case cond.Before != nil:
+			t.Op, t.Expected = "before", cond.Before
+		case cond.After != nil:
+			t.Op, t.Expected = "after", cond.After
+		case len(cond.Between) > 0:
+			between := make([]any, len(cond.Between))
+			for i, v := range cond.Between {
+				between[i] = resolveOperand(v, facts)
+			}
+			t.Op, t.Expected = "between", between
+		case cond.isSet():
+			op, operands := "subset_of", cond.SubsetOf
+			if len(cond.Intersects) > 0 {
+				op, operands = "intersects", cond.Intersects
+			} else if len(cond.ContainsAll) > 0 {
+				op, operands = "contains_all", cond.ContainsAll
+			}
+			set := make([]any, len(operands))
+			for i, v := range operands {
+				set[i] = resolveOperand(v, facts)
+			}
+			t.Op, t.Expected = op, set
+		}
+		if name, ok := paramRef(t.Expected); ok {
+			t.Param, t.Expected = name, resolveOperand(t.Expected, facts)
+		}
+		t.Passed = evalCondition(cond, facts)
+		return t
+	}
+	return ConditionTrace{Kind: "empty", Passed: true}
+}
+
+func verdictType(v VerdictDef) string {
+	switch {
+	case v.Deny != nil:
+		return "deny"
+	case v.Escalate != nil:
+		return "escalate"
+	case v.Require != nil:
+		return "require"
+	case v.Flag != nil:
+		return "flag"
+	}
+	return ""
+}
@@ -0,0 +1,88 @@
+package engine
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTraceCondition_agreesWithEvalCondition(t *testing.T) {
+	fs := NewFactSet()
+	fs.Set("status", "active")
+	fs.Set("amount", map[string]any{"value": 250.0})
+
+	conds := []Condition{
+		{Fact: "status", Equals: "active"},
+		{Fact: "status", In: []any{"closed", "suspended"}},
+		{Fact: "amount.value", GreaterThan: 100.0},
+		{Fact: "missing", Equals: "x"},
+		{All: []Condition{{Fact: "status", Equals: "active"}, {Fact: "amount.value", LessThan: 100.0}}},
+		{Any: []Condition{{Fact: "status", Equals: "closed"}, {Fact: "amount.value", LessThan: 300.0}}},
+		{Not: &Condition{Fact: "status", Equals: "active"}},
+		{},
+	}
+	for i, c := range conds {
+		if got, want := traceCondition(c, fs).Passed, evalCondition(c, fs); got != want {
+			t.Errorf("condition %d: trace=%v eval=%v", i, got, want)
+		}
+	}
+}
+
+func TestTraceCondition_recordsLeafDetails(t *testing.T) {
+	fs := NewFactSet()
+	fs.Set("amount", map[string]any{"value": 50.0})
+
+	tr := traceCondition(Condition{All: []Condition{
+		{Fact: "amount.value", GreaterThan: 100.0},
+		{Fact: "absent", Equals: "x"},
+	}}, fs)
+
+	if tr.Kind != "all" || tr.Passed || len(tr.Children) != 2 {
+		t.Fatalf("unexpected trace: %+v", tr)
+	}
+	leaf := tr.Children[0]
+	if leaf.Op != "greater_than" || leaf.Expected != 100.0 || leaf.Actual != 50.0 || !leaf.Present || leaf.Passed {
+		t.Fatalf("unexpected leaf trace: %+v", leaf)
+	}
+	if tr.Children[1].Present {
+		t.Fatalf("expected absent fact to be marked not present: %+v", tr.Children[1])
+	}
+}
+
+func TestEngine_Evaluate_explainTracesEveryConstrainingRule(t *testing.T) {
+	eng := NewEngine(&mockPorts{})
+	contract := makeSimpleContract("r1",
+		VerdictDef{Flag: &FlagVerdict{Code: "F"}},
+		Condition{Fact: "customer.status", Equals: "blocked"},
+	)
+	eng.LoadContract(contract, "etag-1")
+
+	resp, err := eng.Evaluate(context.Background(), &Request{
+		Operation: "testOp",
+		Input:     map[string]any{"customer.status": "active"},
+		DryRun:    true,
+		Explain:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Explain == nil || len(resp.Explain.Rules) != 1 {
+		t.Fatalf("expected one rule trace, got %+v", resp.Explain)
+	}
+	rt := resp.Explain.Rules[0]
+	if rt.ID != "r1" || rt.Matched || rt.Verdict != "flag" || rt.When.Actual != "active" {
+		t.Fatalf("unexpected rule trace: %+v", rt)
+	}
+}
+
+func TestEngine_Evaluate_noExplainByDefault(t *testing.T) {
+	eng := NewEngine(&mockPorts{})
+	eng.LoadContract(makeMinimalContract(), "etag-1")
+
+	resp, err := eng.Evaluate(context.Background(), &Request{Operation: "testOp", DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Explain != nil {
+		t.Fatalf("expected no explanation unless requested, got %+v", resp.Explain)
+	}
+}
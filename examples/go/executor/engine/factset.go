@@ -3,14 +3,23 @@ package engine
 import (
 	"strings"
 	"sync"
+	"time"
 )
 
 // FactSet is a thread-safe store of named facts gathered during evaluation.
 // Fact names are dotted strings like "customer.status" or "payment.amount".
 // Facts may be scalars or nested maps (e.g. payment.amount is {"value":500,"currency":"USD"}).
 type FactSet struct {
-	mu    sync.RWMutex
-	facts map[string]any
+	mu     sync.RWMutex
+	facts  map[string]any
+	traces map[string]FactTrace
+	units  map[string]string // declared units of facts, by name
+	// now and loc are the instant and timezone timestamps are compared in,
+	// and compare is the contract's default string comparison; see
+	// evaluateAs.
+	now     time.Time
+	loc     *time.Location
+	compare *StringCompare
 }
 
 func NewFactSet() *FactSet {
@@ -24,6 +33,84 @@ func (f *FactSet) Set(name string, val any) {
 	f.facts[name] = val
 }
 
+// SetTraced stores a port fact value along with where it came from.
+func (f *FactSet) SetTraced(name string, val any, trace FactTrace) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.facts[name] = val
+	if f.traces == nil {
+		f.traces = map[string]FactTrace{}
+	}
+	f.traces[name] = trace
+}
+
+// Traces returns a copy of the recorded fact provenance.
+func (f *FactSet) Traces() map[string]FactTrace {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if len(f.traces) == 0 {
+		return nil
+	}
+	out := make(map[string]FactTrace, len(f.traces))
+	for k, v := range f.traces {
+		out[k] = v
+	}
+	return out
+}
+
+// setUnits records the units c declares for its facts.
+func (f *FactSet) setUnits(c *Contract) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for name, def := range c.Facts {
+		if def.Unit != "" {
+			if f.units == nil {
+				f.units = map[string]string{}
+			}
+			f.units[name] = def.Unit
+		}
+	}
+}
+
+// unit returns the declared unit of the fact name, or "".
+func (f *FactSet) unit(name string) string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.units[name]
+}
+
+// evaluateAs sets what conditions read besides facts: the instant "now"
+// means in timestamp comparisons, and c's timezone and default string
+// comparison.
+func (f *FactSet) evaluateAs(c *Contract, now time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now, f.loc, f.compare = now, c.location(), c.StringCompare
+}
+
+// clock returns the instant and timezone set by evaluateAs, or the current
+// time in UTC.
+func (f *FactSet) clock() (time.Time, *time.Location) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	now, loc := f.now, f.loc
+	if now.IsZero() {
+		now = time.Now()
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	return now, loc
+}
+
+// stringMode returns how cond compares strings under the default set by
+// evaluateAs.
+func (f *FactSet) stringMode(cond Condition) stringMode {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return stringModeFor(f.compare, cond.Compare)
+}
+
 // Get returns a fact value by exact name, and whether it was found.
 func (f *FactSet) Get(name string) (any, bool) {
 	f.mu.RLock()
@@ -41,9 +128,13 @@ func (f *FactSet) Get(name string) (any, bool) {
 func (f *FactSet) GetPath(path string) (any, bool) {
 	f.mu.RLock()
 	defer f.mu.RUnlock()
+	return getPath(f.facts, path)
+}
 
+// getPath resolves a dotted path against facts as GetPath does.
+func getPath(facts map[string]any, path string) (any, bool) {
 	// Try exact match first.
-	if v, ok := f.facts[path]; ok {
+	if v, ok := facts[path]; ok {
 		return v, true
 	}
 
@@ -51,7 +142,7 @@ func (f *FactSet) GetPath(path string) (any, bool) {
 	parts := strings.Split(path, ".")
 	for i := len(parts) - 1; i > 0; i-- {
 		prefix := strings.Join(parts[:i], ".")
-		if v, ok := f.facts[prefix]; ok {
+		if v, ok := facts[prefix]; ok {
 			result, ok := navigatePath(v, parts[i:])
 			return result, ok
 		}
@@ -0,0 +1,131 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Staleness policies for FactDef.OnStale.
+const (
+	onStaleRefresh = "refresh"
+	onStaleDeny    = "deny"
+	onStaleFlag    = "flag"
+)
+
+// Timestamped is a fact value that says how old it is. A port returns one
+// from Get when its data may lag, such as a balance read from a nightly
+// replica, so the engine can hold the value to the fact's max_staleness.
+type Timestamped struct {
+	Value any
+	AsOf  time.Time
+}
+
+// unwrapTimestamped returns val's plain value, recording its as-of time in
+// trace if the port supplied one.
+func unwrapTimestamped(val any, trace *FactTrace) any {
+	ts, ok := val.(Timestamped)
+	if !ok {
+		return val
+	}
+	asOf := ts.AsOf.UTC()
+	trace.AsOf = &asOf
+	return ts.Value
+}
+
+// maxStaleness returns the fact's staleness bound, or 0 if it has none.
+func (d FactDef) maxStaleness() time.Duration {
+	max, err := time.ParseDuration(d.MaxStaleness)
+	if err != nil || max < 0 {
+		return 0
+	}
+	return max
+}
+
+// asOf returns when the traced value was last known true: the time its port
+// reported, or else when it was fetched.
+func (t FactTrace) asOf() time.Time {
+	if t.AsOf != nil {
+		return *t.AsOf
+	}
+	return t.FetchedAt
+}
+
+// stale reports whether the traced value is older at now than def allows.
+func stale(def FactDef, trace FactTrace, now time.Time) bool {
+	max := def.maxStaleness()
+	return max > 0 && now.Sub(trace.asOf()) > max
+}
+
+// staleError refuses a request whose fact is too old to decide on.
+func staleError(name string, def FactDef, trace FactTrace, now time.Time) *factError {
+	return &factError{
+		fact:    name,
+		reason:  fmt.Sprintf("value is %s old, more than its max_staleness of %s", now.Sub(trace.asOf()).Round(time.Second), def.MaxStaleness),
+		outcome: OutcomeDenied,
+		stale:   true,
+	}
+}
+
+// staleFlags returns a STALE_FACT flag for each gathered fact that is older
+// than its max_staleness and whose policy is to flag rather than refuse.
+func staleFlags(c *Contract, facts *FactSet, now time.Time) []Verdict {
+	traces := facts.Traces()
+	names := make([]string, 0, len(traces))
+	for name := range traces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var flags []Verdict
+	for _, name := range names {
+		def, ok := c.Facts[name]
+		if !ok || def.OnStale != onStaleFlag || !stale(def, traces[name], now) {
+			continue
+		}
+		flags = append(flags, Verdict{
+			Type:   "flag",
+			Code:   "STALE_FACT",
+			Reason: fmt.Sprintf("fact %q is %s old, more than its max_staleness of %s", name, now.Sub(traces[name].asOf()).Round(time.Second), def.MaxStaleness),
+		})
+	}
+	return flags
+}
+
+// validateFreshness checks a fact's staleness declaration.
+func validateFreshness(name string, def FactDef) []Diagnostic {
+	var diags []Diagnostic
+	if def.MaxStaleness != "" {
+		if max, err := time.ParseDuration(def.MaxStaleness); err != nil || max <= 0 {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("fact %s: max_staleness %q is not a positive duration", name, def.MaxStaleness),
+			})
+		} else if !strings.HasPrefix(def.Source, "port:") {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("fact %s: max_staleness has no effect on %s facts", name, def.Source),
+			})
+		}
+	}
+	switch def.OnStale {
+	case "", onStaleRefresh, onStaleDeny, onStaleFlag:
+	default:
+		diags = append(diags, Diagnostic{
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("fact %s: on_stale %q must be refresh, deny or flag", name, def.OnStale),
+		})
+	}
+	return diags
+}
+
+// sortedFacts returns the contract's fact names in order.
+func sortedFacts(c *Contract) []string {
+	names := make([]string, 0, len(c.Facts))
+	for name := range c.Facts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,97 @@
+package engine
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// freshnessContract is intentContract with balance allowed to be at most
+// an hour old.
+func freshnessContract(onStale string) *Contract {
+	c := intentContract()
+	c.Facts["balance"] = FactDef{Source: "port:accounts", MaxStaleness: "1h", OnStale: onStale}
+	return c
+}
+
+func TestEngine_staleCachedFactIsRefreshed(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	var gets atomic.Int32
+	ports := &mockPorts{getFunc: func(context.Context, string, string, map[string]any) (any, error) {
+		gets.Add(1)
+		return Timestamped{Value: 5.0, AsOf: now.Add(-59 * time.Minute)}, nil
+	}}
+	e := NewEngine(ports, WithClock(func() time.Time { return now }))
+	e.LoadContract(freshnessContract(""), "v1")
+	input := map[string]any{"customer.id": "cust_1"}
+
+	if _, err := e.Prefetch(context.Background(), &Intent{Operation: "testOp", Input: input, TTLSeconds: 300}); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(2 * time.Minute)
+	resp, err := e.Evaluate(context.Background(), &Request{Operation: "testOp", Input: input, Explain: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trace := resp.Explain.Facts["balance"]
+	if gets.Load() != 2 || resp.Outcome != "executed" || trace.Source != "port" || !trace.AsOf.Equal(now.Add(-59*time.Minute)) {
+		t.Errorf("expected the stale cached balance refetched, got %d gets, %s, %+v", gets.Load(), resp.Outcome, trace)
+	}
+}
+
+func TestEngine_staleFactIsDenied(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	ports := &mockPorts{getFunc: func(context.Context, string, string, map[string]any) (any, error) {
+		return Timestamped{Value: 5.0, AsOf: now.Add(-26 * time.Hour)}, nil
+	}}
+	for _, onStale := range []string{"", "deny"} {
+		e := NewEngine(ports, WithClock(func() time.Time { return now }))
+		e.LoadContract(freshnessContract(onStale), "v1")
+		resp, err := e.Evaluate(context.Background(), &Request{Operation: "testOp", Input: map[string]any{"customer.id": "cust_1"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Outcome != "denied" || resp.Error == nil || resp.Error.Code != "FACT_STALE" {
+			t.Fatalf("on_stale %q: expected a FACT_STALE denial, got %+v", onStale, resp)
+		}
+		if want := `fact "balance" is stale: value is 26h0m0s old, more than its max_staleness of 1h`; resp.Error.Message != want {
+			t.Errorf("unexpected message %q", resp.Error.Message)
+		}
+	}
+}
+
+func TestEngine_staleFactIsFlagged(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	ports := &mockPorts{getFunc: func(context.Context, string, string, map[string]any) (any, error) {
+		return Timestamped{Value: 50.0, AsOf: now.Add(-2 * time.Hour)}, nil
+	}}
+	e := NewEngine(ports, WithClock(func() time.Time { return now }))
+	e.LoadContract(freshnessContract("flag"), "v1")
+	resp, err := e.Evaluate(context.Background(), &Request{Operation: "testOp", Input: map[string]any{"customer.id": "cust_1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Outcome != "executed" || len(resp.Verdicts) != 1 || resp.Verdicts[0].Code != "STALE_FACT" {
+		t.Fatalf("expected a STALE_FACT flag, got %+v", resp)
+	}
+}
+
+func TestValidate_freshnessDeclarations(t *testing.T) {
+	c := freshnessContract("ignore")
+	c.Facts["customer.id"] = FactDef{Source: "input", MaxStaleness: "1h"}
+	c.Facts["rate"] = FactDef{Source: "port:fx", MaxStaleness: "daily"}
+	var got []string
+	for _, d := range Validate(c, time.Now()) {
+		got = append(got, d.String())
+	}
+	want := []string{
+		`error: fact balance: on_stale "ignore" must be refresh, deny or flag`,
+		`warning: fact customer.id: max_staleness has no effect on input facts`,
+		`error: fact rate: max_staleness "daily" is not a positive duration`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected diagnostics:\n%s", strings.Join(got, "\n"))
+	}
+}
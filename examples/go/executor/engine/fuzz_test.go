@@ -0,0 +1,161 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+// Fuzz targets. Run one with e.g.:
+//
+//	go test ./executor/engine -run=^$ -fuzz=FuzzCompileContract -fuzztime=30s
+//
+// Without -fuzz, the seed corpus runs as part of the normal test suite.
+
+const fuzzSeedContract = `
+facts: {
+	"customer.status": {source: "port:customerRepo", on_missing: "deny"}
+	"payment.amount":  {source: "input"}
+}
+derived_facts: {
+	"payment.large": {derivation: {fn: "greater_than", args: [{fact: "payment.amount.value"}, {value: 100}]}}
+}
+rules: [{
+	id: "r1"
+	applies_to: ["Pay"]
+	when: {all: [{fact: "customer.status", equals: "closed"}, {not: {fact: "payment.large", equals: true}}]}
+	verdict: deny: {code: "CLOSED", reason: "closed", error: {code: "CLOSED", http_status: 422}}
+}]
+operations: {
+	"Pay": {constrained_by: ["r1"], transitions: [{entity: "invoice", from: "approved", to: "paid"}]}
+}
+entities: {
+	"invoice": {states: ["approved", "paid"], initial: "approved", terminal: ["paid"], transitions: [{from: "approved", to: "paid", via: "Pay"}]}
+}
+`
+
+func FuzzCompileContract(f *testing.F) {
+	f.Add([]byte(fuzzSeedContract))
+	f.Add([]byte(`facts: 1`))
+	f.Add([]byte(`rules: {a: 1}`))
+	f.Add([]byte(`operations: {"X": {constrained_by: "r1"}}`))
+	f.Add([]byte(`derived_facts: {"a": {derivation: {fn: "not", args: [{fact: "a"}]}}}`))
+	f.Add([]byte(`rules: [{when: {in: "x"}}]`))
+	f.Add([]byte(``))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		c, err := compileContract([]sourceFile{{path: "fuzz.cue", data: data}})
+		if err != nil {
+			if c != nil {
+				t.Fatalf("non-nil contract returned alongside error: %v", err)
+			}
+			return
+		}
+		if c.Facts == nil || c.DerivedFacts == nil || c.Operations == nil || c.Entities == nil {
+			t.Fatalf("extracted contract has nil maps: %+v", c)
+		}
+
+		// Whatever compiled must be evaluable without panicking.
+		eng := NewEngine(&mockPorts{})
+		eng.LoadContract(c, "fuzz")
+		for name := range c.Operations {
+			eng.Evaluate(context.Background(), &Request{Operation: name, DryRun: true})
+		}
+	})
+}
+
+func FuzzRequestDecode(f *testing.F) {
+	f.Add([]byte(`{"operation":"Pay","input":{"payment.amount":{"value":500,"currency":"USD"}},"dry_run":true}`))
+	f.Add([]byte(`{"operation":"Pay","input":{"customer.status":"closed"}}`))
+	f.Add([]byte(`{"operation":"Pay","input":null,"contract_etag":"fuzz"}`))
+	f.Add([]byte(`{"operation":"Nope"}`))
+	f.Add([]byte(`{"input":{"payment.amount":[1,2,3]}}`))
+	f.Add([]byte(`null`))
+
+	c, err := compileContract([]sourceFile{{path: "seed.cue", data: []byte(fuzzSeedContract)}})
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var req Request
+		if err := json.Unmarshal(data, &req); err != nil {
+			return
+		}
+
+		eng := NewEngine(&mockPorts{})
+		eng.LoadContract(c, "fuzz")
+		resp, err := eng.Evaluate(context.Background(), &req)
+		if err != nil {
+			if resp != nil {
+				t.Fatalf("non-nil response returned alongside error: %v", err)
+			}
+			return
+		}
+		if resp.Outcome == "" {
+			t.Fatalf("response without outcome: %+v", resp)
+		}
+		if resp.Outcome == "denied" && resp.Error == nil {
+			t.Fatalf("denied response without error envelope: %+v", resp)
+		}
+		if _, err := json.Marshal(resp); err != nil {
+			t.Fatalf("response does not encode: %v", err)
+		}
+	})
+}
+
+func FuzzEvalCondition(f *testing.F) {
+	f.Add(`"active"`, `"active"`, byte(0))
+	f.Add(`1000`, `500`, byte(1))
+	f.Add(`{"value":5}`, `5`, byte(2))
+	f.Add(`["a","b"]`, `"a"`, byte(3))
+	f.Add(`null`, `true`, byte(4))
+	f.Add(`true`, `{"x":1}`, byte(5))
+
+	f.Fuzz(func(t *testing.T, factJSON, operandJSON string, shape byte) {
+		var fact, operand any
+		if json.Unmarshal([]byte(factJSON), &fact) != nil {
+			return
+		}
+		if json.Unmarshal([]byte(operandJSON), &operand) != nil {
+			return
+		}
+
+		fs := NewFactSet()
+		fs.Set("f", fact)
+		fs.Set("g", operand)
+
+		leaf := Condition{Fact: "f"}
+		switch shape % 4 {
+		case 0:
+			leaf.Equals = operand
+		case 1:
+			leaf.GreaterThan = operand
+		case 2:
+			leaf.LessThan = operand
+		case 3:
+			leaf.In = []any{operand}
+		}
+		nested := Condition{Any: []Condition{{Not: &leaf}, {All: []Condition{leaf, {Fact: "f.value", Equals: operand}}}}}
+
+		// A condition and its negation can never both hold.
+		if evalCondition(leaf, fs) == evalCondition(Condition{Not: &leaf}, fs) {
+			t.Fatalf("condition and its negation agree for fact=%s operand=%s", factJSON, operandJSON)
+		}
+		evalCondition(nested, fs)
+
+		for _, fn := range []string{"greater_than", "greater_or_equal", "less_than", "equals", "and", "or", "not"} {
+			for _, args := range [][]DerivationArg{
+				nil,
+				{{Fact: "f"}},
+				{{Fact: "f"}, {Value: operand}},
+				{{Fact: "f", Op: "equals", Value: operand}, {Fact: "g"}},
+				{{Fact: "missing"}, {Fact: "f.value.deeper"}},
+			} {
+				if _, err := evalDerivation(Derivation{Fn: fn, Args: args}, fs); err != nil {
+					t.Fatalf("%s(%v): unexpected error %v", fn, args, err)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,169 @@
+package engine
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Execution is an executed operation, as kept in the execution history.
+type Execution struct {
+	// DecisionID is the invocation ID of the decision that executed it.
+	DecisionID string `json:"decision_id"`
+	Operation  string `json:"operation"`
+	// Entities maps each contract entity whose key the request carried to
+	// the instance it named, e.g. {"invoice": "inv_001"}.
+	Entities map[string]string `json:"entities,omitempty"`
+	// Output holds the scalar fields of the operation's output; nested
+	// objects and lists are left out.
+	Output          map[string]any `json:"output,omitempty"`
+	ContractVersion string         `json:"contract_version,omitempty"`
+	ExecutedAt      time.Time      `json:"executed_at"`
+	// Fingerprint identifies the values of the operation's duplicate keys,
+	// if it declares any; see DuplicateDef.
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+// ExecutionQuery selects executions from the history. Zero fields select
+// everything.
+type ExecutionQuery struct {
+	// Entity and ID select the executions on one entity instance.
+	Entity string
+	ID     string
+	// Operation selects the executions of one operation.
+	Operation string
+	// Fingerprint selects the executions with the given fingerprint.
+	Fingerprint string
+	// Since selects executions at or after it.
+	Since time.Time
+	// Limit bounds how many executions are returned; 0 means
+	// DefaultHistoryLimit.
+	Limit int
+}
+
+// DefaultHistoryLimit is how many executions a query returns unless it
+// sets a limit.
+const DefaultHistoryLimit = 100
+
+// ExecutionStore keeps the history of executed operations, for operation
+// prerequisites and investigations. Unlike decision history it is never
+// sampled.
+type ExecutionStore interface {
+	RecordExecution(ctx context.Context, ex *Execution) error
+	// Execution returns the execution made by the decision with the given
+	// invocation ID, or ErrNotFound if that decision executed nothing.
+	Execution(ctx context.Context, decisionID string) (*Execution, error)
+	// Executions returns the executions q selects, newest first.
+	Executions(ctx context.Context, q ExecutionQuery) ([]*Execution, error)
+}
+
+// WithHistory records every execution in s, where operation
+// prerequisites are looked up.
+func WithHistory(s ExecutionStore) Option {
+	return func(e *Engine) { e.history = s }
+}
+
+// recordExecution adds an execution to the history, if there is one. The
+// operation has taken effect by then, so a failure to record it does not
+// fail the request.
+func (e *Engine) recordExecution(ctx context.Context, c *Contract, rec *AuditRecord, input, output map[string]any) {
+	if e.history == nil {
+		return
+	}
+	ex := &Execution{
+		DecisionID:      rec.InvocationID,
+		Operation:       rec.Operation,
+		ContractVersion: rec.ContractVersion,
+		ExecutedAt:      rec.Timestamp,
+		Fingerprint:     duplicateFingerprint(c, rec.Operation, input),
+	}
+	for name := range c.Entities {
+		if id, err := entityKey(c, name, input); err == nil {
+			if ex.Entities == nil {
+				ex.Entities = map[string]string{}
+			}
+			ex.Entities[name] = id
+		}
+	}
+	for field, v := range output {
+		switch v.(type) {
+		case map[string]any, []any:
+			continue
+		}
+		if ex.Output == nil {
+			ex.Output = map[string]any{}
+		}
+		ex.Output[field] = v
+	}
+	e.history.RecordExecution(context.WithoutCancel(ctx), ex)
+}
+
+// MemoryExecutionStore is an ExecutionStore in process memory, for a single
+// executor and for tests. It keeps the most recent executions up to its
+// capacity, and is safe for concurrent use.
+type MemoryExecutionStore struct {
+	capacity int
+
+	mu         sync.Mutex
+	executions []*Execution // oldest first
+}
+
+// NewMemoryExecutionStore returns a MemoryExecutionStore keeping at most
+// capacity executions.
+func NewMemoryExecutionStore(capacity int) *MemoryExecutionStore {
+	return &MemoryExecutionStore{capacity: capacity}
+}
+
+// RecordExecution implements ExecutionStore.
+func (s *MemoryExecutionStore) RecordExecution(_ context.Context, ex *Execution) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.executions = append(s.executions, ex)
+	if over := len(s.executions) - s.capacity; s.capacity > 0 && over > 0 {
+		s.executions = slices.Delete(s.executions, 0, over)
+	}
+	return nil
+}
+
+// Execution implements ExecutionStore.
+func (s *MemoryExecutionStore) Execution(_ context.Context, decisionID string) (*Execution, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ex := range s.executions {
+		if ex.DecisionID == decisionID {
+			return ex, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Executions implements ExecutionStore.
+func (s *MemoryExecutionStore) Executions(_ context.Context, q ExecutionQuery) ([]*Execution, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultHistoryLimit
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*Execution
+	for i := len(s.executions) - 1; i >= 0 && len(out) < limit; i-- {
+		ex := s.executions[i]
+		if q.Matches(ex) {
+			out = append(out, ex)
+		}
+	}
+	return out, nil
+}
+
+// Matches reports whether q selects ex.
+func (q ExecutionQuery) Matches(ex *Execution) bool {
+	if q.Entity != "" {
+		if id, ok := ex.Entities[q.Entity]; !ok || id != q.ID {
+			return false
+		}
+	}
+	return (q.Operation == "" || ex.Operation == q.Operation) &&
+		(q.Fingerprint == "" || ex.Fingerprint == q.Fingerprint) &&
+		!ex.ExecutedAt.Before(q.Since)
+}
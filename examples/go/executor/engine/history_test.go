@@ -0,0 +1,78 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestEngine_recordsExecutions(t *testing.T) {
+	ports := &mockPorts{
+		executeFunc: func(_ context.Context, _, _ string, _ map[string]any) (map[string]any, error) {
+			return map[string]any{"payment_id": "pay_1", "amount": 120.0, "receipt": map[string]any{"url": "https://example.com"}}, nil
+		},
+	}
+	history := NewMemoryExecutionStore(0)
+	e := NewEngine(ports, WithHistory(history))
+	e.LoadContract(refundContract(), "v1")
+	ctx := context.Background()
+
+	resp, err := e.Evaluate(ctx, &Request{Operation: "ProcessPayment", Input: map[string]any{"invoice.id": "inv_1"}})
+	if err != nil || resp.Outcome != OutcomeExecuted {
+		t.Fatalf("expected executed, got %+v, %v", resp, err)
+	}
+	if _, err := e.Evaluate(ctx, &Request{Operation: "ProcessPayment", Input: map[string]any{"invoice.id": "inv_2"}, DryRun: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	ex, err := history.Execution(ctx, resp.InvocationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ex.Operation != "ProcessPayment" || ex.Entities["invoice"] != "inv_1" || ex.ContractVersion != "v1" {
+		t.Errorf("unexpected execution %+v", ex)
+	}
+	if len(ex.Output) != 2 || ex.Output["payment_id"] != "pay_1" {
+		t.Errorf("expected the scalar output fields only, got %v", ex.Output)
+	}
+	if list, _ := history.Executions(ctx, ExecutionQuery{Entity: "invoice", ID: "inv_2"}); len(list) != 0 {
+		t.Errorf("expected a dry run not recorded, got %+v", list)
+	}
+}
+
+func TestMemoryExecutionStore_queriesNewestFirst(t *testing.T) {
+	s := NewMemoryExecutionStore(3)
+	ctx := context.Background()
+	t0 := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i, ex := range []*Execution{
+		{DecisionID: "inv_a", Operation: "ProcessPayment", Entities: map[string]string{"invoice": "inv_001"}},
+		{DecisionID: "inv_b", Operation: "ProcessPayment", Entities: map[string]string{"invoice": "inv_001"}},
+		{DecisionID: "inv_c", Operation: "ProcessPayment", Entities: map[string]string{"invoice": "inv_002"}},
+		{DecisionID: "inv_d", Operation: "RefundPayment", Entities: map[string]string{"invoice": "inv_001"}},
+	} {
+		ex.ExecutedAt = t0.Add(time.Duration(i) * time.Minute)
+		s.RecordExecution(ctx, ex)
+	}
+
+	if _, err := s.Execution(ctx, "inv_a"); err != ErrNotFound {
+		t.Errorf("expected the oldest execution evicted, got %v", err)
+	}
+	for _, tc := range []struct {
+		q    ExecutionQuery
+		want []string
+	}{
+		{ExecutionQuery{Entity: "invoice", ID: "inv_001"}, []string{"inv_d", "inv_b"}},
+		{ExecutionQuery{Operation: "ProcessPayment"}, []string{"inv_c", "inv_b"}},
+		{ExecutionQuery{Since: t0.Add(2 * time.Minute)}, []string{"inv_d", "inv_c"}},
+		{ExecutionQuery{Limit: 1}, []string{"inv_d"}},
+	} {
+		list, _ := s.Executions(ctx, tc.q)
+		var got []string
+		for _, ex := range list {
+			got = append(got, ex.DecisionID)
+		}
+		if len(got) != len(tc.want) || (len(got) > 0 && (got[0] != tc.want[0] || got[len(got)-1] != tc.want[len(tc.want)-1])) {
+			t.Errorf("%+v: expected %v, got %v", tc.q, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+)
+
+// Hook points, in the order a request reaches them.
+const (
+	HookBeforeEvaluate = "before_evaluate"
+	HookAfterVerdict   = "after_verdict"
+	HookBeforeExecute  = "before_execute"
+	HookAfterExecute   = "after_execute"
+)
+
+// Hook attaches integrator logic to evaluation — enriching input, vetoing
+// requests, labeling decisions — without changing the engine. Any of its
+// callbacks may be nil. At each point, hooks run in the order they were
+// registered with WithHook, and the first to fail stops the rest.
+//
+// A callback that returns a *Veto refuses the request as denied (or
+// would_deny for a dry-run) with the veto's envelope; any other error
+// refuses it as a retryable system_error with code HOOK_FAILED.
+type Hook struct {
+	// Name identifies the hook in vetoes and errors.
+	Name string
+	// BeforeEvaluate runs once the caller is authorized, before facts are
+	// gathered. It alone may change the request's input.
+	BeforeEvaluate func(ctx context.Context, call *HookCall) error
+	// AfterVerdict runs once rules are evaluated, with the facts and
+	// verdicts, live or dry-run.
+	AfterVerdict func(ctx context.Context, call *HookCall) error
+	// BeforeExecute runs when a live request is about to execute.
+	BeforeExecute func(ctx context.Context, call *HookCall) error
+	// AfterExecute runs after execution, successful or not, with the
+	// response.
+	AfterExecute func(ctx context.Context, call *HookCall)
+}
+
+// Veto is the error a hook returns to refuse a request.
+type Veto struct {
+	Code    string
+	Message string
+	// HttpStatus defaults to 403.
+	HttpStatus int
+}
+
+func (v *Veto) Error() string {
+	return v.Code + ": " + v.Message
+}
+
+// HookCall is a hook's view of one request. Its getters return copies, so
+// hooks cannot change the request except through SetInput and Label.
+type HookCall struct {
+	stage     string
+	operation string
+	dryRun    bool
+	input     map[string]any
+	caller    map[string]any
+	facts     *FactSet
+	verdicts  []Verdict
+	resp      *Response
+	rec       *AuditRecord
+	changed   bool // input changed
+}
+
+// WithHook registers h. Hooks run in registration order.
+func WithHook(h Hook) Option {
+	return func(e *Engine) { e.hooks = append(e.hooks, h) }
+}
+
+// Stage returns the hook point being run.
+func (c *HookCall) Stage() string { return c.stage }
+
+// Operation returns the operation requested.
+func (c *HookCall) Operation() string { return c.operation }
+
+// DryRun reports whether the request is a dry-run.
+func (c *HookCall) DryRun() bool { return c.dryRun }
+
+// Input returns an input field.
+func (c *HookCall) Input(name string) (any, bool) {
+	v, ok := c.input[name]
+	return cloneValue(v), ok
+}
+
+// Caller returns one of the caller's ctx facts.
+func (c *HookCall) Caller(name string) (any, bool) {
+	v, ok := c.caller[name]
+	return cloneValue(v), ok
+}
+
+// Fact returns a gathered or derived fact, from AfterVerdict on.
+func (c *HookCall) Fact(name string) (any, bool) {
+	if c.facts == nil {
+		return nil, false
+	}
+	v, ok := c.facts.Get(name)
+	return cloneValue(v), ok
+}
+
+// Verdicts returns the verdicts rules produced, from AfterVerdict on.
+func (c *HookCall) Verdicts() []Verdict {
+	return copyVerdicts(c.verdicts)
+}
+
+// Outcome returns the response's outcome in AfterExecute.
+func (c *HookCall) Outcome() Outcome {
+	if c.resp == nil {
+		return ""
+	}
+	return c.resp.Outcome
+}
+
+// Output returns the execution's output in AfterExecute.
+func (c *HookCall) Output() map[string]any {
+	if c.resp == nil {
+		return nil
+	}
+	out, _ := cloneValue(c.resp.Output).(map[string]any)
+	return out
+}
+
+// SetInput sets an input field, as enrichment before facts are gathered.
+// The audit record carries the changed input.
+func (c *HookCall) SetInput(name string, value any) error {
+	if c.stage != HookBeforeEvaluate {
+		return fmt.Errorf("input can only be changed %s, not %s", HookBeforeEvaluate, c.stage)
+	}
+	c.input[name] = cloneValue(value)
+	c.changed = true
+	return nil
+}
+
+// Label attaches a label to the request's audit record.
+func (c *HookCall) Label(key, value string) {
+	if c.rec.Labels == nil {
+		c.rec.Labels = map[string]string{}
+	}
+	c.rec.Labels[key] = value
+}
+
+// newHookCall returns the hook view of req, or nil without hooks.
+func (e *Engine) newHookCall(req *Request, rec *AuditRecord) *HookCall {
+	if len(e.hooks) == 0 {
+		return nil
+	}
+	return &HookCall{
+		operation: req.Operation,
+		dryRun:    req.DryRun,
+		input:     cloneValue(req.Input).(map[string]any),
+		caller:    req.Context,
+		rec:       rec,
+	}
+}
+
+// runHooks runs the stage's callbacks in order. On the first failure it
+// returns the response refusing the request, with verdicts so far.
+func (e *Engine) runHooks(ctx context.Context, call *HookCall, stage string) *Response {
+	if call == nil {
+		return nil
+	}
+	call.stage = stage
+	for _, h := range e.hooks {
+		var fn func(context.Context, *HookCall) error
+		switch stage {
+		case HookBeforeEvaluate:
+			fn = h.BeforeEvaluate
+		case HookAfterVerdict:
+			fn = h.AfterVerdict
+		case HookBeforeExecute:
+			fn = h.BeforeExecute
+		case HookAfterExecute:
+			if h.AfterExecute != nil {
+				h.AfterExecute(ctx, call)
+			}
+			continue
+		}
+		if fn == nil {
+			continue
+		}
+		if err := fn(ctx, call); err != nil {
+			return hookRefusal(h.Name, err, call)
+		}
+	}
+	return nil
+}
+
+// afterExecute runs the AfterExecute callbacks with resp.
+func (e *Engine) afterExecute(ctx context.Context, call *HookCall, resp *Response) {
+	if call != nil {
+		call.resp = resp
+		e.runHooks(ctx, call, HookAfterExecute)
+	}
+}
+
+// hookRefusal is the response to a request a hook refused.
+func hookRefusal(hook string, err error, call *HookCall) *Response {
+	veto, ok := err.(*Veto)
+	if !ok {
+		return &Response{
+			Outcome: OutcomeSystemError,
+			Error: &ErrorEnvelope{
+				Code:       "HOOK_FAILED",
+				Message:    fmt.Sprintf("hook %s failed %s: %v", hook, call.stage, err),
+				HttpStatus: 500,
+				Category:   "system",
+				Retryable:  true,
+				Details:    map[string]any{"hook": hook},
+			},
+			Verdicts: call.verdicts,
+		}
+	}
+	status := veto.HttpStatus
+	if status == 0 {
+		status = 403
+	}
+	env := &ErrorEnvelope{
+		Code:       veto.Code,
+		Message:    veto.Message,
+		HttpStatus: status,
+		Category:   "business_rule_violation",
+		Details:    map[string]any{"hook": hook},
+	}
+	verdicts := append(call.Verdicts(), Verdict{Type: "deny", Code: veto.Code, Reason: veto.Message, Error: env})
+	if call.dryRun {
+		return &Response{DryRun: true, Outcome: OutcomeWouldDeny, Verdicts: verdicts, SideEffectsIsolated: true}
+	}
+	return &Response{Outcome: OutcomeDenied, Error: env, Verdicts: verdicts}
+}
+
+// cloneValue deep-copies a decoded JSON value.
+func cloneValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, x := range v {
+			out[k] = cloneValue(x)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, x := range v {
+			out[i] = cloneValue(x)
+		}
+		return out
+	}
+	return v
+}
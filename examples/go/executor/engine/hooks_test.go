@@ -0,0 +1,156 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// flaggedContract flags blocked customers.
+func flaggedContract() *Contract {
+	return makeSimpleContract("r1",
+		VerdictDef{Flag: &FlagVerdict{Code: "BLOCKED"}},
+		Condition{Fact: "customer.status", Equals: "blocked"},
+	)
+}
+
+func TestEngine_Evaluate_runsHooksInOrder(t *testing.T) {
+	var calls []string
+	record := func(name string) func(context.Context, *HookCall) error {
+		return func(_ context.Context, call *HookCall) error {
+			calls = append(calls, name+":"+call.Stage())
+			return nil
+		}
+	}
+	enrich := Hook{
+		Name: "enrich",
+		BeforeEvaluate: func(ctx context.Context, call *HookCall) error {
+			record("enrich")(ctx, call)
+			return call.SetInput("customer.status", "blocked")
+		},
+		AfterVerdict: func(ctx context.Context, call *HookCall) error {
+			record("enrich")(ctx, call)
+			if err := call.SetInput("customer.status", "active"); err == nil {
+				t.Error("expected input to be read-only after evaluation")
+			}
+			if v, _ := call.Fact("customer.status"); v != "blocked" || len(call.Verdicts()) != 1 {
+				t.Errorf("expected the enriched fact and its flag, got %v and %+v", v, call.Verdicts())
+			}
+			call.Label("tier", "gold")
+			return nil
+		},
+	}
+	audit := Hook{
+		Name:           "audit",
+		BeforeEvaluate: record("audit"),
+		BeforeExecute:  record("audit"),
+		AfterExecute: func(ctx context.Context, call *HookCall) {
+			record("audit")(ctx, call)
+			if call.Outcome() != "executed" || call.Output()["result"] != "ok" {
+				t.Errorf("expected the response, got %s %v", call.Outcome(), call.Output())
+			}
+		},
+	}
+	ports := &mockPorts{executeFunc: func(context.Context, string, string, map[string]any) (map[string]any, error) {
+		return map[string]any{"result": "ok"}, nil
+	}}
+	sink := &recordingSink{}
+	e := NewEngine(ports, WithAuditSink(sink), WithHook(enrich), WithHook(audit))
+	e.LoadContract(flaggedContract(), "v1")
+
+	input := map[string]any{"customer.id": "cust_1"}
+	resp, err := e.Evaluate(context.Background(), &Request{Operation: "testOp", Input: input})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Outcome != "executed" || len(resp.Verdicts) != 1 {
+		t.Fatalf("expected the enriched input flagged, got %+v", resp)
+	}
+	want := []string{
+		"enrich:before_evaluate", "audit:before_evaluate",
+		"enrich:after_verdict",
+		"audit:before_execute",
+		"audit:after_execute",
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("got calls %v", calls)
+	}
+	if _, ok := input["customer.status"]; ok {
+		t.Error("expected the caller's input left unchanged")
+	}
+	rec := sink.recs[0]
+	if rec.Input["customer.status"] != "blocked" || rec.Labels["tier"] != "gold" {
+		t.Errorf("expected the enriched input and labels audited, got %v %v", rec.Input, rec.Labels)
+	}
+}
+
+func TestEngine_Evaluate_hookVetoes(t *testing.T) {
+	var executed, later bool
+	ports := &mockPorts{executeFunc: func(context.Context, string, string, map[string]any) (map[string]any, error) {
+		executed = true
+		return map[string]any{}, nil
+	}}
+	veto := Hook{Name: "fraud", AfterVerdict: func(context.Context, *HookCall) error {
+		return &Veto{Code: "FRAUD_SUSPECTED", Message: "Blocked by the fraud screen"}
+	}}
+	next := Hook{Name: "next", AfterVerdict: func(context.Context, *HookCall) error {
+		later = true
+		return nil
+	}}
+	e := NewEngine(ports, WithHook(veto), WithHook(next))
+	e.LoadContract(flaggedContract(), "v1")
+
+	resp, _ := e.Evaluate(context.Background(), &Request{Operation: "testOp", Input: map[string]any{"customer.status": "blocked"}})
+	if resp.Outcome != "denied" || resp.Error.Code != "FRAUD_SUSPECTED" || resp.Error.HttpStatus != 403 || resp.Error.Details["hook"] != "fraud" {
+		t.Fatalf("expected the veto, got %+v", resp)
+	}
+	if len(resp.Verdicts) != 2 || resp.Verdicts[1].Type != "deny" {
+		t.Errorf("expected the rule's flag and the veto's deny, got %+v", resp.Verdicts)
+	}
+	if executed || later {
+		t.Error("expected a veto to stop execution and later hooks")
+	}
+
+	resp, _ = e.Evaluate(context.Background(), &Request{Operation: "testOp", Input: map[string]any{}, DryRun: true})
+	if resp.Outcome != "would_deny" || !resp.DryRun {
+		t.Errorf("expected a dry-run veto to be would_deny, got %+v", resp)
+	}
+}
+
+func TestEngine_Evaluate_hookFailureIsRetryable(t *testing.T) {
+	e := NewEngine(&mockPorts{}, WithHook(Hook{Name: "crm", BeforeEvaluate: func(context.Context, *HookCall) error {
+		return errors.New("crm unreachable")
+	}}))
+	e.LoadContract(flaggedContract(), "v1")
+
+	resp, _ := e.Evaluate(context.Background(), &Request{Operation: "testOp", Input: map[string]any{}})
+	if resp.Outcome != "system_error" || resp.Error.Code != "HOOK_FAILED" || !resp.Error.Retryable {
+		t.Errorf("expected HOOK_FAILED, got %+v", resp)
+	}
+}
+
+func TestHookCall_gettersReturnCopies(t *testing.T) {
+	e := NewEngine(&mockPorts{}, WithHook(Hook{}))
+	input := map[string]any{"items": []any{map[string]any{"sku": "A"}}}
+	call := e.newHookCall(&Request{Operation: "testOp", Input: input}, &AuditRecord{})
+
+	items, _ := call.Input("items")
+	items.([]any)[0].(map[string]any)["sku"] = "B"
+	if input["items"].([]any)[0].(map[string]any)["sku"] != "A" {
+		t.Error("expected the request input unchanged by writes to a getter's value")
+	}
+
+	call.verdicts = []Verdict{{
+		Type:     "deny",
+		Error:    &ErrorEnvelope{Code: "DENIED", Details: map[string]any{"limit": 10}},
+		Metadata: map[string]any{"tags": []any{"a"}},
+	}}
+	v := call.Verdicts()
+	v[0].Error.Code = "CHANGED"
+	v[0].Error.Details["limit"] = 20
+	v[0].Metadata["tags"].([]any)[0] = "b"
+	if w := call.verdicts[0]; w.Error.Code != "DENIED" || w.Error.Details["limit"] != 10 || w.Metadata["tags"].([]any)[0] != "a" {
+		t.Errorf("expected the verdicts unchanged by writes to a getter's value, got %+v", w)
+	}
+}
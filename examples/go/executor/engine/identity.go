@@ -0,0 +1,181 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+)
+
+// Identity ctx facts. Identity providers derive them from the credentials a
+// caller presented, and the identity operators read them:
+//
+//	{has_role: "billing_admin"}                   user.roles contains it
+//	{has_scope: "payments:write"}                 user.scopes contains it
+//	{subject_matches: "spiffe://acme.com/ns/*"}   user.subject matches the pattern
+//	{tenant_equals: {param: "tenant"}}            user.tenant equals it
+//
+// A contract using an operator must declare its fact with source "ctx".
+const (
+	FactSubject = "user.subject"
+	FactRoles   = "user.roles"
+	FactScopes  = "user.scopes"
+	FactTenant  = "user.tenant"
+)
+
+// PersonaDef declares an identity that invokes operations. Can lists the
+// operations it may invoke.
+type PersonaDef struct {
+	Description string   `json:"description,omitempty"`
+	Can         []string `json:"can"`
+	RequiresMFA bool     `json:"requires_mfa,omitempty"`
+}
+
+// identityOp returns the identity operator cond uses, the fact it reads and
+// its operand, or an empty op if it uses none.
+func (cond Condition) identityOp() (op, fact string, operand any) {
+	switch {
+	case cond.HasRole != nil:
+		return "has_role", FactRoles, cond.HasRole
+	case cond.HasScope != nil:
+		return "has_scope", FactScopes, cond.HasScope
+	case cond.SubjectMatches != nil:
+		return "subject_matches", FactSubject, cond.SubjectMatches
+	case cond.TenantEquals != nil:
+		return "tenant_equals", FactTenant, cond.TenantEquals
+	}
+	return "", "", nil
+}
+
+func (cond Condition) isIdentity() bool {
+	op, _, _ := cond.identityOp()
+	return op != ""
+}
+
+// evalIdentity applies an identity operator to the value of its fact.
+func evalIdentity(op string, val, want any) bool {
+	switch op {
+	case "has_role", "has_scope":
+		return contains(val, want)
+	case "subject_matches":
+		subject, _ := val.(string)
+		pattern, _ := want.(string)
+		matched, err := path.Match(pattern, subject)
+		return subject != "" && err == nil && matched
+	case "tenant_equals":
+		return val != nil && applyOp("equals", val, want)
+	}
+	return false
+}
+
+type callerFactsKey struct{}
+
+// WithCallerFacts returns a context carrying the caller's ctx facts as an
+// identity provider established them. Evaluate uses them in place of the
+// request's Context, which the caller could have written itself; facts nil
+// means the caller presented no credentials, so it has no ctx facts.
+func WithCallerFacts(ctx context.Context, facts map[string]any) context.Context {
+	if facts == nil {
+		facts = map[string]any{}
+	}
+	return context.WithValue(ctx, callerFactsKey{}, facts)
+}
+
+// CallerFacts returns the ctx facts WithCallerFacts put in ctx.
+func CallerFacts(ctx context.Context) (map[string]any, bool) {
+	facts, ok := ctx.Value(callerFactsKey{}).(map[string]any)
+	return facts, ok
+}
+
+// validateIdentity checks the identity operators in rules and authorize
+// blocks, and the personas they name.
+func validateIdentity(c *Contract) []Diagnostic {
+	var diags []Diagnostic
+	for _, r := range c.Rules {
+		walkIdentity(r.When, false, func(cond Condition, _ bool) {
+			op, fact, _ := cond.identityOp()
+			if def, ok := c.Facts[fact]; !ok || def.Source != "ctx" {
+				diags = append(diags, Diagnostic{
+					Severity: SeverityError,
+					Rule:     r.ID,
+					Message:  fmt.Sprintf("%s reads %s, which is not declared as a ctx fact", op, fact),
+				})
+			}
+			for _, msg := range checkIdentityOperand(c, cond) {
+				diags = append(diags, Diagnostic{Severity: SeverityError, Rule: r.ID, Message: msg})
+			}
+		})
+	}
+	for _, name := range sortedOperations(c) {
+		auth := c.Operations[name].Authorize
+		if auth == nil {
+			continue
+		}
+		walkIdentity(auth.Requires, false, func(cond Condition, negated bool) {
+			for _, msg := range checkIdentityOperand(c, cond) {
+				diags = append(diags, Diagnostic{Severity: SeverityError, Message: fmt.Sprintf("operation %s: authorize %s", name, msg)})
+			}
+			role, ok := c.ResolveOperand(cond.HasRole).(string)
+			if p, declared := c.Personas[role]; ok && declared && !negated && !slices.Contains(p.Can, name) {
+				diags = append(diags, Diagnostic{
+					Severity: SeverityWarning,
+					Message:  fmt.Sprintf("operation %s: authorize admits persona %s, whose can list does not include it", name, role),
+				})
+			}
+		})
+	}
+	for _, persona := range sortedPersonas(c) {
+		for _, op := range c.Personas[persona].Can {
+			if _, ok := c.Operations[op]; !ok {
+				diags = append(diags, Diagnostic{
+					Severity: SeverityWarning,
+					Message:  fmt.Sprintf("persona %s: can invoke unknown operation %s", persona, op),
+				})
+			}
+		}
+	}
+	return diags
+}
+
+// checkIdentityOperand checks an identity operator's operand: a
+// subject_matches pattern must be valid, and once personas are declared a
+// has_role must name one.
+func checkIdentityOperand(c *Contract, cond Condition) []string {
+	op, _, operand := cond.identityOp()
+	value := c.ResolveOperand(operand)
+	switch op {
+	case "subject_matches":
+		pattern, ok := value.(string)
+		if _, err := path.Match(pattern, ""); !ok || err != nil {
+			return []string{fmt.Sprintf("subject_matches pattern %v is not a valid pattern", value)}
+		}
+	case "has_role":
+		if _, ok := c.Personas[fmt.Sprint(value)]; len(c.Personas) > 0 && !ok {
+			return []string{fmt.Sprintf("has_role %v names no declared persona", value)}
+		}
+	}
+	return nil
+}
+
+// walkIdentity calls visit for each identity condition in cond, with whether
+// it sits under a not.
+func walkIdentity(cond Condition, negated bool, visit func(Condition, bool)) {
+	if cond.isIdentity() {
+		visit(cond, negated)
+	}
+	for _, sub := range cond.All {
+		walkIdentity(sub, negated, visit)
+	}
+	for _, sub := range cond.Any {
+		walkIdentity(sub, negated, visit)
+	}
+	if cond.Not != nil {
+		walkIdentity(*cond.Not, !negated, visit)
+	}
+}
+
+// sortedPersonas returns the contract's persona names in order.
+func sortedPersonas(c *Contract) []string {
+	return slices.Sorted(maps.Keys(c.Personas))
+}
@@ -0,0 +1,118 @@
+package engine
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEvalCondition_identityOperators(t *testing.T) {
+	fs := NewFactSet()
+	fs.Set(FactRoles, []any{"support", "billing_admin"})
+	fs.Set(FactScopes, []string{"invoices:read", "payments:write"})
+	fs.Set(FactSubject, "spiffe://acme.com/ns/billing/sa/worker")
+	fs.Set(FactTenant, "acme")
+	fs.Set(paramFactPrefix+"tenant", "acme")
+	for _, tc := range []struct {
+		cond Condition
+		want bool
+	}{
+		{Condition{HasRole: "billing_admin"}, true},
+		{Condition{HasRole: "admin"}, false},
+		{Condition{HasScope: "payments:write"}, true},
+		{Condition{HasScope: "payments"}, false},
+		{Condition{SubjectMatches: "spiffe://acme.com/ns/billing/sa/*"}, true},
+		{Condition{SubjectMatches: "spiffe://acme.com/ns/*"}, false},
+		{Condition{TenantEquals: map[string]any{"param": "tenant"}}, true},
+		{Condition{TenantEquals: "globex"}, false},
+	} {
+		if got := evalCondition(tc.cond, fs); got != tc.want {
+			op, _, operand := tc.cond.identityOp()
+			t.Errorf("%s %v: got %v, want %v", op, operand, got, tc.want)
+		}
+		if tr := traceCondition(tc.cond, fs); tr.Passed != tc.want {
+			t.Errorf("trace disagrees with evalCondition for %+v", tc.cond)
+		}
+	}
+	if evalCondition(Condition{TenantEquals: "acme"}, NewFactSet()) {
+		t.Error("expected an absent tenant to match nothing")
+	}
+}
+
+func TestEngine_Evaluate_callerFactsReplaceRequestContext(t *testing.T) {
+	c := makeMinimalContract()
+	c.Facts[FactRoles] = FactDef{Source: "ctx"}
+	c.Operations["testOp"] = OperationDef{Authorize: &AuthorizeDef{Requires: Condition{HasRole: "billing_admin"}}}
+	e := NewEngine(&mockPorts{})
+	e.LoadContract(c, "v1")
+
+	claimed := &Request{Operation: "testOp", Context: map[string]any{FactRoles: []any{"billing_admin"}}}
+	ctx := WithCallerFacts(context.Background(), nil)
+	if resp, _ := e.Evaluate(ctx, claimed); resp.Outcome != "denied" {
+		t.Errorf("expected a self-asserted role ignored once a provider ran, got %s", resp.Outcome)
+	}
+	ctx = WithCallerFacts(context.Background(), map[string]any{FactRoles: []string{"billing_admin"}})
+	if resp, _ := e.Evaluate(ctx, &Request{Operation: "testOp"}); resp.Outcome != "executed" {
+		t.Errorf("expected the provider's role admitted, got %s", resp.Outcome)
+	}
+}
+
+func TestValidate_identityOperatorsAndPersonas(t *testing.T) {
+	c := makeSimpleContract("r1",
+		VerdictDef{Flag: &FlagVerdict{Code: "F", Reason: "f"}},
+		Condition{All: []Condition{
+			{HasScope: "payments:write"},
+			{HasRole: "auditor"},
+			{SubjectMatches: "spiffe://acme.com/[ns"},
+		}})
+	c.Facts[FactRoles] = FactDef{Source: "ctx"}
+	c.Personas = map[string]PersonaDef{
+		"customer":      {Can: []string{"testOp"}},
+		"billing_admin": {Can: []string{"Refund"}},
+	}
+	c.Operations["testOp"] = OperationDef{
+		ConstrainedBy: []string{"r1"},
+		Authorize:     &AuthorizeDef{Requires: Condition{Any: []Condition{{HasRole: "customer"}, {HasRole: "billing_admin"}}}},
+	}
+
+	var got []string
+	for _, d := range Validate(c, time.Now()) {
+		got = append(got, d.String())
+	}
+	want := []string{
+		"error: rule r1: has_scope reads user.scopes, which is not declared as a ctx fact",
+		"error: rule r1: has_role auditor names no declared persona",
+		"error: rule r1: subject_matches reads user.subject, which is not declared as a ctx fact",
+		"error: rule r1: subject_matches pattern spiffe://acme.com/[ns is not a valid pattern",
+		"warning: operation testOp: authorize admits persona billing_admin, whose can list does not include it",
+		"warning: persona billing_admin: can invoke unknown operation Refund",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s", strings.Join(got, "\n"))
+	}
+}
+
+func TestCompileSources_personasAndIdentityOperators(t *testing.T) {
+	c, err := CompileSources(map[string][]byte{"contract.cue": []byte(`
+facts: "user.roles": {source: "ctx"}
+personas: customer: {description: "Pays invoices", can: ["Pay"]}
+operations: Pay: {
+	constrained_by: []
+	transitions: []
+	authorize: requires: {has_role: "customer"}
+}
+`)}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := c.Personas["customer"]; len(p.Can) != 1 || p.Can[0] != "Pay" {
+		t.Errorf("personas not extracted: %+v", c.Personas)
+	}
+	if got := c.Operations["Pay"].Authorize.Requires.HasRole; got != "customer" {
+		t.Errorf("has_role not extracted: %v", got)
+	}
+	if diags := Validate(c, time.Now()); len(diags) != 0 {
+		t.Errorf("unexpected diagnostics %v", diags)
+	}
+}
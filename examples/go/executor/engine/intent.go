@@ -0,0 +1,231 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Intent bounds: how long prefetched facts stay usable by default and at
+// most, and how many cached facts the engine holds.
+const (
+	defaultIntentTTL  = 30 * time.Second
+	maxIntentTTL      = 5 * time.Minute
+	factCacheCapacity = 10_000
+)
+
+// FactTrace sources and origins.
+const (
+	factSourcePort     = "port"
+	factSourceCache    = "cache"
+	factSourceState    = "state"
+	factOriginPrefetch = "prefetch"
+)
+
+// Intent declares that a client will likely invoke Operation with Input
+// soon. Prefetch warms the fact cache with the operation's port facts, so
+// the eventual request need not wait for its ports.
+type Intent struct {
+	Operation string         `json:"operation"`
+	Input     map[string]any `json:"input"`
+	// TTLSeconds is how long the prefetched facts may be used; default 30,
+	// at most 300.
+	TTLSeconds int `json:"ttl_seconds,omitempty"`
+	// Purpose is the purpose of the requests to come; facts the data-use
+	// policy does not allow for it are not prefetched.
+	Purpose string `json:"purpose,omitempty"`
+}
+
+// IntentReceipt reports what Prefetch warmed.
+type IntentReceipt struct {
+	IntentID  string            `json:"intent_id"`
+	Operation string            `json:"operation"`
+	Facts     []string          `json:"facts"`
+	Failed    map[string]string `json:"failed,omitempty"`
+	ExpiresAt time.Time         `json:"expires_at"`
+}
+
+// FactTrace records where a fact's value came from, for explain output:
+// fetched from its port for this request, served from the fact cache, or
+// read from the state store.
+type FactTrace struct {
+	Source    string     `json:"source"` // port, cache or state
+	Port      string     `json:"port,omitempty"`
+	FetchedAt time.Time  `json:"fetched_at"`
+	AsOf      *time.Time `json:"as_of,omitempty"` // when the port says the value was true
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// Origin is what put a cached value there ("prefetch", "derived" or
+	// "session"), IntentID the intent that prefetched it and SessionID the
+	// session it was fetched in.
+	Origin    string `json:"origin,omitempty"`
+	IntentID  string `json:"intent_id,omitempty"`
+	SessionID string `json:"session_id,omitempty"`
+}
+
+// Prefetch fetches the port facts the rules of intent.Operation need, with
+// intent.Input as request input, and caches them. A later request uses a
+// cached fact if its input agrees with every field of intent.Input and the
+// fact has not expired. Facts whose port fails are reported, not cached.
+func (e *Engine) Prefetch(ctx context.Context, intent *Intent) (*IntentReceipt, error) {
+	contract := e.Contract()
+	if contract == nil {
+		return nil, fmt.Errorf("no contract loaded")
+	}
+	if _, ok := contract.Operations[intent.Operation]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownOperation, intent.Operation)
+	}
+	ttl := defaultIntentTTL
+	if intent.TTLSeconds > 0 {
+		ttl = min(time.Duration(intent.TTLSeconds)*time.Second, maxIntentTTL)
+	}
+
+	receipt := &IntentReceipt{
+		IntentID:  "int_" + randID(16),
+		Operation: intent.Operation,
+		Facts:     []string{},
+		ExpiresAt: e.now().Add(ttl).UTC(),
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name := range neededBaseFacts(contract, intent.Operation) {
+		def := contract.Facts[name]
+		if !strings.HasPrefix(def.Source, "port:") {
+			continue
+		}
+		if !contract.PurposeAllows(name, intent.Purpose) {
+			mu.Lock()
+			if receipt.Failed == nil {
+				receipt.Failed = map[string]string{}
+			}
+			receipt.Failed[name] = fmt.Sprintf("not permitted for purpose %q", intent.Purpose)
+			mu.Unlock()
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			val, err := e.ports.Get(ctx, portName(def.Source), name, intent.Input)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if receipt.Failed == nil {
+					receipt.Failed = map[string]string{}
+				}
+				receipt.Failed[name] = err.Error()
+				return
+			}
+			trace := FactTrace{
+				Source:    factSourceCache,
+				Port:      portName(def.Source),
+				FetchedAt: e.now().UTC(),
+				ExpiresAt: &receipt.ExpiresAt,
+				Origin:    factOriginPrefetch,
+				IntentID:  receipt.IntentID,
+			}
+			val = unwrapTimestamped(val, &trace)
+			e.factCache.put(name, &cachedFact{scope: intent.Input, value: val, trace: trace}, e.now())
+			receipt.Facts = append(receipt.Facts, name)
+		}()
+	}
+	wg.Wait()
+	sort.Strings(receipt.Facts)
+	return receipt, nil
+}
+
+// factCache holds port facts fetched ahead of the requests that use them,
+// and derived facts computed by earlier requests. Each entry is scoped to
+// the input it was fetched or computed with.
+type factCache struct {
+	mu      sync.Mutex
+	entries map[string][]*cachedFact // by fact name
+	size    int
+}
+
+type cachedFact struct {
+	scope map[string]any
+	value any
+	trace FactTrace
+	etag  string // contract a derived value was computed under
+}
+
+func (c *cachedFact) expired(now time.Time) bool {
+	return c.trace.ExpiresAt != nil && !now.Before(*c.trace.ExpiresAt)
+}
+
+// matches reports whether input agrees with every field of the entry's
+// scope.
+func (c *cachedFact) matches(input map[string]any) bool {
+	for k, v := range c.scope {
+		iv, ok := input[k]
+		if !ok || !equalValues(iv, v) {
+			return false
+		}
+	}
+	return true
+}
+
+func newFactCache() *factCache {
+	return &factCache{entries: map[string][]*cachedFact{}}
+}
+
+// get returns the freshest unexpired entry for fact that input matches.
+func (c *factCache) get(fact string, input map[string]any, now time.Time) (*cachedFact, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var best *cachedFact
+	for _, ent := range c.entries[fact] {
+		if !ent.expired(now) && ent.matches(input) && (best == nil || ent.trace.FetchedAt.After(best.trace.FetchedAt)) {
+			best = ent
+		}
+	}
+	return best, best != nil
+}
+
+// put adds an entry, replacing one with the same scope. When the cache is
+// full, expired entries are dropped first; if none are, the new entry is
+// not kept.
+func (c *factCache) put(fact string, ent *cachedFact, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	list := c.entries[fact]
+	for i, old := range list {
+		if equalValues(old.scope, ent.scope) {
+			list[i] = ent
+			return
+		}
+	}
+	if c.size >= factCacheCapacity {
+		c.sweep(now)
+		if c.size >= factCacheCapacity {
+			return
+		}
+	}
+	c.entries[fact] = append(c.entries[fact], ent)
+	c.size++
+}
+
+// sweep drops expired entries. Callers hold c.mu.
+func (c *factCache) sweep(now time.Time) {
+	for fact, list := range c.entries {
+		kept := list[:0]
+		for _, ent := range list {
+			if !ent.expired(now) {
+				kept = append(kept, ent)
+			}
+		}
+		c.size -= len(list) - len(kept)
+		if len(kept) == 0 {
+			delete(c.entries, fact)
+		} else {
+			c.entries[fact] = kept
+		}
+	}
+}
+
+// equalValues compares decoded JSON values the way conditions do.
+func equalValues(a, b any) bool {
+	return fmt.Sprintf("%v", a) == fmt.Sprintf("%v", b)
+}
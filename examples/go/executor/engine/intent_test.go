@@ -0,0 +1,85 @@
+package engine
+
+import (
+	"context"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// intentContract flags testOp when the balance, fetched per customer, is
+// low.
+func intentContract() *Contract {
+	c := makeMinimalContract()
+	c.Facts["customer.id"] = FactDef{Source: "input", Required: true}
+	c.Facts["balance"] = FactDef{Source: "port:accounts"}
+	c.Rules = []RuleDef{{ID: "low", When: Condition{Fact: "balance", LessThan: 10.0}, Verdict: VerdictDef{Flag: &FlagVerdict{Code: "LOW"}}}}
+	c.Operations["testOp"] = OperationDef{ConstrainedBy: []string{"low"}}
+	return c
+}
+
+func TestEngine_Prefetch_warmsFactsForMatchingRequests(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	var gets atomic.Int32
+	ports := &mockPorts{getFunc: func(_ context.Context, _, _ string, input map[string]any) (any, error) {
+		gets.Add(1)
+		return 5.0, nil
+	}}
+	e := NewEngine(ports, WithClock(func() time.Time { return now }))
+	e.LoadContract(intentContract(), "v1")
+
+	receipt, err := e.Prefetch(context.Background(), &Intent{Operation: "testOp", Input: map[string]any{"customer.id": "cust_1"}, TTLSeconds: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(receipt.Facts, []string{"balance"}) || !receipt.ExpiresAt.Equal(now.Add(time.Minute)) || gets.Load() != 1 {
+		t.Fatalf("unexpected receipt %+v after %d gets", receipt, gets.Load())
+	}
+
+	resp, err := e.Evaluate(context.Background(), &Request{
+		Operation: "testOp",
+		Input:     map[string]any{"customer.id": "cust_1", "note": "extra input is fine"},
+		Explain:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gets.Load() != 1 || resp.Outcome != "executed" || len(resp.Verdicts) != 1 {
+		t.Fatalf("expected the prefetched fact to be used, got %d gets, %+v", gets.Load(), resp)
+	}
+	trace := resp.Explain.Facts["balance"]
+	if trace.Source != "cache" || trace.Origin != "prefetch" || trace.IntentID != receipt.IntentID || trace.Port != "accounts" {
+		t.Errorf("expected cache attribution in explain, got %+v", trace)
+	}
+
+	// Another customer, and the same customer after expiry, go to the port.
+	e.Evaluate(context.Background(), &Request{Operation: "testOp", Input: map[string]any{"customer.id": "cust_2"}})
+	now = now.Add(time.Minute)
+	resp, _ = e.Evaluate(context.Background(), &Request{Operation: "testOp", Input: map[string]any{"customer.id": "cust_1"}, Explain: true})
+	if gets.Load() != 3 || resp.Explain.Facts["balance"].Source != "port" {
+		t.Errorf("expected port fetches outside the intent, got %d gets, %+v", gets.Load(), resp.Explain.Facts)
+	}
+}
+
+func TestEngine_Prefetch_reportsFailedFacts(t *testing.T) {
+	ports := &mockPorts{getFunc: func(context.Context, string, string, map[string]any) (any, error) {
+		return nil, context.DeadlineExceeded
+	}}
+	e := NewEngine(ports)
+	e.LoadContract(intentContract(), "v1")
+
+	receipt, err := e.Prefetch(context.Background(), &Intent{Operation: "testOp", Input: map[string]any{"customer.id": "cust_1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(receipt.Facts) != 0 || receipt.Failed["balance"] == "" {
+		t.Errorf("expected the failure reported, got %+v", receipt)
+	}
+	if _, ok := e.factCache.get("balance", map[string]any{"customer.id": "cust_1"}, time.Now()); ok {
+		t.Error("failed fetches must not be cached")
+	}
+	if _, err := e.Prefetch(context.Background(), &Intent{Operation: "nope"}); err == nil {
+		t.Error("expected error for an unknown operation")
+	}
+}
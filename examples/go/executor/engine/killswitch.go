@@ -0,0 +1,111 @@
+package engine
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// KillSwitchMode is what a kill switch does to its operation's requests.
+type KillSwitchMode string
+
+const (
+	// KillSwitchDeny refuses every request with a TEMPORARILY_UNAVAILABLE
+	// envelope.
+	KillSwitchDeny KillSwitchMode = "deny"
+	// KillSwitchDryRun evaluates live requests as dry runs, so they are
+	// decided and audited but nothing executes.
+	KillSwitchDryRun KillSwitchMode = "dry_run"
+)
+
+// AllOperations is the operation of a kill switch covering every
+// operation, which puts the executor in maintenance mode.
+const AllOperations = "*"
+
+// KillSwitch takes an operation out of service without changing the
+// contract. A switch on an operation takes precedence over one on
+// AllOperations.
+type KillSwitch struct {
+	Operation string         `json:"operation"`
+	Mode      KillSwitchMode `json:"mode"`
+	Reason    string         `json:"reason,omitempty"`
+	SetBy     string         `json:"set_by,omitempty"`
+	SetAt     time.Time      `json:"set_at"`
+}
+
+// SetKillSwitch sets, or replaces, the kill switch on s.Operation.
+func (e *Engine) SetKillSwitch(s KillSwitch) error {
+	if s.Operation == "" {
+		return fmt.Errorf("kill switch names no operation")
+	}
+	if s.Mode != KillSwitchDeny && s.Mode != KillSwitchDryRun {
+		return fmt.Errorf("kill switch mode %q is neither %s nor %s", s.Mode, KillSwitchDeny, KillSwitchDryRun)
+	}
+	if s.SetAt.IsZero() {
+		s.SetAt = e.now().UTC()
+	}
+	e.switchesMu.Lock()
+	defer e.switchesMu.Unlock()
+	if e.switches == nil {
+		e.switches = map[string]KillSwitch{}
+	}
+	e.switches[s.Operation] = s
+	return nil
+}
+
+// ClearKillSwitch puts operation back in service, reporting whether it had
+// a kill switch.
+func (e *Engine) ClearKillSwitch(operation string) bool {
+	e.switchesMu.Lock()
+	defer e.switchesMu.Unlock()
+	_, ok := e.switches[operation]
+	delete(e.switches, operation)
+	return ok
+}
+
+// KillSwitches lists the kill switches set, by operation.
+func (e *Engine) KillSwitches() []KillSwitch {
+	e.switchesMu.RLock()
+	defer e.switchesMu.RUnlock()
+	out := make([]KillSwitch, 0, len(e.switches))
+	for _, s := range e.switches {
+		out = append(out, s)
+	}
+	slices.SortFunc(out, func(a, b KillSwitch) int { return strings.Compare(a.Operation, b.Operation) })
+	return out
+}
+
+// killSwitch returns the kill switch applying to operation, if any.
+func (e *Engine) killSwitch(operation string) (KillSwitch, bool) {
+	e.switchesMu.RLock()
+	defer e.switchesMu.RUnlock()
+	if s, ok := e.switches[operation]; ok {
+		return s, true
+	}
+	s, ok := e.switches[AllOperations]
+	return s, ok
+}
+
+// switchedOff is the response to a request for an operation switched to
+// deny.
+func switchedOff(s KillSwitch, req *Request) *Response {
+	message := fmt.Sprintf("%s is temporarily unavailable", req.Operation)
+	if s.Reason != "" {
+		message += ": " + s.Reason
+	}
+	env := &ErrorEnvelope{
+		Code:       "TEMPORARILY_UNAVAILABLE",
+		Message:    message,
+		HttpStatus: http.StatusServiceUnavailable,
+		Category:   "system",
+		Retryable:  true,
+		Suggestion: "Retry once the operation is back in service",
+		Details:    map[string]any{"operation": req.Operation, "kill_switch": s.Operation, "since": s.SetAt},
+	}
+	if req.DryRun {
+		return &Response{DryRun: true, Outcome: OutcomeWouldDeny, Error: env, SideEffectsIsolated: true}
+	}
+	return &Response{Outcome: OutcomeDenied, Error: env}
+}
@@ -0,0 +1,65 @@
+package engine
+
+import (
+	"context"
+	"testing"
+)
+
+func TestEngine_killSwitches(t *testing.T) {
+	executed := 0
+	ports := &mockPorts{
+		executeFunc: func(_ context.Context, _, _ string, _ map[string]any) (map[string]any, error) {
+			executed++
+			return map[string]any{}, nil
+		},
+	}
+	e := NewEngine(ports, WithIdempotencyStore(newMemStore()))
+	e.LoadContract(makeMinimalContract(), "v1")
+	ctx := context.Background()
+	evaluate := func(dryRun bool) *Response {
+		t.Helper()
+		resp, err := e.Evaluate(ctx, &Request{Operation: "testOp", DryRun: dryRun, IdempotencyKey: "key_1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	if err := e.SetKillSwitch(KillSwitch{Operation: AllOperations, Mode: KillSwitchDeny, Reason: "maintenance"}); err != nil {
+		t.Fatal(err)
+	}
+	resp := evaluate(false)
+	if resp.Outcome != OutcomeDenied || resp.Error.Code != "TEMPORARILY_UNAVAILABLE" || !resp.Error.Retryable {
+		t.Errorf("expected a TEMPORARILY_UNAVAILABLE denial, got %s %+v", resp.Outcome, resp.Error)
+	}
+	if resp := evaluate(true); resp.Outcome != OutcomeWouldDeny {
+		t.Errorf("expected a dry run to be told it would be denied, got %s", resp.Outcome)
+	}
+
+	// A switch on the operation overrides maintenance mode.
+	e.SetKillSwitch(KillSwitch{Operation: "testOp", Mode: KillSwitchDryRun})
+	resp = evaluate(false)
+	if resp.Outcome != OutcomeWouldExecute || !resp.DryRun || !resp.ForcedDryRun || executed != 0 {
+		t.Errorf("expected a forced dry run, got %s %+v (executed %d)", resp.Outcome, resp, executed)
+	}
+
+	e.ClearKillSwitch("testOp")
+	e.ClearKillSwitch(AllOperations)
+	if len(e.KillSwitches()) != 0 {
+		t.Fatalf("expected no kill switches left, got %+v", e.KillSwitches())
+	}
+	// The idempotency key was not used up while the operation was off.
+	if resp := evaluate(false); resp.Outcome != OutcomeExecuted || resp.IdempotentReplay || executed != 1 {
+		t.Errorf("expected executed once back in service, got %s (executed %d)", resp.Outcome, executed)
+	}
+}
+
+func TestEngine_SetKillSwitch_rejectsUnknownModes(t *testing.T) {
+	e := NewEngine(&mockPorts{})
+	if err := e.SetKillSwitch(KillSwitch{Operation: "testOp", Mode: "off"}); err == nil {
+		t.Error("expected an error")
+	}
+	if err := e.SetKillSwitch(KillSwitch{Mode: KillSwitchDeny}); err == nil {
+		t.Error("expected an error without an operation")
+	}
+}
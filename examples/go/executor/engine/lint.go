@@ -0,0 +1,139 @@
+package engine
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+)
+
+// Lint rule IDs.
+const (
+	LintDenySuggestion          = "deny-suggestion"
+	LintClientErrorStatus       = "client-error-status"
+	LintEscalateQueueRegistered = "escalate-queue-registered"
+)
+
+// lintOff turns a lint rule off in LintConfig.Severity.
+const lintOff = "off"
+
+// LintConfig tunes the lint rules Validate applies, from a contract's lint
+// block. A rule can also suppress lint rules for itself with lint_ignore.
+type LintConfig struct {
+	// Severity overrides a lint rule's default severity by ID: "error",
+	// "warning", or "off".
+	Severity map[string]string `json:"severity,omitempty"`
+}
+
+// lintRule checks one rule of a contract, returning a message for each
+// problem.
+type lintRule struct {
+	id       string
+	severity string // default
+	check    func(c *Contract, r RuleDef) []string
+}
+
+// lintRules are the built-in lint rules, in the order they report.
+var lintRules = []lintRule{
+	{LintDenySuggestion, SeverityWarning, lintDenySuggestion},
+	{LintClientErrorStatus, SeverityError, lintClientErrorStatus},
+	{LintEscalateQueueRegistered, SeverityWarning, lintEscalateQueue},
+}
+
+// clientCategories are the error categories that blame the request, and so
+// call for a 4xx status.
+var clientCategories = []string{"validation", "business_rule_violation", "authorization"}
+
+// lintDenySuggestion requires a deny verdict's error to tell the caller
+// how to recover.
+func lintDenySuggestion(_ *Contract, r RuleDef) []string {
+	if d := r.Verdict.Deny; d != nil && d.Error.Suggestion == "" {
+		return []string{"deny verdict error has no suggestion"}
+	}
+	return nil
+}
+
+// lintClientErrorStatus requires a client-category deny error to carry a
+// 4xx status.
+func lintClientErrorStatus(_ *Contract, r RuleDef) []string {
+	d := r.Verdict.Deny
+	if d == nil || !slices.Contains(clientCategories, d.Error.Category) {
+		return nil
+	}
+	if s := d.Error.HttpStatus; s < http.StatusBadRequest || s >= http.StatusInternalServerError {
+		return []string{fmt.Sprintf("deny verdict error has category %q but http_status %d; client errors need a 4xx status", d.Error.Category, s)}
+	}
+	return nil
+}
+
+// lintEscalateQueue requires an escalate verdict to name a queue in the
+// queue catalog, directly or through its param's default and binding.
+// Loading enforces this for contracts that declare a catalog; the lint
+// flags escalations in contracts that have none.
+func lintEscalateQueue(c *Contract, r RuleDef) []string {
+	var msgs []string
+	for _, q := range c.escalationQueues(r, c.Bindings) {
+		if _, ok := c.Queues[q]; !ok {
+			msgs = append(msgs, fmt.Sprintf("escalate verdict names queue %q, which the queue catalog does not declare", q))
+		}
+	}
+	return msgs
+}
+
+// lint applies the lint rules to every rule of c, at the severities c's
+// lint block sets.
+func lint(c *Contract) []Diagnostic {
+	var diags []Diagnostic
+	severity := map[string]string{}
+	for _, lr := range lintRules {
+		severity[lr.id] = lr.severity
+	}
+	if c.Lint != nil {
+		for _, id := range slices.Sorted(maps.Keys(c.Lint.Severity)) {
+			s := c.Lint.Severity[id]
+			if _, ok := severity[id]; !ok {
+				diags = append(diags, Diagnostic{Severity: SeverityWarning, Message: fmt.Sprintf("lint: unknown lint rule %q", id)})
+				continue
+			}
+			if s != SeverityError && s != SeverityWarning && s != lintOff {
+				diags = append(diags, Diagnostic{Severity: SeverityError, Message: fmt.Sprintf("lint: severity of %q must be error, warning or off, got %q", id, s)})
+				continue
+			}
+			severity[id] = s
+		}
+	}
+
+	for _, r := range c.Rules {
+		for _, id := range r.LintIgnore {
+			if _, ok := severity[id]; !ok {
+				diags = append(diags, Diagnostic{Severity: SeverityWarning, Rule: r.ID, Message: fmt.Sprintf("lint_ignore names unknown lint rule %q", id)})
+			}
+		}
+		for _, lr := range lintRules {
+			if severity[lr.id] == lintOff || slices.Contains(r.LintIgnore, lr.id) {
+				continue
+			}
+			for _, msg := range lr.check(c, r) {
+				diags = append(diags, Diagnostic{Severity: severity[lr.id], Rule: r.ID, Lint: lr.id, Message: msg})
+			}
+		}
+	}
+	return diags
+}
+
+// mergeLint layers one contract's lint block over another's: its
+// severities replace those set before.
+func mergeLint(base, layer *LintConfig) *LintConfig {
+	if layer == nil {
+		return base
+	}
+	if base == nil {
+		return layer
+	}
+	out := &LintConfig{Severity: maps.Clone(base.Severity)}
+	if out.Severity == nil {
+		out.Severity = map[string]string{}
+	}
+	maps.Copy(out.Severity, layer.Severity)
+	return out
+}
@@ -0,0 +1,124 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func makeLintContract(rules ...RuleDef) *Contract {
+	c := makeMinimalContract()
+	c.Rules = rules
+	return c
+}
+
+func lintIDs(diags []Diagnostic) []string {
+	var ids []string
+	for _, d := range diags {
+		ids = append(ids, d.Severity+":"+d.Lint)
+	}
+	return ids
+}
+
+func TestLint_reportsEachRuleAtItsDefaultSeverity(t *testing.T) {
+	c := makeLintContract(
+		RuleDef{ID: "no-suggestion", Verdict: VerdictDef{Deny: &DenyVerdict{Code: "X", Error: ErrorEnvelope{
+			Code: "X", Category: "validation", HttpStatus: 400,
+		}}}},
+		RuleDef{ID: "server-status", Verdict: VerdictDef{Deny: &DenyVerdict{Code: "Y", Error: ErrorEnvelope{
+			Code: "Y", Category: "authorization", HttpStatus: 500, Suggestion: "Ask an admin",
+		}}}},
+		RuleDef{ID: "unknown-queue", Verdict: VerdictDef{Escalate: &EscalateVerdict{Queue: "fraud"}}},
+	)
+	c.Queues = map[string]QueueDef{"payment-review": {SLA: "4h", Owner: "payments-ops"}}
+
+	diags := lint(c)
+	got := strings.Join(lintIDs(diags), " ")
+	want := "warning:deny-suggestion error:client-error-status warning:escalate-queue-registered"
+	if got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+	if diags[1].Rule != "server-status" || !strings.Contains(diags[1].String(), "[client-error-status]") {
+		t.Errorf("unexpected diagnostic %s", diags[1])
+	}
+}
+
+func TestLint_severityOverridesAndLintIgnore(t *testing.T) {
+	deny := VerdictDef{Deny: &DenyVerdict{Code: "X", Error: ErrorEnvelope{Code: "X", Category: "validation", HttpStatus: 500}}}
+	c := makeLintContract(
+		RuleDef{ID: "a", Verdict: deny},
+		RuleDef{ID: "b", Verdict: deny, LintIgnore: []string{LintClientErrorStatus}},
+	)
+	c.Lint = &LintConfig{Severity: map[string]string{
+		LintDenySuggestion:    lintOff,
+		LintClientErrorStatus: SeverityWarning,
+	}}
+
+	diags := lint(c)
+	if len(diags) != 1 || diags[0].Rule != "a" || diags[0].Severity != SeverityWarning || diags[0].Lint != LintClientErrorStatus {
+		t.Errorf("expected one client-error-status warning for rule a, got %v", diags)
+	}
+}
+
+func TestLint_flagsUnknownIDsAndBadSeverities(t *testing.T) {
+	c := makeLintContract(RuleDef{ID: "r", Verdict: VerdictDef{Flag: &FlagVerdict{}}, LintIgnore: []string{"no-such-lint"}})
+	c.Lint = &LintConfig{Severity: map[string]string{
+		"typo-lint":        SeverityError,
+		LintDenySuggestion: "fatal",
+	}}
+
+	var msgs []string
+	for _, d := range lint(c) {
+		msgs = append(msgs, d.Severity+": "+d.Message)
+	}
+	want := []string{
+		`error: lint: severity of "deny-suggestion" must be error, warning or off, got "fatal"`,
+		`warning: lint: unknown lint rule "typo-lint"`,
+		`warning: lint_ignore names unknown lint rule "no-such-lint"`,
+	}
+	if strings.Join(msgs, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got %q, want %q", msgs, want)
+	}
+}
+
+func TestLint_escalateQueueResolvesParam(t *testing.T) {
+	c := makeLintContract(RuleDef{ID: "r", Verdict: VerdictDef{Escalate: &EscalateVerdict{QueueParam: "review_queue"}}})
+	c.Params = map[string]ParamDef{"review_queue": {Default: "payment-review"}}
+	c.Queues = map[string]QueueDef{"payment-review": {SLA: "4h", Owner: "payments-ops"}}
+
+	if diags := lint(c); len(diags) != 0 {
+		t.Fatalf("expected the default queue to be in the catalog, got %v", diags)
+	}
+	c.Bindings = map[string]any{"review_queue": "fraud"}
+	if diags := lint(c); len(diags) != 1 || !strings.Contains(diags[0].Message, `"fraud"`) {
+		t.Errorf("expected the bound queue flagged, got %v", diags)
+	}
+
+	c.Queues = nil
+	if diags := lint(c); len(diags) != 2 {
+		t.Errorf("expected both queues flagged without a catalog, got %v", diags)
+	}
+}
+
+func TestCompileSources_lintBlockMergesWithImports(t *testing.T) {
+	c, err := CompileSources(map[string][]byte{
+		"shop/contract.cue": []byte(`imports: ["common"]
+lint: severity: "deny-suggestion": "error"
+operations: op: {constrained_by: [], transitions: []}
+`),
+	}, func(name string) (map[string][]byte, error) {
+		return map[string][]byte{name + "/base.cue": []byte(`lint: severity: {"deny-suggestion": "off", "client-error-status": "warning"}
+`)}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Lint.Severity; got[LintDenySuggestion] != SeverityError || got[LintClientErrorStatus] != SeverityWarning {
+		t.Errorf("unexpected severities %v", got)
+	}
+	for _, d := range Validate(c, time.Now()) {
+		if d.Lint != "" {
+			t.Errorf("unexpected lint diagnostic %s", d)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sync"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+)
+
+// Loader loads contracts incrementally. It keeps the files it has fetched
+// and compiled, keyed by checksum, and the unified value of every prefix of
+// the file lists it last compiled. Reloading a contract in which one file
+// changed then fetches and compiles only that file, and unifies only from
+// it onward; a file a contract server's manifest lists under an unchanged
+// checksum is not fetched at all. Files are compiled in order, so changes
+// to the last files in a contract are cheapest.
+//
+// Loaders keep only what their last successful load, and any failed loads
+// since, used. They are safe for
+// concurrent use; loads are serialized.
+type Loader struct {
+	mu    sync.Mutex
+	cache *compileCache
+	last  LoadStats
+}
+
+// LoadStats counts the work a load did and the work it reused, over the
+// domain contract and its bases.
+type LoadStats struct {
+	Files    int `json:"files"`
+	Fetched  int `json:"fetched"`
+	Compiled int `json:"compiled"`
+	Unified  int `json:"unified"`
+}
+
+func NewLoader() *Loader {
+	return &Loader{cache: newCompileCache()}
+}
+
+// LoadContract is the package-level LoadContract, reusing earlier loads.
+func (l *Loader) LoadContract(serverURL string, disc *Discovery) (*Contract, error) {
+	return l.LoadContractFiles(serverURL, disc.Contracts)
+}
+
+// LoadContractFiles is the package-level LoadContractFiles, reusing earlier
+// loads.
+func (l *Loader) LoadContractFiles(serverURL string, cf ContractFiles) (*Contract, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	c, err := loadContractFiles(l.cache, serverURL, cf)
+	l.finish(err)
+	return c, err
+}
+
+// CompileSources is the package-level CompileSources, reusing earlier
+// compilations.
+func (l *Loader) CompileSources(files map[string][]byte, load func(name string) (map[string][]byte, error)) (*Contract, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	c, err := compileSources(l.cache, files, load)
+	l.finish(err)
+	return c, err
+}
+
+// LastLoad returns the stats of the most recent load.
+func (l *Loader) LastLoad() LoadStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.last
+}
+
+// finish records a load's stats. A successful load then drops what it did
+// not use; a failed one keeps it, since the next load is likely to return
+// to the contract that last loaded.
+func (l *Loader) finish(err error) {
+	l.last = l.cache.stats
+	l.cache.stats = LoadStats{}
+	if err == nil {
+		l.cache.sweep()
+	}
+}
+
+// fileKey identifies a compiled file. The path is part of it because CUE
+// positions, and so error messages, name the file.
+type fileKey struct {
+	path, sum string
+}
+
+// unifyChain is the last file list compiled as one file set: values[i] is
+// the unification of keys[:i+1].
+type unifyChain struct {
+	keys   []fileKey
+	values []cue.Value
+}
+
+// compileCache holds the fetched and compiled sources of recent loads. Its values all belong to one CUE context, so they can be
+// unified with each other.
+type compileCache struct {
+	ctx    *cue.Context
+	files  map[string][]byte // fetched contents, by checksum
+	values map[fileKey]cue.Value
+	chains map[string]*unifyChain // by file set: the domain, or an import
+
+	// Checksums and file sets used since the last sweep.
+	usedSums, usedSets map[string]bool
+	stats              LoadStats
+}
+
+func newCompileCache() *compileCache {
+	return &compileCache{
+		ctx:      cuecontext.New(),
+		files:    map[string][]byte{},
+		values:   map[fileKey]cue.Value{},
+		chains:   map[string]*unifyChain{},
+		usedSums: map[string]bool{},
+		usedSets: map[string]bool{},
+	}
+}
+
+// compile compiles and unifies files in order as the named file set, then
+// extracts a Contract from the unified value. It fails with a
+// *ContractError.
+func (cc *compileCache) compile(set string, files []sourceFile) (*Contract, error) {
+	c, err := cc.compileUnified(set, files)
+	if err != nil {
+		return nil, &ContractError{Errors: cueDiagnostics(err), err: err}
+	}
+	return c, nil
+}
+
+func (cc *compileCache) compileUnified(set string, files []sourceFile) (*Contract, error) {
+	unified, err := cc.unify(set, files)
+	if err != nil {
+		return nil, err
+	}
+	if !unified.Exists() {
+		return nil, fmt.Errorf("no contract files loaded")
+	}
+	if unified.Err() != nil {
+		return nil, fmt.Errorf("unified contract error: %w", unified.Err())
+	}
+	return extractContract(unified)
+}
+
+// unify returns the unification of files, reusing the set's last
+// unification up to the first file that differs and any file compiled
+// before.
+func (cc *compileCache) unify(set string, files []sourceFile) (cue.Value, error) {
+	cc.usedSets[set] = true
+	cc.stats.Files += len(files)
+	ch := cc.chains[set]
+	if ch == nil {
+		ch = &unifyChain{}
+		cc.chains[set] = ch
+	}
+
+	keys := make([]fileKey, len(files))
+	for i, f := range files {
+		keys[i] = fileKey{path: f.path, sum: checksum(f.data)}
+		cc.usedSums[keys[i].sum] = true
+	}
+	same := 0
+	for same < len(keys) && same < len(ch.keys) && keys[same] == ch.keys[same] {
+		same++
+	}
+	ch.keys, ch.values = ch.keys[:same], ch.values[:same]
+
+	for i := same; i < len(files); i++ {
+		v, ok := cc.values[keys[i]]
+		if !ok {
+			v = cc.ctx.CompileBytes(files[i].data, cue.Filename(files[i].path))
+			if v.Err() != nil {
+				return cue.Value{}, fmt.Errorf("compile %s: %w", files[i].path, v.Err())
+			}
+			cc.values[keys[i]] = v
+			cc.stats.Compiled++
+		}
+		if i > 0 {
+			v = ch.values[i-1].Unify(v)
+		}
+		ch.keys, ch.values = append(ch.keys, keys[i]), append(ch.values, v)
+		cc.stats.Unified++
+	}
+	if len(ch.values) == 0 {
+		return cue.Value{}, nil
+	}
+	return ch.values[len(ch.values)-1], nil
+}
+
+// fetched returns the contents of a file the manifest lists under sum, if an
+// earlier load fetched it.
+func (cc *compileCache) fetched(sum string) ([]byte, bool) {
+	data, ok := cc.files[sum]
+	if ok {
+		cc.usedSums[sum] = true
+	}
+	return data, ok
+}
+
+func (cc *compileCache) store(sum string, data []byte) {
+	cc.files[sum] = data
+	cc.usedSums[sum] = true
+	cc.stats.Fetched++
+}
+
+// sweep drops what was not used since the last sweep.
+func (cc *compileCache) sweep() {
+	for sum := range cc.files {
+		if !cc.usedSums[sum] {
+			delete(cc.files, sum)
+		}
+	}
+	for k := range cc.values {
+		if !cc.usedSums[k.sum] {
+			delete(cc.values, k)
+		}
+	}
+	for set := range cc.chains {
+		if !cc.usedSets[set] {
+			delete(cc.chains, set)
+		}
+	}
+	cc.usedSums, cc.usedSets = map[string]bool{}, map[string]bool{}
+}
+
+// checksum is the hex SHA-256 of data, as in contract manifests.
+func checksum(data []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
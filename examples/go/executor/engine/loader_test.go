@@ -0,0 +1,206 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// contractTree returns a contract split over n files, each declaring a fact
+// and an operation flagged on it.
+func contractTree(n int) map[string][]byte {
+	files := map[string][]byte{}
+	for i := range n {
+		files[fmt.Sprintf("f%03d.cue", i)] = []byte(fmt.Sprintf(`
+facts: "f%[1]d.score": {source: "input"}
+derived_facts: "f%[1]d.high": derivation: {fn: "greater_than", args: [{fact: "f%[1]d.score"}, {value: 50}]}
+operations: "Op%[1]d": {
+	constrained_by: []
+	transitions: []
+}
+`, i))
+	}
+	return files
+}
+
+// serveTree serves files as a contract server would, counting fetches.
+func serveTree(t testing.TB, files map[string][]byte) (*httptest.Server, *sync.Map) {
+	var fetches sync.Map
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := fetches.LoadOrStore(r.URL.Path, 0)
+		fetches.Store(r.URL.Path, n.(int)+1)
+		w.Write(files[strings.TrimPrefix(r.URL.Path, "/contracts/shop/")])
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &fetches
+}
+
+// treeFiles lists files in a manifest.
+func treeFiles(files map[string][]byte) ContractFiles {
+	cf := ContractFiles{Checksums: map[string]string{}}
+	for _, f := range sortedSources(files) {
+		url := "/contracts/shop/" + f.path
+		cf.Files = append(cf.Files, url)
+		cf.Checksums[url] = checksum(f.data)
+	}
+	cf.ETag = ManifestETag(cf.Checksums)
+	return cf
+}
+
+func TestLoader_LoadContractFiles_refetchesAndRecompilesOnlyChangedFiles(t *testing.T) {
+	files := contractTree(20)
+	srv, fetches := serveTree(t, files)
+	l := NewLoader()
+
+	if _, err := l.LoadContractFiles(srv.URL, treeFiles(files)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := l.LastLoad(), (LoadStats{Files: 20, Fetched: 20, Compiled: 20, Unified: 20}); got != want {
+		t.Errorf("first load: got %+v, want %+v", got, want)
+	}
+
+	files["f015.cue"] = []byte(`
+facts: "f15.score": {source: "input"}
+operations: Op15: {constrained_by: [], transitions: []}
+`)
+	c, err := l.LoadContractFiles(srv.URL, treeFiles(files))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := l.LastLoad(), (LoadStats{Files: 20, Fetched: 1, Compiled: 1, Unified: 5}); got != want {
+		t.Errorf("reload: got %+v, want %+v", got, want)
+	}
+	if n, _ := fetches.Load("/contracts/shop/f015.cue"); n != 2 {
+		t.Errorf("expected the changed file fetched again, got %v fetches", n)
+	}
+	if n, _ := fetches.Load("/contracts/shop/f014.cue"); n != 1 {
+		t.Errorf("expected an unchanged file fetched once, got %v fetches", n)
+	}
+	if _, ok := c.DerivedFacts["f15.high"]; ok || len(c.Operations) != 20 {
+		t.Errorf("expected the change applied, got %d operations", len(c.Operations))
+	}
+	full, err := LoadContractFiles(srv.URL, treeFiles(files))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c, full) {
+		t.Error("incremental load differs from a full load")
+	}
+}
+
+func TestLoader_CompileSources_reportsErrorsAndRecovers(t *testing.T) {
+	files := contractTree(5)
+	l := NewLoader()
+	if _, err := l.CompileSources(files, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	good := files["f002.cue"]
+	files["f002.cue"] = []byte(`facts: {`)
+	var cerr *ContractError
+	if _, err := l.CompileSources(files, nil); !errors.As(err, &cerr) || cerr.Errors[0].File != "f002.cue" {
+		t.Fatalf("expected a ContractError in f002.cue, got %v", err)
+	}
+
+	files["f002.cue"] = good
+	if _, err := l.CompileSources(files, nil); err != nil {
+		t.Fatalf("expected the reverted contract to load, got %v", err)
+	}
+	if got := l.LastLoad(); got.Compiled != 0 || got.Unified != 3 {
+		t.Errorf("expected the reverted file reused, got %+v", got)
+	}
+}
+
+func TestLoader_CompileSources_reusesImportedBases(t *testing.T) {
+	base := map[string][]byte{"base.cue": []byte(`facts: "customer.status": {source: "input"}`)}
+	domain := map[string][]byte{"d.cue": []byte(`
+imports: ["common"]
+operations: Pay: {constrained_by: [], transitions: []}
+`)}
+	load := func(name string) (map[string][]byte, error) { return base, nil }
+	l := NewLoader()
+	if _, err := l.CompileSources(domain, load); err != nil {
+		t.Fatal(err)
+	}
+	domain["d.cue"] = append(domain["d.cue"], "operations: Refund: {constrained_by: [], transitions: []}\n"...)
+	c, err := l.CompileSources(domain, load)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := l.LastLoad(); got.Files != 2 || got.Compiled != 1 {
+		t.Errorf("expected only the domain recompiled, got %+v", got)
+	}
+	if _, ok := c.Facts["customer.status"]; !ok || len(c.Operations) != 2 {
+		t.Errorf("expected the base composed in, got %+v", c)
+	}
+}
+
+// The benchmarks load a 200-file contract tree in which one file changed
+// since the last load: the first file, the worst case for reuse, or the
+// last.
+
+func BenchmarkLoadContractFiles_200Files(b *testing.B) {
+	files := contractTree(200)
+	srv, _ := serveTree(b, files)
+	cf := treeFiles(files)
+	for b.Loop() {
+		if _, err := LoadContractFiles(srv.URL, cf); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLoader_LoadContractFiles_200FilesFirstChanged(b *testing.B) {
+	benchmarkReload(b, "f000.cue")
+}
+
+func BenchmarkLoader_LoadContractFiles_200FilesLastChanged(b *testing.B) {
+	benchmarkReload(b, "f199.cue")
+}
+
+func benchmarkReload(b *testing.B, changed string) {
+	files := contractTree(200)
+	srv, _ := serveTree(b, files)
+	original := files[changed]
+	edited := append(append([]byte{}, original...), "// edited\n"...)
+	l := NewLoader()
+	for i := 0; b.Loop(); i++ {
+		files[changed] = original
+		if i%2 == 1 {
+			files[changed] = edited
+		}
+		if _, err := l.LoadContractFiles(srv.URL, treeFiles(files)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCompileSources_200Files(b *testing.B) {
+	files := contractTree(200)
+	for b.Loop() {
+		if _, err := CompileSources(files, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLoader_CompileSources_200FilesLastChanged(b *testing.B) {
+	files := contractTree(200)
+	original := files["f199.cue"]
+	edited := append(append([]byte{}, original...), "// edited\n"...)
+	l := NewLoader()
+	for i := 0; b.Loop(); i++ {
+		files["f199.cue"] = original
+		if i%2 == 1 {
+			files["f199.cue"] = edited
+		}
+		if _, err := l.CompileSources(files, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package engine
+
+import "os"
+
+// mapFile reads path, on platforms without mmap.
+func mapFile(path string) (data []byte, unmap func(), err error) {
+	data, err = os.ReadFile(path)
+	return data, func() {}, err
+}
@@ -0,0 +1,101 @@
+package engine
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Outcome is the result of evaluating a request, as reported in
+// Response.Outcome.
+type Outcome string
+
+// Outcomes of live requests.
+const (
+	OutcomeExecuted    Outcome = "executed"     // the operation ran; side effects occurred
+	OutcomeDenied      Outcome = "denied"       // a deny verdict, authorization or policy refused it
+	OutcomeEscalated   Outcome = "escalated"    // an escalate verdict queued it for review
+	OutcomeRequired    Outcome = "required"     // a require verdict asks for more before it can run
+	OutcomeSystemError Outcome = "system_error" // a fact, port or store failed
+	// OutcomeStateConflict means an entity the operation transitions moved
+	// while the request was evaluated; see Engine.Evaluate.
+	OutcomeStateConflict Outcome = "state_conflict"
+	// OutcomeThrottled means the request's priority lane was full; see
+	// Scheduler.
+	OutcomeThrottled Outcome = "throttled"
+	// OutcomePortContractViolation means a port's payload did not match its
+	// schema; see PortContractViolation.
+	OutcomePortContractViolation Outcome = "port_contract_violation"
+)
+
+// Outcomes of dry-runs and simulations, which never have side effects.
+const (
+	OutcomeWouldExecute          Outcome = "would_execute"
+	OutcomeWouldExecuteWithFlags Outcome = "would_execute_with_flags"
+	OutcomeWouldDeny             Outcome = "would_deny"
+	OutcomeWouldEscalate         Outcome = "would_escalate"
+	OutcomeWouldRequire          Outcome = "would_require"
+)
+
+// Outcomes lists every outcome, live then dry-run.
+var Outcomes = []Outcome{
+	OutcomeExecuted, OutcomeDenied, OutcomeEscalated, OutcomeRequired,
+	OutcomeSystemError, OutcomeStateConflict, OutcomeThrottled, OutcomePortContractViolation,
+	OutcomeWouldExecute, OutcomeWouldExecuteWithFlags, OutcomeWouldDeny,
+	OutcomeWouldEscalate, OutcomeWouldRequire,
+}
+
+// ParseOutcome returns the outcome named s, or an error if there is none.
+func ParseOutcome(s string) (Outcome, error) {
+	if o := Outcome(s); o.Valid() {
+		return o, nil
+	}
+	return "", fmt.Errorf("unknown outcome %q", s)
+}
+
+// Valid reports whether o is one of Outcomes.
+func (o Outcome) Valid() bool {
+	return slices.Contains(Outcomes, o)
+}
+
+// IsDryRun reports whether o is the outcome of a dry-run or simulation.
+func (o Outcome) IsDryRun() bool {
+	switch o {
+	case OutcomeWouldExecute, OutcomeWouldExecuteWithFlags, OutcomeWouldDeny,
+		OutcomeWouldEscalate, OutcomeWouldRequire:
+		return true
+	}
+	return false
+}
+
+// WouldProceed reports whether the operation ran, or for a dry-run would
+// have run.
+func (o Outcome) WouldProceed() bool {
+	return o == OutcomeExecuted || o == OutcomeWouldExecute || o == OutcomeWouldExecuteWithFlags
+}
+
+// IsDryRun reports whether r answers a dry-run or simulation.
+func (r *Response) IsDryRun() bool {
+	return r.Outcome.IsDryRun()
+}
+
+// WouldProceed reports whether the operation ran, or for a dry-run would
+// have run.
+func (r *Response) WouldProceed() bool {
+	return r.Outcome.WouldProceed()
+}
+
+// IsTerminal reports whether r is final for its request. Executions,
+// denials and dry-runs are; escalations and requirements await a reviewer
+// or more input; and errors, throttling, state conflicts and port contract
+// violations are final only when their error is not retryable.
+func (r *Response) IsTerminal() bool {
+	switch r.Outcome {
+	case OutcomeExecuted, OutcomeDenied:
+		return true
+	case OutcomeEscalated, OutcomeRequired:
+		return false
+	case OutcomeSystemError, OutcomeStateConflict, OutcomeThrottled, OutcomePortContractViolation:
+		return r.Error == nil || !r.Error.Retryable
+	}
+	return r.Outcome.IsDryRun()
+}
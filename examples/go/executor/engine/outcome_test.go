@@ -0,0 +1,37 @@
+package engine
+
+import "testing"
+
+func TestParseOutcome(t *testing.T) {
+	for _, o := range Outcomes {
+		if got, err := ParseOutcome(string(o)); err != nil || got != o {
+			t.Errorf("%s: got %q, %v", o, got, err)
+		}
+	}
+	if _, err := ParseOutcome("would_maybe"); err == nil {
+		t.Error("expected an unknown outcome rejected")
+	}
+}
+
+func TestResponse_predicates(t *testing.T) {
+	retryable := &ErrorEnvelope{Retryable: true}
+	tests := []struct {
+		resp                           Response
+		dryRun, terminal, wouldProceed bool
+	}{
+		{Response{Outcome: OutcomeExecuted}, false, true, true},
+		{Response{Outcome: OutcomeDenied}, false, true, false},
+		{Response{Outcome: OutcomeEscalated}, false, false, false},
+		{Response{Outcome: OutcomeSystemError, Error: retryable}, false, false, false},
+		{Response{Outcome: OutcomeSystemError, Error: &ErrorEnvelope{}}, false, true, false},
+		{Response{Outcome: OutcomeThrottled, Error: retryable}, false, false, false},
+		{Response{Outcome: OutcomeWouldExecuteWithFlags}, true, true, true},
+		{Response{Outcome: OutcomeWouldDeny}, true, true, false},
+	}
+	for _, tt := range tests {
+		r := &tt.resp
+		if r.IsDryRun() != tt.dryRun || r.IsTerminal() != tt.terminal || r.WouldProceed() != tt.wouldProceed {
+			t.Errorf("%s: got dry-run %v, terminal %v, would proceed %v", r.Outcome, r.IsDryRun(), r.IsTerminal(), r.WouldProceed())
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package engine
+
+import (
+	"runtime"
+	"sync"
+)
+
+// Rule conditions are evaluated in parallel once an operation has at least
+// parallelRuleThreshold rules in effect; below it, the goroutines cost more
+// than they save. Each worker takes a contiguous run of at least
+// minRulesPerWorker rules.
+const (
+	parallelRuleThreshold = 128
+	minRulesPerWorker     = 32
+)
+
+// ruleWorkers returns how many workers evaluate n rules' conditions.
+func ruleWorkers(n int) int {
+	if n < parallelRuleThreshold {
+		return 1
+	}
+	return max(1, min(runtime.GOMAXPROCS(0), n/minRulesPerWorker))
+}
+
+// matchRules reports which rules' conditions hold, by position, splitting
+// the rules between workers. Conditions only read facts, so they can be
+// evaluated in any order; verdicts are built from the result in rule order,
+// so they come out as they would serially.
+func matchRules(rules []*RuleDef, facts *FactSet, workers int) []bool {
+	matched := make([]bool, len(rules))
+	if workers <= 1 {
+		for i, rule := range rules {
+			matched[i] = evalCondition(rule.When, facts)
+		}
+		return matched
+	}
+
+	chunk := (len(rules) + workers - 1) / workers
+	var wg sync.WaitGroup
+	for start := 0; start < len(rules); start += chunk {
+		end := min(start+chunk, len(rules))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := start; i < end; i++ {
+				matched[i] = evalCondition(rules[i].When, facts)
+			}
+		}()
+	}
+	wg.Wait()
+	return matched
+}
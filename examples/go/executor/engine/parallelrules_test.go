@@ -0,0 +1,112 @@
+package engine
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// wideContract constrains testOp by n rules, each flagging when a score
+// exceeds its threshold and the customer's status is one of a few.
+func wideContract(n int) *Contract {
+	c := makeMinimalContract()
+	var ids []string
+	for i := range n {
+		score := fmt.Sprintf("score.%d", i%10)
+		c.Facts[score] = FactDef{Source: "input"}
+		id := fmt.Sprintf("r%03d", i)
+		ids = append(ids, id)
+		c.Rules = append(c.Rules, RuleDef{
+			ID: id,
+			When: Condition{All: []Condition{
+				{Fact: score, GreaterThan: float64(i % 100)},
+				{Fact: "customer.status", In: []any{"active", "trial", "past_due"}},
+			}},
+			Verdict: VerdictDef{Flag: &FlagVerdict{Code: id}},
+		})
+	}
+	c.Facts["customer.status"] = FactDef{Source: "input"}
+	c.Operations["testOp"] = OperationDef{ConstrainedBy: ids}
+	return c
+}
+
+func wideFacts() *FactSet {
+	facts := NewFactSet()
+	for i := range 10 {
+		facts.Set(fmt.Sprintf("score.%d", i), float64(i*10+5))
+	}
+	facts.Set("customer.status", "trial")
+	return facts
+}
+
+func ruleRefs(c *Contract) []*RuleDef {
+	rules := make([]*RuleDef, len(c.Rules))
+	for i := range c.Rules {
+		rules[i] = &c.Rules[i]
+	}
+	return rules
+}
+
+func TestMatchRules_parallelAgreesWithSerial(t *testing.T) {
+	rules, facts := ruleRefs(wideContract(500)), wideFacts()
+	serial := matchRules(rules, facts, 1)
+	for _, workers := range []int{2, 3, 7, 16} {
+		if got := matchRules(rules, facts, workers); !reflect.DeepEqual(got, serial) {
+			t.Errorf("%d workers disagree with serial evaluation", workers)
+		}
+	}
+}
+
+func TestEvaluateRules_wideRuleSetKeepsRuleOrder(t *testing.T) {
+	c := wideContract(500)
+	e := NewEngine(&mockPorts{})
+	verdicts := e.evaluateRules(c, "testOp", wideFacts(), time.Now())
+
+	var want []string
+	for i, rule := range c.Rules {
+		if score := float64((i%10)*10 + 5); score > float64(i%100) {
+			want = append(want, rule.ID)
+		}
+	}
+	var got []string
+	for _, v := range verdicts {
+		got = append(got, v.Code)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %d verdicts out of order or wrong, want %d", len(got), len(want))
+	}
+}
+
+func TestRuleWorkers_smallRuleSetsStaySerial(t *testing.T) {
+	if n := ruleWorkers(parallelRuleThreshold - 1); n != 1 {
+		t.Errorf("expected a small rule set evaluated serially, got %d workers", n)
+	}
+}
+
+// The benchmarks match 500 rules serially and over the worker pool the
+// engine would use.
+
+func BenchmarkMatchRules_500Serial(b *testing.B) {
+	rules, facts := ruleRefs(wideContract(500)), wideFacts()
+	for b.Loop() {
+		matchRules(rules, facts, 1)
+	}
+}
+
+func BenchmarkMatchRules_500Parallel(b *testing.B) {
+	rules, facts := ruleRefs(wideContract(500)), wideFacts()
+	workers := ruleWorkers(len(rules))
+	for b.Loop() {
+		matchRules(rules, facts, workers)
+	}
+}
+
+func BenchmarkEvaluateRules_500Rules(b *testing.B) {
+	c, facts := wideContract(500), wideFacts()
+	e := NewEngine(&mockPorts{})
+	now := time.Now()
+	for b.Loop() {
+		e.evaluateRules(c, "testOp", facts, now)
+	}
+}
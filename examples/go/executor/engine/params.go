@@ -0,0 +1,150 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// paramFactPrefix namespaces parameters in the fact set: parameter
+// "large_payment_threshold" is readable as fact "params.large_payment_threshold".
+const paramFactPrefix = "params."
+
+// Bind attaches per-environment parameter values to the contract. Values
+// must name declared params; params without a default must be bound; and
+// every {param: name} reference in the rules and authorize blocks must be
+// declared; and a queue bound for an escalation must be in the queue
+// catalog. Bind must be called before the contract is loaded into an Engine.
+func (c *Contract) Bind(env string, values map[string]any) error {
+	for name := range values {
+		if _, ok := c.Params[name]; !ok {
+			return fmt.Errorf("binding %q: no such param", name)
+		}
+	}
+	var unbound []string
+	for name, def := range c.Params {
+		if _, ok := values[name]; !ok && def.Default == nil {
+			unbound = append(unbound, name)
+		}
+	}
+	if len(unbound) > 0 {
+		sort.Strings(unbound)
+		return fmt.Errorf("params without default must be bound for environment %q: %s", env, strings.Join(unbound, ", "))
+	}
+	for _, rule := range c.Rules {
+		for _, name := range ruleParamRefs(rule) {
+			if _, ok := c.Params[name]; !ok {
+				return fmt.Errorf("rule %q references undeclared param %q", rule.ID, name)
+			}
+		}
+	}
+	for _, name := range sortedOperations(c) {
+		if auth := c.Operations[name].Authorize; auth != nil {
+			var undeclared []string
+			collectParamRefs(auth.Requires, func(param string) {
+				if _, ok := c.Params[param]; !ok {
+					undeclared = append(undeclared, param)
+				}
+			})
+			if len(undeclared) > 0 {
+				return fmt.Errorf("operation %q authorize references undeclared param %q", name, undeclared[0])
+			}
+		}
+	}
+	if err := checkQueueRefs(c, values); err != nil {
+		return fmt.Errorf("environment %q: %w", env, err)
+	}
+	c.Environment = env
+	c.Bindings = values
+	return nil
+}
+
+// ParamValue returns the value param name resolves to: its binding for the
+// contract's environment if any, otherwise its default.
+func (c *Contract) ParamValue(name string) (any, bool) {
+	if v, ok := c.Bindings[name]; ok {
+		return v, true
+	}
+	def, ok := c.Params[name]
+	if !ok || def.Default == nil {
+		return nil, false
+	}
+	return def.Default, true
+}
+
+// ResolveOperand returns v, or the value of the param it references if v is
+// a {param: name} operand.
+func (c *Contract) ResolveOperand(v any) any {
+	if name, ok := paramRef(v); ok {
+		pv, _ := c.ParamValue(name)
+		return pv
+	}
+	return v
+}
+
+// LoadBindings reads a JSON object of param values from path.
+func LoadBindings(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]any
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("parse bindings %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// paramRef reports whether an operand is a {param: name} reference.
+func paramRef(v any) (string, bool) {
+	m, ok := v.(map[string]any)
+	if !ok || len(m) != 1 {
+		return "", false
+	}
+	name, ok := m["param"].(string)
+	return name, ok && name != ""
+}
+
+// resolveOperand replaces a {param: name} operand with the param's value in
+// the fact set, so simulations can supply their own parameter values.
+func resolveOperand(v any, facts *FactSet) any {
+	if name, ok := paramRef(v); ok {
+		val, _ := facts.Get(paramFactPrefix + name)
+		return val
+	}
+	return v
+}
+
+// ruleParamRefs lists the params a rule's condition operands and verdict
+// reference.
+func ruleParamRefs(rule RuleDef) []string {
+	var refs []string
+	collectParamRefs(rule.When, func(name string) { refs = append(refs, name) })
+	if rule.Verdict.Escalate != nil && rule.Verdict.Escalate.QueueParam != "" {
+		refs = append(refs, rule.Verdict.Escalate.QueueParam)
+	}
+	return refs
+}
+
+func collectParamRefs(cond Condition, collect func(string)) {
+	operands := []any{cond.Equals, cond.GreaterThan, cond.LessThan, cond.Contains, cond.Before, cond.After, cond.HasRole, cond.HasScope, cond.SubjectMatches, cond.TenantEquals}
+	for _, list := range [][]any{cond.In, cond.Between, cond.SubsetOf, cond.Intersects, cond.ContainsAll} {
+		operands = append(operands, list...)
+	}
+	for _, v := range operands {
+		if name, ok := paramRef(v); ok {
+			collect(name)
+		}
+	}
+	for _, sub := range cond.All {
+		collectParamRefs(sub, collect)
+	}
+	for _, sub := range cond.Any {
+		collectParamRefs(sub, collect)
+	}
+	if cond.Not != nil {
+		collectParamRefs(*cond.Not, collect)
+	}
+}
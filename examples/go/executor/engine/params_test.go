@@ -0,0 +1,171 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// makeParamContract flags testOp when amount exceeds the "limit" param.
+func makeParamContract() *Contract {
+	c := makeSimpleContract("over-limit",
+		VerdictDef{Flag: &FlagVerdict{Code: "OVER_LIMIT"}},
+		Condition{Fact: "amount", GreaterThan: map[string]any{"param": "limit"}},
+	)
+	c.Facts["amount"] = FactDef{Source: "input", Required: true}
+	c.Params = map[string]ParamDef{"limit": {Default: 100.0}}
+	c.Facts["params.limit"] = FactDef{Source: "param", OnMissing: "skip"}
+	return c
+}
+
+func TestEngine_Evaluate_paramOperandUsesDefault(t *testing.T) {
+	e := NewEngine(&mockPorts{})
+	e.LoadContract(makeParamContract(), "v1")
+
+	resp, err := e.Evaluate(context.Background(), &Request{
+		Operation: "testOp",
+		Input:     map[string]any{"amount": 150.0},
+		DryRun:    true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Outcome != "would_execute_with_flags" {
+		t.Errorf("expected would_execute_with_flags, got %s", resp.Outcome)
+	}
+}
+
+func TestEngine_Evaluate_paramOperandUsesBinding(t *testing.T) {
+	c := makeParamContract()
+	if err := c.Bind("prod", map[string]any{"limit": 1000.0}); err != nil {
+		t.Fatalf("bind: %v", err)
+	}
+	e := NewEngine(&mockPorts{})
+	e.LoadContract(c, "v1")
+
+	resp, err := e.Evaluate(context.Background(), &Request{
+		Operation: "testOp",
+		Input:     map[string]any{"amount": 150.0},
+		DryRun:    true,
+		Explain:   true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Outcome != "would_execute" {
+		t.Errorf("expected would_execute, got %s", resp.Outcome)
+	}
+
+	ex := resp.Explain
+	if ex.Environment != "prod" {
+		t.Errorf("expected environment prod, got %q", ex.Environment)
+	}
+	if got := ex.Params["limit"]; got.Value != 1000.0 || got.Source != "binding" {
+		t.Errorf("expected limit=1000 from binding, got %+v", got)
+	}
+	leaf := ex.Rules[0].When
+	if leaf.Param != "limit" || leaf.Expected != 1000.0 {
+		t.Errorf("expected trace to show resolved param, got %+v", leaf)
+	}
+}
+
+func TestEngine_Simulate_suppliedParamOverridesBinding(t *testing.T) {
+	e := NewEngine(&mockPorts{})
+	e.LoadContract(makeParamContract(), "v1")
+
+	resp, err := e.Simulate(&SimulateRequest{
+		Operation: "testOp",
+		Facts:     map[string]any{"amount": 150.0, "params.limit": 200.0},
+		Explain:   true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Outcome != "would_execute" {
+		t.Errorf("expected would_execute, got %s", resp.Outcome)
+	}
+	if got := resp.Explain.Params["limit"]; got.Source != "supplied" {
+		t.Errorf("expected supplied source, got %+v", got)
+	}
+}
+
+func TestEngine_Evaluate_paramFactGatesRule(t *testing.T) {
+	c := makeSimpleContract("new-flow",
+		VerdictDef{Deny: &DenyVerdict{Code: "NEW_FLOW"}},
+		Condition{Fact: "params.new_flow", Equals: true},
+	)
+	c.Params = map[string]ParamDef{"new_flow": {Default: false}}
+	c.Facts["params.new_flow"] = FactDef{Source: "param", OnMissing: "skip"}
+	if err := c.Bind("staging", map[string]any{"new_flow": true}); err != nil {
+		t.Fatalf("bind: %v", err)
+	}
+	e := NewEngine(&mockPorts{})
+	e.LoadContract(c, "v1")
+
+	resp, _ := e.Evaluate(context.Background(), &Request{Operation: "testOp", DryRun: true})
+	if resp.Outcome != "would_deny" {
+		t.Errorf("expected would_deny, got %s", resp.Outcome)
+	}
+}
+
+func TestEngine_Evaluate_escalationQueueFromParam(t *testing.T) {
+	var ev EscalateVerdict
+	if err := json.Unmarshal([]byte(`{"queue": {"param": "queue"}, "reason": "r"}`), &ev); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	c := makeSimpleContract("esc", VerdictDef{Escalate: &ev}, Condition{})
+	c.Params = map[string]ParamDef{"queue": {Default: "default-review"}}
+	c.Facts["params.queue"] = FactDef{Source: "param", OnMissing: "skip"}
+	if err := c.Bind("prod", map[string]any{"queue": "prod-review"}); err != nil {
+		t.Fatalf("bind: %v", err)
+	}
+	e := NewEngine(&mockPorts{})
+	e.LoadContract(c, "v1")
+
+	resp, _ := e.Evaluate(context.Background(), &Request{Operation: "testOp", DryRun: true})
+	if len(resp.Verdicts) != 1 || resp.Verdicts[0].Queue != "prod-review" {
+		t.Errorf("expected queue prod-review, got %+v", resp.Verdicts)
+	}
+}
+
+func TestContract_Bind_rejectsUnknownBinding(t *testing.T) {
+	err := makeParamContract().Bind("prod", map[string]any{"limt": 1})
+	if err == nil || !strings.Contains(err.Error(), "no such param") {
+		t.Fatalf("expected unknown binding error, got %v", err)
+	}
+}
+
+func TestContract_Bind_requiresParamsWithoutDefault(t *testing.T) {
+	c := makeParamContract()
+	c.Params["limit"] = ParamDef{}
+	err := c.Bind("prod", nil)
+	if err == nil || !strings.Contains(err.Error(), "limit") {
+		t.Fatalf("expected unbound param error, got %v", err)
+	}
+}
+
+func TestContract_Bind_rejectsUndeclaredReference(t *testing.T) {
+	c := makeParamContract()
+	delete(c.Params, "limit")
+	err := c.Bind("", nil)
+	if err == nil || !strings.Contains(err.Error(), "undeclared param") {
+		t.Fatalf("expected undeclared param error, got %v", err)
+	}
+}
+
+func TestCompileFiles_paramsExposedAsFacts(t *testing.T) {
+	c, err := compileContract([]sourceFile{{path: "p.cue", data: []byte(`
+params: limit: {default: 10}
+operations: op: {constrained_by: [], transitions: []}
+`)}})
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	if c.Params["limit"].Default != 10.0 {
+		t.Errorf("expected default 10, got %v", c.Params["limit"].Default)
+	}
+	if c.Facts["params.limit"].Source != "param" {
+		t.Errorf("expected params.limit fact, got %+v", c.Facts["params.limit"])
+	}
+}
@@ -0,0 +1,33 @@
+package engine
+
+import (
+	"context"
+	"time"
+)
+
+// maxPartialReserve caps the time a partial dry-run keeps back from its
+// deadline to evaluate rules on the facts it has.
+const maxPartialReserve = 50 * time.Millisecond
+
+// partialStop returns a channel that fires when a partial dry-run should
+// stop waiting for facts: a tenth of the time left before ctx's deadline,
+// up to maxPartialReserve, ahead of it. It returns nil — wait for every
+// fact — for other requests or when ctx has no deadline.
+func partialStop(ctx context.Context, req *Request, now time.Time) (<-chan time.Time, func()) {
+	deadline, ok := ctx.Deadline()
+	if !req.DryRun || !req.Partial || !ok {
+		return nil, func() {}
+	}
+	left := deadline.Sub(now)
+	left -= min(left/10, maxPartialReserve)
+	t := time.NewTimer(max(left, 0))
+	return t.C, func() { t.Stop() }
+}
+
+// withRequestTimeout bounds a dry-run by req.TimeoutMS, if set.
+func withRequestTimeout(ctx context.Context, req *Request) (context.Context, context.CancelFunc) {
+	if !req.DryRun || req.TimeoutMS <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, time.Duration(req.TimeoutMS)*time.Millisecond)
+}
@@ -0,0 +1,87 @@
+package engine
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// partialContract denies testOp when the fast port fact is "bad"; the slow
+// port fact only answers once its context ends.
+func partialContract() (*Contract, *mockPorts) {
+	c := makeMinimalContract()
+	c.Facts["fast"] = FactDef{Source: "port:p"}
+	c.Facts["slow"] = FactDef{Source: "port:p"}
+	c.Rules = []RuleDef{
+		{ID: "fast-bad", When: Condition{Fact: "fast", Equals: "bad"}, Verdict: VerdictDef{Deny: &DenyVerdict{Code: "BAD"}}},
+		{ID: "slow-bad", When: Condition{Fact: "slow", Equals: "bad"}, Verdict: VerdictDef{Deny: &DenyVerdict{Code: "SLOW_BAD"}}},
+	}
+	c.Operations["testOp"] = OperationDef{ConstrainedBy: []string{"fast-bad", "slow-bad"}}
+	ports := &mockPorts{getFunc: func(ctx context.Context, _, fact string, _ map[string]any) (any, error) {
+		if fact == "slow" {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return "bad", nil
+	}}
+	return c, ports
+}
+
+func TestEngine_Evaluate_partialDryRunSkipsSlowFacts(t *testing.T) {
+	c, ports := partialContract()
+	e := NewEngine(ports)
+	e.LoadContract(c, "v1")
+
+	start := time.Now()
+	resp, err := e.Evaluate(context.Background(), &Request{Operation: "testOp", DryRun: true, Partial: true, TimeoutMS: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed >= 50*time.Millisecond {
+		t.Errorf("expected an answer before the deadline, took %v", elapsed)
+	}
+	if !resp.Partial || !reflect.DeepEqual(resp.SkippedFacts, []string{"slow"}) {
+		t.Errorf("expected partial result skipping slow, got partial=%v skipped=%v", resp.Partial, resp.SkippedFacts)
+	}
+	if resp.Outcome != "would_deny" || resp.FactSnapshot["fast"] != "bad" {
+		t.Errorf("expected a verdict from the gathered facts, got %+v", resp)
+	}
+}
+
+func TestEngine_Evaluate_partialUsesContextDeadline(t *testing.T) {
+	c, ports := partialContract()
+	e := NewEngine(ports)
+	e.LoadContract(c, "v1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	resp, err := e.Evaluate(ctx, &Request{Operation: "testOp", DryRun: true, Partial: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Partial || len(resp.SkippedFacts) != 1 {
+		t.Errorf("expected a partial result, got %+v", resp)
+	}
+}
+
+func TestEngine_Evaluate_partialOnlyForDryRuns(t *testing.T) {
+	c, ports := partialContract()
+	e := NewEngine(ports)
+	e.LoadContract(c, "v1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	resp, err := e.Evaluate(ctx, &Request{Operation: "testOp", Partial: true, TimeoutMS: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Partial || resp.Outcome != "system_error" {
+		t.Errorf("a live request must wait for its facts, got %+v", resp)
+	}
+
+	resp, _ = e.Evaluate(context.Background(), &Request{Operation: "testOp", DryRun: true, TimeoutMS: 20})
+	if resp.Partial || resp.Outcome != "system_error" {
+		t.Errorf("without partial a timed-out fact is an error, got %+v", resp)
+	}
+}
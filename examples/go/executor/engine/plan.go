@@ -0,0 +1,163 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// PlanRequest is the payload sent to POST /plan/validate: the operations an
+// agent intends to invoke, in order.
+type PlanRequest struct {
+	Steps []PlanStep `json:"steps"`
+	// ContractETag pins every step to one contract; by default, the one
+	// active when validation starts.
+	ContractETag string         `json:"contract_etag,omitempty"`
+	Context      map[string]any `json:"context,omitempty"`
+	Purpose      string         `json:"purpose,omitempty"`
+	SessionID    string         `json:"session_id,omitempty"`
+}
+
+// PlanStep is one intended operation of a plan.
+type PlanStep struct {
+	Operation string         `json:"operation"`
+	Input     map[string]any `json:"input"`
+}
+
+// PlanResult reports whether every step of a plan would proceed. Steps are
+// those evaluated: all of them for a valid plan, otherwise those up to and
+// including FailedStep, the index of the first that would not proceed.
+type PlanResult struct {
+	Valid          bool             `json:"valid"`
+	FailedStep     *int             `json:"failed_step,omitempty"`
+	Steps          []PlanStepResult `json:"steps"`
+	ContractSemver string           `json:"contract_semver,omitempty"`
+}
+
+// PlanStepResult is the dry-run of one step.
+type PlanStepResult struct {
+	Step         int            `json:"step"`
+	Operation    string         `json:"operation"`
+	InvocationID string         `json:"invocation_id,omitempty"`
+	Outcome      Outcome        `json:"outcome"`
+	Verdicts     []Verdict      `json:"verdicts,omitempty"`
+	Error        *ErrorEnvelope `json:"error,omitempty"`
+	// Transitions are the entity transitions the step would make, which
+	// later steps are evaluated as having happened.
+	Transitions []PlannedTransition `json:"transitions,omitempty"`
+}
+
+// PlannedTransition is an entity instance moved by a plan step.
+type PlannedTransition struct {
+	Entity string `json:"entity"`
+	ID     string `json:"id"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+}
+
+// ValidatePlan dry-runs the steps of req in order and stops at the first
+// that would not proceed. The entity transitions of each step that would
+// proceed are simulated for the steps after it, so that a step reading
+// entity:invoice.state sees the state an earlier step would leave the
+// invoice in. Nothing is executed and no state is changed.
+//
+// A step naming an unknown operation, or missing a required input fact, is
+// an error, as for Evaluate.
+func (e *Engine) ValidatePlan(ctx context.Context, req *PlanRequest) (*PlanResult, error) {
+	if len(req.Steps) == 0 {
+		return nil, errors.New("plan has no steps")
+	}
+	e.activateDue()
+	contract, etag, _ := e.selectContract("")
+	if contract == nil {
+		return nil, fmt.Errorf("no contract loaded")
+	}
+	if req.ContractETag != "" {
+		etag = req.ContractETag
+	}
+	for i, step := range req.Steps {
+		if _, ok := contract.Operations[step.Operation]; !ok {
+			return nil, fmt.Errorf("step %d: %w: %s", i, ErrUnknownOperation, step.Operation)
+		}
+	}
+
+	planned := plannedStates{}
+	ctx = context.WithValue(ctx, plannedStatesKey{}, planned)
+	result := &PlanResult{Valid: true, Steps: []PlanStepResult{}, ContractSemver: contract.Version}
+	for i, step := range req.Steps {
+		resp, err := e.Evaluate(ctx, &Request{
+			Operation:    step.Operation,
+			Input:        step.Input,
+			DryRun:       true,
+			ContractETag: etag,
+			Context:      req.Context,
+			Purpose:      req.Purpose,
+			SessionID:    req.SessionID,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("step %d: %w", i, err)
+		}
+		sr := PlanStepResult{
+			Step:         i,
+			Operation:    step.Operation,
+			InvocationID: resp.InvocationID,
+			Outcome:      resp.Outcome,
+			Verdicts:     resp.Verdicts,
+			Error:        resp.Error,
+		}
+		var refused *Response
+		if resp.WouldProceed() {
+			if sr.Transitions, refused, err = e.planTransitions(ctx, contract, step, planned); err != nil {
+				return nil, fmt.Errorf("step %d: %w", i, err)
+			}
+		}
+		if refused != nil {
+			sr.Outcome, sr.Error = refused.Outcome, refused.Error
+		}
+		result.Steps = append(result.Steps, sr)
+		if !resp.WouldProceed() || refused != nil {
+			result.Valid, result.FailedStep = false, &i
+			return result, nil
+		}
+	}
+	return result, nil
+}
+
+// planTransitions records the transitions step would make in planned, and
+// returns them. Entities without a key, and steps without the key's input
+// fact, are not tracked, as in live evaluation. If an instance would not
+// be in the state a transition declares it from, nothing is recorded and
+// it returns the refusal live evaluation would give.
+func (e *Engine) planTransitions(ctx context.Context, c *Contract, step PlanStep, planned plannedStates) ([]PlannedTransition, *Response, error) {
+	if e.states == nil {
+		return nil, nil, nil
+	}
+	var ts []PlannedTransition
+	for _, ref := range c.Operations[step.Operation].Transitions {
+		id, err := entityKey(c, ref.Entity, step.Input)
+		if err != nil {
+			continue
+		}
+		from, err := e.entityState(ctx, c, ref.Entity, id)
+		if err != nil {
+			return nil, nil, fmt.Errorf("state of %s %s unavailable: %w", ref.Entity, id, err)
+		}
+		if ref.From != "" && from != ref.From {
+			return nil, invalidTransition(ref, id, from), nil
+		}
+		ts = append(ts, PlannedTransition{Entity: ref.Entity, ID: id, From: from, To: ref.To})
+	}
+	for _, t := range ts {
+		planned[entityInstance{t.Entity, t.ID}] = t.To
+	}
+	return ts, nil, nil
+}
+
+// entityInstance identifies an instance of an entity.
+type entityInstance struct{ entity, id string }
+
+// plannedStates are the states earlier steps of a plan would leave entity
+// instances in. entityState reads them in place of the StateStore's.
+type plannedStates map[entityInstance]string
+
+type plannedStatesKey struct{}
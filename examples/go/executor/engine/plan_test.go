@@ -0,0 +1,76 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// planContract adds approve, which moves an invoice from draft to approved,
+// to entityStateContract, whose testOp needs the invoice approved.
+func planContract() *Contract {
+	c := entityStateContract()
+	c.Operations["approve"] = OperationDef{Transitions: []EntityTransitionRef{{Entity: "invoice", From: "draft", To: "approved"}}}
+	return c
+}
+
+func TestEngine_ValidatePlan_simulatesTransitionsBetweenSteps(t *testing.T) {
+	states := NewMemoryStateStore()
+	e := NewEngine(&mockPorts{}, WithStateStore(states))
+	e.LoadContract(planContract(), "v1")
+	ctx := context.Background()
+	inv := map[string]any{"invoice.id": "inv_1"}
+
+	res, err := e.ValidatePlan(ctx, &PlanRequest{Steps: []PlanStep{{"approve", inv}, {"testOp", inv}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Valid || res.FailedStep != nil || len(res.Steps) != 2 {
+		t.Fatalf("expected a valid plan, got %+v", res)
+	}
+	if want := []PlannedTransition{{Entity: "invoice", ID: "inv_1", From: "draft", To: "approved"}}; !reflect.DeepEqual(res.Steps[0].Transitions, want) {
+		t.Errorf("got transitions %+v", res.Steps[0].Transitions)
+	}
+	if res.Steps[1].Outcome != OutcomeWouldExecuteWithFlags {
+		t.Errorf("expected testOp to see the approved invoice, got %+v", res.Steps[1])
+	}
+	if state, _, _ := states.GetState(ctx, "invoice", "inv_1"); state != "" {
+		t.Errorf("expected no state change, got %q", state)
+	}
+
+	// In the other order, testOp runs against the draft invoice.
+	res, err = e.ValidatePlan(ctx, &PlanRequest{Steps: []PlanStep{{"testOp", inv}, {"approve", inv}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Valid || res.FailedStep == nil || *res.FailedStep != 0 || len(res.Steps) != 1 || res.Steps[0].Verdicts[0].Code != "NOT_APPROVED" {
+		t.Errorf("expected the first step to fail, got %+v", res)
+	}
+}
+
+func TestEngine_ValidatePlan_rejectsUnknownOperations(t *testing.T) {
+	e := NewEngine(&mockPorts{}, WithStateStore(NewMemoryStateStore()))
+	e.LoadContract(planContract(), "v1")
+	_, err := e.ValidatePlan(context.Background(), &PlanRequest{Steps: []PlanStep{{"approve", nil}, {"refund", nil}}})
+	if !errors.Is(err, ErrUnknownOperation) || err.Error() != "step 1: unknown operation: refund" {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestEngine_ValidatePlan_failsStepFromAnotherState(t *testing.T) {
+	e := NewEngine(&mockPorts{}, WithStateStore(NewMemoryStateStore()))
+	e.LoadContract(planContract(), "v1")
+	inv := map[string]any{"invoice.id": "inv_1"}
+
+	res, err := e.ValidatePlan(context.Background(), &PlanRequest{Steps: []PlanStep{{"approve", inv}, {"approve", inv}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Valid || res.FailedStep == nil || *res.FailedStep != 1 || len(res.Steps) != 2 {
+		t.Fatalf("expected the second approval to fail, got %+v", res)
+	}
+	if sr := res.Steps[1]; sr.Outcome != OutcomeStateConflict || sr.Error == nil || sr.Error.Code != "INVALID_TRANSITION" || len(sr.Transitions) != 0 {
+		t.Errorf("expected an invalid transition, got %+v", sr)
+	}
+}
@@ -0,0 +1,54 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PortContractViolation is the error a port returns when an upstream's
+// payload does not match the schema it is expected to have, such as after
+// the upstream changed its shape unannounced. The engine answers
+// OutcomePortContractViolation, whatever the fact's on_missing, and records
+// the violation in the audit record.
+type PortContractViolation struct {
+	Port      string `json:"port"`
+	Fact      string `json:"fact,omitempty"`
+	Operation string `json:"operation,omitempty"`
+	// Schema identifies the schema the payload was validated against, such
+	// as a registry subject and version.
+	Schema     string   `json:"schema"`
+	Violations []string `json:"violations"`
+}
+
+func (v *PortContractViolation) Error() string {
+	what := "fact " + v.Fact
+	if v.Operation != "" {
+		what = "operation " + v.Operation
+	}
+	return fmt.Sprintf("port %s: %s does not match schema %s: %s", v.Port, what, v.Schema, strings.Join(v.Violations, "; "))
+}
+
+// portContractViolation answers a request refused by v. A fact's violation
+// is retryable, having no side effects, in case the upstream is fixed; an
+// operation whose output violated its schema has taken effect upstream, so
+// its transitions stand and it is not retryable.
+func portContractViolation(v *PortContractViolation) *Response {
+	details := map[string]any{"port": v.Port, "schema": v.Schema, "violations": v.Violations}
+	if v.Fact != "" {
+		details["fact"] = v.Fact
+	}
+	if v.Operation != "" {
+		details["operation"] = v.Operation
+	}
+	return &Response{
+		Outcome: OutcomePortContractViolation,
+		Error: &ErrorEnvelope{
+			Code:       "PORT_CONTRACT_VIOLATION",
+			Message:    v.Error(),
+			HttpStatus: 502,
+			Category:   "system",
+			Retryable:  v.Operation == "",
+			Details:    details,
+		},
+	}
+}
@@ -0,0 +1,55 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestEngine_portContractViolation(t *testing.T) {
+	violation := &PortContractViolation{Port: "accounts", Schema: "accounts.balance v3", Violations: []string{"/value: expected number, got string"}}
+	for _, tc := range []struct {
+		name      string
+		ports     *mockPorts
+		retryable bool
+	}{
+		{"fact", &mockPorts{getFunc: func(_ context.Context, _, fact string, _ map[string]any) (any, error) {
+			v := *violation
+			v.Fact = fact
+			return nil, fmt.Errorf("get: %w", &v)
+		}}, true},
+		{"operation", &mockPorts{
+			getFunc: func(context.Context, string, string, map[string]any) (any, error) { return 50.0, nil },
+			executeFunc: func(_ context.Context, _, operation string, _ map[string]any) (map[string]any, error) {
+				v := *violation
+				v.Operation = operation
+				return nil, &v
+			},
+		}, false},
+	} {
+		sink := &recordingSink{}
+		e := NewEngine(tc.ports, WithAuditSink(sink))
+		c := intentContract()
+		// A contract violation is reported whatever the fact's on_missing.
+		c.Facts["balance"] = FactDef{Source: "port:accounts", OnMissing: "skip"}
+		e.LoadContract(c, "v1")
+
+		resp, err := e.Evaluate(context.Background(), &Request{Operation: "testOp", Input: map[string]any{"customer.id": "cust_1"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Outcome != OutcomePortContractViolation || resp.Error.Code != "PORT_CONTRACT_VIOLATION" || resp.Error.Retryable != tc.retryable {
+			t.Fatalf("%s: expected a port contract violation, got %+v", tc.name, resp)
+		}
+		if resp.Error.Details[tc.name] == nil || resp.IsTerminal() == tc.retryable {
+			t.Errorf("%s: unexpected envelope %+v", tc.name, resp.Error)
+		}
+		rec := sink.recs[0]
+		if rec.Outcome != string(OutcomePortContractViolation) || rec.PortContractViolation == nil || rec.PortContractViolation.Schema != "accounts.balance v3" {
+			t.Errorf("%s: expected the violation audited, got %+v", tc.name, rec)
+		}
+		if v1 := resp.ForProtocol(ProtocolV1); v1.Outcome != OutcomeSystemError {
+			t.Errorf("%s: expected a system error for version 1 clients, got %s", tc.name, v1.Outcome)
+		}
+	}
+}
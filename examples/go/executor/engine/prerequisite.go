@@ -0,0 +1,135 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// PrerequisiteDef requires an operation to follow an earlier execution of
+// another, e.g.
+//
+//	requires: [{operation: "ProcessPayment", reference: "payment.invocation_id", entity: "invoice"}]
+//
+// Reference is the input fact holding the invocation ID of that execution,
+// which is looked up in the execution history. If Entity is set, the
+// execution must have been on the same instance of it: both requests carry
+// the same value of the entity's key. A request without such an execution
+// is denied with Error, or a PREREQUISITE_NOT_MET envelope if none is
+// declared.
+type PrerequisiteDef struct {
+	Operation string         `json:"operation"`
+	Reference string         `json:"reference"`
+	Entity    string         `json:"entity,omitempty"`
+	Error     *ErrorEnvelope `json:"error,omitempty"`
+}
+
+// checkPrerequisites returns the response refusing a request whose
+// operation's prerequisites are not met, or nil if they are.
+func (e *Engine) checkPrerequisites(ctx context.Context, c *Contract, operation string, op OperationDef, req *Request) *Response {
+	for _, p := range op.Requires {
+		ref, _ := req.Input[p.Reference].(string)
+		if ref == "" {
+			return prerequisiteNotMet(operation, p, req, "", "missing_reference",
+				fmt.Sprintf("%s needs %s, the invocation ID of a prior %s", operation, p.Reference, p.Operation))
+		}
+		if e.history == nil {
+			return prerequisiteUnavailable(operation, p, ref, errors.New("no execution history"))
+		}
+		prior, err := e.history.Execution(ctx, ref)
+		switch {
+		case errors.Is(err, ErrNotFound):
+			return prerequisiteNotMet(operation, p, req, ref, "not_found",
+				fmt.Sprintf("%s %s is not a known execution", p.Reference, ref))
+		case err != nil:
+			return prerequisiteUnavailable(operation, p, ref, err)
+		case prior.Operation != p.Operation:
+			return prerequisiteNotMet(operation, p, req, ref, "wrong_operation",
+				fmt.Sprintf("%s %s is an execution of %s, not %s", p.Reference, ref, prior.Operation, p.Operation))
+		}
+		if p.Entity == "" {
+			continue
+		}
+		want, err := entityKey(c, p.Entity, req.Input)
+		if err != nil {
+			return prerequisiteNotMet(operation, p, req, ref, "missing_entity", err.Error())
+		}
+		if got, ok := prior.Entities[p.Entity]; !ok || got != want {
+			return prerequisiteNotMet(operation, p, req, ref, "different_entity",
+				fmt.Sprintf("%s %s of %s was not on %s %s", p.Reference, ref, p.Operation, p.Entity, want))
+		}
+	}
+	return nil
+}
+
+// prerequisiteNotMet denies a request for the reason given, a short code
+// reported in the envelope's details.
+func prerequisiteNotMet(operation string, p PrerequisiteDef, req *Request, ref, reason, message string) *Response {
+	env := &ErrorEnvelope{
+		Code:       "PREREQUISITE_NOT_MET",
+		Message:    message,
+		HttpStatus: http.StatusUnprocessableEntity,
+		Category:   "business_rule_violation",
+		Suggestion: fmt.Sprintf("Invoke %s first and pass its invocation ID as %s", p.Operation, p.Reference),
+	}
+	if p.Error != nil {
+		declared := *p.Error
+		env = &declared
+	}
+	env.Details = map[string]any{
+		"operation": operation,
+		"requires":  p.Operation,
+		"reference": p.Reference,
+		"reason":    reason,
+	}
+	if ref != "" {
+		env.Details["invocation_id"] = ref
+	}
+	if p.Entity != "" {
+		env.Details["entity"] = p.Entity
+	}
+	return &Response{DryRun: req.DryRun, Outcome: OutcomeDenied, Error: env}
+}
+
+// prerequisiteUnavailable is the response to a request whose prerequisite
+// could not be looked up.
+func prerequisiteUnavailable(operation string, p PrerequisiteDef, ref string, err error) *Response {
+	return &Response{
+		Outcome: OutcomeSystemError,
+		Error: &ErrorEnvelope{
+			Code:       "PREREQUISITE_UNAVAILABLE",
+			Message:    fmt.Sprintf("prior %s %s of %s could not be looked up: %v", p.Operation, ref, operation, err),
+			HttpStatus: http.StatusServiceUnavailable,
+			Category:   "system",
+			Retryable:  true,
+			Details:    map[string]any{"operation": operation, "requires": p.Operation, "invocation_id": ref},
+		},
+	}
+}
+
+// validatePrerequisites checks that an operation's prerequisites name a
+// declared operation, an input fact and an entity with a key.
+func validatePrerequisites(c *Contract, name string, op OperationDef) []Diagnostic {
+	var diags []Diagnostic
+	for i, p := range op.Requires {
+		report := func(format string, args ...any) {
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("operation %s: requires[%d] ", name, i) + fmt.Sprintf(format, args...),
+			})
+		}
+		if _, ok := c.Operations[p.Operation]; !ok {
+			report("operation %q is not declared", p.Operation)
+		}
+		if def, ok := c.Facts[p.Reference]; !ok || def.Source != "input" {
+			report("reference %q is not an input fact", p.Reference)
+		}
+		if p.Entity != "" {
+			if def, ok := c.Entities[p.Entity]; !ok || def.Key == "" {
+				report("entity %q is not declared with a key", p.Entity)
+			}
+		}
+	}
+	return diags
+}
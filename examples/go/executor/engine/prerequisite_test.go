@@ -0,0 +1,103 @@
+package engine
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func refundContract() *Contract {
+	c := makeMinimalContract()
+	c.Facts = map[string]FactDef{
+		"invoice.id":            {Source: "input"},
+		"payment.invocation_id": {Source: "input"},
+	}
+	c.Entities["invoice"] = EntityDef{Key: "invoice.id", States: []string{"open"}, Initial: "open"}
+	c.Operations = map[string]OperationDef{
+		"ProcessPayment": {},
+		"RefundPayment": {Requires: []PrerequisiteDef{
+			{Operation: "ProcessPayment", Reference: "payment.invocation_id", Entity: "invoice"},
+		}},
+	}
+	return c
+}
+
+func TestEngine_prerequisites(t *testing.T) {
+	sink := &recordingSink{}
+	e := NewEngine(&mockPorts{}, WithAuditSink(sink), WithHistory(NewMemoryExecutionStore(0)))
+	e.LoadContract(refundContract(), "v1")
+	ctx := context.Background()
+	evaluate := func(op string, input map[string]any) *Response {
+		t.Helper()
+		resp, err := e.Evaluate(ctx, &Request{Operation: op, Input: input})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	paid := evaluate("ProcessPayment", map[string]any{"invoice.id": "inv_1"})
+	if paid.Outcome != OutcomeExecuted {
+		t.Fatalf("expected the payment executed, got %s", paid.Outcome)
+	}
+	if resp := evaluate("RefundPayment", map[string]any{"invoice.id": "inv_1", "payment.invocation_id": paid.InvocationID}); resp.Outcome != OutcomeExecuted {
+		t.Fatalf("expected the refund executed, got %s: %+v", resp.Outcome, resp.Error)
+	}
+	refund := sink.recs[len(sink.recs)-1].InvocationID
+
+	for _, tc := range []struct {
+		input  map[string]any
+		reason string
+	}{
+		{map[string]any{"invoice.id": "inv_1"}, "missing_reference"},
+		{map[string]any{"invoice.id": "inv_1", "payment.invocation_id": "inv_unknown"}, "not_found"},
+		{map[string]any{"invoice.id": "inv_1", "payment.invocation_id": refund}, "wrong_operation"},
+		{map[string]any{"invoice.id": "inv_2", "payment.invocation_id": paid.InvocationID}, "different_entity"},
+	} {
+		resp := evaluate("RefundPayment", tc.input)
+		if resp.Outcome != OutcomeDenied || resp.Error.Code != "PREREQUISITE_NOT_MET" || resp.Error.Details["reason"] != tc.reason {
+			t.Errorf("%s: expected a PREREQUISITE_NOT_MET denial, got %s %+v", tc.reason, resp.Outcome, resp.Error)
+		}
+	}
+
+	// A denied payment is no prerequisite.
+	deny := makeSimpleContract("blocked", VerdictDef{Deny: &DenyVerdict{Error: ErrorEnvelope{Code: "BLOCKED"}}}, Condition{})
+	c := refundContract()
+	c.Rules = deny.Rules
+	c.Operations["ProcessPayment"] = OperationDef{ConstrainedBy: []string{"blocked"}}
+	e.LoadContract(c, "v2")
+	denied := evaluate("ProcessPayment", map[string]any{"invoice.id": "inv_3"})
+	if resp := evaluate("RefundPayment", map[string]any{"invoice.id": "inv_3", "payment.invocation_id": denied.InvocationID}); resp.Error == nil || resp.Error.Details["reason"] != "not_found" {
+		t.Errorf("expected a refund of a denied payment refused, got %+v", resp.Error)
+	}
+}
+
+func TestEngine_prerequisites_withoutHistoryIsSystemError(t *testing.T) {
+	e := NewEngine(&mockPorts{})
+	e.LoadContract(refundContract(), "v1")
+
+	resp, err := e.Evaluate(context.Background(), &Request{Operation: "RefundPayment", Input: map[string]any{"invoice.id": "inv_1", "payment.invocation_id": "inv_abc"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Outcome != OutcomeSystemError || resp.Error.Code != "PREREQUISITE_UNAVAILABLE" || !resp.Error.Retryable {
+		t.Errorf("expected a retryable system error, got %s %+v", resp.Outcome, resp.Error)
+	}
+}
+
+func TestValidate_reportsMalformedPrerequisites(t *testing.T) {
+	c := refundContract()
+	c.Operations["RefundPayment"] = OperationDef{Requires: []PrerequisiteDef{
+		{Operation: "CapturePayment", Reference: "payment.id", Entity: "payment"},
+	}}
+	var got []string
+	for _, d := range Validate(c, time.Now()) {
+		if strings.Contains(d.Message, "requires[0]") {
+			got = append(got, d.Message)
+		}
+	}
+	if len(got) != 3 {
+		t.Errorf("expected 3 diagnostics, got %q", got)
+	}
+}
@@ -0,0 +1,55 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Request priorities. Evaluations default to interactive and simulations to
+// batch.
+const (
+	PriorityInteractive = "interactive"
+	PriorityBatch       = "batch"
+)
+
+// ErrLaneFull is returned by a Scheduler whose queue for the requested
+// priority is full.
+var ErrLaneFull = errors.New("priority lane full")
+
+// Scheduler runs evaluations in per-priority lanes, so a flood of batch work
+// cannot starve interactive requests.
+type Scheduler interface {
+	// Do runs fn in the lane for priority and returns once it has finished.
+	// It returns ErrLaneFull without running fn if the lane's queue is full,
+	// ctx's error if ctx ends before fn starts, or an error if fn panics.
+	Do(ctx context.Context, priority string, fn func()) error
+}
+
+// WithScheduler runs every Evaluate and Simulate call through s.
+func WithScheduler(s Scheduler) Option {
+	return func(e *Engine) { e.scheduler = s }
+}
+
+// schedule runs fn through the engine's scheduler, if any, at the given
+// priority or def when it is empty. A full lane yields a throttled response.
+func (e *Engine) schedule(ctx context.Context, priority, def string, fn func() (*Response, error)) (*Response, error) {
+	if priority == "" {
+		priority = def
+	}
+	if priority != PriorityInteractive && priority != PriorityBatch {
+		return nil, fmt.Errorf("unknown priority %q (want %s or %s)", priority, PriorityInteractive, PriorityBatch)
+	}
+	if e.scheduler == nil {
+		return fn()
+	}
+	var resp *Response
+	var err error
+	switch serr := e.scheduler.Do(ctx, priority, func() { resp, err = fn() }); {
+	case errors.Is(serr, ErrLaneFull):
+		return throttled(fmt.Sprintf("Too many queued %s evaluations", priority), map[string]any{"priority": priority}), nil
+	case serr != nil:
+		return nil, serr
+	}
+	return resp, err
+}
@@ -0,0 +1,66 @@
+package engine
+
+import (
+	"context"
+	"testing"
+)
+
+// laneRecorder runs work inline and records the lanes asked for, or refuses
+// every request when full.
+type laneRecorder struct {
+	lanes []string
+	full  bool
+}
+
+func (s *laneRecorder) Do(_ context.Context, priority string, fn func()) error {
+	s.lanes = append(s.lanes, priority)
+	if s.full {
+		return ErrLaneFull
+	}
+	fn()
+	return nil
+}
+
+func TestEngine_schedulerDefaultsByEntryPoint(t *testing.T) {
+	s := &laneRecorder{}
+	e := NewEngine(&mockPorts{}, WithScheduler(s))
+	e.LoadContract(makeMinimalContract(), "v1")
+
+	if resp, err := e.Evaluate(context.Background(), &Request{Operation: "testOp"}); err != nil || resp.Outcome != "executed" {
+		t.Fatalf("unexpected result %+v, %v", resp, err)
+	}
+	e.Evaluate(context.Background(), &Request{Operation: "testOp", Priority: PriorityBatch})
+	e.Simulate(&SimulateRequest{Operation: "testOp"})
+	e.Simulate(&SimulateRequest{Operation: "testOp", Priority: PriorityInteractive})
+
+	want := []string{PriorityInteractive, PriorityBatch, PriorityBatch, PriorityInteractive}
+	if len(s.lanes) != len(want) {
+		t.Fatalf("expected lanes %v, got %v", want, s.lanes)
+	}
+	for i := range want {
+		if s.lanes[i] != want[i] {
+			t.Errorf("call %d: expected %s, got %s", i, want[i], s.lanes[i])
+		}
+	}
+}
+
+func TestEngine_fullLaneIsThrottled(t *testing.T) {
+	e := NewEngine(&mockPorts{}, WithScheduler(&laneRecorder{full: true}))
+	e.LoadContract(makeMinimalContract(), "v1")
+
+	resp, err := e.Evaluate(context.Background(), &Request{Operation: "testOp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Outcome != "throttled" || resp.Error.Code != "THROTTLED" || resp.Error.Details["priority"] != PriorityInteractive {
+		t.Errorf("expected throttled response, got %+v", resp)
+	}
+}
+
+func TestEngine_unknownPriorityIsRejected(t *testing.T) {
+	e := NewEngine(&mockPorts{})
+	e.LoadContract(makeMinimalContract(), "v1")
+	if _, err := e.Evaluate(context.Background(), &Request{Operation: "testOp", Priority: "urgent"}); err == nil {
+		t.Error("expected error for an unknown priority")
+	}
+}
@@ -0,0 +1,71 @@
+package engine
+
+import "fmt"
+
+// Versions of the wire protocol requests and responses are exchanged in
+// over HTTP. A request names the version its client speaks in
+// protocol_version, and the executor answers in that version, or in its
+// own if the client's is newer; the response names the version it is in.
+// Discovery lists the versions a deployment serves.
+//
+// Version 1 is the unversioned format of clients that send no
+// protocol_version; its outcomes include throttled, which predates
+// versioning. Version 2 adds protocol_version and the state_conflict and
+// port_contract_violation outcomes.
+const (
+	ProtocolV1 = 1
+	ProtocolV2 = 2
+
+	// ProtocolVersion is the version this package speaks.
+	ProtocolVersion = ProtocolV2
+	// MinProtocolVersion is the oldest version still served.
+	MinProtocolVersion = ProtocolV1
+)
+
+// ProtocolVersions lists the versions served, oldest first.
+func ProtocolVersions() []int {
+	versions := make([]int, 0, ProtocolVersion-MinProtocolVersion+1)
+	for v := MinProtocolVersion; v <= ProtocolVersion; v++ {
+		versions = append(versions, v)
+	}
+	return versions
+}
+
+// NegotiateProtocol returns the version to answer a request in, given the
+// version it names (0 for none).
+func NegotiateProtocol(requested int) (int, error) {
+	switch {
+	case requested == 0:
+		return ProtocolV1, nil
+	case requested < MinProtocolVersion:
+		return 0, fmt.Errorf("protocol version %d is no longer supported; this executor speaks %d to %d", requested, MinProtocolVersion, ProtocolVersion)
+	case requested > ProtocolVersion:
+		return ProtocolVersion, nil
+	}
+	return requested, nil
+}
+
+// responseShims translate a response down one version: responseShims[v]
+// turns a version v+1 response into a version v one.
+var responseShims = map[int]func(*Response){
+	ProtocolV1: func(r *Response) {
+		r.ProtocolVersion = 0
+		// Version 1 clients report conflicts and port contract violations
+		// as the system errors they are; the envelope still says
+		// STATE_CONFLICT or PORT_CONTRACT_VIOLATION.
+		if r.Outcome == OutcomeStateConflict || r.Outcome == OutcomePortContractViolation {
+			r.Outcome = OutcomeSystemError
+		}
+	},
+}
+
+// ForProtocol returns a copy of r as it is sent to a client speaking
+// version, which NegotiateProtocol has accepted.
+func (r *Response) ForProtocol(version int) *Response {
+	cp := *r
+	cp.ProtocolVersion = ProtocolVersion
+	for v := ProtocolVersion - 1; v >= version; v-- {
+		responseShims[v](&cp)
+	}
+	return &cp
+}
@@ -0,0 +1,50 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNegotiateProtocol(t *testing.T) {
+	for requested, want := range map[int]int{0: ProtocolV1, 1: ProtocolV1, 2: ProtocolV2, 9: ProtocolVersion} {
+		if got, err := NegotiateProtocol(requested); err != nil || got != want {
+			t.Errorf("%d: got %d, %v, want %d", requested, got, err, want)
+		}
+	}
+	if _, err := NegotiateProtocol(-1); err == nil {
+		t.Error("expected a version below the minimum rejected")
+	}
+	if got := ProtocolVersions(); !reflect.DeepEqual(got, []int{1, 2}) {
+		t.Errorf("got versions %v", got)
+	}
+}
+
+func TestResponse_ForProtocol(t *testing.T) {
+	resp := stateConflict("invoice", "inv_1")
+
+	v2 := resp.ForProtocol(ProtocolV2)
+	if v2.ProtocolVersion != ProtocolV2 || v2.Outcome != OutcomeStateConflict {
+		t.Errorf("expected the response unchanged but for its version, got %+v", v2)
+	}
+	v1 := resp.ForProtocol(ProtocolV1)
+	if v1.ProtocolVersion != 0 || v1.Outcome != OutcomeSystemError || v1.Error.Code != "STATE_CONFLICT" || !v1.Error.Retryable {
+		t.Errorf("expected a version 1 system_error, got %+v", v1)
+	}
+	if resp.Outcome != OutcomeStateConflict || resp.ProtocolVersion != 0 {
+		t.Error("expected the response itself left unchanged")
+	}
+}
+
+func TestResponse_ForProtocol_sendsVersion1ClientsOnlyVersion1Outcomes(t *testing.T) {
+	v1 := map[Outcome]bool{
+		OutcomeExecuted: true, OutcomeDenied: true, OutcomeEscalated: true, OutcomeRequired: true,
+		OutcomeSystemError: true, OutcomeThrottled: true,
+		OutcomeWouldExecute: true, OutcomeWouldExecuteWithFlags: true, OutcomeWouldDeny: true,
+		OutcomeWouldEscalate: true, OutcomeWouldRequire: true,
+	}
+	for _, o := range Outcomes {
+		if got := (&Response{Outcome: o}).ForProtocol(ProtocolV1).Outcome; !v1[got] {
+			t.Errorf("%s: sent to a version 1 client as %s", o, got)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package engine
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// A contract's data_use policy limits facts to declared purposes, e.g.
+//
+//	data_use: {
+//		"customer.email":   ["billing", "fraud_prevention"]
+//		"customer.address": ["billing"]
+//	}
+//
+// Requests state their purpose. One whose operation needs a fact the
+// policy does not allow for that purpose, directly or through a derived
+// fact, is refused before any fact is fetched, so the fact is neither
+// read from its port nor exposed in the response or audit record. Facts
+// the policy does not list may be used for any purpose.
+
+// PurposeAllows reports whether the data-use policy lets a request with
+// purpose use fact.
+func (c *Contract) PurposeAllows(fact, purpose string) bool {
+	allowed, restricted := c.DataUse[fact]
+	return !restricted || slices.Contains(allowed, purpose)
+}
+
+// forbiddenFacts returns, sorted, the facts and derived facts operation
+// needs that purpose may not use.
+func forbiddenFacts(c *Contract, operation, purpose string) []string {
+	if len(c.DataUse) == 0 {
+		return nil
+	}
+	needed, derived := neededFacts(c, operation, nil)
+	var out []string
+	for _, set := range []map[string]bool{needed, derived} {
+		for name := range set {
+			if !c.PurposeAllows(name, purpose) {
+				out = append(out, name)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// purposeDenied returns the response refusing a request whose purpose the
+// data-use policy does not permit for facts its operation needs, or nil.
+func purposeDenied(c *Contract, operation string, req *Request) *Response {
+	forbidden := forbiddenFacts(c, operation, req.Purpose)
+	if len(forbidden) == 0 {
+		return nil
+	}
+	message := fmt.Sprintf("Purpose %q does not permit use of %s", req.Purpose, strings.Join(forbidden, ", "))
+	if req.Purpose == "" {
+		message = fmt.Sprintf("%s needs a purpose that permits use of %s", operation, strings.Join(forbidden, ", "))
+	}
+	return &Response{
+		DryRun:  req.DryRun,
+		Outcome: OutcomeDenied,
+		Error: &ErrorEnvelope{
+			Code:       "PURPOSE_NOT_PERMITTED",
+			Message:    message,
+			HttpStatus: http.StatusForbidden,
+			Category:   "authorization",
+			Suggestion: "Send a purpose the contract's data_use policy allows for these facts",
+			Details:    map[string]any{"purpose": req.Purpose, "facts": forbidden},
+		},
+	}
+}
+
+// validateDataUse checks that the data-use policy names declared facts and
+// allows each some purpose.
+func validateDataUse(c *Contract) []Diagnostic {
+	var diags []Diagnostic
+	names := make([]string, 0, len(c.DataUse))
+	for name := range c.DataUse {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		_, fact := c.Facts[name]
+		_, derived := c.DerivedFacts[name]
+		switch {
+		case !fact && !derived:
+			diags = append(diags, Diagnostic{
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("data_use: %s is not a declared fact", name),
+			})
+		case len(c.DataUse[name]) == 0:
+			diags = append(diags, Diagnostic{
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("data_use: %s allows no purpose, so operations that need it always refuse", name),
+			})
+		}
+	}
+	return diags
+}
+
+// sameDataUse reports whether two policies for a fact allow the same
+// purposes.
+func sameDataUse(a, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return reflect.DeepEqual(a, b)
+}
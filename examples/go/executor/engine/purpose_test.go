@@ -0,0 +1,124 @@
+package engine
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// purposeContract restricts the balance to billing, and reads it through a
+// derived fact.
+func purposeContract() *Contract {
+	c := intentContract()
+	c.DerivedFacts["balance.low"] = DerivedFactDef{Derivation: Derivation{Fn: "less_than", Args: []DerivationArg{{Fact: "balance"}, {Value: 10.0}}}}
+	c.Rules[0].When = Condition{Fact: "balance.low", Equals: true}
+	c.DataUse = map[string][]string{"balance": {"billing", "fraud_prevention"}}
+	return c
+}
+
+func TestEngine_Evaluate_refusesFactsOutsideTheirPurpose(t *testing.T) {
+	var gets atomic.Int32
+	ports := &mockPorts{getFunc: func(context.Context, string, string, map[string]any) (any, error) {
+		gets.Add(1)
+		return 5.0, nil
+	}}
+	sink := &recordingSink{}
+	e := NewEngine(ports, WithAuditSink(sink))
+	e.LoadContract(purposeContract(), "v1")
+	input := map[string]any{"customer.id": "cust_1"}
+
+	for _, purpose := range []string{"marketing", ""} {
+		resp, err := e.Evaluate(context.Background(), &Request{Operation: "testOp", Input: input, Purpose: purpose})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Outcome != "denied" || resp.Error == nil || resp.Error.Code != "PURPOSE_NOT_PERMITTED" || resp.Error.HttpStatus != 403 {
+			t.Fatalf("%q: expected PURPOSE_NOT_PERMITTED, got %+v", purpose, resp)
+		}
+		if facts := resp.Error.Details["facts"]; !reflect.DeepEqual(facts, []string{"balance"}) {
+			t.Errorf("%q: expected the restricted fact listed, got %v", purpose, facts)
+		}
+	}
+	if gets.Load() != 0 {
+		t.Errorf("expected no port fetches for a refused purpose, got %d", gets.Load())
+	}
+
+	resp, _ := e.Evaluate(context.Background(), &Request{Operation: "testOp", Input: input, Purpose: "billing"})
+	if resp.Outcome != "executed" || gets.Load() != 1 {
+		t.Errorf("expected an allowed purpose to proceed, got %s after %d gets", resp.Outcome, gets.Load())
+	}
+	if len(sink.recs) != 3 || sink.recs[0].Purpose != "marketing" || sink.recs[2].Purpose != "billing" {
+		t.Errorf("expected the purpose audited, got %d records", len(sink.recs))
+	}
+}
+
+func TestEngine_Prefetch_skipsFactsOutsideItsPurpose(t *testing.T) {
+	var gets atomic.Int32
+	ports := &mockPorts{getFunc: func(context.Context, string, string, map[string]any) (any, error) {
+		gets.Add(1)
+		return 5.0, nil
+	}}
+	e := NewEngine(ports)
+	e.LoadContract(purposeContract(), "v1")
+
+	receipt, err := e.Prefetch(context.Background(), &Intent{Operation: "testOp", Input: map[string]any{"customer.id": "cust_1"}, Purpose: "marketing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gets.Load() != 0 || len(receipt.Facts) != 0 || !strings.Contains(receipt.Failed["balance"], "marketing") {
+		t.Errorf("expected the restricted fact skipped, got %+v after %d gets", receipt, gets.Load())
+	}
+}
+
+func TestValidate_dataUse(t *testing.T) {
+	c := purposeContract()
+	c.DataUse["customer.email"] = []string{"billing"}
+	c.DataUse["customer.id"] = []string{}
+
+	var got []string
+	for _, d := range Validate(c, time.Now()) {
+		got = append(got, d.String())
+	}
+	want := []string{
+		"error: data_use: customer.email is not a declared fact",
+		"warning: data_use: customer.id allows no purpose, so operations that need it always refuse",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s", strings.Join(got, "\n"))
+	}
+}
+
+func TestComposeContract_dataUseConflicts(t *testing.T) {
+	base := &Contract{DataUse: map[string][]string{"balance": {"billing", "support"}}}
+	domain := purposeContract()
+	domain.DataUse["balance"] = []string{"support", "billing"}
+
+	c, err := composeContract(domain, []baseContract{{"common", base}})
+	if err != nil {
+		t.Fatalf("expected a reordered policy accepted, got %v", err)
+	}
+	if !c.PurposeAllows("balance", "support") || c.PurposeAllows("balance", "marketing") {
+		t.Errorf("data_use not merged: %v", c.DataUse)
+	}
+
+	domain.DataUse["balance"] = []string{"billing"}
+	if _, err := composeContract(domain, []baseContract{{"common", base}}); err == nil || !strings.Contains(err.Error(), "conflicts") {
+		t.Errorf("expected a conflict, got %v", err)
+	}
+}
+
+func TestCompileSources_dataUse(t *testing.T) {
+	c, err := CompileSources(map[string][]byte{"contract.cue": []byte(`
+facts: "customer.email": {source: "input"}
+data_use: "customer.email": ["billing"]
+`)}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.PurposeAllows("customer.email", "billing") || c.PurposeAllows("customer.email", "marketing") {
+		t.Errorf("data_use not extracted: %v", c.DataUse)
+	}
+}
@@ -0,0 +1,104 @@
+package engine
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+)
+
+// QueueDef declares an escalation queue in a contract's queue catalog.
+type QueueDef struct {
+	SLA         string `json:"sla"`   // Go duration, e.g. "4h": how soon an escalation must be decided
+	Owner       string `json:"owner"` // team accountable for the queue
+	Description string `json:"description,omitempty"`
+	// HardExpiry, a Go duration no shorter than SLA, is how long an
+	// escalation may stay pending; then it is resolved with OnExpiry,
+	// DecisionDeny or DecisionExecute. Without it escalations wait
+	// indefinitely.
+	HardExpiry string `json:"hard_expiry,omitempty"`
+	OnExpiry   string `json:"on_expiry,omitempty"`
+}
+
+// SLADuration returns the parsed SLA, or 0 if it is missing or malformed.
+func (q QueueDef) SLADuration() time.Duration {
+	sla, err := time.ParseDuration(q.SLA)
+	if err != nil || sla < 0 {
+		return 0
+	}
+	return sla
+}
+
+// HardExpiryDuration returns the parsed hard expiry, or 0 if it is missing
+// or malformed.
+func (q QueueDef) HardExpiryDuration() time.Duration {
+	expiry, err := time.ParseDuration(q.HardExpiry)
+	if err != nil || expiry < 0 {
+		return 0
+	}
+	return expiry
+}
+
+// escalationQueues returns the queues rule r can escalate to: its literal
+// queue, or for a {param: name} queue the param's default and its value in
+// bindings.
+func (c *Contract) escalationQueues(r RuleDef, bindings map[string]any) []string {
+	e := r.Verdict.Escalate
+	if e == nil {
+		return nil
+	}
+	if e.QueueParam == "" {
+		return []string{e.Queue}
+	}
+	var queues []string
+	if q, ok := c.Params[e.QueueParam].Default.(string); ok {
+		queues = append(queues, q)
+	}
+	if q, ok := bindings[e.QueueParam].(string); ok && !slices.Contains(queues, q) {
+		queues = append(queues, q)
+	}
+	return queues
+}
+
+// checkQueues checks a composed contract's queue catalog: every queue needs
+// a positive SLA and an owner, a hard expiry needs an expiry decision, and
+// once a catalog is declared every escalate verdict must name a queue in
+// it. Contracts without a catalog may escalate to any queue.
+func checkQueues(c *Contract) error {
+	for _, name := range slices.Sorted(maps.Keys(c.Queues)) {
+		q := c.Queues[name]
+		sla, err := time.ParseDuration(q.SLA)
+		if err != nil || sla <= 0 {
+			return fmt.Errorf("queue %q: sla %q is not a positive duration", name, q.SLA)
+		}
+		if q.Owner == "" {
+			return fmt.Errorf("queue %q: owner is required", name)
+		}
+		switch {
+		case q.HardExpiry == "" && q.OnExpiry != "":
+			return fmt.Errorf("queue %q: on_expiry needs a hard_expiry", name)
+		case q.HardExpiry == "":
+		case q.HardExpiryDuration() < sla:
+			return fmt.Errorf("queue %q: hard_expiry %q must be a duration no shorter than the sla", name, q.HardExpiry)
+		case q.OnExpiry != DecisionDeny && q.OnExpiry != DecisionExecute:
+			return fmt.Errorf("queue %q: on_expiry must be %s or %s, got %q", name, DecisionDeny, DecisionExecute, q.OnExpiry)
+		}
+	}
+	return checkQueueRefs(c, nil)
+}
+
+// checkQueueRefs requires the queues c's escalate verdicts name, with
+// bindings applied, to be in its queue catalog.
+func checkQueueRefs(c *Contract, bindings map[string]any) error {
+	if len(c.Queues) == 0 {
+		return nil
+	}
+	for _, r := range c.Rules {
+		for _, q := range c.escalationQueues(r, bindings) {
+			if _, ok := c.Queues[q]; !ok {
+				return fmt.Errorf("rule %q escalates to queue %q, which the queue catalog does not declare", r.ID, q)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const queueCatalog = `queues: "payment-review": {sla: "4h", owner: "payments-ops"}
+`
+
+func compileQueues(t *testing.T, src string) (*Contract, error) {
+	t.Helper()
+	return CompileSources(map[string][]byte{"shop/contract.cue": []byte(src)}, nil)
+}
+
+func TestCompileSources_readsQueueCatalog(t *testing.T) {
+	c, err := compileQueues(t, queueCatalog+`rules: [{id: "big", when: {fact: "x", equals: 1}, verdict: escalate: {queue: "payment-review", reason: "big"}}]
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := c.Queues["payment-review"]
+	if q.Owner != "payments-ops" || q.SLADuration() != 4*time.Hour {
+		t.Errorf("unexpected queue %+v", q)
+	}
+}
+
+func TestCompileSources_rejectsEscalationOutsideQueueCatalog(t *testing.T) {
+	_, err := compileQueues(t, queueCatalog+`rules: [{id: "big", when: {fact: "x", equals: 1}, verdict: escalate: {queue: "fraud-review", reason: "big"}}]
+`)
+	if err == nil || !strings.Contains(err.Error(), `rule "big" escalates to queue "fraud-review"`) {
+		t.Errorf("expected the undeclared queue rejected, got %v", err)
+	}
+
+	if _, err := compileQueues(t, `rules: [{id: "big", when: {fact: "x", equals: 1}, verdict: escalate: {queue: "fraud-review", reason: "big"}}]
+`); err != nil {
+		t.Errorf("expected any queue allowed without a catalog, got %v", err)
+	}
+}
+
+func TestCompileSources_rejectsInvalidQueueDefinitions(t *testing.T) {
+	for src, want := range map[string]string{
+		`queues: q: {sla: "soon", owner: "ops"}`:                                       `queue "q": sla "soon" is not a positive duration`,
+		`queues: q: {sla: "1h", owner: ""}`:                                            `queue "q": owner is required`,
+		`queues: q: {sla: "1h", owner: "ops", on_expiry: "deny"}`:                      `queue "q": on_expiry needs a hard_expiry`,
+		`queues: q: {sla: "1h", owner: "ops", hard_expiry: "30m", on_expiry: "deny"}`:  `queue "q": hard_expiry "30m" must be a duration no shorter than the sla`,
+		`queues: q: {sla: "1h", owner: "ops", hard_expiry: "2h", on_expiry: "ignore"}`: `queue "q": on_expiry must be deny or execute, got "ignore"`,
+	} {
+		if _, err := compileQueues(t, src); err == nil || err.Error() != want {
+			t.Errorf("%s: got %v, want %s", src, err, want)
+		}
+	}
+}
+
+func TestBind_rejectsBoundQueueOutsideCatalog(t *testing.T) {
+	c, err := compileQueues(t, queueCatalog+`params: review_queue: default: "payment-review"
+rules: [{id: "big", when: {fact: "x", equals: 1}, verdict: escalate: {queue: {param: "review_queue"}, reason: "big"}}]
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Bind("prod", map[string]any{"review_queue": "payment-review"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	err = c.Bind("prod", map[string]any{"review_queue": "fraud-review"})
+	if err == nil || !strings.Contains(err.Error(), `environment "prod": rule "big" escalates to queue "fraud-review"`) {
+		t.Errorf("expected the bound queue rejected, got %v", err)
+	}
+}
+
+func TestComposeContract_conflictingQueueFails(t *testing.T) {
+	base := makeMinimalContract()
+	base.Queues = map[string]QueueDef{"payment-review": {SLA: "4h", Owner: "payments-ops"}}
+	domain := makeMinimalContract()
+	domain.Queues = map[string]QueueDef{"payment-review": {SLA: "1h", Owner: "payments-ops"}}
+
+	_, err := composeContract(domain, []baseContract{{"common", base}})
+	if err == nil || !strings.Contains(err.Error(), `queue "payment-review" conflicts with its definition in common`) {
+		t.Errorf("expected a conflict, got %v", err)
+	}
+
+	domain.Queues = map[string]QueueDef{"fraud-review": {SLA: "1h", Owner: "risk"}}
+	c, err := composeContract(domain, []baseContract{{"common", base}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Queues) != 2 {
+		t.Errorf("expected both catalogs merged, got %v", c.Queues)
+	}
+}
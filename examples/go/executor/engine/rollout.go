@@ -0,0 +1,76 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+)
+
+// RolloutDef applies a rule to a share of requests only, so an aggressive
+// rule can be introduced gradually, e.g.
+//
+//	rollout: {percent: 10, key: "customer.id"}
+//
+// Requests are bucketed by the value of the key fact, hashed with the rule
+// ID: the same customer is always in or out of a rule's rollout, and raising
+// the percentage only adds customers. For requests outside the rollout a
+// matching rule is a shadow verdict: recorded in the audit record's
+// ShadowVerdicts, but without effect. A request without the key fact is
+// outside the rollout.
+type RolloutDef struct {
+	Percent float64 `json:"percent"`
+	Key     string  `json:"key"`
+}
+
+// inRollout reports whether the request with the given facts is in rule's
+// rollout; every request is in that of a rule without one.
+func inRollout(rule *RuleDef, facts *FactSet) bool {
+	r := rule.Rollout
+	if r == nil {
+		return true
+	}
+	v, ok := facts.Get(r.Key)
+	if !ok || v == nil {
+		return false
+	}
+	sum := sha256.Sum256([]byte(rule.ID + "\x00" + fmt.Sprint(v)))
+	return float64(binary.BigEndian.Uint64(sum[:8])%10000) < r.Percent*100
+}
+
+// splitShadow separates the shadow verdicts of rules outside their rollout
+// from those that take effect.
+func splitShadow(verdicts []Verdict) (live, shadow []Verdict) {
+	for _, v := range verdicts {
+		if v.Shadow {
+			shadow = append(shadow, v)
+		} else {
+			live = append(live, v)
+		}
+	}
+	return live, shadow
+}
+
+// validateRollout checks a rule's rollout declaration.
+func validateRollout(c *Contract, r RuleDef) []Diagnostic {
+	if r.Rollout == nil {
+		return nil
+	}
+	var diags []Diagnostic
+	if r.Rollout.Percent < 0 || r.Rollout.Percent > 100 {
+		diags = append(diags, Diagnostic{
+			Severity: SeverityError,
+			Rule:     r.ID,
+			Message:  fmt.Sprintf("rollout percent %v is not between 0 and 100", r.Rollout.Percent),
+		})
+	}
+	_, fact := c.Facts[r.Rollout.Key]
+	_, derived := c.DerivedFacts[r.Rollout.Key]
+	if !fact && !derived {
+		diags = append(diags, Diagnostic{
+			Severity: SeverityError,
+			Rule:     r.ID,
+			Message:  fmt.Sprintf("rollout key %q is not a declared fact", r.Rollout.Key),
+		})
+	}
+	return diags
+}
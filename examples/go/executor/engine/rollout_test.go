@@ -0,0 +1,129 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func rolloutContract(percent float64) *Contract {
+	c := makeSimpleContract("new-deny",
+		VerdictDef{Deny: &DenyVerdict{Code: "BLOCKED", Error: ErrorEnvelope{Code: "BLOCKED", HttpStatus: 403}}},
+		Condition{Fact: "customer.status", Equals: "new"},
+	)
+	c.Facts["customer.id"] = FactDef{Source: "input"}
+	c.Rules[0].Rollout = &RolloutDef{Percent: percent, Key: "customer.id"}
+	return c
+}
+
+func TestEngine_rolloutAppliesRuleToShareOfKeys(t *testing.T) {
+	sink := &recordingSink{}
+	e := NewEngine(&mockPorts{}, WithAuditSink(sink), WithDecisionCache(100))
+	ctx := context.Background()
+	denied := func(percent float64) map[string]bool {
+		t.Helper()
+		e.LoadContract(rolloutContract(percent), fmt.Sprintf("v%v", percent))
+		out := map[string]bool{}
+		for i := range 400 {
+			customer := fmt.Sprintf("cust_%d", i)
+			resp, err := e.Evaluate(ctx, &Request{Operation: "testOp", Input: map[string]any{"customer.id": customer, "customer.status": "new"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			rec := sink.recs[len(sink.recs)-1]
+			if resp.Outcome == OutcomeDenied {
+				out[customer] = true
+			} else if len(rec.ShadowVerdicts) != 1 || rec.ShadowVerdicts[0].Rule != "new-deny" {
+				t.Fatalf("%s: expected a shadow verdict in the audit record, got %+v", customer, rec.ShadowVerdicts)
+			}
+		}
+		return out
+	}
+
+	if n := len(denied(0)); n != 0 {
+		t.Errorf("expected no denials at 0%%, got %d", n)
+	}
+	ten := denied(10)
+	if len(ten) < 20 || len(ten) > 60 {
+		t.Errorf("expected about 40 of 400 denied at 10%%, got %d", len(ten))
+	}
+	// Raising the percentage keeps everyone already in the rollout.
+	fifty := denied(50)
+	for customer := range ten {
+		if !fifty[customer] {
+			t.Errorf("%s left the rollout when it grew", customer)
+		}
+	}
+	if n := len(denied(100)); n != 400 {
+		t.Errorf("expected every request denied at 100%%, got %d", n)
+	}
+
+	// Without the key a request is outside the rollout.
+	resp, _ := e.Evaluate(ctx, &Request{Operation: "testOp", Input: map[string]any{"customer.status": "new"}})
+	if resp.Outcome != OutcomeExecuted {
+		t.Errorf("expected a request without the key executed, got %s", resp.Outcome)
+	}
+}
+
+func TestValidate_reportsMalformedRollouts(t *testing.T) {
+	c := rolloutContract(150)
+	c.Rules[0].Rollout.Key = "account.id"
+	var got []string
+	for _, d := range Validate(c, time.Now()) {
+		if strings.Contains(d.Message, "rollout") && d.Rule == "new-deny" {
+			got = append(got, d.Message)
+		}
+	}
+	if len(got) != 2 {
+		t.Errorf("expected 2 diagnostics, got %q", got)
+	}
+}
+
+func TestEngine_SimulateBatch_leavesOutRowsOutsideRollout(t *testing.T) {
+	e := NewEngine(&mockPorts{})
+	e.LoadContract(rolloutContract(50), "v1")
+	rows := make([]map[string]any, 200)
+	for i := range rows {
+		rows[i] = map[string]any{"customer.id": fmt.Sprintf("cust_%d", i), "customer.status": "new"}
+	}
+	resp, err := e.SimulateBatch(&BatchSimulateRequest{Operation: "testOp", Rows: rows})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Vectorized) != 0 {
+		t.Errorf("expected the rollout rule evaluated row by row, got vectorized %v", resp.Vectorized)
+	}
+	denied := 0
+	for i, row := range rows {
+		want, err := e.Simulate(&SimulateRequest{Operation: "testOp", Facts: row})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := resp.Rows[i]; got.Outcome != want.Outcome || len(got.Rules) != len(want.Verdicts) {
+			t.Fatalf("row %d: got %s %v, want %s %+v", i, got.Outcome, got.Rules, want.Outcome, want.Verdicts)
+		}
+		if len(want.Verdicts) > 0 {
+			denied++
+		}
+	}
+	if denied == 0 || denied == len(rows) || resp.RuleMatches["new-deny"] != denied {
+		t.Errorf("expected %d rule matches for a partial rollout, got %d", denied, resp.RuleMatches["new-deny"])
+	}
+}
+
+func TestExplain_marksRulesOutsideRolloutAsShadow(t *testing.T) {
+	for _, percent := range []float64{0, 100} {
+		e := NewEngine(&mockPorts{})
+		e.LoadContract(rolloutContract(percent), "v1")
+		resp, err := e.Simulate(&SimulateRequest{Operation: "testOp", Facts: map[string]any{"customer.id": "cust_1", "customer.status": "new"}, Explain: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		live := percent == 100
+		if tr := resp.Explain.Rules[0]; tr.Matched != live || tr.Shadow == live || !tr.When.Passed {
+			t.Errorf("%v%%: expected matched %v and shadow %v, got %+v", percent, live, !live, tr)
+		}
+	}
+}
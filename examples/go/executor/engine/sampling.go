@@ -0,0 +1,116 @@
+package engine
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Sampling bounds the volume and content of audit records sent to a sink,
+// for telemetry on high-QPS operations where keeping every record costs more
+// than it tells.
+type Sampling struct {
+	// Rates maps an outcome to the fraction of its records kept, from 0 to
+	// 1. Outcomes not listed are always kept.
+	Rates map[string]float64
+	// Suppress lists input fields and fact names removed from every kept
+	// record.
+	Suppress []string
+}
+
+// SampledSink wraps next so it only receives the records s keeps, with
+// suppressed fields removed. Records kept at a rate below 1 carry it as
+// SampleRate, so counts can be scaled back up.
+//
+// Sampling is decided by a hash of the invocation ID, not at random, so
+// every sink sampled at the same rate keeps the same decisions.
+func SampledSink(next AuditSink, s Sampling) AuditSink {
+	suppress := make(map[string]bool, len(s.Suppress))
+	for _, f := range s.Suppress {
+		suppress[strings.TrimSpace(f)] = true
+	}
+	return &sampledSink{next: next, rates: s.Rates, suppress: suppress}
+}
+
+type sampledSink struct {
+	next     AuditSink
+	rates    map[string]float64
+	suppress map[string]bool
+}
+
+func (s *sampledSink) Record(ctx context.Context, rec *AuditRecord) {
+	rate, sampled := s.rates[rec.Outcome]
+	sampled = sampled && rate < 1
+	if sampled && samplePoint(rec.InvocationID) >= rate {
+		return
+	}
+	if !sampled && len(s.suppress) == 0 {
+		s.next.Record(ctx, rec)
+		return
+	}
+
+	// Other sinks share rec, so changes go on a copy.
+	cp := *rec
+	if sampled {
+		cp.SampleRate = rate
+	}
+	cp.Input = s.without(rec.Input)
+	cp.FactSnapshot = s.without(rec.FactSnapshot)
+	s.next.Record(ctx, &cp)
+}
+
+// without returns m minus the suppressed keys, copying only if needed.
+func (s *sampledSink) without(m map[string]any) map[string]any {
+	hit := false
+	for k := range m {
+		if s.suppress[k] {
+			hit = true
+			break
+		}
+	}
+	if !hit {
+		return m
+	}
+	out := make(map[string]any, len(m))
+	for k, v := range m {
+		if !s.suppress[k] {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// samplePoint maps an invocation ID uniformly onto [0, 1).
+func samplePoint(id string) float64 {
+	sum := sha256.Sum256([]byte(id))
+	return float64(binary.BigEndian.Uint64(sum[:])>>11) / (1 << 53)
+}
+
+// ParseSampleRates parses a comma-separated list of outcome=rate pairs, such
+// as "executed=0.01,would_execute=0.1".
+func ParseSampleRates(spec string) (map[string]float64, error) {
+	rates := map[string]float64{}
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		outcome, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("sample rate %q: expected outcome=rate", pair)
+		}
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(rate) || rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("sample rate %q: rate must be between 0 and 1", pair)
+		}
+		if _, err := ParseOutcome(strings.TrimSpace(outcome)); err != nil {
+			return nil, fmt.Errorf("sample rate %q: %w", pair, err)
+		}
+		rates[strings.TrimSpace(outcome)] = rate
+	}
+	return rates, nil
+}
@@ -0,0 +1,95 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestSampledSink_keepsConfiguredFraction(t *testing.T) {
+	sink := &recordingSink{}
+	s := SampledSink(sink, Sampling{Rates: map[string]float64{"executed": 0.1}})
+
+	for i := range 10000 {
+		s.Record(context.Background(), &AuditRecord{InvocationID: fmt.Sprintf("inv_%d", i), Outcome: "executed"})
+		s.Record(context.Background(), &AuditRecord{InvocationID: fmt.Sprintf("inv_d%d", i), Outcome: "denied"})
+	}
+
+	var executed, denied int
+	for _, rec := range sink.recs {
+		switch rec.Outcome {
+		case "executed":
+			executed++
+			if rec.SampleRate != 0.1 {
+				t.Fatalf("expected sample_rate 0.1, got %v", rec.SampleRate)
+			}
+		case "denied":
+			denied++
+			if rec.SampleRate != 0 {
+				t.Fatalf("unsampled record has sample_rate %v", rec.SampleRate)
+			}
+		}
+	}
+	if denied != 10000 {
+		t.Errorf("expected every deny kept, got %d", denied)
+	}
+	if executed < 800 || executed > 1200 {
+		t.Errorf("expected about 1000 executes kept, got %d", executed)
+	}
+}
+
+func TestSampledSink_sameDecisionAcrossSinks(t *testing.T) {
+	a, b := &recordingSink{}, &recordingSink{}
+	rates := Sampling{Rates: map[string]float64{"executed": 0.5}}
+	sa, sb := SampledSink(a, rates), SampledSink(b, rates)
+	for i := range 200 {
+		rec := &AuditRecord{InvocationID: fmt.Sprintf("inv_%d", i), Outcome: "executed"}
+		sa.Record(context.Background(), rec)
+		sb.Record(context.Background(), rec)
+	}
+	if len(a.recs) != len(b.recs) {
+		t.Fatalf("sinks kept %d and %d records", len(a.recs), len(b.recs))
+	}
+	for i := range a.recs {
+		if a.recs[i].InvocationID != b.recs[i].InvocationID {
+			t.Fatalf("sinks diverge at %d: %s vs %s", i, a.recs[i].InvocationID, b.recs[i].InvocationID)
+		}
+	}
+}
+
+func TestSampledSink_suppressesFieldsOnCopy(t *testing.T) {
+	sink := &recordingSink{}
+	s := SampledSink(sink, Sampling{Suppress: []string{"card.number"}})
+	rec := &AuditRecord{
+		Outcome:      "executed",
+		Input:        map[string]any{"card.number": "4111", "amount": 10},
+		FactSnapshot: map[string]any{"card.number": "4111", "customer.tier": "gold"},
+	}
+	s.Record(context.Background(), rec)
+
+	got := sink.recs[0]
+	if _, ok := got.Input["card.number"]; ok || got.Input["amount"] != 10 {
+		t.Errorf("unexpected input %v", got.Input)
+	}
+	if _, ok := got.FactSnapshot["card.number"]; ok || got.FactSnapshot["customer.tier"] != "gold" {
+		t.Errorf("unexpected fact snapshot %v", got.FactSnapshot)
+	}
+	if rec.Input["card.number"] != "4111" {
+		t.Error("suppression modified the shared record")
+	}
+}
+
+func TestParseSampleRates(t *testing.T) {
+	rates, err := ParseSampleRates("executed=0.01, would_execute=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rates["executed"] != 0.01 || rates["would_execute"] != 0 || len(rates) != 2 {
+		t.Errorf("unexpected rates %v", rates)
+	}
+	for _, bad := range []string{"executed", "executed=2", "executed=x", "executed=NaN", "excuted=0.5"} {
+		if _, err := ParseSampleRates(bad); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
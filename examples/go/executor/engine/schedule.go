@@ -0,0 +1,74 @@
+package engine
+
+import "time"
+
+// pendingContract is a contract waiting for its activation time.
+type pendingContract struct {
+	contract *Contract
+	etag     string
+	at       time.Time
+}
+
+// PendingContract describes the contract scheduled to activate next.
+type PendingContract struct {
+	Version    string    `json:"version,omitempty"`
+	ETag       string    `json:"contract_etag"`
+	ActivateAt time.Time `json:"activate_at"`
+}
+
+// ScheduleContract stages c to become the active contract at the given time,
+// replacing any earlier schedule. The switch happens on the first evaluation
+// at or after that instant by the engine's clock, so replicas with
+// synchronised clocks switch together regardless of when they fetched it.
+func (e *Engine) ScheduleContract(c *Contract, etag string, at time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.pending = &pendingContract{contract: c, etag: etag, at: at}
+}
+
+// CancelPending drops the scheduled contract if it has not activated yet.
+// One that is already due is activated instead, since other replicas may
+// have switched to it.
+func (e *Engine) CancelPending() {
+	e.activateDue()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.pending = nil
+}
+
+// Pending returns the scheduled contract, or nil if none is pending.
+func (e *Engine) Pending() *PendingContract {
+	e.activateDue()
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.pending == nil {
+		return nil
+	}
+	return &PendingContract{
+		Version:    e.pending.contract.Version,
+		ETag:       e.pending.etag,
+		ActivateAt: e.pending.at,
+	}
+}
+
+// activateDue promotes the pending contract once its activation time has
+// passed.
+func (e *Engine) activateDue() {
+	e.mu.RLock()
+	p := e.pending
+	e.mu.RUnlock()
+	if p == nil || e.now().Before(p.at) {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.pending != p {
+		return // promoted or replaced concurrently
+	}
+	e.pending = nil
+	e.contract = p.contract
+	e.contractETag = p.etag
+	e.retainVersion(p.contract, p.etag)
+	e.invalidateDecisions()
+}
@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// manualClock is a settable clock for scheduling tests.
+type manualClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *manualClock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *manualClock) set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = t
+}
+
+func TestEngine_ScheduleContract_activatesAtScheduledTime(t *testing.T) {
+	clock := &manualClock{t: *mustTime(t, "2026-05-01T11:59:00Z")}
+	e := NewEngine(&mockPorts{}, WithClock(clock.now))
+	e.LoadContract(versionedContract("1.0.0"), "etag-1")
+	e.ScheduleContract(versionedContract("1.1.0"), "etag-2", *mustTime(t, "2026-05-01T12:00:00Z"))
+
+	resp, _ := e.Evaluate(context.Background(), &Request{Operation: "testOp", DryRun: true})
+	if resp.ContractSemver != "1.0.0" {
+		t.Fatalf("expected 1.0.0 before activation, got %q", resp.ContractSemver)
+	}
+	if p := e.Pending(); p == nil || p.ETag != "etag-2" || p.Version != "1.1.0" {
+		t.Fatalf("expected pending etag-2, got %+v", p)
+	}
+
+	clock.set(*mustTime(t, "2026-05-01T12:00:00Z"))
+	resp, _ = e.Evaluate(context.Background(), &Request{Operation: "testOp", DryRun: true})
+	if resp.ContractSemver != "1.1.0" {
+		t.Errorf("expected 1.1.0 at activation, got %q", resp.ContractSemver)
+	}
+	if e.ETag() != "etag-2" {
+		t.Errorf("expected active etag-2, got %s", e.ETag())
+	}
+	if p := e.Pending(); p != nil {
+		t.Errorf("expected no pending contract after activation, got %+v", p)
+	}
+}
+
+func TestEngine_ScheduleContract_staleETagRejectedAfterSwitch(t *testing.T) {
+	clock := &manualClock{t: *mustTime(t, "2026-05-01T12:00:01Z")}
+	e := NewEngine(&mockPorts{}, WithClock(clock.now))
+	e.LoadContract(versionedContract("1.0.0"), "etag-1")
+	e.ScheduleContract(versionedContract("1.1.0"), "etag-2", *mustTime(t, "2026-05-01T12:00:00Z"))
+
+	resp, _ := e.Evaluate(context.Background(), &Request{Operation: "testOp", ContractETag: "etag-1"})
+	if resp.Error == nil || resp.Error.Code != "CONTRACT_VERSION_MISMATCH" {
+		t.Errorf("expected CONTRACT_VERSION_MISMATCH, got %+v", resp.Error)
+	}
+}
+
+func TestEngine_CancelPending_dropsFutureSchedule(t *testing.T) {
+	clock := &manualClock{t: *mustTime(t, "2026-05-01T00:00:00Z")}
+	e := NewEngine(&mockPorts{}, WithClock(clock.now))
+	e.LoadContract(versionedContract("1.0.0"), "etag-1")
+	e.ScheduleContract(versionedContract("2.0.0"), "etag-2", *mustTime(t, "2026-06-01T00:00:00Z"))
+
+	e.CancelPending()
+	clock.set(*mustTime(t, "2026-07-01T00:00:00Z"))
+	if e.ETag() != "etag-1" {
+		t.Errorf("expected cancelled schedule never to activate, active is %s", e.ETag())
+	}
+}
+
+func TestEngine_CancelPending_activatesDueSchedule(t *testing.T) {
+	clock := &manualClock{t: *mustTime(t, "2026-06-01T00:00:00Z")}
+	e := NewEngine(&mockPorts{}, WithClock(clock.now))
+	e.LoadContract(versionedContract("1.0.0"), "etag-1")
+	e.ScheduleContract(versionedContract("2.0.0"), "etag-2", *mustTime(t, "2026-06-01T00:00:00Z"))
+
+	e.CancelPending()
+	if e.ETag() != "etag-2" {
+		t.Errorf("expected due schedule to activate rather than cancel, active is %s", e.ETag())
+	}
+}
@@ -0,0 +1,104 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// ScoreNone is the score of a sum below every band.
+const ScoreNone = "none"
+
+// ScoreBand labels the scores from Min up to the next band's Min.
+type ScoreBand struct {
+	Label string  `json:"label"`
+	Min   float64 `json:"min"`
+}
+
+// weightedSum adds up the weighted terms of a weighted_sum or score. A
+// term is a numeric fact, a boolean fact counting 1 when true, or an
+// inline condition {fact, op, value} counting 1 when it holds; it is
+// multiplied by its weight, 1 by default. Absent facts and facts of other
+// types count 0, so a missing signal adds no risk rather than failing the
+// score.
+func weightedSum(terms []DerivationArg, facts *FactSet) float64 {
+	var sum float64
+	for _, t := range terms {
+		weight := 1.0
+		if t.Weight != nil {
+			weight = *t.Weight
+		}
+		var v any
+		if t.Fact != "" {
+			v, _ = facts.GetPath(t.Fact)
+		} else {
+			v = t.Value
+		}
+		if t.Fact != "" && t.Op != "" {
+			v = applyOp(t.Op, v, t.Value)
+		}
+		switch x := v.(type) {
+		case bool:
+			if x {
+				sum += weight
+			}
+		default:
+			if f, ok := toFloat(x); ok && !math.IsNaN(f) {
+				sum += weight * f
+			}
+		}
+	}
+	// Rounded to hide float error in comparisons against thresholds.
+	return math.Round(sum*1e9) / 1e9
+}
+
+// scoreBand returns the label of the highest band whose Min sum reaches,
+// or ScoreNone.
+func scoreBand(bands []ScoreBand, sum float64) string {
+	label, best := ScoreNone, math.Inf(-1)
+	for _, b := range bands {
+		if sum >= b.Min && b.Min > best {
+			label, best = b.Label, b.Min
+		}
+	}
+	return label
+}
+
+// checkScore reports what is wrong with a weighted_sum or score
+// derivation.
+func checkScore(d Derivation) []string {
+	var problems []string
+	if len(d.Args) == 0 {
+		problems = append(problems, fmt.Sprintf("%s has no terms", d.Fn))
+	}
+	for i, t := range d.Args {
+		if t.Op != "" && t.Fact == "" {
+			problems = append(problems, fmt.Sprintf("%s term %d has an op but no fact", d.Fn, i))
+		}
+	}
+	if d.Fn != "score" {
+		if len(d.Bands) > 0 {
+			problems = append(problems, "bands apply to score, not weighted_sum")
+		}
+		return problems
+	}
+	if len(d.Bands) == 0 {
+		problems = append(problems, "score has no bands")
+	}
+	labels := map[string]bool{}
+	mins := make([]float64, 0, len(d.Bands))
+	for _, b := range d.Bands {
+		if b.Label == "" || labels[b.Label] {
+			problems = append(problems, fmt.Sprintf("score band labels must be unique and non-empty, got %q", b.Label))
+		}
+		labels[b.Label] = true
+		mins = append(mins, b.Min)
+	}
+	sort.Float64s(mins)
+	for i := 1; i < len(mins); i++ {
+		if mins[i] == mins[i-1] {
+			problems = append(problems, fmt.Sprintf("score bands share the min %v", mins[i]))
+		}
+	}
+	return problems
+}
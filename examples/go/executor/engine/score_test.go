@@ -0,0 +1,80 @@
+package engine
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func weight(w float64) *float64 { return &w }
+
+// riskContract scores payments on three signals and escalates high risk.
+func riskContract() *Contract {
+	c := makeMinimalContract()
+	c.Facts["payment.amount"] = FactDef{Source: "input", Required: true}
+	c.Facts["customer.new"] = FactDef{Source: "input", Required: false}
+	c.Facts["customer.country"] = FactDef{Source: "input", Required: false}
+	terms := []DerivationArg{
+		{Fact: "payment.amount", Op: "greater_than", Value: 1000.0, Weight: weight(40)},
+		{Fact: "customer.new", Weight: weight(30)},
+		{Fact: "customer.country", Op: "equals", Value: "XX", Weight: weight(50)},
+		{Fact: "payment.amount", Weight: weight(0.001)},
+	}
+	c.DerivedFacts = map[string]DerivedFactDef{
+		"risk.points": {Derivation: Derivation{Fn: "weighted_sum", Args: terms}},
+		"risk.level": {Derivation: Derivation{Fn: "score", Args: terms, Bands: []ScoreBand{
+			{Label: "low", Min: 0}, {Label: "high", Min: 70}, {Label: "medium", Min: 30},
+		}}},
+	}
+	c.Rules = []RuleDef{{ID: "high-risk", When: Condition{Fact: "risk.level", Equals: "high"}, Verdict: VerdictDef{Deny: &DenyVerdict{Code: "HIGH_RISK"}}}}
+	c.Operations["testOp"] = OperationDef{ConstrainedBy: []string{"high-risk"}}
+	return c
+}
+
+func TestEngine_weightedScore(t *testing.T) {
+	sink := &recordingSink{}
+	e := NewEngine(&mockPorts{}, WithAuditSink(sink))
+	e.LoadContract(riskContract(), "v1")
+	for _, tc := range []struct {
+		input   map[string]any
+		points  float64
+		level   string
+		outcome Outcome
+	}{
+		{map[string]any{"payment.amount": 500.0}, 0.5, "low", OutcomeExecuted},
+		{map[string]any{"payment.amount": 2000.0, "customer.new": false}, 42, "medium", OutcomeExecuted},
+		{map[string]any{"payment.amount": 2000.0, "customer.new": true}, 72, "high", OutcomeDenied},
+		{map[string]any{"payment.amount": -1000.0, "customer.country": "XX"}, 49, "medium", OutcomeExecuted},
+	} {
+		resp, err := e.Evaluate(context.Background(), &Request{Operation: "testOp", Input: tc.input})
+		if err != nil {
+			t.Fatal(err)
+		}
+		facts := sink.recs[len(sink.recs)-1].FactSnapshot
+		if facts["risk.points"] != tc.points || facts["risk.level"] != tc.level || resp.Outcome != tc.outcome {
+			t.Errorf("%v: got %v points, %v, %s", tc.input, facts["risk.points"], facts["risk.level"], resp.Outcome)
+		}
+	}
+}
+
+func TestEngine_Warmup_reportsMalformedScores(t *testing.T) {
+	c := riskContract()
+	c.DerivedFacts["risk.level"] = DerivedFactDef{Derivation: Derivation{Fn: "score", Args: c.DerivedFacts["risk.level"].Derivation.Args, Bands: []ScoreBand{
+		{Label: "low", Min: 0}, {Label: "low", Min: 50}, {Label: "high", Min: 50},
+	}}}
+	c.DerivedFacts["risk.empty"] = DerivedFactDef{Derivation: Derivation{Fn: "weighted_sum", Bands: []ScoreBand{{Label: "x"}}}}
+	var got []string
+	for _, d := range NewEngine(&mockPorts{}).Warmup(c, time.Now()) {
+		got = append(got, d.Message)
+	}
+	want := []string{
+		"derived fact risk.empty: weighted_sum has no terms",
+		"derived fact risk.empty: bands apply to score, not weighted_sum",
+		`derived fact risk.level: score band labels must be unique and non-empty, got "low"`,
+		"derived fact risk.level: score bands share the min 50",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
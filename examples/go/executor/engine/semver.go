@@ -0,0 +1,154 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// semver is a MAJOR.MINOR.PATCH version. Pre-release and build suffixes are
+// not supported; contracts version their public behaviour only.
+type semver struct {
+	major, minor, patch int
+}
+
+func (v semver) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.major, v.minor, v.patch)
+}
+
+func (v semver) less(o semver) bool {
+	if v.major != o.major {
+		return v.major < o.major
+	}
+	if v.minor != o.minor {
+		return v.minor < o.minor
+	}
+	return v.patch < o.patch
+}
+
+// parseSemver parses "1", "1.2" or "1.2.3" (optionally prefixed with "v").
+// Missing components are zero.
+func parseSemver(s string) (semver, error) {
+	var v semver
+	parts, err := versionParts(s)
+	if err != nil {
+		return v, err
+	}
+	for i, p := range parts {
+		if p < 0 {
+			return v, fmt.Errorf("invalid version %q: wildcard not allowed", s)
+		}
+		switch i {
+		case 0:
+			v.major = p
+		case 1:
+			v.minor = p
+		case 2:
+			v.patch = p
+		}
+	}
+	return v, nil
+}
+
+// versionParts splits a version into up to three numeric components;
+// "x" and "*" components are returned as -1.
+func versionParts(s string) ([]int, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if s == "" {
+		return nil, fmt.Errorf("empty version")
+	}
+	fields := strings.Split(s, ".")
+	if len(fields) > 3 {
+		return nil, fmt.Errorf("invalid version %q", s)
+	}
+	parts := make([]int, len(fields))
+	for i, f := range fields {
+		if f == "x" || f == "X" || f == "*" {
+			parts[i] = -1
+			continue
+		}
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid version %q", s)
+		}
+		parts[i] = n
+	}
+	return parts, nil
+}
+
+// versionRange is a parsed client version constraint. Supported forms:
+//
+//	"1.2.3"   exactly 1.2.3
+//	"^1.2"    >=1.2.0 <2.0.0 (for 0.x: >=0.2.0 <0.3.0)
+//	"~1.2.3"  >=1.2.3 <1.3.0
+//	">=1.2"   1.2.0 or newer
+//	"1.x"     any 1.*.*
+//	"*"       any version
+type versionRange struct {
+	min, max semver // max is exclusive
+	hasMax   bool
+}
+
+func parseVersionRange(s string) (versionRange, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case s == "*" || s == "x":
+		return versionRange{}, nil
+
+	case strings.HasPrefix(s, ">="):
+		min, err := parseSemver(s[2:])
+		return versionRange{min: min}, err
+
+	case strings.HasPrefix(s, "^"):
+		min, err := parseSemver(s[1:])
+		if err != nil {
+			return versionRange{}, err
+		}
+		r := versionRange{min: min, hasMax: true}
+		switch {
+		case min.major > 0:
+			r.max = semver{major: min.major + 1}
+		case min.minor > 0:
+			r.max = semver{minor: min.minor + 1}
+		default:
+			r.max = semver{patch: min.patch + 1}
+		}
+		return r, nil
+
+	case strings.HasPrefix(s, "~"):
+		min, err := parseSemver(s[1:])
+		if err != nil {
+			return versionRange{}, err
+		}
+		return versionRange{min: min, max: semver{major: min.major, minor: min.minor + 1}, hasMax: true}, nil
+	}
+
+	// Exact version, possibly with wildcard or omitted components.
+	parts, err := versionParts(s)
+	if err != nil {
+		return versionRange{}, err
+	}
+	var r versionRange
+	r.hasMax = true
+	switch {
+	case parts[0] < 0:
+		return versionRange{}, nil
+	case len(parts) == 1 || parts[1] < 0:
+		r.min = semver{major: parts[0]}
+		r.max = semver{major: parts[0] + 1}
+	case len(parts) == 2 || parts[2] < 0:
+		r.min = semver{major: parts[0], minor: parts[1]}
+		r.max = semver{major: parts[0], minor: parts[1] + 1}
+	default:
+		r.min = semver{parts[0], parts[1], parts[2]}
+		r.max = semver{parts[0], parts[1], parts[2] + 1}
+	}
+	return r, nil
+}
+
+func (r versionRange) matches(v semver) bool {
+	if v.less(r.min) {
+		return false
+	}
+	return !r.hasMax || v.less(r.max)
+}
@@ -0,0 +1,255 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Session bounds: how long an idle session lasts by default and at most,
+// how many sessions the engine holds open, and how many decisions a
+// transcript keeps.
+const (
+	defaultSessionTTL = 30 * time.Minute
+	maxSessionTTL     = 24 * time.Hour
+	maxSessions       = 10_000
+	maxSessionEntries = 1_000
+)
+
+// factOriginSession marks a fact taken from the request's session.
+const factOriginSession = "session"
+
+// ErrUnknownSession is returned (wrapped) for a session that was never
+// opened, has been closed or has expired.
+var ErrUnknownSession = errors.New("unknown session")
+
+// SessionOptions configures a session when it is opened.
+type SessionOptions struct {
+	// TTLSeconds is how long the session lasts without requests; default
+	// 1800, at most 86400.
+	TTLSeconds int `json:"ttl_seconds,omitempty"`
+}
+
+// Session identifies an open session. Requests carrying its ID as
+// session_id share the port facts earlier requests in the session fetched,
+// and are recorded in its transcript.
+type Session struct {
+	SessionID string    `json:"session_id"`
+	OpenedAt  time.Time `json:"opened_at"`
+	ExpiresAt time.Time `json:"expires_at"` // unless used again before then
+}
+
+// Transcript is the record of a session, for review of what an agent did
+// in it.
+type Transcript struct {
+	Session
+	ClosedAt *time.Time `json:"closed_at,omitempty"`
+	// Facts are the port facts the session holds, sorted. FactsFetched
+	// counts the port calls its requests made, FactsReused the facts they
+	// took from the session instead.
+	Facts        []string `json:"facts"`
+	FactsFetched int      `json:"facts_fetched"`
+	FactsReused  int      `json:"facts_reused"`
+	// Decisions are the session's evaluations, oldest first. Past
+	// maxSessionEntries the oldest are dropped, and counted in Dropped.
+	Decisions []SessionDecision `json:"decisions"`
+	Dropped   int               `json:"dropped,omitempty"`
+}
+
+// SessionDecision is one evaluation in a session.
+type SessionDecision struct {
+	InvocationID string         `json:"invocation_id"`
+	Timestamp    time.Time      `json:"timestamp"`
+	Operation    string         `json:"operation"`
+	Input        map[string]any `json:"input"`
+	DryRun       bool           `json:"dry_run"`
+	Outcome      Outcome        `json:"outcome"`
+	Verdicts     []Verdict      `json:"verdicts,omitempty"`
+	Error        *ErrorEnvelope `json:"error,omitempty"`
+	EscalationID string         `json:"escalation_id,omitempty"`
+	// SessionFacts are the facts taken from the session rather than
+	// fetched.
+	SessionFacts []string `json:"session_facts,omitempty"`
+}
+
+// session is an open session's state.
+type session struct {
+	ttl time.Duration
+
+	mu        sync.Mutex
+	info      Session
+	facts     *factCache
+	decisions []SessionDecision
+	dropped   int
+	fetched   int
+	reused    int
+}
+
+// OpenSession opens a session for a conversation or multi-step plan. Port
+// facts its requests fetch are kept for its later requests whose input
+// agrees with every field of the input they were fetched with, so a plan
+// that checks the same customer or invoice at each step reads it once.
+// max_staleness still applies to them, and a live execution in the session
+// drops them all, since it may change what the ports report.
+func (e *Engine) OpenSession(opts SessionOptions) (*Session, error) {
+	ttl := defaultSessionTTL
+	if opts.TTLSeconds > 0 {
+		ttl = min(time.Duration(opts.TTLSeconds)*time.Second, maxSessionTTL)
+	}
+	now := e.now().UTC()
+
+	e.sessionsMu.Lock()
+	defer e.sessionsMu.Unlock()
+	if len(e.sessions) >= maxSessions {
+		for id, s := range e.sessions {
+			if s.expired(now) {
+				delete(e.sessions, id)
+			}
+		}
+		if len(e.sessions) >= maxSessions {
+			return nil, fmt.Errorf("too many open sessions (%d)", maxSessions)
+		}
+	}
+	s := &session{
+		info:  Session{SessionID: "ses_" + randID(16), OpenedAt: now, ExpiresAt: now.Add(ttl)},
+		ttl:   ttl,
+		facts: newFactCache(),
+	}
+	if e.sessions == nil {
+		e.sessions = map[string]*session{}
+	}
+	e.sessions[s.info.SessionID] = s
+	info := s.info
+	return &info, nil
+}
+
+// Transcript returns the transcript of an open session.
+func (e *Engine) Transcript(id string) (*Transcript, error) {
+	s, err := e.session(id, false)
+	if err != nil {
+		return nil, err
+	}
+	return s.transcript(), nil
+}
+
+// CloseSession closes a session, discarding its facts, and returns its
+// final transcript. Requests naming it afterwards fail with
+// ErrUnknownSession.
+func (e *Engine) CloseSession(id string) (*Transcript, error) {
+	s, err := e.session(id, false)
+	if err != nil {
+		return nil, err
+	}
+	e.sessionsMu.Lock()
+	delete(e.sessions, id)
+	e.sessionsMu.Unlock()
+	t := s.transcript()
+	closed := e.now().UTC()
+	t.ClosedAt = &closed
+	return t, nil
+}
+
+// session returns the open session id, extending it if touch is set.
+func (e *Engine) session(id string, touch bool) (*session, error) {
+	now := e.now().UTC()
+	e.sessionsMu.Lock()
+	defer e.sessionsMu.Unlock()
+	s, ok := e.sessions[id]
+	if ok && s.expired(now) {
+		delete(e.sessions, id)
+		ok = false
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownSession, id)
+	}
+	if touch {
+		s.mu.Lock()
+		s.info.ExpiresAt = now.Add(s.ttl)
+		s.mu.Unlock()
+	}
+	return s, nil
+}
+
+func (s *session) expired(now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !now.Before(s.info.ExpiresAt)
+}
+
+// cache keeps a port fact fetched for a request in the session, scoped to
+// the request's input.
+func (s *session) cache(name string, val any, trace FactTrace, input map[string]any, now time.Time) {
+	trace.Source, trace.Origin, trace.SessionID = factSourceCache, factOriginSession, s.info.SessionID
+	s.factCache().put(name, &cachedFact{scope: input, value: val, trace: trace}, now)
+	s.mu.Lock()
+	s.fetched++
+	s.mu.Unlock()
+}
+
+// record adds a decision to the transcript, and drops the session's facts
+// after a live execution.
+func (s *session) record(rec *AuditRecord, resp *Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.decisions) == maxSessionEntries {
+		s.decisions = s.decisions[1:]
+		s.dropped++
+	}
+	s.decisions = append(s.decisions, SessionDecision{
+		InvocationID: rec.InvocationID,
+		Timestamp:    rec.Timestamp,
+		Operation:    rec.Operation,
+		Input:        rec.Input,
+		DryRun:       rec.DryRun,
+		Outcome:      resp.Outcome,
+		Verdicts:     resp.Verdicts,
+		Error:        resp.Error,
+		EscalationID: resp.EscalationID,
+		SessionFacts: rec.SessionFacts,
+	})
+	s.reused += len(rec.SessionFacts)
+	if resp.Outcome == OutcomeExecuted {
+		s.facts = newFactCache()
+	}
+}
+
+func (s *session) factCache() *factCache {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.facts
+}
+
+func (s *session) transcript() *Transcript {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := &Transcript{
+		Session:      s.info,
+		Facts:        []string{},
+		FactsFetched: s.fetched,
+		FactsReused:  s.reused,
+		Decisions:    append([]SessionDecision{}, s.decisions...),
+		Dropped:      s.dropped,
+	}
+	s.facts.mu.Lock()
+	for name := range s.facts.entries {
+		t.Facts = append(t.Facts, name)
+	}
+	s.facts.mu.Unlock()
+	sort.Strings(t.Facts)
+	return t
+}
+
+// sessionFacts returns the facts of a request's snapshot that were taken
+// from its session, sorted.
+func sessionFacts(traces map[string]FactTrace) []string {
+	var names []string
+	for name, t := range traces {
+		if t.Origin == factOriginSession {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,93 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEngine_Session_sharesFactsAndRecordsDecisions(t *testing.T) {
+	var gets atomic.Int32
+	ports := &mockPorts{getFunc: func(context.Context, string, string, map[string]any) (any, error) {
+		gets.Add(1)
+		return 5.0, nil
+	}}
+	e := NewEngine(ports)
+	e.LoadContract(intentContract(), "v1")
+	ctx := context.Background()
+
+	s, err := e.OpenSession(SessionOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := map[string]any{"customer.id": "cust_1"}
+	e.Evaluate(ctx, &Request{Operation: "testOp", Input: input, DryRun: true, SessionID: s.SessionID})
+	resp, err := e.Evaluate(ctx, &Request{Operation: "testOp", Input: input, DryRun: true, SessionID: s.SessionID, Explain: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gets.Load() != 1 {
+		t.Errorf("expected the second dry-run to reuse the session's fact, got %d gets", gets.Load())
+	}
+	if trace := resp.Explain.Facts["balance"]; trace.Origin != "session" || trace.SessionID != s.SessionID {
+		t.Errorf("expected session attribution in explain, got %+v", trace)
+	}
+
+	// Requests outside the session, and for another customer, fetch.
+	e.Evaluate(ctx, &Request{Operation: "testOp", Input: input, DryRun: true})
+	e.Evaluate(ctx, &Request{Operation: "testOp", Input: map[string]any{"customer.id": "cust_2"}, DryRun: true, SessionID: s.SessionID})
+	if gets.Load() != 3 {
+		t.Errorf("expected 3 gets, got %d", gets.Load())
+	}
+
+	// A live execution may change the balance; the next request fetches it.
+	e.Evaluate(ctx, &Request{Operation: "testOp", Input: input, SessionID: s.SessionID})
+	e.Evaluate(ctx, &Request{Operation: "testOp", Input: input, DryRun: true, SessionID: s.SessionID})
+	if gets.Load() != 4 {
+		t.Errorf("expected a fetch after the execution, got %d gets", gets.Load())
+	}
+
+	tr, err := e.CloseSession(s.SessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var outcomes []Outcome
+	for _, d := range tr.Decisions {
+		outcomes = append(outcomes, d.Outcome)
+	}
+	want := []Outcome{OutcomeWouldExecuteWithFlags, OutcomeWouldExecuteWithFlags, OutcomeWouldExecuteWithFlags, OutcomeExecuted, OutcomeWouldExecuteWithFlags}
+	if !reflect.DeepEqual(outcomes, want) || tr.ClosedAt == nil {
+		t.Errorf("got transcript outcomes %v, closed %v", outcomes, tr.ClosedAt)
+	}
+	if tr.FactsFetched != 3 || tr.FactsReused != 2 || !reflect.DeepEqual(tr.Decisions[1].SessionFacts, []string{"balance"}) {
+		t.Errorf("got fetched %d, reused %d, decisions %+v", tr.FactsFetched, tr.FactsReused, tr.Decisions)
+	}
+
+	if _, err := e.Evaluate(ctx, &Request{Operation: "testOp", Input: input, SessionID: s.SessionID}); !errors.Is(err, ErrUnknownSession) {
+		t.Errorf("expected a closed session to be unknown, got %v", err)
+	}
+}
+
+func TestEngine_Session_expiresWhenIdle(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	e := NewEngine(&mockPorts{}, WithClock(func() time.Time { return now }))
+	e.LoadContract(intentContract(), "v1")
+
+	s, _ := e.OpenSession(SessionOptions{TTLSeconds: 60})
+	now = now.Add(50 * time.Second)
+	if _, err := e.Evaluate(context.Background(), &Request{Operation: "testOp", Input: map[string]any{"customer.id": "c"}, SessionID: s.SessionID}); err != nil {
+		t.Fatal(err)
+	}
+	// Use extended it.
+	now = now.Add(50 * time.Second)
+	if tr, err := e.Transcript(s.SessionID); err != nil || len(tr.Decisions) != 1 || !tr.ExpiresAt.Equal(now.Add(10*time.Second)) {
+		t.Fatalf("got %+v, %v", tr, err)
+	}
+	now = now.Add(10 * time.Second)
+	if _, err := e.Transcript(s.SessionID); !errors.Is(err, ErrUnknownSession) {
+		t.Errorf("expected an idle session to expire, got %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package engine
+
+// isSet reports whether cond compares a list fact with a set of values.
+func (cond Condition) isSet() bool {
+	return len(cond.SubsetOf) > 0 || len(cond.Intersects) > 0 || len(cond.ContainsAll) > 0
+}
+
+// evalSet applies cond's subset_of, intersects or contains_all operator to
+// the list val. Elements compare as equals does; a value that is not a list
+// never compares.
+func evalSet(cond Condition, val any, facts *FactSet) bool {
+	elems, ok := listElems(val)
+	if !ok {
+		return false
+	}
+	resolve := func(operands []any) []any {
+		out := make([]any, len(operands))
+		for i, v := range operands {
+			out[i] = resolveOperand(v, facts)
+		}
+		return out
+	}
+	switch {
+	case len(cond.SubsetOf) > 0:
+		return every(elems, resolve(cond.SubsetOf))
+	case len(cond.Intersects) > 0:
+		set := resolve(cond.Intersects)
+		for _, el := range elems {
+			if member(el, set) {
+				return true
+			}
+		}
+		return false
+	case len(cond.ContainsAll) > 0:
+		return every(resolve(cond.ContainsAll), elems)
+	}
+	return false
+}
+
+// every reports whether each of elems is a member of set.
+func every(elems, set []any) bool {
+	for _, el := range elems {
+		if !member(el, set) {
+			return false
+		}
+	}
+	return true
+}
+
+func member(el any, set []any) bool {
+	for _, v := range set {
+		if applyOp("equals", el, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// listElems returns the elements of a list fact value.
+func listElems(v any) ([]any, bool) {
+	switch l := v.(type) {
+	case []any:
+		return l, true
+	case []string:
+		out := make([]any, len(l))
+		for i, s := range l {
+			out[i] = s
+		}
+		return out, true
+	}
+	return nil, false
+}
@@ -0,0 +1,73 @@
+package engine
+
+import (
+	"testing"
+)
+
+func TestEvalCondition_setOperators(t *testing.T) {
+	fs := NewFactSet()
+	fs.Set("user.roles", []any{"billing", "viewer"})
+	fs.Set("invoice.tags", []string{"approved", "recurring"})
+	fs.Set("none", []any{})
+	fs.Set("status", "approved")
+	fs.Set("params.reviewer", "viewer")
+
+	for _, tc := range []struct {
+		cond Condition
+		want bool
+	}{
+		{Condition{Fact: "user.roles", Intersects: []any{"admin", "billing"}}, true},
+		{Condition{Fact: "user.roles", Intersects: []any{"admin", "owner"}}, false},
+		{Condition{Fact: "user.roles", SubsetOf: []any{"billing", "viewer", "admin"}}, true},
+		{Condition{Fact: "user.roles", SubsetOf: []any{"billing"}}, false},
+		{Condition{Fact: "user.roles", ContainsAll: []any{"billing", map[string]any{"param": "reviewer"}}}, true},
+		{Condition{Fact: "invoice.tags", ContainsAll: []any{"approved", "reviewed"}}, false},
+		{Condition{Fact: "invoice.tags", SubsetOf: []any{"approved", "recurring"}}, true},
+		{Condition{Fact: "none", SubsetOf: []any{"approved"}}, true},
+		{Condition{Fact: "none", Intersects: []any{"approved"}}, false},
+		// Values that are not lists never compare.
+		{Condition{Fact: "status", Intersects: []any{"approved"}}, false},
+		{Condition{Fact: "missing", SubsetOf: []any{"approved"}}, false},
+	} {
+		if got := evalCondition(tc.cond, fs); got != tc.want {
+			t.Errorf("%+v: expected %v, got %v", tc.cond, tc.want, got)
+		}
+	}
+}
+
+func TestTraceCondition_setOperatorsResolveParams(t *testing.T) {
+	fs := NewFactSet()
+	fs.Set("user.roles", []any{"viewer"})
+	fs.Set("params.reviewer", "viewer")
+	tr := traceCondition(Condition{Fact: "user.roles", ContainsAll: []any{map[string]any{"param": "reviewer"}}}, fs)
+	if !tr.Passed || tr.Op != "contains_all" {
+		t.Fatalf("expected a passing contains_all, got %+v", tr)
+	}
+	if set, ok := tr.Expected.([]any); !ok || len(set) != 1 || set[0] != "viewer" {
+		t.Errorf("expected the param resolved, got %v", tr.Expected)
+	}
+}
+
+func TestEngine_SimulateBatch_setOperatorsRowByRow(t *testing.T) {
+	c := makeMinimalContract()
+	c.Facts = map[string]FactDef{"invoice.tags": {Source: "input"}}
+	c.Rules = []RuleDef{{
+		ID:        "unreviewed",
+		AppliesTo: []string{"testOp"},
+		When:      Condition{Not: &Condition{Fact: "invoice.tags", ContainsAll: []any{"approved", "reviewed"}}},
+		Verdict:   VerdictDef{Flag: &FlagVerdict{Code: "UNREVIEWED", Reason: "not reviewed"}},
+	}}
+	c.Operations["testOp"] = OperationDef{ConstrainedBy: []string{"unreviewed"}}
+	e := NewEngine(&mockPorts{})
+	e.LoadContract(c, "v1")
+	resp, err := e.SimulateBatch(&BatchSimulateRequest{Operation: "testOp", Rows: []map[string]any{
+		{"invoice.tags": []any{"reviewed", "approved"}},
+		{"invoice.tags": []any{"approved"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Vectorized) != 0 || resp.RuleMatches["unreviewed"] != 1 || len(resp.Rows[1].Rules) != 1 {
+		t.Errorf("expected only the second row unreviewed, evaluated row by row, got %+v", resp)
+	}
+}
@@ -0,0 +1,81 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+)
+
+// Simulate evaluates an operation against a caller-supplied fact set without
+// consulting any port for facts. Derived facts are always recomputed from the
+// supplied base facts; base facts the caller omits are treated as absent, so
+// conditions referencing them evaluate to false. The one port read is the
+// rates port, for convert derivations; no port executes.
+//
+// Rules are evaluated as of req.At if set, so time-bounded rules can be
+// checked ahead of their window.
+//
+// The response has the same shape as a dry-run, with Simulated set. With a
+// Scheduler, simulations run in the batch lane unless req.Priority says
+// otherwise.
+func (e *Engine) Simulate(req *SimulateRequest) (*Response, error) {
+	return e.schedule(context.Background(), req.Priority, PriorityBatch, func() (*Response, error) {
+		return e.simulate(req)
+	})
+}
+
+func (e *Engine) simulate(req *SimulateRequest) (*Response, error) {
+	e.activateDue()
+	contract, etag, negErr := e.selectContract(req.VersionRange)
+	if negErr != nil {
+		return negErr, nil
+	}
+	if contract == nil {
+		return nil, fmt.Errorf("no contract loaded")
+	}
+	if req.ContractETag != "" && req.ContractETag != etag {
+		return contractVersionMismatch(), nil
+	}
+	if _, ok := contract.Operations[req.Operation]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownOperation, req.Operation)
+	}
+
+	// Params come from the contract's bindings unless the caller supplies
+	// params.<name> facts to try other values.
+	facts := NewFactSet()
+	for name := range contract.Params {
+		if val, ok := contract.ParamValue(name); ok {
+			facts.Set(paramFactPrefix+name, val)
+		}
+	}
+	for name, val := range req.Facts {
+		facts.Set(name, val)
+	}
+	if err := e.deriveFacts(context.Background(), ratesOnlyPorts{readOnlyPorts{e.ports}}, contract, facts); err != nil {
+		return nil, fmt.Errorf("derive facts: %w", err)
+	}
+
+	at := e.now()
+	if req.At != nil {
+		at = *req.At
+	}
+	verdicts, _ := e.decide(contract, etag, req.Operation, facts, at, false)
+	resp := &Response{
+		DryRun:              true,
+		Simulated:           true,
+		Outcome:             dryRunOutcome(resolveVerdicts(verdicts)),
+		Verdicts:            verdicts,
+		FactSnapshot:        facts.Snapshot(),
+		SideEffectsIsolated: true,
+		ContractSemver:      contract.Version,
+	}
+	if req.Explain {
+		resp.Explain = explain(contract, req.Operation, facts, at)
+		for name, pt := range resp.Explain.Params {
+			if _, ok := req.Facts[paramFactPrefix+name]; ok {
+				pt.Source = "supplied"
+				resp.Explain.Params[name] = pt
+			}
+		}
+	}
+	return resp, nil
+}
@@ -0,0 +1,120 @@
+package engine
+
+import (
+	"context"
+	"testing"
+)
+
+func simulateContract() *Contract {
+	return &Contract{
+		Facts: map[string]FactDef{
+			"customer.status": {Source: "port:customerRepo", Required: true},
+			"payment.amount":  {Source: "input", Required: true},
+		},
+		DerivedFacts: map[string]DerivedFactDef{
+			"payment.large": {Derivation: Derivation{
+				Fn:   "greater_than",
+				Args: []DerivationArg{{Fact: "payment.amount.value"}, {Value: 1000.0}},
+			}},
+		},
+		Rules: []RuleDef{
+			{
+				ID:   "closed",
+				When: Condition{Fact: "customer.status", Equals: "closed"},
+				Verdict: VerdictDef{Deny: &DenyVerdict{
+					Code:  "ACCOUNT_CLOSED",
+					Error: ErrorEnvelope{Code: "ACCOUNT_CLOSED", HttpStatus: 422},
+				}},
+			},
+			{
+				ID:      "large",
+				When:    Condition{Fact: "payment.large", Equals: true},
+				Verdict: VerdictDef{Flag: &FlagVerdict{Code: "LARGE"}},
+			},
+		},
+		Operations: map[string]OperationDef{
+			"Pay": {ConstrainedBy: []string{"closed", "large"}},
+		},
+		Entities: map[string]EntityDef{},
+	}
+}
+
+func noPortsAllowed(t *testing.T) *mockPorts {
+	return &mockPorts{
+		getFunc: func(_ context.Context, port, fact string, _ map[string]any) (any, error) {
+			t.Fatalf("Simulate called port %s for %s", port, fact)
+			return nil, nil
+		},
+		executeFunc: func(_ context.Context, port, op string, _ map[string]any) (map[string]any, error) {
+			t.Fatalf("Simulate executed %s on %s", op, port)
+			return nil, nil
+		},
+	}
+}
+
+func TestSimulate_usesSuppliedFactsOnly(t *testing.T) {
+	eng := NewEngine(noPortsAllowed(t))
+	eng.LoadContract(simulateContract(), "etag-1")
+
+	resp, err := eng.Simulate(&SimulateRequest{
+		Operation: "Pay",
+		Facts: map[string]any{
+			"customer.status": "closed",
+			"payment.amount":  map[string]any{"value": 5000.0, "currency": "USD"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Outcome != "would_deny" || !resp.Simulated || !resp.DryRun {
+		t.Fatalf("expected simulated would_deny, got %+v", resp)
+	}
+	if len(resp.Verdicts) != 2 {
+		t.Fatalf("expected deny and flag verdicts, got %+v", resp.Verdicts)
+	}
+	if resp.FactSnapshot["payment.large"] != true {
+		t.Fatalf("expected derived fact in snapshot, got %v", resp.FactSnapshot)
+	}
+}
+
+func TestSimulate_recomputesSuppliedDerivedFacts(t *testing.T) {
+	eng := NewEngine(noPortsAllowed(t))
+	eng.LoadContract(simulateContract(), "etag-1")
+
+	resp, err := eng.Simulate(&SimulateRequest{
+		Operation: "Pay",
+		Facts: map[string]any{
+			"customer.status": "active",
+			"payment.amount":  map[string]any{"value": 10.0},
+			"payment.large":   true, // stale fixture value
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Outcome != "would_execute" {
+		t.Fatalf("expected would_execute, got %s (%+v)", resp.Outcome, resp.Verdicts)
+	}
+}
+
+func TestSimulate_contractETagMismatch(t *testing.T) {
+	eng := NewEngine(noPortsAllowed(t))
+	eng.LoadContract(simulateContract(), "etag-current")
+
+	resp, err := eng.Simulate(&SimulateRequest{Operation: "Pay", ContractETag: "etag-stale"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error == nil || resp.Error.Code != "CONTRACT_VERSION_MISMATCH" {
+		t.Fatalf("expected CONTRACT_VERSION_MISMATCH, got %+v", resp)
+	}
+}
+
+func TestSimulate_unknownOperationReturnsError(t *testing.T) {
+	eng := NewEngine(noPortsAllowed(t))
+	eng.LoadContract(simulateContract(), "etag-1")
+
+	if _, err := eng.Simulate(&SimulateRequest{Operation: "Nope"}); err == nil {
+		t.Fatal("expected error for unknown operation")
+	}
+}
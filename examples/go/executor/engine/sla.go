@@ -0,0 +1,283 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Escalation decisions, made by a reviewer or by a queue's expiry policy.
+const (
+	DecisionDeny    = "deny"
+	DecisionExecute = "execute"
+)
+
+// ResolvedByExpiry is the ResolvedBy of escalations resolved by their
+// queue's hard expiry.
+const ResolvedByExpiry = "sla-expiry"
+
+// EscalationResolution links the audit record of a resolution to the
+// escalation it resolved.
+type EscalationResolution struct {
+	EscalationID string `json:"escalation_id"`
+	// EscalatedInvocationID is the invocation that was escalated.
+	EscalatedInvocationID string `json:"escalated_invocation_id"`
+	Decision              string `json:"decision"`
+	ResolvedBy            string `json:"resolved_by"`
+	// Justification is the reviewer's reason for the decision.
+	Justification string `json:"justification"`
+}
+
+// ErrJustificationRequired is returned by ResolveEscalation when no
+// justification is given.
+var ErrJustificationRequired = errors.New("a justification is required to resolve an escalation")
+
+// EscalationBacklog summarizes the pending escalations at CheckedAt.
+type EscalationBacklog struct {
+	CheckedAt time.Time                `json:"checked_at"`
+	Pending   int                      `json:"pending"`
+	Overdue   int                      `json:"overdue"`
+	Expired   int                      `json:"expired"`
+	Queues    map[string]*QueueBacklog `json:"queues"`
+}
+
+// QueueBacklog summarizes one queue's pending escalations. Overdue ones are
+// past their SLA; Expired counts those the sweep resolved at their hard
+// expiry.
+type QueueBacklog struct {
+	Pending          int     `json:"pending"`
+	Overdue          int     `json:"overdue"`
+	Expired          int     `json:"expired"`
+	OldestAgeSeconds float64 `json:"oldest_age_seconds"`
+}
+
+// SweepEscalations checks every pending escalation against its queue's SLA,
+// resolves those past their hard expiry with the queue's on_expiry decision,
+// and summarizes the rest. An escalation that fails to resolve is counted
+// as pending and retried on the next sweep; the failures are returned
+// joined, with the backlog.
+func (e *Engine) SweepEscalations(ctx context.Context) (*EscalationBacklog, error) {
+	if e.escalations == nil {
+		return nil, errors.New("no escalation store")
+	}
+	pending, err := e.escalations.Escalations(ctx, "", EscalationPending)
+	if err != nil {
+		return nil, err
+	}
+	now := e.now().UTC()
+	b := &EscalationBacklog{CheckedAt: now, Queues: map[string]*QueueBacklog{}}
+	var errs []error
+	for _, esc := range pending {
+		q := b.Queues[esc.Queue]
+		if q == nil {
+			q = &QueueBacklog{}
+			b.Queues[esc.Queue] = q
+		}
+		if !esc.ExpiresAt.IsZero() && !now.Before(esc.ExpiresAt) {
+			why := fmt.Sprintf("pending past the hard expiry of queue %s at %s", esc.Queue, esc.ExpiresAt.Format(time.RFC3339))
+			_, err := e.resolveEscalation(ctx, esc, esc.OnExpiry, ResolvedByExpiry, why)
+			switch {
+			case err == nil:
+				q.Expired++
+				b.Expired++
+				continue
+			case errors.Is(err, ErrStatusChanged):
+				continue // resolved meanwhile, by a reviewer or another executor
+			}
+			errs = append(errs, fmt.Errorf("escalation %s: %w", esc.ID, err))
+		}
+		q.Pending++
+		b.Pending++
+		if !esc.DueAt.IsZero() && now.After(esc.DueAt) {
+			q.Overdue++
+			b.Overdue++
+		}
+		q.OldestAgeSeconds = max(q.OldestAgeSeconds, now.Sub(esc.CreatedAt).Seconds())
+	}
+	return b, errors.Join(errs...)
+}
+
+// ResolveEscalation resolves a pending escalation on behalf of by, for the
+// reason justification: it is denied, or approved and its operation
+// executed with the escalated input. The resolution is audited like an
+// evaluation, justification included. It fails with ErrStatusChanged if the
+// escalation is no longer pending.
+func (e *Engine) ResolveEscalation(ctx context.Context, id, decision, by, justification string) (*Escalation, error) {
+	if e.escalations == nil {
+		return nil, errors.New("no escalation store")
+	}
+	if strings.TrimSpace(justification) == "" {
+		return nil, ErrJustificationRequired
+	}
+	esc, err := e.escalations.Escalation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if esc.Status != EscalationPending {
+		return nil, fmt.Errorf("escalation %s is %s: %w", id, esc.Status, ErrStatusChanged)
+	}
+	return e.resolveEscalation(ctx, esc, decision, by, justification)
+}
+
+func (e *Engine) resolveEscalation(ctx context.Context, esc *Escalation, decision, by, justification string) (*Escalation, error) {
+	if decision != DecisionDeny && decision != DecisionExecute {
+		return nil, fmt.Errorf("decision must be %s or %s, got %q", DecisionDeny, DecisionExecute, decision)
+	}
+	start := time.Now()
+	rec := e.resolutionRecord(esc, decision, by, justification)
+	res := *esc
+	res.ResolvedAt, res.ResolvedBy = rec.Timestamp, by
+
+	var resp *Response
+	if decision == DecisionDeny {
+		res.Status = EscalationDenied
+		if err := e.escalations.Transition(ctx, &res, EscalationPending); err != nil {
+			return nil, err
+		}
+		resp = &Response{
+			Outcome: OutcomeDenied,
+			Error: &ErrorEnvelope{
+				Code:       "ESCALATION_DENIED",
+				Message:    fmt.Sprintf("escalation %s was denied by %s: %s", esc.ID, by, justification),
+				HttpStatus: 403,
+				Category:   "authorization",
+			},
+		}
+	} else {
+		// Claim the escalation before executing, so it executes once.
+		res.Status = EscalationApproved
+		if err := e.escalations.Transition(ctx, &res, EscalationPending); err != nil {
+			return nil, err
+		}
+		return e.completeEscalation(ctx, &res, rec, start)
+	}
+	resp.InvocationID = rec.InvocationID
+	resp.EscalationID = esc.ID
+	e.audit(ctx, rec, resp, time.Since(start))
+	return &res, nil
+}
+
+// ResumeEscalation completes an escalation left approved by a resolution
+// that was interrupted, e.g. by a restart, before recording the execution:
+// its operation is executed, on behalf of by for the reason justification,
+// and the result recorded and audited as for ResolveEscalation. It fails
+// with ErrStatusChanged if the escalation is not approved.
+//
+// An interrupted resolution may have executed the operation already; only
+// a caller that knows it did not, or whose operation is idempotent, should
+// resume it.
+func (e *Engine) ResumeEscalation(ctx context.Context, id, by, justification string) (*Escalation, error) {
+	if e.escalations == nil {
+		return nil, errors.New("no escalation store")
+	}
+	if strings.TrimSpace(justification) == "" {
+		return nil, ErrJustificationRequired
+	}
+	esc, err := e.escalations.Escalation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if esc.Status != EscalationApproved {
+		return nil, fmt.Errorf("escalation %s is %s: %w", id, esc.Status, ErrStatusChanged)
+	}
+	start := time.Now()
+	return e.completeEscalation(ctx, esc, e.resolutionRecord(esc, DecisionExecute, by, justification), start)
+}
+
+// completeEscalation executes approved escalation esc and records the
+// result, or returns it to pending if the execution was refused.
+func (e *Engine) completeEscalation(ctx context.Context, esc *Escalation, rec *AuditRecord, start time.Time) (*Escalation, error) {
+	res := *esc
+	resp, refused := e.executeEscalation(ctx, esc, rec)
+	if refused {
+		// Leave it pending for the next attempt.
+		res.Status, res.ResolvedAt, res.ResolvedBy = EscalationPending, time.Time{}, ""
+		if err := e.escalations.Transition(ctx, &res, EscalationApproved); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("execution refused: %s", resp.Error.Message)
+	}
+	res.Status, res.Output = EscalationExecuted, resp.Output
+	if resp.Error != nil {
+		res.Status, res.Error = EscalationFailed, resp.Error.Message
+	}
+	if err := e.escalations.Transition(ctx, &res, EscalationApproved); err != nil {
+		return nil, err
+	}
+	resp.InvocationID = rec.InvocationID
+	resp.EscalationID = esc.ID
+	e.audit(ctx, rec, resp, time.Since(start))
+	return &res, nil
+}
+
+// executeEscalation executes an approved escalation's operation, reporting
+// whether it was refused for lack of capacity rather than attempted. Its
+// entity transitions are claimed from the state at execution, not at
+// escalation. A port contract violation is recorded in rec.
+func (e *Engine) executeEscalation(ctx context.Context, esc *Escalation, rec *AuditRecord) (*Response, bool) {
+	contract := e.Contract()
+	if contract == nil {
+		return executionFailed(errors.New("no contract loaded")), false
+	}
+	op, ok := contract.Operations[esc.Operation]
+	if !ok {
+		return executionFailed(fmt.Errorf("%w: %s", ErrUnknownOperation, esc.Operation)), false
+	}
+	release, refused := e.acquireExecution(ctx, esc.Operation, op)
+	if refused != nil {
+		return refused, true
+	}
+	defer release()
+	transitions, conflict := e.readTransitions(ctx, contract, op, esc.Input, NewFactSet())
+	if conflict == nil {
+		conflict = e.claimTransitions(ctx, transitions)
+	}
+	if conflict != nil {
+		return conflict, false
+	}
+	output, err := e.ports.Execute(ctx, operationPort(op), esc.Operation, esc.Input)
+	var violation *PortContractViolation
+	if errors.As(err, &violation) {
+		rec.PortContractViolation = violation
+		return portContractViolation(violation), false
+	}
+	if err != nil {
+		e.undoTransitions(ctx, transitions)
+		return executionFailed(err), false
+	}
+	e.recordExecution(ctx, contract, rec, esc.Input, output)
+	return &Response{Outcome: OutcomeExecuted, Output: output, Undo: undoInfo(op, esc.Input, output, e.now())}, false
+}
+
+// resolutionRecord starts the audit record of a resolution of esc.
+func (e *Engine) resolutionRecord(esc *Escalation, decision, by, justification string) *AuditRecord {
+	return &AuditRecord{
+		InvocationID:    "inv_" + randID(16),
+		Timestamp:       e.now().UTC(),
+		Operation:       esc.Operation,
+		Input:           esc.Input,
+		ContractVersion: e.ETag(),
+		Resolution: &EscalationResolution{
+			EscalationID:          esc.ID,
+			EscalatedInvocationID: esc.InvocationID,
+			Decision:              decision,
+			ResolvedBy:            by,
+			Justification:         justification,
+		},
+	}
+}
+
+func executionFailed(err error) *Response {
+	return &Response{
+		Outcome: OutcomeSystemError,
+		Error: &ErrorEnvelope{
+			Code:       "EXECUTION_FAILED",
+			Message:    err.Error(),
+			HttpStatus: 500,
+			Category:   "system",
+			Retryable:  true,
+		},
+	}
+}
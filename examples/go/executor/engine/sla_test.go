@@ -0,0 +1,149 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// escalatingEngine escalates every testOp to queue, with the catalog entry
+// def, on a clock the test advances.
+func escalatingEngine(t *testing.T, queue string, def QueueDef, ports *mockPorts, opts ...Option) (*Engine, *memStore, *time.Time) {
+	t.Helper()
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	store := newMemStore()
+	opts = append(opts, WithEscalationStore(store), WithClock(func() time.Time { return now }))
+	e := NewEngine(ports, opts...)
+	c := makeSimpleContract("review", VerdictDef{Escalate: &EscalateVerdict{Queue: queue, Reason: "check"}}, Condition{})
+	c.Queues = map[string]QueueDef{queue: def}
+	e.LoadContract(c, "v1")
+	return e, store, &now
+}
+
+func escalate(t *testing.T, e *Engine) string {
+	t.Helper()
+	resp, err := e.Evaluate(context.Background(), &Request{Operation: "testOp", Input: map[string]any{"amount": 10.0}})
+	if err != nil || resp.EscalationID == "" {
+		t.Fatalf("expected an escalation, got %+v, %v", resp, err)
+	}
+	return resp.EscalationID
+}
+
+func TestEngine_Evaluate_escalationCarriesQueueDeadlines(t *testing.T) {
+	e, store, now := escalatingEngine(t, "review", QueueDef{SLA: "1h", HardExpiry: "4h", OnExpiry: DecisionDeny}, &mockPorts{})
+	esc, _ := store.Escalation(context.Background(), escalate(t, e))
+	if !esc.DueAt.Equal(now.Add(time.Hour)) || !esc.ExpiresAt.Equal(now.Add(4*time.Hour)) || esc.OnExpiry != DecisionDeny {
+		t.Errorf("unexpected deadlines %+v", esc)
+	}
+}
+
+func TestEngine_SweepEscalations_countsOverdueAndDeniesExpired(t *testing.T) {
+	sink := &recordingSink{}
+	e, store, now := escalatingEngine(t, "review", QueueDef{SLA: "1h", HardExpiry: "4h", OnExpiry: DecisionDeny}, &mockPorts{}, WithAuditSink(sink))
+	first := escalate(t, e)
+	*now = now.Add(3 * time.Hour)
+	escalate(t, e)
+
+	b, err := e.SweepEscalations(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q := b.Queues["review"]; b.Pending != 2 || b.Overdue != 1 || q.Overdue != 1 || q.OldestAgeSeconds != 3*3600 {
+		t.Errorf("expected two pending, one overdue, got %+v %+v", b, q)
+	}
+
+	*now = now.Add(time.Hour)
+	b, err = e.SweepEscalations(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Pending != 1 || b.Expired != 1 || b.Overdue != 0 {
+		t.Errorf("expected the first escalation expired, got %+v", b)
+	}
+	esc, _ := store.Escalation(context.Background(), first)
+	if esc.Status != EscalationDenied || esc.ResolvedBy != ResolvedByExpiry || !esc.ResolvedAt.Equal(*now) {
+		t.Errorf("unexpected resolution %+v", esc)
+	}
+	rec := sink.recs[len(sink.recs)-1]
+	if rec.Outcome != "denied" || rec.ErrorCode != "ESCALATION_DENIED" || rec.Resolution == nil || rec.Resolution.EscalationID != first ||
+		rec.Resolution.Justification != "pending past the hard expiry of queue review at 2026-05-01T16:00:00Z" {
+		t.Errorf("expected the denial audited, got %+v", rec)
+	}
+}
+
+func TestEngine_SweepEscalations_executesExpiredOnce(t *testing.T) {
+	executions := 0
+	ports := &mockPorts{executeFunc: func(_ context.Context, _, op string, input map[string]any) (map[string]any, error) {
+		executions++
+		return map[string]any{"paid": input["amount"]}, nil
+	}}
+	e, store, now := escalatingEngine(t, "review", QueueDef{SLA: "1h", HardExpiry: "1h", OnExpiry: DecisionExecute}, ports)
+	id := escalate(t, e)
+	*now = now.Add(time.Hour)
+
+	for range 2 {
+		if _, err := e.SweepEscalations(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	esc, _ := store.Escalation(context.Background(), id)
+	if executions != 1 || esc.Status != EscalationExecuted || esc.Output["paid"] != 10.0 {
+		t.Errorf("expected one execution recorded, got %d and %+v", executions, esc)
+	}
+}
+
+func TestEngine_ResolveEscalation_recordsFailedExecution(t *testing.T) {
+	ports := &mockPorts{executeFunc: func(context.Context, string, string, map[string]any) (map[string]any, error) {
+		return nil, errors.New("processor down")
+	}}
+	e, _, _ := escalatingEngine(t, "review", QueueDef{SLA: "1h"}, ports)
+	id := escalate(t, e)
+
+	if _, err := e.ResolveEscalation(context.Background(), id, DecisionExecute, "alice", " "); !errors.Is(err, ErrJustificationRequired) {
+		t.Errorf("expected ErrJustificationRequired, got %v", err)
+	}
+	esc, err := e.ResolveEscalation(context.Background(), id, DecisionExecute, "alice", "customer confirmed by phone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if esc.Status != EscalationFailed || esc.Error != "processor down" || esc.ResolvedBy != "alice" {
+		t.Errorf("unexpected resolution %+v", esc)
+	}
+	if _, err := e.ResolveEscalation(context.Background(), id, DecisionDeny, "bob", "duplicate"); !errors.Is(err, ErrStatusChanged) {
+		t.Errorf("expected ErrStatusChanged resolving twice, got %v", err)
+	}
+}
+
+func TestEngine_ResumeEscalation_executesInterruptedApproval(t *testing.T) {
+	executions := 0
+	ports := &mockPorts{executeFunc: func(context.Context, string, string, map[string]any) (map[string]any, error) {
+		executions++
+		return map[string]any{"ok": true}, nil
+	}}
+	e, store, now := escalatingEngine(t, "review", QueueDef{SLA: "1h"}, ports)
+	ctx := context.Background()
+	id := escalate(t, e)
+
+	if _, err := e.ResumeEscalation(ctx, id, "alice", "approved"); !errors.Is(err, ErrStatusChanged) {
+		t.Errorf("expected a pending escalation not to resume, got %v", err)
+	}
+	// Claimed, then interrupted before executing.
+	pending, _ := store.Escalation(ctx, id)
+	claimed := *pending
+	claimed.Status, claimed.ResolvedAt, claimed.ResolvedBy = EscalationApproved, *now, "alice"
+	if err := store.Transition(ctx, &claimed, EscalationPending); err != nil {
+		t.Fatal(err)
+	}
+
+	esc, err := e.ResumeEscalation(ctx, id, "alice", "approved")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if executions != 1 || esc.Status != EscalationExecuted || esc.ResolvedBy != "alice" || esc.Output["ok"] != true {
+		t.Errorf("expected one execution recorded, got %d and %+v", executions, esc)
+	}
+	if _, err := e.ResumeEscalation(ctx, id, "alice", "approved"); !errors.Is(err, ErrStatusChanged) {
+		t.Errorf("expected ErrStatusChanged resuming twice, got %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// snapshotFormat is bumped whenever Contract changes shape, so processes
+// running different builds do not misread each other's snapshots.
+const snapshotFormat = 1
+
+// ErrSnapshotMismatch is returned by ReadSnapshot for a snapshot of another
+// contract, environment or snapshot format.
+var ErrSnapshotMismatch = errors.New("contract snapshot does not match")
+
+// snapshot is the gob-encoded content of a snapshot file.
+type snapshot struct {
+	Format   int
+	ETag     string
+	Contract *Contract
+}
+
+func init() {
+	// Operands, defaults and bindings hold JSON-like values in interfaces.
+	gob.Register(map[string]any{})
+	gob.Register([]any{})
+}
+
+// WriteSnapshot saves a compiled, bound contract to path, so other
+// processes on the host can load it with ReadSnapshot instead of fetching
+// and compiling it themselves. Put it on a tmpfs such as /dev/shm. The file
+// is replaced atomically and left read-only.
+func WriteSnapshot(path, etag string, c *Contract) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(snapshot{Format: snapshotFormat, ETag: etag, Contract: c}); err != nil {
+		return fmt.Errorf("encode contract snapshot: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o444); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// ReadSnapshot loads the contract WriteSnapshot saved to path, which must
+// be the contract with the given ETag bound for env. The file is mapped
+// into memory rather than read, so processes loading it share the page
+// cache's copy. A missing file is reported as an fs.ErrNotExist error.
+func ReadSnapshot(path, etag, env string) (*Contract, error) {
+	data, unmap, err := mapFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer unmap()
+
+	var snap snapshot
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&snap); err != nil {
+		return nil, fmt.Errorf("decode contract snapshot: %w", err)
+	}
+	switch {
+	case snap.Format != snapshotFormat:
+		return nil, fmt.Errorf("%w: format %d, want %d", ErrSnapshotMismatch, snap.Format, snapshotFormat)
+	case snap.ETag != etag:
+		return nil, fmt.Errorf("%w: etag %s, want %s", ErrSnapshotMismatch, snap.ETag, etag)
+	case snap.Contract == nil || snap.Contract.Environment != env:
+		return nil, fmt.Errorf("%w: not bound for environment %q", ErrSnapshotMismatch, env)
+	}
+	snap.Contract.restoreMaps()
+	return snap.Contract, nil
+}
+
+// restoreMaps allocates the maps and lists a compiled contract always has,
+// which gob leaves nil when they are empty.
+func (c *Contract) restoreMaps() {
+	if c.Facts == nil {
+		c.Facts = map[string]FactDef{}
+	}
+	if c.DerivedFacts == nil {
+		c.DerivedFacts = map[string]DerivedFactDef{}
+	}
+	if c.Operations == nil {
+		c.Operations = map[string]OperationDef{}
+	}
+	if c.Entities == nil {
+		c.Entities = map[string]EntityDef{}
+	}
+	for name, op := range c.Operations {
+		if op.Transitions == nil {
+			op.Transitions = []EntityTransitionRef{}
+			c.Operations[name] = op
+		}
+	}
+}
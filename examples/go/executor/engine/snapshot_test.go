@@ -0,0 +1,55 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSnapshot_roundTripsTheBillingContract(t *testing.T) {
+	c, err := CompileDir("../../contracts/billing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Bind("dev", nil); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "billing.snap")
+	if err := WriteSnapshot(path, "v1", c); err != nil {
+		t.Fatal(err)
+	}
+	if fi, _ := os.Stat(path); fi.Mode().Perm() != 0o444 {
+		t.Errorf("expected a read-only snapshot, got %v", fi.Mode())
+	}
+
+	got, err := ReadSnapshot(path, "v1", "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := json.Marshal(c)
+	if data, _ := json.Marshal(got); string(data) != string(want) {
+		t.Errorf("snapshot changed the contract:\n got %s\nwant %s", data, want)
+	}
+}
+
+func TestReadSnapshot_rejectsOtherContracts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "c.snap")
+	if _, err := ReadSnapshot(path, "v1", ""); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected a missing snapshot reported as such, got %v", err)
+	}
+	if err := WriteSnapshot(path, "v1", makeMinimalContract()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadSnapshot(path, "v2", ""); !errors.Is(err, ErrSnapshotMismatch) {
+		t.Errorf("expected another ETag's snapshot rejected, got %v", err)
+	}
+	if _, err := ReadSnapshot(path, "v1", "prod"); !errors.Is(err, ErrSnapshotMismatch) {
+		t.Errorf("expected another environment's snapshot rejected, got %v", err)
+	}
+	if err := WriteSnapshot(path, "v2", makeMinimalContract()); err != nil {
+		t.Errorf("expected a snapshot replaced, got %v", err)
+	}
+}
@@ -0,0 +1,236 @@
+package engine
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNotFound is returned by stores when the requested record does not exist.
+var ErrNotFound = errors.New("not found")
+
+// DecisionStore is an AuditSink that keeps records for later lookup by
+// invocation ID.
+type DecisionStore interface {
+	AuditSink
+	Decision(ctx context.Context, invocationID string) (*AuditRecord, error)
+}
+
+// IdempotencyStore remembers the responses of live requests by idempotency
+// key, so a retried request replays the original outcome instead of
+// executing twice.
+type IdempotencyStore interface {
+	// Claim atomically reserves key for a request with the given
+	// fingerprint. It returns nil if the key was free, otherwise the
+	// existing record — whose Response is nil while the original request is
+	// still in flight.
+	Claim(ctx context.Context, key, fingerprint string, at time.Time) (*IdempotencyRecord, error)
+	// Complete stores the response for a claimed key.
+	Complete(ctx context.Context, key string, resp *Response) error
+	// Release frees a claimed key so the request can be retried.
+	Release(ctx context.Context, key string) error
+}
+
+// IdempotencyRecord is the stored state of one idempotency key.
+type IdempotencyRecord struct {
+	Key         string    `json:"key"`
+	Fingerprint string    `json:"fingerprint"`
+	Response    *Response `json:"response,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// EscalationStore holds escalated requests awaiting review.
+type EscalationStore interface {
+	Enqueue(ctx context.Context, esc *Escalation) error
+	Escalation(ctx context.Context, id string) (*Escalation, error)
+	// Escalations lists escalations, oldest first, optionally filtered by
+	// queue and status.
+	Escalations(ctx context.Context, queue, status string) ([]*Escalation, error)
+	// Transition moves escalation esc.ID from status from to esc.Status,
+	// storing esc's resolution fields. It fails with ErrStatusChanged if the
+	// escalation is not in status from, so executors sharing a store
+	// resolve each escalation once.
+	Transition(ctx context.Context, esc *Escalation, from string) error
+}
+
+// CounterStore keeps fixed-window counters, for quotas and velocity limits
+// that must hold across executor replicas.
+type CounterStore interface {
+	// Add adds delta to key's counter for the window of the given length
+	// containing at, and returns the window's new total.
+	Add(ctx context.Context, key string, window time.Duration, at time.Time, delta int64) (int64, error)
+	// Sum returns the total of key's counters over the windows of the
+	// given length from the one containing from to the one containing to.
+	// It creates no counters.
+	Sum(ctx context.Context, key string, window time.Duration, from, to time.Time) (int64, error)
+}
+
+// StateStore tracks the current state of entity instances, so rules can
+// read it with entity:<entity>.state. Each instance has a version, which
+// every transition increments.
+type StateStore interface {
+	// GetState returns the state of the instance of entity with the given
+	// ID, and its version. It returns ErrNotFound for an instance it does
+	// not track, which is in the entity's initial state at version 0.
+	GetState(ctx context.Context, entity, id string) (state string, version int64, err error)
+	// TransitionCAS moves the instance to state to if it is still at
+	// version, and returns its new version. It fails with ErrStateConflict
+	// if another transition got there first, so of two concurrent
+	// transitions from one version exactly one succeeds.
+	TransitionCAS(ctx context.Context, entity, id string, version int64, to string) (int64, error)
+}
+
+// ErrStateConflict is returned by StateStore.TransitionCAS when the entity
+// instance has moved past the expected version.
+var ErrStateConflict = errors.New("entity state changed")
+
+// Escalation statuses. A pending escalation is resolved once: denied, or
+// approved and then executed or failed.
+const (
+	EscalationPending  = "pending"
+	EscalationApproved = "approved" // claimed for execution
+	EscalationExecuted = "executed"
+	EscalationDenied   = "denied"
+	EscalationFailed   = "failed" // approved, but the execution failed
+)
+
+// ErrStatusChanged is returned by EscalationStore.Transition when the
+// escalation is no longer in the expected status.
+var ErrStatusChanged = errors.New("escalation status changed")
+
+// Escalation is a request held for review by an escalate verdict.
+type Escalation struct {
+	ID           string         `json:"id"`
+	InvocationID string         `json:"invocation_id"`
+	Operation    string         `json:"operation"`
+	Queue        string         `json:"queue"`
+	Rule         string         `json:"rule"`
+	Reason       string         `json:"reason,omitempty"`
+	Input        map[string]any `json:"input"`
+	Status       string         `json:"status"`
+	CreatedAt    time.Time      `json:"created_at"`
+
+	// DueAt is when the queue's SLA says the escalation must be decided,
+	// and ExpiresAt when it is resolved per OnExpiry if still pending.
+	// They are set from the queue catalog when the escalation is queued.
+	DueAt     time.Time `json:"due_at,omitzero"`
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+	OnExpiry  string    `json:"on_expiry,omitempty"`
+
+	// Resolution, once the escalation has left pending. Output and Error
+	// are the result of executing an approved escalation.
+	ResolvedAt time.Time      `json:"resolved_at,omitzero"`
+	ResolvedBy string         `json:"resolved_by,omitempty"`
+	Output     map[string]any `json:"output,omitempty"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// WithIdempotencyStore enables idempotency keys on live requests.
+func WithIdempotencyStore(s IdempotencyStore) Option {
+	return func(e *Engine) { e.idempotency = s }
+}
+
+// WithEscalationStore records every escalated request in s.
+func WithEscalationStore(s EscalationStore) Option {
+	return func(e *Engine) { e.escalations = s }
+}
+
+// WithStateStore resolves entity state references through s.
+func WithStateStore(s StateStore) Option {
+	return func(e *Engine) { e.states = s }
+}
+
+// requestFingerprint identifies a request's operation and input, so an
+// idempotency key reused for a different request can be detected.
+func requestFingerprint(req *Request) string {
+	input, _ := json.Marshal(req.Input) // map keys marshal sorted
+	sum := sha256.Sum256(append([]byte(req.Operation+"\x00"), input...))
+	return hex.EncodeToString(sum[:])
+}
+
+// claimIdempotencyKey reserves req's idempotency key. It returns a response
+// to send instead of evaluating — the stored one on a replay, or an error if
+// the key is in flight or was used for a different request.
+func (e *Engine) claimIdempotencyKey(ctx context.Context, req *Request) (*Response, error) {
+	existing, err := e.idempotency.Claim(ctx, req.IdempotencyKey, requestFingerprint(req), e.now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("idempotency store: %w", err)
+	}
+	switch {
+	case existing == nil:
+		return nil, nil
+	case existing.Fingerprint != requestFingerprint(req):
+		return &Response{
+			Outcome: OutcomeSystemError,
+			Error: &ErrorEnvelope{
+				Code:       "IDEMPOTENCY_KEY_REUSED",
+				Message:    "Idempotency key was already used for a different request",
+				HttpStatus: 422,
+				Category:   "validation",
+				Retryable:  false,
+				Suggestion: "Use a new idempotency key for each distinct request",
+			},
+		}, nil
+	case existing.Response == nil:
+		return &Response{
+			Outcome: OutcomeSystemError,
+			Error: &ErrorEnvelope{
+				Code:       "IDEMPOTENCY_KEY_IN_FLIGHT",
+				Message:    "A request with this idempotency key is still being processed",
+				HttpStatus: 409,
+				Category:   "system",
+				Retryable:  true,
+			},
+		}, nil
+	}
+	replay := *existing.Response
+	replay.IdempotentReplay = true
+	return &replay, nil
+}
+
+// settleIdempotencyKey stores resp under req's key, or releases the key if
+// resp is a retryable system error, throttled, a state conflict or a port
+// contract violation so the client can try again.
+// A store failure leaves the key claimed; see Engine.Evaluate.
+func (e *Engine) settleIdempotencyKey(ctx context.Context, req *Request, resp *Response) {
+	switch resp.Outcome {
+	case OutcomeSystemError, OutcomeThrottled, OutcomeStateConflict, OutcomePortContractViolation:
+		if resp.Error != nil && resp.Error.Retryable {
+			e.idempotency.Release(ctx, req.IdempotencyKey)
+			return
+		}
+	}
+	e.idempotency.Complete(ctx, req.IdempotencyKey, resp)
+}
+
+// enqueueEscalation records an escalated request, with the deadlines its
+// queue in c's catalog sets.
+func (e *Engine) enqueueEscalation(ctx context.Context, c *Contract, req *Request, rec *AuditRecord, v *Verdict) (string, error) {
+	esc := &Escalation{
+		ID:           "esc_" + randID(16),
+		InvocationID: rec.InvocationID,
+		Operation:    req.Operation,
+		Queue:        v.Queue,
+		Rule:         v.Rule,
+		Reason:       v.Reason,
+		Input:        req.Input,
+		Status:       EscalationPending,
+		CreatedAt:    rec.Timestamp,
+	}
+	if q, ok := c.Queues[v.Queue]; ok {
+		if sla := q.SLADuration(); sla > 0 {
+			esc.DueAt = rec.Timestamp.Add(sla)
+		}
+		if expiry := q.HardExpiryDuration(); expiry > 0 {
+			esc.ExpiresAt, esc.OnExpiry = rec.Timestamp.Add(expiry), q.OnExpiry
+		}
+	}
+	if err := e.escalations.Enqueue(ctx, esc); err != nil {
+		return "", err
+	}
+	return esc.ID, nil
+}
@@ -0,0 +1,215 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memStore is an in-memory IdempotencyStore and EscalationStore.
+type memStore struct {
+	mu          sync.Mutex
+	keys        map[string]*IdempotencyRecord
+	escalations []*Escalation
+	enqueueErr  error
+}
+
+func newMemStore() *memStore {
+	return &memStore{keys: map[string]*IdempotencyRecord{}}
+}
+
+func (m *memStore) Claim(_ context.Context, key, fingerprint string, at time.Time) (*IdempotencyRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if r, ok := m.keys[key]; ok {
+		cp := *r
+		return &cp, nil
+	}
+	m.keys[key] = &IdempotencyRecord{Key: key, Fingerprint: fingerprint, CreatedAt: at}
+	return nil, nil
+}
+
+func (m *memStore) Complete(_ context.Context, key string, resp *Response) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys[key].Response = resp
+	return nil
+}
+
+func (m *memStore) Release(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.keys, key)
+	return nil
+}
+
+func (m *memStore) Enqueue(_ context.Context, esc *Escalation) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.enqueueErr != nil {
+		return m.enqueueErr
+	}
+	m.escalations = append(m.escalations, esc)
+	return nil
+}
+
+func (m *memStore) Escalation(_ context.Context, id string) (*Escalation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, esc := range m.escalations {
+		if esc.ID == id {
+			return esc, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (m *memStore) Transition(_ context.Context, esc *Escalation, from string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, cur := range m.escalations {
+		if cur.ID == esc.ID {
+			if cur.Status != from {
+				return ErrStatusChanged
+			}
+			cp := *esc
+			m.escalations[i] = &cp
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (m *memStore) Escalations(_ context.Context, queue, status string) ([]*Escalation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []*Escalation
+	for _, esc := range m.escalations {
+		if (queue == "" || esc.Queue == queue) && (status == "" || esc.Status == status) {
+			out = append(out, esc)
+		}
+	}
+	return out, nil
+}
+
+func TestEngine_Evaluate_idempotencyKeyReplaysResponse(t *testing.T) {
+	var executions int
+	ports := &mockPorts{executeFunc: func(context.Context, string, string, map[string]any) (map[string]any, error) {
+		executions++
+		return map[string]any{"n": executions}, nil
+	}}
+	e := NewEngine(ports, WithIdempotencyStore(newMemStore()))
+	e.LoadContract(makeMinimalContract(), "v1")
+
+	req := &Request{Operation: "testOp", Input: map[string]any{"a": 1}, IdempotencyKey: "k1"}
+	first, _ := e.Evaluate(context.Background(), req)
+	second, _ := e.Evaluate(context.Background(), req)
+
+	if executions != 1 {
+		t.Fatalf("expected one execution, got %d", executions)
+	}
+	if !second.IdempotentReplay || second.InvocationID != first.InvocationID {
+		t.Errorf("expected replay of %s, got %+v", first.InvocationID, second)
+	}
+	if first.IdempotentReplay {
+		t.Error("original response must not be marked as a replay")
+	}
+}
+
+func TestEngine_Evaluate_idempotencyKeyReusedForDifferentInput(t *testing.T) {
+	e := NewEngine(&mockPorts{}, WithIdempotencyStore(newMemStore()))
+	e.LoadContract(makeMinimalContract(), "v1")
+
+	e.Evaluate(context.Background(), &Request{Operation: "testOp", Input: map[string]any{"a": 1}, IdempotencyKey: "k1"})
+	resp, _ := e.Evaluate(context.Background(), &Request{Operation: "testOp", Input: map[string]any{"a": 2}, IdempotencyKey: "k1"})
+	if resp.Error == nil || resp.Error.Code != "IDEMPOTENCY_KEY_REUSED" {
+		t.Errorf("expected IDEMPOTENCY_KEY_REUSED, got %+v", resp.Error)
+	}
+}
+
+func TestEngine_Evaluate_idempotencyKeyInFlight(t *testing.T) {
+	store := newMemStore()
+	e := NewEngine(&mockPorts{}, WithIdempotencyStore(store))
+	e.LoadContract(makeMinimalContract(), "v1")
+
+	req := &Request{Operation: "testOp", IdempotencyKey: "k1"}
+	store.Claim(context.Background(), "k1", requestFingerprint(req), time.Now())
+	resp, _ := e.Evaluate(context.Background(), req)
+	if resp.Error == nil || resp.Error.Code != "IDEMPOTENCY_KEY_IN_FLIGHT" || !resp.Error.Retryable {
+		t.Errorf("expected retryable IDEMPOTENCY_KEY_IN_FLIGHT, got %+v", resp.Error)
+	}
+}
+
+func TestEngine_Evaluate_retryableFailureReleasesIdempotencyKey(t *testing.T) {
+	fail := true
+	ports := &mockPorts{executeFunc: func(context.Context, string, string, map[string]any) (map[string]any, error) {
+		if fail {
+			return nil, errors.New("processor down")
+		}
+		return map[string]any{}, nil
+	}}
+	e := NewEngine(ports, WithIdempotencyStore(newMemStore()))
+	e.LoadContract(makeMinimalContract(), "v1")
+
+	req := &Request{Operation: "testOp", IdempotencyKey: "k1"}
+	if resp, _ := e.Evaluate(context.Background(), req); resp.Outcome != "system_error" {
+		t.Fatalf("expected system_error, got %s", resp.Outcome)
+	}
+	fail = false
+	if resp, _ := e.Evaluate(context.Background(), req); resp.Outcome != "executed" || resp.IdempotentReplay {
+		t.Errorf("expected a fresh execution, got %+v", resp)
+	}
+}
+
+func TestEngine_Evaluate_dryRunIgnoresIdempotencyKey(t *testing.T) {
+	store := newMemStore()
+	e := NewEngine(&mockPorts{}, WithIdempotencyStore(store))
+	e.LoadContract(makeMinimalContract(), "v1")
+
+	e.Evaluate(context.Background(), &Request{Operation: "testOp", IdempotencyKey: "k1", DryRun: true})
+	if len(store.keys) != 0 {
+		t.Errorf("dry-run must not claim idempotency keys, got %v", store.keys)
+	}
+}
+
+func TestEngine_Evaluate_escalationEnqueued(t *testing.T) {
+	store := newMemStore()
+	sink := &recordingSink{}
+	e := NewEngine(&mockPorts{}, WithEscalationStore(store), WithAuditSink(sink))
+	e.LoadContract(makeSimpleContract("review",
+		VerdictDef{Escalate: &EscalateVerdict{Queue: "manual-review", Reason: "check"}},
+		Condition{},
+	), "v1")
+
+	resp, _ := e.Evaluate(context.Background(), &Request{Operation: "testOp", Input: map[string]any{"a": 1}})
+	if resp.Outcome != "escalated" || resp.EscalationID == "" {
+		t.Fatalf("expected escalated with an escalation ID, got %+v", resp)
+	}
+	esc, err := store.Escalation(context.Background(), resp.EscalationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if esc.Queue != "manual-review" || esc.Rule != "review" || esc.InvocationID != resp.InvocationID || esc.Status != EscalationPending {
+		t.Errorf("unexpected escalation %+v", esc)
+	}
+	if rec := sink.recs[0]; rec.EscalationID != resp.EscalationID {
+		t.Errorf("expected the audit record to carry the escalation ID, got %q", rec.EscalationID)
+	}
+}
+
+func TestEngine_Evaluate_escalationStoreFailureIsRetryable(t *testing.T) {
+	store := newMemStore()
+	store.enqueueErr = errors.New("disk full")
+	e := NewEngine(&mockPorts{}, WithEscalationStore(store))
+	e.LoadContract(makeSimpleContract("review",
+		VerdictDef{Escalate: &EscalateVerdict{Queue: "q"}},
+		Condition{},
+	), "v1")
+
+	resp, _ := e.Evaluate(context.Background(), &Request{Operation: "testOp"})
+	if resp.Outcome != "system_error" || resp.Error.Code != "ESCALATION_FAILED" || !resp.Error.Retryable {
+		t.Errorf("expected retryable ESCALATION_FAILED, got %+v", resp)
+	}
+}
@@ -0,0 +1,73 @@
+package engine
+
+import (
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// StringCompare modifies how equals and in compare strings, so values from
+// systems that disagree on case, padding or Unicode form still match. A
+// contract's StringCompare is the default for its conditions, whose own
+// set fields override it.
+type StringCompare struct {
+	CaseInsensitive *bool `json:"case_insensitive,omitempty"`
+	Trim            *bool `json:"trim,omitempty"` // ignore leading and trailing white space
+	NFC             *bool `json:"nfc,omitempty"`  // compare in Unicode normalization form C
+}
+
+// stringMode is the comparison a condition's StringCompare and its
+// contract's default settle on.
+type stringMode struct {
+	fold, trim, nfc bool
+}
+
+// stringModeFor applies cond's modifiers over the contract default def.
+func stringModeFor(def, cond *StringCompare) stringMode {
+	var m stringMode
+	for _, sc := range []*StringCompare{def, cond} {
+		if sc == nil {
+			continue
+		}
+		if sc.CaseInsensitive != nil {
+			m.fold = *sc.CaseInsensitive
+		}
+		if sc.Trim != nil {
+			m.trim = *sc.Trim
+		}
+		if sc.NFC != nil {
+			m.nfc = *sc.NFC
+		}
+	}
+	return m
+}
+
+// exact reports whether m compares strings as they are.
+func (m stringMode) exact() bool {
+	return m == stringMode{}
+}
+
+// equal compares left and right as equals does, normalizing them first
+// when both are strings.
+func (m stringMode) equal(left, right any) bool {
+	l, okl := left.(string)
+	r, okr := right.(string)
+	if m.exact() || !okl || !okr {
+		return applyOp("equals", left, right)
+	}
+	l, r = m.normalize(l), m.normalize(r)
+	if m.fold {
+		return strings.EqualFold(l, r)
+	}
+	return l == r
+}
+
+func (m stringMode) normalize(s string) string {
+	if m.trim {
+		s = strings.TrimSpace(s)
+	}
+	if m.nfc {
+		s = norm.NFC.String(s)
+	}
+	return s
+}
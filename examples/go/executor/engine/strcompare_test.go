@@ -0,0 +1,94 @@
+package engine
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEvalCondition_stringCompareModifiers(t *testing.T) {
+	yes, no := true, false
+	c := makeMinimalContract()
+	c.StringCompare = &StringCompare{Trim: &yes}
+	fs := NewFactSet()
+	fs.evaluateAs(c, time.Now())
+	fs.Set("customer.status", " ACTIVE")
+	fs.Set("customer.city", "Caf\u00e9")
+	fs.Set("customer.tier", 2)
+
+	for _, tc := range []struct {
+		cond Condition
+		want bool
+	}{
+		{Condition{Fact: "customer.status", Equals: "active"}, false},
+		{Condition{Fact: "customer.status", Equals: "active", Compare: &StringCompare{CaseInsensitive: &yes}}, true},
+		{Condition{Fact: "customer.status", In: []any{"closed", "Active"}, Compare: &StringCompare{CaseInsensitive: &yes}}, true},
+		// A condition overrides the contract default.
+		{Condition{Fact: "customer.status", Equals: "ACTIVE"}, true},
+		{Condition{Fact: "customer.status", Equals: "ACTIVE", Compare: &StringCompare{Trim: &no}}, false},
+		// A precomposed é against e and a combining acute accent.
+		{Condition{Fact: "customer.city", Equals: "Cafe\u0301"}, false},
+		{Condition{Fact: "customer.city", Equals: "Cafe\u0301", Compare: &StringCompare{NFC: &yes}}, true},
+		{Condition{Fact: "customer.city", Equals: "CAFE\u0301", Compare: &StringCompare{NFC: &yes, CaseInsensitive: &yes}}, true},
+		// Values other than strings compare as before.
+		{Condition{Fact: "customer.tier", Equals: 2.0, Compare: &StringCompare{CaseInsensitive: &yes}}, true},
+	} {
+		if got := evalCondition(tc.cond, fs); got != tc.want {
+			t.Errorf("%+v: expected %v, got %v", tc.cond, tc.want, got)
+		}
+	}
+}
+
+func TestEngine_Simulate_contractStringCompare(t *testing.T) {
+	yes := true
+	c := makeMinimalContract()
+	c.StringCompare = &StringCompare{CaseInsensitive: &yes}
+	c.Facts = map[string]FactDef{"customer.status": {Source: "input"}}
+	c.Rules = []RuleDef{{
+		ID:        "suspended",
+		AppliesTo: []string{"testOp"},
+		When:      Condition{Fact: "customer.status", Equals: "suspended"},
+		Verdict:   VerdictDef{Deny: &DenyVerdict{Code: "SUSPENDED", Reason: "suspended"}},
+	}}
+	c.Operations["testOp"] = OperationDef{ConstrainedBy: []string{"suspended"}}
+	e := NewEngine(&mockPorts{})
+	e.LoadContract(c, "v1")
+
+	resp, err := e.Simulate(&SimulateRequest{Operation: "testOp", Facts: map[string]any{"customer.status": "SUSPENDED"}, Explain: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Outcome != OutcomeWouldDeny || !resp.Explain.Rules[0].Matched {
+		t.Errorf("expected the case-insensitive default to deny, got %s", resp.Outcome)
+	}
+
+	batch, err := e.SimulateBatch(&BatchSimulateRequest{Operation: "testOp", Rows: []map[string]any{
+		{"customer.status": "Suspended"},
+		{"customer.status": "active"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch.Vectorized) != 0 || batch.RuleMatches["suspended"] != 1 {
+		t.Errorf("expected one match, evaluated row by row, got %+v", batch)
+	}
+}
+
+func TestCompileSources_stringCompare(t *testing.T) {
+	c, err := CompileSources(map[string][]byte{"contract.cue": []byte(`
+string_compare: {case_insensitive: true, trim: true}
+facts: "customer.status": {source: "input"}
+rules: [{
+	id: "suspended"
+	applies_to: ["Pay"]
+	when: {fact: "customer.status", equals: "suspended", compare: {trim: false}}
+	verdict: deny: {code: "SUSPENDED", reason: "Customer is suspended"}
+}]
+operations: Pay: {constrained_by: ["suspended"], transitions: []}
+`)}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := stringModeFor(c.StringCompare, c.Rules[0].When.Compare); got != (stringMode{fold: true}) {
+		t.Errorf("expected case folding only, got %+v", got)
+	}
+}
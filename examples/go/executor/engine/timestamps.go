@@ -0,0 +1,250 @@
+package engine
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	// Contracts name their timezone; embed the database so hosts without
+	// one, such as minimal containers, load them alike.
+	_ "time/tzdata"
+)
+
+// FactTypeTimestamp is the type of facts holding instants, compared with
+// the before, after and between operators.
+const FactTypeTimestamp = "timestamp"
+
+// localLayouts are the timestamp forms without an offset, read in the
+// contract's timezone: a local date and time, and a date, meaning its
+// midnight.
+var localLayouts = []string{"2006-01-02T15:04:05", "2006-01-02"}
+
+// parseTime reads v as an instant: an RFC 3339 string, a string in one of
+// the localLayouts read in loc, or a time.Time.
+func parseTime(v any, loc *time.Location) (time.Time, bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, true
+	case string:
+		if ts, err := time.Parse(time.RFC3339Nano, t); err == nil {
+			return ts, true
+		}
+		for _, layout := range localLayouts {
+			if ts, err := time.ParseInLocation(layout, t, loc); err == nil {
+				return ts, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// timeOperand reads a before, after or between operand: a timestamp as
+// parseTime reads it, or "now" or "today" (midnight in loc), optionally
+// offset by a duration, as in "now-1h" or "today+30d". Offsets in whole
+// days move by calendar days.
+func timeOperand(v any, now time.Time, loc *time.Location) (time.Time, bool) {
+	s, ok := v.(string)
+	if !ok {
+		return parseTime(v, loc)
+	}
+	for _, anchor := range []string{"now", "today"} {
+		rest, ok := strings.CutPrefix(s, anchor)
+		if !ok {
+			continue
+		}
+		t := now.In(loc)
+		if anchor == "today" {
+			y, m, d := t.Date()
+			t = time.Date(y, m, d, 0, 0, 0, 0, loc)
+		}
+		rest = strings.TrimSpace(rest)
+		if rest == "" {
+			return t, true
+		}
+		q, ok := parseQuantity(rest[1:])
+		if !ok || q.dim != "duration" || rest[0] != '+' && rest[0] != '-' {
+			return time.Time{}, false
+		}
+		if rest[0] == '-' {
+			q.value = -q.value
+		}
+		if days := q.value / 86400; days == float64(int(days)) {
+			return t.AddDate(0, 0, int(days)), true
+		}
+		return t.Add(time.Duration(q.value * float64(time.Second))), true
+	}
+	return parseTime(s, loc)
+}
+
+// isTime reports whether cond compares a timestamp fact.
+func (cond Condition) isTime() bool {
+	return cond.Before != nil || cond.After != nil || len(cond.Between) > 0
+}
+
+// evalTime applies cond's before, after or between operator to val. Between
+// holds from its first instant up to, not including, its second. Values and
+// operands that are not timestamps never compare.
+func evalTime(cond Condition, val any, facts *FactSet) bool {
+	now, loc := facts.clock()
+	t, ok := parseTime(val, loc)
+	if !ok {
+		return false
+	}
+	bound := func(v any) (time.Time, bool) {
+		return timeOperand(resolveOperand(v, facts), now, loc)
+	}
+	switch {
+	case cond.Before != nil:
+		b, ok := bound(cond.Before)
+		return ok && t.Before(b)
+	case cond.After != nil:
+		b, ok := bound(cond.After)
+		return ok && t.After(b)
+	case len(cond.Between) == 2:
+		from, okf := bound(cond.Between[0])
+		to, okt := bound(cond.Between[1])
+		return okf && okt && !t.Before(from) && t.Before(to)
+	}
+	return false
+}
+
+// readsClock reports whether cond compares a timestamp with the time of
+// evaluation, so its result may change while the facts do not. Param
+// operands count, as requests may supply their values.
+func readsClock(cond Condition) bool {
+	clock := false
+	eachCondition(cond, func(cond Condition) {
+		for _, v := range append([]any{cond.Before, cond.After}, cond.Between...) {
+			s, ok := v.(string)
+			_, param := paramRef(v)
+			if param || ok && (strings.HasPrefix(s, "now") || strings.HasPrefix(s, "today")) {
+				clock = true
+			}
+		}
+	})
+	return clock
+}
+
+// locations caches loaded timezones by name.
+var locations sync.Map
+
+func loadLocation(name string) (*time.Location, error) {
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+// location returns the contract's timezone, UTC if it declares none or
+// one Validate reports.
+func (c *Contract) location() *time.Location {
+	if c.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := loadLocation(c.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// timestampRefused refuses a request whose input timestamp facts do not
+// parse, naming the first such fact.
+func timestampRefused(c *Contract, req *Request, facts *FactSet) *Response {
+	var names []string
+	for name, def := range c.Facts {
+		if def.Type == FactTypeTimestamp && def.Source == "input" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	loc := c.location()
+	for _, name := range names {
+		val, ok := facts.Get(name)
+		if !ok || val == nil {
+			continue
+		}
+		if _, ok := parseTime(val, loc); !ok {
+			return &Response{DryRun: req.DryRun, Outcome: OutcomeDenied, Error: &ErrorEnvelope{
+				Code:       "INVALID_TIMESTAMP",
+				Message:    fmt.Sprintf("%s is %v, not a timestamp", name, val),
+				HttpStatus: http.StatusUnprocessableEntity,
+				Category:   "validation",
+				Suggestion: "Send an RFC 3339 timestamp, such as 2024-01-31T17:00:00Z, or a date, such as 2024-01-31",
+				Details:    map[string]any{"fact": name},
+			}}
+		}
+	}
+	return nil
+}
+
+// checkTimes reports a timestamp comparison that can never hold: on a
+// declared fact of another type, or with an operand that is not a
+// timestamp.
+func checkTimes(c *Contract, cond Condition, at time.Time) []string {
+	if !cond.isTime() {
+		return nil
+	}
+	var problems []string
+	if def, ok := c.Facts[cond.Fact]; ok && def.Type != FactTypeTimestamp {
+		problems = append(problems, fmt.Sprintf("%s is not declared type: %q", cond.Fact, FactTypeTimestamp))
+	}
+	if len(cond.Between) > 0 && len(cond.Between) != 2 {
+		problems = append(problems, fmt.Sprintf("%s between needs a from and a to timestamp, got %d", cond.Fact, len(cond.Between)))
+	}
+	for _, t := range []struct {
+		op       string
+		operands []any
+	}{{"before", []any{cond.Before}}, {"after", []any{cond.After}}, {"between", cond.Between}} {
+		for _, v := range t.operands {
+			if v == nil {
+				continue
+			}
+			if _, ok := timeOperand(c.ResolveOperand(v), at, c.location()); !ok {
+				problems = append(problems, fmt.Sprintf("%s %s %v is not a timestamp", cond.Fact, t.op, c.ResolveOperand(v)))
+			}
+		}
+	}
+	return problems
+}
+
+// validateTimezone checks the contract's timezone.
+func validateTimezone(c *Contract) []Diagnostic {
+	if c.Timezone == "" {
+		return nil
+	}
+	if _, err := loadLocation(c.Timezone); err != nil {
+		return []Diagnostic{{
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("timezone %q: %v", c.Timezone, err),
+		}}
+	}
+	return nil
+}
+
+// validateFactType checks a fact's type.
+func validateFactType(name string, def FactDef) []Diagnostic {
+	switch {
+	case def.Type == "":
+		return nil
+	case def.Type != FactTypeTimestamp:
+		return []Diagnostic{{
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("fact %s: unknown type %q", name, def.Type),
+		}}
+	case def.Unit != "":
+		return []Diagnostic{{
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("fact %s: a %s has no unit", name, def.Type),
+		}}
+	}
+	return nil
+}
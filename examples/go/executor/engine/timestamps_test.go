@@ -0,0 +1,219 @@
+package engine
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimeOperand(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 23:30 UTC on 25 October 2025 is already the 26th in Paris, the day
+	// its clocks go back.
+	now := time.Date(2025, 10, 25, 23, 30, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		in   any
+		want time.Time
+		ok   bool
+	}{
+		{"now", now, true},
+		{"now-90m", now.Add(-90 * time.Minute), true},
+		{"today", time.Date(2025, 10, 26, 0, 0, 0, 0, paris), true},
+		{"today+1d", time.Date(2025, 10, 27, 0, 0, 0, 0, paris), true},
+		{"today - 1w", time.Date(2025, 10, 19, 0, 0, 0, 0, paris), true},
+		{"2025-10-01T08:00:00Z", time.Date(2025, 10, 1, 8, 0, 0, 0, time.UTC), true},
+		{"2025-10-01T08:00:00.5+02:00", time.Date(2025, 10, 1, 6, 0, 0, 5e8, time.UTC), true},
+		{"2025-10-01T08:00:00", time.Date(2025, 10, 1, 8, 0, 0, 0, paris), true},
+		{"2025-10-01", time.Date(2025, 10, 1, 0, 0, 0, 0, paris), true},
+		{"now+1MB", time.Time{}, false},
+		{"now30d", time.Time{}, false},
+		{"yesterday", time.Time{}, false},
+		{1700000000, time.Time{}, false},
+	} {
+		got, ok := timeOperand(tc.in, now, paris)
+		if ok != tc.ok || !got.Equal(tc.want) {
+			t.Errorf("timeOperand(%v): expected %v %v, got %v %v", tc.in, tc.want, tc.ok, got, ok)
+		}
+	}
+}
+
+func TestEvalCondition_comparesTimestamps(t *testing.T) {
+	c := makeMinimalContract()
+	c.Timezone = "America/New_York"
+	c.Params = map[string]ParamDef{"grace": {Default: "today-30d"}}
+	fs := NewFactSet()
+	fs.evaluateAs(c, time.Date(2025, 3, 10, 15, 0, 0, 0, time.UTC))
+	fs.Set("invoice.due_date", "2025-03-09")
+	fs.Set("session.started", "2025-03-10T14:00:00Z")
+	fs.Set("params.grace", "today-30d")
+	fs.Set("bogus", "soon")
+
+	for _, tc := range []struct {
+		cond Condition
+		want bool
+	}{
+		{Condition{Fact: "invoice.due_date", Before: "today"}, true},
+		{Condition{Fact: "invoice.due_date", Before: "2025-03-09"}, false},
+		{Condition{Fact: "invoice.due_date", After: map[string]any{"param": "grace"}}, true},
+		// Midnight on 9 March in New York, before its clocks went forward,
+		// is 05:00 UTC.
+		{Condition{Fact: "invoice.due_date", After: "2025-03-09T04:59:59Z"}, true},
+		{Condition{Fact: "invoice.due_date", After: "2025-03-09T05:00:00Z"}, false},
+		{Condition{Fact: "session.started", Between: []any{"now-1h", "now"}}, true},
+		{Condition{Fact: "session.started", Between: []any{"now-30m", "now"}}, false},
+		// Between excludes its end.
+		{Condition{Fact: "session.started", Between: []any{"today", "2025-03-10T14:00:00Z"}}, false},
+		{Condition{Fact: "bogus", Before: "now"}, false},
+		{Condition{Fact: "missing", Before: "now"}, false},
+		{Condition{Fact: "invoice.due_date", Before: "soon"}, false},
+	} {
+		if got := evalCondition(tc.cond, fs); got != tc.want {
+			t.Errorf("%+v: expected %v, got %v", tc.cond, tc.want, got)
+		}
+	}
+}
+
+func timestampContract() *Contract {
+	c := makeMinimalContract()
+	c.Timezone = "Europe/Paris"
+	c.Facts = map[string]FactDef{"invoice.due_date": {Source: "input", Type: FactTypeTimestamp}}
+	c.Rules = []RuleDef{{
+		ID:        "overdue",
+		AppliesTo: []string{"testOp"},
+		When:      Condition{Fact: "invoice.due_date", Before: "today"},
+		Verdict:   VerdictDef{Flag: &FlagVerdict{Code: "OVERDUE", Reason: "Invoice is past due"}},
+	}}
+	c.Operations["testOp"] = OperationDef{ConstrainedBy: []string{"overdue"}}
+	return c
+}
+
+func TestEngine_Evaluate_comparesInputTimestamps(t *testing.T) {
+	e := NewEngine(&mockPorts{}, WithClock(func() time.Time { return time.Date(2025, 6, 2, 9, 0, 0, 0, time.UTC) }))
+	e.LoadContract(timestampContract(), "v1")
+	for _, tc := range []struct {
+		due     any
+		flagged bool
+		code    string
+	}{
+		{"2025-06-01", true, ""},
+		{"2025-06-02", false, ""},
+		{"2025-06-01T23:30:00+02:00", true, ""},
+		{"2025-06-01T23:30:00Z", false, ""}, // the 2nd in Paris
+		{"June 1st", false, "INVALID_TIMESTAMP"},
+		{20250601, false, "INVALID_TIMESTAMP"},
+	} {
+		resp, err := e.Evaluate(context.Background(), &Request{Operation: "testOp", Input: map[string]any{"invoice.due_date": tc.due}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc.code != "" {
+			if resp.Outcome != OutcomeDenied || resp.Error == nil || resp.Error.Code != tc.code || resp.Error.HttpStatus != 422 {
+				t.Errorf("%v: expected %s, got %s %+v", tc.due, tc.code, resp.Outcome, resp.Error)
+			}
+			continue
+		}
+		if flagged := len(resp.Verdicts) == 1 && resp.Verdicts[0].Code == "OVERDUE"; flagged != tc.flagged || resp.Outcome != OutcomeExecuted {
+			t.Errorf("%v: expected flagged %v, got %s %+v", tc.due, tc.flagged, resp.Outcome, resp.Verdicts)
+		}
+	}
+}
+
+func TestEngine_Simulate_comparesTimestampsAsOfAt(t *testing.T) {
+	e := NewEngine(&mockPorts{}, WithDecisionCache(100))
+	e.LoadContract(timestampContract(), "v1")
+	for _, tc := range []struct {
+		at      time.Time
+		flagged bool
+	}{
+		{time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC), false},
+		{time.Date(2025, 6, 1, 22, 30, 0, 0, time.UTC), true}, // past midnight in Paris
+		{time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC), false},
+	} {
+		resp, err := e.Simulate(&SimulateRequest{Operation: "testOp", Facts: map[string]any{"invoice.due_date": "2025-06-01"}, At: &tc.at, Explain: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if flagged := len(resp.Verdicts) == 1; flagged != tc.flagged || resp.Explain.Rules[0].Matched != tc.flagged {
+			t.Errorf("at %v: expected flagged %v, got %+v", tc.at, tc.flagged, resp.Verdicts)
+		}
+	}
+	if stats := e.DecisionCacheStats()["testOp"]; stats.Hits != 0 {
+		t.Errorf("verdicts that depend on the clock were cached: %+v", stats)
+	}
+}
+
+func TestEngine_SimulateBatch_comparesTimestampsRowByRow(t *testing.T) {
+	e := NewEngine(&mockPorts{})
+	e.LoadContract(timestampContract(), "v1")
+	at := time.Date(2025, 6, 2, 9, 0, 0, 0, time.UTC)
+	resp, err := e.SimulateBatch(&BatchSimulateRequest{Operation: "testOp", At: &at, Rows: []map[string]any{
+		{"invoice.due_date": "2025-06-05"},
+		{"invoice.due_date": "2025-05-20"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Vectorized) != 0 || resp.RuleMatches["overdue"] != 1 || len(resp.Rows[1].Rules) != 1 {
+		t.Errorf("expected only the second row overdue, evaluated row by row, got %+v", resp)
+	}
+}
+
+func TestValidate_reportsTimezonesAndFactTypes(t *testing.T) {
+	c := makeMinimalContract()
+	c.Timezone = "Mars/Olympus_Mons"
+	c.Facts = map[string]FactDef{
+		"invoice.due_date": {Source: "input", Type: "date"},
+		"session.age":      {Source: "input", Type: FactTypeTimestamp, Unit: "h"},
+	}
+	var got []string
+	for _, d := range Validate(c, time.Now()) {
+		if strings.Contains(d.Message, "timezone") || strings.Contains(d.Message, "type") || strings.Contains(d.Message, "timestamp") {
+			got = append(got, d.Message)
+		}
+	}
+	if len(got) != 3 {
+		t.Errorf("expected 3 diagnostics, got %q", got)
+	}
+}
+
+func TestEngine_Warmup_reportsTimestampComparisonsThatNeverHold(t *testing.T) {
+	c := timestampContract()
+	c.Facts["invoice.total"] = FactDef{Source: "input"}
+	c.Rules = []RuleDef{
+		{ID: "untyped", AppliesTo: []string{"testOp"}, When: Condition{Fact: "invoice.total", After: "now"}, Verdict: VerdictDef{Flag: &FlagVerdict{Code: "A"}}},
+		{ID: "operand", AppliesTo: []string{"testOp"}, When: Condition{Fact: "invoice.due_date", Before: "tomorrow"}, Verdict: VerdictDef{Flag: &FlagVerdict{Code: "B"}}},
+		{ID: "range", AppliesTo: []string{"testOp"}, When: Condition{Fact: "invoice.due_date", Between: []any{"today"}}, Verdict: VerdictDef{Flag: &FlagVerdict{Code: "C"}}},
+		{ID: "ok", AppliesTo: []string{"testOp"}, When: Condition{Fact: "invoice.due_date", Between: []any{"2025-01-01", "today+1d"}}, Verdict: VerdictDef{Flag: &FlagVerdict{Code: "D"}}},
+	}
+	diags := NewEngine(&mockPorts{}).Warmup(c, time.Now())
+	if len(diags) != 3 || diags[0].Rule != "untyped" || diags[1].Rule != "operand" || diags[2].Rule != "range" {
+		t.Errorf("expected the untyped, operand and range rules reported, got %v", diags)
+	}
+}
+
+func TestCompileSources_timestamps(t *testing.T) {
+	c, err := CompileSources(map[string][]byte{"contract.cue": []byte(`
+timezone: "Europe/Paris"
+facts: "invoice.due_date": {source: "input", type: "timestamp"}
+rules: [{
+	id: "overdue"
+	applies_to: ["Pay"]
+	when: {fact: "invoice.due_date", before: "today"}
+	verdict: flag: {code: "OVERDUE", reason: "Invoice is past due"}
+}]
+operations: Pay: {constrained_by: ["overdue"], transitions: []}
+`)}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Timezone != "Europe/Paris" || c.Facts["invoice.due_date"].Type != FactTypeTimestamp || c.Rules[0].When.Before != "today" {
+		t.Errorf("timestamps not extracted: %q %+v %+v", c.Timezone, c.Facts, c.Rules[0].When)
+	}
+	if diags := NewEngine(&mockPorts{}).Warmup(c, time.Now()); len(diags) != 0 {
+		t.Errorf("unexpected warm-up diagnostics %v", diags)
+	}
+}
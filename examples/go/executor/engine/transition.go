@@ -0,0 +1,132 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Operations that transition entities are checked optimistically: the
+// engine reads the version of each instance before evaluating rules, and
+// claims the transition with a compare-and-swap from that version just
+// before executing. Of two requests racing on one instance, exactly one
+// claims it; the other gets state_conflict without side effects, and a
+// retry evaluates its rules against the new state.
+
+// entityTransition is a transition an operation makes on one instance, with
+// the instance's state and version as its rules saw them.
+type entityTransition struct {
+	ref        EntityTransitionRef
+	entity, id string
+	from, to   string
+	version    int64
+}
+
+// readTransitions reads the instances op transitions. Entities without a
+// key, and requests without the key's input fact, are not tracked. It
+// answers state_conflict if an instance has moved since facts read its
+// state.
+func (e *Engine) readTransitions(ctx context.Context, c *Contract, op OperationDef, input map[string]any, facts *FactSet) ([]entityTransition, *Response) {
+	if e.states == nil {
+		return nil, nil
+	}
+	var ts []entityTransition
+	for _, ref := range op.Transitions {
+		id, err := entityKey(c, ref.Entity, input)
+		if err != nil {
+			continue
+		}
+		state, version, err := e.states.GetState(ctx, ref.Entity, id)
+		switch {
+		case errors.Is(err, ErrNotFound):
+			state = c.Entities[ref.Entity].Initial
+		case err != nil:
+			return nil, stateUnavailable(ref.Entity, id, err)
+		}
+		if seen, ok := facts.Get(entityRefPrefix + ref.Entity + ".state"); ok && seen != state {
+			return nil, stateConflict(ref.Entity, id)
+		}
+		ts = append(ts, entityTransition{ref: ref, entity: ref.Entity, id: id, from: state, to: ref.To, version: version})
+	}
+	return ts, nil
+}
+
+// claimTransitions moves each instance to its new state if it is still at
+// the version read, updating ts with the new versions. If any has moved,
+// or was read in a state other than the one its transition declares it
+// from, those already claimed are undone and it answers state_conflict.
+func (e *Engine) claimTransitions(ctx context.Context, ts []entityTransition) *Response {
+	for i := range ts {
+		t := &ts[i]
+		if t.ref.From != "" && t.from != t.ref.From {
+			e.undoTransitions(ctx, ts[:i])
+			return invalidTransition(t.ref, t.id, t.from)
+		}
+		version, err := e.states.TransitionCAS(ctx, t.entity, t.id, t.version, t.to)
+		if err != nil {
+			e.undoTransitions(ctx, ts[:i])
+			if errors.Is(err, ErrStateConflict) {
+				return stateConflict(t.entity, t.id)
+			}
+			return stateUnavailable(t.entity, t.id, err)
+		}
+		t.version = version
+	}
+	return nil
+}
+
+// undoTransitions returns claimed instances to their earlier states, after
+// the execution they were claimed for failed. It is best effort: an
+// instance that moved again since is left alone.
+func (e *Engine) undoTransitions(ctx context.Context, ts []entityTransition) {
+	ctx = context.WithoutCancel(ctx)
+	for _, t := range ts {
+		e.states.TransitionCAS(ctx, t.entity, t.id, t.version, t.from)
+	}
+}
+
+// stateConflict is the response to a request whose entity instance another
+// request moved first.
+func stateConflict(entity, id string) *Response {
+	return &Response{
+		Outcome: OutcomeStateConflict,
+		Error: &ErrorEnvelope{
+			Code:       "STATE_CONFLICT",
+			Message:    fmt.Sprintf("%s %s changed state while the request was evaluated", entity, id),
+			HttpStatus: 409,
+			Category:   "system",
+			Retryable:  true,
+			Suggestion: "Retry to evaluate the request against the current state",
+			Details:    map[string]any{"entity": entity, "id": id},
+		},
+	}
+}
+
+// invalidTransition is the response to a request whose entity instance is
+// not in the state its transition starts from. Retrying does not help until
+// something else moves the instance.
+func invalidTransition(ref EntityTransitionRef, id, state string) *Response {
+	return &Response{
+		Outcome: OutcomeStateConflict,
+		Error: &ErrorEnvelope{
+			Code:       "INVALID_TRANSITION",
+			Message:    fmt.Sprintf("%s %s is %s, not %s, so it cannot move to %s", ref.Entity, id, state, ref.From, ref.To),
+			HttpStatus: 409,
+			Category:   "business_rule_violation",
+			Details:    map[string]any{"entity": ref.Entity, "id": id, "state": state, "from": ref.From, "to": ref.To},
+		},
+	}
+}
+
+func stateUnavailable(entity, id string, err error) *Response {
+	return &Response{
+		Outcome: OutcomeSystemError,
+		Error: &ErrorEnvelope{
+			Code:       "STATE_UNAVAILABLE",
+			Message:    fmt.Sprintf("state of %s %s unavailable: %v", entity, id, err),
+			HttpStatus: 503,
+			Category:   "system",
+			Retryable:  true,
+		},
+	}
+}
@@ -0,0 +1,165 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// paymentContract pays approved invoices, moving them to paid.
+func paymentContract() *Contract {
+	c := entityStateContract()
+	c.Entities["invoice"] = EntityDef{Key: "invoice.id", States: []string{"draft", "approved", "paid"}, Initial: "draft"}
+	c.Operations["testOp"] = OperationDef{
+		ConstrainedBy: []string{"approved"},
+		Transitions:   []EntityTransitionRef{{Entity: "invoice", From: "approved", To: "paid"}},
+	}
+	return c
+}
+
+// barrierStates holds the first n state reads until all n have arrived, so
+// that n requests evaluate their rules against the same state.
+type barrierStates struct {
+	*MemoryStateStore
+	reads   atomic.Int32
+	n       int32
+	arrived chan struct{}
+}
+
+func (b *barrierStates) GetState(ctx context.Context, entity, id string) (string, int64, error) {
+	state, version, err := b.MemoryStateStore.GetState(ctx, entity, id)
+	if r := b.reads.Add(1); r <= b.n {
+		if r == b.n {
+			close(b.arrived)
+		}
+		<-b.arrived
+	}
+	return state, version, err
+}
+
+func TestEngine_Evaluate_racingTransitionsHaveOneWinner(t *testing.T) {
+	states := &barrierStates{MemoryStateStore: NewMemoryStateStore(), n: 2, arrived: make(chan struct{})}
+	states.TransitionCAS(context.Background(), "invoice", "inv_1", 0, "approved")
+	var executions atomic.Int32
+	ports := &mockPorts{executeFunc: func(context.Context, string, string, map[string]any) (map[string]any, error) {
+		executions.Add(1)
+		return map[string]any{}, nil
+	}}
+	e := NewEngine(ports, WithStateStore(states))
+	e.LoadContract(paymentContract(), "v1")
+
+	var wg sync.WaitGroup
+	outcomes := make([]*Response, 2)
+	for i := range outcomes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := e.Evaluate(context.Background(), &Request{Operation: "testOp", Input: map[string]any{"invoice.id": "inv_1"}})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			outcomes[i] = resp
+		}()
+	}
+	wg.Wait()
+
+	byOutcome := map[Outcome]*Response{}
+	for _, resp := range outcomes {
+		byOutcome[resp.Outcome] = resp
+	}
+	conflict := byOutcome["state_conflict"]
+	if byOutcome["executed"] == nil || conflict == nil {
+		t.Fatalf("expected one executed and one state_conflict, got %s and %s", outcomes[0].Outcome, outcomes[1].Outcome)
+	}
+	if conflict.Error.Code != "STATE_CONFLICT" || !conflict.Error.Retryable || conflict.Error.HttpStatus != 409 {
+		t.Errorf("unexpected conflict envelope %+v", conflict.Error)
+	}
+	if executions.Load() != 1 {
+		t.Errorf("expected the loser not to execute, got %d executions", executions.Load())
+	}
+	if state, v, _ := states.GetState(context.Background(), "invoice", "inv_1"); state != "paid" || v != 2 {
+		t.Errorf("got %s@%d", state, v)
+	}
+
+	resp, _ := e.Evaluate(context.Background(), &Request{Operation: "testOp", Input: map[string]any{"invoice.id": "inv_1"}})
+	if resp.Outcome != "denied" {
+		t.Errorf("expected a retry to be denied on the new state, got %s", resp.Outcome)
+	}
+}
+
+func TestEngine_Evaluate_failedExecutionUndoesTransition(t *testing.T) {
+	states := NewMemoryStateStore()
+	states.TransitionCAS(context.Background(), "invoice", "inv_1", 0, "approved")
+	ports := &mockPorts{executeFunc: func(context.Context, string, string, map[string]any) (map[string]any, error) {
+		return nil, errors.New("processor unavailable")
+	}}
+	e := NewEngine(ports, WithStateStore(states))
+	e.LoadContract(paymentContract(), "v1")
+
+	resp, err := e.Evaluate(context.Background(), &Request{Operation: "testOp", Input: map[string]any{"invoice.id": "inv_1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error == nil || resp.Error.Code != "EXECUTION_FAILED" {
+		t.Fatalf("expected the execution to fail, got %+v", resp)
+	}
+	if state, _, _ := states.GetState(context.Background(), "invoice", "inv_1"); state != "approved" {
+		t.Errorf("expected the invoice back in approved, got %s", state)
+	}
+}
+
+func TestEngine_Evaluate_dryRunDoesNotTransition(t *testing.T) {
+	states := NewMemoryStateStore()
+	states.TransitionCAS(context.Background(), "invoice", "inv_1", 0, "approved")
+	e := NewEngine(&mockPorts{}, WithStateStore(states))
+	e.LoadContract(paymentContract(), "v1")
+
+	resp, _ := e.Evaluate(context.Background(), &Request{Operation: "testOp", Input: map[string]any{"invoice.id": "inv_1"}, DryRun: true})
+	if resp.Outcome != "would_execute" {
+		t.Fatalf("got %s", resp.Outcome)
+	}
+	if state, v, _ := states.GetState(context.Background(), "invoice", "inv_1"); state != "approved" || v != 1 {
+		t.Errorf("expected a dry-run to leave the invoice alone, got %s@%d", state, v)
+	}
+}
+
+func TestEngine_Evaluate_refusesTransitionFromAnotherState(t *testing.T) {
+	states := NewMemoryStateStore()
+	states.TransitionCAS(context.Background(), "invoice", "inv_1", 0, "approved")
+	var executions atomic.Int32
+	ports := &mockPorts{executeFunc: func(context.Context, string, string, map[string]any) (map[string]any, error) {
+		executions.Add(1)
+		return map[string]any{}, nil
+	}}
+	e := NewEngine(ports, WithStateStore(states))
+	// No rule reads the invoice's state, so only the transition's from
+	// keeps it from being paid twice.
+	c := paymentContract()
+	c.Operations["testOp"] = OperationDef{Transitions: c.Operations["testOp"].Transitions}
+	e.LoadContract(c, "v1")
+
+	pay := func() *Response {
+		t.Helper()
+		resp, err := e.Evaluate(context.Background(), &Request{Operation: "testOp", Input: map[string]any{"invoice.id": "inv_1"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	if resp := pay(); resp.Outcome != OutcomeExecuted {
+		t.Fatalf("expected the first payment executed, got %s %+v", resp.Outcome, resp.Error)
+	}
+	resp := pay()
+	if resp.Outcome != OutcomeStateConflict || resp.Error.Code != "INVALID_TRANSITION" || resp.Error.Retryable {
+		t.Errorf("expected the second payment refused as an invalid transition, got %s %+v", resp.Outcome, resp.Error)
+	}
+	if executions.Load() != 1 {
+		t.Errorf("expected one execution, got %d", executions.Load())
+	}
+	if state, v, _ := states.GetState(context.Background(), "invoice", "inv_1"); state != "paid" || v != 2 {
+		t.Errorf("expected the invoice paid once, got %s@%d", state, v)
+	}
+}
@@ -1,33 +1,97 @@
 package engine
 
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
 // Contract holds the parsed domain contract extracted from CUE sources.
 type Contract struct {
-	Facts        map[string]FactDef
-	DerivedFacts map[string]DerivedFactDef
-	Rules        []RuleDef
-	Operations   map[string]OperationDef
-	Entities     map[string]EntityDef
+	// Version is the contract's declared semantic version (e.g. "2.1.0").
+	// Empty for unversioned contracts, which clients cannot select by range.
+	Version string `json:"version,omitempty"`
+	// Imports names the base contracts this contract composes over; see
+	// composeContract for the merge semantics.
+	Imports []string `json:"imports,omitempty"`
+	// Params are tunable values (limits, queues, switches) that rules
+	// reference as {param: name} operands or read as params.<name> facts.
+	Params map[string]ParamDef `json:"params,omitempty"`
+	// Experiments evaluate shares of traffic with other param values.
+	Experiments map[string]ExperimentDef `json:"experiments,omitempty"`
+	// Environment and Bindings are set by Bind.
+	Environment  string                    `json:"environment,omitempty"`
+	Bindings     map[string]any            `json:"bindings,omitempty"`
+	Facts        map[string]FactDef        `json:"facts"`
+	DerivedFacts map[string]DerivedFactDef `json:"derived_facts"`
+	Rules        []RuleDef                 `json:"rules"`
+	Operations   map[string]OperationDef   `json:"operations"`
+	Entities     map[string]EntityDef      `json:"entities"`
+	// Queues is the escalation queue catalog, keyed by queue name. Once
+	// declared, escalate verdicts must name one of its queues.
+	Queues map[string]QueueDef `json:"queues,omitempty"`
+	// Lint tunes the lint rules Validate applies.
+	Lint *LintConfig `json:"lint,omitempty"`
+	// Personas are the identities that invoke the contract's operations,
+	// keyed by name.
+	Personas map[string]PersonaDef `json:"personas,omitempty"`
+	// DataUse maps restricted facts to the purposes they may be used for;
+	// see PurposeAllows.
+	DataUse map[string][]string `json:"data_use,omitempty"`
+	// Timezone is the IANA zone, such as "Europe/Paris", that timestamps
+	// without an offset and "today" are read in; UTC if empty.
+	Timezone string `json:"timezone,omitempty"`
+	// StringCompare is how equals and in compare strings unless a
+	// condition says otherwise; exactly if nil.
+	StringCompare *StringCompare `json:"string_compare,omitempty"`
+}
+
+// ParamDef declares a contract parameter. A nil Default means the param must
+// be bound for every environment.
+type ParamDef struct {
+	Default     any    `json:"default,omitempty"`
+	Description string `json:"description,omitempty"`
+	Override    bool   `json:"override,omitempty"`
 }
 
 type FactDef struct {
-	Source    string // "input", "ctx", "port:<name>"
-	Required  bool
-	OnMissing string // "system_error" (default), "deny", "skip"
+	Source    string `json:"source"` // "input", "ctx", "port:<name>"
+	Required  bool   `json:"required"`
+	OnMissing string `json:"on_missing"`         // "system_error" (default), "deny", "skip"
+	Override  bool   `json:"override,omitempty"` // replaces an imported definition
+	// MaxStaleness is the oldest a port fact's value may be, as a Go
+	// duration; OnStale says what happens when it is older. See Timestamped.
+	MaxStaleness string `json:"max_staleness,omitempty"`
+	OnStale      string `json:"on_stale,omitempty"` // "refresh" (default), "deny", "flag"
+	// Unit is the unit of a numeric fact's values, such as "h", "%" or
+	// "MiB"; comparisons convert it and their thresholds' units alike.
+	Unit string `json:"unit,omitempty"`
+	// Type is FactTypeTimestamp for facts holding instants, which requests
+	// must send as timestamps.
+	Type string `json:"type,omitempty"`
 }
 
 type DerivedFactDef struct {
-	Derivation Derivation
+	Derivation Derivation `json:"derivation"`
+	Override   bool       `json:"override,omitempty"`
+	// Cache shares computed values across requests that agree on the Key
+	// input facts; see Engine.cacheDerivedFacts.
+	Cache *CacheDef `json:"cache,omitempty"`
 }
 
 type Derivation struct {
 	Fn   string          `json:"fn"`
 	Args []DerivationArg `json:"args"`
+	// Bands label the ranges of a score; see ScoreBand.
+	Bands []ScoreBand `json:"bands,omitempty"`
 }
 
 type DerivationArg struct {
 	Fact  string `json:"fact,omitempty"`
 	Op    string `json:"op,omitempty"`
 	Value any    `json:"value,omitempty"`
+	// Weight is the term's weight in weighted_sum and score.
+	Weight *float64 `json:"weight,omitempty"`
 }
 
 type RuleDef struct {
@@ -35,6 +99,28 @@ type RuleDef struct {
 	AppliesTo []string   `json:"applies_to"`
 	When      Condition  `json:"when"`
 	Verdict   VerdictDef `json:"verdict"`
+	Override  bool       `json:"override,omitempty"` // replaces an imported rule
+	Final     bool       `json:"final,omitempty"`    // importers may not override
+
+	// EffectiveFrom and ExpiresAt bound when the rule is in effect
+	// (RFC 3339). Outside the window the rule is skipped.
+	EffectiveFrom *time.Time `json:"effective_from,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+
+	// Rollout applies the rule to a share of requests only.
+	Rollout *RolloutDef `json:"rollout,omitempty"`
+
+	// LintIgnore suppresses the named lint rules for this rule.
+	LintIgnore []string `json:"lint_ignore,omitempty"`
+}
+
+// ActiveAt reports whether the rule is in effect at t: on or after
+// EffectiveFrom and before ExpiresAt.
+func (r RuleDef) ActiveAt(t time.Time) bool {
+	if r.EffectiveFrom != nil && t.Before(*r.EffectiveFrom) {
+		return false
+	}
+	return r.ExpiresAt == nil || t.Before(*r.ExpiresAt)
 }
 
 type Condition struct {
@@ -46,6 +132,30 @@ type Condition struct {
 	GreaterThan any         `json:"greater_than,omitempty"`
 	LessThan    any         `json:"less_than,omitempty"`
 	In          []any       `json:"in,omitempty"`
+	// Compare modifies how Equals and In compare strings, over the
+	// contract's StringCompare.
+	Compare *StringCompare `json:"compare,omitempty"`
+	// Contains holds when the fact is a list with an element equal to the
+	// operand, or a string with the operand as a substring.
+	Contains any `json:"contains,omitempty"`
+	// Before, After and Between compare a timestamp fact with instants;
+	// see timeOperand for the forms they take.
+	Before  any   `json:"before,omitempty"`
+	After   any   `json:"after,omitempty"`
+	Between []any `json:"between,omitempty"` // [from, to)
+	// Set operators compare a list fact with a list of values: SubsetOf
+	// holds when every element of the fact is among them, Intersects when
+	// any is, and ContainsAll when the fact has every one of them.
+	SubsetOf    []any `json:"subset_of,omitempty"`
+	Intersects  []any `json:"intersects,omitempty"`
+	ContainsAll []any `json:"contains_all,omitempty"`
+
+	// Identity operators read the caller's identity ctx facts, with no
+	// fact of their own; see FactRoles.
+	HasRole        any `json:"has_role,omitempty"`
+	HasScope       any `json:"has_scope,omitempty"`
+	SubjectMatches any `json:"subject_matches,omitempty"` // path.Match pattern
+	TenantEquals   any `json:"tenant_equals,omitempty"`
 }
 
 type VerdictDef struct {
@@ -53,6 +163,9 @@ type VerdictDef struct {
 	Escalate *EscalateVerdict `json:"escalate,omitempty"`
 	Require  *RequireVerdict  `json:"require,omitempty"`
 	Flag     *FlagVerdict     `json:"flag,omitempty"`
+	// Metadata is passed through to the verdict for downstream tooling,
+	// e.g. {owner: "billing", doc_url: "...", risk_tier: "high"}.
+	Metadata map[string]any `json:"metadata,omitempty"`
 }
 
 type DenyVerdict struct {
@@ -64,6 +177,33 @@ type DenyVerdict struct {
 type EscalateVerdict struct {
 	Queue  string `json:"queue"`
 	Reason string `json:"reason"`
+	// QueueParam names the param holding the queue when the contract gives
+	// queue as {param: name}.
+	QueueParam string `json:"queue_param,omitempty"`
+}
+
+func (v *EscalateVerdict) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Queue      any    `json:"queue"`
+		Reason     string `json:"reason"`
+		QueueParam string `json:"queue_param"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*v = EscalateVerdict{Reason: raw.Reason, QueueParam: raw.QueueParam}
+	switch q := raw.Queue.(type) {
+	case nil:
+	case string:
+		v.Queue = q
+	default:
+		name, ok := paramRef(q)
+		if !ok {
+			return fmt.Errorf("escalate queue must be a string or {param: name}")
+		}
+		v.QueueParam = name
+	}
+	return nil
 }
 
 type RequireVerdict struct {
@@ -83,11 +223,30 @@ type ErrorEnvelope struct {
 	Category   string `json:"category"`
 	Retryable  bool   `json:"retryable"`
 	Suggestion string `json:"suggestion,omitempty"`
+
+	// Details carries machine-readable context for the error, such as the
+	// available versions on a failed negotiation.
+	Details map[string]any `json:"details,omitempty"`
 }
 
 type OperationDef struct {
 	ConstrainedBy []string              `json:"constrained_by"`
 	Transitions   []EntityTransitionRef `json:"transitions"`
+	// Cache marks a pure read whose executed responses may be reused.
+	Cache *CacheDef `json:"cache,omitempty"`
+	// Concurrency bounds simultaneous executions of the operation.
+	Concurrency *ConcurrencyDef `json:"concurrency,omitempty"`
+	// Authorize gates the operation on its caller's ctx facts.
+	Authorize *AuthorizeDef `json:"authorize,omitempty"`
+	// Undo declares how an execution can be compensated.
+	Undo *UndoDef `json:"undo,omitempty"`
+	// Requires lists earlier executions the operation must follow.
+	Requires []PrerequisiteDef `json:"requires,omitempty"`
+	// Duplicates flags or denies likely resubmissions of an execution.
+	Duplicates *DuplicateDef `json:"duplicates,omitempty"`
+	// Currencies denies money facts in currencies the operation does not
+	// accept.
+	Currencies *CurrencyDef `json:"currencies,omitempty"`
 }
 
 type EntityTransitionRef struct {
@@ -97,6 +256,9 @@ type EntityTransitionRef struct {
 }
 
 type EntityDef struct {
+	// Key is the input fact identifying an instance, such as invoice.id,
+	// by which entity:<entity>.state is looked up.
+	Key         string       `json:"key,omitempty"`
 	States      []string     `json:"states"`
 	Initial     string       `json:"initial"`
 	Terminal    []string     `json:"terminal"`
@@ -115,23 +277,117 @@ type Request struct {
 	Input        map[string]any `json:"input"`
 	DryRun       bool           `json:"dry_run"`
 	ContractETag string         `json:"contract_etag,omitempty"`
+	Explain      bool           `json:"explain,omitempty"`
+
+	// VersionRange selects among loaded contract versions, e.g. "^2.0" or
+	// "~2.1.3". Empty means the active contract.
+	VersionRange string `json:"version_range,omitempty"`
+
+	// IdempotencyKey makes a live request safe to retry; see Engine.Evaluate.
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
+
+	// Priority is "interactive" (the default) or "batch"; see Scheduler.
+	Priority string `json:"priority,omitempty"`
+
+	// Partial lets a dry-run answer from the facts gathered by its
+	// deadline — the context's, or TimeoutMS after it starts — instead of
+	// waiting for slow ports. Facts not gathered in time are treated as
+	// absent and listed in the response's SkippedFacts.
+	Partial   bool `json:"partial,omitempty"`
+	TimeoutMS int  `json:"timeout_ms,omitempty"`
+
+	// Context carries the caller's ctx facts, such as "user.roles", as
+	// established by whatever authenticated it.
+	Context map[string]any `json:"context,omitempty"`
+
+	// Purpose is what the caller will use the decision for, e.g. "billing",
+	// checked against the contract's data-use policy.
+	Purpose string `json:"purpose,omitempty"`
+
+	// ProtocolVersion is the wire protocol version the client speaks; see
+	// ProtocolVersion. Zero means version 1.
+	ProtocolVersion int `json:"protocol_version,omitempty"`
+
+	// SessionID makes the request part of an open session; see
+	// Engine.OpenSession.
+	SessionID string `json:"session_id,omitempty"`
+}
+
+// SimulateRequest is the payload sent to POST /simulate. Facts is the
+// complete base fact set; no port is consulted for facts, only the rates
+// port for conversions.
+type SimulateRequest struct {
+	Operation    string         `json:"operation"`
+	Facts        map[string]any `json:"facts"`
+	ContractETag string         `json:"contract_etag,omitempty"`
+	Explain      bool           `json:"explain,omitempty"`
+	VersionRange string         `json:"version_range,omitempty"`
+	At           *time.Time     `json:"at,omitempty"`       // evaluate as of this time (default now)
+	Priority     string         `json:"priority,omitempty"` // default "batch"
+	// ProtocolVersion is as in Request.
+	ProtocolVersion int `json:"protocol_version,omitempty"`
 }
 
 // Response is returned from POST /execute.
 type Response struct {
-	Outcome      string         `json:"outcome"`
+	InvocationID string         `json:"invocation_id,omitempty"`
+	Outcome      Outcome        `json:"outcome"`
 	Output       map[string]any `json:"output,omitempty"`
 	Error        *ErrorEnvelope `json:"error,omitempty"`
 	Verdicts     []Verdict      `json:"verdicts,omitempty"`
 	FactSnapshot map[string]any `json:"fact_snapshot,omitempty"`
 	DryRun       bool           `json:"dry_run,omitempty"`
+	Simulated    bool           `json:"simulated,omitempty"`
+
+	// ContractSemver is the semantic version of the contract the request was
+	// evaluated against, when that contract declares one.
+	ContractSemver string `json:"contract_semver,omitempty"`
+
+	// SideEffectsIsolated reports that the evaluation ran against a
+	// read-only view of the ports (always true for dry-runs).
+	SideEffectsIsolated bool `json:"side_effects_isolated,omitempty"`
+	// ForcedDryRun marks a live request evaluated as a dry run because a
+	// kill switch holds its operation in dry_run mode.
+	ForcedDryRun bool `json:"forced_dry_run,omitempty"`
+
+	// Explain is the per-rule evaluation trace, present when requested.
+	Explain *Explanation `json:"explain,omitempty"`
+
+	// EscalationID identifies the queued escalation of an escalated request
+	// when the engine has an EscalationStore.
+	EscalationID string `json:"escalation_id,omitempty"`
+
+	// Undo tells the caller of an executed operation that declares undo
+	// how it can be compensated.
+	Undo *UndoInfo `json:"undo,omitempty"`
+
+	// IdempotentReplay marks a response replayed for a repeated
+	// idempotency key rather than newly evaluated.
+	IdempotentReplay bool `json:"idempotent_replay,omitempty"`
+
+	// Cache is set on responses of cacheable operations; see CacheDef.
+	Cache *CacheInfo `json:"cache,omitempty"`
+	// Partial marks a best-effort dry-run evaluated without the port facts
+	// in SkippedFacts; see Request.Partial.
+	Partial      bool     `json:"partial,omitempty"`
+	SkippedFacts []string `json:"skipped_facts,omitempty"`
+
+	// ProtocolVersion is the wire protocol version of a response sent over
+	// HTTP, from version 2; see ForProtocol.
+	ProtocolVersion int `json:"protocol_version,omitempty"`
 }
 
 // Verdict is a resolved verdict from rule evaluation.
 type Verdict struct {
-	Type   string         `json:"type"` // deny, escalate, require, flag
+	Rule   string         `json:"rule,omitempty"` // ID of the rule that produced it
+	Type   string         `json:"type"`           // deny, escalate, require, flag
 	Code   string         `json:"code,omitempty"`
 	Reason string         `json:"reason,omitempty"`
 	Error  *ErrorEnvelope `json:"error,omitempty"`
 	Queue  string         `json:"queue,omitempty"`
+	// Shadow marks the verdict of a rule outside its rollout, which has no
+	// effect; see RolloutDef.
+	Shadow bool `json:"shadow,omitempty"`
+	// Metadata is the rule's verdict metadata, as the contract declares it.
+	Metadata map[string]any `json:"metadata,omitempty"`
 }
@@ -0,0 +1,126 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// UndoDef declares how an executed operation can be compensated, e.g.
+//
+//	undo: {
+//		operation: "RefundPayment"
+//		window:    "720h"
+//		input: {"payment.id": "output.payment_id", "invoice.id": "input.invoice.id"}
+//	}
+//
+// Input maps the undo operation's input facts to a field of the executed
+// operation's output ("output.<field>") or one of its input facts
+// ("input.<fact>"). An operation that cannot be compensated declares
+// irreversible: true instead.
+type UndoDef struct {
+	Operation    string            `json:"operation,omitempty"`
+	Window       string            `json:"window,omitempty"` // Go duration; empty means no limit
+	Input        map[string]string `json:"input,omitempty"`
+	Irreversible bool              `json:"irreversible,omitempty"`
+}
+
+// UndoInfo tells the caller of an executed operation whether and how it can
+// be compensated: by invoking Operation with Input before Deadline, if
+// there is one.
+type UndoInfo struct {
+	Irreversible bool           `json:"irreversible,omitempty"`
+	Operation    string         `json:"operation,omitempty"`
+	Input        map[string]any `json:"input,omitempty"`
+	Deadline     *time.Time     `json:"deadline,omitempty"`
+}
+
+// window returns the parsed window, or 0 if it is missing or malformed.
+func (d UndoDef) window() time.Duration {
+	w, err := time.ParseDuration(d.Window)
+	if err != nil || w < 0 {
+		return 0
+	}
+	return w
+}
+
+// undoInfo returns the undo information of an operation executed at with
+// input and output, or nil if the operation declares none. Undo input the
+// execution did not supply is left out.
+func undoInfo(op OperationDef, input, output map[string]any, at time.Time) *UndoInfo {
+	if op.Undo == nil {
+		return nil
+	}
+	if op.Undo.Irreversible {
+		return &UndoInfo{Irreversible: true}
+	}
+	info := &UndoInfo{Operation: op.Undo.Operation}
+	if w := op.Undo.window(); w > 0 {
+		deadline := at.Add(w).UTC()
+		info.Deadline = &deadline
+	}
+	for fact, from := range op.Undo.Input {
+		var val any
+		var ok bool
+		if name, isInput := strings.CutPrefix(from, "input."); isInput {
+			val, ok = input[name]
+		} else if path, isOutput := strings.CutPrefix(from, "output."); isOutput {
+			val, ok = getPath(output, path)
+		}
+		if ok {
+			if info.Input == nil {
+				info.Input = map[string]any{}
+			}
+			info.Input[fact] = val
+		}
+	}
+	return info
+}
+
+// validateUndo checks an operation's undo declaration: it names a declared
+// operation, or is irreversible, and maps input facts from the input or
+// output.
+func validateUndo(c *Contract, name string, op OperationDef) []Diagnostic {
+	if op.Undo == nil {
+		return nil
+	}
+	u := op.Undo
+	report := func(format string, args ...any) Diagnostic {
+		return Diagnostic{Severity: SeverityError, Message: fmt.Sprintf("operation %s: undo ", name) + fmt.Sprintf(format, args...)}
+	}
+	if u.Irreversible {
+		if u.Operation != "" || u.Window != "" || len(u.Input) > 0 {
+			return []Diagnostic{report("is irreversible but also names how to undo it")}
+		}
+		return nil
+	}
+	var diags []Diagnostic
+	if _, ok := c.Operations[u.Operation]; !ok {
+		diags = append(diags, report("operation %q is not declared", u.Operation))
+	}
+	if u.Window != "" {
+		if w, err := time.ParseDuration(u.Window); err != nil || w <= 0 {
+			diags = append(diags, report("window %q is not a positive duration", u.Window))
+		}
+	}
+	facts := make([]string, 0, len(u.Input))
+	for fact := range u.Input {
+		facts = append(facts, fact)
+	}
+	sort.Strings(facts)
+	for _, fact := range facts {
+		if def, ok := c.Facts[fact]; !ok || def.Source != "input" {
+			diags = append(diags, report("input %q is not an input fact", fact))
+		}
+		from := u.Input[fact]
+		if name, ok := strings.CutPrefix(from, "input."); ok {
+			if def, ok := c.Facts[name]; !ok || def.Source != "input" {
+				diags = append(diags, report("input %q is taken from %q, which is not an input fact", fact, from))
+			}
+		} else if path, ok := strings.CutPrefix(from, "output."); !ok || path == "" {
+			diags = append(diags, report("input %q is taken from %q; expected input.<fact> or output.<field>", fact, from))
+		}
+	}
+	return diags
+}
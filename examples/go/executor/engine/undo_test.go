@@ -0,0 +1,79 @@
+package engine
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func undoContract() *Contract {
+	c := makeMinimalContract()
+	c.Facts["invoice.id"] = FactDef{Source: "input", Required: true}
+	c.Facts["payment.id"] = FactDef{Source: "input"}
+	c.Operations["pay"] = OperationDef{Undo: &UndoDef{
+		Operation: "refund",
+		Window:    "24h",
+		Input:     map[string]string{"payment.id": "output.payment.id", "invoice.id": "input.invoice.id"},
+	}}
+	c.Operations["refund"] = OperationDef{Undo: &UndoDef{Irreversible: true}}
+	return c
+}
+
+func TestEngine_Evaluate_reportsUndo(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	ports := &mockPorts{executeFunc: func(context.Context, string, string, map[string]any) (map[string]any, error) {
+		return map[string]any{"payment": map[string]any{"id": "pay_1"}}, nil
+	}}
+	e := NewEngine(ports, WithClock(func() time.Time { return now }))
+	e.LoadContract(undoContract(), "v1")
+	ctx := context.Background()
+
+	resp, err := e.Evaluate(ctx, &Request{Operation: "pay", Input: map[string]any{"invoice.id": "inv_1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := now.Add(24 * time.Hour)
+	want := &UndoInfo{Operation: "refund", Input: map[string]any{"payment.id": "pay_1", "invoice.id": "inv_1"}, Deadline: &deadline}
+	if !reflect.DeepEqual(resp.Undo, want) {
+		t.Errorf("got undo %+v", resp.Undo)
+	}
+
+	resp, _ = e.Evaluate(ctx, &Request{Operation: "refund", Input: map[string]any{"invoice.id": "inv_1"}})
+	if resp.Undo == nil || !resp.Undo.Irreversible {
+		t.Errorf("expected refund to be irreversible, got %+v", resp.Undo)
+	}
+	resp, _ = e.Evaluate(ctx, &Request{Operation: "pay", Input: map[string]any{"invoice.id": "inv_1"}, DryRun: true})
+	if resp.Undo != nil {
+		t.Errorf("expected no undo for a dry-run, got %+v", resp.Undo)
+	}
+}
+
+func TestValidate_undo(t *testing.T) {
+	c := undoContract()
+	c.Operations["void"] = OperationDef{Undo: &UndoDef{
+		Operation: "unvoid",
+		Window:    "soon",
+		Input:     map[string]string{"invoice.id": "invoice.id", "reason": "input.reason"},
+	}}
+	c.Operations["close"] = OperationDef{Undo: &UndoDef{Irreversible: true, Operation: "reopen"}}
+
+	var got []string
+	for _, d := range Validate(c, time.Now()) {
+		if strings.Contains(d.Message, "undo") {
+			got = append(got, d.Message)
+		}
+	}
+	want := []string{
+		"operation close: undo is irreversible but also names how to undo it",
+		`operation void: undo operation "unvoid" is not declared`,
+		`operation void: undo window "soon" is not a positive duration`,
+		`operation void: undo input "invoice.id" is taken from "invoice.id"; expected input.<fact> or output.<field>`,
+		`operation void: undo input "reason" is not an input fact`,
+		`operation void: undo input "reason" is taken from "input.reason", which is not an input fact`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got diagnostics\n%s", strings.Join(got, "\n"))
+	}
+}
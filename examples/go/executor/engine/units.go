@@ -0,0 +1,134 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// unitDef places a unit in its dimension: a quantity in the unit is scale
+// times as many of the dimension's base unit.
+type unitDef struct {
+	dim   string
+	scale float64
+}
+
+// units are the units facts and thresholds may be given in. Durations are
+// compared in seconds, percentages in percentage points and sizes in bytes.
+var units = map[string]unitDef{
+	"ms": {"duration", 0.001},
+	"s":  {"duration", 1},
+	"m":  {"duration", 60},
+	"h":  {"duration", 3600},
+	"d":  {"duration", 86400},
+	"w":  {"duration", 7 * 86400},
+
+	"%":  {"percent", 1},
+	"bp": {"percent", 0.01}, // basis points
+
+	"B":   {"bytes", 1},
+	"KB":  {"bytes", 1e3},
+	"MB":  {"bytes", 1e6},
+	"GB":  {"bytes", 1e9},
+	"TB":  {"bytes", 1e12},
+	"KiB": {"bytes", 1 << 10},
+	"MiB": {"bytes", 1 << 20},
+	"GiB": {"bytes", 1 << 30},
+	"TiB": {"bytes", 1 << 40},
+}
+
+// quantity is a number in the base unit of its dimension, or a plain number
+// with no dimension.
+type quantity struct {
+	value float64
+	dim   string
+}
+
+// toQuantity reads v as a quantity: a string of a number and a unit, such
+// as "30d" or "2.5GiB", or a number in unit, which may be empty for a plain
+// number.
+func toQuantity(v any, unit string) (quantity, bool) {
+	if s, ok := v.(string); ok {
+		return parseQuantity(s)
+	}
+	n, ok := toFloat(v)
+	if !ok {
+		return quantity{}, false
+	}
+	if u, ok := units[unit]; ok {
+		return quantity{n * u.scale, u.dim}, true
+	}
+	return quantity{value: n}, true
+}
+
+// parseQuantity parses a number followed by a unit, such as "36h".
+func parseQuantity(s string) (quantity, bool) {
+	s = strings.TrimSpace(s)
+	i := strings.LastIndexAny(s, "0123456789.") + 1
+	u, ok := units[strings.TrimSpace(s[i:])]
+	if !ok || i == 0 {
+		return quantity{}, false
+	}
+	n, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil {
+		return quantity{}, false
+	}
+	return quantity{n * u.scale, u.dim}, true
+}
+
+// compareQuantities applies greater_than, greater_or_equal or less_than to
+// two values read as quantities, a plain number in the unit given for its
+// side. Quantities of different dimensions never compare.
+func compareQuantities(op string, left any, leftUnit string, right any, rightUnit string) bool {
+	l, okl := toQuantity(left, leftUnit)
+	r, okr := toQuantity(right, rightUnit)
+	if !okl || !okr || l.dim != r.dim {
+		return false
+	}
+	switch op {
+	case "greater_than":
+		return l.value > r.value
+	case "greater_or_equal":
+		return l.value >= r.value
+	case "less_than":
+		return l.value < r.value
+	}
+	return false
+}
+
+// isQuantity reports whether v is a number or a quantity with a unit.
+func isQuantity(v any) bool {
+	_, ok := toQuantity(v, "")
+	return ok
+}
+
+// checkUnits reports a threshold whose dimension differs from that of the
+// fact it is compared with, which can never hold.
+func checkUnits(c *Contract, fact string, threshold any) string {
+	q, ok := toQuantity(threshold, "")
+	if !ok || q.dim == "" {
+		return ""
+	}
+	def, declared := c.Facts[fact]
+	if !declared {
+		return ""
+	}
+	if def.Unit == "" {
+		return fmt.Sprintf("threshold %v has a unit but %s declares none", threshold, fact)
+	}
+	if u := units[def.Unit]; u.dim != q.dim {
+		return fmt.Sprintf("threshold %v is not a %s like %s's unit %s", threshold, u.dim, fact, def.Unit)
+	}
+	return ""
+}
+
+// validateUnit checks a fact's unit.
+func validateUnit(name string, def FactDef) []Diagnostic {
+	if _, ok := units[def.Unit]; def.Unit == "" || ok {
+		return nil
+	}
+	return []Diagnostic{{
+		Severity: SeverityError,
+		Message:  fmt.Sprintf("fact %s: unknown unit %q", name, def.Unit),
+	}}
+}
@@ -0,0 +1,167 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseQuantity(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want quantity
+		ok   bool
+	}{
+		{"30d", quantity{30 * 86400, "duration"}, true},
+		{"1.5h", quantity{5400, "duration"}, true},
+		{"250ms", quantity{0.25, "duration"}, true},
+		{"95%", quantity{95, "percent"}, true},
+		{"50bp", quantity{0.5, "percent"}, true},
+		{"2 MiB", quantity{2 << 20, "bytes"}, true},
+		{"-3s", quantity{-3, "duration"}, true},
+		{"42", quantity{}, false},
+		{"h", quantity{}, false},
+		{"30 days", quantity{}, false},
+	} {
+		got, ok := parseQuantity(tc.in)
+		if ok != tc.ok || got != tc.want {
+			t.Errorf("parseQuantity(%q): expected %v %v, got %v %v", tc.in, tc.want, tc.ok, got, ok)
+		}
+	}
+}
+
+func TestEvalCondition_comparesQuantitiesInTheirUnits(t *testing.T) {
+	c := makeMinimalContract()
+	c.Facts = map[string]FactDef{
+		"invoice.age_hours": {Source: "input", Unit: "h"},
+		"upload.size":       {Source: "input", Unit: "KiB"},
+		"plain":             {Source: "input"},
+	}
+	fs := NewFactSet()
+	fs.setUnits(c)
+	fs.Set("invoice.age_hours", 800.0) // 33 days and 8 hours
+	fs.Set("upload.size", 2048)
+	fs.Set("plain", 800.0)
+	fs.Set("latency", "1.5s")
+
+	for _, tc := range []struct {
+		cond Condition
+		want bool
+	}{
+		{Condition{Fact: "invoice.age_hours", GreaterThan: "30d"}, true},
+		{Condition{Fact: "invoice.age_hours", GreaterThan: "34d"}, false},
+		{Condition{Fact: "invoice.age_hours", LessThan: "5w"}, true},
+		// A plain threshold is in the fact's unit.
+		{Condition{Fact: "invoice.age_hours", GreaterThan: 799.0}, true},
+		{Condition{Fact: "upload.size", GreaterThan: "1MiB"}, true},
+		{Condition{Fact: "upload.size", LessThan: "2MB"}, false},
+		// A value given with its unit needs no declaration.
+		{Condition{Fact: "latency", GreaterThan: "1200ms"}, true},
+		// Different dimensions, or a unit against a plain number, never compare.
+		{Condition{Fact: "upload.size", GreaterThan: "1h"}, false},
+		{Condition{Fact: "plain", GreaterThan: "1d"}, false},
+		{Condition{Fact: "latency", GreaterThan: 1.0}, false},
+	} {
+		if got := evalCondition(tc.cond, fs); got != tc.want {
+			t.Errorf("%+v: expected %v, got %v", tc.cond, tc.want, got)
+		}
+	}
+}
+
+func TestEvalDerivation_comparesQuantitiesAcrossUnits(t *testing.T) {
+	c := makeMinimalContract()
+	c.Facts = map[string]FactDef{
+		"job.runtime_min": {Source: "input", Unit: "m"},
+		"job.budget_h":    {Source: "input", Unit: "h"},
+	}
+	fs := NewFactSet()
+	fs.setUnits(c)
+	fs.Set("job.runtime_min", 90.0)
+	fs.Set("job.budget_h", 1.0)
+
+	over, err := evalDerivation(Derivation{Fn: "greater_than", Args: []DerivationArg{{Fact: "job.runtime_min"}, {Fact: "job.budget_h"}}}, fs)
+	if err != nil || over != true {
+		t.Errorf("expected 90m over a 1h budget, got %v, %v", over, err)
+	}
+	under, err := evalDerivation(Derivation{Fn: "less_than", Args: []DerivationArg{{Fact: "job.runtime_min"}, {Value: "2h"}}}, fs)
+	if err != nil || under != true {
+		t.Errorf("expected 90m under 2h, got %v, %v", under, err)
+	}
+}
+
+func TestEngine_SimulateBatch_comparesQuantitiesRowByRow(t *testing.T) {
+	c := makeMinimalContract()
+	c.Facts = map[string]FactDef{"invoice.age_hours": {Source: "input", Unit: "h"}}
+	c.Rules = []RuleDef{{
+		ID:        "overdue",
+		AppliesTo: []string{"testOp"},
+		When:      Condition{Fact: "invoice.age_hours", GreaterThan: "30d"},
+		Verdict:   VerdictDef{Flag: &FlagVerdict{Code: "OVERDUE", Reason: "overdue"}},
+	}}
+	c.Operations["testOp"] = OperationDef{ConstrainedBy: []string{"overdue"}}
+	e := NewEngine(&mockPorts{})
+	e.LoadContract(c, "v1")
+	resp, err := e.SimulateBatch(&BatchSimulateRequest{Operation: "testOp", Rows: []map[string]any{
+		{"invoice.age_hours": 24.0},
+		{"invoice.age_hours": 1000.0},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Vectorized) != 0 || resp.RuleMatches["overdue"] != 1 || len(resp.Rows[1].Rules) != 1 {
+		t.Errorf("expected only the second row overdue, evaluated row by row, got %+v", resp)
+	}
+}
+
+func TestValidate_reportsUnknownUnits(t *testing.T) {
+	c := makeMinimalContract()
+	c.Facts = map[string]FactDef{"invoice.age": {Source: "input", Unit: "days"}}
+	var got []string
+	for _, d := range Validate(c, time.Now()) {
+		if strings.Contains(d.Message, "unit") {
+			got = append(got, d.Message)
+		}
+	}
+	if len(got) != 1 {
+		t.Errorf("expected an unknown unit diagnostic, got %q", got)
+	}
+}
+
+func TestEngine_Warmup_reportsMismatchedUnits(t *testing.T) {
+	c := makeMinimalContract()
+	c.Facts = map[string]FactDef{
+		"invoice.age_hours": {Source: "input", Unit: "h"},
+		"plain":             {Source: "input"},
+	}
+	c.Rules = []RuleDef{
+		{ID: "size", AppliesTo: []string{"testOp"}, When: Condition{Fact: "invoice.age_hours", GreaterThan: "10MB"}, Verdict: VerdictDef{Flag: &FlagVerdict{Code: "A"}}},
+		{ID: "undeclared", AppliesTo: []string{"testOp"}, When: Condition{Fact: "plain", GreaterThan: "1d"}, Verdict: VerdictDef{Flag: &FlagVerdict{Code: "B"}}},
+		{ID: "ok", AppliesTo: []string{"testOp"}, When: Condition{Fact: "invoice.age_hours", GreaterThan: "30d"}, Verdict: VerdictDef{Flag: &FlagVerdict{Code: "C"}}},
+	}
+	diags := NewEngine(&mockPorts{}).Warmup(c, time.Now())
+	if len(diags) != 2 || diags[0].Rule != "size" || diags[1].Rule != "undeclared" {
+		t.Errorf("expected the size and undeclared rules reported, got %v", diags)
+	}
+}
+
+func TestCompileSources_factUnits(t *testing.T) {
+	c, err := CompileSources(map[string][]byte{"contract.cue": []byte(`
+facts: "invoice.age_hours": {source: "input", unit: "h"}
+rules: [{
+	id: "overdue"
+	applies_to: ["Pay"]
+	when: {fact: "invoice.age_hours", greater_than: "30d"}
+	verdict: flag: {code: "OVERDUE", reason: "Invoice is over 30 days old"}
+}]
+operations: Pay: {constrained_by: ["overdue"], transitions: []}
+`)}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Facts["invoice.age_hours"].Unit; got != "h" {
+		t.Errorf("unit not extracted: %q", got)
+	}
+	if diags := NewEngine(&mockPorts{}).Warmup(c, time.Now()); len(diags) != 0 {
+		t.Errorf("unexpected warm-up diagnostics %v", diags)
+	}
+}
@@ -0,0 +1,142 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	cueerrors "cuelang.org/go/cue/errors"
+)
+
+// Diagnostic severities.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Diagnostic is one finding from Validate, or one error from compiling a
+// contract. File, Line and Column locate it in the source when known.
+type Diagnostic struct {
+	Severity string `json:"severity"`
+	Rule     string `json:"rule,omitempty"`
+	Lint     string `json:"lint,omitempty"` // lint rule that reported it
+	Message  string `json:"message"`
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+}
+
+func (d Diagnostic) String() string {
+	msg := fmt.Sprintf("%s: %s", d.Severity, d.Message)
+	if d.Rule != "" {
+		msg = fmt.Sprintf("%s: rule %s: %s", d.Severity, d.Rule, d.Message)
+	}
+	if d.Lint != "" {
+		msg += " [" + d.Lint + "]"
+	}
+	if d.File != "" {
+		return fmt.Sprintf("%s:%d:%d: %s", d.File, d.Line, d.Column, msg)
+	}
+	return msg
+}
+
+// ErrorDiagnostics returns the diagnostics of a contract that failed to
+// compile: the Errors of the ContractError in err's chain, or else a single
+// diagnostic with err's message.
+func ErrorDiagnostics(err error) []Diagnostic {
+	if err == nil {
+		return nil
+	}
+	var ce *ContractError
+	if errors.As(err, &ce) {
+		return slices.Clone(ce.Errors)
+	}
+	return []Diagnostic{{Severity: SeverityError, Message: err.Error()}}
+}
+
+// cueDiagnostics makes one diagnostic per CUE error in err, each at its
+// source position. An error that is not from CUE becomes a single
+// diagnostic without one.
+func cueDiagnostics(err error) []Diagnostic {
+	var diags []Diagnostic
+	errs := cueerrors.Errors(err)
+	for _, e := range errs {
+		d := Diagnostic{Severity: SeverityError, Message: e.Error()}
+		if pos := cueerrors.Positions(e); len(pos) > 0 {
+			d.File, d.Line, d.Column = pos[0].Filename(), pos[0].Line(), pos[0].Column()
+		} else if len(errs) == 1 {
+			d.Message = err.Error() // keep what it was wrapped with
+		}
+		diags = append(diags, d)
+	}
+	return diags
+}
+
+// Validate checks a compiled contract for problems that compile cleanly but
+// are almost certainly mistakes, as of the given time. It never modifies c.
+func Validate(c *Contract, now time.Time) []Diagnostic {
+	var diags []Diagnostic
+	diags = append(diags, validateExperiments(c)...)
+	for _, r := range c.Rules {
+		diags = append(diags, validateVerdict(r)...)
+		diags = append(diags, validateWindow(r, now)...)
+		diags = append(diags, validateRollout(c, r)...)
+	}
+	for _, name := range sortedOperations(c) {
+		diags = append(diags, validateCache(c, name, c.Operations[name])...)
+		diags = append(diags, validateConcurrency(name, c.Operations[name])...)
+		diags = append(diags, validateAuthorize(c, name, c.Operations[name])...)
+		diags = append(diags, validateUndo(c, name, c.Operations[name])...)
+		diags = append(diags, validatePrerequisites(c, name, c.Operations[name])...)
+		diags = append(diags, validateDuplicates(c, name, c.Operations[name])...)
+		diags = append(diags, validateCurrencies(c, name, c.Operations[name])...)
+	}
+	for _, name := range sortedFacts(c) {
+		diags = append(diags, validateFreshness(name, c.Facts[name])...)
+		diags = append(diags, validateUnit(name, c.Facts[name])...)
+		diags = append(diags, validateFactType(name, c.Facts[name])...)
+	}
+	for _, name := range sortedDerivedFacts(c) {
+		diags = append(diags, validateDerivedCache(c, name, c.DerivedFacts[name])...)
+		diags = append(diags, validateConvert(name, c.DerivedFacts[name])...)
+	}
+	diags = append(diags, validateTimezone(c)...)
+	diags = append(diags, validateIdentity(c)...)
+	diags = append(diags, validateDataUse(c)...)
+	diags = append(diags, validateEntityRefs(c)...)
+	diags = append(diags, lint(c)...)
+	return diags
+}
+
+// validateVerdict checks that a rule declares a verdict; one that does not
+// can match but never contributes to an outcome.
+func validateVerdict(r RuleDef) []Diagnostic {
+	if verdictType(r.Verdict) != "" {
+		return nil
+	}
+	return []Diagnostic{{
+		Severity: SeverityError,
+		Rule:     r.ID,
+		Message:  "verdict declares none of deny, escalate, require or flag",
+	}}
+}
+
+// validateWindow checks a rule's effective_from / expires_at window.
+func validateWindow(r RuleDef, now time.Time) []Diagnostic {
+	switch {
+	case r.EffectiveFrom != nil && r.ExpiresAt != nil && !r.EffectiveFrom.Before(*r.ExpiresAt):
+		return []Diagnostic{{
+			Severity: SeverityError,
+			Rule:     r.ID,
+			Message:  fmt.Sprintf("effective_from %s is not before expires_at %s; the rule can never apply", r.EffectiveFrom.Format(time.RFC3339), r.ExpiresAt.Format(time.RFC3339)),
+		}}
+	case r.ExpiresAt != nil && !now.Before(*r.ExpiresAt):
+		return []Diagnostic{{
+			Severity: SeverityWarning,
+			Rule:     r.ID,
+			Message:  fmt.Sprintf("expired at %s; remove it from the contract", r.ExpiresAt.Format(time.RFC3339)),
+		}}
+	}
+	return nil
+}
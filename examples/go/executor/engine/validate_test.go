@@ -0,0 +1,59 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestErrorDiagnostics_locatesCompileErrors(t *testing.T) {
+	_, err := CompileSources(map[string][]byte{
+		"shop/facts.cue": []byte("facts: {\n\tx: {source: \"input\"}\n}\n"),
+		"shop/rules.cue": []byte("rules: [\n\t{id: \"r\", when: {fact: \"x\", equals: }},\n]\n"),
+	}, nil)
+	diags := ErrorDiagnostics(err)
+	if len(diags) == 0 {
+		t.Fatal("expected diagnostics")
+	}
+	if got, want := diags[0].String(), "shop/rules.cue:2:38: error: expected operand, found '}'"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestCompileSources_failsWithContractError(t *testing.T) {
+	_, err := CompileSources(map[string][]byte{
+		"shop/a.cue": []byte("version: \"1.0.0\"\n"),
+		"shop/b.cue": []byte("version: \"2.0.0\"\n"),
+	}, nil)
+	var ce *ContractError
+	if !errors.As(err, &ce) {
+		t.Fatalf("expected a *ContractError, got %T: %v", err, err)
+	}
+	if len(ce.Errors) != 1 || ce.Errors[0].File != "shop/a.cue" || ce.Errors[0].Line != 1 {
+		t.Errorf("expected the conflict located, got %+v", ce.Errors)
+	}
+	if got := err.Error(); got != "shop/a.cue:1:10: "+ce.Errors[0].Message {
+		t.Errorf("unexpected message %q", got)
+	}
+}
+
+func TestErrorDiagnostics_keepsErrorsWithoutPositions(t *testing.T) {
+	diags := ErrorDiagnostics(fmt.Errorf("import %q: %w", "common", errors.New("not found")))
+	if len(diags) != 1 || diags[0].File != "" || diags[0].Message != `import "common": not found` {
+		t.Errorf("unexpected diagnostics %+v", diags)
+	}
+}
+
+func TestCompileSources_resolvesImportsWithLoad(t *testing.T) {
+	c, err := CompileSources(map[string][]byte{
+		"shop/contract.cue": []byte("imports: [\"common\"]\noperations: op: {constrained_by: [], transitions: []}\n"),
+	}, func(name string) (map[string][]byte, error) {
+		return map[string][]byte{name + "/base.cue": []byte("facts: \"customer.id\": {source: \"input\"}\n")}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Facts["customer.id"]; !ok {
+		t.Errorf("expected the imported fact, got %v", c.Facts)
+	}
+}
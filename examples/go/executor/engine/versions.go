@@ -0,0 +1,137 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+)
+
+// defaultRetainedVersions is how many distinct semantic versions an Engine
+// keeps loaded for negotiation unless WithRetainedVersions says otherwise.
+const defaultRetainedVersions = 3
+
+// loadedContract is one contract version the engine can evaluate against.
+type loadedContract struct {
+	contract *Contract
+	etag     string
+	version  semver
+}
+
+// ContractVersion describes a loaded contract version, as listed by Versions.
+type ContractVersion struct {
+	Version string `json:"version"`
+	ETag    string `json:"contract_etag"`
+	Active  bool   `json:"active"`
+}
+
+// WithRetainedVersions sets how many distinct semantic versions stay loaded
+// after newer ones arrive, so clients pinned to an older range keep working.
+// The active contract is always retained.
+func WithRetainedVersions(n int) Option {
+	return func(e *Engine) {
+		if n > 0 {
+			e.retain = n
+		}
+	}
+}
+
+// retainVersion records c under its declared version, replacing any earlier
+// load of the same version, and evicts the oldest-loaded versions beyond the
+// retention limit. Unversioned contracts are not retained. Callers hold e.mu.
+func (e *Engine) retainVersion(c *Contract, etag string) {
+	if c.Version == "" {
+		return
+	}
+	v, err := parseSemver(c.Version)
+	if err != nil {
+		// compileContract rejects invalid versions; a hand-built Contract
+		// with a bad version simply isn't negotiable.
+		return
+	}
+	kept := e.versions[:0]
+	for _, lc := range e.versions {
+		if lc.version != v {
+			kept = append(kept, lc)
+		}
+	}
+	kept = append(kept, loadedContract{contract: c, etag: etag, version: v})
+	if len(kept) > e.retain {
+		kept = kept[len(kept)-e.retain:]
+	}
+	e.versions = kept
+}
+
+// Versions lists the loaded contract versions, newest first.
+func (e *Engine) Versions() []ContractVersion {
+	e.activateDue()
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	out := make([]ContractVersion, 0, len(e.versions))
+	for _, lc := range e.sortedVersions() {
+		out = append(out, ContractVersion{
+			Version: lc.version.String(),
+			ETag:    lc.etag,
+			Active:  lc.contract == e.contract,
+		})
+	}
+	return out
+}
+
+// sortedVersions returns the retained versions, newest first. Callers hold e.mu.
+func (e *Engine) sortedVersions() []loadedContract {
+	vs := append([]loadedContract(nil), e.versions...)
+	sort.Slice(vs, func(i, j int) bool { return vs[j].version.less(vs[i].version) })
+	return vs
+}
+
+// selectContract picks the contract a request is evaluated against. With no
+// range it is the active contract; otherwise the highest loaded version
+// satisfying the range. If none does, a negotiation error response is
+// returned instead.
+func (e *Engine) selectContract(rng string) (*Contract, string, *Response) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if rng == "" {
+		return e.contract, e.contractETag, nil
+	}
+	r, err := parseVersionRange(rng)
+	if err != nil {
+		return nil, "", &Response{
+			Outcome: OutcomeSystemError,
+			Error: &ErrorEnvelope{
+				Code:       "INVALID_VERSION_RANGE",
+				Message:    err.Error(),
+				HttpStatus: 400,
+				Category:   "validation",
+				Retryable:  false,
+			},
+		}
+	}
+
+	sorted := e.sortedVersions()
+	for _, lc := range sorted {
+		if r.matches(lc.version) {
+			return lc.contract, lc.etag, nil
+		}
+	}
+
+	available := make([]string, 0, len(sorted))
+	for _, lc := range sorted {
+		available = append(available, lc.version.String())
+	}
+	return nil, "", &Response{
+		Outcome: OutcomeSystemError,
+		Error: &ErrorEnvelope{
+			Code:       "CONTRACT_VERSION_UNAVAILABLE",
+			Message:    fmt.Sprintf("No loaded contract version satisfies %q", rng),
+			HttpStatus: 406,
+			Category:   "system",
+			Retryable:  false,
+			Suggestion: "Request one of the available versions",
+			Details: map[string]any{
+				"requested": rng,
+				"available": available,
+			},
+		},
+	}
+}
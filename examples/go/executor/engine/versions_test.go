@@ -0,0 +1,161 @@
+package engine
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func versionedContract(version string) *Contract {
+	c := makeMinimalContract()
+	c.Version = version
+	return c
+}
+
+func TestVersionRange_matches(t *testing.T) {
+	cases := []struct {
+		rng, version string
+		want         bool
+	}{
+		{"^2.0", "2.0.0", true},
+		{"^2.0", "2.9.3", true},
+		{"^2.0", "3.0.0", false},
+		{"^2.1", "2.0.9", false},
+		{"^0.2.1", "0.2.5", true},
+		{"^0.2.1", "0.3.0", false},
+		{"~2.1.3", "2.1.9", true},
+		{"~2.1.3", "2.2.0", false},
+		{"2.1.3", "2.1.3", true},
+		{"2.1.3", "2.1.4", false},
+		{"2.1", "2.1.7", true},
+		{"2.x", "2.4.0", true},
+		{"2.x", "1.9.0", false},
+		{">=1.5", "4.0.0", true},
+		{">=1.5", "1.4.9", false},
+		{"*", "0.0.1", true},
+	}
+	for _, tc := range cases {
+		r, err := parseVersionRange(tc.rng)
+		if err != nil {
+			t.Fatalf("parseVersionRange(%q): %v", tc.rng, err)
+		}
+		v, err := parseSemver(tc.version)
+		if err != nil {
+			t.Fatalf("parseSemver(%q): %v", tc.version, err)
+		}
+		if got := r.matches(v); got != tc.want {
+			t.Errorf("%q matches %q = %v, want %v", tc.rng, tc.version, got, tc.want)
+		}
+	}
+}
+
+func TestParseVersionRange_rejectsGarbage(t *testing.T) {
+	for _, s := range []string{"", "^", "two", "1.2.3.4", "^1.x", "~-1"} {
+		if _, err := parseVersionRange(s); err == nil {
+			t.Errorf("parseVersionRange(%q): expected error", s)
+		}
+	}
+}
+
+func TestEngine_Evaluate_versionRangeSelectsHighestCompatible(t *testing.T) {
+	e := NewEngine(&mockPorts{})
+	e.LoadContract(versionedContract("1.4.0"), "etag-1")
+	e.LoadContract(versionedContract("2.0.0"), "etag-2a")
+	e.LoadContract(versionedContract("2.1.0"), "etag-2b")
+	e.LoadContract(versionedContract("3.0.0"), "etag-3")
+
+	resp, err := e.Evaluate(context.Background(), &Request{
+		Operation:    "testOp",
+		DryRun:       true,
+		VersionRange: "^2.0",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.ContractSemver != "2.1.0" {
+		t.Errorf("expected 2.1.0, got %q", resp.ContractSemver)
+	}
+}
+
+func TestEngine_Evaluate_noVersionRangeUsesActiveContract(t *testing.T) {
+	e := NewEngine(&mockPorts{})
+	e.LoadContract(versionedContract("2.0.0"), "etag-2")
+	e.LoadContract(versionedContract("1.0.0"), "etag-1") // rollback
+
+	resp, err := e.Evaluate(context.Background(), &Request{Operation: "testOp", DryRun: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.ContractSemver != "1.0.0" {
+		t.Errorf("expected active 1.0.0, got %q", resp.ContractSemver)
+	}
+}
+
+func TestEngine_Evaluate_unsatisfiableRangeListsAvailableVersions(t *testing.T) {
+	e := NewEngine(&mockPorts{})
+	e.LoadContract(versionedContract("1.0.0"), "etag-1")
+	e.LoadContract(versionedContract("2.0.0"), "etag-2")
+
+	resp, err := e.Evaluate(context.Background(), &Request{Operation: "testOp", VersionRange: "^3.0"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Error == nil || resp.Error.Code != "CONTRACT_VERSION_UNAVAILABLE" {
+		t.Fatalf("expected CONTRACT_VERSION_UNAVAILABLE, got %+v", resp.Error)
+	}
+	if resp.Error.HttpStatus != 406 {
+		t.Errorf("expected HTTP 406, got %d", resp.Error.HttpStatus)
+	}
+	want := []string{"2.0.0", "1.0.0"}
+	if got := resp.Error.Details["available"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected available %v, got %v", want, got)
+	}
+}
+
+func TestEngine_Evaluate_invalidRangeIsValidationError(t *testing.T) {
+	e := NewEngine(&mockPorts{})
+	e.LoadContract(versionedContract("1.0.0"), "etag-1")
+
+	resp, err := e.Evaluate(context.Background(), &Request{Operation: "testOp", VersionRange: "latest"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Error == nil || resp.Error.Code != "INVALID_VERSION_RANGE" {
+		t.Fatalf("expected INVALID_VERSION_RANGE, got %+v", resp.Error)
+	}
+}
+
+func TestEngine_Evaluate_etagCheckedAgainstSelectedVersion(t *testing.T) {
+	e := NewEngine(&mockPorts{})
+	e.LoadContract(versionedContract("1.0.0"), "etag-1")
+	e.LoadContract(versionedContract("2.0.0"), "etag-2")
+
+	resp, err := e.Evaluate(context.Background(), &Request{
+		Operation:    "testOp",
+		DryRun:       true,
+		VersionRange: "^1.0",
+		ContractETag: "etag-1",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Error != nil {
+		t.Fatalf("expected etag of the selected version to be accepted, got %+v", resp.Error)
+	}
+}
+
+func TestEngine_LoadContract_evictsOldestBeyondRetention(t *testing.T) {
+	e := NewEngine(&mockPorts{}, WithRetainedVersions(2))
+	e.LoadContract(versionedContract("1.0.0"), "etag-1")
+	e.LoadContract(versionedContract("1.1.0"), "etag-2")
+	e.LoadContract(versionedContract("1.1.0"), "etag-3") // same version, new content
+	e.LoadContract(versionedContract("2.0.0"), "etag-4")
+
+	want := []ContractVersion{
+		{Version: "2.0.0", ETag: "etag-4", Active: true},
+		{Version: "1.1.0", ETag: "etag-3"},
+	}
+	if got := e.Versions(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
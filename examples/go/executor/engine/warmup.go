@@ -0,0 +1,213 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Warmup exercises a contract before it is activated, as of the given time,
+// and returns as errors the problems that would otherwise only surface when
+// a request reaches them:
+//
+//   - a greater_than or less_than threshold, in a condition or a
+//     derivation, that is not a number or a quantity such as "30d" once
+//     params are bound, or whose unit does not suit the fact it is
+//     compared with, so the comparison can never hold;
+//   - a before, after or between operand that is not a timestamp, or a
+//     comparison of a declared fact not of type timestamp;
+//   - a fact that a rule, an authorize block or a derivation reads but that
+//     is neither declared nor derived, so it is always absent;
+//   - a weighted_sum or score without terms, or a score whose bands are
+//     missing, unlabelled or overlap;
+//   - a failure evaluating an operation, such as an unknown derivation
+//     function, when each operation is evaluated on synthetic facts made
+//     from the operands the contract compares them with.
+//
+// Like Validate, it never modifies c; a contract with any diagnostic should
+// not be activated.
+func (e *Engine) Warmup(c *Contract, at time.Time) []Diagnostic {
+	var diags []Diagnostic
+	report := func(rule, format string, args ...any) {
+		diags = append(diags, Diagnostic{Severity: SeverityError, Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	checkCondition := func(rule, where string, cond Condition) {
+		eachCondition(cond, func(cond Condition) {
+			if cond.Fact != "" && !factDeclared(c, cond.Fact) {
+				report(rule, "%sreads %s, which is neither declared nor derived", where, cond.Fact)
+			}
+			for _, t := range []struct {
+				op      string
+				operand any
+			}{{"greater_than", cond.GreaterThan}, {"less_than", cond.LessThan}} {
+				if t.operand == nil {
+					continue
+				}
+				v := c.ResolveOperand(t.operand)
+				if !isQuantity(v) {
+					report(rule, "%s%s %s threshold %v is not a number", where, cond.Fact, t.op, v)
+				} else if p := checkUnits(c, cond.Fact, v); p != "" {
+					report(rule, "%s%s %s %s", where, cond.Fact, t.op, p)
+				}
+			}
+			for _, p := range checkTimes(c, cond, at) {
+				report(rule, "%s%s", where, p)
+			}
+		})
+	}
+	for _, r := range c.Rules {
+		checkCondition(r.ID, "", r.When)
+	}
+	for _, name := range sortedOperations(c) {
+		if auth := c.Operations[name].Authorize; auth != nil {
+			checkCondition("", "operation "+name+" authorize: ", auth.Requires)
+		}
+	}
+	for _, name := range sortedDerivedFacts(c) {
+		d := c.DerivedFacts[name].Derivation
+		if d.Fn == "weighted_sum" || d.Fn == "score" {
+			for _, p := range checkScore(d) {
+				report("", "derived fact %s: %s", name, p)
+			}
+		}
+		for i, arg := range d.Args {
+			if arg.Fact != "" && !factDeclared(c, arg.Fact) {
+				report("", "derived fact %s: reads %s, which is neither declared nor derived", name, arg.Fact)
+			}
+			if (arg.Op == "greater_than" || arg.Op == "less_than") && !isNumber(arg.Value) {
+				report("", "derived fact %s: %s threshold %v is not a number", name, d.Fn, arg.Value)
+			}
+			if i > 0 && arg.Fact == "" && (d.Fn == "greater_than" || d.Fn == "greater_or_equal" || d.Fn == "less_than") {
+				if !isQuantity(arg.Value) {
+					report("", "derived fact %s: %s threshold %v is not a number", name, d.Fn, arg.Value)
+				} else if p := checkUnits(c, d.Args[0].Fact, arg.Value); p != "" {
+					report("", "derived fact %s: %s %s", name, d.Fn, p)
+				}
+			}
+		}
+	}
+	if len(diags) > 0 {
+		return diags
+	}
+
+	probes := probeFacts(c)
+	facts := NewFactSet()
+	for name, val := range probes {
+		facts.Set(name, val)
+	}
+	if err := guard(func() error { return e.deriveFacts(context.Background(), nil, c, facts) }); err != nil {
+		report("", "self-check: %v", err)
+		return diags
+	}
+	derived := facts.Snapshot()
+	for _, name := range sortedOperations(c) {
+		facts := NewFactSet()
+		for name, val := range derived {
+			facts.Set(name, val)
+		}
+		err := guard(func() error {
+			e.evaluateRules(c, name, facts, at)
+			if auth := c.Operations[name].Authorize; auth != nil {
+				evalCondition(auth.Requires, facts)
+			}
+			return nil
+		})
+		if err != nil {
+			report("", "operation %s: self-check: %v", name, err)
+		}
+	}
+	return diags
+}
+
+// factDeclared reports whether path names a declared or derived fact, an
+// entity state, or a field of one of them. Entity state references are
+// checked by validateEntityRefs.
+func factDeclared(c *Contract, path string) bool {
+	if strings.HasPrefix(path, entityRefPrefix) {
+		return true
+	}
+	for p := path; ; {
+		if _, ok := c.Facts[p]; ok {
+			return true
+		}
+		if _, ok := c.DerivedFacts[p]; ok {
+			return true
+		}
+		i := strings.LastIndex(p, ".")
+		if i < 0 {
+			return false
+		}
+		p = p[:i]
+	}
+}
+
+// probeFacts returns synthetic values for the facts the contract compares,
+// each the first operand it is compared with, plus the bound params.
+func probeFacts(c *Contract) map[string]any {
+	probes := map[string]any{}
+	probe := func(path string, operand any) {
+		if _, ok := probes[path]; ok || path == "" || operand == nil {
+			return
+		}
+		if _, ok := c.DerivedFacts[path]; !ok {
+			probes[path] = operand
+		}
+	}
+	visit := func(cond Condition) {
+		eachCondition(cond, func(cond Condition) {
+			for _, v := range append([]any{cond.Equals, cond.GreaterThan, cond.LessThan}, cond.In...) {
+				probe(cond.Fact, c.ResolveOperand(v))
+			}
+		})
+	}
+	for _, r := range c.Rules {
+		visit(r.When)
+	}
+	for _, name := range sortedOperations(c) {
+		if auth := c.Operations[name].Authorize; auth != nil {
+			visit(auth.Requires)
+		}
+	}
+	for _, name := range sortedDerivedFacts(c) {
+		for _, arg := range c.DerivedFacts[name].Derivation.Args {
+			probe(arg.Fact, arg.Value)
+		}
+	}
+	for name := range c.Params {
+		if val, ok := c.ParamValue(name); ok {
+			probes[paramFactPrefix+name] = val
+		}
+	}
+	return probes
+}
+
+func isNumber(v any) bool {
+	_, ok := toFloat(v)
+	return ok
+}
+
+// eachCondition calls visit for cond and every condition nested in it.
+func eachCondition(cond Condition, visit func(Condition)) {
+	visit(cond)
+	for _, sub := range cond.All {
+		eachCondition(sub, visit)
+	}
+	for _, sub := range cond.Any {
+		eachCondition(sub, visit)
+	}
+	if cond.Not != nil {
+		eachCondition(*cond.Not, visit)
+	}
+}
+
+// guard runs fn, returning a panic in it as an error.
+func guard(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn()
+}
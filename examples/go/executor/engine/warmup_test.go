@@ -0,0 +1,73 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEngine_Warmup_acceptsTheBillingContract(t *testing.T) {
+	c, err := CompileDir("../../contracts/billing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Bind("dev", nil); err != nil {
+		t.Fatal(err)
+	}
+	if diags := NewEngine(&mockPorts{}).Warmup(c, time.Now()); len(diags) != 0 {
+		t.Errorf("expected no warm-up errors, got %v", diags)
+	}
+}
+
+func TestEngine_Warmup_reportsLatentErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Contract)
+		want   string
+	}{
+		{
+			name: "threshold param bound to a string",
+			modify: func(c *Contract) {
+				c.Bindings = map[string]any{"limit": "100"}
+			},
+			want: "error: rule over-limit: amount greater_than threshold 100 is not a number",
+		},
+		{
+			name: "undeclared fact",
+			modify: func(c *Contract) {
+				c.Rules[0].When = Condition{Fact: "amout", GreaterThan: 100.0}
+			},
+			want: "error: rule over-limit: reads amout, which is neither declared nor derived",
+		},
+		{
+			name: "derivation threshold",
+			modify: func(c *Contract) {
+				c.DerivedFacts["large"] = DerivedFactDef{Derivation: Derivation{Fn: "greater_than", Args: []DerivationArg{{Fact: "amount"}, {Value: "1k"}}}}
+			},
+			want: "error: derived fact large: greater_than threshold 1k is not a number",
+		},
+		{
+			name: "unknown derivation function",
+			modify: func(c *Contract) {
+				c.DerivedFacts["large"] = DerivedFactDef{Derivation: Derivation{Fn: "greater_then", Args: []DerivationArg{{Fact: "amount"}}}}
+			},
+			want: `error: self-check: derive "large": unknown derivation function: greater_then`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := makeParamContract()
+			tt.modify(c)
+			diags := NewEngine(&mockPorts{}).Warmup(c, time.Now())
+			if len(diags) != 1 || diags[0].String() != tt.want {
+				t.Errorf("got %v, want %s", diags, tt.want)
+			}
+		})
+	}
+}
+
+func TestGuard_returnsPanicsAsErrors(t *testing.T) {
+	if err := guard(func() error { panic("boom") }); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("expected the panic returned, got %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package engine
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func fixedClock(t time.Time) func() time.Time { return func() time.Time { return t } }
+
+func mustTime(t *testing.T, s string) *time.Time {
+	t.Helper()
+	ts, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &ts
+}
+
+func makeWindowedContract(t *testing.T, from, until string) *Contract {
+	c := makeSimpleContract("promo-limit",
+		VerdictDef{Deny: &DenyVerdict{Code: "PROMO_LIMIT", Error: ErrorEnvelope{Code: "PROMO_LIMIT", Suggestion: "Pay without the promotion"}}},
+		Condition{},
+	)
+	if from != "" {
+		c.Rules[0].EffectiveFrom = mustTime(t, from)
+	}
+	if until != "" {
+		c.Rules[0].ExpiresAt = mustTime(t, until)
+	}
+	return c
+}
+
+func TestEngine_Evaluate_ruleAppliesInsideWindow(t *testing.T) {
+	e := NewEngine(&mockPorts{}, WithClock(fixedClock(*mustTime(t, "2026-02-15T00:00:00Z"))))
+	e.LoadContract(makeWindowedContract(t, "2026-01-01T00:00:00Z", "2026-04-01T00:00:00Z"), "v1")
+
+	resp, _ := e.Evaluate(context.Background(), &Request{Operation: "testOp", DryRun: true})
+	if resp.Outcome != "would_deny" {
+		t.Errorf("expected would_deny, got %s", resp.Outcome)
+	}
+}
+
+func TestEngine_Evaluate_ruleSkippedAfterExpiry(t *testing.T) {
+	e := NewEngine(&mockPorts{}, WithClock(fixedClock(*mustTime(t, "2026-04-01T00:00:00Z"))))
+	e.LoadContract(makeWindowedContract(t, "", "2026-04-01T00:00:00Z"), "v1")
+
+	resp, _ := e.Evaluate(context.Background(), &Request{Operation: "testOp", DryRun: true, Explain: true})
+	if resp.Outcome != "would_execute" {
+		t.Errorf("expected would_execute, got %s", resp.Outcome)
+	}
+	if rt := resp.Explain.Rules[0]; !rt.Inactive || rt.Matched {
+		t.Errorf("expected inactive, unmatched trace, got %+v", rt)
+	}
+}
+
+func TestEngine_Evaluate_ruleSkippedBeforeEffectiveFrom(t *testing.T) {
+	e := NewEngine(&mockPorts{}, WithClock(fixedClock(*mustTime(t, "2025-12-31T23:59:59Z"))))
+	e.LoadContract(makeWindowedContract(t, "2026-01-01T00:00:00Z", ""), "v1")
+
+	resp, _ := e.Evaluate(context.Background(), &Request{Operation: "testOp", DryRun: true})
+	if resp.Outcome != "would_execute" {
+		t.Errorf("expected would_execute, got %s", resp.Outcome)
+	}
+}
+
+func TestEngine_Evaluate_auditTimestampUsesClock(t *testing.T) {
+	at := *mustTime(t, "2026-03-01T12:00:00Z")
+	sink := &recordingSink{}
+	e := NewEngine(&mockPorts{}, WithClock(fixedClock(at)), WithAuditSink(sink))
+	e.LoadContract(makeMinimalContract(), "v1")
+
+	e.Evaluate(context.Background(), &Request{Operation: "testOp", DryRun: true})
+	if got := sink.recs[0].Timestamp; !got.Equal(at) {
+		t.Errorf("expected timestamp %v, got %v", at, got)
+	}
+}
+
+func TestEngine_Simulate_evaluatesAsOfRequestedTime(t *testing.T) {
+	e := NewEngine(&mockPorts{}, WithClock(fixedClock(*mustTime(t, "2025-06-01T00:00:00Z"))))
+	e.LoadContract(makeWindowedContract(t, "2026-01-01T00:00:00Z", ""), "v1")
+
+	resp, err := e.Simulate(&SimulateRequest{Operation: "testOp", At: mustTime(t, "2026-01-02T00:00:00Z")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Outcome != "would_deny" {
+		t.Errorf("expected would_deny as of 2026-01-02, got %s", resp.Outcome)
+	}
+}
+
+func TestCompileContract_parsesRuleWindow(t *testing.T) {
+	c, err := compileContract([]sourceFile{{path: "r.cue", data: []byte(`
+rules: [{
+	id: "promo"
+	applies_to: ["op"]
+	when: {}
+	verdict: flag: {code: "PROMO", reason: "r"}
+	effective_from: "2026-01-01T00:00:00Z"
+	expires_at:     "2026-03-31T23:59:59Z"
+}]
+`)}})
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	r := c.Rules[0]
+	if r.EffectiveFrom == nil || r.ExpiresAt == nil || r.ExpiresAt.Month() != time.March {
+		t.Errorf("window not parsed: %+v", r)
+	}
+}
+
+func TestValidate_warnsAboutExpiredRule(t *testing.T) {
+	c := makeWindowedContract(t, "", "2026-01-01T00:00:00Z")
+
+	diags := Validate(c, *mustTime(t, "2026-06-01T00:00:00Z"))
+	if len(diags) != 1 || diags[0].Severity != SeverityWarning || diags[0].Rule != "promo-limit" {
+		t.Fatalf("expected one expiry warning, got %v", diags)
+	}
+	if diags := Validate(c, *mustTime(t, "2025-06-01T00:00:00Z")); len(diags) != 0 {
+		t.Errorf("expected no diagnostics before expiry, got %v", diags)
+	}
+}
+
+func TestValidate_emptyWindowIsError(t *testing.T) {
+	c := makeWindowedContract(t, "2026-04-01T00:00:00Z", "2026-01-01T00:00:00Z")
+
+	diags := Validate(c, *mustTime(t, "2025-01-01T00:00:00Z"))
+	if len(diags) != 1 || diags[0].Severity != SeverityError {
+		t.Fatalf("expected one error, got %v", diags)
+	}
+}
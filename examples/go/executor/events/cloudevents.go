@@ -0,0 +1,242 @@
+// Package events publishes decision events for external eventing
+// infrastructure.
+//
+// Emitter is an engine.AuditSink that turns every audit record into a
+// CloudEvents 1.0 event and POSTs it to an HTTP sink — a Knative broker, an
+// EventBridge API destination, or anything else that speaks the CloudEvents
+// HTTP binding — in either binary or structured content mode. Routing can
+// key on the event type (one per outcome), the subject (the operation), or
+// the outcome and contractetag extension attributes, without a
+// covenant-specific schema.
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"covenant-poc/executor/engine"
+)
+
+// Content modes of the CloudEvents HTTP protocol binding.
+const (
+	// ModeBinary carries the attributes in ce-* headers and the audit
+	// record as the body.
+	ModeBinary = "binary"
+	// ModeStructured carries the whole event, data included, as an
+	// application/cloudevents+json body.
+	ModeStructured = "structured"
+)
+
+// TypePrefix prefixes each event's type; the outcome completes it, e.g.
+// "dev.covenant.decision.denied".
+const TypePrefix = "dev.covenant.decision."
+
+// defaultQueueSize bounds the events awaiting delivery.
+const defaultQueueSize = 1024
+
+// eventCounts is published at /debug/vars as covenant_events.
+var eventCounts = expvar.NewMap("covenant_events")
+
+// Event is a CloudEvents 1.0 event carrying one decision.
+type Event struct {
+	SpecVersion     string              `json:"specversion"`
+	ID              string              `json:"id"`
+	Source          string              `json:"source"`
+	Type            string              `json:"type"`
+	Subject         string              `json:"subject,omitempty"`
+	Time            time.Time           `json:"time"`
+	DataContentType string              `json:"datacontenttype"`
+	Outcome         string              `json:"outcome"`
+	ContractETag    string              `json:"contractetag,omitempty"`
+	Data            *engine.AuditRecord `json:"data"`
+}
+
+// NewEvent wraps rec as a CloudEvent from source. The invocation ID is the
+// event ID, so redelivered events can be deduplicated downstream.
+func NewEvent(source string, rec *engine.AuditRecord) Event {
+	return Event{
+		SpecVersion:     "1.0",
+		ID:              rec.InvocationID,
+		Source:          source,
+		Type:            TypePrefix + rec.Outcome,
+		Subject:         rec.Operation,
+		Time:            rec.Timestamp,
+		DataContentType: "application/json",
+		Outcome:         rec.Outcome,
+		ContractETag:    rec.ContractVersion,
+		Data:            rec,
+	}
+}
+
+// Config configures an Emitter. Zero values take the documented defaults.
+type Config struct {
+	URL       string       // sink to POST events to
+	Mode      string       // ModeBinary (default) or ModeStructured
+	Source    string       // event source URI reference (default "/covenant/executor")
+	QueueSize int          // events buffered for delivery (default 1024)
+	Client    *http.Client // default has a 10s timeout
+}
+
+// Emitter delivers decision events asynchronously. When the queue is full,
+// events are dropped and counted rather than delaying evaluation.
+type Emitter struct {
+	cfg   Config
+	queue chan Event
+	wg    sync.WaitGroup
+}
+
+// NewEmitter starts an Emitter delivering to cfg.URL.
+func NewEmitter(cfg Config) (*Emitter, error) {
+	switch cfg.Mode {
+	case "":
+		cfg.Mode = ModeBinary
+	case ModeBinary, ModeStructured:
+	default:
+		return nil, fmt.Errorf("unknown CloudEvents mode %q (want %s or %s)", cfg.Mode, ModeBinary, ModeStructured)
+	}
+	if cfg.Source == "" {
+		cfg.Source = "/covenant/executor"
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultQueueSize
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	e := &Emitter{cfg: cfg, queue: make(chan Event, cfg.QueueSize)}
+	e.wg.Add(1)
+	go e.run()
+	return e, nil
+}
+
+// Record implements engine.AuditSink.
+func (e *Emitter) Record(_ context.Context, rec *engine.AuditRecord) {
+	select {
+	case e.queue <- NewEvent(e.cfg.Source, rec):
+	default:
+		eventCounts.Add("dropped", 1)
+	}
+}
+
+// Close waits for queued events to be delivered. The Emitter must not
+// receive records after Close.
+func (e *Emitter) Close() {
+	close(e.queue)
+	e.wg.Wait()
+}
+
+// Deliver sends rec's event synchronously, for delivery through an outbox
+// rather than the Emitter's queue.
+func (e *Emitter) Deliver(ctx context.Context, rec *engine.AuditRecord) error {
+	if err := e.send(ctx, NewEvent(e.cfg.Source, rec)); err != nil {
+		eventCounts.Add("failed", 1)
+		return err
+	}
+	eventCounts.Add("sent", 1)
+	return nil
+}
+
+func (e *Emitter) run() {
+	defer e.wg.Done()
+	for ev := range e.queue {
+		if err := e.send(context.Background(), ev); err != nil {
+			eventCounts.Add("failed", 1)
+			log.Printf("cloudevents: %s: %v", ev.ID, err)
+			continue
+		}
+		eventCounts.Add("sent", 1)
+	}
+}
+
+func (e *Emitter) send(ctx context.Context, ev Event) error {
+	req, err := NewRequest(e.cfg.URL, e.cfg.Mode, ev)
+	if err != nil {
+		return err
+	}
+	resp, err := e.cfg.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// NewRequest encodes ev as a POST to url in the given content mode.
+func NewRequest(url, mode string, ev Event) (*http.Request, error) {
+	if mode == ModeStructured {
+		body, err := json.Marshal(ev)
+		if err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/cloudevents+json; charset=utf-8")
+		return req, nil
+	}
+
+	body, err := json.Marshal(ev.Data)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", ev.DataContentType)
+	for name, val := range binaryHeaders(ev) {
+		req.Header.Set(name, val)
+	}
+	return req, nil
+}
+
+// binaryHeaders maps ev's attributes, other than datacontenttype (which is
+// Content-Type), to ce-* headers.
+func binaryHeaders(ev Event) map[string]string {
+	h := map[string]string{
+		"ce-specversion": ev.SpecVersion,
+		"ce-id":          ev.ID,
+		"ce-source":      ev.Source,
+		"ce-type":        ev.Type,
+		"ce-time":        ev.Time.Format(time.RFC3339Nano),
+		"ce-outcome":     ev.Outcome,
+	}
+	if ev.Subject != "" {
+		h["ce-subject"] = ev.Subject
+	}
+	if ev.ContractETag != "" {
+		h["ce-contractetag"] = ev.ContractETag
+	}
+	for k, v := range h {
+		h[k] = headerEscape(v)
+	}
+	return h
+}
+
+// headerEscape percent-encodes space, '"', '%' and bytes outside printable
+// ASCII, as the HTTP binding requires of ce-* header values.
+func headerEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c <= ' ' || c == '"' || c == '%' || c >= 0x7f {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
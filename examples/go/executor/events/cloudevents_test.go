@@ -0,0 +1,131 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"covenant-poc/executor/engine"
+)
+
+// captured is one request received by the test sink.
+type captured struct {
+	header http.Header
+	body   []byte
+}
+
+func newSink(t *testing.T) (*httptest.Server, func() []captured) {
+	var mu sync.Mutex
+	var got []captured
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		got = append(got, captured{header: r.Header, body: body})
+		mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []captured {
+		mu.Lock()
+		defer mu.Unlock()
+		return got
+	}
+}
+
+func testRecord() *engine.AuditRecord {
+	return &engine.AuditRecord{
+		InvocationID:    "inv_abc",
+		Timestamp:       time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC),
+		Operation:       "ProcessPayment",
+		ContractVersion: "etag-1",
+		Outcome:         "denied",
+		Verdicts:        []engine.Verdict{{Rule: "r", Type: "deny", Code: "NO"}},
+	}
+}
+
+func TestEmitter_binaryMode(t *testing.T) {
+	srv, got := newSink(t)
+	em, err := NewEmitter(Config{URL: srv.URL, Source: "/billing/executor"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	em.Record(context.Background(), testRecord())
+	em.Close()
+
+	reqs := got()
+	if len(reqs) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(reqs))
+	}
+	h := reqs[0].header
+	want := map[string]string{
+		"Content-Type":    "application/json",
+		"Ce-Specversion":  "1.0",
+		"Ce-Id":           "inv_abc",
+		"Ce-Source":       "/billing/executor",
+		"Ce-Type":         "dev.covenant.decision.denied",
+		"Ce-Subject":      "ProcessPayment",
+		"Ce-Time":         "2026-05-01T12:00:00Z",
+		"Ce-Outcome":      "denied",
+		"Ce-Contractetag": "etag-1",
+	}
+	for k, v := range want {
+		if h.Get(k) != v {
+			t.Errorf("%s: got %q, want %q", k, h.Get(k), v)
+		}
+	}
+	var rec engine.AuditRecord
+	if err := json.Unmarshal(reqs[0].body, &rec); err != nil {
+		t.Fatalf("body is not an audit record: %v", err)
+	}
+	if rec.InvocationID != "inv_abc" || len(rec.Verdicts) != 1 {
+		t.Errorf("unexpected body %s", reqs[0].body)
+	}
+}
+
+func TestEmitter_structuredMode(t *testing.T) {
+	srv, got := newSink(t)
+	em, err := NewEmitter(Config{URL: srv.URL, Mode: ModeStructured})
+	if err != nil {
+		t.Fatal(err)
+	}
+	em.Record(context.Background(), testRecord())
+	em.Close()
+
+	reqs := got()
+	if len(reqs) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(reqs))
+	}
+	if ct := reqs[0].header.Get("Content-Type"); ct != "application/cloudevents+json; charset=utf-8" {
+		t.Errorf("unexpected Content-Type %q", ct)
+	}
+	if reqs[0].header.Get("Ce-Id") != "" {
+		t.Error("structured mode must not set ce-* headers")
+	}
+	var ev map[string]any
+	if err := json.Unmarshal(reqs[0].body, &ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev["specversion"] != "1.0" || ev["source"] != "/covenant/executor" || ev["type"] != "dev.covenant.decision.denied" {
+		t.Errorf("unexpected event %s", reqs[0].body)
+	}
+	if data, _ := ev["data"].(map[string]any); data["invocation_id"] != "inv_abc" {
+		t.Errorf("expected audit record as data, got %v", ev["data"])
+	}
+}
+
+func TestNewEmitter_rejectsUnknownMode(t *testing.T) {
+	if _, err := NewEmitter(Config{URL: "http://x", Mode: "batched"}); err == nil {
+		t.Fatal("expected error for unknown mode")
+	}
+}
+
+func TestHeaderEscape(t *testing.T) {
+	if got := headerEscape(`Pay "now" 100% ü`); got != `Pay%20%22now%22%20100%25%20%C3%BC` {
+		t.Errorf("got %s", got)
+	}
+}
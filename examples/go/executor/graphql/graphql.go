@@ -0,0 +1,356 @@
+// Package graphql serves contract operations over GraphQL.
+//
+// The schema is generated from the loaded contract and regenerated whenever
+// its ETag changes: each operation becomes a mutation resolving through
+// Engine.Evaluate, with dry_run, explain, contract_etag and version_range as
+// field arguments, and the contract's facts and operations are exposed as
+// queries. Types and field names mirror the JSON envelopes of POST /execute.
+//
+// Parsing and validation use gqlparser; execution is the small subset needed
+// here: fields, aliases, fragments and @skip/@include. Schema introspection
+// (__schema, __type) is not supported; GET /graphql/schema returns the SDL.
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"github.com/vektah/gqlparser/v2/validator"
+
+	"covenant-poc/executor/engine"
+)
+
+// Evaluator is the part of *engine.Engine the handler uses.
+type Evaluator interface {
+	Contract() *engine.Contract
+	ETag() string
+	Evaluate(ctx context.Context, req *engine.Request) (*engine.Response, error)
+}
+
+// Handler serves GraphQL requests at POST /graphql and the generated schema
+// at GET /graphql/schema.
+type Handler struct {
+	eng Evaluator
+
+	mu     sync.Mutex
+	schema *schema
+}
+
+// NewHandler returns a Handler evaluating against eng.
+func NewHandler(eng Evaluator) *Handler {
+	return &Handler{eng: eng}
+}
+
+// Register mounts the handler's routes on mux.
+func (h *Handler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("POST /graphql", h.serveQuery)
+	mux.HandleFunc("GET /graphql/schema", h.serveSchema)
+}
+
+// request is a GraphQL-over-HTTP request body.
+type request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// result is a GraphQL response body.
+type result struct {
+	Data   any           `json:"data"`
+	Errors gqlerror.List `json:"errors,omitempty"`
+}
+
+func (h *Handler) serveSchema(w http.ResponseWriter, r *http.Request) {
+	s, err := h.current()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(s.sdl))
+}
+
+func (h *Handler) serveQuery(w http.ResponseWriter, r *http.Request) {
+	var req request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	res := h.Execute(r.Context(), req.Query, req.OperationName, req.Variables)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+// current returns the schema for the active contract, regenerating it if the
+// contract has changed since it was last built.
+func (h *Handler) current() (*schema, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	etag := h.eng.ETag()
+	if h.schema != nil && h.schema.etag == etag {
+		return h.schema, nil
+	}
+	c := h.eng.Contract()
+	if c == nil {
+		return nil, gqlerror.Errorf("no contract loaded")
+	}
+	s, err := buildSchema(c, etag)
+	if err != nil {
+		return nil, err
+	}
+	h.schema = s
+	return s, nil
+}
+
+// Execute runs a GraphQL document against the active contract.
+func (h *Handler) Execute(ctx context.Context, query, operationName string, variables map[string]any) *result {
+	s, err := h.current()
+	if err != nil {
+		return &result{Errors: gqlerror.List{gqlerror.WrapIfUnwrapped(err)}}
+	}
+
+	doc, errs := gqlparser.LoadQuery(s.schema, query)
+	if len(errs) > 0 {
+		return &result{Errors: errs}
+	}
+	op := doc.Operations.ForName(operationName)
+	if op == nil {
+		return &result{Errors: gqlerror.List{gqlerror.Errorf("operation %q not found", operationName)}}
+	}
+	vars, err := validator.VariableValues(s.schema, op, variables)
+	if err != nil {
+		return &result{Errors: gqlerror.List{gqlerror.WrapIfUnwrapped(err)}}
+	}
+
+	ex := &execution{h: h, schema: s, doc: doc, vars: vars}
+	data := ex.selectionSet(ctx, op.SelectionSet, nil, ast.Path{})
+	return &result{Data: data, Errors: ex.errs}
+}
+
+// execution is the state of one Execute call.
+type execution struct {
+	h      *Handler
+	schema *schema
+	doc    *ast.QueryDocument
+	vars   map[string]any
+	errs   gqlerror.List
+}
+
+// selectionSet resolves the fields of set. At the root (parent == nil) each
+// field is resolved by resolveRoot; below it, fields are read from parent,
+// the JSON form of the resolved value. Fields run in document order, so
+// mutations execute serially as the spec requires.
+func (ex *execution) selectionSet(ctx context.Context, set ast.SelectionSet, parent map[string]any, path ast.Path) *orderedMap {
+	out := &orderedMap{}
+	for _, f := range ex.collectFields(set, nil) {
+		key := f.Alias
+		if key == "" {
+			key = f.Name
+		}
+		fpath := append(append(ast.Path{}, path...), ast.PathName(key))
+
+		var val any
+		switch {
+		case f.Name == "__typename":
+			val = f.ObjectDefinition.Name
+		case f.Name == "__schema" || f.Name == "__type":
+			ex.errs = append(ex.errs, gqlerror.ErrorPathf(fpath, "schema introspection is not supported; fetch GET /graphql/schema"))
+		case parent == nil:
+			v, err := ex.resolveRoot(ctx, f)
+			if err != nil {
+				ex.errs = append(ex.errs, gqlerror.ErrorPathf(fpath, "%s", err.Error()))
+			} else {
+				val = ex.complete(ctx, f, toJSON(v), fpath)
+			}
+		default:
+			val = ex.complete(ctx, f, parent[f.Name], fpath)
+		}
+		out.set(key, val)
+	}
+	return out
+}
+
+// complete projects a resolved JSON value onto the field's selection set.
+func (ex *execution) complete(ctx context.Context, f *ast.Field, v any, path ast.Path) any {
+	if len(f.SelectionSet) == 0 || v == nil {
+		return v
+	}
+	switch v := v.(type) {
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = ex.complete(ctx, f, item, append(append(ast.Path{}, path...), ast.PathIndex(i)))
+		}
+		return out
+	case map[string]any:
+		return ex.selectionSet(ctx, f.SelectionSet, v, path)
+	}
+	return v
+}
+
+// collectFields flattens fragments and applies @skip/@include.
+func (ex *execution) collectFields(set ast.SelectionSet, out []*ast.Field) []*ast.Field {
+	for _, sel := range set {
+		switch sel := sel.(type) {
+		case *ast.Field:
+			if ex.included(sel.Directives) {
+				out = append(out, sel)
+			}
+		case *ast.InlineFragment:
+			if ex.included(sel.Directives) {
+				out = ex.collectFields(sel.SelectionSet, out)
+			}
+		case *ast.FragmentSpread:
+			if frag := ex.doc.Fragments.ForName(sel.Name); frag != nil && ex.included(sel.Directives) {
+				out = ex.collectFields(frag.SelectionSet, out)
+			}
+		}
+	}
+	return out
+}
+
+func (ex *execution) included(dirs ast.DirectiveList) bool {
+	if d := dirs.ForName("skip"); d != nil && d.ArgumentMap(ex.vars)["if"] == true {
+		return false
+	}
+	if d := dirs.ForName("include"); d != nil && d.ArgumentMap(ex.vars)["if"] == false {
+		return false
+	}
+	return true
+}
+
+// resolveRoot resolves a Query or Mutation field.
+func (ex *execution) resolveRoot(ctx context.Context, f *ast.Field) (any, error) {
+	args := f.ArgumentMap(ex.vars)
+	if f.ObjectDefinition.Name == "Mutation" {
+		return ex.evaluate(ctx, ex.schema.operations[f.Name], args)
+	}
+
+	c := ex.h.eng.Contract()
+	switch f.Name {
+	case "contract":
+		return map[string]any{
+			"contract_etag": ex.schema.etag,
+			"version":       c.Version,
+			"environment":   c.Environment,
+		}, nil
+	case "facts":
+		return facts(c), nil
+	case "fact":
+		name, _ := args["name"].(string)
+		for _, fi := range facts(c) {
+			if fi.Name == name {
+				return fi, nil
+			}
+		}
+		return nil, nil
+	case "operations":
+		names := make([]string, 0, len(c.Operations))
+		for name := range c.Operations {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		out := make([]map[string]any, 0, len(names))
+		for _, name := range names {
+			op := c.Operations[name]
+			out = append(out, map[string]any{
+				"name":           name,
+				"field":          fieldName(name),
+				"constrained_by": op.ConstrainedBy,
+				"transitions":    op.Transitions,
+			})
+		}
+		return out, nil
+	}
+	return nil, gqlerror.Errorf("unknown field %q", f.Name)
+}
+
+// evaluate runs an operation mutation through the engine.
+func (ex *execution) evaluate(ctx context.Context, operation string, args map[string]any) (*engine.Response, error) {
+	req := &engine.Request{Operation: operation}
+	if input, ok := args["input"].(map[string]any); ok {
+		req.Input = input
+	} else if args["input"] != nil {
+		return nil, gqlerror.Errorf("input must be an object")
+	}
+	req.DryRun, _ = args["dry_run"].(bool)
+	req.Explain, _ = args["explain"].(bool)
+	req.ContractETag, _ = args["contract_etag"].(string)
+	req.VersionRange, _ = args["version_range"].(string)
+	return ex.h.eng.Evaluate(ctx, req)
+}
+
+// factInfo is the Fact query type.
+type factInfo struct {
+	Name       string             `json:"name"`
+	Source     string             `json:"source"`
+	Required   bool               `json:"required"`
+	OnMissing  string             `json:"on_missing,omitempty"`
+	Derivation *engine.Derivation `json:"derivation,omitempty"`
+}
+
+// facts lists c's declared and derived facts by name.
+func facts(c *engine.Contract) []factInfo {
+	out := make([]factInfo, 0, len(c.Facts)+len(c.DerivedFacts))
+	for name, def := range c.Facts {
+		out = append(out, factInfo{Name: name, Source: def.Source, Required: def.Required, OnMissing: def.OnMissing})
+	}
+	for name, def := range c.DerivedFacts {
+		d := def.Derivation
+		out = append(out, factInfo{Name: name, Source: "derived", Derivation: &d})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// toJSON converts a resolved Go value to its generic JSON form, so that
+// field names follow the envelopes' json tags.
+func toJSON(v any) any {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var out any
+	json.Unmarshal(data, &out)
+	return out
+}
+
+// orderedMap is a JSON object that keeps fields in selection order, as
+// GraphQL responses must.
+type orderedMap struct {
+	keys []string
+	vals map[string]any
+}
+
+func (m *orderedMap) set(k string, v any) {
+	if m.vals == nil {
+		m.vals = map[string]any{}
+	}
+	if _, ok := m.vals[k]; !ok {
+		m.keys = append(m.keys, k)
+	}
+	m.vals[k] = v
+}
+
+func (m *orderedMap) MarshalJSON() ([]byte, error) {
+	buf := []byte{'{'}
+	for i, k := range m.keys {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		kb, _ := json.Marshal(k)
+		vb, err := json.Marshal(m.vals[k])
+		if err != nil {
+			return nil, err
+		}
+		buf = append(append(append(buf, kb...), ':'), vb...)
+	}
+	return append(buf, '}'), nil
+}
@@ -0,0 +1,162 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"covenant-poc/executor/engine"
+)
+
+type noPorts struct{}
+
+func (noPorts) Get(context.Context, string, string, map[string]any) (any, error) { return nil, nil }
+func (noPorts) Execute(context.Context, string, string, map[string]any) (map[string]any, error) {
+	return map[string]any{}, nil
+}
+
+// newTestEngine loads a contract whose Pay operation is denied above 100.
+func newTestEngine() *engine.Engine {
+	eng := engine.NewEngine(noPorts{})
+	eng.LoadContract(&engine.Contract{
+		Version: "1.2.0",
+		Facts:   map[string]engine.FactDef{"amount": {Source: "input", Required: true}},
+		DerivedFacts: map[string]engine.DerivedFactDef{
+			"large": {Derivation: engine.Derivation{Fn: "greater_than", Args: []engine.DerivationArg{{Fact: "amount"}, {Value: 50.0}}}},
+		},
+		Rules: []engine.RuleDef{{
+			ID:      "too-large",
+			When:    engine.Condition{Fact: "amount", GreaterThan: 100.0},
+			Verdict: engine.VerdictDef{Deny: &engine.DenyVerdict{Code: "TOO_LARGE", Error: engine.ErrorEnvelope{Code: "TOO_LARGE", HttpStatus: 422, Category: "business_rule"}}},
+		}},
+		Operations: map[string]engine.OperationDef{
+			"Pay":         {ConstrainedBy: []string{"too-large"}},
+			"close-books": {},
+		},
+	}, "etag-1")
+	return eng
+}
+
+func execute(t *testing.T, h *Handler, query string, vars map[string]any) (map[string]any, []any) {
+	t.Helper()
+	data, err := json.Marshal(h.Execute(context.Background(), query, "", vars))
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var res struct {
+		Data   map[string]any `json:"data"`
+		Errors []any          `json:"errors"`
+	}
+	if err := json.Unmarshal(data, &res); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	return res.Data, res.Errors
+}
+
+func TestHandler_mutationEvaluatesOperation(t *testing.T) {
+	h := NewHandler(newTestEngine())
+	data, errs := execute(t, h, `mutation($amt: JSON) {
+		pay: Pay(input: $amt, dry_run: true) { outcome dry_run verdicts { code error { http_status } } }
+	}`, map[string]any{"amt": map[string]any{"amount": 500}})
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	pay := data["pay"].(map[string]any)
+	if pay["outcome"] != "would_deny" || pay["dry_run"] != true {
+		t.Errorf("expected dry-run deny, got %v", pay)
+	}
+	v := pay["verdicts"].([]any)[0].(map[string]any)
+	if v["code"] != "TOO_LARGE" || v["error"].(map[string]any)["http_status"] != 422.0 {
+		t.Errorf("unexpected verdict %v", v)
+	}
+	if _, ok := v["reason"]; ok {
+		t.Errorf("unselected field returned: %v", v)
+	}
+}
+
+func TestHandler_invalidOperationNameSanitized(t *testing.T) {
+	h := NewHandler(newTestEngine())
+	data, errs := execute(t, h, `mutation { close_books { outcome } }`, nil)
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if out := data["close_books"].(map[string]any)["outcome"]; out != "executed" {
+		t.Errorf("expected executed, got %v", out)
+	}
+}
+
+func TestHandler_factQueries(t *testing.T) {
+	h := NewHandler(newTestEngine())
+	data, errs := execute(t, h, `{
+		contract { contract_etag version }
+		facts { name source }
+		large: fact(name: "large") { source derivation }
+		missing: fact(name: "nope") { name }
+		operations { name field constrained_by }
+	}`, nil)
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if c := data["contract"].(map[string]any); c["contract_etag"] != "etag-1" || c["version"] != "1.2.0" {
+		t.Errorf("unexpected contract %v", c)
+	}
+	if n := len(data["facts"].([]any)); n != 2 {
+		t.Errorf("expected 2 facts, got %d", n)
+	}
+	if d := data["large"].(map[string]any); d["source"] != "derived" || d["derivation"].(map[string]any)["fn"] != "greater_than" {
+		t.Errorf("unexpected derived fact %v", d)
+	}
+	if data["missing"] != nil {
+		t.Errorf("expected null for undeclared fact, got %v", data["missing"])
+	}
+	ops := data["operations"].([]any)
+	if op := ops[1].(map[string]any); op["name"] != "close-books" || op["field"] != "close_books" {
+		t.Errorf("unexpected operation %v", op)
+	}
+}
+
+func TestHandler_validationErrors(t *testing.T) {
+	h := NewHandler(newTestEngine())
+	_, errs := execute(t, h, `mutation { Refund { outcome } }`, nil)
+	if len(errs) == 0 {
+		t.Fatal("expected error for unknown operation")
+	}
+}
+
+func TestHandler_schemaFollowsContractReload(t *testing.T) {
+	eng := newTestEngine()
+	h := NewHandler(eng)
+	if _, errs := execute(t, h, `mutation { Pay(input: {amount: 1}) { outcome } }`, nil); len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	eng.LoadContract(&engine.Contract{Operations: map[string]engine.OperationDef{"Refund": {}}}, "etag-2")
+	if _, errs := execute(t, h, `mutation { Pay { outcome } }`, nil); len(errs) == 0 {
+		t.Error("expected Pay to be gone after reload")
+	}
+	if _, errs := execute(t, h, `mutation { Refund { outcome } }`, nil); len(errs) > 0 {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+}
+
+func TestHandler_serveHTTP(t *testing.T) {
+	mux := http.NewServeMux()
+	NewHandler(newTestEngine()).Register(mux)
+
+	body := `{"query": "mutation { a: Pay(input: {amount: 5}) { outcome } b: Pay(input: {amount: 500}) { outcome } }"}`
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("POST", "/graphql", bytes.NewBufferString(body)))
+	if want := `{"data":{"a":{"outcome":"executed"},"b":{"outcome":"denied"}}}`; strings.TrimSpace(rec.Body.String()) != want {
+		t.Errorf("got %s, want %s", rec.Body.String(), want)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/graphql/schema", nil))
+	if !strings.Contains(rec.Body.String(), "Pay(input: JSON, dry_run: Boolean = false") {
+		t.Errorf("schema missing Pay mutation:\n%s", rec.Body.String())
+	}
+}
@@ -0,0 +1,147 @@
+package graphql
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+
+	"covenant-poc/executor/engine"
+)
+
+// staticSDL declares the types shared by every contract's schema. Field
+// names follow the JSON envelopes of POST /execute so both transports read
+// the same.
+const staticSDL = `
+"Arbitrary JSON value."
+scalar JSON
+
+type Query {
+  "The active contract."
+  contract: Contract!
+  "Declared and derived facts."
+  facts: [Fact!]!
+  "A single fact by name, or null if the contract does not declare it."
+  fact(name: String!): Fact
+  "Operations and the rules that constrain them."
+  operations: [Operation!]!
+}
+
+type Contract {
+  contract_etag: String!
+  version: String
+  environment: String
+}
+
+type Fact {
+  name: String!
+  "input, ctx, port:<name>, param, or derived."
+  source: String!
+  required: Boolean!
+  on_missing: String
+  derivation: JSON
+}
+
+type Operation {
+  name: String!
+  "The operation's mutation field, which differs from name only when name is not a valid GraphQL name."
+  field: String!
+  constrained_by: [String!]!
+  transitions: JSON
+}
+
+type Response {
+  invocation_id: String
+  outcome: String!
+  output: JSON
+  error: Error
+  verdicts: [Verdict!]
+  fact_snapshot: JSON
+  dry_run: Boolean
+  contract_semver: String
+  side_effects_isolated: Boolean
+  explain: JSON
+}
+
+type Error {
+  code: String!
+  message: String!
+  http_status: Int!
+  category: String!
+  retryable: Boolean!
+  suggestion: String
+  details: JSON
+}
+
+type Verdict {
+  rule: String
+  type: String!
+  code: String
+  reason: String
+  error: Error
+  queue: String
+  metadata: JSON
+}
+`
+
+// mutationArgs are the arguments of every operation mutation, mirroring
+// engine.Request.
+const mutationArgs = `(input: JSON, dry_run: Boolean = false, explain: Boolean = false, contract_etag: String, version_range: String)`
+
+var invalidNameChars = regexp.MustCompile(`[^_0-9A-Za-z]`)
+
+// fieldName maps an operation name to a valid GraphQL field name.
+func fieldName(op string) string {
+	name := invalidNameChars.ReplaceAllString(op, "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') || strings.HasPrefix(name, "__") {
+		name = "op_" + name
+	}
+	return name
+}
+
+// schema is the GraphQL schema generated for one loaded contract.
+type schema struct {
+	etag   string
+	schema *ast.Schema
+	sdl    string
+	// operations maps mutation field names back to contract operations.
+	operations map[string]string
+}
+
+// buildSchema generates the schema for c: one mutation per operation plus
+// the fixed introspection queries.
+func buildSchema(c *engine.Contract, etag string) (*schema, error) {
+	s := &schema{etag: etag, operations: map[string]string{}}
+
+	ops := make([]string, 0, len(c.Operations))
+	for name := range c.Operations {
+		ops = append(ops, name)
+	}
+	sort.Strings(ops)
+
+	var b strings.Builder
+	b.WriteString(staticSDL)
+	if len(ops) > 0 {
+		b.WriteString("\ntype Mutation {\n")
+		for _, op := range ops {
+			field := fieldName(op)
+			if prev, dup := s.operations[field]; dup {
+				return nil, fmt.Errorf("operations %q and %q map to the same GraphQL field %q", prev, op, field)
+			}
+			s.operations[field] = op
+			fmt.Fprintf(&b, "  %q\n  %s%s: Response\n", "Evaluate operation "+op+".", field, mutationArgs)
+		}
+		b.WriteString("}\n")
+	}
+	s.sdl = b.String()
+
+	sch, err := gqlparser.LoadSchema(&ast.Source{Name: "contract.graphql", Input: s.sdl})
+	if err != nil {
+		return nil, err
+	}
+	s.schema = sch
+	return s, nil
+}
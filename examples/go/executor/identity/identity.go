@@ -0,0 +1,181 @@
+// Package identity derives a caller's ctx facts — user.subject, user.roles,
+// user.scopes and user.tenant — from the credentials it presented, so
+// authorize blocks and the identity operators judge a verified identity
+// rather than what a request body claims.
+//
+// Providers cover OIDC tokens and SPIFFE IDs. Middleware runs one on every
+// request and hands the facts to the engine with engine.WithCallerFacts.
+package identity
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"covenant-poc/executor/engine"
+	"covenant-poc/executor/rbac"
+)
+
+// ErrNoCredentials is returned by a provider when the request carries no
+// credentials of its kind.
+var ErrNoCredentials = errors.New("no credentials")
+
+// Provider derives ctx facts from a request's credentials.
+type Provider interface {
+	Facts(r *http.Request) (map[string]any, error)
+}
+
+// ProviderFunc adapts a function to Provider.
+type ProviderFunc func(r *http.Request) (map[string]any, error)
+
+func (f ProviderFunc) Facts(r *http.Request) (map[string]any, error) { return f(r) }
+
+// First returns a provider answering with the first of ps that finds
+// credentials in the request. A request with credentials that fail to
+// verify is refused even if a later provider would accept it.
+func First(ps ...Provider) Provider {
+	return ProviderFunc(func(r *http.Request) (map[string]any, error) {
+		for _, p := range ps {
+			facts, err := p.Facts(r)
+			if !errors.Is(err, ErrNoCredentials) {
+				return facts, err
+			}
+		}
+		return nil, ErrNoCredentials
+	})
+}
+
+// Middleware derives each request's ctx facts with p and attaches them to
+// its context for the engine. A request whose credentials are missing or
+// invalid is served with no ctx facts, so authorize blocks refuse it.
+func Middleware(p Provider, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		facts, err := p.Facts(r)
+		if err != nil {
+			facts = nil
+		}
+		h.ServeHTTP(w, r.WithContext(engine.WithCallerFacts(r.Context(), facts)))
+	})
+}
+
+// OIDC maps the claims of an OIDC access or ID token to ctx facts: sub to
+// user.subject, the roles claim to user.roles, scope (space-separated) or
+// scp to user.scopes, and the tenant claim to user.tenant.
+type OIDC struct {
+	// Claims verifies the token in the request's Authorization header and
+	// returns its claims, e.g. (*rbac.Authorizer).Claims.
+	Claims func(r *http.Request) (map[string]any, error)
+
+	RolesClaim  string // default "roles"
+	TenantClaim string // default "tenant"
+}
+
+func (o OIDC) Facts(r *http.Request) (map[string]any, error) {
+	if r.Header.Get("Authorization") == "" {
+		return nil, ErrNoCredentials
+	}
+	claims, err := o.Claims(r)
+	if err != nil {
+		return nil, err
+	}
+	return o.ClaimFacts(claims)
+}
+
+// ClaimFacts maps verified claims to ctx facts.
+func (o OIDC) ClaimFacts(claims map[string]any) (map[string]any, error) {
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return nil, errors.New("token has no sub claim")
+	}
+	facts := map[string]any{
+		engine.FactSubject: sub,
+		engine.FactRoles:   rbac.ClaimValues(claims[cmp.Or(o.RolesClaim, "roles")]),
+	}
+	scopes := rbac.ClaimValues(claims["scp"])
+	if s, ok := claims["scope"].(string); ok {
+		scopes = strings.Fields(s)
+	}
+	facts[engine.FactScopes] = scopes
+	if tenant, ok := claims[cmp.Or(o.TenantClaim, "tenant")].(string); ok && tenant != "" {
+		facts[engine.FactTenant] = tenant
+	}
+	return facts, nil
+}
+
+// SPIFFE maps a workload's SPIFFE ID, spiffe://<trust domain>/<path>, to
+// user.subject, and its trust domain to user.tenant. The ID is read from
+// the URI SAN of the TLS client certificate.
+type SPIFFE struct {
+	// TrustDomains, if set, are the trust domains accepted.
+	TrustDomains []string
+
+	// Forwarded reads the ID from the X-Forwarded-Client-Cert header when
+	// the connection has no client certificate, for servers behind a proxy
+	// such as Envoy that terminates mTLS. Only set it if every request
+	// reaches the server through that proxy, since anyone else could set
+	// the header.
+	Forwarded bool
+}
+
+func (s SPIFFE) Facts(r *http.Request) (map[string]any, error) {
+	var id string
+	switch {
+	case r.TLS != nil && len(r.TLS.PeerCertificates) > 0:
+		for _, u := range r.TLS.PeerCertificates[0].URIs {
+			if u.Scheme == "spiffe" {
+				id = u.String()
+				break
+			}
+		}
+	case s.Forwarded && r.Header.Get("X-Forwarded-Client-Cert") != "":
+		id = forwardedURI(r.Header.Get("X-Forwarded-Client-Cert"))
+	}
+	if id == "" {
+		return nil, ErrNoCredentials
+	}
+	u, err := url.Parse(id)
+	if err != nil || u.Scheme != "spiffe" || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+		return nil, fmt.Errorf("invalid SPIFFE ID %q", id)
+	}
+	if len(s.TrustDomains) > 0 && !slices.Contains(s.TrustDomains, u.Host) {
+		return nil, fmt.Errorf("SPIFFE ID %s is not in an accepted trust domain", id)
+	}
+	return map[string]any{engine.FactSubject: id, engine.FactTenant: u.Host}, nil
+}
+
+// forwardedURI returns the URI field of the last element of an
+// X-Forwarded-Client-Cert header, the client certificate the nearest proxy
+// saw. Elements are separated by commas and fields by semicolons, outside
+// double quotes.
+func forwardedURI(header string) string {
+	elements := split(header, ',')
+	for _, field := range split(elements[len(elements)-1], ';') {
+		key, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+		if strings.EqualFold(key, "URI") {
+			return strings.Trim(value, `"`)
+		}
+	}
+	return ""
+}
+
+// split splits s at sep, except inside double quotes.
+func split(s string, sep byte) []string {
+	var parts []string
+	quoted, start := false, 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quoted:
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
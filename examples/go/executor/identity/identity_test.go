@@ -0,0 +1,100 @@
+package identity
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"covenant-poc/executor/engine"
+)
+
+func TestOIDC_mapsClaimsToCtxFacts(t *testing.T) {
+	o := OIDC{TenantClaim: "tid"}
+	facts, err := o.ClaimFacts(map[string]any{
+		"sub":   "alice",
+		"roles": []any{"billing_admin", "support"},
+		"scope": "invoices:read payments:write",
+		"tid":   "acme",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		engine.FactSubject: "alice",
+		engine.FactRoles:   []string{"billing_admin", "support"},
+		engine.FactScopes:  []string{"invoices:read", "payments:write"},
+		engine.FactTenant:  "acme",
+	}
+	if !reflect.DeepEqual(facts, want) {
+		t.Errorf("got %v", facts)
+	}
+
+	facts, _ = o.ClaimFacts(map[string]any{"sub": "svc", "scp": []any{"invoices:read"}})
+	if !reflect.DeepEqual(facts[engine.FactScopes], []string{"invoices:read"}) || facts[engine.FactTenant] != nil {
+		t.Errorf("expected scp read as scopes and no tenant, got %v", facts)
+	}
+	if _, err := o.ClaimFacts(map[string]any{"roles": "admin"}); err == nil {
+		t.Error("expected a token without sub refused")
+	}
+}
+
+func TestSPIFFE_readsIDFromCertificateOrForwardedHeader(t *testing.T) {
+	id, _ := url.Parse("spiffe://acme.com/ns/billing/sa/worker")
+	r := httptest.NewRequest(http.MethodPost, "/execute", nil)
+	r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{URIs: []*url.URL{id}}}}
+	facts, err := SPIFFE{TrustDomains: []string{"acme.com"}}.Facts(r)
+	if err != nil || facts[engine.FactSubject] != id.String() || facts[engine.FactTenant] != "acme.com" {
+		t.Errorf("got %v, %v", facts, err)
+	}
+	if _, err := (SPIFFE{TrustDomains: []string{"globex.com"}}).Facts(r); err == nil {
+		t.Error("expected an ID outside the trust domains refused")
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/execute", nil)
+	r.Header.Set("X-Forwarded-Client-Cert",
+		`By=spiffe://acme.com/gw;URI=spiffe://acme.com/ns/a,By=spiffe://acme.com/ns/billing;Hash=ab;Subject="CN=w,O=Acme";URI=spiffe://acme.com/ns/billing/sa/worker`)
+	if _, err := (SPIFFE{}).Facts(r); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("expected the header ignored unless forwarded IDs are trusted, got %v", err)
+	}
+	facts, err = SPIFFE{Forwarded: true}.Facts(r)
+	if err != nil || facts[engine.FactSubject] != "spiffe://acme.com/ns/billing/sa/worker" {
+		t.Errorf("expected the nearest proxy's client, got %v, %v", facts, err)
+	}
+}
+
+func TestMiddleware_attachesFactsAndDropsInvalidCredentials(t *testing.T) {
+	verified := OIDC{Claims: func(r *http.Request) (map[string]any, error) {
+		if r.Header.Get("Authorization") != "Bearer good" {
+			return nil, errors.New("invalid token")
+		}
+		return map[string]any{"sub": "alice", "roles": "billing_admin"}, nil
+	}}
+	var got map[string]any
+	var present bool
+	h := Middleware(First(SPIFFE{Forwarded: true}, verified), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, present = engine.CallerFacts(r.Context())
+	}))
+
+	for _, tc := range []struct {
+		auth    string
+		subject any
+	}{
+		{"Bearer good", "alice"},
+		{"Bearer forged", nil},
+		{"", nil},
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/execute", nil)
+		if tc.auth != "" {
+			r.Header.Set("Authorization", tc.auth)
+		}
+		h.ServeHTTP(httptest.NewRecorder(), r)
+		if !present || got[engine.FactSubject] != tc.subject {
+			t.Errorf("%q: got %v (present %v)", tc.auth, got, present)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+// Package jsonrpc serves contract operations over JSON-RPC 2.0.
+//
+// Each contract operation is a method. Params are the fields of the
+// POST /execute request other than operation — input, dry_run, explain,
+// contract_etag, version_range — and the result is the same Response
+// envelope, so denials, escalations and negotiation errors arrive as results
+// carrying their usual outcome and error codes. JSON-RPC errors are reserved
+// for requests that could not be evaluated at all. Batches and notifications
+// are supported.
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+
+	"covenant-poc/executor/engine"
+)
+
+// Standard JSON-RPC 2.0 error codes.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+// Evaluator is the part of *engine.Engine the handler uses.
+type Evaluator interface {
+	Evaluate(ctx context.Context, req *engine.Request) (*engine.Response, error)
+}
+
+// Request is a JSON-RPC 2.0 request. A request without an ID is a
+// notification and gets no response.
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+// Response is a JSON-RPC 2.0 response; exactly one of Result and Error is set.
+type Response struct {
+	JSONRPC string           `json:"jsonrpc"`
+	Result  *engine.Response `json:"result,omitempty"`
+	Error   *Error           `json:"error,omitempty"`
+	ID      json.RawMessage  `json:"id"`
+}
+
+// Error is a JSON-RPC 2.0 error object.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Handler serves JSON-RPC requests.
+type Handler struct {
+	eng Evaluator
+}
+
+// NewHandler returns a Handler evaluating against eng.
+func NewHandler(eng Evaluator) *Handler {
+	return &Handler{eng: eng}
+}
+
+// ServeHTTP handles a single request or a batch.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var out any
+	trimmed := bytes.TrimLeft(body, " \t\r\n")
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(body, &batch); err != nil {
+			out = errorResponse(nil, CodeParseError, "parse error: "+err.Error())
+		} else if len(batch) == 0 {
+			out = errorResponse(nil, CodeInvalidRequest, "empty batch")
+		} else if resps := h.batch(r.Context(), batch); len(resps) > 0 {
+			out = resps
+		}
+	} else if resp := h.call(r.Context(), body); resp != nil {
+		out = resp
+	}
+
+	if out == nil {
+		// Only notifications: nothing to return.
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
+
+// batch evaluates the calls of a batch concurrently, returning responses in
+// request order with notifications omitted.
+func (h *Handler) batch(ctx context.Context, calls []json.RawMessage) []*Response {
+	resps := make([]*Response, len(calls))
+	var wg sync.WaitGroup
+	for i, raw := range calls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resps[i] = h.call(ctx, raw)
+		}()
+	}
+	wg.Wait()
+
+	out := resps[:0]
+	for _, r := range resps {
+		if r != nil {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// call evaluates one request, returning nil for a notification.
+func (h *Handler) call(ctx context.Context, raw json.RawMessage) *Response {
+	var req Request
+	if err := json.Unmarshal(raw, &req); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return errorResponse(nil, CodeParseError, "parse error: "+err.Error())
+		}
+		return errorResponse(nil, CodeInvalidRequest, "invalid request: "+err.Error())
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return errorResponse(req.ID, CodeInvalidRequest, `invalid request: jsonrpc must be "2.0" and method is required`)
+	}
+
+	resp := h.evaluate(ctx, &req)
+	if req.ID == nil {
+		return nil
+	}
+	resp.ID = req.ID
+	return resp
+}
+
+func (h *Handler) evaluate(ctx context.Context, req *Request) *Response {
+	var er engine.Request
+	if len(req.Params) > 0 && !bytes.Equal(req.Params, []byte("null")) {
+		dec := json.NewDecoder(bytes.NewReader(req.Params))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&er); err != nil {
+			return errorResponse(nil, CodeInvalidParams, "invalid params: params must be an object of execute request fields: "+err.Error())
+		}
+	}
+	er.Operation = req.Method
+
+	resp, err := h.eng.Evaluate(ctx, &er)
+	switch {
+	case errors.Is(err, engine.ErrUnknownOperation):
+		return errorResponse(nil, CodeMethodNotFound, err.Error())
+	case errors.Is(err, engine.ErrUnknownSession):
+		return errorResponse(nil, CodeInvalidParams, err.Error())
+	case err != nil:
+		return errorResponse(nil, CodeInternalError, err.Error())
+	}
+	return &Response{JSONRPC: "2.0", Result: resp}
+}
+
+func errorResponse(id json.RawMessage, code int, msg string) *Response {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return &Response{JSONRPC: "2.0", Error: &Error{Code: code, Message: msg}, ID: id}
+}
@@ -0,0 +1,138 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"covenant-poc/executor/engine"
+)
+
+type noPorts struct{}
+
+func (noPorts) Get(context.Context, string, string, map[string]any) (any, error) { return nil, nil }
+func (noPorts) Execute(context.Context, string, string, map[string]any) (map[string]any, error) {
+	return map[string]any{}, nil
+}
+
+// newTestEngine loads a contract whose Pay operation is denied above 100.
+func newTestEngine() *engine.Engine {
+	eng := engine.NewEngine(noPorts{})
+	eng.LoadContract(&engine.Contract{
+		Facts: map[string]engine.FactDef{"amount": {Source: "input", Required: true}},
+		Rules: []engine.RuleDef{{
+			ID:      "too-large",
+			When:    engine.Condition{Fact: "amount", GreaterThan: 100.0},
+			Verdict: engine.VerdictDef{Deny: &engine.DenyVerdict{Code: "TOO_LARGE"}},
+		}},
+		Operations: map[string]engine.OperationDef{"Pay": {ConstrainedBy: []string{"too-large"}}},
+	}, "etag-1")
+	return eng
+}
+
+func post(t *testing.T, h *Handler, body string) (int, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/rpc", strings.NewReader(body)))
+	return rec.Code, strings.TrimSpace(rec.Body.String())
+}
+
+func TestHandler_singleCallReturnsExecuteResponse(t *testing.T) {
+	h := NewHandler(newTestEngine())
+	_, body := post(t, h, `{"jsonrpc": "2.0", "method": "Pay", "params": {"input": {"amount": 500}, "dry_run": true}, "id": "a"}`)
+
+	var resp Response
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		t.Fatalf("unmarshal %s: %v", body, err)
+	}
+	if resp.Error != nil || string(resp.ID) != `"a"` {
+		t.Fatalf("unexpected response %s", body)
+	}
+	if resp.Result.Outcome != "would_deny" || resp.Result.Verdicts[0].Code != "TOO_LARGE" {
+		t.Errorf("expected would_deny TOO_LARGE, got %+v", resp.Result)
+	}
+}
+
+func TestHandler_contractErrorsAreResults(t *testing.T) {
+	h := NewHandler(newTestEngine())
+	_, body := post(t, h, `{"jsonrpc": "2.0", "method": "Pay", "params": {"input": {"amount": 1}, "contract_etag": "stale"}, "id": 1}`)
+	if !strings.Contains(body, `"result":{`) || !strings.Contains(body, `"code":"CONTRACT_VERSION_MISMATCH"`) {
+		t.Errorf("expected mismatch as result, got %s", body)
+	}
+}
+
+func TestHandler_errorCodes(t *testing.T) {
+	h := NewHandler(newTestEngine())
+	tests := []struct {
+		body string
+		code int
+	}{
+		{`{"jsonrpc": "2.0", "method": "Pay", "id": 1`, CodeParseError},
+		{`{"jsonrpc": "1.0", "method": "Pay", "id": 1}`, CodeInvalidRequest},
+		{`{"jsonrpc": "2.0", "method": "Refund", "id": 1}`, CodeMethodNotFound},
+		{`{"jsonrpc": "2.0", "method": "Pay", "params": [500], "id": 1}`, CodeInvalidParams},
+		{`{"jsonrpc": "2.0", "method": "Pay", "params": {"amount": 500}, "id": 1}`, CodeInvalidParams},
+		{`{"jsonrpc": "2.0", "method": "Pay", "id": 1}`, CodeInternalError}, // required input missing
+		{`[]`, CodeInvalidRequest},
+	}
+	for _, tt := range tests {
+		_, body := post(t, h, tt.body)
+		var resp Response
+		if err := json.Unmarshal([]byte(body), &resp); err != nil {
+			t.Fatalf("%s: unmarshal %s: %v", tt.body, body, err)
+		}
+		if resp.Error == nil || resp.Error.Code != tt.code {
+			t.Errorf("%s: expected error %d, got %s", tt.body, tt.code, body)
+		}
+	}
+}
+
+func TestHandler_batchKeepsOrderAndOmitsNotifications(t *testing.T) {
+	h := NewHandler(newTestEngine())
+	_, body := post(t, h, `[
+		{"jsonrpc": "2.0", "method": "Pay", "params": {"input": {"amount": 500}}, "id": 1},
+		{"jsonrpc": "2.0", "method": "Pay", "params": {"input": {"amount": 5}}},
+		{"jsonrpc": "2.0", "method": "Refund", "id": 2},
+		{"jsonrpc": "2.0", "method": "Pay", "params": {"input": {"amount": 5}}, "id": 3}
+	]`)
+
+	var resps []Response
+	if err := json.Unmarshal([]byte(body), &resps); err != nil {
+		t.Fatalf("unmarshal %s: %v", body, err)
+	}
+	if len(resps) != 3 {
+		t.Fatalf("expected 3 responses, got %s", body)
+	}
+	if string(resps[0].ID) != "1" || resps[0].Result.Outcome != "denied" {
+		t.Errorf("unexpected first response %s", body)
+	}
+	if string(resps[1].ID) != "2" || resps[1].Error.Code != CodeMethodNotFound {
+		t.Errorf("unexpected second response %s", body)
+	}
+	if string(resps[2].ID) != "3" || resps[2].Result.Outcome != "executed" {
+		t.Errorf("unexpected third response %s", body)
+	}
+}
+
+// countingEvaluator counts evaluations.
+type countingEvaluator struct{ n atomic.Int32 }
+
+func (c *countingEvaluator) Evaluate(context.Context, *engine.Request) (*engine.Response, error) {
+	c.n.Add(1)
+	return &engine.Response{Outcome: "executed"}, nil
+}
+
+func TestHandler_notificationsEvaluateWithoutResponse(t *testing.T) {
+	eval := &countingEvaluator{}
+	h := NewHandler(eval)
+	status, body := post(t, h, `[{"jsonrpc": "2.0", "method": "Pay"}, {"jsonrpc": "2.0", "method": "Pay"}]`)
+	if status != 204 || body != "" {
+		t.Errorf("expected 204 with no body, got %d %q", status, body)
+	}
+	if eval.n.Load() != 2 {
+		t.Errorf("expected 2 evaluations, got %d", eval.n.Load())
+	}
+}
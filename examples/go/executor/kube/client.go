@@ -0,0 +1,125 @@
+// Package kube reads covenant contracts from a Kubernetes cluster, from
+// ConfigMaps or Contract custom resources, so GitOps pipelines can manage
+// contracts as cluster objects and executors reload them as they change.
+// It speaks the API server's list and watch protocol directly rather than
+// through client-go.
+package kube
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// serviceAccountDir holds the credentials Kubernetes mounts into pods.
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// Client makes requests to a Kubernetes API server.
+type Client struct {
+	base  string
+	token string
+	http  *http.Client
+}
+
+// NewClient returns a client for the API server at baseURL, such as a
+// kubectl proxy at http://localhost:8001, authenticating with token if it
+// is not empty.
+func NewClient(baseURL, token string) *Client {
+	return &Client{base: strings.TrimSuffix(baseURL, "/"), token: token, http: &http.Client{}}
+}
+
+// InCluster returns a client for the API server of the cluster the process
+// runs in, authenticating as the pod's service account.
+func InCluster() (*Client, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("not running in a Kubernetes cluster: KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT are not set")
+	}
+	token, err := os.ReadFile(serviceAccountDir + "/token")
+	if err != nil {
+		return nil, err
+	}
+	ca, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("%s/ca.crt: no certificates", serviceAccountDir)
+	}
+	c := NewClient("https://"+net.JoinHostPort(host, port), strings.TrimSpace(string(token)))
+	c.http.Transport = &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}
+	return c, nil
+}
+
+// Object is the part of a Kubernetes object a source reads.
+type Object struct {
+	Metadata Metadata `json:"metadata"`
+	// Data is a ConfigMap's data.
+	Data map[string]string `json:"data,omitempty"`
+	// Spec is a custom resource's spec.
+	Spec json.RawMessage `json:"spec,omitempty"`
+}
+
+// Metadata is an object's metadata.
+type Metadata struct {
+	Name            string            `json:"name"`
+	Namespace       string            `json:"namespace,omitempty"`
+	ResourceVersion string            `json:"resourceVersion,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty"`
+}
+
+// list is a list response.
+type list struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	Items []Object `json:"items"`
+}
+
+// StatusError is a failed request's Status.
+type StatusError struct {
+	Code    int    `json:"code"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("kubernetes: %d %s: %s", e.Code, e.Reason, e.Message)
+}
+
+// get sends a GET for path with the given query, returning the response
+// body of a 200 and a *StatusError otherwise.
+func (c *Client) get(ctx context.Context, path, query string) (io.ReadCloser, error) {
+	u := c.base + path
+	if query != "" {
+		u += "?" + query
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		status := &StatusError{Code: resp.StatusCode, Reason: resp.Status}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		json.Unmarshal(body, status)
+		return nil, status
+	}
+	return resp.Body, nil
+}
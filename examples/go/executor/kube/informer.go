@@ -0,0 +1,194 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxBackoff caps the wait between failed lists or watches.
+const maxBackoff = 30 * time.Second
+
+// Informer mirrors the objects of one collection, such as the ConfigMaps
+// of a namespace, by listing them and then watching for changes. A watch
+// that fails is resumed from the last version seen, and the collection is
+// listed again if the server no longer has that version.
+type Informer struct {
+	client   *Client
+	path     string
+	selector string
+
+	mu      sync.RWMutex
+	objects map[string]Object // by name
+	synced  chan struct{}     // closed once the first list is in
+	once    sync.Once
+	changes chan struct{}
+}
+
+// NewInformer returns an informer for the collection at path, e.g.
+// /api/v1/namespaces/covenant/configmaps, limited to objects matching the
+// label selector if it is not empty. Call Run to start it.
+func NewInformer(c *Client, path, labelSelector string) *Informer {
+	return &Informer{
+		client:   c,
+		path:     path,
+		selector: labelSelector,
+		objects:  map[string]Object{},
+		synced:   make(chan struct{}),
+		changes:  make(chan struct{}, 1),
+	}
+}
+
+// Run lists and watches until ctx is done.
+func (i *Informer) Run(ctx context.Context) {
+	backoff := time.Second
+	for ctx.Err() == nil {
+		version, err := i.list(ctx)
+		for err == nil {
+			version, err = i.watch(ctx, version)
+			backoff = time.Second
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("kube: %s: %v", i.path, err)
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxBackoff)
+	}
+}
+
+// WaitSynced waits for the first list.
+func (i *Informer) WaitSynced(ctx context.Context) error {
+	select {
+	case <-i.synced:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Objects returns the objects, by name.
+func (i *Informer) Objects() []Object {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	out := make([]Object, 0, len(i.objects))
+	for _, o := range i.objects {
+		out = append(out, o)
+	}
+	slices.SortFunc(out, func(a, b Object) int { return strings.Compare(a.Metadata.Name, b.Metadata.Name) })
+	return out
+}
+
+// Changes receives a value after the objects change. Changes in quick
+// succession may be coalesced into one.
+func (i *Informer) Changes() <-chan struct{} { return i.changes }
+
+func (i *Informer) changed() {
+	select {
+	case i.changes <- struct{}{}:
+	default:
+	}
+}
+
+func (i *Informer) query(v url.Values) string {
+	if i.selector != "" {
+		v.Set("labelSelector", i.selector)
+	}
+	return v.Encode()
+}
+
+// list replaces the objects with the collection's, returning its version.
+func (i *Informer) list(ctx context.Context) (string, error) {
+	body, err := i.client.get(ctx, i.path, i.query(url.Values{}))
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	var l list
+	if err := json.NewDecoder(body).Decode(&l); err != nil {
+		return "", err
+	}
+	objects := make(map[string]Object, len(l.Items))
+	for _, o := range l.Items {
+		objects[o.Metadata.Name] = o
+	}
+	i.mu.Lock()
+	i.objects = objects
+	i.mu.Unlock()
+	i.once.Do(func() { close(i.synced) })
+	i.changed()
+	return l.Metadata.ResourceVersion, nil
+}
+
+// event is one watch event.
+type event struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+// errGone is a watch from a version the server no longer has.
+var errGone = errors.New("resource version too old")
+
+// watch applies changes from version on until the server ends the watch,
+// returning the last version seen. A version the server no longer has is
+// an error, so the collection is listed again.
+func (i *Informer) watch(ctx context.Context, version string) (string, error) {
+	body, err := i.client.get(ctx, i.path, i.query(url.Values{
+		"watch":               {"1"},
+		"resourceVersion":     {version},
+		"allowWatchBookmarks": {"true"},
+	}))
+	if err != nil {
+		var status *StatusError
+		if errors.As(err, &status) && status.Code == http.StatusGone {
+			return "", errGone
+		}
+		return "", err
+	}
+	defer body.Close()
+	dec := json.NewDecoder(body)
+	for {
+		var ev event
+		if err := dec.Decode(&ev); err != nil {
+			if ctx.Err() != nil {
+				return "", ctx.Err()
+			}
+			// The server ends watches after a timeout; carry on from here.
+			return version, nil
+		}
+		if ev.Type == "ERROR" {
+			var status StatusError
+			json.Unmarshal(ev.Object, &status)
+			if status.Code == http.StatusGone {
+				return "", errGone
+			}
+			return "", &status
+		}
+		var o Object
+		if err := json.Unmarshal(ev.Object, &o); err != nil {
+			return "", err
+		}
+		version = o.Metadata.ResourceVersion
+		i.mu.Lock()
+		switch ev.Type {
+		case "ADDED", "MODIFIED":
+			i.objects[o.Metadata.Name] = o
+		case "DELETED":
+			delete(i.objects, o.Metadata.Name)
+		}
+		i.mu.Unlock()
+		if ev.Type != "BOOKMARK" {
+			i.changed()
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package kube
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+
+	"covenant-poc/executor/engine"
+)
+
+// Kinds of object a Source reads contracts from.
+const (
+	// ConfigMaps are ConfigMaps labeled with DomainLabel. Each data key
+	// ending in .cue is a contract file of the labeled domain, and each
+	// bindings.<env>.json key the domain's param bindings for env.
+	ConfigMaps = "configmaps"
+	// Contracts are Contract custom resources (covenant.dev/v1alpha1), as
+	// defined by contracts.covenant.dev.yaml.
+	Contracts = "contracts"
+)
+
+// DomainLabel names the domain of a ConfigMap's contract files.
+const DomainLabel = "covenant.dev/domain"
+
+// ContractSpec is the spec of a Contract custom resource, e.g.
+//
+//	spec:
+//	  domain: billing
+//	  files:
+//	    payments.cue: |
+//	      ...
+//	  bindings:
+//	    prod: {large_payment_threshold: 15000}
+//
+// Domain defaults to the resource's name.
+type ContractSpec struct {
+	Domain   string                    `json:"domain,omitempty"`
+	Files    map[string]string         `json:"files"`
+	Bindings map[string]map[string]any `json:"bindings,omitempty"`
+}
+
+// SourceConfig says where a Source finds its contract.
+type SourceConfig struct {
+	Kind      string // ConfigMaps or Contracts
+	Namespace string
+	Domain    string // the domain to serve; others are bases it may import
+}
+
+// ParseSourceURL parses a contract source URL,
+// kube://<kind>/<namespace>?domain=<domain>, e.g.
+// kube://configmaps/covenant?domain=billing.
+func ParseSourceURL(raw string) (SourceConfig, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return SourceConfig{}, err
+	}
+	cfg := SourceConfig{Kind: u.Host, Namespace: strings.Trim(u.Path, "/"), Domain: u.Query().Get("domain")}
+	switch {
+	case u.Scheme != "kube":
+		return SourceConfig{}, fmt.Errorf("%s: not a kube:// URL", raw)
+	case cfg.Kind != ConfigMaps && cfg.Kind != Contracts:
+		return SourceConfig{}, fmt.Errorf("%s: kind %q is neither %s nor %s", raw, cfg.Kind, ConfigMaps, Contracts)
+	case cfg.Namespace == "" || strings.Contains(cfg.Namespace, "/"):
+		return SourceConfig{}, fmt.Errorf("%s: expected kube://%s/<namespace>", raw, cfg.Kind)
+	case cfg.Domain == "":
+		return SourceConfig{}, fmt.Errorf("%s: no ?domain=", raw)
+	}
+	return cfg, nil
+}
+
+// Source compiles a domain's contract from the cluster objects holding its
+// files and those of the bases it imports. Its ETag is over the checksums
+// of every file read, as the contract server's is, so it changes whenever
+// any of them does.
+type Source struct {
+	cfg      SourceConfig
+	informer *Informer
+}
+
+// NewSource returns a source reading through c. Call Run to start it.
+func NewSource(c *Client, cfg SourceConfig) *Source {
+	path := "/api/v1/namespaces/" + cfg.Namespace + "/configmaps"
+	selector := DomainLabel
+	if cfg.Kind == Contracts {
+		path = "/apis/covenant.dev/v1alpha1/namespaces/" + cfg.Namespace + "/contracts"
+		selector = ""
+	}
+	return &Source{cfg: cfg, informer: NewInformer(c, path, selector)}
+}
+
+// Run watches the cluster until ctx is done.
+func (s *Source) Run(ctx context.Context) { s.informer.Run(ctx) }
+
+// WaitSynced waits until the objects have been listed.
+func (s *Source) WaitSynced(ctx context.Context) error { return s.informer.WaitSynced(ctx) }
+
+// Changes receives a value after the objects change; the contract may
+// have changed.
+func (s *Source) Changes() <-chan struct{} { return s.informer.Changes() }
+
+// domainFiles is what the objects hold for each domain.
+type domainFiles struct {
+	files    map[string]map[string][]byte         // domain → file → content
+	bindings map[string]map[string]map[string]any // domain → env → values
+}
+
+// read gathers the objects' files by domain. A file or binding declared by
+// two objects is an error.
+func (s *Source) read() (*domainFiles, error) {
+	df := &domainFiles{files: map[string]map[string][]byte{}, bindings: map[string]map[string]map[string]any{}}
+	from := map[string]string{} // domain/file → object declaring it
+	add := func(obj, domain, name string, data []byte) error {
+		key := domain + "/" + name
+		if other, ok := from[key]; ok {
+			return fmt.Errorf("%s: declared by both %s and %s", key, other, obj)
+		}
+		from[key] = obj
+		if df.files[domain] == nil {
+			df.files[domain] = map[string][]byte{}
+		}
+		df.files[domain][name] = data
+		return nil
+	}
+	bind := func(obj, domain, env string, values map[string]any) error {
+		key := domain + "/bindings/" + env
+		if other, ok := from[key]; ok {
+			return fmt.Errorf("%s: declared by both %s and %s", key, other, obj)
+		}
+		from[key] = obj
+		if df.bindings[domain] == nil {
+			df.bindings[domain] = map[string]map[string]any{}
+		}
+		df.bindings[domain][env] = values
+		return nil
+	}
+	for _, o := range s.informer.Objects() {
+		obj := s.cfg.Kind + "/" + o.Metadata.Name
+		if s.cfg.Kind == ConfigMaps {
+			domain := o.Metadata.Labels[DomainLabel]
+			for _, key := range slices.Sorted(maps.Keys(o.Data)) {
+				switch {
+				case strings.HasSuffix(key, ".cue"):
+					if err := add(obj, domain, key, []byte(o.Data[key])); err != nil {
+						return nil, err
+					}
+				case strings.HasPrefix(key, "bindings.") && strings.HasSuffix(key, ".json"):
+					env := strings.TrimSuffix(strings.TrimPrefix(key, "bindings."), ".json")
+					var values map[string]any
+					if err := json.Unmarshal([]byte(o.Data[key]), &values); err != nil {
+						return nil, fmt.Errorf("%s: %s: %w", obj, key, err)
+					}
+					if err := bind(obj, domain, env, values); err != nil {
+						return nil, err
+					}
+				}
+			}
+			continue
+		}
+		var spec ContractSpec
+		if err := json.Unmarshal(o.Spec, &spec); err != nil {
+			return nil, fmt.Errorf("%s: spec: %w", obj, err)
+		}
+		if spec.Domain == "" {
+			spec.Domain = o.Metadata.Name
+		}
+		for _, name := range slices.Sorted(maps.Keys(spec.Files)) {
+			if err := add(obj, spec.Domain, name, []byte(spec.Files[name])); err != nil {
+				return nil, err
+			}
+		}
+		for _, env := range slices.Sorted(maps.Keys(spec.Bindings)) {
+			if err := bind(obj, spec.Domain, env, spec.Bindings[env]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return df, nil
+}
+
+// etag is the ETag of the files and bindings read.
+func (df *domainFiles) etag() string {
+	checksums := map[string]string{}
+	for domain, files := range df.files {
+		for name, data := range files {
+			checksums[domain+"/"+name] = fmt.Sprintf("%x", sha256.Sum256(data))
+		}
+	}
+	for domain, envs := range df.bindings {
+		for env, values := range envs {
+			data, _ := json.Marshal(values)
+			checksums[domain+"/bindings/"+env] = fmt.Sprintf("%x", sha256.Sum256(data))
+		}
+	}
+	return engine.ManifestETag(checksums)
+}
+
+// ETag returns the ETag of the contract Load would compile now.
+func (s *Source) ETag() (string, error) {
+	df, err := s.read()
+	if err != nil {
+		return "", err
+	}
+	return df.etag(), nil
+}
+
+// Load compiles the domain's contract, resolving imports from the other
+// domains' files. It satisfies covenant.Source.
+func (s *Source) Load(context.Context) (*engine.Contract, string, error) {
+	c, _, etag, err := s.load()
+	return c, etag, err
+}
+
+// LoadWithBindings is Load also returning the domain's param bindings for
+// env, read along with its files.
+func (s *Source) LoadWithBindings(env string) (*engine.Contract, map[string]any, string, error) {
+	c, df, etag, err := s.load()
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return c, df.bindings[s.cfg.Domain][env], etag, nil
+}
+
+func (s *Source) load() (*engine.Contract, *domainFiles, string, error) {
+	df, err := s.read()
+	if err != nil {
+		return nil, nil, "", err
+	}
+	files, ok := df.files[s.cfg.Domain]
+	if !ok {
+		return nil, nil, "", fmt.Errorf("no %s hold contract files of domain %s in namespace %s", s.cfg.Kind, s.cfg.Domain, s.cfg.Namespace)
+	}
+	c, err := engine.CompileSources(prefixed(s.cfg.Domain, files), func(name string) (map[string][]byte, error) {
+		files, ok := df.files[name]
+		if !ok {
+			return nil, fmt.Errorf("import %q: no %s hold its contract files", name, s.cfg.Kind)
+		}
+		return prefixed(name, files), nil
+	})
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return c, df, df.etag(), nil
+}
+
+// prefixed keys a domain's files by domain/file, the names compile errors
+// report.
+func prefixed(domain string, files map[string][]byte) map[string][]byte {
+	out := make(map[string][]byte, len(files))
+	for name, data := range files {
+		out[domain+"/"+name] = data
+	}
+	return out
+}
@@ -0,0 +1,221 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeAPI serves one collection: a list, and watches of the changes push
+// makes from the version asked for on.
+type fakeAPI struct {
+	mu      sync.Mutex
+	version int
+	items   map[string]Object
+	log     []event // pushed events; log[i] is at version first+i
+	first   int
+	watches []chan event
+}
+
+func newFakeAPI(items ...Object) *fakeAPI {
+	api := &fakeAPI{items: map[string]Object{}}
+	for _, o := range items {
+		api.version++
+		o.Metadata.ResourceVersion = fmt.Sprint(api.version)
+		api.items[o.Metadata.Name] = o
+	}
+	return api
+}
+
+func (api *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("watch") == "" {
+		api.mu.Lock()
+		l := list{Items: []Object{}}
+		l.Metadata.ResourceVersion = fmt.Sprint(api.version)
+		for _, o := range api.items {
+			l.Items = append(l.Items, o)
+		}
+		api.mu.Unlock()
+		json.NewEncoder(w).Encode(l)
+		return
+	}
+	from, _ := strconv.Atoi(r.URL.Query().Get("resourceVersion"))
+	events := make(chan event, 16)
+	api.mu.Lock()
+	for v, ev := range api.log {
+		if api.first+v > from {
+			events <- ev
+		}
+	}
+	api.watches = append(api.watches, events)
+	api.mu.Unlock()
+	w.(http.Flusher).Flush()
+	enc := json.NewEncoder(w)
+	for {
+		select {
+		case ev := <-events:
+			enc.Encode(ev)
+			w.(http.Flusher).Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// push applies a change and sends it to the watches.
+func (api *fakeAPI) push(typ string, o Object) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.version++
+	o.Metadata.ResourceVersion = fmt.Sprint(api.version)
+	if typ == "DELETED" {
+		delete(api.items, o.Metadata.Name)
+	} else {
+		api.items[o.Metadata.Name] = o
+	}
+	data, _ := json.Marshal(o)
+	if api.log == nil {
+		api.first = api.version
+	}
+	api.log = append(api.log, event{Type: typ, Object: data})
+	for _, w := range api.watches {
+		w <- event{Type: typ, Object: data}
+	}
+}
+
+// domainConfigMap holds the files of a directory under ../../contracts.
+func domainConfigMap(t *testing.T, domain string) Object {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join("..", "..", "contracts", domain, "*.cue"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no contract files for %s: %v", domain, err)
+	}
+	o := Object{Metadata: Metadata{Name: domain + "-contract", Labels: map[string]string{DomainLabel: domain}}, Data: map[string]string{}}
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o.Data[filepath.Base(p)] = string(data)
+	}
+	return o
+}
+
+func startSource(t *testing.T, api *fakeAPI, cfg SourceConfig) *Source {
+	t.Helper()
+	srv := httptest.NewServer(api)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(func() { cancel(); srv.Close() })
+	src := NewSource(NewClient(srv.URL, ""), cfg)
+	go src.Run(ctx)
+	if err := src.WaitSynced(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return src
+}
+
+func TestSource_configMaps(t *testing.T) {
+	billing := domainConfigMap(t, "billing")
+	billing.Data["bindings.prod.json"] = `{}`
+	api := newFakeAPI(billing, domainConfigMap(t, "common"),
+		Object{Metadata: Metadata{Name: "unrelated", Labels: map[string]string{DomainLabel: "shipping"}}, Data: map[string]string{"README": "not a contract"}})
+	src := startSource(t, api, SourceConfig{Kind: ConfigMaps, Namespace: "covenant", Domain: "billing"})
+
+	c, bindings, etag, err := src.LoadWithBindings("prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Operations) == 0 || bindings == nil || etag == "" {
+		t.Fatalf("expected the billing contract with prod bindings, got %d operations, bindings %v, etag %q", len(c.Operations), bindings, etag)
+	}
+
+	// Editing a base contract changes the ETag, and is seen through the watch.
+	common := domainConfigMap(t, "common")
+	common.Data["base.cue"] += "\n// edited\n"
+	api.push("MODIFIED", common)
+	select {
+	case <-src.Changes():
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change after a watch event")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		next, err := src.ETag()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if next != etag {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("ETag unchanged after editing a base contract")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	api.push("DELETED", common)
+	deadline = time.Now().Add(5 * time.Second)
+	for {
+		_, _, err := src.Load(context.Background())
+		if err != nil && strings.Contains(err.Error(), `import "common"`) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the missing import reported, got %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSource_contracts(t *testing.T) {
+	cm := domainConfigMap(t, "billing")
+	spec, _ := json.Marshal(ContractSpec{Files: cm.Data, Bindings: map[string]map[string]any{"prod": {}}})
+	common, _ := json.Marshal(ContractSpec{Files: domainConfigMap(t, "common").Data})
+	api := newFakeAPI(
+		Object{Metadata: Metadata{Name: "billing"}, Spec: spec},
+		Object{Metadata: Metadata{Name: "shared"}, Spec: json.RawMessage(strings.Replace(string(common), `{`, `{"domain":"common",`, 1))},
+	)
+	src := startSource(t, api, SourceConfig{Kind: Contracts, Namespace: "covenant", Domain: "billing"})
+	if _, _, err := src.Load(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	api.push("ADDED", Object{Metadata: Metadata{Name: "billing-extra"}, Spec: json.RawMessage(`{"domain":"billing","files":{"rules.cue":""}}`)})
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, _, err := src.Load(context.Background())
+		if err != nil && strings.Contains(err.Error(), "declared by both") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected a file declared twice reported, got %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestParseSourceURL(t *testing.T) {
+	cfg, err := ParseSourceURL("kube://configmaps/covenant?domain=billing")
+	if err != nil || cfg != (SourceConfig{Kind: ConfigMaps, Namespace: "covenant", Domain: "billing"}) {
+		t.Errorf("got %+v, %v", cfg, err)
+	}
+	for _, bad := range []string{
+		"http://configmaps/covenant?domain=billing",
+		"kube://secrets/covenant?domain=billing",
+		"kube://contracts/?domain=billing",
+		"kube://contracts/covenant",
+	} {
+		if _, err := ParseSourceURL(bad); err == nil {
+			t.Errorf("%s: expected an error", bad)
+		}
+	}
+}
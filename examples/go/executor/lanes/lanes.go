@@ -0,0 +1,162 @@
+// Package lanes schedules evaluations in priority lanes, each with its own
+// worker pool and bounded queue, so large batch and simulation jobs cannot
+// starve interactive requests. A Scheduler satisfies engine.Scheduler.
+package lanes
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"covenant-poc/executor/engine"
+)
+
+// Config sizes one lane.
+type Config struct {
+	Workers int // evaluations run at once
+	Queue   int // evaluations that may wait for a worker
+}
+
+// Stats describes one lane's load.
+type Stats struct {
+	Workers   int   `json:"workers"`
+	Busy      int64 `json:"busy"`
+	Queued    int   `json:"queued"`
+	Completed int64 `json:"completed"`
+	Rejected  int64 `json:"rejected"`
+}
+
+// Scheduler runs functions in named lanes.
+type Scheduler struct {
+	lanes map[string]*lane
+}
+
+type lane struct {
+	cfg       Config
+	jobs      chan *job
+	busy      atomic.Int64
+	completed atomic.Int64
+	rejected  atomic.Int64
+}
+
+// Job states.
+const (
+	queued int32 = iota
+	running
+	abandoned
+)
+
+type job struct {
+	fn    func()
+	state atomic.Int32
+	done  chan struct{}
+	err   error // a panic in fn, set before done is closed
+}
+
+// New starts a scheduler with the given lanes.
+func New(cfg map[string]Config) *Scheduler {
+	s := &Scheduler{lanes: make(map[string]*lane, len(cfg))}
+	for name, c := range cfg {
+		l := &lane{cfg: c, jobs: make(chan *job, c.Queue)}
+		s.lanes[name] = l
+		for i := 0; i < c.Workers; i++ {
+			go work(l)
+		}
+	}
+	return s
+}
+
+func work(l *lane) {
+	for j := range l.jobs {
+		if !j.state.CompareAndSwap(queued, running) {
+			continue // the caller gave up waiting
+		}
+		l.run(j)
+	}
+}
+
+// run runs j on the worker. A panic in j's fn is returned to its caller as
+// an error rather than taking the process down with the worker.
+func (l *lane) run(j *job) {
+	l.busy.Add(1)
+	defer func() {
+		if r := recover(); r != nil {
+			j.err = fmt.Errorf("panic: %v", r)
+		}
+		l.busy.Add(-1)
+		l.completed.Add(1)
+		close(j.done)
+	}()
+	j.fn()
+}
+
+// Do runs fn in the named lane and waits for it to finish. Once fn has
+// started, Do waits for it even if ctx ends, since fn may write to the
+// caller's variables. If fn panics, Do returns the panic as an error.
+func (s *Scheduler) Do(ctx context.Context, priority string, fn func()) error {
+	l, ok := s.lanes[priority]
+	if !ok {
+		return fmt.Errorf("no %q lane", priority)
+	}
+	j := &job{fn: fn, done: make(chan struct{})}
+	select {
+	case l.jobs <- j:
+	default:
+		l.rejected.Add(1)
+		return engine.ErrLaneFull
+	}
+	select {
+	case <-j.done:
+		return j.err
+	case <-ctx.Done():
+		if j.state.CompareAndSwap(queued, abandoned) {
+			return ctx.Err()
+		}
+		<-j.done
+		return j.err
+	}
+}
+
+// Stats returns the current load of every lane.
+func (s *Scheduler) Stats() map[string]Stats {
+	out := make(map[string]Stats, len(s.lanes))
+	for name, l := range s.lanes {
+		out[name] = Stats{
+			Workers:   l.cfg.Workers,
+			Busy:      l.busy.Load(),
+			Queued:    len(l.jobs),
+			Completed: l.completed.Load(),
+			Rejected:  l.rejected.Load(),
+		}
+	}
+	return out
+}
+
+// Parse parses a comma-separated list of lane=workers:queue pairs, such as
+// "interactive=32:1000,batch=4:10000".
+func Parse(spec string) (map[string]Config, error) {
+	cfg := map[string]Config{}
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		workersStr, queueStr, ok2 := strings.Cut(value, ":")
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("lane %q: expected lane=workers:queue", pair)
+		}
+		workers, err := strconv.Atoi(workersStr)
+		if err != nil || workers < 1 {
+			return nil, fmt.Errorf("lane %q: workers must be a positive integer", pair)
+		}
+		queue, err := strconv.Atoi(queueStr)
+		if err != nil || queue < 0 {
+			return nil, fmt.Errorf("lane %q: queue must be a non-negative integer", pair)
+		}
+		cfg[strings.TrimSpace(name)] = Config{Workers: workers, Queue: queue}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,115 @@
+package lanes
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"covenant-poc/executor/engine"
+)
+
+func TestScheduler_batchBacklogDoesNotStarveInteractive(t *testing.T) {
+	s := New(map[string]Config{"interactive": {Workers: 1, Queue: 10}, "batch": {Workers: 1, Queue: 10}})
+
+	block := make(chan struct{})
+	for i := 0; i < 5; i++ {
+		go s.Do(context.Background(), "batch", func() { <-block })
+	}
+	waitFor(t, func() bool { st := s.Stats()["batch"]; return st.Busy == 1 && st.Queued == 4 })
+
+	done := make(chan struct{})
+	go func() {
+		s.Do(context.Background(), "interactive", func() {})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("interactive work waited behind the batch lane")
+	}
+	close(block)
+}
+
+func TestScheduler_fullLaneRejects(t *testing.T) {
+	s := New(map[string]Config{"batch": {Workers: 1, Queue: 1}})
+
+	block := make(chan struct{})
+	go s.Do(context.Background(), "batch", func() { <-block })
+	waitFor(t, func() bool { return s.Stats()["batch"].Busy == 1 })
+	go s.Do(context.Background(), "batch", func() {})
+	waitFor(t, func() bool { return s.Stats()["batch"].Queued == 1 })
+
+	if err := s.Do(context.Background(), "batch", func() { t.Error("rejected work ran") }); !errors.Is(err, engine.ErrLaneFull) {
+		t.Errorf("expected ErrLaneFull, got %v", err)
+	}
+	if st := s.Stats()["batch"]; st.Rejected != 1 {
+		t.Errorf("expected one rejection, got %+v", st)
+	}
+	close(block)
+}
+
+func TestScheduler_cancelledWhileQueuedNeverRuns(t *testing.T) {
+	s := New(map[string]Config{"batch": {Workers: 1, Queue: 5}})
+
+	block := make(chan struct{})
+	go s.Do(context.Background(), "batch", func() { <-block })
+	waitFor(t, func() bool { return s.Stats()["batch"].Busy == 1 })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	ran := false
+	if err := s.Do(ctx, "batch", func() { ran = true }); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the context's error, got %v", err)
+	}
+	close(block)
+	s.Do(context.Background(), "batch", func() {}) // drains the abandoned job first
+	if ran {
+		t.Error("abandoned work ran")
+	}
+}
+
+func TestScheduler_panicIsReturnedAsError(t *testing.T) {
+	s := New(map[string]Config{"interactive": {Workers: 1, Queue: 1}})
+
+	err := s.Do(context.Background(), "interactive", func() { panic("port exploded") })
+	if err == nil || !strings.Contains(err.Error(), "port exploded") {
+		t.Errorf("expected the panic as an error, got %v", err)
+	}
+	// The lane's only worker survived to run more work.
+	ran := false
+	if err := s.Do(context.Background(), "interactive", func() { ran = true }); err != nil || !ran {
+		t.Errorf("expected the worker to keep running, got %v", err)
+	}
+	if st := s.Stats()["interactive"]; st.Busy != 0 || st.Completed != 2 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+}
+
+func TestParse(t *testing.T) {
+	got, err := Parse("interactive=32:1000, batch=4:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["interactive"] != (Config{Workers: 32, Queue: 1000}) || got["batch"] != (Config{Workers: 4}) {
+		t.Errorf("unexpected config %v", got)
+	}
+	for _, bad := range []string{"batch", "batch=4", "batch=0:1", "batch=1:-1"} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
+
+// waitFor polls cond until it holds or a second passes.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not reached")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
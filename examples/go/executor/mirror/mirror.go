@@ -0,0 +1,215 @@
+// Package mirror forwards a sample of an executor's live traffic to a
+// secondary executor running a new engine or contract build, and records
+// where the two decide differently. It canaries the engine itself, not just
+// contracts: the secondary sees real requests, but as dry runs, so it has
+// no side effects, and the primary's answers are never held up by it.
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"covenant-poc/executor/engine"
+)
+
+// Config configures a Mirror.
+type Config struct {
+	URL    string  // base URL of the secondary executor
+	Sample float64 // fraction of requests mirrored, from 0 to 1
+	// Queue bounds the mirrored requests waiting to be sent; requests
+	// sampled while it is full are dropped. Default 100.
+	Queue int
+	// Workers send mirrored requests concurrently. Default 4.
+	Workers int
+	// Keep is how many recent diffs Diffs returns. Default 100.
+	Keep int
+	// Client sends the requests; default one with a 10s timeout.
+	Client *http.Client
+}
+
+// Decision is what a mirror compares of a response: its outcome and its
+// verdicts, as "type CODE", sorted.
+type Decision struct {
+	Outcome  engine.Outcome `json:"outcome"`
+	Verdicts []string       `json:"verdicts,omitempty"`
+}
+
+// Diff is a request the executors decided differently.
+type Diff struct {
+	Time      time.Time       `json:"time"`
+	Request   *engine.Request `json:"request"`
+	Primary   Decision        `json:"primary"`
+	Secondary Decision        `json:"secondary"`
+}
+
+// Stats counts mirrored requests.
+type Stats struct {
+	Mirrored  int64  `json:"mirrored"` // answered by the secondary
+	Matched   int64  `json:"matched"`  // decided the same by both
+	Diffed    int64  `json:"diffed"`   // decided differently
+	Failed    int64  `json:"failed"`   // not answered by the secondary
+	Dropped   int64  `json:"dropped"`  // sampled while the queue was full
+	LastError string `json:"last_error,omitempty"`
+}
+
+// Mirror forwards sampled requests to the secondary.
+type Mirror struct {
+	cfg   Config
+	queue chan mirrored
+
+	mu    sync.Mutex
+	stats Stats
+	diffs []Diff // ring of the last cfg.Keep, oldest first
+}
+
+type mirrored struct {
+	req     *engine.Request
+	primary Decision
+}
+
+// New starts a mirror to cfg.URL.
+func New(cfg Config) *Mirror {
+	if cfg.Queue <= 0 {
+		cfg.Queue = 100
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 4
+	}
+	if cfg.Keep <= 0 {
+		cfg.Keep = 100
+	}
+	if cfg.Client == nil {
+		cfg.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	cfg.URL = strings.TrimSuffix(cfg.URL, "/")
+	m := &Mirror{cfg: cfg, queue: make(chan mirrored, cfg.Queue)}
+	for range cfg.Workers {
+		go func() {
+			for mr := range m.queue {
+				m.send(mr)
+			}
+		}()
+	}
+	return m
+}
+
+// Offer mirrors req, which the primary answered with resp, if it is
+// sampled. It never blocks. The secondary is sent a dry run of req with the
+// caller facts in ctx, without its idempotency key or contract ETag, which
+// belong to the primary. Requests in a session are not mirrored, as the
+// secondary has no such session, nor are idempotent replays.
+func (m *Mirror) Offer(ctx context.Context, req *engine.Request, resp *engine.Response) {
+	if req.SessionID != "" || resp.IdempotentReplay || rand.Float64() >= m.cfg.Sample {
+		return
+	}
+	dry := *req
+	dry.DryRun, dry.IdempotencyKey, dry.ContractETag = true, "", ""
+	if facts, ok := engine.CallerFacts(ctx); ok {
+		dry.Context = facts
+	}
+	select {
+	case m.queue <- mirrored{req: &dry, primary: AsDryRun(Summarize(resp))}:
+	default:
+		m.mu.Lock()
+		m.stats.Dropped++
+		m.mu.Unlock()
+	}
+}
+
+func (m *Mirror) send(mr mirrored) {
+	secondary, err := m.post(mr.req)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil {
+		m.stats.Failed++
+		m.stats.LastError = err.Error()
+		return
+	}
+	m.stats.Mirrored++
+	if secondary.Outcome == mr.primary.Outcome && slices.Equal(secondary.Verdicts, mr.primary.Verdicts) {
+		m.stats.Matched++
+		return
+	}
+	m.stats.Diffed++
+	log.Printf("mirror: op=%s primary=%s %v secondary=%s %v", mr.req.Operation, mr.primary.Outcome, mr.primary.Verdicts, secondary.Outcome, secondary.Verdicts)
+	if len(m.diffs) == m.cfg.Keep {
+		m.diffs = m.diffs[1:]
+	}
+	m.diffs = append(m.diffs, Diff{Time: time.Now().UTC(), Request: mr.req, Primary: mr.primary, Secondary: secondary})
+}
+
+func (m *Mirror) post(req *engine.Request) (Decision, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return Decision{}, err
+	}
+	resp, err := m.cfg.Client.Post(m.cfg.URL+"/execute", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return Decision{}, err
+	}
+	defer resp.Body.Close()
+	var out engine.Response
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil || out.Outcome == "" {
+		return Decision{}, fmt.Errorf("secondary answered %s without a decision", resp.Status)
+	}
+	return Summarize(&out), nil
+}
+
+// Stats returns the mirror's counts.
+func (m *Mirror) Stats() Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stats
+}
+
+// Diffs returns the most recent diffs, newest first.
+func (m *Mirror) Diffs() []Diff {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := slices.Clone(m.diffs)
+	slices.Reverse(out)
+	return out
+}
+
+// Summarize returns the decision of resp. Shadow verdicts are left out;
+// they have no effect.
+func Summarize(resp *engine.Response) Decision {
+	d := Decision{Outcome: resp.Outcome}
+	for _, v := range resp.Verdicts {
+		if !v.Shadow {
+			d.Verdicts = append(d.Verdicts, v.Type+" "+v.Code)
+		}
+	}
+	slices.Sort(d.Verdicts)
+	return d
+}
+
+// AsDryRun returns the decision a dry run of a live request decided as d
+// would have had: executed becomes would_execute, or
+// would_execute_with_flags if it was flagged, denied becomes would_deny,
+// and so on. Other outcomes are kept.
+func AsDryRun(d Decision) Decision {
+	switch d.Outcome {
+	case engine.OutcomeExecuted:
+		d.Outcome = engine.OutcomeWouldExecute
+		if slices.ContainsFunc(d.Verdicts, func(v string) bool { return strings.HasPrefix(v, "flag ") }) {
+			d.Outcome = engine.OutcomeWouldExecuteWithFlags
+		}
+	case engine.OutcomeDenied:
+		d.Outcome = engine.OutcomeWouldDeny
+	case engine.OutcomeEscalated:
+		d.Outcome = engine.OutcomeWouldEscalate
+	case engine.OutcomeRequired:
+		d.Outcome = engine.OutcomeWouldRequire
+	}
+	return d
+}
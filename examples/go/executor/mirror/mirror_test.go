@@ -0,0 +1,109 @@
+package mirror
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"covenant-poc/executor/engine"
+)
+
+// secondary answers every request with resp, keeping the requests it got.
+type secondary struct {
+	resp engine.Response
+
+	mu   sync.Mutex
+	reqs []engine.Request
+}
+
+func (s *secondary) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req engine.Request
+	json.NewDecoder(r.Body).Decode(&req)
+	s.mu.Lock()
+	s.reqs = append(s.reqs, req)
+	s.mu.Unlock()
+	json.NewEncoder(w).Encode(s.resp)
+}
+
+func waitFor(t *testing.T, m *Mirror, answered int64) Stats {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		st := m.Stats()
+		if st.Mirrored+st.Failed >= answered {
+			return st
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d mirrored requests answered, got %+v", answered, st)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestMirror_recordsDiffs(t *testing.T) {
+	sec := &secondary{resp: engine.Response{Outcome: engine.OutcomeWouldDeny, Verdicts: []engine.Verdict{{Type: "deny", Code: "LIMIT"}}}}
+	srv := httptest.NewServer(sec)
+	defer srv.Close()
+	m := New(Config{URL: srv.URL, Sample: 1})
+
+	ctx := engine.WithCallerFacts(context.Background(), map[string]any{"user.id": "u1"})
+	req := &engine.Request{Operation: "Pay", Input: map[string]any{"amount": 500}, IdempotencyKey: "k1", ContractETag: "abc"}
+	m.Offer(ctx, req, &engine.Response{Outcome: engine.OutcomeDenied, Verdicts: []engine.Verdict{{Type: "deny", Code: "LIMIT"}}})
+	m.Offer(ctx, req, &engine.Response{Outcome: engine.OutcomeExecuted})
+	st := waitFor(t, m, 2)
+
+	if st.Mirrored != 2 || st.Matched != 1 || st.Diffed != 1 {
+		t.Errorf("expected one match and one diff, got %+v", st)
+	}
+	diffs := m.Diffs()
+	if len(diffs) != 1 || diffs[0].Primary.Outcome != engine.OutcomeWouldExecute || diffs[0].Secondary.Outcome != engine.OutcomeWouldDeny {
+		t.Errorf("unexpected diffs %+v", diffs)
+	}
+	sec.mu.Lock()
+	defer sec.mu.Unlock()
+	for _, got := range sec.reqs {
+		if !got.DryRun || got.IdempotencyKey != "" || got.ContractETag != "" || got.Context["user.id"] != "u1" {
+			t.Errorf("expected a dry run with the caller facts and without the primary's keys, got %+v", got)
+		}
+	}
+	if req.DryRun || req.IdempotencyKey != "k1" {
+		t.Errorf("the primary's request was changed: %+v", req)
+	}
+}
+
+func TestMirror_samplesAndSkips(t *testing.T) {
+	sec := &secondary{resp: engine.Response{Outcome: engine.OutcomeWouldExecute}}
+	srv := httptest.NewServer(sec)
+	defer srv.Close()
+
+	none := New(Config{URL: srv.URL, Sample: 0})
+	all := New(Config{URL: srv.URL, Sample: 1})
+	for _, m := range []*Mirror{none, all} {
+		m.Offer(context.Background(), &engine.Request{Operation: "Pay", SessionID: "s1"}, &engine.Response{Outcome: engine.OutcomeExecuted})
+		m.Offer(context.Background(), &engine.Request{Operation: "Pay"}, &engine.Response{Outcome: engine.OutcomeExecuted, IdempotentReplay: true})
+		m.Offer(context.Background(), &engine.Request{Operation: "Pay"}, &engine.Response{Outcome: engine.OutcomeExecuted})
+	}
+	if st := waitFor(t, all, 1); st.Mirrored != 1 || st.Matched != 1 {
+		t.Errorf("expected only the plain request mirrored, got %+v", st)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if st := none.Stats(); st.Mirrored+st.Failed != 0 {
+		t.Errorf("expected nothing mirrored at sample 0, got %+v", st)
+	}
+}
+
+func TestMirror_countsFailures(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusBadGateway)
+	}))
+	defer srv.Close()
+	m := New(Config{URL: srv.URL, Sample: 1})
+	m.Offer(context.Background(), &engine.Request{Operation: "Pay"}, &engine.Response{Outcome: engine.OutcomeExecuted})
+	if st := waitFor(t, m, 1); st.Failed != 1 || st.LastError == "" {
+		t.Errorf("expected a failure, got %+v", st)
+	}
+}
@@ -0,0 +1,62 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// LogAlerter writes alerts to the standard logger.
+type LogAlerter struct{}
+
+func (LogAlerter) Alert(_ context.Context, a Alert) error {
+	log.Printf("ALERT %s", a)
+	return nil
+}
+
+// WebhookAlerter POSTs each alert as JSON to URL. Delivery is asynchronous so
+// a slow receiver never delays evaluation.
+type WebhookAlerter struct {
+	URL    string
+	Client *http.Client
+}
+
+func (w WebhookAlerter) Alert(_ context.Context, a Alert) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	client := w.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	go func() {
+		resp, err := client.Post(w.URL, "application/json", bytes.NewReader(body))
+		if err != nil {
+			log.Printf("alert webhook: %v", err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			log.Printf("alert webhook: HTTP %d", resp.StatusCode)
+		}
+	}()
+	return nil
+}
+
+// alertCounts is published at /debug/vars as covenant_rule_alerts.
+var alertCounts = expvar.NewMap("covenant_rule_alerts")
+
+// MetricAlerter counts alerts per kind, operation and rule in the
+// covenant_rule_alerts expvar map.
+type MetricAlerter struct{}
+
+func (MetricAlerter) Alert(_ context.Context, a Alert) error {
+	alertCounts.Add(fmt.Sprintf("%s:%s:%s", a.Kind, a.Operation, a.Rule), 1)
+	return nil
+}
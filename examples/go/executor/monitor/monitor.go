@@ -0,0 +1,219 @@
+// Package monitor watches per-rule hit rates and raises alerts on anomalies.
+//
+// Monitor is an engine.AuditSink. Records are grouped into fixed windows; when
+// a window closes, each rule's hit rate (matches per evaluation of the
+// operation it constrains) is compared against an exponentially weighted
+// baseline of earlier windows. A rate that spikes — typically an upstream
+// outage tripping a deny rule — or falls to zero — typically a rule disabled
+// by a contract change — is reported to the configured Alerters.
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"covenant-poc/executor/engine"
+)
+
+// Alert kinds.
+const (
+	KindSpike = "spike"
+	KindZero  = "dropped_to_zero"
+)
+
+// Alert describes one anomalous rule in a closed window.
+type Alert struct {
+	Kind            string    `json:"kind"`
+	Operation       string    `json:"operation"`
+	Rule            string    `json:"rule"`
+	Rate            float64   `json:"rate"`
+	Baseline        float64   `json:"baseline"`
+	Evaluations     int       `json:"evaluations"`
+	WindowStart     time.Time `json:"window_start"`
+	WindowEnd       time.Time `json:"window_end"`
+	ContractVersion string    `json:"contract_version"`
+	// ContractChanged is set when the window saw a different contract
+	// version than the one before it — the usual cause of a rule going silent.
+	ContractChanged bool   `json:"contract_changed"`
+	PreviousVersion string `json:"previous_version,omitempty"`
+}
+
+func (a Alert) String() string {
+	s := fmt.Sprintf("rule %s on %s %s: rate %.3f vs baseline %.3f over %d evaluations",
+		a.Rule, a.Operation, a.Kind, a.Rate, a.Baseline, a.Evaluations)
+	if a.ContractChanged {
+		s += fmt.Sprintf(" (contract changed %s → %s)", a.PreviousVersion, a.ContractVersion)
+	}
+	return s
+}
+
+// Alerter delivers alerts. Implementations must be safe for concurrent use.
+type Alerter interface {
+	Alert(ctx context.Context, a Alert) error
+}
+
+// Config tunes anomaly detection. Zero values take the documented defaults.
+type Config struct {
+	Window      time.Duration // window length (default 5m)
+	MinSamples  int           // minimum evaluations of an operation per window to judge its rules (default 20)
+	SpikeFactor float64       // rate must exceed baseline by this factor to be a spike (default 3)
+	MinRate     float64       // rates below this are noise: no spikes under it, no zero-drop alerts from baselines under it (default 0.01)
+	Smoothing   float64       // EWMA weight given to the newest window (default 0.3)
+}
+
+func (c *Config) defaults() {
+	if c.Window <= 0 {
+		c.Window = 5 * time.Minute
+	}
+	if c.MinSamples <= 0 {
+		c.MinSamples = 20
+	}
+	if c.SpikeFactor <= 0 {
+		c.SpikeFactor = 3
+	}
+	if c.MinRate <= 0 {
+		c.MinRate = 0.01
+	}
+	if c.Smoothing <= 0 || c.Smoothing > 1 {
+		c.Smoothing = 0.3
+	}
+}
+
+type ruleKey struct{ op, rule string }
+
+// Monitor tracks rule hit rates. Create with New.
+type Monitor struct {
+	cfg      Config
+	alerters []Alerter
+
+	mu          sync.Mutex
+	windowStart time.Time
+	evals       map[string]int  // operation → evaluations in current window
+	hits        map[ruleKey]int // (operation, rule) → matches in current window
+	baseline    map[ruleKey]float64
+	version     string // latest contract version seen in the current window
+	prevVersion string // contract version at the end of the previous window
+}
+
+// New returns a Monitor that reports to the given alerters.
+func New(cfg Config, alerters ...Alerter) *Monitor {
+	cfg.defaults()
+	return &Monitor{
+		cfg:      cfg,
+		alerters: alerters,
+		evals:    map[string]int{},
+		hits:     map[ruleKey]int{},
+		baseline: map[ruleKey]float64{},
+	}
+}
+
+// Record implements engine.AuditSink. Escalation resolutions match no
+// rules and are not counted.
+func (m *Monitor) Record(ctx context.Context, rec *engine.AuditRecord) {
+	if rec.Resolution != nil {
+		return
+	}
+	m.mu.Lock()
+	var alerts []Alert
+	if m.windowStart.IsZero() {
+		m.windowStart = rec.Timestamp.Truncate(m.cfg.Window)
+	}
+	if !rec.Timestamp.Before(m.windowStart.Add(m.cfg.Window)) {
+		alerts = m.closeWindow()
+		m.windowStart = rec.Timestamp.Truncate(m.cfg.Window)
+	}
+
+	m.evals[rec.Operation]++
+	seen := map[string]bool{}
+	for _, rule := range rec.RulesMatched {
+		if !seen[rule] {
+			seen[rule] = true
+			m.hits[ruleKey{rec.Operation, rule}]++
+		}
+	}
+	m.version = rec.ContractVersion
+	m.mu.Unlock()
+
+	m.deliver(ctx, alerts)
+}
+
+// closeWindow evaluates the current window against the baselines, folds it
+// into them and resets the counters. Callers hold m.mu.
+func (m *Monitor) closeWindow() []Alert {
+	var alerts []Alert
+	changed := m.prevVersion != "" && m.version != m.prevVersion
+
+	keys := make([]ruleKey, 0, len(m.baseline)+len(m.hits))
+	for k := range m.baseline {
+		keys = append(keys, k)
+	}
+	for k := range m.hits {
+		if _, ok := m.baseline[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].op != keys[j].op {
+			return keys[i].op < keys[j].op
+		}
+		return keys[i].rule < keys[j].rule
+	})
+
+	for _, k := range keys {
+		n := m.evals[k.op]
+		if n < m.cfg.MinSamples {
+			continue
+		}
+		rate := float64(m.hits[k]) / float64(n)
+		base, known := m.baseline[k]
+		if !known {
+			m.baseline[k] = rate
+			continue
+		}
+
+		kind := ""
+		switch {
+		case rate > m.cfg.MinRate && rate > base*m.cfg.SpikeFactor:
+			kind = KindSpike
+		case m.hits[k] == 0 && base >= m.cfg.MinRate:
+			kind = KindZero
+		}
+		if kind != "" {
+			a := Alert{
+				Kind:            kind,
+				Operation:       k.op,
+				Rule:            k.rule,
+				Rate:            rate,
+				Baseline:        base,
+				Evaluations:     n,
+				WindowStart:     m.windowStart,
+				WindowEnd:       m.windowStart.Add(m.cfg.Window),
+				ContractVersion: m.version,
+				ContractChanged: changed,
+			}
+			if changed {
+				a.PreviousVersion = m.prevVersion
+			}
+			alerts = append(alerts, a)
+		}
+		m.baseline[k] = m.cfg.Smoothing*rate + (1-m.cfg.Smoothing)*base
+	}
+
+	m.prevVersion = m.version
+	m.evals = map[string]int{}
+	m.hits = map[ruleKey]int{}
+	return alerts
+}
+
+func (m *Monitor) deliver(ctx context.Context, alerts []Alert) {
+	for _, a := range alerts {
+		for _, al := range m.alerters {
+			// Alert delivery failures are reported by the alerter itself;
+			// they must never affect evaluation.
+			_ = al.Alert(ctx, a)
+		}
+	}
+}
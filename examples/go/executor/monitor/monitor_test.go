@@ -0,0 +1,88 @@
+package monitor
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"covenant-poc/executor/engine"
+)
+
+type captureAlerter struct {
+	mu     sync.Mutex
+	alerts []Alert
+}
+
+func (c *captureAlerter) Alert(_ context.Context, a Alert) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.alerts = append(c.alerts, a)
+	return nil
+}
+
+var t0 = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// feed records n evaluations of Pay in the window starting at start, of which
+// hits matched rule, under the given contract version.
+func feed(m *Monitor, start time.Time, n, hits int, rule, version string) {
+	for i := 0; i < n; i++ {
+		rec := &engine.AuditRecord{
+			Operation:       "Pay",
+			Timestamp:       start.Add(time.Duration(i) * time.Second),
+			ContractVersion: version,
+		}
+		if i < hits {
+			rec.RulesMatched = []string{rule}
+		}
+		m.Record(context.Background(), rec)
+	}
+}
+
+func TestMonitor_alertsOnSpike(t *testing.T) {
+	c := &captureAlerter{}
+	m := New(Config{Window: time.Minute, MinSamples: 10}, c)
+
+	feed(m, t0, 50, 5, "processor-down", "v1")                  // baseline 10%
+	feed(m, t0.Add(time.Minute), 50, 5, "processor-down", "v1") // steady
+	feed(m, t0.Add(2*time.Minute), 50, 40, "processor-down", "v1")
+	feed(m, t0.Add(3*time.Minute), 1, 0, "", "v1") // closes the spiking window
+
+	if len(c.alerts) != 1 {
+		t.Fatalf("expected 1 alert, got %+v", c.alerts)
+	}
+	a := c.alerts[0]
+	if a.Kind != KindSpike || a.Rule != "processor-down" || a.Rate != 0.8 || a.ContractChanged {
+		t.Fatalf("unexpected alert: %+v", a)
+	}
+}
+
+func TestMonitor_alertsWhenRuleGoesSilentAfterContractChange(t *testing.T) {
+	c := &captureAlerter{}
+	m := New(Config{Window: time.Minute, MinSamples: 10}, c)
+
+	feed(m, t0, 40, 10, "insufficient-funds", "v1")
+	feed(m, t0.Add(time.Minute), 40, 0, "insufficient-funds", "v2")
+	feed(m, t0.Add(2*time.Minute), 1, 0, "", "v2")
+
+	if len(c.alerts) != 1 {
+		t.Fatalf("expected 1 alert, got %+v", c.alerts)
+	}
+	a := c.alerts[0]
+	if a.Kind != KindZero || !a.ContractChanged || a.PreviousVersion != "v1" || a.ContractVersion != "v2" {
+		t.Fatalf("unexpected alert: %+v", a)
+	}
+}
+
+func TestMonitor_ignoresLowVolumeWindows(t *testing.T) {
+	c := &captureAlerter{}
+	m := New(Config{Window: time.Minute, MinSamples: 10}, c)
+
+	feed(m, t0, 40, 4, "r", "v1")
+	feed(m, t0.Add(time.Minute), 5, 5, "r", "v1") // spike, but below MinSamples
+	feed(m, t0.Add(2*time.Minute), 1, 0, "", "v1")
+
+	if len(c.alerts) != 0 {
+		t.Fatalf("expected no alerts for a low-volume window, got %+v", c.alerts)
+	}
+}
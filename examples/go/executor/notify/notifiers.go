@@ -0,0 +1,185 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// resolveBody shows how to resolve an escalation at its ResolveURL.
+const resolveBody = `{"decision": "execute" or "deny", "resolved_by": "<your name>", "justification": "<why>"}`
+
+// Slack posts new escalations to a Slack incoming webhook, with buttons
+// linking to the review UI and the decision.
+type Slack struct {
+	WebhookURL string
+	Client     *http.Client // default has a 10s timeout
+}
+
+func (s *Slack) Notify(ctx context.Context, n Notification) error {
+	text := fmt.Sprintf("*%s* escalated to `%s`\n%s (rule `%s`)", n.Operation, n.Queue, n.Reason, n.Rule)
+	if !n.DueAt.IsZero() {
+		text += fmt.Sprintf("\nDecide by %s", n.DueAt.Format(time.RFC1123))
+	}
+	if !n.ExpiresAt.IsZero() {
+		text += fmt.Sprintf("; at %s it is resolved with %s", n.ExpiresAt.Format(time.RFC1123), n.OnExpiry)
+	}
+	text += fmt.Sprintf("\nResolve: `POST %s` with `%s`", n.ResolveURL, resolveBody)
+
+	button := func(label, url string) map[string]any {
+		return map[string]any{"type": "button", "text": map[string]any{"type": "plain_text", "text": label}, "url": url}
+	}
+	msg := map[string]any{
+		"text": n.Summary(),
+		"blocks": []any{
+			map[string]any{"type": "section", "text": map[string]any{"type": "mrkdwn", "text": text}},
+			map[string]any{"type": "actions", "elements": []any{
+				button("Review", n.ReviewURL),
+				button("View decision", n.DecisionURL),
+			}},
+		},
+	}
+	return postJSON(ctx, s.Client, s.WebhookURL, msg)
+}
+
+// Email mails new escalations through an SMTP server.
+type Email struct {
+	Addr     string // host:port
+	Username string // PLAIN auth, if set
+	Password string
+	From     string
+	To       []string
+
+	// send defaults to smtp.SendMail.
+	send func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+func (e *Email) Notify(_ context.Context, n Notification) error {
+	var auth smtp.Auth
+	if e.Username != "" {
+		host, _, _ := strings.Cut(e.Addr, ":")
+		auth = smtp.PlainAuth("", e.Username, e.Password, host)
+	}
+	send := e.send
+	if send == nil {
+		send = smtp.SendMail
+	}
+	return send(e.Addr, auth, e.From, e.To, e.message(n))
+}
+
+func (e *Email) message(n Notification) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", e.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(e.To, ", "))
+	fmt.Fprintf(&b, "Subject: [%s] %s escalated for review\r\n", n.Queue, n.Operation)
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&b, "%s\r\n\r\n", n.Summary())
+	if !n.DueAt.IsZero() {
+		fmt.Fprintf(&b, "Decide by: %s\r\n", n.DueAt.Format(time.RFC1123))
+	}
+	if !n.ExpiresAt.IsZero() {
+		fmt.Fprintf(&b, "Resolved with %s at: %s\r\n", n.OnExpiry, n.ExpiresAt.Format(time.RFC1123))
+	}
+	fmt.Fprintf(&b, "Review: %s\r\n", n.ReviewURL)
+	fmt.Fprintf(&b, "Decision: %s\r\n", n.DecisionURL)
+	fmt.Fprintf(&b, "Escalation: %s\r\n\r\n", n.EscalationURL)
+	fmt.Fprintf(&b, "To resolve it, POST %s to %s\r\n", resolveBody, n.ResolveURL)
+	return []byte(b.String())
+}
+
+// PagerDutyEventsURL is the PagerDuty Events API v2 endpoint.
+const PagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// PagerDuty triggers an incident for each new escalation and resolves it
+// when the escalation is resolved. The escalation ID is the dedup key.
+type PagerDuty struct {
+	RoutingKey string
+	Severity   string       // default warning
+	URL        string       // default PagerDutyEventsURL
+	Client     *http.Client // default has a 10s timeout
+}
+
+func (p *PagerDuty) Notify(ctx context.Context, n Notification) error {
+	severity := p.Severity
+	if severity == "" {
+		severity = "warning"
+	}
+	details := map[string]any{
+		"escalation_id": n.EscalationID,
+		"invocation_id": n.InvocationID,
+		"queue":         n.Queue,
+		"rule":          n.Rule,
+		"resolve_url":   n.ResolveURL,
+	}
+	if !n.DueAt.IsZero() {
+		details["due_at"] = n.DueAt
+	}
+	if !n.ExpiresAt.IsZero() {
+		details["expires_at"], details["on_expiry"] = n.ExpiresAt, n.OnExpiry
+	}
+	return p.send(ctx, map[string]any{
+		"routing_key":  p.RoutingKey,
+		"event_action": "trigger",
+		"dedup_key":    n.EscalationID,
+		"payload": map[string]any{
+			"summary":        n.Summary(),
+			"source":         "covenant",
+			"severity":       severity,
+			"timestamp":      n.CreatedAt,
+			"component":      n.Operation,
+			"group":          n.Queue,
+			"custom_details": details,
+		},
+		"links": []any{
+			map[string]any{"href": n.ReviewURL, "text": "Review"},
+			map[string]any{"href": n.DecisionURL, "text": "Decision"},
+			map[string]any{"href": n.EscalationURL, "text": "Escalation"},
+		},
+	})
+}
+
+// Resolve implements Resolver.
+func (p *PagerDuty) Resolve(ctx context.Context, n Notification) error {
+	return p.send(ctx, map[string]any{
+		"routing_key":  p.RoutingKey,
+		"event_action": "resolve",
+		"dedup_key":    n.EscalationID,
+	})
+}
+
+func (p *PagerDuty) send(ctx context.Context, event map[string]any) error {
+	url := p.URL
+	if url == "" {
+		url = PagerDutyEventsURL
+	}
+	return postJSON(ctx, p.Client, url, event)
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
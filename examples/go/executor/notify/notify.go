@@ -0,0 +1,287 @@
+// Package notify tells people about escalations waiting for them.
+//
+// Dispatcher is an engine.AuditSink that watches for escalated decisions and
+// notifies each one through the notifiers configured for its queue — Slack,
+// email or PagerDuty — with deep links to the review UI, the decision, and
+// the endpoint that resolves it. Notifiers that can also close what they
+// opened, like a PagerDuty incident, are told when the escalation is
+// resolved.
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"covenant-poc/executor/engine"
+)
+
+// AnyQueue configures the notifiers of queues without their own entry.
+const AnyQueue = "*"
+
+// defaultQueueSize bounds the notifications awaiting delivery.
+const defaultQueueSize = 256
+
+// notificationCounts is published at /debug/vars as covenant_notifications.
+var notificationCounts = expvar.NewMap("covenant_notifications")
+
+// Notification describes one escalation to the people who decide it.
+type Notification struct {
+	EscalationID string
+	InvocationID string
+	Operation    string
+	Queue        string
+	Rule         string
+	Reason       string
+	CreatedAt    time.Time
+	DueAt        time.Time // zero if the queue has no SLA
+	ExpiresAt    time.Time // zero if the queue has no hard expiry
+	OnExpiry     string
+
+	DecisionURL   string // the escalated decision's audit record
+	EscalationURL string // the escalation and its status
+	ReviewURL     string // the escalation in the review UI
+	// ResolveURL accepts a POST of {"decision": "execute"|"deny",
+	// "resolved_by": ..., "justification": ...}.
+	ResolveURL string
+
+	// Resolution is set when the escalation has been resolved.
+	Resolution *engine.EscalationResolution
+}
+
+// Summary is a one-line description of the escalation.
+func (n Notification) Summary() string {
+	return fmt.Sprintf("%s escalated to %s by rule %s: %s", n.Operation, n.Queue, n.Rule, n.Reason)
+}
+
+// Notifier delivers notifications of new escalations.
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// Resolver is implemented by notifiers that close their notification once
+// the escalation is resolved.
+type Resolver interface {
+	Resolve(ctx context.Context, n Notification) error
+}
+
+// Escalations looks up escalations; engine.EscalationStore implements it.
+type Escalations interface {
+	Escalation(ctx context.Context, id string) (*engine.Escalation, error)
+}
+
+// Config configures a Dispatcher. Zero values take the documented defaults.
+type Config struct {
+	// Queues maps queue names, or AnyQueue, to their notifiers.
+	Queues map[string][]Notifier
+	// BaseURL is where the executor is reachable by the people notified,
+	// e.g. "https://covenant.example.com"; deep links are relative to it.
+	BaseURL   string
+	QueueSize int // notifications buffered for delivery (default 256)
+}
+
+// Dispatcher notifies escalations asynchronously. When its queue is full,
+// notifications are dropped and counted rather than delaying evaluation.
+type Dispatcher struct {
+	cfg         Config
+	escalations Escalations
+	queue       chan *engine.AuditRecord
+	wg          sync.WaitGroup
+}
+
+// NewDispatcher starts a Dispatcher that looks up escalated decisions in
+// escalations and notifies them as cfg configures.
+func NewDispatcher(cfg Config, escalations Escalations) *Dispatcher {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultQueueSize
+	}
+	cfg.BaseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+	d := &Dispatcher{cfg: cfg, escalations: escalations, queue: make(chan *engine.AuditRecord, cfg.QueueSize)}
+	d.wg.Add(1)
+	go d.run()
+	return d
+}
+
+// Record implements engine.AuditSink. Only the records of live escalations
+// and their resolutions are notified.
+func (d *Dispatcher) Record(_ context.Context, rec *engine.AuditRecord) {
+	if !Notifies(rec) {
+		return
+	}
+	select {
+	case d.queue <- rec:
+	default:
+		notificationCounts.Add("dropped", 1)
+	}
+}
+
+// Close waits for queued notifications to be delivered. The Dispatcher must
+// not receive records after Close.
+func (d *Dispatcher) Close() {
+	close(d.queue)
+	d.wg.Wait()
+}
+
+// Notifies reports whether rec is notified: it is the record of a live
+// escalation or of its resolution.
+func Notifies(rec *engine.AuditRecord) bool {
+	return !rec.DryRun && (rec.Resolution != nil || rec.EscalationID != "")
+}
+
+// Deliver notifies rec synchronously, for delivery through an outbox rather
+// than the Dispatcher's queue, and returns the notifiers' failures joined.
+// Notifiers that succeeded are notified again when a failed delivery is
+// retried.
+func (d *Dispatcher) Deliver(ctx context.Context, rec *engine.AuditRecord) error {
+	return d.dispatch(ctx, rec)
+}
+
+func (d *Dispatcher) run() {
+	defer d.wg.Done()
+	for rec := range d.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		d.dispatch(ctx, rec)
+		cancel()
+	}
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context, rec *engine.AuditRecord) error {
+	id := rec.EscalationID
+	if rec.Resolution != nil {
+		id = rec.Resolution.EscalationID
+	}
+	esc, err := d.escalations.Escalation(ctx, id)
+	if err != nil {
+		notificationCounts.Add("failed", 1)
+		log.Printf("notify: escalation %s: %v", id, err)
+		return fmt.Errorf("escalation %s: %w", id, err)
+	}
+	n := d.notification(esc)
+	n.Resolution = rec.Resolution
+
+	var errs []error
+	for _, notifier := range d.notifiers(esc.Queue) {
+		if n.Resolution == nil {
+			err = notifier.Notify(ctx, n)
+		} else if r, ok := notifier.(Resolver); ok {
+			err = r.Resolve(ctx, n)
+		} else {
+			continue
+		}
+		if err != nil {
+			notificationCounts.Add("failed", 1)
+			log.Printf("notify: escalation %s: %T: %v", id, notifier, err)
+			errs = append(errs, fmt.Errorf("%T: %w", notifier, err))
+			continue
+		}
+		notificationCounts.Add("sent", 1)
+	}
+	return errors.Join(errs...)
+}
+
+func (d *Dispatcher) notifiers(queue string) []Notifier {
+	if ns, ok := d.cfg.Queues[queue]; ok {
+		return ns
+	}
+	return d.cfg.Queues[AnyQueue]
+}
+
+func (d *Dispatcher) notification(esc *engine.Escalation) Notification {
+	return Notification{
+		EscalationID:  esc.ID,
+		InvocationID:  esc.InvocationID,
+		Operation:     esc.Operation,
+		Queue:         esc.Queue,
+		Rule:          esc.Rule,
+		Reason:        esc.Reason,
+		CreatedAt:     esc.CreatedAt,
+		DueAt:         esc.DueAt,
+		ExpiresAt:     esc.ExpiresAt,
+		OnExpiry:      esc.OnExpiry,
+		DecisionURL:   d.cfg.BaseURL + "/decisions/" + esc.InvocationID,
+		EscalationURL: d.cfg.BaseURL + "/escalations/" + esc.ID,
+		ReviewURL:     d.cfg.BaseURL + "/ui/escalations.html#" + esc.ID,
+		ResolveURL:    d.cfg.BaseURL + "/escalations/" + esc.ID + "/resolve",
+	}
+}
+
+// Target configures one notifier in a notifier file. Type selects the
+// notifier; the other fields are those it needs.
+type Target struct {
+	Type string `json:"type"` // slack, email or pagerduty
+
+	// Slack
+	WebhookURL string `json:"webhook_url,omitempty"`
+
+	// Email
+	SMTPAddr string   `json:"smtp_addr,omitempty"` // host:port
+	Username string   `json:"username,omitempty"`  // PLAIN auth, if set
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from,omitempty"`
+	To       []string `json:"to,omitempty"`
+
+	// PagerDuty
+	RoutingKey string `json:"routing_key,omitempty"`
+	Severity   string `json:"severity,omitempty"` // critical, error, warning (default) or info
+}
+
+// LoadFile reads a notifier file: a JSON object mapping queue names, or
+// AnyQueue, to the targets that queue notifies, e.g.
+//
+//	{"payment-review": [{"type": "slack", "webhook_url": "https://hooks.slack.com/..."},
+//	                    {"type": "pagerduty", "routing_key": "..."}]}
+func LoadFile(path string) (map[string][]Notifier, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var targets map[string][]Target
+	if err := json.Unmarshal(data, &targets); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	queues := make(map[string][]Notifier, len(targets))
+	for queue, ts := range targets {
+		for i, t := range ts {
+			n, err := t.Notifier()
+			if err != nil {
+				return nil, fmt.Errorf("%s: queue %q: target %d: %w", path, queue, i, err)
+			}
+			queues[queue] = append(queues[queue], n)
+		}
+	}
+	return queues, nil
+}
+
+// Notifier returns the notifier t configures.
+func (t Target) Notifier() (Notifier, error) {
+	switch t.Type {
+	case "slack":
+		if t.WebhookURL == "" {
+			return nil, fmt.Errorf("slack needs a webhook_url")
+		}
+		return &Slack{WebhookURL: t.WebhookURL}, nil
+	case "email":
+		if t.SMTPAddr == "" || t.From == "" || len(t.To) == 0 {
+			return nil, fmt.Errorf("email needs an smtp_addr, from and to")
+		}
+		return &Email{Addr: t.SMTPAddr, Username: t.Username, Password: t.Password, From: t.From, To: t.To}, nil
+	case "pagerduty":
+		if t.RoutingKey == "" {
+			return nil, fmt.Errorf("pagerduty needs a routing_key")
+		}
+		switch t.Severity {
+		case "", "critical", "error", "warning", "info":
+		default:
+			return nil, fmt.Errorf("pagerduty severity must be critical, error, warning or info, got %q", t.Severity)
+		}
+		return &PagerDuty{RoutingKey: t.RoutingKey, Severity: t.Severity}, nil
+	}
+	return nil, fmt.Errorf("unknown notifier type %q (want slack, email or pagerduty)", t.Type)
+}
@@ -0,0 +1,174 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"covenant-poc/executor/engine"
+)
+
+type escalations map[string]*engine.Escalation
+
+func (m escalations) Escalation(_ context.Context, id string) (*engine.Escalation, error) {
+	if esc, ok := m[id]; ok {
+		return esc, nil
+	}
+	return nil, engine.ErrNotFound
+}
+
+func testEscalations() escalations {
+	created := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	return escalations{"esc_1": {
+		ID:           "esc_1",
+		InvocationID: "inv_1",
+		Operation:    "ProcessPayment",
+		Queue:        "payment-review",
+		Rule:         "large-payment-review",
+		Reason:       "Large payments require manual review",
+		Status:       engine.EscalationPending,
+		CreatedAt:    created,
+		DueAt:        created.Add(4 * time.Hour),
+	}}
+}
+
+// newReceiver records the JSON bodies POSTed to it.
+func newReceiver(t *testing.T) (*httptest.Server, func() []map[string]any) {
+	var mu sync.Mutex
+	var got []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var v map[string]any
+		if err := json.Unmarshal(body, &v); err != nil {
+			t.Errorf("invalid JSON %s", body)
+		}
+		mu.Lock()
+		got = append(got, v)
+		mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []map[string]any {
+		mu.Lock()
+		defer mu.Unlock()
+		return got
+	}
+}
+
+func TestDispatcher_notifiesQueueTargetsWithDeepLinks(t *testing.T) {
+	slack, slackGot := newReceiver(t)
+	other, otherGot := newReceiver(t)
+	d := NewDispatcher(Config{
+		Queues: map[string][]Notifier{
+			"payment-review": {&Slack{WebhookURL: slack.URL}},
+			AnyQueue:         {&Slack{WebhookURL: other.URL}},
+		},
+		BaseURL: "https://covenant.example.com/",
+	}, testEscalations())
+	d.Record(context.Background(), &engine.AuditRecord{InvocationID: "inv_1", Outcome: "escalated", EscalationID: "esc_1"})
+	d.Record(context.Background(), &engine.AuditRecord{InvocationID: "inv_2", Outcome: "executed"})
+	d.Close()
+
+	msgs := slackGot()
+	if len(msgs) != 1 || len(otherGot()) != 0 {
+		t.Fatalf("expected one message to the queue's webhook, got %v and %v", msgs, otherGot())
+	}
+	body, _ := json.Marshal(msgs[0])
+	for _, want := range []string{
+		"https://covenant.example.com/decisions/inv_1",
+		"https://covenant.example.com/ui/escalations.html#esc_1",
+		"POST https://covenant.example.com/escalations/esc_1/resolve",
+		"Decide by Fri, 01 May 2026 16:00:00 UTC",
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("expected %q in %s", want, body)
+		}
+	}
+}
+
+func TestDispatcher_resolvesPagerDutyIncident(t *testing.T) {
+	pd, got := newReceiver(t)
+	d := NewDispatcher(Config{Queues: map[string][]Notifier{
+		AnyQueue: {&PagerDuty{RoutingKey: "key", URL: pd.URL}, &Slack{WebhookURL: pd.URL}},
+	}}, testEscalations())
+	d.Record(context.Background(), &engine.AuditRecord{InvocationID: "inv_1", Outcome: "escalated", EscalationID: "esc_1"})
+	d.Record(context.Background(), &engine.AuditRecord{InvocationID: "inv_2", Outcome: "denied", Resolution: &engine.EscalationResolution{
+		EscalationID: "esc_1", EscalatedInvocationID: "inv_1", Decision: engine.DecisionDeny, ResolvedBy: "alice",
+	}})
+	d.Close()
+
+	events := got()
+	if len(events) != 3 {
+		t.Fatalf("expected a trigger, a Slack message and a resolve, got %v", events)
+	}
+	trigger, resolve := events[0], events[2]
+	if trigger["event_action"] != "trigger" || trigger["dedup_key"] != "esc_1" || trigger["payload"].(map[string]any)["severity"] != "warning" {
+		t.Errorf("unexpected trigger %v", trigger)
+	}
+	if resolve["event_action"] != "resolve" || resolve["dedup_key"] != "esc_1" {
+		t.Errorf("unexpected resolve %v", resolve)
+	}
+}
+
+func TestEmail_message(t *testing.T) {
+	var sent []byte
+	var to []string
+	e := &Email{Addr: "smtp.example.com:587", From: "covenant@example.com", To: []string{"ops@example.com"},
+		send: func(_ string, _ smtp.Auth, _ string, rcpt []string, msg []byte) error {
+			to, sent = rcpt, msg
+			return nil
+		}}
+	d := NewDispatcher(Config{Queues: map[string][]Notifier{AnyQueue: {e}}, BaseURL: "http://exec"}, testEscalations())
+	d.Record(context.Background(), &engine.AuditRecord{Outcome: "escalated", EscalationID: "esc_1"})
+	d.Close()
+
+	msg := string(sent)
+	for _, want := range []string{
+		"Subject: [payment-review] ProcessPayment escalated for review\r\n",
+		"Decision: http://exec/decisions/inv_1\r\n",
+		"Review: http://exec/ui/escalations.html#esc_1\r\n",
+		`"justification": "<why>"} to http://exec/escalations/esc_1/resolve`,
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("expected %q in %s", want, msg)
+		}
+	}
+	if len(to) != 1 || to[0] != "ops@example.com" {
+		t.Errorf("unexpected recipients %v", to)
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notify.json")
+	os.WriteFile(path, []byte(`{
+		"payment-review": [{"type": "slack", "webhook_url": "https://hooks.example.com/x"}, {"type": "pagerduty", "routing_key": "k"}],
+		"*": [{"type": "email", "smtp_addr": "localhost:25", "from": "a@example.com", "to": ["b@example.com"]}]
+	}`), 0o644)
+	queues, err := LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queues["payment-review"]) != 2 || len(queues[AnyQueue]) != 1 {
+		t.Errorf("unexpected notifiers %v", queues)
+	}
+
+	for target, want := range map[string]string{
+		`{"type": "sms"}`:   `unknown notifier type "sms"`,
+		`{"type": "slack"}`: `slack needs a webhook_url`,
+		`{"type": "pagerduty", "routing_key": "k", "severity": "low"}`: `pagerduty severity must be critical, error, warning or info, got "low"`,
+	} {
+		os.WriteFile(path, []byte(`{"q": [`+target+`]}`), 0o644)
+		if _, err := LoadFile(path); err == nil || !strings.Contains(err.Error(), `queue "q": target 0: `+want) {
+			t.Errorf("%s: got %v, want %s", target, err, want)
+		}
+	}
+}
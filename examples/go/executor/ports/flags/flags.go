@@ -0,0 +1,74 @@
+// Package flags exposes feature flags as contract facts.
+//
+// Port is a ports.Client that answers facts named "flags.<key>" by asking a
+// Provider, so contracts can gate rules on rollout state:
+//
+//	facts: "flags.new_refund_flow": {source: "port:flags", required: false, on_missing: "skip"}
+//
+// Provider mirrors the OpenFeature evaluation model — a flag key plus a
+// targeting key and attributes — so an OpenFeature or LaunchDarkly client is
+// wrapped with a ProviderFunc. Static is a file-backed provider for local use.
+package flags
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// FactPrefix is the namespace flag facts live under.
+const FactPrefix = "flags."
+
+// ErrFlagNotFound is returned by providers for unknown flags. The engine then
+// applies the fact's on_missing policy; "skip" treats the flag as off.
+var ErrFlagNotFound = errors.New("flag not found")
+
+// Target identifies who a flag is evaluated for (OpenFeature's evaluation
+// context).
+type Target struct {
+	Key        string
+	Attributes map[string]any
+}
+
+// Provider evaluates flags.
+type Provider interface {
+	Evaluate(ctx context.Context, flag string, target Target) (any, error)
+}
+
+// ProviderFunc adapts a function to Provider, e.g. for an OpenFeature client:
+//
+//	flags.ProviderFunc(func(ctx context.Context, flag string, t flags.Target) (any, error) {
+//		ec := openfeature.NewEvaluationContext(t.Key, t.Attributes)
+//		return client.BooleanValue(ctx, flag, false, ec)
+//	})
+type ProviderFunc func(ctx context.Context, flag string, target Target) (any, error)
+
+func (f ProviderFunc) Evaluate(ctx context.Context, flag string, target Target) (any, error) {
+	return f(ctx, flag, target)
+}
+
+// Port serves flag facts from a Provider.
+type Port struct {
+	provider Provider
+	keyFact  string
+}
+
+// NewPort returns a Port backed by p. The targeting key is read from the
+// request input fact keyFact (e.g. "customer.id"); the whole input is passed
+// as targeting attributes.
+func NewPort(p Provider, keyFact string) *Port {
+	return &Port{provider: p, keyFact: keyFact}
+}
+
+func (p *Port) Get(ctx context.Context, fact string, input map[string]any) (any, error) {
+	if !strings.HasPrefix(fact, FactPrefix) {
+		return nil, fmt.Errorf("unknown fact %q", fact)
+	}
+	key, _ := input[p.keyFact].(string)
+	return p.provider.Evaluate(ctx, strings.TrimPrefix(fact, FactPrefix), Target{Key: key, Attributes: input})
+}
+
+func (p *Port) Execute(_ context.Context, operation string, _ map[string]any) (map[string]any, error) {
+	return nil, fmt.Errorf("flags does not execute operation %q", operation)
+}
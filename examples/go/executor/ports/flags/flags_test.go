@@ -0,0 +1,99 @@
+package flags
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func pct(v float64) *float64 { return &v }
+
+func TestPort_Get_servesFlagFact(t *testing.T) {
+	p := NewPort(NewStatic(map[string]Flag{"new_flow": {Value: true}}), "customer.id")
+
+	v, err := p.Get(context.Background(), "flags.new_flow", map[string]any{"customer.id": "cust_1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v != true {
+		t.Errorf("expected true, got %v", v)
+	}
+}
+
+func TestPort_Get_unknownFlagIsNotFound(t *testing.T) {
+	p := NewPort(NewStatic(nil), "customer.id")
+
+	_, err := p.Get(context.Background(), "flags.missing", nil)
+	if !errors.Is(err, ErrFlagNotFound) {
+		t.Errorf("expected ErrFlagNotFound, got %v", err)
+	}
+}
+
+func TestPort_Get_passesTargetToProvider(t *testing.T) {
+	var got Target
+	p := NewPort(ProviderFunc(func(_ context.Context, _ string, tg Target) (any, error) {
+		got = tg
+		return "v", nil
+	}), "customer.id")
+
+	p.Get(context.Background(), "flags.x", map[string]any{"customer.id": "cust_9", "region": "eu"})
+	if got.Key != "cust_9" || got.Attributes["region"] != "eu" {
+		t.Errorf("unexpected target %+v", got)
+	}
+}
+
+func TestStatic_Evaluate_rolloutIsStableAndProportional(t *testing.T) {
+	s := NewStatic(map[string]Flag{"f": {Value: true, Rollout: pct(25)}})
+
+	on := 0
+	for i := 0; i < 4000; i++ {
+		key := fmt.Sprintf("cust_%d", i)
+		v1, _ := s.Evaluate(context.Background(), "f", Target{Key: key})
+		v2, _ := s.Evaluate(context.Background(), "f", Target{Key: key})
+		if v1 != v2 {
+			t.Fatalf("rollout not stable for %s", key)
+		}
+		if v1 == true {
+			on++
+		}
+	}
+	if on < 800 || on > 1200 {
+		t.Errorf("expected ~1000 of 4000 targets in a 25%% rollout, got %d", on)
+	}
+}
+
+func TestStatic_Evaluate_offValueOutsideRollout(t *testing.T) {
+	s := NewStatic(map[string]Flag{"f": {Value: "v2", Rollout: pct(0), Off: "v1"}})
+
+	v, _ := s.Evaluate(context.Background(), "f", Target{Key: "anyone"})
+	if v != "v1" {
+		t.Errorf("expected off value v1, got %v", v)
+	}
+}
+
+func TestStatic_ReloadFile_replacesFlags(t *testing.T) {
+	s := NewStatic(map[string]Flag{"old": {Value: true}})
+	path := filepath.Join(t.TempDir(), "flags.json")
+	if err := os.WriteFile(path, []byte(`{"new": {"value": true}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ReloadFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := s.Evaluate(context.Background(), "new", Target{}); v != true {
+		t.Errorf("expected new flag on, got %v", v)
+	}
+	if _, err := s.Evaluate(context.Background(), "old", Target{}); !errors.Is(err, ErrFlagNotFound) {
+		t.Errorf("expected old flag removed, got %v", err)
+	}
+
+	if err := s.ReloadFile(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+	if v, _ := s.Evaluate(context.Background(), "new", Target{}); v != true {
+		t.Errorf("expected flags kept after a failed reload, got %v", v)
+	}
+}
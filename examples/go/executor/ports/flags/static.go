@@ -0,0 +1,91 @@
+package flags
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sync"
+)
+
+// Flag is one statically configured flag.
+type Flag struct {
+	Value any `json:"value"`
+	// Rollout, when set, serves Value to this percentage (0–100) of targets,
+	// chosen by a stable hash of flag and targeting key, and Off to the rest.
+	Rollout *float64 `json:"rollout,omitempty"`
+	// Off is served outside the rollout; defaults to false.
+	Off any `json:"off,omitempty"`
+}
+
+// Static is an in-memory Provider. Flags can be changed at runtime with Set.
+type Static struct {
+	mu    sync.RWMutex
+	flags map[string]Flag
+}
+
+// NewStatic returns a provider serving the given flags.
+func NewStatic(flags map[string]Flag) *Static {
+	s := &Static{flags: map[string]Flag{}}
+	for k, f := range flags {
+		s.flags[k] = f
+	}
+	return s
+}
+
+// LoadFile reads a JSON object of flag key → Flag.
+func LoadFile(path string) (*Static, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var flags map[string]Flag
+	if err := json.Unmarshal(data, &flags); err != nil {
+		return nil, fmt.Errorf("parse flags %s: %w", path, err)
+	}
+	return NewStatic(flags), nil
+}
+
+// ReloadFile replaces every flag with those in the JSON file at path,
+// keeping the current flags if it cannot be read.
+func (s *Static) ReloadFile(path string) error {
+	next, err := LoadFile(path)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flags = next.flags
+	return nil
+}
+
+// Set adds or replaces a flag.
+func (s *Static) Set(key string, f Flag) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flags[key] = f
+}
+
+func (s *Static) Evaluate(_ context.Context, flag string, target Target) (any, error) {
+	s.mu.RLock()
+	f, ok := s.flags[flag]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrFlagNotFound, flag)
+	}
+	if f.Rollout == nil || bucket(flag, target.Key) < *f.Rollout {
+		return f.Value, nil
+	}
+	if f.Off == nil {
+		return false, nil
+	}
+	return f.Off, nil
+}
+
+// bucket maps (flag, key) to a stable percentage in [0, 100).
+func bucket(flag, key string) float64 {
+	h := fnv.New32a()
+	h.Write([]byte(flag + "/" + key))
+	return float64(h.Sum32()%10000) / 100
+}
@@ -0,0 +1,104 @@
+// Package geoip exposes where a request comes from as contract facts.
+//
+// Port is a ports.Client that resolves the request's IP address, the input
+// fact request.ip, with a Backend and answers facts named "geo.<field>", so
+// contracts can deny or escalate by location without a port of their own:
+//
+//	facts: "geo.country": {source: "port:geo", required: false, on_missing: "skip"}
+//	rules: [{id: "no-tor", when: {fact: "geo.is_tor", equals: true}, verdict: {deny: {...}}}]
+//
+// The fields are country (an ISO 3166-1 alpha-2 code), is_datacenter and
+// is_tor. A MaxMind or IPinfo database is wrapped with a BackendFunc; Ranges
+// is a file-backed backend for local use.
+package geoip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// FactPrefix is the namespace geo facts live under.
+const FactPrefix = "geo."
+
+// DefaultIPFact is the input fact holding the request's IP address.
+const DefaultIPFact = "request.ip"
+
+// ErrNotFound is returned by backends for addresses they know nothing
+// about. The engine then applies the fact's on_missing policy.
+var ErrNotFound = errors.New("address not found")
+
+// Location is what a backend knows about an address.
+type Location struct {
+	Country    string
+	Datacenter bool // a hosting or cloud provider's network
+	Tor        bool // a Tor exit node
+}
+
+// Backend resolves addresses.
+type Backend interface {
+	Lookup(ctx context.Context, ip netip.Addr) (Location, error)
+}
+
+// BackendFunc adapts a function to Backend, e.g. for a MaxMind reader:
+//
+//	geoip.BackendFunc(func(_ context.Context, ip netip.Addr) (geoip.Location, error) {
+//		rec, err := reader.Country(ip.AsSlice())
+//		if err != nil {
+//			return geoip.Location{}, err
+//		}
+//		return geoip.Location{Country: rec.Country.IsoCode}, nil
+//	})
+type BackendFunc func(ctx context.Context, ip netip.Addr) (Location, error)
+
+func (f BackendFunc) Lookup(ctx context.Context, ip netip.Addr) (Location, error) {
+	return f(ctx, ip)
+}
+
+// Port serves geo facts from a Backend.
+type Port struct {
+	backend Backend
+	ipFact  string
+}
+
+// NewPort returns a Port backed by b, reading the address from the input
+// fact ipFact (e.g. DefaultIPFact).
+func NewPort(b Backend, ipFact string) *Port {
+	return &Port{backend: b, ipFact: ipFact}
+}
+
+func (p *Port) Get(ctx context.Context, fact string, input map[string]any) (any, error) {
+	field, ok := strings.CutPrefix(fact, FactPrefix)
+	if !ok || (field != "country" && field != "is_datacenter" && field != "is_tor") {
+		return nil, fmt.Errorf("unknown fact %q", fact)
+	}
+	s, _ := input[p.ipFact].(string)
+	if s == "" {
+		return nil, fmt.Errorf("%s: input lacks %s", fact, p.ipFact)
+	}
+	ip, err := netip.ParseAddr(s)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fact, err)
+	}
+	loc, err := p.backend.Lookup(ctx, ip.Unmap())
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fact, err)
+	}
+	switch field {
+	case "country":
+		if loc.Country == "" {
+			return nil, fmt.Errorf("%s: %w", fact, ErrNotFound)
+		}
+		return loc.Country, nil
+	case "is_datacenter":
+		return loc.Datacenter, nil
+	default:
+		return loc.Tor, nil
+	}
+}
+
+func (p *Port) Execute(_ context.Context, operation string, _ map[string]any) (map[string]any, error) {
+	return nil, fmt.Errorf("geo does not execute operation %q", operation)
+}
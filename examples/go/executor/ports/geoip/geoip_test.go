@@ -0,0 +1,73 @@
+package geoip
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const ranges = `# network,country,tags...
+198.51.100.0/24,DE
+203.0.113.0/24,NL,datacenter
+203.0.113.7,NL,datacenter,tor
+2001:db8::/32,US
+2001:db8:1::/48,,datacenter
+`
+
+func TestPort_Get_resolvesRequestIP(t *testing.T) {
+	r, err := ParseRanges(strings.NewReader(ranges))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewPort(r, DefaultIPFact)
+	ctx := context.Background()
+	for _, tc := range []struct {
+		ip, fact string
+		want     any
+	}{
+		{"198.51.100.20", "geo.country", "DE"},
+		{"198.51.100.20", "geo.is_datacenter", false},
+		{"203.0.113.9", "geo.is_datacenter", true},
+		{"203.0.113.9", "geo.is_tor", false},
+		// The most specific network wins.
+		{"203.0.113.7", "geo.is_tor", true},
+		{"::ffff:203.0.113.7", "geo.is_tor", true},
+		{"2001:db8:ffff::1", "geo.country", "US"},
+		{"2001:db8:1::1", "geo.is_datacenter", true},
+	} {
+		v, err := p.Get(ctx, tc.fact, map[string]any{"request.ip": tc.ip})
+		if err != nil || v != tc.want {
+			t.Errorf("%s %s: expected %v, got %v, %v", tc.ip, tc.fact, tc.want, v, err)
+		}
+	}
+}
+
+func TestPort_Get_unknownAddressIsNotFound(t *testing.T) {
+	r, err := ParseRanges(strings.NewReader(ranges))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewPort(r, DefaultIPFact)
+	ctx := context.Background()
+
+	for _, ip := range []string{"192.0.2.1", "2001:db8:1::1"} {
+		if _, err := p.Get(ctx, "geo.country", map[string]any{"request.ip": ip}); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: expected ErrNotFound, got %v", ip, err)
+		}
+	}
+	if _, err := p.Get(ctx, "geo.country", map[string]any{"request.ip": "not-an-ip"}); err == nil {
+		t.Error("expected an error for a malformed address")
+	}
+	if _, err := p.Get(ctx, "geo.city", map[string]any{"request.ip": "198.51.100.20"}); err == nil {
+		t.Error("expected an error for an unknown fact")
+	}
+}
+
+func TestParseRanges_rejectsMalformedLines(t *testing.T) {
+	for _, in := range []string{"198.51.100.0/33,DE", "198.51.100.0/24", "198.51.100.0/24,DE,mobile"} {
+		if _, err := ParseRanges(strings.NewReader(in)); err == nil {
+			t.Errorf("%q: expected an error", in)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package geoip
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Ranges is an in-memory Backend resolving each address to the location of
+// the most specific network containing it.
+type Ranges struct {
+	bits     []int // prefix lengths present, longest first
+	networks map[int]map[netip.Prefix]Location
+}
+
+// NewRanges returns a backend resolving the addresses in each network to
+// its location.
+func NewRanges(networks map[netip.Prefix]Location) *Ranges {
+	r := &Ranges{networks: map[int]map[netip.Prefix]Location{}}
+	for p, loc := range networks {
+		r.add(p, loc)
+	}
+	return r
+}
+
+func (r *Ranges) add(p netip.Prefix, loc Location) {
+	p = p.Masked()
+	byBits := r.networks[p.Bits()]
+	if byBits == nil {
+		byBits = map[netip.Prefix]Location{}
+		r.networks[p.Bits()] = byBits
+		r.bits = append(r.bits, p.Bits())
+		slices.SortFunc(r.bits, func(a, b int) int { return b - a })
+	}
+	byBits[p] = loc
+}
+
+// ParseRanges reads CSV lines of network, country and any of the tags
+// datacenter and tor; lines starting with # are comments:
+//
+//	# network,country,tags...
+//	203.0.113.0/24,NL,datacenter
+//	203.0.113.7,NL,datacenter,tor
+//
+// A bare address is a network of one, and a network listed again replaces
+// the earlier line. The country may be empty for networks known only by
+// their tags.
+func ParseRanges(in io.Reader) (*Ranges, error) {
+	cr := csv.NewReader(in)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	r := NewRanges(nil)
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return r, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		if len(row) < 2 {
+			return nil, fmt.Errorf("line %d: expected network,country", line)
+		}
+		p, err := parseNetwork(strings.TrimSpace(row[0]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		loc := Location{Country: strings.ToUpper(strings.TrimSpace(row[1]))}
+		for _, tag := range row[2:] {
+			switch strings.TrimSpace(tag) {
+			case "datacenter":
+				loc.Datacenter = true
+			case "tor":
+				loc.Tor = true
+			case "":
+			default:
+				return nil, fmt.Errorf("line %d: unknown tag %q", line, tag)
+			}
+		}
+		r.add(p, loc)
+	}
+}
+
+func parseNetwork(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		return netip.ParsePrefix(s)
+	}
+	ip, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(ip, ip.BitLen()), nil
+}
+
+// LoadFile reads a ranges file as ParseRanges does.
+func LoadFile(path string) (*Ranges, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := ParseRanges(f)
+	if err != nil {
+		return nil, fmt.Errorf("parse geoip ranges %s: %w", path, err)
+	}
+	return r, nil
+}
+
+func (r *Ranges) Lookup(_ context.Context, ip netip.Addr) (Location, error) {
+	for _, bits := range r.bits {
+		if bits > ip.BitLen() {
+			continue
+		}
+		p, err := ip.Prefix(bits)
+		if err != nil {
+			continue
+		}
+		if loc, ok := r.networks[bits][p]; ok {
+			return loc, nil
+		}
+	}
+	return Location{}, fmt.Errorf("%w: %s", ErrNotFound, ip)
+}
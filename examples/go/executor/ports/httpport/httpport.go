@@ -0,0 +1,380 @@
+// Package httpport serves port facts and operations from upstream HTTP APIs
+// declared in configuration, so that a contract can execute against a real
+// service without a Go adapter.
+//
+// Each fact and operation of a port maps to a request template:
+//
+//	{"invoiceRepo": {
+//	  "base_url": "https://payments.example.com/v1",
+//	  "headers": {"Authorization": "Bearer ${PAYMENTS_TOKEN}"},
+//	  "facts": {
+//	    "invoice.status": {"url": "/invoices/{{invoice.id}}", "result": "status"}
+//	  },
+//	  "operations": {
+//	    "ProcessPayment": {
+//	      "method": "POST",
+//	      "url": "/invoices/{{invoice.id}}/payments",
+//	      "body": {"amount": "{{payment.amount.value}}", "currency": "{{payment.amount.currency}}"},
+//	      "output": {"payment_id": "id", "status": "status", "new_balance": "invoice.balance"}
+//	    }
+//	  }
+//	}}
+//
+// {{name}} placeholders are replaced by input facts. A name may continue
+// into a fact's value, as payment.amount.value does into payment.amount. In
+// a URL the value is path-escaped; in a body, a string that is a single
+// placeholder is replaced by the value itself, keeping its JSON type, and
+// placeholders within longer strings are formatted as text. ${VAR} in
+// header values is replaced by the environment variable, keeping secrets out
+// of the file.
+//
+// A fact is the response field named by result, and an operation's output
+// maps its fields to response fields; either defaults to the whole response.
+// Field names are dotted paths into the response, with numbers indexing
+// arrays. A response other than 2xx fails the fact or operation.
+package httpport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"covenant-poc/executor/ports"
+)
+
+// defaultTimeout bounds a request unless the port configures a timeout.
+const defaultTimeout = 10 * time.Second
+
+// maxErrorBody is how much of a failed response is quoted in its error.
+const maxErrorBody = 512
+
+// Config declares a port served by an HTTP API.
+type Config struct {
+	BaseURL string            `json:"base_url"`
+	Headers map[string]string `json:"headers,omitempty"` // sent with every request
+	Timeout string            `json:"timeout,omitempty"` // per request, e.g. 5s; default 10s
+
+	Facts      map[string]Request `json:"facts,omitempty"`
+	Operations map[string]Request `json:"operations,omitempty"`
+}
+
+// Request is the template of the request serving a fact or operation.
+type Request struct {
+	Method  string            `json:"method,omitempty"` // default GET, or POST with a body
+	URL     string            `json:"url"`              // appended to the base URL
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    any               `json:"body,omitempty"` // JSON template
+
+	// Result is the response field holding a fact's value.
+	Result string `json:"result,omitempty"`
+	// Output maps an operation's output fields to response fields.
+	Output map[string]string `json:"output,omitempty"`
+}
+
+// Port is a ports.Client that serves facts and operations with requests to
+// an HTTP API.
+type Port struct {
+	cfg    Config
+	client *http.Client
+}
+
+// NewPort returns a Port serving cfg's facts and operations.
+func NewPort(cfg Config) (*Port, error) {
+	if _, err := url.Parse(cfg.BaseURL); err != nil || cfg.BaseURL == "" {
+		return nil, fmt.Errorf("base_url %q is not a URL", cfg.BaseURL)
+	}
+	timeout := defaultTimeout
+	if cfg.Timeout != "" {
+		d, err := time.ParseDuration(cfg.Timeout)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("timeout %q is not a positive duration", cfg.Timeout)
+		}
+		timeout = d
+	}
+	cfg.Headers = expandEnv(cfg.Headers)
+	for kind, reqs := range map[string]map[string]Request{"fact": cfg.Facts, "operation": cfg.Operations} {
+		for name, r := range reqs {
+			if r.Method != "" && !validMethod(r.Method) {
+				return nil, fmt.Errorf("%s %s: unsupported method %q", kind, name, r.Method)
+			}
+			if r.Result != "" && kind == "operation" {
+				return nil, fmt.Errorf("operation %s: result applies to facts; map responses with output", name)
+			}
+			if r.Output != nil && kind == "fact" {
+				return nil, fmt.Errorf("fact %s: output applies to operations; pick a response field with result", name)
+			}
+			r.Headers = expandEnv(r.Headers)
+			reqs[name] = r
+		}
+	}
+	return &Port{cfg: cfg, client: &http.Client{Timeout: timeout}}, nil
+}
+
+// LoadFile reads a JSON object mapping port names to their Configs.
+func LoadFile(path string) (map[string]*Port, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfgs map[string]Config
+	if err := json.Unmarshal(data, &cfgs); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	ports := make(map[string]*Port, len(cfgs))
+	for name, cfg := range cfgs {
+		p, err := NewPort(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: port %q: %w", path, name, err)
+		}
+		ports[name] = p
+	}
+	return ports, nil
+}
+
+func (p *Port) Get(ctx context.Context, fact string, input map[string]any) (any, error) {
+	r, ok := p.cfg.Facts[fact]
+	if !ok {
+		return nil, fmt.Errorf("unknown fact %q", fact)
+	}
+	resp, err := p.do(ctx, r, input)
+	if err != nil {
+		return nil, err
+	}
+	return Field(resp, r.Result)
+}
+
+func (p *Port) Execute(ctx context.Context, operation string, input map[string]any) (map[string]any, error) {
+	r, ok := p.cfg.Operations[operation]
+	if !ok {
+		return nil, fmt.Errorf("unknown operation %q", operation)
+	}
+	resp, err := p.do(ctx, r, input)
+	if err != nil {
+		return nil, err
+	}
+	if r.Output == nil {
+		out, ok := resp.(map[string]any)
+		if !ok && resp != nil {
+			return nil, fmt.Errorf("%s response is not an object; map it with output", operation)
+		}
+		return out, nil
+	}
+	out := make(map[string]any, len(r.Output))
+	for field, path := range r.Output {
+		v, err := Field(resp, path)
+		if err != nil {
+			return nil, fmt.Errorf("output %s: %w", field, err)
+		}
+		out[field] = v
+	}
+	return out, nil
+}
+
+// do sends r, filled in from input, and decodes its JSON response; an empty
+// response decodes to nil.
+func (p *Port) do(ctx context.Context, r Request, input map[string]any) (any, error) {
+	target, err := interpolate(r.URL, input, url.PathEscape)
+	if err != nil {
+		return nil, fmt.Errorf("url: %w", err)
+	}
+	var body io.Reader
+	if r.Body != nil {
+		filled, err := fill(r.Body, input)
+		if err != nil {
+			return nil, fmt.Errorf("body: %w", err)
+		}
+		data, err := json.Marshal(filled)
+		if err != nil {
+			return nil, fmt.Errorf("body: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+	method := r.Method
+	if method == "" {
+		method = http.MethodGet
+		if body != nil {
+			method = http.MethodPost
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(p.cfg.BaseURL, "/")+target, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range p.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	for k, v := range r.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		if len(data) > maxErrorBody {
+			data = data[:maxErrorBody]
+		}
+		return nil, fmt.Errorf("%s %s: HTTP %d: %s", method, req.URL.Path, resp.StatusCode, bytes.TrimSpace(data))
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("%s %s: response: %w", method, req.URL.Path, err)
+	}
+	return v, nil
+}
+
+// Field returns the field of v at a dotted path, where numbers index arrays.
+// The empty path is v itself.
+func Field(v any, path string) (any, error) {
+	if path == "" {
+		return v, nil
+	}
+	for _, name := range strings.Split(path, ".") {
+		switch c := v.(type) {
+		case map[string]any:
+			f, ok := c[name]
+			if !ok {
+				return nil, fmt.Errorf("response has no field %s", path)
+			}
+			v = f
+		case []any:
+			i, err := strconv.Atoi(name)
+			if err != nil || i < 0 || i >= len(c) {
+				return nil, fmt.Errorf("response has no field %s", path)
+			}
+			v = c[i]
+		default:
+			return nil, fmt.Errorf("response has no field %s", path)
+		}
+	}
+	return v, nil
+}
+
+// interpolate replaces the {{name}} placeholders in s with the escaped text
+// of the input facts they name.
+func interpolate(s string, input map[string]any, escape func(string) string) (string, error) {
+	var b strings.Builder
+	for {
+		start := strings.Index(s, "{{")
+		if start < 0 {
+			b.WriteString(s)
+			return b.String(), nil
+		}
+		end := strings.Index(s[start:], "}}")
+		if end < 0 {
+			return "", fmt.Errorf("unclosed placeholder in %q", s)
+		}
+		name := strings.TrimSpace(s[start+2 : start+end])
+		v, ok := ports.Lookup(input, name)
+		if !ok {
+			return "", fmt.Errorf("input has no fact %s", name)
+		}
+		b.WriteString(s[:start])
+		b.WriteString(escape(text(v)))
+		s = s[start+end+2:]
+	}
+}
+
+// fill returns a copy of the body template t with its placeholders
+// replaced.
+func fill(t any, input map[string]any) (any, error) {
+	switch t := t.(type) {
+	case string:
+		if name, ok := placeholder(t); ok {
+			v, ok := ports.Lookup(input, name)
+			if !ok {
+				return nil, fmt.Errorf("input has no fact %s", name)
+			}
+			return v, nil
+		}
+		return interpolate(t, input, func(s string) string { return s })
+	case map[string]any:
+		out := make(map[string]any, len(t))
+		for k, v := range t {
+			f, err := fill(v, input)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = f
+		}
+		return out, nil
+	case []any:
+		out := make([]any, len(t))
+		for i, v := range t {
+			f, err := fill(v, input)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = f
+		}
+		return out, nil
+	default:
+		return t, nil
+	}
+}
+
+// placeholder reports whether s is a single placeholder, and its name.
+func placeholder(s string) (string, bool) {
+	if !strings.HasPrefix(s, "{{") || !strings.HasSuffix(s, "}}") || strings.Count(s, "{{") != 1 {
+		return "", false
+	}
+	return strings.TrimSpace(s[2 : len(s)-2]), true
+}
+
+// text formats a fact for a URL or string.
+func text(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case nil:
+		return ""
+	default:
+		if data, err := json.Marshal(v); err == nil {
+			return string(data)
+		}
+		return fmt.Sprint(v)
+	}
+}
+
+func validMethod(m string) bool {
+	switch m {
+	case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// expandEnv returns headers with ${VAR} in their values replaced from the
+// environment.
+func expandEnv(headers map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+	out := make(map[string]string, len(headers))
+	for k, v := range headers {
+		out[k] = os.ExpandEnv(v)
+	}
+	return out
+}
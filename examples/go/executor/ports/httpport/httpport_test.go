@@ -0,0 +1,118 @@
+package httpport
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const config = `{"invoiceRepo": {
+	"base_url": "%s/v1",
+	"headers": {"Authorization": "Bearer ${PAYMENTS_TOKEN}"},
+	"facts": {
+		"invoice.status": {"url": "/invoices/{{invoice.id}}", "result": "status"},
+		"invoice.lines": {"url": "/invoices/{{invoice.id}}/lines", "result": "data.1.sku"}
+	},
+	"operations": {
+		"ProcessPayment": {
+			"method": "POST",
+			"url": "/invoices/{{invoice.id}}/payments",
+			"body": {"amount": "{{payment.amount}}", "currency": "{{payment.amount.currency}}", "memo": "invoice {{invoice.id}}"},
+			"output": {"payment_id": "id", "new_balance": "invoice.balance"}
+		}
+	}
+}}`
+
+func loadPort(t *testing.T, handler http.HandlerFunc) *Port {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	t.Setenv("PAYMENTS_TOKEN", "s3cret")
+	path := filepath.Join(t.TempDir(), "ports.json")
+	if err := os.WriteFile(path, []byte(strings.Replace(config, "%s", srv.URL, 1)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ports, err := LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ports["invoiceRepo"]
+}
+
+func TestPort_Execute_mapsRequestAndResponse(t *testing.T) {
+	var got map[string]any
+	p := loadPort(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.EscapedPath() != "/v1/invoices/inv%2F1/payments" || r.Header.Get("Authorization") != "Bearer s3cret" {
+			t.Errorf("unexpected request %s %s with %q", r.Method, r.URL.EscapedPath(), r.Header.Get("Authorization"))
+		}
+		data, _ := io.ReadAll(r.Body)
+		json.Unmarshal(data, &got)
+		w.Write([]byte(`{"id": "pay_1", "invoice": {"balance": {"value": 0, "currency": "USD"}}}`))
+	})
+
+	input := map[string]any{"invoice.id": "inv/1", "payment.amount": map[string]any{"value": 1500.0, "currency": "USD"}}
+	out, err := p.Execute(context.Background(), "ProcessPayment", input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"amount": map[string]any{"value": 1500.0, "currency": "USD"}, "currency": "USD", "memo": "invoice inv/1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("sent body %v, want %v", got, want)
+	}
+	wantOut := map[string]any{"payment_id": "pay_1", "new_balance": map[string]any{"value": 0.0, "currency": "USD"}}
+	if !reflect.DeepEqual(out, wantOut) {
+		t.Errorf("got output %v, want %v", out, wantOut)
+	}
+
+	if _, err := p.Execute(context.Background(), "ProcessPayment", map[string]any{"invoice.id": "inv_1"}); err == nil || !strings.Contains(err.Error(), "input has no fact payment.amount") {
+		t.Errorf("expected a missing fact error, got %v", err)
+	}
+	if _, err := p.Execute(context.Background(), "RefundPayment", input); err == nil {
+		t.Error("expected an unknown operation error")
+	}
+}
+
+func TestPort_Get(t *testing.T) {
+	p := loadPort(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/invoices/inv_1":
+			w.Write([]byte(`{"status": "approved"}`))
+		case "/v1/invoices/inv_1/lines":
+			w.Write([]byte(`{"data": [{"sku": "a"}, {"sku": "b"}]}`))
+		default:
+			http.Error(w, `{"error": "no such invoice"}`, http.StatusNotFound)
+		}
+	})
+	ctx := context.Background()
+
+	if v, err := p.Get(ctx, "invoice.status", map[string]any{"invoice.id": "inv_1"}); err != nil || v != "approved" {
+		t.Errorf("got %v, %v", v, err)
+	}
+	if v, err := p.Get(ctx, "invoice.lines", map[string]any{"invoice.id": "inv_1"}); err != nil || v != "b" {
+		t.Errorf("got %v, %v", v, err)
+	}
+	if _, err := p.Get(ctx, "invoice.status", map[string]any{"invoice.id": "inv_2"}); err == nil || !strings.Contains(err.Error(), "HTTP 404") {
+		t.Errorf("expected the upstream 404, got %v", err)
+	}
+}
+
+func TestNewPort_rejectsInvalidConfig(t *testing.T) {
+	for _, cfg := range []Config{
+		{},
+		{BaseURL: "http://x", Timeout: "soon"},
+		{BaseURL: "http://x", Facts: map[string]Request{"f": {URL: "/", Method: "CONNECT"}}},
+		{BaseURL: "http://x", Facts: map[string]Request{"f": {URL: "/", Output: map[string]string{"a": "b"}}}},
+		{BaseURL: "http://x", Operations: map[string]Request{"Op": {URL: "/", Result: "a"}}},
+	} {
+		if _, err := NewPort(cfg); err == nil {
+			t.Errorf("expected %+v rejected", cfg)
+		}
+	}
+}
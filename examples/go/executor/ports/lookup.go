@@ -0,0 +1,39 @@
+package ports
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Lookup returns the input fact called name or, failing that, the field of
+// the longest-named fact that name continues into, where numbers index
+// arrays. It reports whether the fact and the field exist.
+func Lookup(input map[string]any, name string) (any, bool) {
+	if v, ok := input[name]; ok {
+		return v, true
+	}
+	for i := strings.LastIndexByte(name, '.'); i > 0; i = strings.LastIndexByte(name[:i], '.') {
+		v, ok := input[name[:i]]
+		if !ok {
+			continue
+		}
+		for _, field := range strings.Split(name[i+1:], ".") {
+			switch c := v.(type) {
+			case map[string]any:
+				if v, ok = c[field]; !ok {
+					return nil, false
+				}
+			case []any:
+				n, err := strconv.Atoi(field)
+				if err != nil || n < 0 || n >= len(c) {
+					return nil, false
+				}
+				v = c[n]
+			default:
+				return nil, false
+			}
+		}
+		return v, true
+	}
+	return nil, false
+}
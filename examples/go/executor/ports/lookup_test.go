@@ -0,0 +1,30 @@
+package ports
+
+import "testing"
+
+func TestLookup(t *testing.T) {
+	input := map[string]any{
+		"customer.id":    "cust_1",
+		"payment.amount": map[string]any{"value": 12.5, "currency": "EUR"},
+		"invoice":        map[string]any{"lines": []any{map[string]any{"sku": "A1"}}},
+		"refund.reason":  nil,
+	}
+	for _, tc := range []struct {
+		name string
+		want any
+		ok   bool
+	}{
+		{"customer.id", "cust_1", true},
+		{"payment.amount.value", 12.5, true},
+		{"invoice.lines.0.sku", "A1", true},
+		{"refund.reason", nil, true},
+		{"payment.amount.fee", nil, false},
+		{"invoice.lines.1.sku", nil, false},
+		{"customer.id.prefix", nil, false},
+		{"customer.name", nil, false},
+	} {
+		if got, ok := Lookup(input, tc.name); got != tc.want || ok != tc.ok {
+			t.Errorf("Lookup(%q): expected %v %v, got %v %v", tc.name, tc.want, tc.ok, got, ok)
+		}
+	}
+}
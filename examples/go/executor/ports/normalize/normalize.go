@@ -0,0 +1,382 @@
+// Package normalize maps the values ports return onto the canonical shape
+// contracts expect, so a contract written against one upstream keeps
+// working against another that returns the same concept differently.
+//
+// A Port wraps a ports.Client and applies a Rule to each fact it gets and
+// each operation output it executes. A normalization file maps port names
+// to the rules of their facts and operations:
+//
+//	{"invoiceRepo": {
+//	  "facts": {
+//	    "invoice.balance": {
+//	      "pick": "data.balance",
+//	      "rename": {"amount": "value"},
+//	      "convert": {"value": {"scale": 0.01}, "": {"currency": "USD", "rates": {"EUR": 1.08}}}
+//	    },
+//	    "invoice.due_date": {"convert": {"": {"timestamp": "unix"}}}
+//	  }
+//	}}
+//
+// turns {"data": {"balance": {"amount": 150000, "currency": "eur"}}} into
+// {"value": 1620, "currency": "USD"}.
+package normalize
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"covenant-poc/executor/ports"
+)
+
+// Timestamp formats a Conversion parses besides Go layouts.
+const (
+	TimestampUnix   = "unix"    // seconds since the epoch
+	TimestampUnixMs = "unix_ms" // milliseconds since the epoch
+)
+
+// Rule normalizes a value in three steps: Pick selects a field of it, Rename
+// moves fields within what was picked, and Convert converts fields. Paths
+// are dotted field names; the empty path is the value itself. Renames and
+// conversions apply in the order of their paths.
+type Rule struct {
+	Pick    string                `json:"pick,omitempty"`
+	Rename  map[string]string     `json:"rename,omitempty"`  // from path → to path
+	Convert map[string]Conversion `json:"convert,omitempty"` // path → conversion
+}
+
+// Conversion converts one field. Exactly one of Scale, Currency and
+// Timestamp is set.
+type Conversion struct {
+	// Scale multiplies a number, e.g. 0.01 for cents to dollars or 3600 for
+	// hours to seconds.
+	Scale float64 `json:"scale,omitempty"`
+	// Currency converts a money object, {"value": ..., "currency": ...}, to
+	// this currency at Rates.
+	Currency string `json:"currency,omitempty"`
+	// Rates are the units of Currency one unit of each other currency buys.
+	Rates map[string]float64 `json:"rates,omitempty"`
+	// Timestamp parses a string, in this Go layout, or a number of
+	// TimestampUnix seconds or TimestampUnixMs milliseconds, into an RFC 3339
+	// UTC timestamp.
+	Timestamp string `json:"timestamp,omitempty"`
+}
+
+// Config holds the rules of a port's facts and operation outputs.
+type Config struct {
+	Facts      map[string]Rule `json:"facts,omitempty"`
+	Operations map[string]Rule `json:"operations,omitempty"`
+}
+
+// Validate reports the first malformed rule.
+func (c Config) Validate() error {
+	for kind, rules := range map[string]map[string]Rule{"fact": c.Facts, "operation": c.Operations} {
+		for name, r := range rules {
+			for path, conv := range r.Convert {
+				if err := conv.validate(); err != nil {
+					return fmt.Errorf("%s %s: convert %q: %w", kind, name, path, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (c Conversion) validate() error {
+	set := 0
+	for _, ok := range []bool{c.Scale != 0, c.Currency != "", c.Timestamp != ""} {
+		if ok {
+			set++
+		}
+	}
+	if set != 1 {
+		return fmt.Errorf("set exactly one of scale, currency and timestamp")
+	}
+	if c.Currency == "" && c.Rates != nil {
+		return fmt.Errorf("rates apply to currency conversions")
+	}
+	for cur, rate := range c.Rates {
+		if rate <= 0 {
+			return fmt.Errorf("rate of %s must be positive", cur)
+		}
+	}
+	return nil
+}
+
+// LoadFile reads a normalization file: a JSON object mapping port names to
+// their Configs.
+func LoadFile(path string) (map[string]Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfgs map[string]Config
+	if err := json.Unmarshal(data, &cfgs); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for name, cfg := range cfgs {
+		if err := cfg.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: port %q: %w", path, name, err)
+		}
+	}
+	return cfgs, nil
+}
+
+// Port is a ports.Client normalizing the facts and outputs of another.
+type Port struct {
+	client ports.Client
+	cfg    Config
+}
+
+// Wrap returns c with cfg's rules applied.
+func Wrap(c ports.Client, cfg Config) *Port {
+	return &Port{client: c, cfg: cfg}
+}
+
+// Get gets fact from the wrapped port and normalizes it. A fact that does
+// not fit its rule fails, applying the fact's on_missing.
+func (p *Port) Get(ctx context.Context, fact string, input map[string]any) (any, error) {
+	v, err := p.client.Get(ctx, fact, input)
+	if err != nil {
+		return nil, err
+	}
+	r, ok := p.cfg.Facts[fact]
+	if !ok {
+		return v, nil
+	}
+	v, err = r.Apply(v)
+	if err != nil {
+		return nil, fmt.Errorf("normalize %s: %w", fact, err)
+	}
+	return v, nil
+}
+
+// Execute executes operation on the wrapped port and normalizes its
+// output. The operation has taken effect by then, so an output that does
+// not fit its rule is logged and returned as the port gave it.
+func (p *Port) Execute(ctx context.Context, operation string, input map[string]any) (map[string]any, error) {
+	out, err := p.client.Execute(ctx, operation, input)
+	if err != nil {
+		return nil, err
+	}
+	r, ok := p.cfg.Operations[operation]
+	if !ok {
+		return out, nil
+	}
+	v, err := r.Apply(out)
+	if err == nil {
+		if m, ok := v.(map[string]any); ok {
+			return m, nil
+		}
+		err = fmt.Errorf("got %T, not an object", v)
+	}
+	log.Printf("normalize: %s output left as executed: %v", operation, err)
+	return out, nil
+}
+
+// Apply returns v normalized by r. v is not modified.
+func (r Rule) Apply(v any) (any, error) {
+	v, err := get(clone(v), r.Pick)
+	if err != nil {
+		return nil, err
+	}
+	for _, from := range slices.Sorted(maps.Keys(r.Rename)) {
+		to := r.Rename[from]
+		f, err := get(v, from)
+		if err != nil {
+			return nil, err
+		}
+		if v, err = del(v, from); err != nil {
+			return nil, err
+		}
+		if v, err = set(v, to, f); err != nil {
+			return nil, err
+		}
+	}
+	for _, path := range slices.Sorted(maps.Keys(r.Convert)) {
+		conv := r.Convert[path]
+		f, err := get(v, path)
+		if err != nil {
+			return nil, err
+		}
+		if f, err = conv.apply(f); err != nil {
+			return nil, fmt.Errorf("%s: %w", describe(path), err)
+		}
+		if v, err = set(v, path, f); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+func (c Conversion) apply(v any) (any, error) {
+	switch {
+	case c.Scale != 0:
+		n, ok := number(v)
+		if !ok {
+			return nil, fmt.Errorf("got %T, not a number", v)
+		}
+		return n * c.Scale, nil
+	case c.Currency != "":
+		return c.convertCurrency(v)
+	default:
+		return c.parseTimestamp(v)
+	}
+}
+
+func (c Conversion) convertCurrency(v any) (any, error) {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("got %T, not a money object", v)
+	}
+	n, ok := number(m["value"])
+	cur, _ := m["currency"].(string)
+	if !ok || cur == "" {
+		return nil, fmt.Errorf("money needs a numeric value and a currency")
+	}
+	cur, to := strings.ToUpper(cur), strings.ToUpper(c.Currency)
+	if cur != to {
+		rate, ok := c.Rates[cur]
+		if !ok {
+			return nil, fmt.Errorf("no rate from %s to %s", cur, to)
+		}
+		// Rounded to hundredths of a minor unit, hiding float error.
+		n = math.Round(n*rate*1e4) / 1e4
+	}
+	m["value"], m["currency"] = n, to
+	return m, nil
+}
+
+func (c Conversion) parseTimestamp(v any) (any, error) {
+	var t time.Time
+	switch c.Timestamp {
+	case TimestampUnix, TimestampUnixMs:
+		n, ok := number(v)
+		if !ok {
+			return nil, fmt.Errorf("got %T, not a number", v)
+		}
+		if c.Timestamp == TimestampUnixMs {
+			t = time.UnixMilli(int64(n))
+		} else {
+			t = time.Unix(int64(n), int64((n-math.Trunc(n))*1e9))
+		}
+	default:
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("got %T, not a string", v)
+		}
+		var err error
+		if t, err = time.Parse(c.Timestamp, s); err != nil {
+			return nil, err
+		}
+	}
+	return t.UTC().Format(time.RFC3339Nano), nil
+}
+
+// get returns the field of v at path.
+func get(v any, path string) (any, error) {
+	if path == "" {
+		return v, nil
+	}
+	for _, name := range strings.Split(path, ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("no field %s", path)
+		}
+		if v, ok = m[name]; !ok {
+			return nil, fmt.Errorf("no field %s", path)
+		}
+	}
+	return v, nil
+}
+
+// set returns v with the field at path set to f, adding objects on the way.
+func set(v any, path string, f any) (any, error) {
+	if path == "" {
+		return f, nil
+	}
+	names := strings.Split(path, ".")
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("cannot set %s in %T", path, v)
+	}
+	root := m
+	for _, name := range names[:len(names)-1] {
+		next, ok := m[name].(map[string]any)
+		if !ok {
+			if _, exists := m[name]; exists {
+				return nil, fmt.Errorf("cannot set %s in %T", path, m[name])
+			}
+			next = map[string]any{}
+			m[name] = next
+		}
+		m = next
+	}
+	m[names[len(names)-1]] = f
+	return root, nil
+}
+
+// del returns v without the field at path.
+func del(v any, path string) (any, error) {
+	if path == "" {
+		return nil, fmt.Errorf("cannot rename the whole value")
+	}
+	i := strings.LastIndexByte(path, '.')
+	parent, err := get(v, path[:max(i, 0)])
+	if err != nil {
+		return nil, err
+	}
+	delete(parent.(map[string]any), path[i+1:])
+	return v, nil
+}
+
+func clone(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, f := range v {
+			out[k] = clone(f)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, f := range v {
+			out[i] = clone(f)
+		}
+		return out
+	}
+	return v
+}
+
+func number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func describe(path string) string {
+	if path == "" {
+		return "value"
+	}
+	return path
+}
@@ -0,0 +1,112 @@
+package normalize
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// upstream returns fixed facts and outputs.
+type upstream struct {
+	facts  map[string]any
+	output map[string]any
+}
+
+func (u upstream) Get(_ context.Context, fact string, _ map[string]any) (any, error) {
+	return u.facts[fact], nil
+}
+
+func (u upstream) Execute(context.Context, string, map[string]any) (map[string]any, error) {
+	return u.output, nil
+}
+
+const config = `{"invoiceRepo": {
+	"facts": {
+		"invoice.balance": {
+			"pick": "data.balance",
+			"rename": {"amount": "value"},
+			"convert": {"value": {"scale": 0.01}, "": {"currency": "USD", "rates": {"EUR": 1.08}}}
+		},
+		"invoice.due_date": {"convert": {"": {"timestamp": "unix"}}},
+		"invoice.issued_at": {"convert": {"": {"timestamp": "02/01/2006 15:04 MST"}}}
+	},
+	"operations": {
+		"ProcessPayment": {"rename": {"charge.id": "payment_id", "charge.state": "status"}}
+	}
+}}`
+
+func loadPort(t *testing.T, u upstream) *Port {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "normalize.json")
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfgs, err := LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Wrap(u, cfgs["invoiceRepo"])
+}
+
+func TestPort_Get_normalizesFacts(t *testing.T) {
+	balance := map[string]any{"data": map[string]any{"balance": map[string]any{"amount": 150000.0, "currency": "eur"}}}
+	p := loadPort(t, upstream{facts: map[string]any{
+		"invoice.balance":   balance,
+		"invoice.due_date":  1767225600.0,
+		"invoice.issued_at": "31/12/2025 09:30 UTC",
+		"invoice.status":    "approved",
+	}})
+	ctx := context.Background()
+
+	for fact, want := range map[string]any{
+		"invoice.balance":   map[string]any{"value": 1620.0, "currency": "USD"},
+		"invoice.due_date":  "2026-01-01T00:00:00Z",
+		"invoice.issued_at": "2025-12-31T09:30:00Z",
+		"invoice.status":    "approved",
+	} {
+		if got, err := p.Get(ctx, fact, nil); err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, %v, want %v", fact, got, err, want)
+		}
+	}
+	if _, ok := balance["data"].(map[string]any)["balance"].(map[string]any)["amount"]; !ok {
+		t.Error("expected the upstream's value left unmodified")
+	}
+
+	p = loadPort(t, upstream{facts: map[string]any{"invoice.balance": map[string]any{"data": map[string]any{"balance": map[string]any{"amount": 1.0, "currency": "GBP"}}}}})
+	if _, err := p.Get(ctx, "invoice.balance", nil); err == nil || !strings.Contains(err.Error(), "no rate from GBP to USD") {
+		t.Errorf("expected a missing rate error, got %v", err)
+	}
+}
+
+func TestPort_Execute_normalizesOutput(t *testing.T) {
+	ctx := context.Background()
+	p := loadPort(t, upstream{output: map[string]any{"charge": map[string]any{"id": "ch_1", "state": "succeeded"}}})
+	out, err := p.Execute(ctx, "ProcessPayment", nil)
+	want := map[string]any{"charge": map[string]any{}, "payment_id": "ch_1", "status": "succeeded"}
+	if err != nil || !reflect.DeepEqual(out, want) {
+		t.Errorf("got %v, %v, want %v", out, err, want)
+	}
+
+	// An output that does not fit is returned as executed.
+	p = loadPort(t, upstream{output: map[string]any{"id": "ch_1"}})
+	if out, err := p.Execute(ctx, "ProcessPayment", nil); err != nil || !reflect.DeepEqual(out, map[string]any{"id": "ch_1"}) {
+		t.Errorf("got %v, %v", out, err)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	for _, conv := range []Conversion{
+		{},
+		{Scale: 2, Timestamp: TimestampUnix},
+		{Scale: 2, Rates: map[string]float64{"EUR": 1}},
+		{Currency: "USD", Rates: map[string]float64{"EUR": 0}},
+	} {
+		cfg := Config{Facts: map[string]Rule{"f": {Convert: map[string]Conversion{"": conv}}}}
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("expected %+v rejected", conv)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+// Package outliers scores numeric input facts against the values seen
+// before, so contracts can flag or escalate unusual ones without an
+// external fraud service.
+//
+// Detector is an engine.AuditSink that learns the distribution of each
+// tracked fact, per value of a grouping fact, from executed decisions, and
+// a ports.Client that answers "<fact>.zscore" with how many standard
+// deviations the request's value lies from the mean of its group:
+//
+//	facts: "payment.amount.value.zscore": {source: "port:outliers", required: false, on_missing: "skip"}
+//	rules: [{id: "unusual-amount", when: {fact: "payment.amount.value.zscore", greater_than: 3}, verdict: {escalate: {...}}}]
+//
+// Only executed decisions are learned from, so refused attempts do not make
+// later ones look usual. Distributions are kept in memory and start empty
+// on each restart.
+package outliers
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+
+	"covenant-poc/executor/engine"
+	"covenant-poc/executor/ports"
+)
+
+// ScoreSuffix ends the names of the facts a Detector answers.
+const ScoreSuffix = ".zscore"
+
+// Defaults.
+const (
+	// DefaultMinSamples is how many values a group must have seen before
+	// its values are scored.
+	DefaultMinSamples = 10
+	// DefaultMaxGroups bounds the groups kept per fact; the least recently
+	// seen are forgotten first.
+	DefaultMaxGroups = 100_000
+	// minRelativeStdDev floors a group's standard deviation at this
+	// fraction of its mean, so a departure from values that have never
+	// varied scores high rather than infinitely.
+	minRelativeStdDev = 0.01
+)
+
+// ErrTooFewSamples is returned for a value whose group has not seen enough
+// values to score it. A fact with on_missing "skip" is then absent.
+var ErrTooFewSamples = errors.New("too few samples")
+
+// Tracked is a fact whose distribution a Detector learns.
+type Tracked struct {
+	// Fact is an input fact, or a field of one, such as
+	// payment.amount.value.
+	Fact string
+	// GroupBy is the input fact values are grouped by, such as
+	// customer.id; empty for one distribution of all values.
+	GroupBy string
+}
+
+// Parse parses a comma-separated list of fact=group_by, or fact alone for
+// an ungrouped distribution, e.g. payment.amount.value=customer.id.
+func Parse(spec string) ([]Tracked, error) {
+	var tracked []Tracked
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		fact, group, _ := strings.Cut(item, "=")
+		fact, group = strings.TrimSpace(fact), strings.TrimSpace(group)
+		if fact == "" {
+			return nil, fmt.Errorf("outlier %q: expected fact=group_by", item)
+		}
+		tracked = append(tracked, Tracked{Fact: fact, GroupBy: group})
+	}
+	return tracked, nil
+}
+
+// Detector learns distributions of tracked facts and scores values against
+// them. It is safe for concurrent use.
+type Detector struct {
+	minSamples int
+	maxGroups  int
+
+	mu    sync.Mutex
+	facts map[string]*distributions
+}
+
+// distributions holds a tracked fact's groups, least recently seen first.
+type distributions struct {
+	Tracked
+	groups map[string]*list.Element
+	lru    *list.List
+}
+
+// group is a running mean and variance (Welford's algorithm).
+type group struct {
+	key  string
+	n    int
+	mean float64
+	m2   float64
+}
+
+// Option configures a Detector.
+type Option func(*Detector)
+
+// WithMinSamples sets how many values, at least 2, a group must have seen
+// before its values are scored.
+func WithMinSamples(n int) Option {
+	return func(d *Detector) { d.minSamples = max(n, 2) }
+}
+
+// WithMaxGroups bounds the groups kept per fact.
+func WithMaxGroups(n int) Option {
+	return func(d *Detector) { d.maxGroups = n }
+}
+
+// NewDetector returns a Detector learning the tracked facts.
+func NewDetector(tracked []Tracked, opts ...Option) *Detector {
+	d := &Detector{minSamples: DefaultMinSamples, maxGroups: DefaultMaxGroups, facts: map[string]*distributions{}}
+	for _, o := range opts {
+		o(d)
+	}
+	for _, t := range tracked {
+		d.facts[t.Fact] = &distributions{Tracked: t, groups: map[string]*list.Element{}, lru: list.New()}
+	}
+	return d
+}
+
+// Record implements engine.AuditSink, learning from executed decisions.
+func (d *Detector) Record(_ context.Context, rec *engine.AuditRecord) {
+	if rec.Outcome != string(engine.OutcomeExecuted) || rec.DryRun || rec.Resolution != nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, dist := range d.facts {
+		x, key, ok := dist.sample(rec.Input)
+		if !ok {
+			continue
+		}
+		g := dist.group(key, d.maxGroups)
+		g.n++
+		delta := x - g.mean
+		g.mean += delta / float64(g.n)
+		g.m2 += delta * (x - g.mean)
+	}
+}
+
+// sample returns the tracked value in input and its group key.
+func (dist *distributions) sample(input map[string]any) (float64, string, bool) {
+	v, _ := ports.Lookup(input, dist.Fact)
+	x, ok := toFloat(v)
+	if !ok {
+		return 0, "", false
+	}
+	if dist.GroupBy == "" {
+		return x, "", true
+	}
+	key, _ := ports.Lookup(input, dist.GroupBy)
+	if key == nil {
+		return 0, "", false
+	}
+	return x, fmt.Sprint(key), true
+}
+
+// group returns the group of key, adding it, and forgetting the least
+// recently seen group if there are more than max.
+func (dist *distributions) group(key string, max int) *group {
+	if el, ok := dist.groups[key]; ok {
+		dist.lru.MoveToBack(el)
+		return el.Value.(*group)
+	}
+	g := &group{key: key}
+	dist.groups[key] = dist.lru.PushBack(g)
+	if max > 0 && dist.lru.Len() > max {
+		oldest := dist.lru.Remove(dist.lru.Front()).(*group)
+		delete(dist.groups, oldest.key)
+	}
+	return g
+}
+
+// Get answers "<fact>.zscore" for a tracked fact: how many standard
+// deviations the value in input lies above (positive) or below the mean of
+// its group.
+func (d *Detector) Get(_ context.Context, fact string, input map[string]any) (any, error) {
+	name, ok := strings.CutSuffix(fact, ScoreSuffix)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	dist := d.facts[name]
+	if !ok || dist == nil {
+		return nil, fmt.Errorf("unknown fact %q", fact)
+	}
+	x, key, ok := dist.sample(input)
+	if !ok {
+		return nil, fmt.Errorf("%s: input lacks %s or %s", fact, dist.Fact, dist.GroupBy)
+	}
+	el, ok := dist.groups[key]
+	if !ok || el.Value.(*group).n < d.minSamples {
+		return nil, fmt.Errorf("%s: %w", fact, ErrTooFewSamples)
+	}
+	g := el.Value.(*group)
+	sd := max(math.Sqrt(g.m2/float64(g.n-1)), math.Abs(g.mean)*minRelativeStdDev, math.SmallestNonzeroFloat64)
+	return math.Round((x-g.mean)/sd*1000) / 1000, nil
+}
+
+func (d *Detector) Execute(_ context.Context, operation string, _ map[string]any) (map[string]any, error) {
+	return nil, fmt.Errorf("outliers does not execute operation %q", operation)
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
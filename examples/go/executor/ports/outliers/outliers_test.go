@@ -0,0 +1,74 @@
+package outliers
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"covenant-poc/executor/engine"
+)
+
+func payment(customer string, amount float64) map[string]any {
+	return map[string]any{"customer.id": customer, "payment.amount": map[string]any{"value": amount, "currency": "USD"}}
+}
+
+func TestDetector_scoresAgainstTheGroup(t *testing.T) {
+	ctx := context.Background()
+	d := NewDetector([]Tracked{{Fact: "payment.amount.value", GroupBy: "customer.id"}}, WithMinSamples(4))
+	for _, amount := range []float64{90, 110, 100, 95, 105} {
+		d.Record(ctx, &engine.AuditRecord{Outcome: "executed", Input: payment("cust_1", amount)})
+	}
+	// Refusals, dry-runs and other customers' payments are not learned.
+	d.Record(ctx, &engine.AuditRecord{Outcome: "denied", Input: payment("cust_1", 1e6)})
+	d.Record(ctx, &engine.AuditRecord{Outcome: "executed", DryRun: true, Input: payment("cust_1", 1e6)})
+	d.Record(ctx, &engine.AuditRecord{Outcome: "executed", Input: payment("cust_2", 1e6)})
+
+	for amount, want := range map[float64]float64{100: 0, 140: 5.06, 60: -5.06} {
+		z, err := d.Get(ctx, "payment.amount.value.zscore", payment("cust_1", amount))
+		if err != nil || z != want {
+			t.Errorf("amount %v: got %v, %v, want %v", amount, z, err, want)
+		}
+	}
+	if _, err := d.Get(ctx, "payment.amount.value.zscore", payment("cust_2", 10)); !errors.Is(err, ErrTooFewSamples) {
+		t.Errorf("expected ErrTooFewSamples, got %v", err)
+	}
+	if _, err := d.Get(ctx, "invoice.balance.zscore", payment("cust_1", 10)); err == nil {
+		t.Error("expected an unknown fact error")
+	}
+}
+
+func TestDetector_constantGroupScoresDepartures(t *testing.T) {
+	ctx := context.Background()
+	d := NewDetector([]Tracked{{Fact: "amount"}}, WithMinSamples(2))
+	for range 3 {
+		d.Record(ctx, &engine.AuditRecord{Outcome: "executed", Input: map[string]any{"amount": 200.0}})
+	}
+	if z, err := d.Get(ctx, "amount.zscore", map[string]any{"amount": 220.0}); err != nil || z != 10.0 {
+		t.Errorf("got %v, %v", z, err)
+	}
+}
+
+func TestDetector_forgetsLeastRecentGroups(t *testing.T) {
+	ctx := context.Background()
+	d := NewDetector([]Tracked{{Fact: "amount", GroupBy: "customer.id"}}, WithMinSamples(2), WithMaxGroups(2))
+	for _, c := range []string{"a", "a", "b", "b", "a", "c", "c"} {
+		d.Record(ctx, &engine.AuditRecord{Outcome: "executed", Input: map[string]any{"customer.id": c, "amount": 1.0}})
+	}
+	for c, known := range map[string]bool{"a": true, "b": false, "c": true} {
+		if _, err := d.Get(ctx, "amount.zscore", map[string]any{"customer.id": c, "amount": 1.0}); (err == nil) != known {
+			t.Errorf("customer %s: expected known %v, got %v", c, known, err)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	got, err := Parse("payment.amount.value=customer.id, invoice.balance.value")
+	want := []Tracked{{Fact: "payment.amount.value", GroupBy: "customer.id"}, {Fact: "invoice.balance.value"}}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, %v", got, err)
+	}
+	if _, err := Parse("=customer.id"); err == nil {
+		t.Error("expected an error for a missing fact")
+	}
+}
@@ -0,0 +1,127 @@
+// Package rates serves exchange rates to the engine's convert derivation.
+//
+// Port is a ports.Client, registered as engine.RatesPort, answering facts
+// named after currency pairs: "EUR/USD" is how many US dollars one euro
+// buys. A contract converts money facts before comparing them:
+//
+//	derived: "payment.usd": {derivation: {fn: "convert", args: [{fact: "payment.amount"}, {value: "USD"}]}}
+//
+// A treasury or market data feed is wrapped with a SourceFunc; Table is a
+// file-backed source for local use.
+package rates
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrNotFound is returned by sources without a rate for a pair. The engine
+// then reports the converted fact unavailable.
+var ErrNotFound = errors.New("rate not found")
+
+// Source quotes exchange rates.
+type Source interface {
+	Rate(ctx context.Context, from, to string) (float64, error)
+}
+
+// SourceFunc adapts a function to Source.
+type SourceFunc func(ctx context.Context, from, to string) (float64, error)
+
+func (f SourceFunc) Rate(ctx context.Context, from, to string) (float64, error) {
+	return f(ctx, from, to)
+}
+
+// Port serves rates from a Source.
+type Port struct {
+	source Source
+}
+
+// NewPort returns a Port backed by s.
+func NewPort(s Source) *Port {
+	return &Port{source: s}
+}
+
+func (p *Port) Get(ctx context.Context, fact string, _ map[string]any) (any, error) {
+	from, to, ok := strings.Cut(fact, "/")
+	if !ok || len(from) != 3 || len(to) != 3 {
+		return nil, fmt.Errorf("unknown fact %q: expected a currency pair such as EUR/USD", fact)
+	}
+	if from == to {
+		return 1.0, nil
+	}
+	rate, err := p.source.Rate(ctx, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fact, err)
+	}
+	return rate, nil
+}
+
+func (p *Port) Execute(_ context.Context, operation string, _ map[string]any) (map[string]any, error) {
+	return nil, fmt.Errorf("rates does not execute operation %q", operation)
+}
+
+// Table is an in-memory Source of rates against one base currency, from
+// which the rate of any pair of its currencies is derived.
+type Table struct {
+	Base  string             `json:"base"`
+	Rates map[string]float64 `json:"rates"` // units of each currency one unit of Base buys
+}
+
+// ParseTable reads a table in the shape most rate feeds publish:
+//
+//	{"base": "USD", "rates": {"EUR": 0.91, "GBP": 0.79, "JPY": 149.2}}
+func ParseTable(in io.Reader) (*Table, error) {
+	var t Table
+	if err := json.NewDecoder(in).Decode(&t); err != nil {
+		return nil, err
+	}
+	if len(t.Base) != 3 {
+		return nil, fmt.Errorf("base %q is not a currency code", t.Base)
+	}
+	for currency, rate := range t.Rates {
+		if len(currency) != 3 || rate <= 0 {
+			return nil, fmt.Errorf("rate %s %v: expected a currency code and a positive rate", currency, rate)
+		}
+	}
+	return &t, nil
+}
+
+// LoadFile reads a rates file as ParseTable does.
+func LoadFile(path string) (*Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	t, err := ParseTable(f)
+	if err != nil {
+		return nil, fmt.Errorf("parse rates %s: %w", path, err)
+	}
+	return t, nil
+}
+
+func (t *Table) Rate(_ context.Context, from, to string) (float64, error) {
+	per := func(currency string) (float64, error) {
+		if currency == t.Base {
+			return 1, nil
+		}
+		if rate, ok := t.Rates[currency]; ok {
+			return rate, nil
+		}
+		return 0, fmt.Errorf("%w: %s", ErrNotFound, currency)
+	}
+	f, err := per(from)
+	if err != nil {
+		return 0, err
+	}
+	g, err := per(to)
+	if err != nil {
+		return 0, err
+	}
+	return g / f, nil
+}
@@ -0,0 +1,60 @@
+package rates
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+const table = `{"base": "USD", "rates": {"EUR": 0.8, "GBP": 0.5}}`
+
+func TestPort_Get_quotesPairs(t *testing.T) {
+	tbl, err := ParseTable(strings.NewReader(table))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewPort(tbl)
+	for _, tc := range []struct {
+		pair string
+		want float64
+	}{
+		{"USD/EUR", 0.8},
+		{"EUR/USD", 1.25},
+		// Cross rates go through the base.
+		{"EUR/GBP", 0.625},
+		{"GBP/EUR", 1.6},
+		{"JPY/JPY", 1},
+	} {
+		v, err := p.Get(context.Background(), tc.pair, nil)
+		if rate, ok := v.(float64); err != nil || !ok || math.Abs(rate-tc.want) > 1e-9 {
+			t.Errorf("%s: expected %v, got %v, %v", tc.pair, tc.want, v, err)
+		}
+	}
+}
+
+func TestPort_Get_unknownCurrencyIsNotFound(t *testing.T) {
+	tbl, err := ParseTable(strings.NewReader(table))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewPort(tbl).Get(context.Background(), "CHF/USD", nil); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := NewPort(tbl).Get(context.Background(), "euros", nil); err == nil {
+		t.Error("expected an error for a fact that is not a pair")
+	}
+}
+
+func TestParseTable_rejectsBadRates(t *testing.T) {
+	for _, in := range []string{
+		`{"rates": {"EUR": 0.8}}`,
+		`{"base": "USD", "rates": {"EUR": 0}}`,
+		`{"base": "USD", "rates": {"euro": 0.8}}`,
+	} {
+		if _, err := ParseTable(strings.NewReader(in)); err == nil {
+			t.Errorf("%s: expected an error", in)
+		}
+	}
+}
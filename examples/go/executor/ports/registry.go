@@ -30,6 +30,14 @@ func (r *Registry) Register(name string, c Client) {
 	r.clients[name] = c
 }
 
+// Client returns the adapter registered as name.
+func (r *Registry) Client(name string) (Client, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c, ok := r.clients[name]
+	return c, ok
+}
+
 func (r *Registry) Get(ctx context.Context, port, fact string, input map[string]any) (any, error) {
 	r.mu.RLock()
 	c, ok := r.clients[port]
@@ -0,0 +1,252 @@
+// Package schemas validates port payloads against JSON Schemas fetched from
+// a schema registry, so that an upstream that changes its shape unannounced
+// fails requests with the port_contract_violation outcome rather than
+// feeding rules values they were not written for.
+//
+// A schema file names the registry and the subject each fact and operation
+// output of a port is registered under:
+//
+//	{"registry": "http://schema-registry:8081",
+//	 "refresh": "5m",
+//	 "ports": {"invoiceRepo": {
+//	   "facts": {"invoice.balance": "billing.invoice-balance"},
+//	   "operations": {"ProcessPayment": "billing.payment-result"}}}}
+//
+// Schemas are fetched with the Confluent-compatible GET
+// /subjects/{subject}/versions/latest, whose "schema" field holds the JSON
+// Schema as a string; a response without one is taken as the schema
+// itself, so a plain file server works too. Each is fetched again once
+// refresh has passed. While the registry is unreachable the last schema
+// fetched is used, and payloads whose schema has never been fetched pass
+// unvalidated, so that a registry outage does not stop decisions.
+package schemas
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"covenant-poc/executor/engine"
+	"covenant-poc/executor/ports"
+)
+
+// defaultRefresh is how long a fetched schema is used before it is fetched
+// again, unless the file sets refresh.
+const defaultRefresh = 5 * time.Minute
+
+// Config is a schema file.
+type Config struct {
+	Registry string              `json:"registry"`
+	Refresh  string              `json:"refresh,omitempty"` // e.g. 1m; default 5m
+	Ports    map[string]Subjects `json:"ports"`
+}
+
+// Subjects names the registry subjects of a port's payloads.
+type Subjects struct {
+	Facts      map[string]string `json:"facts,omitempty"`
+	Operations map[string]string `json:"operations,omitempty"` // of outputs
+}
+
+// LoadFile reads a schema file and returns its registry and the subjects
+// of each port.
+func LoadFile(path string) (*Registry, map[string]Subjects, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if u, err := url.Parse(cfg.Registry); err != nil || u.Host == "" {
+		return nil, nil, fmt.Errorf("%s: registry %q is not a URL", path, cfg.Registry)
+	}
+	refresh := defaultRefresh
+	if cfg.Refresh != "" {
+		if refresh, err = time.ParseDuration(cfg.Refresh); err != nil || refresh <= 0 {
+			return nil, nil, fmt.Errorf("%s: refresh %q is not a positive duration", path, cfg.Refresh)
+		}
+	}
+	return NewRegistry(cfg.Registry, refresh), cfg.Ports, nil
+}
+
+// Registry fetches and caches schemas from a schema registry.
+type Registry struct {
+	url     string
+	refresh time.Duration
+	client  *http.Client
+	now     func() time.Time
+
+	mu      sync.Mutex
+	schemas map[string]*fetched
+}
+
+type fetched struct {
+	schema  *Schema // nil if never fetched
+	version string
+	at      time.Time
+}
+
+// NewRegistry returns a Registry fetching schemas from the registry at
+// baseURL and keeping each for refresh.
+func NewRegistry(baseURL string, refresh time.Duration) *Registry {
+	return &Registry{
+		url:     strings.TrimSuffix(baseURL, "/"),
+		refresh: refresh,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		now:     time.Now,
+		schemas: map[string]*fetched{},
+	}
+}
+
+// Schema returns the latest schema of subject and a label naming it, such
+// as "billing.invoice-balance v3". If the registry cannot be reached, the
+// last schema fetched is returned; without one, so is the error, until
+// refresh has passed and the schema is fetched again.
+func (r *Registry) Schema(ctx context.Context, subject string) (*Schema, string, error) {
+	r.mu.Lock()
+	f, ok := r.schemas[subject]
+	r.mu.Unlock()
+	if ok && r.now().Sub(f.at) < r.refresh {
+		if f.schema == nil {
+			return nil, subject, fmt.Errorf("schema %s unavailable", subject)
+		}
+		return f.schema, label(subject, f.version), nil
+	}
+
+	schema, version, err := r.fetch(ctx, subject)
+	if err != nil {
+		log.Printf("schemas: %s: %v", subject, err)
+		// Keep what was fetched before, and wait before trying again.
+		next := &fetched{at: r.now()}
+		if ok {
+			next.schema, next.version = f.schema, f.version
+		}
+		r.mu.Lock()
+		r.schemas[subject] = next
+		r.mu.Unlock()
+		if next.schema == nil {
+			return nil, subject, err
+		}
+		return next.schema, label(subject, next.version), nil
+	}
+	r.mu.Lock()
+	r.schemas[subject] = &fetched{schema: schema, version: version, at: r.now()}
+	r.mu.Unlock()
+	return schema, label(subject, version), nil
+}
+
+func (r *Registry) fetch(ctx context.Context, subject string) (*Schema, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url+"/subjects/"+url.PathEscape(subject)+"/versions/latest", nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json, application/json")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, "", fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	var envelope struct {
+		Schema  *string `json:"schema"`
+		Version *int    `json:"version"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, "", err
+	}
+	version := ""
+	if envelope.Version != nil {
+		version = fmt.Sprint(*envelope.Version)
+	}
+	if envelope.Schema != nil {
+		data = []byte(*envelope.Schema)
+	}
+	schema, err := Parse(data)
+	if err != nil {
+		return nil, "", fmt.Errorf("parse: %w", err)
+	}
+	return schema, version, nil
+}
+
+func label(subject, version string) string {
+	if version == "" {
+		return subject
+	}
+	return subject + " v" + version
+}
+
+// Port is a ports.Client validating the payloads of another against their
+// registered schemas. A payload that violates its schema fails with an
+// *engine.PortContractViolation.
+type Port struct {
+	client   ports.Client
+	name     string
+	subjects Subjects
+	registry *Registry
+}
+
+// Wrap returns port name's client c with its payloads validated against
+// the schemas of subjects in r.
+func Wrap(c ports.Client, name string, subjects Subjects, r *Registry) *Port {
+	return &Port{client: c, name: name, subjects: subjects, registry: r}
+}
+
+func (p *Port) Get(ctx context.Context, fact string, input map[string]any) (any, error) {
+	v, err := p.client.Get(ctx, fact, input)
+	if err != nil {
+		return nil, err
+	}
+	payload := v
+	if ts, ok := v.(engine.Timestamped); ok {
+		payload = ts.Value
+	}
+	if violation := p.validate(ctx, p.subjects.Facts[fact], payload); violation != nil {
+		violation.Fact = fact
+		return nil, violation
+	}
+	return v, nil
+}
+
+func (p *Port) Execute(ctx context.Context, operation string, input map[string]any) (map[string]any, error) {
+	out, err := p.client.Execute(ctx, operation, input)
+	if err != nil {
+		return nil, err
+	}
+	if violation := p.validate(ctx, p.subjects.Operations[operation], out); violation != nil {
+		violation.Operation = operation
+		return nil, violation
+	}
+	return out, nil
+}
+
+// validate returns how payload violates the schema of subject, if it is
+// known.
+func (p *Port) validate(ctx context.Context, subject string, payload any) *engine.PortContractViolation {
+	if subject == "" {
+		return nil
+	}
+	schema, label, err := p.registry.Schema(ctx, subject)
+	if err != nil {
+		return nil // logged by the registry
+	}
+	violations := schema.Validate(payload)
+	if len(violations) == 0 {
+		return nil
+	}
+	return &engine.PortContractViolation{Port: p.name, Schema: label, Violations: violations}
+}
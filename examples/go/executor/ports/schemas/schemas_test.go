@@ -0,0 +1,124 @@
+package schemas
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"covenant-poc/executor/engine"
+)
+
+const balanceSchema = `{
+	"type": "object",
+	"required": ["value", "currency"],
+	"additionalProperties": false,
+	"properties": {
+		"value": {"type": "number", "minimum": 0},
+		"currency": {"type": "string", "pattern": "^[A-Z]{3}$"}
+	}
+}`
+
+// upstream returns fixed facts and outputs.
+type upstream struct {
+	fact   any
+	output map[string]any
+}
+
+func (u *upstream) Get(context.Context, string, map[string]any) (any, error) {
+	return u.fact, nil
+}
+
+func (u *upstream) Execute(context.Context, string, map[string]any) (map[string]any, error) {
+	return u.output, nil
+}
+
+func TestPort_validatesPayloads(t *testing.T) {
+	var down atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() || r.URL.Path != "/subjects/billing.invoice-balance/versions/latest" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"subject": "billing.invoice-balance", "version": 3, "id": 17, "schema": balanceSchema})
+	}))
+	defer srv.Close()
+	registry := NewRegistry(srv.URL, time.Minute)
+	now := time.Now()
+	registry.now = func() time.Time { return now }
+	u := &upstream{fact: map[string]any{"value": 1500, "currency": "USD"}, output: map[string]any{"payment_id": "pay_1"}}
+	p := Wrap(u, "invoiceRepo", Subjects{
+		Facts:      map[string]string{"invoice.balance": "billing.invoice-balance"},
+		Operations: map[string]string{"ProcessPayment": "billing.payment-result"},
+	}, registry)
+	ctx := context.Background()
+
+	if v, err := p.Get(ctx, "invoice.balance", nil); err != nil || !reflect.DeepEqual(v, u.fact) {
+		t.Fatalf("expected the conforming balance, got %v, %v", v, err)
+	}
+	// The upstream changes its shape.
+	u.fact = map[string]any{"amount": "1500", "currency": "usd"}
+	_, err := p.Get(ctx, "invoice.balance", nil)
+	var violation *engine.PortContractViolation
+	if !errors.As(err, &violation) {
+		t.Fatalf("expected a PortContractViolation, got %v", err)
+	}
+	want := []string{
+		`/: missing required property "value"`,
+		`/: unexpected property "amount"`,
+		`/currency: "usd" does not match ^[A-Z]{3}$`,
+	}
+	if violation.Port != "invoiceRepo" || violation.Fact != "invoice.balance" || violation.Schema != "billing.invoice-balance v3" || !reflect.DeepEqual(violation.Violations, want) {
+		t.Errorf("unexpected violation %+v", violation)
+	}
+
+	// The schema fetched before is used while the registry is down.
+	down.Store(true)
+	now = now.Add(2 * time.Minute)
+	if _, err := p.Get(ctx, "invoice.balance", nil); !errors.As(err, &violation) {
+		t.Errorf("expected the cached schema applied, got %v", err)
+	}
+	// A payload whose schema was never fetched passes.
+	if out, err := p.Execute(ctx, "ProcessPayment", nil); err != nil || out["payment_id"] != "pay_1" {
+		t.Errorf("expected the output unvalidated, got %v, %v", out, err)
+	}
+}
+
+func TestSchema_Validate(t *testing.T) {
+	s, err := Parse([]byte(`{
+		"type": "object",
+		"properties": {
+			"status": {"enum": ["approved", "paid"]},
+			"lines": {"type": "array", "minItems": 1, "items": {"type": "integer", "maximum": 10}},
+			"note": {"type": ["string", "null"], "maxLength": 3}
+		}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		payload any
+		want    []string
+	}{
+		{map[string]any{"status": "paid", "lines": []int{1, 2}, "note": nil}, nil},
+		{map[string]any{"status": "void", "lines": []any{}, "note": "long"}, []string{
+			`/lines: fewer than 1 items`,
+			`/note: longer than 3 characters`,
+			`/status: "void" is not one of the allowed values`,
+		}},
+		{map[string]any{"lines": []any{1.5, 11.0}}, []string{
+			`/lines/0: expected integer, got number`,
+			`/lines/1: 11 is more than the maximum 10`,
+		}},
+		{"paid", []string{`/: expected object, got string`}},
+	} {
+		if got := s.Validate(tc.payload); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%v: got %q, want %q", tc.payload, got, tc.want)
+		}
+	}
+}
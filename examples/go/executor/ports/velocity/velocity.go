@@ -0,0 +1,235 @@
+// Package velocity exposes how often, and for how much, an operation was
+// executed recently as contract facts, so limits such as "no more than 3
+// payments per day" are declared in a contract rather than written as a
+// port.
+//
+// Tracker is an engine.AuditSink that counts each execution of an
+// operation, per value of a key fact, in an engine.CounterStore, and a
+// ports.Client that answers "<counter>.count_<window>" and
+// "<counter>.sum_<window>" from those counts. A velocity file names the
+// counters:
+//
+//	{"payments": {"operation": "ProcessPayment", "key": "customer.id",
+//	              "sum": "payment.amount.value", "windows": ["1h", "24h", "7d"]}}
+//
+// and a contract reads them as port facts:
+//
+//	facts: "payments.count_24h": {source: "port:velocity", required: true}
+//	rules: [{id: "daily-limit", when: {fact: "payments.count_24h", greater_than: 2}, verdict: {deny: {...}}}]
+//
+// Windows slide: each is counted in twelve slices, and a fact covers the
+// slices that overlap the window ending now, so it may include executions
+// up to a twelfth of the window older than the window but never misses one.
+// Executions are counted once they have happened, so two concurrent
+// requests may both see the count from before either.
+package velocity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"covenant-poc/executor/engine"
+	"covenant-poc/executor/ports"
+)
+
+// Slices is how many counters each window is kept in.
+const Slices = 12
+
+// sumScale is the precision sums are counted to: hundredths.
+const sumScale = 100
+
+// Counter counts the executions of an operation.
+type Counter struct {
+	Operation string `json:"operation"`
+	// Key is the input fact executions are counted per, such as
+	// customer.id.
+	Key string `json:"key"`
+	// Sum is a numeric input fact, or a field of one, such as
+	// payment.amount.value, to total as well; empty to only count.
+	Sum string `json:"sum,omitempty"`
+	// Windows are the lengths counted over, as Go durations or a number
+	// of days such as 7d. They name the facts as written.
+	Windows []string `json:"windows"`
+}
+
+// Validate reports the first malformed field.
+func (c Counter) Validate() error {
+	if c.Operation == "" || c.Key == "" {
+		return fmt.Errorf("operation and key are required")
+	}
+	if len(c.Windows) == 0 {
+		return fmt.Errorf("no windows")
+	}
+	for _, w := range c.Windows {
+		if _, err := ParseWindow(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseWindow parses a window length: a Go duration such as 24h, or a
+// number of days such as 7d.
+func ParseWindow(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, nerr := strconv.Atoi(days)
+		d, err = time.Duration(n)*24*time.Hour, nerr
+	}
+	if err != nil || d < Slices*time.Second {
+		return 0, fmt.Errorf("window %q is not a duration of at least %ds", s, Slices)
+	}
+	return d, nil
+}
+
+// LoadFile reads a velocity file of counter names to Counters.
+func LoadFile(path string) (map[string]Counter, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var counters map[string]Counter
+	if err := json.Unmarshal(data, &counters); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for name, c := range counters {
+		if err := c.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: counter %q: %w", path, name, err)
+		}
+	}
+	return counters, nil
+}
+
+// Keep returns how long the counts of counters must be kept to answer
+// every window.
+func Keep(counters map[string]Counter) time.Duration {
+	var keep time.Duration
+	for _, c := range counters {
+		for _, w := range c.Windows {
+			if d, err := ParseWindow(w); err == nil {
+				keep = max(keep, d+d/Slices)
+			}
+		}
+	}
+	return keep
+}
+
+// Tracker counts executions in a CounterStore and serves the counts as
+// facts. It is safe for concurrent use.
+type Tracker struct {
+	store    engine.CounterStore
+	counters map[string]tracked
+	now      func() time.Time
+}
+
+type tracked struct {
+	Counter
+	windows map[string]time.Duration
+}
+
+// NewTracker returns a Tracker of counters, keeping counts in store.
+func NewTracker(store engine.CounterStore, counters map[string]Counter) (*Tracker, error) {
+	t := &Tracker{store: store, counters: map[string]tracked{}, now: time.Now}
+	for name, c := range counters {
+		if err := c.Validate(); err != nil {
+			return nil, fmt.Errorf("counter %q: %w", name, err)
+		}
+		if name == "" || strings.Contains(name, ".") {
+			return nil, fmt.Errorf("counter %q: names must be non-empty and undotted", name)
+		}
+		tc := tracked{Counter: c, windows: map[string]time.Duration{}}
+		for _, w := range c.Windows {
+			tc.windows[w], _ = ParseWindow(w)
+		}
+		t.counters[name] = tc
+	}
+	return t, nil
+}
+
+// Record implements engine.AuditSink, counting executions.
+func (t *Tracker) Record(ctx context.Context, rec *engine.AuditRecord) {
+	if rec.Outcome != string(engine.OutcomeExecuted) || rec.DryRun {
+		return
+	}
+	for name, c := range t.counters {
+		if c.Operation != rec.Operation {
+			continue
+		}
+		key, _ := ports.Lookup(rec.Input, c.Key)
+		if key == nil {
+			continue
+		}
+		var sum int64
+		if c.Sum != "" {
+			sv, _ := ports.Lookup(rec.Input, c.Sum)
+			v, ok := toFloat(sv)
+			if !ok {
+				log.Printf("velocity: %s: %s is not a number", name, c.Sum)
+			}
+			sum = int64(math.Round(v * sumScale))
+		}
+		for w, d := range c.windows {
+			slice := d / Slices
+			if _, err := t.store.Add(ctx, counterKey(name, "count", w, key), slice, rec.Timestamp, 1); err != nil {
+				log.Printf("velocity: %s: %v", name, err)
+			}
+			if sum != 0 {
+				if _, err := t.store.Add(ctx, counterKey(name, "sum", w, key), slice, rec.Timestamp, sum); err != nil {
+					log.Printf("velocity: %s: %v", name, err)
+				}
+			}
+		}
+	}
+}
+
+func counterKey(name, measure, window string, key any) string {
+	return fmt.Sprintf("velocity/%s/%s_%s/%v", name, measure, window, key)
+}
+
+// Get answers "<counter>.count_<window>" and "<counter>.sum_<window>" for
+// the key fact in input. A key executed nothing for counts 0.
+func (t *Tracker) Get(ctx context.Context, fact string, input map[string]any) (any, error) {
+	name, field, _ := strings.Cut(fact, ".")
+	measure, w, _ := strings.Cut(field, "_")
+	c, ok := t.counters[name]
+	d, known := c.windows[w]
+	if !ok || !known || (measure != "count" && (measure != "sum" || c.Sum == "")) {
+		return nil, fmt.Errorf("unknown fact %q", fact)
+	}
+	key, _ := ports.Lookup(input, c.Key)
+	if key == nil {
+		return nil, fmt.Errorf("%s: input lacks %s", fact, c.Key)
+	}
+	now := t.now()
+	total, err := t.store.Sum(ctx, counterKey(name, measure, w, key), d/Slices, now.Add(-d), now)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fact, err)
+	}
+	if measure == "sum" {
+		return float64(total) / sumScale, nil
+	}
+	return total, nil
+}
+
+func (t *Tracker) Execute(_ context.Context, operation string, _ map[string]any) (map[string]any, error) {
+	return nil, fmt.Errorf("velocity does not execute operation %q", operation)
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
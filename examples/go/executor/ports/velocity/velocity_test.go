@@ -0,0 +1,100 @@
+package velocity
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"covenant-poc/executor/engine"
+)
+
+func payment(at time.Time, customer string, amount float64, outcome engine.Outcome) *engine.AuditRecord {
+	return &engine.AuditRecord{
+		Timestamp: at,
+		Operation: "ProcessPayment",
+		Outcome:   string(outcome),
+		Input: map[string]any{
+			"customer.id":    customer,
+			"payment.amount": map[string]any{"value": amount, "currency": "USD"},
+		},
+	}
+}
+
+func TestTracker_countsExecutionsPerKey(t *testing.T) {
+	tr, err := NewTracker(engine.NewMemoryCounterStore(48*time.Hour), map[string]Counter{
+		"payments": {Operation: "ProcessPayment", Key: "customer.id", Sum: "payment.amount.value", Windows: []string{"1h", "1d"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t0 := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+	tr.Record(ctx, payment(t0, "cust_1", 100, engine.OutcomeExecuted))
+	tr.Record(ctx, payment(t0.Add(3*time.Hour), "cust_1", 49.99, engine.OutcomeExecuted))
+	tr.Record(ctx, payment(t0.Add(4*time.Hour), "cust_1", 20, engine.OutcomeExecuted))
+	tr.Record(ctx, payment(t0.Add(4*time.Hour), "cust_1", 500, engine.OutcomeDenied))
+	tr.Record(ctx, payment(t0.Add(4*time.Hour), "cust_2", 75, engine.OutcomeExecuted))
+	dryRun := payment(t0.Add(4*time.Hour), "cust_1", 500, engine.OutcomeExecuted)
+	dryRun.DryRun = true
+	tr.Record(ctx, dryRun)
+
+	get := func(at time.Time, fact, customer string) any {
+		t.Helper()
+		tr.now = func() time.Time { return at }
+		v, err := tr.Get(ctx, fact, map[string]any{"customer.id": customer})
+		if err != nil {
+			t.Fatalf("%s: %v", fact, err)
+		}
+		return v
+	}
+	now := t0.Add(4*time.Hour + 30*time.Minute)
+	for _, tc := range []struct {
+		fact, customer string
+		want           any
+	}{
+		{"payments.count_1d", "cust_1", int64(3)},
+		{"payments.sum_1d", "cust_1", 169.99},
+		{"payments.count_1h", "cust_1", int64(1)},
+		{"payments.count_1d", "cust_2", int64(1)},
+		{"payments.count_1d", "cust_3", int64(0)},
+	} {
+		if got := get(now, tc.fact, tc.customer); got != tc.want {
+			t.Errorf("%s for %s: expected %v, got %v", tc.fact, tc.customer, tc.want, got)
+		}
+	}
+	// A day later the first payment has left the window.
+	if got := get(t0.Add(26*time.Hour), "payments.count_1d", "cust_1"); got != int64(2) {
+		t.Errorf("expected 2 payments in the sliding day, got %v", got)
+	}
+}
+
+func TestTracker_Get_unknownFacts(t *testing.T) {
+	tr, err := NewTracker(engine.NewMemoryCounterStore(time.Hour), map[string]Counter{
+		"refunds": {Operation: "RefundPayment", Key: "customer.id", Windows: []string{"1h"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := map[string]any{"customer.id": "cust_1"}
+	for _, fact := range []string{"refunds.count_24h", "refunds.sum_1h", "refunds.total_1h", "payments.count_1h"} {
+		if _, err := tr.Get(context.Background(), fact, input); err == nil {
+			t.Errorf("%s: expected an error", fact)
+		}
+	}
+	if _, err := tr.Get(context.Background(), "refunds.count_1h", nil); err == nil {
+		t.Error("expected an error without the key fact")
+	}
+}
+
+func TestParseWindow(t *testing.T) {
+	for s, want := range map[string]time.Duration{"90m": 90 * time.Minute, "24h": 24 * time.Hour, "7d": 7 * 24 * time.Hour} {
+		if d, err := ParseWindow(s); err != nil || d != want {
+			t.Errorf("%s: expected %v, got %v, %v", s, want, d, err)
+		}
+	}
+	for _, s := range []string{"", "0d", "1s", "week"} {
+		if _, err := ParseWindow(s); err == nil {
+			t.Errorf("%q: expected an error", s)
+		}
+	}
+}
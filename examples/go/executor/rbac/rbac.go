@@ -0,0 +1,255 @@
+// Package rbac maps authenticated callers to roles and guards the HTTP
+// endpoints that read or change decisions and contracts.
+//
+// Callers present an HS256-signed JWT as a bearer token. One claim — "roles"
+// by default, or a group claim from an identity provider — lists the values
+// Config.Roles maps to roles, and a caller acts with the highest role any of
+// its values maps to. The roles are ordered, each including the permissions
+// of those before it:
+//
+//	viewer    read decisions, escalations, proposals and the publish log
+//	operator  propose contract changes
+//	approver  resolve escalations, overriding the engine's decision, and
+//	          approve or reject proposals
+//	admin     the admin API and contract publication
+//
+// Refusals are answered with the standard error envelope.
+package rbac
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"covenant-poc/executor/engine"
+)
+
+// Role is a caller's level of access.
+type Role string
+
+// Roles, from least to most privileged.
+const (
+	Viewer   Role = "viewer"
+	Operator Role = "operator"
+	Approver Role = "approver"
+	Admin    Role = "admin"
+)
+
+var ranks = map[Role]int{Viewer: 1, Operator: 2, Approver: 3, Admin: 4}
+
+// Includes reports whether r grants need's permissions.
+func (r Role) Includes(need Role) bool {
+	return ranks[r] > 0 && ranks[r] >= ranks[need]
+}
+
+// Principal is an authenticated caller.
+type Principal struct {
+	Subject string `json:"subject"`
+	Role    Role   `json:"role"`
+}
+
+// Errors returned by Authenticate.
+var (
+	ErrUnauthenticated = errors.New("no valid bearer token")
+	ErrNoRole          = errors.New("token grants no role")
+)
+
+// Config configures an Authorizer.
+type Config struct {
+	// Claim names the token claim, a string or an array of strings, whose
+	// values map to roles (default "roles").
+	Claim string `json:"claim,omitempty"`
+	// Roles maps claim values to roles. A value that is itself a role name
+	// maps to that role unless Roles says otherwise.
+	Roles map[string]Role `json:"roles,omitempty"`
+	// Issuer and Audience, if set, must match the token's iss and aud.
+	Issuer   string `json:"issuer,omitempty"`
+	Audience string `json:"audience,omitempty"`
+}
+
+// Authorizer authenticates requests and checks their callers' roles. A nil
+// *Authorizer disables access control: every request is allowed.
+type Authorizer struct {
+	cfg    Config
+	secret []byte
+	now    func() time.Time
+}
+
+// New returns an Authorizer verifying tokens signed with secret.
+func New(cfg Config, secret []byte) (*Authorizer, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("a token signing secret is required")
+	}
+	if cfg.Claim == "" {
+		cfg.Claim = "roles"
+	}
+	for _, v := range slices.Sorted(maps.Keys(cfg.Roles)) {
+		if ranks[cfg.Roles[v]] == 0 {
+			return nil, fmt.Errorf("roles: %q maps to unknown role %q (want viewer, operator, approver or admin)", v, cfg.Roles[v])
+		}
+	}
+	return &Authorizer{cfg: cfg, secret: secret, now: time.Now}, nil
+}
+
+// LoadFile reads a Config from a JSON file, e.g.
+//
+//	{"claim": "groups", "roles": {"payments-ops": "approver", "platform": "admin"}}
+//
+// and returns an Authorizer verifying tokens with the secret in
+// COVENANT_AUTH_SECRET.
+func LoadFile(path string) (*Authorizer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	a, err := New(cfg, []byte(os.Getenv("COVENANT_AUTH_SECRET")))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return a, nil
+}
+
+// Claims verifies the request's bearer token, including its issuer and
+// audience, and returns its claims.
+func (a *Authorizer) Claims(r *http.Request) (map[string]any, error) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return nil, ErrUnauthenticated
+	}
+	claims, err := verify(token, a.secret, a.now())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnauthenticated, err)
+	}
+	if a.cfg.Issuer != "" && claims["iss"] != a.cfg.Issuer {
+		return nil, fmt.Errorf("%w: issuer is not %s", ErrUnauthenticated, a.cfg.Issuer)
+	}
+	if a.cfg.Audience != "" && !hasValue(claims["aud"], a.cfg.Audience) {
+		return nil, fmt.Errorf("%w: audience is not %s", ErrUnauthenticated, a.cfg.Audience)
+	}
+	return claims, nil
+}
+
+// Authenticate verifies the request's bearer token and returns its caller.
+func (a *Authorizer) Authenticate(r *http.Request) (Principal, error) {
+	claims, err := a.Claims(r)
+	if err != nil {
+		return Principal{}, err
+	}
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return Principal{}, fmt.Errorf("%w: no sub claim", ErrUnauthenticated)
+	}
+	p := Principal{Subject: sub}
+	for _, v := range ClaimValues(claims[a.cfg.Claim]) {
+		role, ok := a.cfg.Roles[v]
+		if !ok && ranks[Role(v)] > 0 {
+			role = Role(v)
+		}
+		if ranks[role] > ranks[p.Role] {
+			p.Role = role
+		}
+	}
+	if p.Role == "" {
+		return p, fmt.Errorf("%w: %s", ErrNoRole, sub)
+	}
+	return p, nil
+}
+
+// Authorize returns the request's caller if it has need's permissions, and
+// otherwise answers 401 or 403 and returns false. With a nil Authorizer it
+// always succeeds, with a zero Principal.
+func (a *Authorizer) Authorize(w http.ResponseWriter, r *http.Request, need Role) (Principal, bool) {
+	if a == nil {
+		return Principal{}, true
+	}
+	p, err := a.Authenticate(r)
+	switch {
+	case errors.Is(err, ErrUnauthenticated):
+		WriteUnauthenticated(w, err.Error())
+		return p, false
+	case err != nil || !p.Role.Includes(need):
+		WriteError(w, &engine.ErrorEnvelope{
+			Code:       "FORBIDDEN",
+			Message:    fmt.Sprintf("%s %s needs the %s role", r.Method, r.URL.Path, need),
+			HttpStatus: http.StatusForbidden,
+			Category:   "authorization",
+			Details:    map[string]any{"required_role": need, "role": p.Role},
+		})
+		return p, false
+	}
+	return p, true
+}
+
+// Require wraps h so it only serves callers with need's permissions, who
+// are available to it through FromContext.
+func (a *Authorizer) Require(need Role, h http.HandlerFunc) http.HandlerFunc {
+	if a == nil {
+		return h
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, ok := a.Authorize(w, r, need)
+		if !ok {
+			return
+		}
+		h(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, p)))
+	}
+}
+
+type principalKey struct{}
+
+// FromContext returns the caller Require authorized.
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// WriteUnauthenticated answers 401 with an UNAUTHENTICATED error envelope.
+func WriteUnauthenticated(w http.ResponseWriter, msg string) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	WriteError(w, &engine.ErrorEnvelope{
+		Code:       "UNAUTHENTICATED",
+		Message:    msg,
+		HttpStatus: http.StatusUnauthorized,
+		Category:   "authorization",
+		Suggestion: "Send a valid token as Authorization: Bearer <token>",
+	})
+}
+
+// WriteError answers with env as {"error": env}.
+func WriteError(w http.ResponseWriter, env *engine.ErrorEnvelope) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(env.HttpStatus)
+	json.NewEncoder(w).Encode(map[string]any{"error": env})
+}
+
+// ClaimValues returns a string or array-of-strings claim as a slice.
+func ClaimValues(claim any) []string {
+	switch v := claim.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		var out []string
+		for _, s := range v {
+			if s, ok := s.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func hasValue(claim any, want string) bool {
+	return slices.Contains(ClaimValues(claim), want)
+}
@@ -0,0 +1,268 @@
+// Package stats aggregates audit records into rolling decision analytics.
+//
+// Aggregator is an engine.AuditSink that keeps per-operation counters over a
+// sliding window. Handler serves any Source as JSON, so a persistent analytics
+// store can replace the in-memory aggregator without touching the endpoint.
+// WithEscalationBacklog adds the escalation queues' SLA status.
+// ExperimentsHandler reports the outcomes of each experiment arm.
+package stats
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"covenant-poc/executor/engine"
+)
+
+// buckets is the number of slots the window is divided into.
+const buckets = 60
+
+// Source provides a point-in-time stats snapshot.
+type Source interface {
+	Snapshot() Snapshot
+}
+
+// Snapshot is the response body of GET /stats.
+type Snapshot struct {
+	WindowSeconds      int                       `json:"window_seconds"`
+	Evaluations        int                       `json:"evaluations"`
+	Operations         map[string]OperationStats `json:"operations"`
+	DeniesByCode       map[string]int            `json:"denies_by_code"`
+	DenyRateByCode     map[string]float64        `json:"deny_rate_by_code"`
+	EscalationsByQueue map[string]int            `json:"escalations_by_queue"`
+	// EscalationBacklog is the pending escalations as of the last SLA
+	// sweep, when the source has one; see WithEscalationBacklog.
+	EscalationBacklog *engine.EscalationBacklog `json:"escalation_backlog,omitempty"`
+	// Experiments maps each experiment to the stats of its arms.
+	Experiments map[string]map[string]ArmStats `json:"experiments,omitempty"`
+}
+
+// ArmStats summarises evaluations in one arm of an experiment within the
+// window.
+type ArmStats struct {
+	Evaluations int            `json:"evaluations"`
+	Outcomes    map[string]int `json:"outcomes"`
+	DenyRate    float64        `json:"deny_rate"`
+}
+
+// OperationStats summarises evaluations of one operation within the window.
+type OperationStats struct {
+	Evaluations  int            `json:"evaluations"`
+	Outcomes     map[string]int `json:"outcomes"`
+	DenyRate     float64        `json:"deny_rate"`
+	AvgLatencyMS float64        `json:"avg_latency_ms"`
+}
+
+// Aggregator keeps rolling counters over a fixed window.
+type Aggregator struct {
+	mu     sync.Mutex
+	window time.Duration
+	slot   time.Duration
+	ring   [buckets]bucket
+	now    func() time.Time
+}
+
+type bucket struct {
+	start       time.Time
+	ops         map[string]*opCounts
+	denyCodes   map[string]int
+	escalations map[string]int
+	arms        map[armKey]map[string]int // outcomes
+}
+
+type armKey struct{ experiment, arm string }
+
+type opCounts struct {
+	evaluations int
+	outcomes    map[string]int
+	latencyMS   float64
+}
+
+// NewAggregator returns an Aggregator covering the given window.
+func NewAggregator(window time.Duration) *Aggregator {
+	slot := window / buckets
+	if slot <= 0 {
+		slot = time.Millisecond
+	}
+	return &Aggregator{window: window, slot: slot, now: time.Now}
+}
+
+// Record implements engine.AuditSink. Escalation resolutions are not
+// evaluations, and are not counted.
+func (a *Aggregator) Record(_ context.Context, rec *engine.AuditRecord) {
+	if rec.Resolution != nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	b := a.current()
+	oc := b.ops[rec.Operation]
+	if oc == nil {
+		oc = &opCounts{outcomes: map[string]int{}}
+		b.ops[rec.Operation] = oc
+	}
+	oc.evaluations++
+	oc.outcomes[rec.Outcome]++
+	oc.latencyMS += rec.DurationMS
+	for experiment, arm := range rec.Experiments {
+		k := armKey{experiment, arm}
+		if b.arms[k] == nil {
+			b.arms[k] = map[string]int{}
+		}
+		b.arms[k][rec.Outcome]++
+	}
+
+	escalated := false
+	for _, v := range rec.Verdicts {
+		switch v.Type {
+		case "deny":
+			b.denyCodes[v.Code]++
+		case "escalate":
+			// Only the resolved escalation is queued; count it once.
+			if rec.Outcome == "escalated" && !escalated {
+				b.escalations[v.Queue]++
+				escalated = true
+			}
+		}
+	}
+}
+
+// current returns the bucket for now, resetting it if it holds stale data.
+func (a *Aggregator) current() *bucket {
+	start := a.now().Truncate(a.slot)
+	b := &a.ring[(start.UnixNano()/int64(a.slot))%buckets]
+	if !b.start.Equal(start) {
+		*b = bucket{
+			start:       start,
+			ops:         map[string]*opCounts{},
+			denyCodes:   map[string]int{},
+			escalations: map[string]int{},
+			arms:        map[armKey]map[string]int{},
+		}
+	}
+	return b
+}
+
+// Snapshot implements Source.
+func (a *Aggregator) Snapshot() Snapshot {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	s := Snapshot{
+		WindowSeconds:      int(a.window / time.Second),
+		Operations:         map[string]OperationStats{},
+		DeniesByCode:       map[string]int{},
+		DenyRateByCode:     map[string]float64{},
+		EscalationsByQueue: map[string]int{},
+	}
+	cutoff := a.now().Add(-a.window)
+	latency := map[string]float64{}
+
+	for i := range a.ring {
+		b := &a.ring[i]
+		if b.ops == nil || !b.start.After(cutoff) {
+			continue
+		}
+		for name, oc := range b.ops {
+			st := s.Operations[name]
+			if st.Outcomes == nil {
+				st.Outcomes = map[string]int{}
+			}
+			st.Evaluations += oc.evaluations
+			for outcome, n := range oc.outcomes {
+				st.Outcomes[outcome] += n
+			}
+			latency[name] += oc.latencyMS
+			s.Operations[name] = st
+			s.Evaluations += oc.evaluations
+		}
+		for code, n := range b.denyCodes {
+			s.DeniesByCode[code] += n
+		}
+		for queue, n := range b.escalations {
+			s.EscalationsByQueue[queue] += n
+		}
+		for k, outcomes := range b.arms {
+			if s.Experiments == nil {
+				s.Experiments = map[string]map[string]ArmStats{}
+			}
+			if s.Experiments[k.experiment] == nil {
+				s.Experiments[k.experiment] = map[string]ArmStats{}
+			}
+			st := s.Experiments[k.experiment][k.arm]
+			if st.Outcomes == nil {
+				st.Outcomes = map[string]int{}
+			}
+			for outcome, n := range outcomes {
+				st.Outcomes[outcome] += n
+				st.Evaluations += n
+			}
+			s.Experiments[k.experiment][k.arm] = st
+		}
+	}
+
+	for name, st := range s.Operations {
+		denied := st.Outcomes["denied"] + st.Outcomes["would_deny"]
+		st.DenyRate = float64(denied) / float64(st.Evaluations)
+		st.AvgLatencyMS = latency[name] / float64(st.Evaluations)
+		s.Operations[name] = st
+	}
+	for _, arms := range s.Experiments {
+		for arm, st := range arms {
+			st.DenyRate = float64(st.Outcomes["denied"]+st.Outcomes["would_deny"]) / float64(st.Evaluations)
+			arms[arm] = st
+		}
+	}
+	for code, n := range s.DeniesByCode {
+		s.DenyRateByCode[code] = float64(n) / float64(s.Evaluations)
+	}
+	return s
+}
+
+// WithEscalationBacklog adds the escalation backlog that backlog returns,
+// which may be nil, to src's snapshots.
+func WithEscalationBacklog(src Source, backlog func() *engine.EscalationBacklog) Source {
+	return backlogSource{src, backlog}
+}
+
+type backlogSource struct {
+	Source
+	backlog func() *engine.EscalationBacklog
+}
+
+func (s backlogSource) Snapshot() Snapshot {
+	snap := s.Source.Snapshot()
+	snap.EscalationBacklog = s.backlog()
+	return snap
+}
+
+// Handler serves GET /stats from src.
+func Handler(src Source) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(src.Snapshot())
+	})
+}
+
+// ExperimentsHandler serves GET /stats/experiments from src: the outcomes
+// and deny rate of each experiment arm within the window.
+func ExperimentsHandler(src Source) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		snap := src.Snapshot()
+		experiments := snap.Experiments
+		if experiments == nil {
+			experiments = map[string]map[string]ArmStats{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(map[string]any{
+			"window_seconds": snap.WindowSeconds,
+			"experiments":    experiments,
+		})
+	})
+}
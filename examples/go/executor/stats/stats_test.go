@@ -0,0 +1,104 @@
+package stats
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"covenant-poc/executor/engine"
+)
+
+func newTestAggregator(window time.Duration) (*Aggregator, *time.Time) {
+	a := NewAggregator(window)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	a.now = func() time.Time { return now }
+	return a, &now
+}
+
+func TestAggregator_countsOutcomesDeniesAndEscalations(t *testing.T) {
+	a, _ := newTestAggregator(time.Minute)
+	ctx := context.Background()
+
+	a.Record(ctx, &engine.AuditRecord{Operation: "Pay", Outcome: "executed", DurationMS: 2})
+	a.Record(ctx, &engine.AuditRecord{Operation: "Pay", Outcome: "denied", DurationMS: 4, Verdicts: []engine.Verdict{
+		{Type: "deny", Code: "ACCOUNT_CLOSED"},
+		{Type: "flag", Code: "LARGE"},
+	}})
+	a.Record(ctx, &engine.AuditRecord{Operation: "Pay", Outcome: "escalated", DurationMS: 6, Verdicts: []engine.Verdict{
+		{Type: "escalate", Queue: "fraud-review"},
+		{Type: "escalate", Queue: "other"},
+	}})
+	a.Record(ctx, &engine.AuditRecord{Operation: "Get", Outcome: "executed", DurationMS: 1})
+
+	s := a.Snapshot()
+	if s.Evaluations != 4 {
+		t.Fatalf("expected 4 evaluations, got %d", s.Evaluations)
+	}
+	pay := s.Operations["Pay"]
+	if pay.Evaluations != 3 || pay.Outcomes["denied"] != 1 || pay.AvgLatencyMS != 4 {
+		t.Fatalf("unexpected Pay stats: %+v", pay)
+	}
+	if pay.DenyRate != 1.0/3 {
+		t.Fatalf("expected deny rate 1/3, got %v", pay.DenyRate)
+	}
+	if s.DeniesByCode["ACCOUNT_CLOSED"] != 1 || s.DenyRateByCode["ACCOUNT_CLOSED"] != 0.25 {
+		t.Fatalf("unexpected deny code stats: %v %v", s.DeniesByCode, s.DenyRateByCode)
+	}
+	if s.EscalationsByQueue["fraud-review"] != 1 || s.EscalationsByQueue["other"] != 0 {
+		t.Fatalf("expected only the resolved escalation to be counted, got %v", s.EscalationsByQueue)
+	}
+}
+
+func TestAggregator_reportsDenyRatePerExperimentArm(t *testing.T) {
+	a, _ := newTestAggregator(time.Minute)
+	ctx := context.Background()
+	for _, r := range []struct{ arm, outcome string }{
+		{"control", "denied"}, {"control", "executed"}, {"treatment", "executed"}, {"treatment", "executed"},
+	} {
+		a.Record(ctx, &engine.AuditRecord{Operation: "Pay", Outcome: r.outcome, Experiments: map[string]string{"limit-test": r.arm}})
+	}
+	a.Record(ctx, &engine.AuditRecord{Operation: "Pay", Outcome: "denied"})
+
+	arms := a.Snapshot().Experiments["limit-test"]
+	if c := arms["control"]; c.Evaluations != 2 || c.DenyRate != 0.5 {
+		t.Errorf("unexpected control stats: %+v", c)
+	}
+	if tr := arms["treatment"]; tr.Evaluations != 2 || tr.DenyRate != 0 {
+		t.Errorf("unexpected treatment stats: %+v", tr)
+	}
+}
+
+func TestAggregator_dropsRecordsOutsideWindow(t *testing.T) {
+	a, now := newTestAggregator(time.Minute)
+	ctx := context.Background()
+
+	a.Record(ctx, &engine.AuditRecord{Operation: "Pay", Outcome: "executed"})
+	*now = now.Add(30 * time.Second)
+	a.Record(ctx, &engine.AuditRecord{Operation: "Pay", Outcome: "executed"})
+
+	if got := a.Snapshot().Evaluations; got != 2 {
+		t.Fatalf("expected 2 evaluations within window, got %d", got)
+	}
+
+	*now = now.Add(45 * time.Second)
+	if got := a.Snapshot().Evaluations; got != 1 {
+		t.Fatalf("expected oldest record to age out, got %d evaluations", got)
+	}
+
+	*now = now.Add(2 * time.Minute)
+	if got := a.Snapshot().Evaluations; got != 0 {
+		t.Fatalf("expected empty window, got %d evaluations", got)
+	}
+}
+
+func TestWithEscalationBacklog_addsBacklogToSnapshot(t *testing.T) {
+	a, _ := newTestAggregator(time.Minute)
+	a.Record(context.Background(), &engine.AuditRecord{Operation: "Pay", Outcome: "executed"})
+	a.Record(context.Background(), &engine.AuditRecord{Operation: "Pay", Outcome: "denied", Resolution: &engine.EscalationResolution{EscalationID: "esc_1"}})
+	backlog := &engine.EscalationBacklog{Pending: 3, Overdue: 1, Queues: map[string]*engine.QueueBacklog{"review": {Pending: 3, Overdue: 1}}}
+
+	s := WithEscalationBacklog(a, func() *engine.EscalationBacklog { return backlog }).Snapshot()
+	if s.EscalationBacklog != backlog || s.Evaluations != 1 {
+		t.Errorf("expected the backlog and one evaluation, got %+v", s)
+	}
+}
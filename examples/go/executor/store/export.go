@@ -0,0 +1,136 @@
+package store
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"covenant-poc/executor/engine"
+)
+
+// Log is a store whose decision history can be scanned by time.
+type Log interface {
+	// Decisions calls fn for each audit record with from <= Timestamp < to,
+	// oldest first, and stops at the first error fn returns.
+	Decisions(ctx context.Context, from, to time.Time, fn func(*engine.AuditRecord) error) error
+}
+
+// Export formats.
+const (
+	FormatNDJSON  = "ndjson"
+	FormatParquet = "parquet"
+)
+
+// ContentType returns the media type of an export format, and false if the
+// format is unknown.
+func ContentType(format string) (string, bool) {
+	switch format {
+	case FormatNDJSON:
+		return "application/x-ndjson", true
+	case FormatParquet:
+		return "application/vnd.apache.parquet", true
+	}
+	return "", false
+}
+
+// Export streams the decisions in [from, to) from l to w, oldest first.
+//
+// NDJSON writes one engine.AuditRecord per line, exactly as stored. Parquet
+// writes one row per decision with the scalar fields as typed columns and the
+// input, fact snapshot and verdicts as JSON columns, zstd-compressed, for
+// loading into warehouse and archive tooling.
+func Export(ctx context.Context, w io.Writer, l Log, from, to time.Time, format string) error {
+	switch format {
+	case FormatNDJSON:
+		return exportNDJSON(ctx, w, l, from, to)
+	case FormatParquet:
+		return exportParquet(ctx, w, l, from, to)
+	}
+	return fmt.Errorf("unknown export format %q", format)
+}
+
+func exportNDJSON(ctx context.Context, w io.Writer, l Log, from, to time.Time) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	err := l.Decisions(ctx, from, to, func(rec *engine.AuditRecord) error {
+		return enc.Encode(rec)
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// parquetRecord is the Parquet row layout of an engine.AuditRecord.
+type parquetRecord struct {
+	InvocationID    string    `parquet:"invocation_id"`
+	Timestamp       time.Time `parquet:"timestamp,timestamp(microsecond)"`
+	Operation       string    `parquet:"operation,dict"`
+	Outcome         string    `parquet:"outcome,dict"`
+	ErrorCode       string    `parquet:"error_code,optional,dict"`
+	DryRun          bool      `parquet:"dry_run"`
+	ContractVersion string    `parquet:"contract_version,dict"`
+	ContractSemver  string    `parquet:"contract_semver,optional,dict"`
+	DurationMS      float64   `parquet:"duration_ms"`
+	SampleRate      float64   `parquet:"sample_rate"`
+	RulesMatched    []string  `parquet:"rules_matched"`
+	Input           []byte    `parquet:"input_payload,json"`
+	FactSnapshot    []byte    `parquet:"fact_snapshot,json"`
+	Verdicts        []byte    `parquet:"verdicts,json"`
+}
+
+// parquetRowGroup bounds how many rows are buffered before a row group is
+// flushed to w.
+const parquetRowGroup = 50_000
+
+func exportParquet(ctx context.Context, w io.Writer, l Log, from, to time.Time) error {
+	pw := parquet.NewGenericWriter[parquetRecord](w,
+		parquet.Compression(&parquet.Zstd),
+		parquet.MaxRowsPerRowGroup(parquetRowGroup),
+	)
+	row := make([]parquetRecord, 1)
+	err := l.Decisions(ctx, from, to, func(rec *engine.AuditRecord) error {
+		r, err := toParquet(rec)
+		if err != nil {
+			return fmt.Errorf("%s: %w", rec.InvocationID, err)
+		}
+		row[0] = r
+		_, err = pw.Write(row)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return pw.Close()
+}
+
+func toParquet(rec *engine.AuditRecord) (parquetRecord, error) {
+	r := parquetRecord{
+		InvocationID:    rec.InvocationID,
+		Timestamp:       rec.Timestamp.UTC(),
+		Operation:       rec.Operation,
+		Outcome:         rec.Outcome,
+		ErrorCode:       rec.ErrorCode,
+		DryRun:          rec.DryRun,
+		ContractVersion: rec.ContractVersion,
+		ContractSemver:  rec.ContractSemver,
+		DurationMS:      rec.DurationMS,
+		SampleRate:      rec.SampleRate,
+		RulesMatched:    rec.RulesMatched,
+	}
+	// Absent maps and slices marshal as JSON null.
+	var err error
+	if r.Input, err = json.Marshal(rec.Input); err != nil {
+		return r, err
+	}
+	if r.FactSnapshot, err = json.Marshal(rec.FactSnapshot); err != nil {
+		return r, err
+	}
+	r.Verdicts, err = json.Marshal(rec.Verdicts)
+	return r, err
+}
@@ -0,0 +1,97 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"covenant-poc/executor/engine"
+)
+
+// sliceLog is a Log over records already in timestamp order.
+type sliceLog []*engine.AuditRecord
+
+func (l sliceLog) Decisions(_ context.Context, from, to time.Time, fn func(*engine.AuditRecord) error) error {
+	for _, rec := range l {
+		if !rec.Timestamp.Before(from) && rec.Timestamp.Before(to) {
+			if err := fn(rec); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+var base = time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func testLog() sliceLog {
+	return sliceLog{
+		{InvocationID: "inv_1", Timestamp: base, Operation: "Pay", Outcome: "executed",
+			Input: map[string]any{"amount": 10.0}, ContractVersion: "v1", RulesMatched: []string{}},
+		{InvocationID: "inv_2", Timestamp: base.Add(time.Minute), Operation: "Pay", Outcome: "denied",
+			ErrorCode: "LIMIT", Input: map[string]any{"amount": 900.0}, ContractVersion: "v1",
+			RulesMatched: []string{"limit"}, Verdicts: []engine.Verdict{{Rule: "limit", Type: "deny", Code: "LIMIT"}}},
+		{InvocationID: "inv_3", Timestamp: base.Add(time.Hour), Operation: "Refund", Outcome: "executed"},
+	}
+}
+
+func TestExport_ndjson(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Export(context.Background(), &buf, testLog(), base, base.Add(time.Hour), FormatNDJSON); err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	sc := bufio.NewScanner(&buf)
+	for sc.Scan() {
+		var rec engine.AuditRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			t.Fatalf("line %q: %v", sc.Text(), err)
+		}
+		ids = append(ids, rec.InvocationID)
+	}
+	if len(ids) != 2 || ids[0] != "inv_1" || ids[1] != "inv_2" {
+		t.Errorf("expected inv_1, inv_2, got %v", ids)
+	}
+}
+
+func TestExport_parquet(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Export(context.Background(), &buf, testLog(), base, base.Add(time.Hour), FormatParquet); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := parquet.Read[parquetRecord](bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("read back: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(rows))
+	}
+	denied := rows[1]
+	if denied.InvocationID != "inv_2" || denied.Outcome != "denied" || denied.ErrorCode != "LIMIT" ||
+		!denied.Timestamp.Equal(base.Add(time.Minute)) || len(denied.RulesMatched) != 1 {
+		t.Errorf("unexpected row %+v", denied)
+	}
+	var verdicts []engine.Verdict
+	if err := json.Unmarshal(denied.Verdicts, &verdicts); err != nil || verdicts[0].Code != "LIMIT" {
+		t.Errorf("unexpected verdicts %s: %v", denied.Verdicts, err)
+	}
+	if string(rows[0].Input) != `{"amount":10}` {
+		t.Errorf("unexpected input %s", rows[0].Input)
+	}
+}
+
+func TestExport_unknownFormat(t *testing.T) {
+	if err := Export(context.Background(), &bytes.Buffer{}, testLog(), base, base, "csv"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+	if _, ok := ContentType("csv"); ok {
+		t.Error("csv must not have a content type")
+	}
+}
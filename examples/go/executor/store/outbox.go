@@ -0,0 +1,193 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"time"
+
+	"covenant-poc/executor/engine"
+)
+
+// Outbox defaults.
+const (
+	// outboxLease is how long a claimed entry is left to its dispatcher
+	// before another may claim it. It outlasts the delivery of a batch.
+	outboxLease = 5 * time.Minute
+	// outboxBatch is how many entries a dispatcher claims at once.
+	outboxBatch = 20
+	// deliveryTimeout bounds one delivery.
+	deliveryTimeout = 10 * time.Second
+	// maxOutboxBackoff caps the wait between attempts at an entry.
+	maxOutboxBackoff = time.Hour
+)
+
+// outboxCounts is published at /debug/vars as covenant_outbox.
+var outboxCounts = expvar.NewMap("covenant_outbox")
+
+// OutboxSink is a destination of side effects, like decision events or
+// escalation notifications, that must not be lost if the executor stops
+// between recording a decision and delivering them.
+type OutboxSink struct {
+	// Name identifies the sink in outbox entries; it must stay the same
+	// across restarts.
+	Name string
+	// Accept reports whether rec goes to the sink; nil accepts every
+	// record.
+	Accept func(rec *engine.AuditRecord) bool
+	// Deliver delivers rec synchronously. It may be called more than once
+	// for a record, so the receiver should deduplicate by invocation ID.
+	Deliver func(ctx context.Context, rec *engine.AuditRecord) error
+}
+
+// Accepts reports whether rec goes to s.
+func (s OutboxSink) Accepts(rec *engine.AuditRecord) bool {
+	return s.Accept == nil || s.Accept(rec)
+}
+
+// OutboxEntry is an audit record awaiting delivery to a sink.
+type OutboxEntry struct {
+	ID            int64               `json:"id"`
+	Sink          string              `json:"sink"`
+	Record        *engine.AuditRecord `json:"record"`
+	CreatedAt     time.Time           `json:"created_at"`
+	Attempts      int                 `json:"attempts"`
+	NextAttemptAt time.Time           `json:"next_attempt_at,omitzero"`
+	LastError     string              `json:"last_error,omitempty"`
+	// DeadLetteredAt is when a dead letter was given up on.
+	DeadLetteredAt time.Time `json:"dead_lettered_at,omitzero"`
+}
+
+// Outbox is a store that writes outbox entries in the same transaction as
+// the audit records they carry, so a recorded decision's side effects are
+// delivered even if the executor stops before delivering them.
+type Outbox interface {
+	// EnableOutbox makes Record also add an entry for each of sinks that
+	// accepts the record. It must be called before the store records.
+	EnableOutbox(sinks ...OutboxSink)
+	// ClaimOutbox leases up to limit entries due at now, oldest first,
+	// counting an attempt at each and deferring their next attempt until
+	// the lease ends, so that concurrent dispatchers claim each once.
+	ClaimOutbox(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*OutboxEntry, error)
+	// CompleteOutbox removes a delivered entry.
+	CompleteOutbox(ctx context.Context, id int64) error
+	// RetryOutbox schedules an entry's next attempt at at, recording why
+	// the last one failed.
+	RetryOutbox(ctx context.Context, id int64, at time.Time, lastErr string) error
+	// PendingOutbox lists up to limit undelivered entries, oldest first,
+	// and counts them all.
+	PendingOutbox(ctx context.Context, limit int) ([]*OutboxEntry, int64, error)
+
+	// DeadLetter moves an entry that failed too often to the dead letters,
+	// recording why its last attempt failed.
+	DeadLetter(ctx context.Context, id int64, at time.Time, lastErr string) error
+	// DeadLetters lists up to limit dead letters, oldest first, and counts
+	// them all.
+	DeadLetters(ctx context.Context, limit int) ([]*OutboxEntry, int64, error)
+	// RetryDeadLetter moves a dead letter back to the outbox, due at at
+	// with no attempts. It fails with engine.ErrNotFound if there is no
+	// such dead letter.
+	RetryDeadLetter(ctx context.Context, id int64, at time.Time) error
+	// DiscardDeadLetter deletes a dead letter. It fails with
+	// engine.ErrNotFound if there is no such dead letter.
+	DiscardDeadLetter(ctx context.Context, id int64) error
+}
+
+// OutboxDispatcher delivers outbox entries to their sinks. Several
+// dispatchers, in one executor or many, may share a store: each entry is
+// leased to one at a time, and removed once delivered. An executor that
+// stops after delivering an entry but before removing it delivers it again
+// once the lease ends, so delivery is at least once; sinks deduplicate by
+// invocation ID.
+type OutboxDispatcher struct {
+	outbox      Outbox
+	sinks       map[string]OutboxSink
+	maxAttempts int
+	now         func() time.Time
+}
+
+// NewOutboxDispatcher returns a dispatcher delivering o's entries to sinks.
+// An entry whose delivery fails maxAttempts times is moved to the dead
+// letters; with maxAttempts 0 it is retried until delivered.
+func NewOutboxDispatcher(o Outbox, maxAttempts int, sinks ...OutboxSink) *OutboxDispatcher {
+	d := &OutboxDispatcher{outbox: o, sinks: map[string]OutboxSink{}, maxAttempts: maxAttempts, now: time.Now}
+	for _, s := range sinks {
+		d.sinks[s.Name] = s
+	}
+	return d
+}
+
+// Dispatch claims a batch of due entries and attempts each, and reports how
+// many were delivered. A failed delivery is retried with exponential
+// backoff, from a second up to an hour, or dead-lettered once it has been
+// attempted maxAttempts times.
+func (d *OutboxDispatcher) Dispatch(ctx context.Context) (int, error) {
+	entries, err := d.outbox.ClaimOutbox(ctx, d.now(), outboxLease, outboxBatch)
+	if err != nil {
+		return 0, err
+	}
+	delivered := 0
+	var errs []error
+	for _, e := range entries {
+		err := d.deliver(ctx, e)
+		if err == nil {
+			outboxCounts.Add("delivered", 1)
+			delivered++
+			errs = append(errs, d.outbox.CompleteOutbox(ctx, e.ID))
+			continue
+		}
+		outboxCounts.Add("failed", 1)
+		log.Printf("outbox: %s to %s, attempt %d: %v", e.Record.InvocationID, e.Sink, e.Attempts, err)
+		if d.maxAttempts > 0 && e.Attempts >= d.maxAttempts {
+			outboxCounts.Add("dead_lettered", 1)
+			log.Printf("outbox: %s to %s failed %d times; moved to the dead letters", e.Record.InvocationID, e.Sink, e.Attempts)
+			errs = append(errs, d.outbox.DeadLetter(ctx, e.ID, d.now(), err.Error()))
+			continue
+		}
+		errs = append(errs, d.outbox.RetryOutbox(ctx, e.ID, d.now().Add(outboxBackoff(e.Attempts)), err.Error()))
+	}
+	return delivered, errors.Join(errs...)
+}
+
+func (d *OutboxDispatcher) deliver(ctx context.Context, e *OutboxEntry) error {
+	s, ok := d.sinks[e.Sink]
+	if !ok {
+		return fmt.Errorf("no sink %q", e.Sink)
+	}
+	ctx, cancel := context.WithTimeout(ctx, deliveryTimeout)
+	defer cancel()
+	return s.Deliver(ctx, e.Record)
+}
+
+// outboxBackoff is the wait after failed attempt n.
+func outboxBackoff(n int) time.Duration {
+	d := time.Second
+	for i := 1; i < n && d < maxOutboxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxOutboxBackoff)
+}
+
+// Run dispatches immediately and then every interval until ctx is done,
+// without waiting while full batches are delivered. Failures are logged
+// and retried on the next tick.
+func (d *OutboxDispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		n, err := d.Dispatch(ctx)
+		if err != nil {
+			log.Printf("outbox: %v", err)
+		}
+		if err == nil && n == outboxBatch && ctx.Err() == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
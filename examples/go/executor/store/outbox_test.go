@@ -0,0 +1,96 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"covenant-poc/executor/engine"
+)
+
+// fakeOutbox hands out its entries and records what became of them.
+type fakeOutbox struct {
+	entries   []*OutboxEntry
+	completed []int64
+	retries   map[int64]time.Time
+	dead      []int64
+	errs      map[int64]string
+}
+
+func (f *fakeOutbox) EnableOutbox(...OutboxSink) {}
+
+func (f *fakeOutbox) ClaimOutbox(_ context.Context, _ time.Time, _ time.Duration, limit int) ([]*OutboxEntry, error) {
+	return f.entries[:min(limit, len(f.entries))], nil
+}
+
+func (f *fakeOutbox) CompleteOutbox(_ context.Context, id int64) error {
+	f.completed = append(f.completed, id)
+	return nil
+}
+
+func (f *fakeOutbox) RetryOutbox(_ context.Context, id int64, at time.Time, lastErr string) error {
+	f.retries[id], f.errs[id] = at, lastErr
+	return nil
+}
+
+func (f *fakeOutbox) PendingOutbox(context.Context, int) ([]*OutboxEntry, int64, error) {
+	return f.entries, int64(len(f.entries)), nil
+}
+
+func (f *fakeOutbox) DeadLetter(_ context.Context, id int64, _ time.Time, lastErr string) error {
+	f.dead, f.errs[id] = append(f.dead, id), lastErr
+	return nil
+}
+
+func (f *fakeOutbox) DeadLetters(context.Context, int) ([]*OutboxEntry, int64, error) {
+	return nil, 0, nil
+}
+
+func (f *fakeOutbox) RetryDeadLetter(context.Context, int64, time.Time) error { return nil }
+
+func (f *fakeOutbox) DiscardDeadLetter(context.Context, int64) error { return nil }
+
+func TestOutboxDispatcher_Dispatch(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	rec := &engine.AuditRecord{InvocationID: "inv_1"}
+	f := &fakeOutbox{
+		entries: []*OutboxEntry{
+			{ID: 1, Sink: "events", Record: rec, Attempts: 1},
+			{ID: 2, Sink: "webhook", Record: rec, Attempts: 3},
+			{ID: 3, Sink: "retired", Record: rec, Attempts: 1},
+			{ID: 4, Sink: "webhook", Record: rec, Attempts: 5},
+		},
+		retries: map[int64]time.Time{},
+		errs:    map[int64]string{},
+	}
+	var delivered []string
+	d := NewOutboxDispatcher(f, 5,
+		OutboxSink{Name: "events", Deliver: func(_ context.Context, rec *engine.AuditRecord) error {
+			delivered = append(delivered, rec.InvocationID)
+			return nil
+		}},
+		OutboxSink{Name: "webhook", Deliver: func(context.Context, *engine.AuditRecord) error {
+			return errors.New("HTTP 503")
+		}},
+	)
+	d.now = func() time.Time { return now }
+
+	n, err := d.Dispatch(context.Background())
+	if err != nil || n != 1 || len(delivered) != 1 {
+		t.Fatalf("expected one delivery, got %d, %v", n, err)
+	}
+	if len(f.completed) != 1 || f.completed[0] != 1 {
+		t.Errorf("expected the delivered entry completed, got %v", f.completed)
+	}
+	// The third attempt failed, so the fourth waits 4s.
+	if !f.retries[2].Equal(now.Add(4*time.Second)) || f.errs[2] != "HTTP 503" {
+		t.Errorf("expected a retry with backoff, got %s %q", f.retries[2], f.errs[2])
+	}
+	if f.errs[3] != `no sink "retired"` {
+		t.Errorf("expected an entry without a sink retried, got %q", f.errs[3])
+	}
+	if _, retried := f.retries[4]; retried || len(f.dead) != 1 || f.dead[0] != 4 || f.errs[4] != "HTTP 503" {
+		t.Errorf("expected the fifth failure dead-lettered, got %v", f.dead)
+	}
+}
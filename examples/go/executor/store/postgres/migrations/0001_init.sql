@@ -0,0 +1,39 @@
+CREATE TABLE audit (
+	invocation_id    TEXT PRIMARY KEY,
+	ts               TIMESTAMPTZ NOT NULL,
+	operation        TEXT NOT NULL,
+	outcome          TEXT NOT NULL,
+	dry_run          BOOLEAN NOT NULL,
+	contract_version TEXT NOT NULL,
+	record           JSONB NOT NULL
+);
+CREATE INDEX audit_ts ON audit (ts);
+CREATE INDEX audit_operation_ts ON audit (operation, ts);
+
+CREATE TABLE idempotency (
+	key         TEXT PRIMARY KEY,
+	fingerprint TEXT NOT NULL,
+	response    JSONB,                 -- NULL while in flight
+	created_at  TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX idempotency_created_at ON idempotency (created_at);
+
+CREATE TABLE escalations (
+	id            TEXT PRIMARY KEY,
+	invocation_id TEXT NOT NULL,
+	operation     TEXT NOT NULL,
+	queue         TEXT NOT NULL,
+	rule          TEXT NOT NULL,
+	reason        TEXT NOT NULL,
+	input         JSONB NOT NULL,
+	status        TEXT NOT NULL,
+	created_at    TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX escalations_queue ON escalations (queue, status, created_at);
+
+CREATE TABLE counters (
+	key          TEXT NOT NULL,
+	window_start TIMESTAMPTZ NOT NULL,
+	value        BIGINT NOT NULL,
+	PRIMARY KEY (key, window_start)
+);
@@ -0,0 +1,9 @@
+ALTER TABLE escalations
+	ADD COLUMN due_at      TIMESTAMPTZ,
+	ADD COLUMN expires_at  TIMESTAMPTZ,
+	ADD COLUMN on_expiry   TEXT NOT NULL DEFAULT '',
+	ADD COLUMN resolved_at TIMESTAMPTZ,
+	ADD COLUMN resolved_by TEXT NOT NULL DEFAULT '',
+	ADD COLUMN output      JSONB,
+	ADD COLUMN error       TEXT NOT NULL DEFAULT '';
+CREATE INDEX escalations_expires_at ON escalations (expires_at) WHERE status = 'pending';
@@ -0,0 +1,8 @@
+CREATE TABLE entity_states (
+	entity     TEXT NOT NULL,
+	id         TEXT NOT NULL,
+	state      TEXT NOT NULL,
+	version    BIGINT NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY (entity, id)
+);
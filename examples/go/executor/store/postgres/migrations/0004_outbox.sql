@@ -0,0 +1,11 @@
+CREATE TABLE outbox (
+	id              BIGSERIAL PRIMARY KEY,
+	sink            TEXT NOT NULL,
+	invocation_id   TEXT NOT NULL,
+	record          JSONB NOT NULL,
+	created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+	attempts        INTEGER NOT NULL DEFAULT 0,
+	next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	last_error      TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX outbox_next_attempt_at ON outbox (next_attempt_at, id);
@@ -0,0 +1,10 @@
+CREATE TABLE outbox_dead_letters (
+	id               BIGINT PRIMARY KEY, -- the outbox entry's
+	sink             TEXT NOT NULL,
+	invocation_id    TEXT NOT NULL,
+	record           JSONB NOT NULL,
+	created_at       TIMESTAMPTZ NOT NULL,
+	attempts         INTEGER NOT NULL,
+	last_error       TEXT NOT NULL,
+	dead_lettered_at TIMESTAMPTZ NOT NULL
+);
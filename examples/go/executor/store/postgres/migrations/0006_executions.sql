@@ -0,0 +1,16 @@
+CREATE TABLE executions (
+	decision_id TEXT PRIMARY KEY,
+	operation   TEXT NOT NULL,
+	executed_at TIMESTAMPTZ NOT NULL,
+	execution   JSONB NOT NULL
+);
+CREATE INDEX executions_executed_at ON executions (executed_at);
+
+CREATE TABLE execution_entities (
+	entity      TEXT NOT NULL,
+	id          TEXT NOT NULL,
+	decision_id TEXT NOT NULL REFERENCES executions (decision_id) ON DELETE CASCADE,
+	executed_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (entity, id, decision_id)
+);
+CREATE INDEX execution_entities_executed_at ON execution_entities (entity, id, executed_at);
@@ -0,0 +1,2 @@
+ALTER TABLE executions ADD COLUMN fingerprint TEXT NOT NULL DEFAULT '';
+CREATE INDEX executions_fingerprint ON executions (operation, fingerprint, executed_at);
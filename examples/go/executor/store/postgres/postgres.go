@@ -0,0 +1,631 @@
+// Package postgres is the production storage backend for executors.
+//
+// Store implements the same storage interfaces as the SQLite store —
+// engine.DecisionStore, engine.IdempotencyStore, engine.EscalationStore,
+// engine.CounterStore, engine.StateStore, engine.ExecutionStore and
+// store.Outbox — on a shared Postgres database, so any number of executor
+// replicas see one decision history, one idempotency key space, one
+// escalation queue, one set of quota counters, one state per entity
+// instance, one execution history and one outbox.
+//
+// Connections come from a pgxpool pool, tuned with the pool_* parameters of
+// the connection URL (pool_max_conns, pool_min_conns,
+// pool_max_conn_lifetime, ...). Open applies the embedded migrations in
+// migrations/ in order, under an advisory lock so replicas starting together
+// migrate once.
+//
+// Integration tests run against a real database with
+//
+//	COVENANT_POSTGRES_URL=postgres://... go test -tags integration ./executor/store/postgres
+package postgres
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"covenant-poc/executor/engine"
+	"covenant-poc/executor/store"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// migrationLock is the advisory lock key held while migrating.
+const migrationLock = 0x636f76656e616e74 // "covenant"
+
+// Store is a Postgres-backed executor store. It is safe for concurrent use.
+type Store struct {
+	pool   *pgxpool.Pool
+	outbox []store.OutboxSink
+}
+
+// Open connects to the database at url and applies pending migrations.
+func Open(ctx context.Context, url string) (*Store, error) {
+	pool, err := pgxpool.New(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	if err := migrate(ctx, pool); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("migrate: %w", err)
+	}
+	return &Store{pool: pool}, nil
+}
+
+// Close closes the pool.
+func (s *Store) Close() {
+	s.pool.Close()
+}
+
+// migrate applies, in version order, each migrations/NNNN_name.sql file not
+// yet recorded in schema_migrations, each in its own transaction.
+func migrate(ctx context.Context, pool *pgxpool.Pool) error {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, int64(migrationLock)); err != nil {
+		return err
+	}
+	defer conn.Exec(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, int64(migrationLock))
+
+	_, err = conn.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return err
+	}
+
+	files, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		name := strings.TrimPrefix(file, "migrations/")
+		version, err := strconv.Atoi(strings.SplitN(name, "_", 2)[0])
+		if err != nil {
+			return fmt.Errorf("%s: file name must start with a version number", name)
+		}
+		var applied bool
+		err = conn.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, version).Scan(&applied)
+		if err != nil {
+			return err
+		}
+		if applied {
+			continue
+		}
+		sql, err := migrations.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		err = pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, string(sql)); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, version)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		log.Printf("postgres: applied migration %s", name)
+	}
+	return nil
+}
+
+// Record implements engine.AuditSink. Records are written synchronously so
+// they are durable once the response is sent; write errors are logged.
+// With the outbox enabled, a record's outbox entries are written in the
+// same transaction.
+func (s *Store) Record(ctx context.Context, rec *engine.AuditRecord) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		log.Printf("postgres audit: %v", err)
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	err = pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx,
+			`INSERT INTO audit (invocation_id, ts, operation, outcome, dry_run, contract_version, record)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7)
+			 ON CONFLICT (invocation_id) DO UPDATE SET record = excluded.record`,
+			rec.InvocationID, rec.Timestamp, rec.Operation, rec.Outcome, rec.DryRun, rec.ContractVersion, data)
+		for _, sink := range s.outbox {
+			if err != nil || !sink.Accepts(rec) {
+				continue
+			}
+			_, err = tx.Exec(ctx, `INSERT INTO outbox (sink, invocation_id, record) VALUES ($1, $2, $3)`, sink.Name, rec.InvocationID, data)
+		}
+		return err
+	})
+	if err != nil {
+		log.Printf("postgres audit: %s: %v", rec.InvocationID, err)
+	}
+}
+
+// EnableOutbox implements store.Outbox.
+func (s *Store) EnableOutbox(sinks ...store.OutboxSink) {
+	s.outbox = sinks
+}
+
+// ClaimOutbox implements store.Outbox. Rows claimed by a concurrent
+// dispatcher are skipped rather than waited for.
+func (s *Store) ClaimOutbox(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*store.OutboxEntry, error) {
+	rows, err := s.pool.Query(ctx,
+		`UPDATE outbox SET attempts = attempts + 1, next_attempt_at = $1
+		 WHERE id IN (
+			SELECT id FROM outbox WHERE next_attempt_at <= $2 ORDER BY id LIMIT $3 FOR UPDATE SKIP LOCKED
+		 )
+		 RETURNING `+outboxColumns,
+		now.Add(lease), now, limit)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := scanOutbox(rows)
+	if err != nil {
+		return nil, err
+	}
+	// RETURNING does not keep the subquery's order.
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	return entries, nil
+}
+
+// CompleteOutbox implements store.Outbox.
+func (s *Store) CompleteOutbox(ctx context.Context, id int64) error {
+	_, err := s.pool.Exec(context.WithoutCancel(ctx), `DELETE FROM outbox WHERE id = $1`, id)
+	return err
+}
+
+// RetryOutbox implements store.Outbox.
+func (s *Store) RetryOutbox(ctx context.Context, id int64, at time.Time, lastErr string) error {
+	_, err := s.pool.Exec(context.WithoutCancel(ctx),
+		`UPDATE outbox SET next_attempt_at = $1, last_error = $2 WHERE id = $3`, at, lastErr, id)
+	return err
+}
+
+// PendingOutbox implements store.Outbox.
+func (s *Store) PendingOutbox(ctx context.Context, limit int) ([]*store.OutboxEntry, int64, error) {
+	var total int64
+	if err := s.pool.QueryRow(ctx, `SELECT count(*) FROM outbox`).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.pool.Query(ctx, `SELECT `+outboxColumns+` FROM outbox ORDER BY id LIMIT $1`, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	entries, err := scanOutbox(rows)
+	return entries, total, err
+}
+
+// DeadLetter implements store.Outbox.
+func (s *Store) DeadLetter(ctx context.Context, id int64, at time.Time, lastErr string) error {
+	ctx = context.WithoutCancel(ctx)
+	return pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx,
+			`INSERT INTO outbox_dead_letters (id, sink, invocation_id, record, created_at, attempts, last_error, dead_lettered_at)
+			 SELECT id, sink, invocation_id, record, created_at, attempts, $1, $2 FROM outbox WHERE id = $3`,
+			lastErr, at, id)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `DELETE FROM outbox WHERE id = $1`, id)
+		return err
+	})
+}
+
+// DeadLetters implements store.Outbox.
+func (s *Store) DeadLetters(ctx context.Context, limit int) ([]*store.OutboxEntry, int64, error) {
+	var total int64
+	if err := s.pool.QueryRow(ctx, `SELECT count(*) FROM outbox_dead_letters`).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.pool.Query(ctx, `SELECT `+deadLetterColumns+` FROM outbox_dead_letters ORDER BY id LIMIT $1`, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	entries, err := scanOutbox(rows)
+	return entries, total, err
+}
+
+// RetryDeadLetter implements store.Outbox.
+func (s *Store) RetryDeadLetter(ctx context.Context, id int64, at time.Time) error {
+	return pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx,
+			`INSERT INTO outbox (id, sink, invocation_id, record, created_at, next_attempt_at, last_error)
+			 SELECT id, sink, invocation_id, record, created_at, $1, last_error FROM outbox_dead_letters WHERE id = $2`,
+			at, id)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return engine.ErrNotFound
+		}
+		_, err = tx.Exec(ctx, `DELETE FROM outbox_dead_letters WHERE id = $1`, id)
+		return err
+	})
+}
+
+// DiscardDeadLetter implements store.Outbox.
+func (s *Store) DiscardDeadLetter(ctx context.Context, id int64) error {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM outbox_dead_letters WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return engine.ErrNotFound
+	}
+	return nil
+}
+
+const (
+	outboxColumns     = `id, sink, record, created_at, attempts, next_attempt_at, last_error, NULL::timestamptz`
+	deadLetterColumns = `id, sink, record, created_at, attempts, NULL::timestamptz, last_error, dead_lettered_at`
+)
+
+func scanOutbox(rows pgx.Rows) ([]*store.OutboxEntry, error) {
+	defer rows.Close()
+	entries := []*store.OutboxEntry{}
+	for rows.Next() {
+		var e store.OutboxEntry
+		var next, dead *time.Time
+		if err := rows.Scan(&e.ID, &e.Sink, &e.Record, &e.CreatedAt, &e.Attempts, &next, &e.LastError, &dead); err != nil {
+			return nil, err
+		}
+		e.CreatedAt, e.NextAttemptAt, e.DeadLetteredAt = e.CreatedAt.UTC(), fromNullTime(next), fromNullTime(dead)
+		entries = append(entries, &e)
+	}
+	return entries, rows.Err()
+}
+
+// Decision implements engine.DecisionStore.
+func (s *Store) Decision(ctx context.Context, invocationID string) (*engine.AuditRecord, error) {
+	var rec engine.AuditRecord
+	err := s.pool.QueryRow(ctx, `SELECT record FROM audit WHERE invocation_id = $1`, invocationID).Scan(&rec)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, engine.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// Decisions calls fn for each audit record with from <= Timestamp < to,
+// oldest first. Rows stream from a single query on one pooled connection.
+func (s *Store) Decisions(ctx context.Context, from, to time.Time, fn func(*engine.AuditRecord) error) error {
+	rows, err := s.pool.Query(ctx,
+		`SELECT record FROM audit WHERE ts >= $1 AND ts < $2 ORDER BY ts, invocation_id`, from, to)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var rec engine.AuditRecord
+		if err := rows.Scan(&rec); err != nil {
+			return err
+		}
+		if err := fn(&rec); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Claim implements engine.IdempotencyStore.
+func (s *Store) Claim(ctx context.Context, key, fingerprint string, at time.Time) (*engine.IdempotencyRecord, error) {
+	tag, err := s.pool.Exec(ctx,
+		`INSERT INTO idempotency (key, fingerprint, created_at) VALUES ($1, $2, $3)
+		 ON CONFLICT (key) DO NOTHING`,
+		key, fingerprint, at)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 1 {
+		return nil, nil
+	}
+
+	ir := engine.IdempotencyRecord{Key: key}
+	err = s.pool.QueryRow(ctx,
+		`SELECT fingerprint, response, created_at FROM idempotency WHERE key = $1`, key,
+	).Scan(&ir.Fingerprint, &ir.Response, &ir.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	ir.CreatedAt = ir.CreatedAt.UTC()
+	return &ir, nil
+}
+
+// Complete implements engine.IdempotencyStore.
+func (s *Store) Complete(ctx context.Context, key string, resp *engine.Response) error {
+	_, err := s.pool.Exec(context.WithoutCancel(ctx), `UPDATE idempotency SET response = $1 WHERE key = $2`, resp, key)
+	return err
+}
+
+// Release implements engine.IdempotencyStore.
+func (s *Store) Release(ctx context.Context, key string) error {
+	_, err := s.pool.Exec(context.WithoutCancel(ctx), `DELETE FROM idempotency WHERE key = $1 AND response IS NULL`, key)
+	return err
+}
+
+// Enqueue implements engine.EscalationStore.
+func (s *Store) Enqueue(ctx context.Context, esc *engine.Escalation) error {
+	_, err := s.pool.Exec(ctx,
+		`INSERT INTO escalations (id, invocation_id, operation, queue, rule, reason, input, status, created_at, due_at, expires_at, on_expiry)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+		esc.ID, esc.InvocationID, esc.Operation, esc.Queue, esc.Rule, esc.Reason, esc.Input, esc.Status, esc.CreatedAt,
+		nullTime(esc.DueAt), nullTime(esc.ExpiresAt), esc.OnExpiry)
+	return err
+}
+
+// Transition implements engine.EscalationStore. The conditional update is
+// atomic, so of several replicas resolving one escalation only one wins.
+func (s *Store) Transition(ctx context.Context, esc *engine.Escalation, from string) error {
+	tag, err := s.pool.Exec(context.WithoutCancel(ctx),
+		`UPDATE escalations SET status = $1, resolved_at = $2, resolved_by = $3, output = $4, error = $5
+		 WHERE id = $6 AND status = $7`,
+		esc.Status, nullTime(esc.ResolvedAt), esc.ResolvedBy, esc.Output, esc.Error, esc.ID, from)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		if _, err := s.Escalation(ctx, esc.ID); err != nil {
+			return err
+		}
+		return engine.ErrStatusChanged
+	}
+	return nil
+}
+
+const escalationColumns = `id, invocation_id, operation, queue, rule, reason, input, status, created_at,
+	due_at, expires_at, on_expiry, resolved_at, resolved_by, output, error`
+
+// Escalation implements engine.EscalationStore.
+func (s *Store) Escalation(ctx context.Context, id string) (*engine.Escalation, error) {
+	esc, err := scanEscalation(s.pool.QueryRow(ctx, `SELECT `+escalationColumns+` FROM escalations WHERE id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, engine.ErrNotFound
+	}
+	return esc, err
+}
+
+// Escalations implements engine.EscalationStore.
+func (s *Store) Escalations(ctx context.Context, queue, status string) ([]*engine.Escalation, error) {
+	rows, err := s.pool.Query(ctx,
+		`SELECT `+escalationColumns+` FROM escalations
+		 WHERE ($1 = '' OR queue = $1) AND ($2 = '' OR status = $2)
+		 ORDER BY created_at, id`,
+		queue, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*engine.Escalation
+	for rows.Next() {
+		esc, err := scanEscalation(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, esc)
+	}
+	return out, rows.Err()
+}
+
+func scanEscalation(row pgx.Row) (*engine.Escalation, error) {
+	var (
+		esc                      engine.Escalation
+		due, expires, resolvedAt *time.Time
+	)
+	err := row.Scan(&esc.ID, &esc.InvocationID, &esc.Operation, &esc.Queue, &esc.Rule, &esc.Reason, &esc.Input, &esc.Status, &esc.CreatedAt,
+		&due, &expires, &esc.OnExpiry, &resolvedAt, &esc.ResolvedBy, &esc.Output, &esc.Error)
+	if err != nil {
+		return nil, err
+	}
+	esc.CreatedAt = esc.CreatedAt.UTC()
+	esc.DueAt, esc.ExpiresAt, esc.ResolvedAt = fromNullTime(due), fromNullTime(expires), fromNullTime(resolvedAt)
+	return &esc, nil
+}
+
+// nullTime stores an optional time: the zero time is NULL.
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func fromNullTime(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return t.UTC()
+}
+
+// Add implements engine.CounterStore. The upsert is atomic, so replicas
+// incrementing the same counter never lose updates.
+func (s *Store) Add(ctx context.Context, key string, window time.Duration, at time.Time, delta int64) (int64, error) {
+	var total int64
+	err := s.pool.QueryRow(ctx,
+		`INSERT INTO counters (key, window_start, value) VALUES ($1, $2, $3)
+		 ON CONFLICT (key, window_start) DO UPDATE SET value = counters.value + excluded.value
+		 RETURNING value`,
+		key, at.Truncate(window), delta,
+	).Scan(&total)
+	return total, err
+}
+
+// Sum implements engine.CounterStore.
+func (s *Store) Sum(ctx context.Context, key string, window time.Duration, from, to time.Time) (int64, error) {
+	var total int64
+	err := s.pool.QueryRow(ctx,
+		`SELECT COALESCE(SUM(value), 0) FROM counters WHERE key = $1 AND window_start BETWEEN $2 AND $3`,
+		key, from.Truncate(window), to.Truncate(window),
+	).Scan(&total)
+	return total, err
+}
+
+// GetState implements engine.StateStore.
+func (s *Store) GetState(ctx context.Context, entity, id string) (string, int64, error) {
+	var state string
+	var version int64
+	err := s.pool.QueryRow(ctx, `SELECT state, version FROM entity_states WHERE entity = $1 AND id = $2`, entity, id).Scan(&state, &version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", 0, engine.ErrNotFound
+	}
+	return state, version, err
+}
+
+// TransitionCAS implements engine.StateStore. The first transition of an
+// instance inserts it; later ones update it only at the expected version.
+// Either statement succeeds for exactly one of the replicas racing on an
+// instance.
+func (s *Store) TransitionCAS(ctx context.Context, entity, id string, version int64, to string) (int64, error) {
+	var tag pgconn.CommandTag
+	var err error
+	if version == 0 {
+		tag, err = s.pool.Exec(ctx,
+			`INSERT INTO entity_states (entity, id, state, version) VALUES ($1, $2, $3, 1)
+			 ON CONFLICT (entity, id) DO NOTHING`,
+			entity, id, to)
+	} else {
+		tag, err = s.pool.Exec(ctx,
+			`UPDATE entity_states SET state = $3, version = version + 1, updated_at = now()
+			 WHERE entity = $1 AND id = $2 AND version = $4`,
+			entity, id, to, version)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if tag.RowsAffected() == 0 {
+		return 0, engine.ErrStateConflict
+	}
+	return version + 1, nil
+}
+
+// RecordExecution implements engine.ExecutionStore. Write errors are also
+// logged, since the engine carries on without them.
+func (s *Store) RecordExecution(ctx context.Context, ex *engine.Execution) error {
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx,
+			`INSERT INTO executions (decision_id, operation, executed_at, execution, fingerprint) VALUES ($1, $2, $3, $4, $5)
+			 ON CONFLICT (decision_id) DO NOTHING`,
+			ex.DecisionID, ex.Operation, ex.ExecutedAt, ex, ex.Fingerprint)
+		for entity, id := range ex.Entities {
+			if err != nil {
+				break
+			}
+			_, err = tx.Exec(ctx,
+				`INSERT INTO execution_entities (entity, id, decision_id, executed_at) VALUES ($1, $2, $3, $4)
+				 ON CONFLICT DO NOTHING`,
+				entity, id, ex.DecisionID, ex.ExecutedAt)
+		}
+		return err
+	})
+	if err != nil {
+		log.Printf("postgres executions: %s: %v", ex.DecisionID, err)
+	}
+	return err
+}
+
+// Execution implements engine.ExecutionStore.
+func (s *Store) Execution(ctx context.Context, decisionID string) (*engine.Execution, error) {
+	var ex engine.Execution
+	err := s.pool.QueryRow(ctx, `SELECT execution FROM executions WHERE decision_id = $1`, decisionID).Scan(&ex)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, engine.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ex, nil
+}
+
+// Executions implements engine.ExecutionStore.
+func (s *Store) Executions(ctx context.Context, q engine.ExecutionQuery) ([]*engine.Execution, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = engine.DefaultHistoryLimit
+	}
+	var rows pgx.Rows
+	var err error
+	if q.Entity != "" {
+		rows, err = s.pool.Query(ctx,
+			`SELECT e.execution FROM execution_entities x JOIN executions e ON e.decision_id = x.decision_id
+			 WHERE x.entity = $1 AND x.id = $2 AND x.executed_at >= $3 AND ($4 = '' OR e.operation = $4)
+			 AND ($5 = '' OR e.fingerprint = $5)
+			 ORDER BY x.executed_at DESC, x.decision_id DESC LIMIT $6`,
+			q.Entity, q.ID, q.Since, q.Operation, q.Fingerprint, limit)
+	} else {
+		rows, err = s.pool.Query(ctx,
+			`SELECT execution FROM executions WHERE executed_at >= $1 AND ($2 = '' OR operation = $2)
+			 AND ($3 = '' OR fingerprint = $3)
+			 ORDER BY executed_at DESC, decision_id DESC LIMIT $4`,
+			q.Since, q.Operation, q.Fingerprint, limit)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	executions := []*engine.Execution{}
+	for rows.Next() {
+		var ex engine.Execution
+		if err := rows.Scan(&ex); err != nil {
+			return nil, err
+		}
+		executions = append(executions, &ex)
+	}
+	return executions, rows.Err()
+}
+
+// Prune deletes audit records, idempotency keys, counter windows and
+// executions from before cutoff and reports how many audit records were
+// removed.
+// Escalations and outbox entries are kept: they are work items, not
+// history.
+func (s *Store) Prune(ctx context.Context, cutoff time.Time) (int64, error) {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM audit WHERE ts < $1`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := s.pool.Exec(ctx, `DELETE FROM idempotency WHERE created_at < $1 AND response IS NOT NULL`, cutoff); err != nil {
+		return tag.RowsAffected(), err
+	}
+	if _, err := s.pool.Exec(ctx, `DELETE FROM counters WHERE window_start < $1`, cutoff); err != nil {
+		return tag.RowsAffected(), err
+	}
+	// Entities go with their executions.
+	if _, err := s.pool.Exec(ctx, `DELETE FROM executions WHERE executed_at < $1`, cutoff); err != nil {
+		return tag.RowsAffected(), err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// Trim deletes the oldest audit records beyond the newest keep and reports
+// how many were removed.
+func (s *Store) Trim(ctx context.Context, keep int64) (int64, error) {
+	tag, err := s.pool.Exec(ctx,
+		`DELETE FROM audit WHERE invocation_id IN (
+			SELECT invocation_id FROM audit ORDER BY ts DESC, invocation_id DESC OFFSET $1
+		 )`, keep)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,459 @@
+//go:build integration
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"covenant-poc/executor/engine"
+	"covenant-poc/executor/store"
+)
+
+// Store must satisfy the engine's storage interfaces.
+var (
+	_ engine.DecisionStore    = (*Store)(nil)
+	_ engine.IdempotencyStore = (*Store)(nil)
+	_ engine.EscalationStore  = (*Store)(nil)
+	_ engine.CounterStore     = (*Store)(nil)
+	_ engine.StateStore       = (*Store)(nil)
+	_ engine.ExecutionStore   = (*Store)(nil)
+	_ store.Log               = (*Store)(nil)
+	_ store.Pruner            = (*Store)(nil)
+	_ store.Outbox            = (*Store)(nil)
+)
+
+// openTest opens COVENANT_POSTGRES_URL with empty tables. Tests share the
+// database, so they do not run in parallel.
+func openTest(t *testing.T) *Store {
+	t.Helper()
+	url := os.Getenv("COVENANT_POSTGRES_URL")
+	if url == "" {
+		t.Skip("COVENANT_POSTGRES_URL not set")
+	}
+	ctx := context.Background()
+	s, err := Open(ctx, url)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	t.Cleanup(s.Close)
+	if _, err := s.pool.Exec(ctx, `TRUNCATE audit, idempotency, escalations, counters, entity_states, outbox, outbox_dead_letters, executions, execution_entities`); err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+	return s
+}
+
+func TestStore_migrateIsIdempotent(t *testing.T) {
+	s := openTest(t)
+	if err := migrate(context.Background(), s.pool); err != nil {
+		t.Fatalf("second migrate: %v", err)
+	}
+}
+
+func TestStore_recordAndLookupDecision(t *testing.T) {
+	s := openTest(t)
+	ctx := context.Background()
+	ts := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	s.Record(ctx, &engine.AuditRecord{
+		InvocationID: "inv_1", Timestamp: ts, Operation: "Pay", Outcome: "denied",
+		Verdicts: []engine.Verdict{{Rule: "r", Type: "deny", Code: "NO"}},
+	})
+
+	rec, err := s.Decision(ctx, "inv_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Operation != "Pay" || rec.Outcome != "denied" || !rec.Timestamp.Equal(ts) || rec.Verdicts[0].Code != "NO" {
+		t.Errorf("unexpected record %+v", rec)
+	}
+	if _, err := s.Decision(ctx, "inv_missing"); !errors.Is(err, engine.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestStore_idempotencyLifecycle(t *testing.T) {
+	s := openTest(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	if r, err := s.Claim(ctx, "k", "fp", now); err != nil || r != nil {
+		t.Fatalf("expected free key, got %+v, %v", r, err)
+	}
+	r, err := s.Claim(ctx, "k", "fp2", now)
+	if err != nil || r == nil || r.Fingerprint != "fp" || r.Response != nil {
+		t.Fatalf("expected in-flight record, got %+v, %v", r, err)
+	}
+	if err := s.Complete(ctx, "k", &engine.Response{InvocationID: "inv_1", Outcome: "executed"}); err != nil {
+		t.Fatal(err)
+	}
+	r, _ = s.Claim(ctx, "k", "fp", now)
+	if r == nil || r.Response == nil || r.Response.InvocationID != "inv_1" {
+		t.Fatalf("expected stored response, got %+v", r)
+	}
+
+	s.Claim(ctx, "k2", "fp", now)
+	s.Release(ctx, "k2")
+	if r, _ := s.Claim(ctx, "k2", "fp", now); r != nil {
+		t.Error("in-flight key was not released")
+	}
+}
+
+func TestStore_concurrentClaimsHaveOneWinner(t *testing.T) {
+	s := openTest(t)
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	winners := 0
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r, err := s.Claim(ctx, "race", "fp", time.Now())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if r == nil {
+				mu.Lock()
+				winners++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if winners != 1 {
+		t.Errorf("expected exactly one claim to win, got %d", winners)
+	}
+}
+
+func TestStore_escalations(t *testing.T) {
+	s := openTest(t)
+	ctx := context.Background()
+	base := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i, q := range []string{"review", "fraud", "review"} {
+		err := s.Enqueue(ctx, &engine.Escalation{
+			ID: "esc_" + string(rune('a'+i)), InvocationID: "inv", Operation: "Pay", Queue: q,
+			Rule: "r", Input: map[string]any{"amount": 10.0}, Status: engine.EscalationPending,
+			CreatedAt: base.Add(time.Duration(i) * time.Minute),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := s.Escalations(ctx, "review", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].ID != "esc_a" || got[1].ID != "esc_c" {
+		t.Errorf("expected esc_a, esc_c in order, got %+v", got)
+	}
+	esc, err := s.Escalation(ctx, "esc_b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if esc.Queue != "fraud" || esc.Input["amount"] != 10.0 || !esc.CreatedAt.Equal(base.Add(time.Minute)) {
+		t.Errorf("unexpected escalation %+v", esc)
+	}
+	if _, err := s.Escalation(ctx, "esc_z"); !errors.Is(err, engine.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestStore_escalationTransition(t *testing.T) {
+	s := openTest(t)
+	ctx := context.Background()
+	created := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	err := s.Enqueue(ctx, &engine.Escalation{
+		ID: "esc_a", InvocationID: "inv", Operation: "Pay", Queue: "review", Rule: "r",
+		Input: map[string]any{}, Status: engine.EscalationPending, CreatedAt: created,
+		DueAt: created.Add(time.Hour), ExpiresAt: created.Add(2 * time.Hour), OnExpiry: engine.DecisionDeny,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	esc, err := s.Escalation(ctx, "esc_a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !esc.DueAt.Equal(created.Add(time.Hour)) || !esc.ExpiresAt.Equal(created.Add(2*time.Hour)) || esc.OnExpiry != engine.DecisionDeny {
+		t.Errorf("expected the SLA deadlines stored, got %+v", esc)
+	}
+
+	esc.Status, esc.ResolvedAt, esc.ResolvedBy = engine.EscalationExecuted, created.Add(time.Minute), "alice"
+	esc.Output = map[string]any{"invoice_id": "inv_1"}
+	if err := s.Transition(ctx, esc, engine.EscalationPending); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Transition(ctx, esc, engine.EscalationPending); !errors.Is(err, engine.ErrStatusChanged) {
+		t.Errorf("expected ErrStatusChanged resolving twice, got %v", err)
+	}
+	if err := s.Transition(ctx, &engine.Escalation{ID: "esc_z"}, engine.EscalationPending); !errors.Is(err, engine.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	got, err := s.Escalation(ctx, "esc_a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != engine.EscalationExecuted || got.ResolvedBy != "alice" || !got.ResolvedAt.Equal(esc.ResolvedAt) || got.Output["invoice_id"] != "inv_1" {
+		t.Errorf("unexpected resolution %+v", got)
+	}
+}
+
+func TestStore_outbox(t *testing.T) {
+	s := openTest(t)
+	ctx := context.Background()
+	s.EnableOutbox(
+		store.OutboxSink{Name: "events"},
+		store.OutboxSink{Name: "notify", Accept: func(rec *engine.AuditRecord) bool { return rec.EscalationID != "" }},
+	)
+	s.Record(ctx, &engine.AuditRecord{InvocationID: "inv_1", Timestamp: time.Now(), Operation: "Pay", Outcome: "executed"})
+	s.Record(ctx, &engine.AuditRecord{InvocationID: "inv_2", Timestamp: time.Now(), Operation: "Pay", Outcome: "escalated", EscalationID: "esc_1"})
+
+	entries, pending, err := s.PendingOutbox(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Sink+" "+e.Record.InvocationID)
+	}
+	if pending != 3 || fmt.Sprint(got) != "[events inv_1 events inv_2 notify inv_2]" {
+		t.Fatalf("expected an entry per accepting sink, got %d: %v", pending, got)
+	}
+
+	now := time.Now()
+	claimed, err := s.ClaimOutbox(ctx, now, time.Minute, 2)
+	if err != nil || len(claimed) != 2 || claimed[0].ID != entries[0].ID || claimed[0].Attempts != 1 {
+		t.Fatalf("expected the oldest two claimed, got %+v, %v", claimed, err)
+	}
+	if again, _ := s.ClaimOutbox(ctx, now, time.Minute, 10); len(again) != 1 || again[0].ID != entries[2].ID {
+		t.Errorf("expected leased entries skipped, got %+v", again)
+	}
+	if err := s.CompleteOutbox(ctx, claimed[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RetryOutbox(ctx, claimed[1].ID, now.Add(time.Second), "HTTP 503"); err != nil {
+		t.Fatal(err)
+	}
+	retried, _ := s.ClaimOutbox(ctx, now.Add(time.Second), time.Minute, 10)
+	if len(retried) != 1 || retried[0].Attempts != 2 || retried[0].LastError != "HTTP 503" {
+		t.Errorf("expected the retry due with its last error, got %+v", retried)
+	}
+	if _, pending, _ := s.PendingOutbox(ctx, 0); pending != 2 {
+		t.Errorf("expected 2 pending, got %d", pending)
+	}
+
+	deadAt := now.Truncate(time.Millisecond).UTC()
+	if err := s.DeadLetter(ctx, retried[0].ID, deadAt, "HTTP 500"); err != nil {
+		t.Fatal(err)
+	}
+	dead, total, err := s.DeadLetters(ctx, 10)
+	if err != nil || total != 1 || dead[0].ID != retried[0].ID || dead[0].Attempts != 2 || dead[0].LastError != "HTTP 500" || !dead[0].DeadLetteredAt.Equal(deadAt) {
+		t.Fatalf("expected the entry dead-lettered, got %+v, %v", dead, err)
+	}
+	if _, pending, _ := s.PendingOutbox(ctx, 0); pending != 1 {
+		t.Errorf("expected the dead letter out of the outbox, got %d pending", pending)
+	}
+	if err := s.RetryDeadLetter(ctx, dead[0].ID, now); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := s.ClaimOutbox(ctx, now.Add(time.Second), time.Minute, 10); len(again) != 1 || again[0].ID != dead[0].ID || again[0].Attempts != 1 {
+		t.Errorf("expected the dead letter back with no attempts, got %+v", again)
+	}
+	if err := s.RetryDeadLetter(ctx, dead[0].ID, now); !errors.Is(err, engine.ErrNotFound) {
+		t.Errorf("expected ErrNotFound retrying a retried dead letter, got %v", err)
+	}
+	s.DeadLetter(ctx, dead[0].ID, now, "HTTP 500")
+	if err := s.DiscardDeadLetter(ctx, dead[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, total, _ := s.DeadLetters(ctx, 0); total != 0 {
+		t.Errorf("expected the dead letter discarded, got %d", total)
+	}
+	if err := s.DiscardDeadLetter(ctx, dead[0].ID); !errors.Is(err, engine.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestStore_concurrentCounterIncrements(t *testing.T) {
+	s := openTest(t)
+	ctx := context.Background()
+	t0 := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.Add(ctx, "cust_1", time.Hour, t0, 1); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n, _ := s.Add(ctx, "cust_1", time.Hour, t0.Add(59*time.Minute), 0); n != 50 {
+		t.Errorf("expected 50, got %d", n)
+	}
+	if n, _ := s.Add(ctx, "cust_1", time.Hour, t0.Add(time.Hour), 1); n != 1 {
+		t.Errorf("expected new window to start at 1, got %d", n)
+	}
+}
+
+func TestStore_sumsCounters(t *testing.T) {
+	s := openTest(t)
+	ctx := context.Background()
+	t0 := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	s.Add(ctx, "cust_1", time.Hour, t0, 1)
+	s.Add(ctx, "cust_1", time.Hour, t0.Add(90*time.Minute), 2)
+	s.Add(ctx, "cust_1", time.Hour, t0.Add(3*time.Hour), 4)
+	if n, err := s.Sum(ctx, "cust_1", time.Hour, t0.Add(30*time.Minute), t0.Add(2*time.Hour)); err != nil || n != 3 {
+		t.Errorf("expected the two windows overlapping the range summed, got %d, %v", n, err)
+	}
+	if n, err := s.Sum(ctx, "cust_2", time.Hour, t0, t0.Add(3*time.Hour)); err != nil || n != 0 {
+		t.Errorf("expected 0 for an uncounted key, got %d, %v", n, err)
+	}
+	var rows int
+	s.pool.QueryRow(ctx, `SELECT COUNT(*) FROM counters`).Scan(&rows)
+	if rows != 3 {
+		t.Errorf("expected Sum to create no counters, got %d rows", rows)
+	}
+}
+
+func TestStore_entityStateTransitions(t *testing.T) {
+	s := openTest(t)
+	ctx := context.Background()
+
+	if _, _, err := s.GetState(ctx, "invoice", "inv_1"); !errors.Is(err, engine.ErrNotFound) {
+		t.Fatalf("expected an untracked instance not found, got %v", err)
+	}
+	if v, err := s.TransitionCAS(ctx, "invoice", "inv_1", 0, "submitted"); err != nil || v != 1 {
+		t.Fatalf("got %d, %v", v, err)
+	}
+	if _, err := s.TransitionCAS(ctx, "invoice", "inv_1", 0, "cancelled"); !errors.Is(err, engine.ErrStateConflict) {
+		t.Errorf("expected a second insert refused, got %v", err)
+	}
+
+	var wg sync.WaitGroup
+	var won atomic.Int32
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.TransitionCAS(ctx, "invoice", "inv_1", 1, "approved")
+			switch {
+			case err == nil:
+				won.Add(1)
+			case !errors.Is(err, engine.ErrStateConflict):
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if won.Load() != 1 {
+		t.Errorf("expected exactly one of the racing transitions to succeed, got %d", won.Load())
+	}
+	if state, v, err := s.GetState(ctx, "invoice", "inv_1"); state != "approved" || v != 2 || err != nil {
+		t.Errorf("got %s@%d, %v", state, v, err)
+	}
+}
+
+func TestStore_pruneRemovesOldHistory(t *testing.T) {
+	s := openTest(t)
+	ctx := context.Background()
+	now := time.Now()
+	s.Record(ctx, &engine.AuditRecord{InvocationID: "old", Timestamp: now.Add(-48 * time.Hour)})
+	s.Record(ctx, &engine.AuditRecord{InvocationID: "new", Timestamp: now})
+
+	n, err := s.Prune(ctx, now.Add(-24*time.Hour))
+	if err != nil || n != 1 {
+		t.Fatalf("expected 1 pruned, got %d, %v", n, err)
+	}
+	if _, err := s.Decision(ctx, "new"); err != nil {
+		t.Errorf("new record pruned: %v", err)
+	}
+}
+
+func TestStore_decisionsAndTrim(t *testing.T) {
+	s := openTest(t)
+	ctx := context.Background()
+	base := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	for i, id := range []string{"a", "b", "c", "d"} {
+		s.Record(ctx, &engine.AuditRecord{InvocationID: id, Timestamp: base.Add(time.Duration(i) * time.Minute)})
+	}
+
+	var got []string
+	s.Decisions(ctx, base.Add(time.Minute), base.Add(3*time.Minute), func(rec *engine.AuditRecord) error {
+		got = append(got, rec.InvocationID)
+		return nil
+	})
+	if len(got) != 2 || got[0] != "b" || got[1] != "c" {
+		t.Errorf("expected b, c, got %v", got)
+	}
+
+	if n, err := s.Trim(ctx, 1); err != nil || n != 3 {
+		t.Fatalf("expected 3 trimmed, got %d, %v", n, err)
+	}
+	if _, err := s.Decision(ctx, "d"); err != nil {
+		t.Errorf("newest record trimmed: %v", err)
+	}
+}
+
+func TestStore_executionHistory(t *testing.T) {
+	s := openTest(t)
+	ctx := context.Background()
+	t0 := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i, ex := range []*engine.Execution{
+		{DecisionID: "inv_a", Operation: "ProcessPayment", Entities: map[string]string{"invoice": "inv_001", "customer": "cust_1"}, Output: map[string]any{"payment_id": "pay_1"}},
+		{DecisionID: "inv_b", Operation: "ProcessPayment", Entities: map[string]string{"invoice": "inv_002"}, Fingerprint: "fp_1"},
+		{DecisionID: "inv_c", Operation: "RefundPayment", Entities: map[string]string{"invoice": "inv_001"}},
+	} {
+		ex.ExecutedAt = t0.Add(time.Duration(i) * time.Minute)
+		if err := s.RecordExecution(ctx, ex); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ex, err := s.Execution(ctx, "inv_a")
+	if err != nil || ex.Output["payment_id"] != "pay_1" || ex.Entities["customer"] != "cust_1" || !ex.ExecutedAt.Equal(t0) {
+		t.Errorf("unexpected execution %+v, %v", ex, err)
+	}
+	if _, err := s.Execution(ctx, "inv_x"); !errors.Is(err, engine.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	for _, tc := range []struct {
+		q    engine.ExecutionQuery
+		want []string
+	}{
+		{engine.ExecutionQuery{Entity: "invoice", ID: "inv_001"}, []string{"inv_c", "inv_a"}},
+		{engine.ExecutionQuery{Entity: "invoice", ID: "inv_001", Operation: "ProcessPayment"}, []string{"inv_a"}},
+		{engine.ExecutionQuery{Operation: "ProcessPayment"}, []string{"inv_b", "inv_a"}},
+		{engine.ExecutionQuery{Since: t0.Add(time.Minute), Limit: 1}, []string{"inv_c"}},
+		{engine.ExecutionQuery{Operation: "ProcessPayment", Fingerprint: "fp_1"}, []string{"inv_b"}},
+		{engine.ExecutionQuery{Entity: "customer", ID: "cust_2"}, []string{}},
+	} {
+		list, err := s.Executions(ctx, tc.q)
+		got := []string{}
+		for _, ex := range list {
+			got = append(got, ex.DecisionID)
+		}
+		if err != nil || fmt.Sprint(got) != fmt.Sprint(tc.want) {
+			t.Errorf("%+v: expected %v, got %v, %v", tc.q, tc.want, got, err)
+		}
+	}
+
+	if _, err := s.Prune(ctx, t0.Add(30*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := s.Executions(ctx, engine.ExecutionQuery{Entity: "invoice", ID: "inv_001"}); len(list) != 1 {
+		t.Errorf("expected the oldest execution pruned, got %d left", len(list))
+	}
+}
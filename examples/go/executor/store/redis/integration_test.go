@@ -0,0 +1,46 @@
+//go:build integration
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"covenant-poc/executor/engine"
+)
+
+// TestStore_againstServer runs the transition script on a real server at
+// COVENANT_REDIS_URL.
+func TestStore_againstServer(t *testing.T) {
+	url := os.Getenv("COVENANT_REDIS_URL")
+	if url == "" {
+		t.Skip("COVENANT_REDIS_URL not set")
+	}
+	ctx := context.Background()
+	s, err := Open(ctx, url)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer s.Close()
+	if _, err := s.do(ctx, "DEL", stateKey("invoice", "inv_1")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := s.GetState(ctx, "invoice", "inv_1"); !errors.Is(err, engine.ErrNotFound) {
+		t.Fatalf("expected an untracked instance not found, got %v", err)
+	}
+	if v, err := s.TransitionCAS(ctx, "invoice", "inv_1", 0, "submitted"); err != nil || v != 1 {
+		t.Fatalf("got %d, %v", v, err)
+	}
+	if _, err := s.TransitionCAS(ctx, "invoice", "inv_1", 0, "cancelled"); !errors.Is(err, engine.ErrStateConflict) {
+		t.Errorf("expected a stale version refused, got %v", err)
+	}
+	if v, err := s.TransitionCAS(ctx, "invoice", "inv_1", 1, "approved"); err != nil || v != 2 {
+		t.Errorf("got %d, %v", v, err)
+	}
+	if state, v, err := s.GetState(ctx, "invoice", "inv_1"); state != "approved" || v != 2 || err != nil {
+		t.Errorf("got %s@%d, %v", state, v, err)
+	}
+}
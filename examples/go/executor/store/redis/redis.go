@@ -0,0 +1,278 @@
+// Package redis keeps entity state in Redis, for executors that share
+// state with other services or want it out of the decision store.
+//
+// Store implements engine.StateStore. Each entity instance is a hash at
+// covenant:state:<entity>:<id> with state and version fields, and
+// transitions are compare-and-swap updates run as one Lua script, so
+// concurrent transitions from one version have exactly one winner across
+// every executor on the server.
+//
+// The package speaks the Redis protocol (RESP2) itself over a small
+// connection pool; it needs Redis 4 or later. Integration tests run
+// against a real server with
+//
+//	COVENANT_REDIS_URL=redis://localhost:6379/15 go test -tags integration ./executor/store/redis
+package redis
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"covenant-poc/executor/engine"
+)
+
+// Connection bounds: idle connections kept for reuse, and how long to wait
+// for a connection when the context sets no deadline.
+const (
+	maxIdleConns = 16
+	dialTimeout  = 5 * time.Second
+)
+
+// keyPrefix namespaces the keys the store writes.
+const keyPrefix = "covenant:state:"
+
+// transitionScript moves an instance to ARGV[2] if it is at version
+// ARGV[1], returning the new version, or -1 if it is not. A missing hash is
+// at version 0.
+const transitionScript = `local v = tonumber(redis.call('HGET', KEYS[1], 'version') or '0')
+if v ~= tonumber(ARGV[1]) then return -1 end
+redis.call('HSET', KEYS[1], 'state', ARGV[2], 'version', v + 1)
+return v + 1`
+
+// Store is a Redis-backed entity state store. It is safe for concurrent
+// use.
+type Store struct {
+	addr     string
+	username string
+	password string
+	db       int
+	idle     chan *conn
+}
+
+// Open connects to the server at a redis://[[user]:password@]host[:port][/db]
+// URL and checks that it answers.
+func Open(ctx context.Context, rawURL string) (*Store, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "redis" {
+		return nil, fmt.Errorf("unsupported scheme %q (want redis://)", u.Scheme)
+	}
+	s := &Store{addr: u.Host, idle: make(chan *conn, maxIdleConns)}
+	if u.Port() == "" {
+		s.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		s.username = u.User.Username()
+		s.password, _ = u.User.Password()
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if s.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("database %q is not a number", db)
+		}
+	}
+	if _, err := s.do(ctx, "PING"); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Close closes the idle connections. Connections in use are closed when
+// they are returned.
+func (s *Store) Close() error {
+	for {
+		select {
+		case c := <-s.idle:
+			c.nc.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// GetState implements engine.StateStore.
+func (s *Store) GetState(ctx context.Context, entity, id string) (string, int64, error) {
+	reply, err := s.do(ctx, "HMGET", stateKey(entity, id), "state", "version")
+	if err != nil {
+		return "", 0, err
+	}
+	fields, ok := reply.([]any)
+	if !ok || len(fields) != 2 {
+		return "", 0, fmt.Errorf("unexpected HMGET reply %v", reply)
+	}
+	state, ok := fields[0].([]byte)
+	if !ok {
+		return "", 0, engine.ErrNotFound
+	}
+	raw, _ := fields[1].([]byte)
+	version, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("version of %s: %w", stateKey(entity, id), err)
+	}
+	return string(state), version, nil
+}
+
+// TransitionCAS implements engine.StateStore.
+func (s *Store) TransitionCAS(ctx context.Context, entity, id string, version int64, to string) (int64, error) {
+	reply, err := s.do(ctx, "EVAL", transitionScript, "1", stateKey(entity, id), strconv.FormatInt(version, 10), to)
+	if err != nil {
+		return 0, err
+	}
+	next, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("unexpected EVAL reply %v", reply)
+	}
+	if next < 0 {
+		return 0, engine.ErrStateConflict
+	}
+	return next, nil
+}
+
+func stateKey(entity, id string) string {
+	return keyPrefix + entity + ":" + id
+}
+
+// serverError is an error reply from the server.
+type serverError string
+
+func (e serverError) Error() string { return "redis: " + string(e) }
+
+// conn is a connection to the server.
+type conn struct {
+	nc net.Conn
+	r  *bufio.Reader
+	w  *bufio.Writer
+}
+
+// do sends a command and returns its reply: a string, int64, []byte, []any
+// or nil. A connection that fails mid-command is discarded; one that
+// returned an error reply is reused.
+func (s *Store) do(ctx context.Context, args ...string) (any, error) {
+	c, err := s.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(dialTimeout)
+	}
+	c.nc.SetDeadline(deadline)
+	reply, err := c.roundTrip(args)
+	var serr serverError
+	if err != nil && !errors.As(err, &serr) {
+		c.nc.Close()
+		return nil, err
+	}
+	s.put(c)
+	return reply, err
+}
+
+// get returns an idle connection, or dials, authenticates and selects the
+// database on a new one.
+func (s *Store) get(ctx context.Context) (*conn, error) {
+	select {
+	case c := <-s.idle:
+		return c, nil
+	default:
+	}
+	d := net.Dialer{Timeout: dialTimeout}
+	nc, err := d.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &conn{nc: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+	nc.SetDeadline(time.Now().Add(dialTimeout))
+	var setup [][]string
+	switch {
+	case s.username != "":
+		setup = append(setup, []string{"AUTH", s.username, s.password})
+	case s.password != "":
+		setup = append(setup, []string{"AUTH", s.password})
+	}
+	if s.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(s.db)})
+	}
+	for _, cmd := range setup {
+		if _, err := c.roundTrip(cmd); err != nil {
+			nc.Close()
+			return nil, fmt.Errorf("%s: %w", cmd[0], err)
+		}
+	}
+	return c, nil
+}
+
+// put keeps c for reuse, or closes it if the pool is full.
+func (s *Store) put(c *conn) {
+	select {
+	case s.idle <- c:
+	default:
+		c.nc.Close()
+	}
+}
+
+func (c *conn) roundTrip(args []string) (any, error) {
+	fmt.Fprintf(c.w, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(c.w, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+	return readReply(c.r)
+}
+
+// readReply reads one RESP2 reply.
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("malformed reply %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, serverError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < 0 {
+			return nil, err // $-1 is a nil bulk string
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = readReply(r); err != nil {
+				var serr serverError
+				if !errors.As(err, &serr) {
+					return nil, err
+				}
+				items[i] = serr
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("malformed reply %q", line)
+}
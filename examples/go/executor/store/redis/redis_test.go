@@ -0,0 +1,178 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"covenant-poc/executor/engine"
+)
+
+// Store must satisfy the engine's state store interface.
+var _ engine.StateStore = (*Store)(nil)
+
+// fakeServer answers the commands the store sends, running the transition
+// script's logic itself. It records the AUTH and SELECT commands it gets.
+type fakeServer struct {
+	ln     net.Listener
+	mu     sync.Mutex
+	hashes map[string]map[string]string
+	setup  []string
+}
+
+func startFake(t *testing.T) *fakeServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeServer{ln: ln, hashes: map[string]map[string]string{}}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(nc)
+		}
+	}()
+	return f
+}
+
+func (f *fakeServer) serve(nc net.Conn) {
+	defer nc.Close()
+	r := bufio.NewReader(nc)
+	for {
+		req, err := readReply(r)
+		if err != nil {
+			return
+		}
+		var args []string
+		for _, a := range req.([]any) {
+			args = append(args, string(a.([]byte)))
+		}
+		fmt.Fprint(nc, f.reply(args))
+	}
+}
+
+func (f *fakeServer) reply(args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch args[0] {
+	case "PING":
+		return "+PONG\r\n"
+	case "AUTH", "SELECT":
+		f.setup = append(f.setup, fmt.Sprint(args))
+		return "+OK\r\n"
+	case "HMGET":
+		out := fmt.Sprintf("*%d\r\n", len(args)-2)
+		for _, field := range args[2:] {
+			if v, ok := f.hashes[args[1]][field]; ok {
+				out += fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+			} else {
+				out += "$-1\r\n"
+			}
+		}
+		return out
+	case "EVAL":
+		if args[1] != transitionScript {
+			return "-ERR unknown script\r\n"
+		}
+		key, want, to := args[3], args[4], args[5]
+		v, _ := strconv.Atoi(f.hashes[key]["version"])
+		if strconv.Itoa(v) != want {
+			return ":-1\r\n"
+		}
+		f.hashes[key] = map[string]string{"state": to, "version": strconv.Itoa(v + 1)}
+		return fmt.Sprintf(":%d\r\n", v+1)
+	}
+	return "-ERR unknown command '" + args[0] + "'\r\n"
+}
+
+func TestStore_entityStateTransitions(t *testing.T) {
+	f := startFake(t)
+	ctx := context.Background()
+	s, err := Open(ctx, "redis://:secret@"+f.ln.Addr().String()+"/3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if _, _, err := s.GetState(ctx, "invoice", "inv_1"); !errors.Is(err, engine.ErrNotFound) {
+		t.Fatalf("expected an untracked instance not found, got %v", err)
+	}
+	if v, err := s.TransitionCAS(ctx, "invoice", "inv_1", 0, "submitted"); err != nil || v != 1 {
+		t.Fatalf("got %d, %v", v, err)
+	}
+	if _, err := s.TransitionCAS(ctx, "invoice", "inv_1", 0, "cancelled"); !errors.Is(err, engine.ErrStateConflict) {
+		t.Errorf("expected a stale version refused, got %v", err)
+	}
+
+	var wg sync.WaitGroup
+	var won atomic.Int32
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.TransitionCAS(ctx, "invoice", "inv_1", 1, "approved")
+			switch {
+			case err == nil:
+				won.Add(1)
+			case !errors.Is(err, engine.ErrStateConflict):
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if won.Load() != 1 {
+		t.Errorf("expected exactly one of the racing transitions to succeed, got %d", won.Load())
+	}
+	if state, v, err := s.GetState(ctx, "invoice", "inv_1"); state != "approved" || v != 2 || err != nil {
+		t.Errorf("got %s@%d, %v", state, v, err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.hashes["covenant:state:invoice:inv_1"]; !ok {
+		t.Errorf("expected the instance under its namespaced key, got %v", f.hashes)
+	}
+	if len(f.setup) == 0 || f.setup[0] != "[AUTH secret]" || f.setup[1] != "[SELECT 3]" {
+		t.Errorf("expected each connection authenticated and on database 3, got %v", f.setup)
+	}
+}
+
+func TestStore_serverErrorsKeepTheConnection(t *testing.T) {
+	f := startFake(t)
+	ctx := context.Background()
+	s, err := Open(ctx, "redis://"+f.ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	_, err = s.do(ctx, "FLUSHALL")
+	var serr serverError
+	if !errors.As(err, &serr) || serr != "ERR unknown command 'FLUSHALL'" {
+		t.Fatalf("expected the server's error, got %v", err)
+	}
+	if len(s.idle) != 1 {
+		t.Errorf("expected the connection kept after an error reply, got %d idle", len(s.idle))
+	}
+	if _, err := s.do(ctx, "PING"); err != nil {
+		t.Errorf("expected the connection usable, got %v", err)
+	}
+}
+
+func TestOpen_rejectsBadURLs(t *testing.T) {
+	for _, u := range []string{"rediss://localhost", "redis://localhost/zero"} {
+		if _, err := Open(context.Background(), u); err == nil {
+			t.Errorf("%s: expected an error", u)
+		}
+	}
+}
@@ -0,0 +1,809 @@
+// Package sqlite is a single-file persistent store for the executor.
+//
+// Store implements engine.DecisionStore (the audit sink plus lookup by
+// invocation ID), engine.IdempotencyStore, engine.EscalationStore,
+// engine.CounterStore, engine.StateStore, engine.ExecutionStore and
+// store.Outbox on one SQLite database using
+// the pure-Go modernc driver, so a single executor binary keeps durable
+// decision history without external services. Prune and Trim bound the history by age and size, and
+// Decisions scans it by time for exports.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"covenant-poc/executor/engine"
+	"covenant-poc/executor/store"
+)
+
+// schema is applied on Open; every statement is idempotent.
+const schema = `
+CREATE TABLE IF NOT EXISTS audit (
+	invocation_id    TEXT PRIMARY KEY,
+	ts               INTEGER NOT NULL, -- unix nanoseconds
+	operation        TEXT NOT NULL,
+	outcome          TEXT NOT NULL,
+	dry_run          INTEGER NOT NULL,
+	contract_version TEXT NOT NULL,
+	record           TEXT NOT NULL     -- engine.AuditRecord as JSON
+);
+CREATE INDEX IF NOT EXISTS audit_ts ON audit (ts);
+
+CREATE TABLE IF NOT EXISTS idempotency (
+	key         TEXT PRIMARY KEY,
+	fingerprint TEXT NOT NULL,
+	response    TEXT,                  -- NULL while in flight
+	created_at  INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idempotency_created_at ON idempotency (created_at);
+
+CREATE TABLE IF NOT EXISTS escalations (
+	id            TEXT PRIMARY KEY,
+	invocation_id TEXT NOT NULL,
+	operation     TEXT NOT NULL,
+	queue         TEXT NOT NULL,
+	rule          TEXT NOT NULL,
+	reason        TEXT NOT NULL,
+	input         TEXT NOT NULL,
+	status        TEXT NOT NULL,
+	created_at    INTEGER NOT NULL,
+	due_at        INTEGER NOT NULL DEFAULT 0, -- unix nanoseconds, 0 if none
+	expires_at    INTEGER NOT NULL DEFAULT 0,
+	on_expiry     TEXT NOT NULL DEFAULT '',
+	resolved_at   INTEGER NOT NULL DEFAULT 0,
+	resolved_by   TEXT NOT NULL DEFAULT '',
+	output        TEXT,                       -- JSON, NULL if none
+	error         TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS escalations_queue ON escalations (queue, status, created_at);
+
+CREATE TABLE IF NOT EXISTS counters (
+	key          TEXT NOT NULL,
+	window_start INTEGER NOT NULL,     -- unix nanoseconds
+	value        INTEGER NOT NULL,
+	PRIMARY KEY (key, window_start)
+);
+
+CREATE TABLE IF NOT EXISTS outbox (
+	id              INTEGER PRIMARY KEY AUTOINCREMENT,
+	sink            TEXT NOT NULL,
+	invocation_id   TEXT NOT NULL,
+	record          TEXT NOT NULL,     -- engine.AuditRecord as JSON
+	created_at      INTEGER NOT NULL,  -- unix nanoseconds
+	attempts        INTEGER NOT NULL DEFAULT 0,
+	next_attempt_at INTEGER NOT NULL,
+	last_error      TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS outbox_next_attempt_at ON outbox (next_attempt_at, id);
+
+CREATE TABLE IF NOT EXISTS outbox_dead_letters (
+	id               INTEGER PRIMARY KEY, -- the outbox entry's
+	sink             TEXT NOT NULL,
+	invocation_id    TEXT NOT NULL,
+	record           TEXT NOT NULL,
+	created_at       INTEGER NOT NULL,
+	attempts         INTEGER NOT NULL,
+	last_error       TEXT NOT NULL,
+	dead_lettered_at INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS executions (
+	decision_id TEXT PRIMARY KEY,
+	operation   TEXT NOT NULL,
+	executed_at INTEGER NOT NULL,      -- unix nanoseconds
+	execution   TEXT NOT NULL,         -- engine.Execution as JSON
+	fingerprint TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS executions_executed_at ON executions (executed_at);
+
+CREATE TABLE IF NOT EXISTS execution_entities (
+	entity      TEXT NOT NULL,
+	id          TEXT NOT NULL,
+	decision_id TEXT NOT NULL,
+	executed_at INTEGER NOT NULL,
+	PRIMARY KEY (entity, id, decision_id)
+);
+CREATE INDEX IF NOT EXISTS execution_entities_executed_at ON execution_entities (entity, id, executed_at);
+
+CREATE TABLE IF NOT EXISTS entity_states (
+	entity     TEXT NOT NULL,
+	id         TEXT NOT NULL,
+	state      TEXT NOT NULL,
+	version    INTEGER NOT NULL,
+	updated_at INTEGER NOT NULL,       -- unix nanoseconds
+	PRIMARY KEY (entity, id)
+);
+`
+
+// columns are added to tables created by earlier versions of the schema.
+// Open adds each one the table lacks.
+var columns = []struct{ table, name, def string }{
+	{"escalations", "due_at", "INTEGER NOT NULL DEFAULT 0"},
+	{"escalations", "expires_at", "INTEGER NOT NULL DEFAULT 0"},
+	{"escalations", "on_expiry", "TEXT NOT NULL DEFAULT ''"},
+	{"escalations", "resolved_at", "INTEGER NOT NULL DEFAULT 0"},
+	{"escalations", "resolved_by", "TEXT NOT NULL DEFAULT ''"},
+	{"escalations", "output", "TEXT"},
+	{"escalations", "error", "TEXT NOT NULL DEFAULT ''"},
+	{"executions", "fingerprint", "TEXT NOT NULL DEFAULT ''"},
+}
+
+// indexes cover added columns, so they are created after addColumns.
+const indexes = `
+CREATE INDEX IF NOT EXISTS executions_fingerprint ON executions (operation, fingerprint, executed_at);
+`
+
+// Store is a SQLite-backed executor store. It is safe for concurrent use.
+type Store struct {
+	db     *sql.DB
+	outbox []store.OutboxSink
+}
+
+// Open opens (creating if needed) the database at path and applies the schema.
+func Open(path string) (*Store, error) {
+	// WAL lets lookups proceed while the audit sink writes; the busy timeout
+	// covers the brief write lock.
+	dsn := "file:" + path + "?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_pragma=synchronous(NORMAL)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows one writer; a single connection serialises writes in
+	// the pool rather than in busy retries.
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("apply schema: %w", err)
+	}
+	if err := addColumns(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("apply schema: %w", err)
+	}
+	if _, err := db.Exec(indexes); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("apply schema: %w", err)
+	}
+	return &Store{db: db}, nil
+}
+
+// addColumns adds the columns a table created by an earlier schema lacks.
+func addColumns(db *sql.DB) error {
+	for _, col := range columns {
+		var n int
+		err := db.QueryRow(`SELECT count(*) FROM pragma_table_info(?) WHERE name = ?`, col.table, col.name).Scan(&n)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			continue
+		}
+		if _, err := db.Exec(`ALTER TABLE ` + col.table + ` ADD COLUMN ` + col.name + ` ` + col.def); err != nil {
+			return fmt.Errorf("add %s.%s: %w", col.table, col.name, err)
+		}
+	}
+	return nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Record implements engine.AuditSink. Records are written synchronously so
+// they are durable once the response is sent; write errors are logged.
+// With the outbox enabled, a record's outbox entries are written in the
+// same transaction.
+func (s *Store) Record(ctx context.Context, rec *engine.AuditRecord) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		log.Printf("sqlite audit: %v", err)
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("sqlite audit: %s: %v", rec.InvocationID, err)
+		return
+	}
+	defer tx.Rollback()
+	_, err = tx.ExecContext(ctx,
+		`INSERT OR REPLACE INTO audit (invocation_id, ts, operation, outcome, dry_run, contract_version, record)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		rec.InvocationID, rec.Timestamp.UnixNano(), rec.Operation, rec.Outcome, rec.DryRun, rec.ContractVersion, data)
+	for _, sink := range s.outbox {
+		if err != nil || !sink.Accepts(rec) {
+			continue
+		}
+		now := time.Now().UnixNano()
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO outbox (sink, invocation_id, record, created_at, next_attempt_at) VALUES (?, ?, ?, ?, ?)`,
+			sink.Name, rec.InvocationID, data, now, now)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Printf("sqlite audit: %s: %v", rec.InvocationID, err)
+	}
+}
+
+// EnableOutbox implements store.Outbox.
+func (s *Store) EnableOutbox(sinks ...store.OutboxSink) {
+	s.outbox = sinks
+}
+
+// ClaimOutbox implements store.Outbox.
+func (s *Store) ClaimOutbox(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*store.OutboxEntry, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	rows, err := tx.QueryContext(ctx,
+		`UPDATE outbox SET attempts = attempts + 1, next_attempt_at = ?
+		 WHERE id IN (SELECT id FROM outbox WHERE next_attempt_at <= ? ORDER BY id LIMIT ?)
+		 RETURNING `+outboxColumns,
+		now.Add(lease).UnixNano(), now.UnixNano(), limit)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := scanOutbox(rows)
+	if err != nil {
+		return nil, err
+	}
+	// RETURNING does not keep the subquery's order.
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	return entries, tx.Commit()
+}
+
+// CompleteOutbox implements store.Outbox.
+func (s *Store) CompleteOutbox(ctx context.Context, id int64) error {
+	_, err := s.db.ExecContext(context.WithoutCancel(ctx), `DELETE FROM outbox WHERE id = ?`, id)
+	return err
+}
+
+// RetryOutbox implements store.Outbox.
+func (s *Store) RetryOutbox(ctx context.Context, id int64, at time.Time, lastErr string) error {
+	_, err := s.db.ExecContext(context.WithoutCancel(ctx),
+		`UPDATE outbox SET next_attempt_at = ?, last_error = ? WHERE id = ?`, at.UnixNano(), lastErr, id)
+	return err
+}
+
+// PendingOutbox implements store.Outbox.
+func (s *Store) PendingOutbox(ctx context.Context, limit int) ([]*store.OutboxEntry, int64, error) {
+	var total int64
+	if err := s.db.QueryRowContext(ctx, `SELECT count(*) FROM outbox`).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT `+outboxColumns+` FROM outbox ORDER BY id LIMIT ?`, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	entries, err := scanOutbox(rows)
+	return entries, total, err
+}
+
+// DeadLetter implements store.Outbox.
+func (s *Store) DeadLetter(ctx context.Context, id int64, at time.Time, lastErr string) error {
+	ctx = context.WithoutCancel(ctx)
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO outbox_dead_letters (id, sink, invocation_id, record, created_at, attempts, last_error, dead_lettered_at)
+		 SELECT id, sink, invocation_id, record, created_at, attempts, ?, ? FROM outbox WHERE id = ?`,
+		lastErr, at.UnixNano(), id)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM outbox WHERE id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeadLetters implements store.Outbox.
+func (s *Store) DeadLetters(ctx context.Context, limit int) ([]*store.OutboxEntry, int64, error) {
+	var total int64
+	if err := s.db.QueryRowContext(ctx, `SELECT count(*) FROM outbox_dead_letters`).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT `+deadLetterColumns+` FROM outbox_dead_letters ORDER BY id LIMIT ?`, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	entries, err := scanOutbox(rows)
+	return entries, total, err
+}
+
+// RetryDeadLetter implements store.Outbox.
+func (s *Store) RetryDeadLetter(ctx context.Context, id int64, at time.Time) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO outbox (id, sink, invocation_id, record, created_at, next_attempt_at, last_error)
+		 SELECT id, sink, invocation_id, record, created_at, ?, last_error FROM outbox_dead_letters WHERE id = ?`,
+		at.UnixNano(), id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return engine.ErrNotFound
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM outbox_dead_letters WHERE id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DiscardDeadLetter implements store.Outbox.
+func (s *Store) DiscardDeadLetter(ctx context.Context, id int64) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM outbox_dead_letters WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return engine.ErrNotFound
+	}
+	return nil
+}
+
+const (
+	outboxColumns     = `id, sink, record, created_at, attempts, next_attempt_at, last_error, 0`
+	deadLetterColumns = `id, sink, record, created_at, attempts, 0, last_error, dead_lettered_at`
+)
+
+func scanOutbox(rows *sql.Rows) ([]*store.OutboxEntry, error) {
+	defer rows.Close()
+	entries := []*store.OutboxEntry{}
+	for rows.Next() {
+		var e store.OutboxEntry
+		var data []byte
+		var created, next, dead int64
+		if err := rows.Scan(&e.ID, &e.Sink, &data, &created, &e.Attempts, &next, &e.LastError, &dead); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &e.Record); err != nil {
+			return nil, err
+		}
+		e.CreatedAt, e.NextAttemptAt, e.DeadLetteredAt = fromUnixNanos(created), fromUnixNanos(next), fromUnixNanos(dead)
+		entries = append(entries, &e)
+	}
+	return entries, rows.Err()
+}
+
+// Decision implements engine.DecisionStore.
+func (s *Store) Decision(ctx context.Context, invocationID string) (*engine.AuditRecord, error) {
+	var data []byte
+	err := s.db.QueryRowContext(ctx, `SELECT record FROM audit WHERE invocation_id = ?`, invocationID).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, engine.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rec engine.AuditRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// exportPage is how many audit records Decisions reads per query.
+const exportPage = 500
+
+// Decisions calls fn for each audit record with from <= Timestamp < to,
+// oldest first. It reads in pages and holds no query open while fn runs, so
+// a slow export does not block the single connection new decisions are
+// written on.
+func (s *Store) Decisions(ctx context.Context, from, to time.Time, fn func(*engine.AuditRecord) error) error {
+	afterTS, afterID := from.UnixNano(), ""
+	for {
+		page, err := s.decisionPage(ctx, afterTS, afterID, to.UnixNano())
+		if err != nil {
+			return err
+		}
+		for _, rec := range page {
+			if err := fn(rec); err != nil {
+				return err
+			}
+		}
+		if len(page) < exportPage {
+			return nil
+		}
+		last := page[len(page)-1]
+		afterTS, afterID = last.Timestamp.UnixNano(), last.InvocationID
+	}
+}
+
+// decisionPage reads the page of records after (ts, id) and before to.
+func (s *Store) decisionPage(ctx context.Context, ts int64, id string, to int64) ([]*engine.AuditRecord, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT record FROM audit
+		 WHERE (ts > ? OR (ts = ? AND invocation_id > ?)) AND ts < ?
+		 ORDER BY ts, invocation_id LIMIT ?`,
+		ts, ts, id, to, exportPage)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var page []*engine.AuditRecord
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var rec engine.AuditRecord
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return nil, err
+		}
+		page = append(page, &rec)
+	}
+	return page, rows.Err()
+}
+
+// Claim implements engine.IdempotencyStore.
+func (s *Store) Claim(ctx context.Context, key, fingerprint string, at time.Time) (*engine.IdempotencyRecord, error) {
+	res, err := s.db.ExecContext(ctx,
+		`INSERT OR IGNORE INTO idempotency (key, fingerprint, created_at) VALUES (?, ?, ?)`,
+		key, fingerprint, at.UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 1 {
+		return nil, nil
+	}
+
+	var (
+		ir       = engine.IdempotencyRecord{Key: key}
+		response sql.NullString
+		created  int64
+	)
+	err = s.db.QueryRowContext(ctx,
+		`SELECT fingerprint, response, created_at FROM idempotency WHERE key = ?`, key,
+	).Scan(&ir.Fingerprint, &response, &created)
+	if err != nil {
+		return nil, err
+	}
+	ir.CreatedAt = time.Unix(0, created).UTC()
+	if response.Valid {
+		if err := json.Unmarshal([]byte(response.String), &ir.Response); err != nil {
+			return nil, err
+		}
+	}
+	return &ir, nil
+}
+
+// Complete implements engine.IdempotencyStore.
+func (s *Store) Complete(ctx context.Context, key string, resp *engine.Response) error {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(context.WithoutCancel(ctx), `UPDATE idempotency SET response = ? WHERE key = ?`, data, key)
+	return err
+}
+
+// Release implements engine.IdempotencyStore.
+func (s *Store) Release(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(context.WithoutCancel(ctx), `DELETE FROM idempotency WHERE key = ? AND response IS NULL`, key)
+	return err
+}
+
+// Enqueue implements engine.EscalationStore.
+func (s *Store) Enqueue(ctx context.Context, esc *engine.Escalation) error {
+	input, err := json.Marshal(esc.Input)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx,
+		`INSERT INTO escalations (id, invocation_id, operation, queue, rule, reason, input, status, created_at, due_at, expires_at, on_expiry)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		esc.ID, esc.InvocationID, esc.Operation, esc.Queue, esc.Rule, esc.Reason, input, esc.Status, esc.CreatedAt.UnixNano(),
+		unixNanos(esc.DueAt), unixNanos(esc.ExpiresAt), esc.OnExpiry)
+	return err
+}
+
+// Transition implements engine.EscalationStore.
+func (s *Store) Transition(ctx context.Context, esc *engine.Escalation, from string) error {
+	var output []byte
+	if esc.Output != nil {
+		var err error
+		if output, err = json.Marshal(esc.Output); err != nil {
+			return err
+		}
+	}
+	res, err := s.db.ExecContext(context.WithoutCancel(ctx),
+		`UPDATE escalations SET status = ?, resolved_at = ?, resolved_by = ?, output = ?, error = ?
+		 WHERE id = ? AND status = ?`,
+		esc.Status, unixNanos(esc.ResolvedAt), esc.ResolvedBy, output, esc.Error, esc.ID, from)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		if _, err := s.Escalation(ctx, esc.ID); err != nil {
+			return err
+		}
+		return engine.ErrStatusChanged
+	}
+	return nil
+}
+
+const escalationColumns = `id, invocation_id, operation, queue, rule, reason, input, status, created_at,
+	due_at, expires_at, on_expiry, resolved_at, resolved_by, output, error`
+
+// Escalation implements engine.EscalationStore.
+func (s *Store) Escalation(ctx context.Context, id string) (*engine.Escalation, error) {
+	esc, err := scanEscalation(s.db.QueryRowContext(ctx, `SELECT `+escalationColumns+` FROM escalations WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, engine.ErrNotFound
+	}
+	return esc, err
+}
+
+// Escalations implements engine.EscalationStore.
+func (s *Store) Escalations(ctx context.Context, queue, status string) ([]*engine.Escalation, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT `+escalationColumns+` FROM escalations
+		 WHERE (? = '' OR queue = ?) AND (? = '' OR status = ?)
+		 ORDER BY created_at, id`,
+		queue, queue, status, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []*engine.Escalation
+	for rows.Next() {
+		esc, err := scanEscalation(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, esc)
+	}
+	return out, rows.Err()
+}
+
+func scanEscalation(row interface{ Scan(...any) error }) (*engine.Escalation, error) {
+	var (
+		esc                               engine.Escalation
+		input, output                     []byte
+		created, due, expires, resolvedAt int64
+	)
+	err := row.Scan(&esc.ID, &esc.InvocationID, &esc.Operation, &esc.Queue, &esc.Rule, &esc.Reason, &input, &esc.Status, &created,
+		&due, &expires, &esc.OnExpiry, &resolvedAt, &esc.ResolvedBy, &output, &esc.Error)
+	if err != nil {
+		return nil, err
+	}
+	esc.CreatedAt = time.Unix(0, created).UTC()
+	esc.DueAt, esc.ExpiresAt, esc.ResolvedAt = fromUnixNanos(due), fromUnixNanos(expires), fromUnixNanos(resolvedAt)
+	if err := json.Unmarshal(input, &esc.Input); err != nil {
+		return nil, err
+	}
+	if output != nil {
+		if err := json.Unmarshal(output, &esc.Output); err != nil {
+			return nil, err
+		}
+	}
+	return &esc, nil
+}
+
+// unixNanos stores an optional time: the zero time is 0.
+func unixNanos(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func fromUnixNanos(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n).UTC()
+}
+
+// Add implements engine.CounterStore.
+func (s *Store) Add(ctx context.Context, key string, window time.Duration, at time.Time, delta int64) (int64, error) {
+	var total int64
+	err := s.db.QueryRowContext(ctx,
+		`INSERT INTO counters (key, window_start, value) VALUES (?, ?, ?)
+		 ON CONFLICT (key, window_start) DO UPDATE SET value = value + excluded.value
+		 RETURNING value`,
+		key, at.Truncate(window).UnixNano(), delta,
+	).Scan(&total)
+	return total, err
+}
+
+// Sum implements engine.CounterStore.
+func (s *Store) Sum(ctx context.Context, key string, window time.Duration, from, to time.Time) (int64, error) {
+	var total int64
+	err := s.db.QueryRowContext(ctx,
+		`SELECT COALESCE(SUM(value), 0) FROM counters WHERE key = ? AND window_start BETWEEN ? AND ?`,
+		key, from.Truncate(window).UnixNano(), to.Truncate(window).UnixNano(),
+	).Scan(&total)
+	return total, err
+}
+
+// GetState implements engine.StateStore.
+func (s *Store) GetState(ctx context.Context, entity, id string) (string, int64, error) {
+	var state string
+	var version int64
+	err := s.db.QueryRowContext(ctx, `SELECT state, version FROM entity_states WHERE entity = ? AND id = ?`, entity, id).Scan(&state, &version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", 0, engine.ErrNotFound
+	}
+	return state, version, err
+}
+
+// TransitionCAS implements engine.StateStore. The first transition of an
+// instance inserts it; later ones update it only at the expected version.
+func (s *Store) TransitionCAS(ctx context.Context, entity, id string, version int64, to string) (int64, error) {
+	now := time.Now().UnixNano()
+	var res sql.Result
+	var err error
+	if version == 0 {
+		res, err = s.db.ExecContext(ctx,
+			`INSERT INTO entity_states (entity, id, state, version, updated_at) VALUES (?, ?, ?, 1, ?)
+			 ON CONFLICT (entity, id) DO NOTHING`,
+			entity, id, to, now)
+	} else {
+		res, err = s.db.ExecContext(ctx,
+			`UPDATE entity_states SET state = ?, version = version + 1, updated_at = ?
+			 WHERE entity = ? AND id = ? AND version = ?`,
+			to, now, entity, id, version)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return 0, engine.ErrStateConflict
+	}
+	return version + 1, nil
+}
+
+// RecordExecution implements engine.ExecutionStore. Write errors are also
+// logged, since the engine carries on without them.
+func (s *Store) RecordExecution(ctx context.Context, ex *engine.Execution) error {
+	err := s.recordExecution(ctx, ex)
+	if err != nil {
+		log.Printf("sqlite executions: %s: %v", ex.DecisionID, err)
+	}
+	return err
+}
+
+func (s *Store) recordExecution(ctx context.Context, ex *engine.Execution) error {
+	data, err := json.Marshal(ex)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	at := ex.ExecutedAt.UnixNano()
+	_, err = tx.ExecContext(ctx,
+		`INSERT OR REPLACE INTO executions (decision_id, operation, executed_at, execution, fingerprint) VALUES (?, ?, ?, ?, ?)`,
+		ex.DecisionID, ex.Operation, at, data, ex.Fingerprint)
+	for entity, id := range ex.Entities {
+		if err != nil {
+			break
+		}
+		_, err = tx.ExecContext(ctx,
+			`INSERT OR REPLACE INTO execution_entities (entity, id, decision_id, executed_at) VALUES (?, ?, ?, ?)`,
+			entity, id, ex.DecisionID, at)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Execution implements engine.ExecutionStore.
+func (s *Store) Execution(ctx context.Context, decisionID string) (*engine.Execution, error) {
+	var data []byte
+	err := s.db.QueryRowContext(ctx, `SELECT execution FROM executions WHERE decision_id = ?`, decisionID).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, engine.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var ex engine.Execution
+	if err := json.Unmarshal(data, &ex); err != nil {
+		return nil, err
+	}
+	return &ex, nil
+}
+
+// Executions implements engine.ExecutionStore.
+func (s *Store) Executions(ctx context.Context, q engine.ExecutionQuery) ([]*engine.Execution, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = engine.DefaultHistoryLimit
+	}
+	query := `SELECT e.execution FROM executions e WHERE e.executed_at >= ? AND (? = '' OR e.operation = ?)
+		AND (? = '' OR e.fingerprint = ?)
+		ORDER BY e.executed_at DESC, e.decision_id DESC LIMIT ?`
+	args := []any{q.Since.UnixNano(), q.Operation, q.Operation, q.Fingerprint, q.Fingerprint, limit}
+	if q.Entity != "" {
+		query = `SELECT e.execution FROM execution_entities x JOIN executions e ON e.decision_id = x.decision_id
+			WHERE x.entity = ? AND x.id = ? AND x.executed_at >= ? AND (? = '' OR e.operation = ?)
+			AND (? = '' OR e.fingerprint = ?)
+			ORDER BY x.executed_at DESC, x.decision_id DESC LIMIT ?`
+		args = append([]any{q.Entity, q.ID}, args...)
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	executions := []*engine.Execution{}
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var ex engine.Execution
+		if err := json.Unmarshal(data, &ex); err != nil {
+			return nil, err
+		}
+		executions = append(executions, &ex)
+	}
+	return executions, rows.Err()
+}
+
+// Prune deletes audit records, idempotency keys, counter windows and
+// executions from before cutoff and reports how many audit records were
+// removed.
+// Escalations and outbox entries are kept: they are work items, not
+// history.
+func (s *Store) Prune(ctx context.Context, cutoff time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM audit WHERE ts < ?`, cutoff.UnixNano())
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM idempotency WHERE created_at < ? AND response IS NOT NULL`, cutoff.UnixNano()); err != nil {
+		return n, err
+	}
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM counters WHERE window_start < ?`, cutoff.UnixNano()); err != nil {
+		return n, err
+	}
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM execution_entities WHERE executed_at < ?`, cutoff.UnixNano()); err != nil {
+		return n, err
+	}
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM executions WHERE executed_at < ?`, cutoff.UnixNano()); err != nil {
+		return n, err
+	}
+	return n, nil
+}
+
+// Trim deletes the oldest audit records beyond the newest keep and reports
+// how many were removed.
+func (s *Store) Trim(ctx context.Context, keep int64) (int64, error) {
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM audit WHERE invocation_id IN (
+			SELECT invocation_id FROM audit ORDER BY ts DESC, invocation_id DESC LIMIT -1 OFFSET ?
+		 )`, keep)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
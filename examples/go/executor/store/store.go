@@ -0,0 +1,72 @@
+// Package store holds what the executor's storage backends share: retention
+// policy, decision export and the outbox of side effects awaiting delivery.
+// The backends themselves live in the sqlite and postgres subpackages.
+package store
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Pruner is a store whose decision history can be bounded.
+type Pruner interface {
+	// Prune deletes history from before cutoff and reports how many audit
+	// records were removed.
+	Prune(ctx context.Context, cutoff time.Time) (int64, error)
+	// Trim deletes the oldest audit records beyond the newest keep and
+	// reports how many were removed.
+	Trim(ctx context.Context, keep int64) (int64, error)
+}
+
+// Retention bounds the decision history a store keeps. A zero field leaves
+// that dimension unbounded.
+type Retention struct {
+	MaxAge     time.Duration
+	MaxRecords int64
+}
+
+// Apply prunes s to r as of now and reports how many audit records were
+// removed. Age is applied before size.
+func (r Retention) Apply(ctx context.Context, s Pruner, now time.Time) (int64, error) {
+	var total int64
+	if r.MaxAge > 0 {
+		n, err := s.Prune(ctx, now.Add(-r.MaxAge))
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	if r.MaxRecords > 0 {
+		n, err := s.Trim(ctx, r.MaxRecords)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// Enforce applies r to s immediately and then every interval until ctx is
+// done. Failures are logged and retried on the next tick.
+func (r Retention) Enforce(ctx context.Context, s Pruner, interval time.Duration) {
+	if r.MaxAge <= 0 && r.MaxRecords <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		n, err := r.Apply(ctx, s, time.Now())
+		switch {
+		case err != nil:
+			log.Printf("Retention: %v", err)
+		case n > 0:
+			log.Printf("Retention: pruned %d decisions", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
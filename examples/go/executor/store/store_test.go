@@ -0,0 +1,57 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakePruner records the calls Retention makes.
+type fakePruner struct {
+	cutoff  time.Time
+	keep    int64
+	pruned  int64
+	trimmed int64
+	err     error
+}
+
+func (f *fakePruner) Prune(_ context.Context, cutoff time.Time) (int64, error) {
+	f.cutoff = cutoff
+	return f.pruned, f.err
+}
+
+func (f *fakePruner) Trim(_ context.Context, keep int64) (int64, error) {
+	f.keep = keep
+	return f.trimmed, nil
+}
+
+func TestRetention_Apply_ageThenSize(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	f := &fakePruner{pruned: 3, trimmed: 2}
+	n, err := Retention{MaxAge: time.Hour, MaxRecords: 100}.Apply(context.Background(), f, now)
+	if err != nil || n != 5 {
+		t.Fatalf("expected 5 removed, got %d, %v", n, err)
+	}
+	if !f.cutoff.Equal(now.Add(-time.Hour)) || f.keep != 100 {
+		t.Errorf("unexpected cutoff %s, keep %d", f.cutoff, f.keep)
+	}
+}
+
+func TestRetention_Apply_zeroFieldsAreUnbounded(t *testing.T) {
+	f := &fakePruner{}
+	Retention{}.Apply(context.Background(), f, time.Now())
+	if !f.cutoff.IsZero() || f.keep != 0 {
+		t.Errorf("expected no pruning, got cutoff %s, keep %d", f.cutoff, f.keep)
+	}
+}
+
+func TestRetention_Apply_pruneErrorSkipsTrim(t *testing.T) {
+	f := &fakePruner{err: errors.New("locked")}
+	if _, err := (Retention{MaxAge: time.Hour, MaxRecords: 1}).Apply(context.Background(), f, time.Now()); err == nil {
+		t.Fatal("expected error")
+	}
+	if f.keep != 0 {
+		t.Error("trim ran after a failed prune")
+	}
+}
@@ -0,0 +1,168 @@
+// Package stream writes long-running results to an HTTP client as they
+// complete, one event at a time, instead of buffering a whole response.
+//
+// Events are sent as NDJSON, one {"type": ..., "data": ...} object per
+// line, or as Server-Sent Events, with the type as the event name and the
+// data as JSON. While no event is ready, a heartbeat keeps proxies and
+// clients from timing the connection out: a {"type": "heartbeat"} line, or
+// an SSE comment.
+package stream
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Formats.
+const (
+	FormatNDJSON = "ndjson"
+	FormatSSE    = "sse"
+)
+
+// Negotiate returns the format a request asks for, by its ?stream query
+// parameter or its Accept header, and false if it asks for none and should
+// get a buffered response.
+func Negotiate(r *http.Request) (string, bool) {
+	switch f := r.URL.Query().Get("stream"); f {
+	case FormatNDJSON, FormatSSE:
+		return f, true
+	}
+	accept := r.Header.Get("Accept")
+	switch {
+	case strings.Contains(accept, "application/x-ndjson"):
+		return FormatNDJSON, true
+	case strings.Contains(accept, "text/event-stream"):
+		return FormatSSE, true
+	}
+	return "", false
+}
+
+// Writer sends events to one client. Its methods may be called from any
+// goroutine.
+type Writer struct {
+	w      http.ResponseWriter
+	format string
+
+	mu      sync.Mutex
+	started bool
+	last    time.Time
+	err     error
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewWriter returns a writer of events in format to w, sending a heartbeat
+// whenever no event has been sent for the heartbeat interval; zero sends
+// none. The response headers are sent with the first event or heartbeat,
+// so until then the caller may still answer with Error.
+func NewWriter(w http.ResponseWriter, format string, heartbeat time.Duration) *Writer {
+	sw := &Writer{w: w, format: format, last: time.Now(), stop: make(chan struct{}), done: make(chan struct{})}
+	if heartbeat <= 0 {
+		close(sw.done)
+		return sw
+	}
+	go func() {
+		defer close(sw.done)
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-sw.stop:
+				return
+			case now := <-ticker.C:
+				sw.mu.Lock()
+				if now.Sub(sw.last) >= heartbeat {
+					sw.heartbeat()
+				}
+				sw.mu.Unlock()
+			}
+		}
+	}()
+	return sw
+}
+
+// Error answers with an ordinary HTTP error instead of a stream, and
+// reports whether it could: once anything has been sent it is too late,
+// and the caller should send an error event instead.
+func (sw *Writer) Error(msg string, status int) bool {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if sw.started {
+		return false
+	}
+	sw.started = true
+	sw.err = errors.New("stream: answered with an error")
+	http.Error(sw.w, msg, status)
+	return true
+}
+
+// Send sends an event of the given type with data, and flushes it to the
+// client. Once a write fails, as when the client has gone, every later Send
+// returns the same error.
+func (sw *Writer) Send(typ string, data any) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if sw.format == FormatSSE {
+		return sw.write("event: %s\ndata: %s\n\n", typ, body)
+	}
+	return sw.write(`{"type":%q,"data":%s}`+"\n", typ, body)
+}
+
+// Close stops the heartbeats. It does not end the response, which ends when
+// the handler returns.
+func (sw *Writer) Close() {
+	select {
+	case <-sw.stop:
+	default:
+		close(sw.stop)
+	}
+	<-sw.done
+}
+
+func (sw *Writer) heartbeat() {
+	if sw.format == FormatSSE {
+		sw.write(": heartbeat\n\n")
+		return
+	}
+	sw.write(`{"type":"heartbeat"}` + "\n")
+}
+
+// write sends one event with sw.mu held.
+func (sw *Writer) write(format string, args ...any) error {
+	if sw.err != nil {
+		return sw.err
+	}
+	if !sw.started {
+		sw.started = true
+		h := sw.w.Header()
+		if sw.format == FormatSSE {
+			h.Set("Content-Type", "text/event-stream")
+		} else {
+			h.Set("Content-Type", "application/x-ndjson")
+		}
+		h.Set("Cache-Control", "no-cache")
+		// Keep nginx-style proxies from buffering the stream.
+		h.Set("X-Accel-Buffering", "no")
+		sw.w.WriteHeader(http.StatusOK)
+	}
+	if _, err := fmt.Fprintf(sw.w, format, args...); err != nil {
+		sw.err = err
+		return err
+	}
+	if err := http.NewResponseController(sw.w).Flush(); err != nil {
+		sw.err = err
+		return err
+	}
+	sw.last = time.Now()
+	return nil
+}
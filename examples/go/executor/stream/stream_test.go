@@ -0,0 +1,115 @@
+package stream
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNegotiate(t *testing.T) {
+	for _, tc := range []struct {
+		query, accept string
+		want          string
+		ok            bool
+	}{
+		{"", "application/json", "", false},
+		{"stream=ndjson", "", FormatNDJSON, true},
+		{"stream=sse", "application/x-ndjson", FormatSSE, true},
+		{"", "application/x-ndjson", FormatNDJSON, true},
+		{"", "text/event-stream", FormatSSE, true},
+		{"stream=xml", "", "", false},
+	} {
+		r := httptest.NewRequest("POST", "/simulate/batch?"+tc.query, nil)
+		r.Header.Set("Accept", tc.accept)
+		if got, ok := Negotiate(r); got != tc.want || ok != tc.ok {
+			t.Errorf("%q %q: got %q %v, want %q %v", tc.query, tc.accept, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestWriter_ndjson(t *testing.T) {
+	rec := httptest.NewRecorder()
+	sw := NewWriter(rec, FormatNDJSON, 0)
+	sw.Send("row", map[string]any{"index": 0})
+	sw.Send("trailer", map[string]any{"rows": 1})
+	sw.Close()
+
+	if ct := rec.Header().Get("Content-Type"); ct != "application/x-ndjson" || !rec.Flushed {
+		t.Errorf("expected flushed NDJSON, got %q flushed=%v", ct, rec.Flushed)
+	}
+	var types []string
+	sc := bufio.NewScanner(rec.Body)
+	for sc.Scan() {
+		var ev struct {
+			Type string         `json:"type"`
+			Data map[string]any `json:"data"`
+		}
+		if err := json.Unmarshal(sc.Bytes(), &ev); err != nil {
+			t.Fatalf("line %q: %v", sc.Text(), err)
+		}
+		types = append(types, ev.Type)
+	}
+	if strings.Join(types, ",") != "row,trailer" {
+		t.Errorf("got events %v", types)
+	}
+}
+
+func TestWriter_sse(t *testing.T) {
+	rec := httptest.NewRecorder()
+	sw := NewWriter(rec, FormatSSE, 0)
+	sw.Send("row", map[string]any{"index": 0})
+	sw.Close()
+
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("got content type %q", ct)
+	}
+	if got := rec.Body.String(); got != "event: row\ndata: {\"index\":0}\n\n" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestWriter_heartbeatsWhileIdle(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := NewWriter(w, FormatNDJSON, 10*time.Millisecond)
+		defer sw.Close()
+		time.Sleep(100 * time.Millisecond)
+		sw.Send("trailer", map[string]any{"rows": 0})
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	sc := bufio.NewScanner(resp.Body)
+	var lines []string
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	if len(lines) < 3 || lines[0] != `{"type":"heartbeat"}` || lines[len(lines)-1] != `{"type":"trailer","data":{"rows":0}}` {
+		t.Errorf("expected heartbeats before the trailer, got %q", lines)
+	}
+}
+
+func TestWriter_errorOnlyBeforeTheFirstEvent(t *testing.T) {
+	rec := httptest.NewRecorder()
+	sw := NewWriter(rec, FormatNDJSON, 0)
+	if !sw.Error("bad batch", http.StatusBadRequest) || rec.Code != http.StatusBadRequest {
+		t.Errorf("expected a 400, got %d", rec.Code)
+	}
+	if sw.Send("row", nil) == nil {
+		t.Error("expected no event after the error")
+	}
+
+	rec = httptest.NewRecorder()
+	sw = NewWriter(rec, FormatNDJSON, 0)
+	sw.Send("row", nil)
+	if sw.Error("too late", http.StatusBadRequest) || rec.Code != http.StatusOK {
+		t.Errorf("expected the stream kept, got %d", rec.Code)
+	}
+}
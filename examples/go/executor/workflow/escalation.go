@@ -0,0 +1,162 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"covenant-poc/executor/engine"
+)
+
+// EscalationWorkflow is the workflow type that sees an escalation through
+// to its resolution.
+const EscalationWorkflow = "escalation"
+
+// DecisionSignal carries a reviewer's Decision to an escalation workflow.
+const DecisionSignal = "decision"
+
+// Decision is a reviewer's resolution of an escalation.
+type Decision struct {
+	Decision      string `json:"decision"`
+	ResolvedBy    string `json:"resolved_by"`
+	Justification string `json:"justification"`
+}
+
+// Resolver resolves escalations; it is implemented by *engine.Engine.
+type Resolver interface {
+	ResolveEscalation(ctx context.Context, id, decision, by, justification string) (*engine.Escalation, error)
+	ResumeEscalation(ctx context.Context, id, by, justification string) (*engine.Escalation, error)
+}
+
+// Escalations is an engine.EscalationStore that starts a workflow for each
+// escalation enqueued in the store it wraps. The workflow waits for a
+// reviewer's decision, or the escalation's hard expiry, and resolves the
+// escalation, so that an approval survives a restart between the decision
+// and the execution: the decision is in the workflow's history, and the
+// resolution is retried until it is recorded in the store.
+type Escalations struct {
+	engine.EscalationStore
+	rt *Runtime
+}
+
+// NewEscalations wraps store to run its escalations as workflows of rt.
+// Register must be called before rt's workflows are resumed.
+func NewEscalations(store engine.EscalationStore, rt *Runtime) *Escalations {
+	return &Escalations{EscalationStore: store, rt: rt}
+}
+
+// Register registers the escalation workflow with the runtime, resolving
+// escalations with r.
+func (s *Escalations) Register(r Resolver) {
+	s.rt.Register(EscalationWorkflow, func(ctx *Context, input json.RawMessage) error {
+		var id string
+		if err := json.Unmarshal(input, &id); err != nil {
+			return err
+		}
+		return s.resolve(ctx, r, id)
+	})
+}
+
+// Resume continues the escalation workflows interrupted by a restart.
+func (s *Escalations) Resume(ctx context.Context) error {
+	return s.rt.Resume(ctx)
+}
+
+// Enqueue stores esc and starts its workflow. If the workflow cannot be
+// started, the escalation is still stored; Resolve starts it on demand.
+func (s *Escalations) Enqueue(ctx context.Context, esc *engine.Escalation) error {
+	if err := s.EscalationStore.Enqueue(ctx, esc); err != nil {
+		return err
+	}
+	return s.rt.Start(ctx, workflowID(esc.ID), EscalationWorkflow, esc.ID)
+}
+
+// Resolve hands a reviewer's decision to escalation id's workflow and
+// returns the escalation as it stands, still pending: the workflow resolves
+// it shortly after. It fails with engine.ErrStatusChanged if the
+// escalation is no longer pending. Of several decisions, the first the
+// workflow receives is the one applied.
+//
+// Resolve has the signature of Engine.ResolveEscalation, which it replaces
+// at POST /escalations/{id}/resolve.
+func (s *Escalations) Resolve(ctx context.Context, id, decision, by, justification string) (*engine.Escalation, error) {
+	if decision != engine.DecisionDeny && decision != engine.DecisionExecute {
+		return nil, fmt.Errorf("decision must be %s or %s, got %q", engine.DecisionDeny, engine.DecisionExecute, decision)
+	}
+	if strings.TrimSpace(justification) == "" {
+		return nil, engine.ErrJustificationRequired
+	}
+	esc, err := s.Escalation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if esc.Status != engine.EscalationPending {
+		return nil, fmt.Errorf("escalation %s is %s: %w", id, esc.Status, engine.ErrStatusChanged)
+	}
+	// Escalations enqueued before workflows were enabled have none yet.
+	if err := s.rt.Start(ctx, workflowID(id), EscalationWorkflow, id); err != nil {
+		return nil, err
+	}
+	d := Decision{Decision: decision, ResolvedBy: by, Justification: justification}
+	if err := s.rt.Signal(ctx, workflowID(id), DecisionSignal, d); err != nil {
+		if errors.Is(err, ErrCompleted) {
+			return nil, fmt.Errorf("escalation %s was resolved: %w", id, engine.ErrStatusChanged)
+		}
+		return nil, err
+	}
+	return esc, nil
+}
+
+// resolve is the escalation workflow.
+func (s *Escalations) resolve(ctx *Context, r Resolver, id string) error {
+	var esc engine.Escalation
+	err := ctx.Activity("load", func(ctx context.Context) (any, error) {
+		stored, err := s.Escalation(ctx, id)
+		if errors.Is(err, engine.ErrNotFound) {
+			return nil, Permanent(err)
+		}
+		return stored, err
+	}, &esc)
+	if err != nil || esc.Status != engine.EscalationPending {
+		return err
+	}
+
+	var d Decision
+	decided, err := ctx.AwaitSignal(DecisionSignal, esc.ExpiresAt, &d)
+	if err != nil {
+		return err
+	}
+	if !decided {
+		d = Decision{
+			Decision:      esc.OnExpiry,
+			ResolvedBy:    engine.ResolvedByExpiry,
+			Justification: fmt.Sprintf("pending past the hard expiry of queue %s at %s", esc.Queue, esc.ExpiresAt.Format(time.RFC3339)),
+		}
+	}
+	return ctx.Activity("resolve", func(ctx context.Context) (any, error) {
+		_, err := r.ResolveEscalation(ctx, id, d.Decision, d.ResolvedBy, d.Justification)
+		switch {
+		case errors.Is(err, engine.ErrJustificationRequired):
+			return nil, Permanent(err)
+		case !errors.Is(err, engine.ErrStatusChanged):
+			return nil, err
+		}
+		// An earlier attempt may have approved the escalation and been
+		// interrupted before executing it.
+		cur, err := s.Escalation(ctx, id)
+		if err != nil || cur.Status != engine.EscalationApproved || Attempt(ctx) == 1 {
+			return nil, err // otherwise resolved elsewhere, e.g. by the SLA sweep
+		}
+		if _, err := r.ResumeEscalation(ctx, id, d.ResolvedBy, d.Justification); !errors.Is(err, engine.ErrStatusChanged) {
+			return nil, err
+		}
+		return nil, nil
+	}, nil)
+}
+
+func workflowID(escalationID string) string {
+	return "escalation:" + escalationID
+}
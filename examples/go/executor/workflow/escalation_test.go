@@ -0,0 +1,147 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"covenant-poc/executor/engine"
+)
+
+// escalationStore is an in-memory engine.EscalationStore.
+type escalationStore struct {
+	mu   sync.Mutex
+	escs map[string]engine.Escalation
+}
+
+func (s *escalationStore) Enqueue(_ context.Context, esc *engine.Escalation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.escs[esc.ID] = *esc
+	return nil
+}
+
+func (s *escalationStore) Escalation(_ context.Context, id string) (*engine.Escalation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	esc, ok := s.escs[id]
+	if !ok {
+		return nil, engine.ErrNotFound
+	}
+	return &esc, nil
+}
+
+func (s *escalationStore) Escalations(context.Context, string, string) ([]*engine.Escalation, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (s *escalationStore) Transition(_ context.Context, esc *engine.Escalation, from string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.escs[esc.ID].Status != from {
+		return engine.ErrStatusChanged
+	}
+	s.escs[esc.ID] = *esc
+	return nil
+}
+
+// resolver approves escalations in two steps, like the engine, failing
+// between them the first interrupt times.
+type resolver struct {
+	store     *escalationStore
+	interrupt int
+	mu        sync.Mutex
+	calls     []string
+}
+
+func (r *resolver) ResolveEscalation(ctx context.Context, id, decision, by, justification string) (*engine.Escalation, error) {
+	r.mu.Lock()
+	r.calls = append(r.calls, fmt.Sprintf("resolve %s %s %s: %s", id, decision, by, justification))
+	interrupt := r.interrupt > 0
+	r.interrupt--
+	r.mu.Unlock()
+	esc, err := r.store.Escalation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if esc.Status != engine.EscalationPending {
+		return nil, engine.ErrStatusChanged
+	}
+	esc.Status = engine.EscalationApproved
+	if decision == engine.DecisionDeny {
+		esc.Status = engine.EscalationDenied
+	}
+	if err := r.store.Transition(ctx, esc, engine.EscalationPending); err != nil || esc.Status == engine.EscalationDenied {
+		return esc, err
+	}
+	if interrupt {
+		return nil, errors.New("connection reset")
+	}
+	return r.ResumeEscalation(ctx, id, by, justification)
+}
+
+func (r *resolver) ResumeEscalation(ctx context.Context, id, by, justification string) (*engine.Escalation, error) {
+	r.mu.Lock()
+	r.calls = append(r.calls, "resume "+id)
+	r.mu.Unlock()
+	esc, _ := r.store.Escalation(ctx, id)
+	esc.Status = engine.EscalationExecuted
+	return esc, r.store.Transition(ctx, esc, engine.EscalationApproved)
+}
+
+func escalations(t *testing.T, r *resolver) (*Escalations, *Runtime) {
+	t.Helper()
+	rt := NewRuntime(NewMemoryJournal(), WithRetryBackoff(time.Millisecond, time.Millisecond))
+	t.Cleanup(rt.Close)
+	s := NewEscalations(r.store, rt)
+	s.Register(r)
+	return s, rt
+}
+
+func TestEscalations_Resolve_resumesInterruptedApproval(t *testing.T) {
+	ctx := context.Background()
+	r := &resolver{store: &escalationStore{escs: map[string]engine.Escalation{}}, interrupt: 1}
+	s, rt := escalations(t, r)
+	if err := s.Enqueue(ctx, &engine.Escalation{ID: "esc_1", Status: engine.EscalationPending}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Resolve(ctx, "esc_1", engine.DecisionExecute, "alice", " "); !errors.Is(err, engine.ErrJustificationRequired) {
+		t.Errorf("expected ErrJustificationRequired, got %v", err)
+	}
+	esc, err := s.Resolve(ctx, "esc_1", engine.DecisionExecute, "alice", "verified")
+	if err != nil || esc.Status != engine.EscalationPending {
+		t.Fatalf("expected the escalation handed over pending, got %+v, %v", esc, err)
+	}
+	waitFor(t, rt, workflowID("esc_1"))
+
+	esc, _ = r.store.Escalation(ctx, "esc_1")
+	want := []string{"resolve esc_1 execute alice: verified", "resolve esc_1 execute alice: verified", "resume esc_1"}
+	if esc.Status != engine.EscalationExecuted || fmt.Sprint(r.calls) != fmt.Sprint(want) {
+		t.Errorf("expected the retry to resume the approval, got %s after %q", esc.Status, r.calls)
+	}
+	if _, err := s.Resolve(ctx, "esc_1", engine.DecisionDeny, "bob", "late"); !errors.Is(err, engine.ErrStatusChanged) {
+		t.Errorf("expected ErrStatusChanged, got %v", err)
+	}
+}
+
+func TestEscalations_resolvesAtHardExpiry(t *testing.T) {
+	ctx := context.Background()
+	r := &resolver{store: &escalationStore{escs: map[string]engine.Escalation{}}}
+	s, rt := escalations(t, r)
+	expires := time.Now().Add(10 * time.Millisecond).UTC()
+	esc := &engine.Escalation{ID: "esc_1", Queue: "review", Status: engine.EscalationPending, ExpiresAt: expires, OnExpiry: engine.DecisionDeny}
+	if err := s.Enqueue(ctx, esc); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, rt, workflowID("esc_1"))
+
+	esc, _ = r.store.Escalation(ctx, "esc_1")
+	want := fmt.Sprintf("resolve esc_1 deny sla-expiry: pending past the hard expiry of queue review at %s", expires.Format(time.RFC3339))
+	if esc.Status != engine.EscalationDenied || len(r.calls) != 1 || r.calls[0] != want {
+		t.Errorf("expected a denial at expiry, got %s after %q", esc.Status, r.calls)
+	}
+}
@@ -0,0 +1,184 @@
+package workflow
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// EventType is the kind of a history event.
+type EventType string
+
+const (
+	// EventStarted begins a history; its Name is the workflow type and its
+	// Payload the input.
+	EventStarted EventType = "started"
+	// EventActivityStarted is recorded before each attempt of activity Seq.
+	EventActivityStarted EventType = "activity_started"
+	// EventActivityCompleted records the result of activity Seq, or the
+	// Error it failed with for good.
+	EventActivityCompleted EventType = "activity_completed"
+	// EventSignaled records a signal sent to the workflow, whether or not
+	// it is awaiting one.
+	EventSignaled EventType = "signaled"
+	// EventSignalReceived records what wait Seq returned: the Payload of
+	// the signal it consumed, or TimedOut.
+	EventSignalReceived EventType = "signal_received"
+	// EventCompleted ends a history, with the Error the workflow returned.
+	EventCompleted EventType = "completed"
+)
+
+// Event is one entry of a workflow's history. Seq numbers the activities
+// and waits of a workflow in the order it makes them, which replay relies
+// on being the same every run.
+type Event struct {
+	Type     EventType       `json:"type"`
+	Seq      int             `json:"seq,omitempty"`
+	Name     string          `json:"name,omitempty"`
+	Payload  json.RawMessage `json:"payload,omitempty"`
+	Error    string          `json:"error,omitempty"`
+	TimedOut bool            `json:"timed_out,omitempty"`
+	At       time.Time       `json:"at"`
+}
+
+// Journal durably records workflow histories. A workflow is running from
+// its EventStarted until its EventCompleted.
+type Journal interface {
+	Append(ctx context.Context, id string, events ...Event) error
+	// History returns workflow id's events in the order appended, or none
+	// if it was never started.
+	History(ctx context.Context, id string) ([]Event, error)
+	// Running lists the workflows started but not completed.
+	Running(ctx context.Context) ([]string, error)
+}
+
+// MemoryJournal keeps histories in memory, for tests and for executors
+// that need no durability.
+type MemoryJournal struct {
+	mu     sync.Mutex
+	events map[string][]Event
+}
+
+func NewMemoryJournal() *MemoryJournal {
+	return &MemoryJournal{events: map[string][]Event{}}
+}
+
+func (j *MemoryJournal) Append(_ context.Context, id string, events ...Event) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.events[id] = append(j.events[id], events...)
+	return nil
+}
+
+func (j *MemoryJournal) History(_ context.Context, id string) ([]Event, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]Event(nil), j.events[id]...), nil
+}
+
+func (j *MemoryJournal) Running(context.Context) ([]string, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return running(j.events), nil
+}
+
+// FileJournal appends histories to a JSON Lines file, syncing each append,
+// and replays the file when opened so workflows survive a restart.
+type FileJournal struct {
+	mu     sync.Mutex
+	f      *os.File
+	events map[string][]Event
+}
+
+// fileEntry is a line of a FileJournal.
+type fileEntry struct {
+	Workflow string `json:"workflow"`
+	Event
+}
+
+// OpenFileJournal opens or creates the journal at path.
+func OpenFileJournal(path string) (*FileJournal, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	j := &FileJournal{f: f, events: map[string][]Event{}}
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 16<<20)
+	for line := 1; sc.Scan(); line++ {
+		var e fileEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		j.events[e.Workflow] = append(j.events[e.Workflow], e.Event)
+	}
+	if err := sc.Err(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return j, nil
+}
+
+func (j *FileJournal) Append(_ context.Context, id string, events ...Event) error {
+	var buf []byte
+	for _, e := range events {
+		line, err := json.Marshal(fileEntry{Workflow: id, Event: e})
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.f.Write(buf); err != nil {
+		return err
+	}
+	if err := j.f.Sync(); err != nil {
+		return err
+	}
+	j.events[id] = append(j.events[id], events...)
+	return nil
+}
+
+func (j *FileJournal) History(_ context.Context, id string) ([]Event, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]Event(nil), j.events[id]...), nil
+}
+
+func (j *FileJournal) Running(context.Context) ([]string, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return running(j.events), nil
+}
+
+func (j *FileJournal) Close() error {
+	return j.f.Close()
+}
+
+// running returns the ids of the started, uncompleted histories, sorted.
+func running(events map[string][]Event) []string {
+	var ids []string
+	for id, h := range events {
+		if len(h) > 0 && !completed(h) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func completed(history []Event) bool {
+	for _, e := range history {
+		if e.Type == EventCompleted {
+			return true
+		}
+	}
+	return false
+}
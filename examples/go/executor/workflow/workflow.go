@@ -0,0 +1,481 @@
+// Package workflow runs long-lived processes, like an escalation awaiting
+// its reviewer, as durable workflows that survive executor restarts.
+//
+// It follows the programming model of workflow engines such as Temporal: a
+// workflow is ordinary Go code whose side effects are activities and whose
+// waits are signals with deadlines. Each activity result and each signal
+// received is recorded in a Journal before the workflow moves on. After a
+// restart, Resume runs each unfinished workflow again from the start,
+// returning the recorded results in place of repeating the work, until it
+// reaches the point where it stopped. Workflow code must therefore be
+// deterministic: anything that varies, like reading a store or calling a
+// port, belongs in an activity.
+//
+// Activities are retried with backoff until they succeed or fail with a
+// Permanent error, and run at least once: an attempt interrupted before its
+// result is recorded is repeated.
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Default retry backoff of activities, doubling from the first to the cap.
+const (
+	defaultBackoff    = time.Second
+	defaultMaxBackoff = time.Minute
+)
+
+// ErrUnknownWorkflow is returned for a workflow that was never started.
+var ErrUnknownWorkflow = errors.New("unknown workflow")
+
+// ErrCompleted is returned when signaling a workflow that has completed.
+var ErrCompleted = errors.New("workflow completed")
+
+// Func is the code of a workflow type, run with the input it was started
+// with.
+type Func func(ctx *Context, input json.RawMessage) error
+
+// Runtime runs the workflows recorded in a Journal.
+type Runtime struct {
+	journal    Journal
+	backoff    time.Duration
+	maxBackoff time.Duration
+
+	// ctx is canceled by Close, interrupting every run.
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// mu guards types and live, and orders signals with the start and
+	// completion of runs.
+	mu    sync.Mutex
+	types map[string]Func
+	live  map[string]*run
+}
+
+// Option configures a Runtime.
+type Option func(*Runtime)
+
+// WithRetryBackoff sets how long a failed activity waits before its first
+// retry, doubling with each attempt up to max.
+func WithRetryBackoff(initial, max time.Duration) Option {
+	return func(rt *Runtime) { rt.backoff, rt.maxBackoff = initial, max }
+}
+
+func NewRuntime(j Journal, opts ...Option) *Runtime {
+	ctx, cancel := context.WithCancel(context.Background())
+	rt := &Runtime{
+		journal:    j,
+		backoff:    defaultBackoff,
+		maxBackoff: defaultMaxBackoff,
+		ctx:        ctx,
+		cancel:     cancel,
+		types:      map[string]Func{},
+		live:       map[string]*run{},
+	}
+	for _, opt := range opts {
+		opt(rt)
+	}
+	return rt
+}
+
+// Register makes fn the code of workflow type name. Types must be
+// registered before workflows of them are started or resumed.
+func (rt *Runtime) Register(name string, fn Func) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.types[name] = fn
+}
+
+// Start starts workflow id of type name with input, marshaled as JSON.
+// Starting a workflow that was already started does nothing, so callers
+// may start one whenever they are unsure it is.
+func (rt *Runtime) Start(ctx context.Context, id, name string, input any) error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if _, ok := rt.types[name]; !ok {
+		return fmt.Errorf("workflow type %q is not registered", name)
+	}
+	if rt.live[id] != nil {
+		return nil
+	}
+	history, err := rt.journal.History(ctx, id)
+	if err != nil {
+		return err
+	}
+	if completed(history) {
+		return nil
+	}
+	if len(history) == 0 {
+		payload, err := json.Marshal(input)
+		if err != nil {
+			return err
+		}
+		started := Event{Type: EventStarted, Name: name, Payload: payload, At: time.Now().UTC()}
+		if err := rt.journal.Append(ctx, id, started); err != nil {
+			return err
+		}
+		history = []Event{started}
+	}
+	return rt.spawn(id, history)
+}
+
+// Resume runs every workflow the journal records as started but not
+// completed, as after a restart. Workflows already running are left alone.
+func (rt *Runtime) Resume(ctx context.Context) error {
+	ids, err := rt.journal.Running(ctx)
+	if err != nil {
+		return err
+	}
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	var errs []error
+	for _, id := range ids {
+		if rt.live[id] != nil {
+			continue
+		}
+		history, err := rt.journal.History(ctx, id)
+		if err == nil {
+			err = rt.spawn(id, history)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("workflow %s: %w", id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Signal sends workflow id the signal name with payload, marshaled as
+// JSON. The signal is recorded whether or not the workflow is awaiting it;
+// the next AwaitSignal for name receives it.
+func (rt *Runtime) Signal(ctx context.Context, id, name string, payload any) error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	r := rt.live[id]
+	if r == nil {
+		history, err := rt.journal.History(ctx, id)
+		switch {
+		case err != nil:
+			return err
+		case len(history) == 0:
+			return fmt.Errorf("%w: %s", ErrUnknownWorkflow, id)
+		case completed(history):
+			return fmt.Errorf("%w: %s", ErrCompleted, id)
+		}
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	e := Event{Type: EventSignaled, Name: name, Payload: data, At: time.Now().UTC()}
+	if err := rt.journal.Append(ctx, id, e); err != nil {
+		return err
+	}
+	if r != nil {
+		r.add(e)
+	}
+	return nil
+}
+
+// Wait blocks until workflow id's current run ends or ctx is done.
+func (rt *Runtime) Wait(ctx context.Context, id string) error {
+	rt.mu.Lock()
+	r := rt.live[id]
+	rt.mu.Unlock()
+	if r == nil {
+		return nil
+	}
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close interrupts every run and waits for them to stop. Their histories
+// are left as they are, for Resume to continue.
+func (rt *Runtime) Close() {
+	rt.cancel()
+	rt.wg.Wait()
+}
+
+// spawn runs workflow id from history in a new goroutine. rt.mu is held.
+func (rt *Runtime) spawn(id string, history []Event) error {
+	if len(history) == 0 || history[0].Type != EventStarted {
+		return fmt.Errorf("history of workflow %s does not begin with %s", id, EventStarted)
+	}
+	fn, ok := rt.types[history[0].Name]
+	if !ok {
+		return fmt.Errorf("workflow type %q is not registered", history[0].Name)
+	}
+	r := &run{id: id, history: history, wake: make(chan struct{}, 1), done: make(chan struct{})}
+	rt.live[id] = r
+	rt.wg.Add(1)
+	go rt.execute(fn, r, history[0].Payload)
+	return nil
+}
+
+// execute runs r to its end and records how it ended, unless it was
+// interrupted, in which case it is continued by the next Resume.
+func (rt *Runtime) execute(fn Func, r *run, input json.RawMessage) {
+	defer rt.wg.Done()
+	err := fn(&Context{rt: rt, run: r}, input)
+
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	defer close(r.done)
+	delete(rt.live, r.id)
+	var stop *interruption
+	if errors.As(err, &stop) {
+		if rt.ctx.Err() == nil {
+			log.Printf("Workflow %s interrupted: %v", r.id, stop.err)
+		}
+		return
+	}
+	e := Event{Type: EventCompleted, At: time.Now().UTC()}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	if err := rt.journal.Append(rt.ctx, r.id, e); err != nil {
+		log.Printf("Workflow %s: record completion: %v", r.id, err)
+	}
+}
+
+// run is a workflow being executed.
+type run struct {
+	id   string
+	wake chan struct{} // signaled when a signal is added
+	done chan struct{} // closed when the run ends
+
+	mu      sync.Mutex
+	history []Event
+}
+
+func (r *run) add(e Event) {
+	r.mu.Lock()
+	r.history = append(r.history, e)
+	r.mu.Unlock()
+	if e.Type == EventSignaled {
+		select {
+		case r.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// find returns the event of type typ for Seq seq.
+func (r *run) find(typ EventType, seq int) (Event, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range r.history {
+		if e.Type == typ && e.Seq == seq {
+			return e, true
+		}
+	}
+	return Event{}, false
+}
+
+// attempts counts the attempts made of activity seq.
+func (r *run) attempts(seq int) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, e := range r.history {
+		if e.Type == EventActivityStarted && e.Seq == seq {
+			n++
+		}
+	}
+	return n
+}
+
+// unreceived returns the payload of the oldest signal name not yet
+// received.
+func (r *run) unreceived(name string) (json.RawMessage, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	received := 0
+	for _, e := range r.history {
+		if e.Type == EventSignalReceived && e.Name == name && !e.TimedOut {
+			received++
+		}
+	}
+	for _, e := range r.history {
+		if e.Type == EventSignaled && e.Name == name {
+			if received == 0 {
+				return e.Payload, true
+			}
+			received--
+		}
+	}
+	return nil, false
+}
+
+// interruption stops a run without completing it: the runtime was closed,
+// or the journal failed.
+type interruption struct{ err error }
+
+func (i *interruption) Error() string { return "interrupted: " + i.err.Error() }
+func (i *interruption) Unwrap() error { return i.err }
+
+// Context is a workflow's handle on the runtime. Its methods must be called
+// from the workflow's own goroutine, in the same order on every run.
+type Context struct {
+	rt  *Runtime
+	run *run
+	seq int
+}
+
+// ID returns the workflow's id.
+func (c *Context) ID() string { return c.run.id }
+
+// record journals e and adds it to the run's history.
+func (c *Context) record(e Event) error {
+	e.At = time.Now().UTC()
+	if err := c.rt.journal.Append(c.rt.ctx, c.run.id, e); err != nil {
+		return &interruption{err}
+	}
+	c.run.add(e)
+	return nil
+}
+
+// Activity runs fn, the side effect name, and unmarshals its result into
+// result, which may be nil. On replay the recorded result is returned
+// instead of running fn again. A failed fn is retried with backoff until
+// it succeeds or returns a Permanent error, which Activity returns.
+func (c *Context) Activity(name string, fn func(ctx context.Context) (any, error), result any) error {
+	c.seq++
+	seq := c.seq
+	if e, ok := c.run.find(EventActivityCompleted, seq); ok {
+		return activityResult(e, result)
+	}
+	for attempt := c.run.attempts(seq) + 1; ; attempt++ {
+		if err := c.record(Event{Type: EventActivityStarted, Seq: seq, Name: name}); err != nil {
+			return err
+		}
+		out, err := fn(context.WithValue(c.rt.ctx, attemptKey{}, attempt))
+		var data []byte
+		if err == nil {
+			data, err = json.Marshal(out)
+		}
+		var perm *permanent
+		switch {
+		case err == nil, errors.As(err, &perm):
+			e := Event{Type: EventActivityCompleted, Seq: seq, Name: name, Payload: data}
+			if err != nil {
+				e.Error = err.Error()
+			}
+			if err := c.record(e); err != nil {
+				return err
+			}
+			return activityResult(e, result)
+		case c.rt.ctx.Err() != nil:
+			return &interruption{c.rt.ctx.Err()}
+		}
+		log.Printf("Workflow %s: activity %s attempt %d: %v", c.run.id, name, attempt, err)
+		select {
+		case <-time.After(c.rt.retryDelay(attempt)):
+		case <-c.rt.ctx.Done():
+			return &interruption{c.rt.ctx.Err()}
+		}
+	}
+}
+
+func activityResult(e Event, result any) error {
+	if e.Error != "" {
+		return fmt.Errorf("activity %s: %s", e.Name, e.Error)
+	}
+	if result == nil || len(e.Payload) == 0 {
+		return nil
+	}
+	return json.Unmarshal(e.Payload, result)
+}
+
+// retryDelay is how long to wait after failed attempt n.
+func (rt *Runtime) retryDelay(n int) time.Duration {
+	d := rt.backoff
+	for i := 1; i < n && d < rt.maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, rt.maxBackoff)
+}
+
+// AwaitSignal waits for the signal name and unmarshals its payload into v,
+// reporting whether one arrived before deadline. A zero deadline waits
+// indefinitely.
+func (c *Context) AwaitSignal(name string, deadline time.Time, v any) (bool, error) {
+	c.seq++
+	seq := c.seq
+	if e, ok := c.run.find(EventSignalReceived, seq); ok {
+		return signalResult(e, v)
+	}
+	for {
+		if payload, ok := c.run.unreceived(name); ok {
+			e := Event{Type: EventSignalReceived, Seq: seq, Name: name, Payload: payload}
+			if err := c.record(e); err != nil {
+				return false, err
+			}
+			return signalResult(e, v)
+		}
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if !deadline.IsZero() {
+			wait := time.Until(deadline)
+			if wait <= 0 {
+				e := Event{Type: EventSignalReceived, Seq: seq, Name: name, TimedOut: true}
+				if err := c.record(e); err != nil {
+					return false, err
+				}
+				return false, nil
+			}
+			timer = time.NewTimer(wait)
+			timeout = timer.C
+		}
+		select {
+		case <-c.run.wake:
+		case <-timeout:
+		case <-c.rt.ctx.Done():
+			return false, &interruption{c.rt.ctx.Err()}
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+func signalResult(e Event, v any) (bool, error) {
+	if e.TimedOut {
+		return false, nil
+	}
+	if v == nil || len(e.Payload) == 0 {
+		return true, nil
+	}
+	return true, json.Unmarshal(e.Payload, v)
+}
+
+type attemptKey struct{}
+
+// Attempt returns which attempt of an activity ctx belongs to, counting
+// from 1 across restarts.
+func Attempt(ctx context.Context) int {
+	n, _ := ctx.Value(attemptKey{}).(int)
+	return n
+}
+
+// permanent marks an activity failure that is not retried.
+type permanent struct{ err error }
+
+func (p *permanent) Error() string { return p.err.Error() }
+func (p *permanent) Unwrap() error { return p.err }
+
+// Permanent marks err as an activity failure that retrying cannot fix.
+func Permanent(err error) error {
+	return &permanent{err}
+}
@@ -0,0 +1,161 @@
+package workflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// waitFor fails t unless workflow id's run ends within a few seconds.
+func waitFor(t *testing.T, rt *Runtime, id string) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := rt.Wait(ctx, id); err != nil {
+		t.Fatalf("workflow %s did not finish: %v", id, err)
+	}
+}
+
+// lastEvent returns the last event of workflow id's history.
+func lastEvent(t *testing.T, j Journal, id string) Event {
+	t.Helper()
+	h, err := j.History(context.Background(), id)
+	if err != nil || len(h) == 0 {
+		t.Fatalf("no history for %s: %v", id, err)
+	}
+	return h[len(h)-1]
+}
+
+func TestRuntime_Resume_replaysRecordedActivities(t *testing.T) {
+	ctx := context.Background()
+	journal, err := OpenFileJournal(filepath.Join(t.TempDir(), "workflows.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var charged, notified int
+	awaiting := make(chan struct{}, 1)
+	register := func(rt *Runtime) {
+		rt.Register("order", func(ctx *Context, input json.RawMessage) error {
+			var receipt string
+			if err := ctx.Activity("charge", func(context.Context) (any, error) {
+				charged++
+				return "rcpt_1", nil
+			}, &receipt); err != nil {
+				return err
+			}
+			awaiting <- struct{}{}
+			var who string
+			if ok, err := ctx.AwaitSignal("ship", time.Time{}, &who); err != nil || !ok {
+				return err
+			}
+			return ctx.Activity("notify", func(context.Context) (any, error) {
+				notified++
+				if receipt != "rcpt_1" || who != "carol" {
+					return nil, Permanent(errors.New("replayed the wrong values"))
+				}
+				return nil, nil
+			}, nil)
+		})
+	}
+
+	rt := NewRuntime(journal)
+	register(rt)
+	if err := rt.Start(ctx, "order_1", "order", map[string]any{"amount": 10}); err != nil {
+		t.Fatal(err)
+	}
+	<-awaiting
+	rt.Close()
+	journal.Close()
+
+	// After a restart, the charge is replayed rather than repeated.
+	journal, err = OpenFileJournal(journal.f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer journal.Close()
+	if running, _ := journal.Running(ctx); !reflect.DeepEqual(running, []string{"order_1"}) {
+		t.Fatalf("expected order_1 running, got %v", running)
+	}
+	rt = NewRuntime(journal)
+	defer rt.Close()
+	register(rt)
+	if err := rt.Resume(ctx); err != nil {
+		t.Fatal(err)
+	}
+	<-awaiting
+	if err := rt.Signal(ctx, "order_1", "ship", "carol"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, rt, "order_1")
+
+	if charged != 1 || notified != 1 {
+		t.Errorf("expected each activity run once, got charge %d and notify %d", charged, notified)
+	}
+	if e := lastEvent(t, journal, "order_1"); e.Type != EventCompleted || e.Error != "" {
+		t.Errorf("expected a clean completion, got %+v", e)
+	}
+	if err := rt.Signal(ctx, "order_1", "ship", "dave"); !errors.Is(err, ErrCompleted) {
+		t.Errorf("expected ErrCompleted signaling a completed workflow, got %v", err)
+	}
+	if err := rt.Signal(ctx, "order_2", "ship", "dave"); !errors.Is(err, ErrUnknownWorkflow) {
+		t.Errorf("expected ErrUnknownWorkflow, got %v", err)
+	}
+}
+
+func TestContext_Activity_retriesUntilPermanent(t *testing.T) {
+	ctx := context.Background()
+	journal := NewMemoryJournal()
+	rt := NewRuntime(journal, WithRetryBackoff(time.Millisecond, time.Millisecond))
+	defer rt.Close()
+	var attempts []int
+	rt.Register("flaky", func(ctx *Context, _ json.RawMessage) error {
+		return ctx.Activity("call", func(ctx context.Context) (any, error) {
+			attempts = append(attempts, Attempt(ctx))
+			if len(attempts) < 3 {
+				return nil, errors.New("unavailable")
+			}
+			return nil, Permanent(errors.New("rejected"))
+		}, nil)
+	})
+	if err := rt.Start(ctx, "f", "flaky", nil); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, rt, "f")
+
+	if !reflect.DeepEqual(attempts, []int{1, 2, 3}) {
+		t.Errorf("got attempts %v", attempts)
+	}
+	if e := lastEvent(t, journal, "f"); e.Type != EventCompleted || e.Error != "activity call: rejected" {
+		t.Errorf("expected the permanent failure recorded, got %+v", e)
+	}
+	// Starting it again does nothing.
+	if err := rt.Start(ctx, "f", "flaky", nil); err != nil || len(attempts) != 3 {
+		t.Errorf("expected no second run, got %v and %d attempts", err, len(attempts))
+	}
+}
+
+func TestContext_AwaitSignal_timesOut(t *testing.T) {
+	ctx := context.Background()
+	journal := NewMemoryJournal()
+	rt := NewRuntime(journal)
+	defer rt.Close()
+	rt.Register("deadline", func(ctx *Context, _ json.RawMessage) error {
+		ok, err := ctx.AwaitSignal("go", time.Now().Add(10*time.Millisecond), nil)
+		if err == nil && ok {
+			return errors.New("expected a timeout")
+		}
+		return err
+	})
+	if err := rt.Start(ctx, "d", "deadline", nil); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, rt, "d")
+	h, _ := journal.History(ctx, "d")
+	if len(h) != 3 || !h[1].TimedOut || h[2].Error != "" {
+		t.Errorf("expected a timeout then completion, got %+v", h)
+	}
+}